@@ -1,17 +1,28 @@
 package main
 
 import (
+	"bytes"
+	"cmp"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"maps"
+	"net"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -40,35 +51,164 @@ type ObservationAdditionResult struct {
 type KnowledgeGraphManager struct {
 	storage    storage.Storage
 	memoryPath string
+	namespace  string      // the namespace storage is scoped to
+	session    *sessionLog // shared by every namespace view
 }
 
+// SessionChanges lists what was added to one namespace through the manager
+// since startup or the last ResetSession. Observations include those sent with
+// create_entities that the stored entity kept.
+type SessionChanges struct {
+	Since        time.Time           `json:"since"`
+	Entities     []SessionEntity     `json:"entities"` // created, or merged into by create_entities
+	Observations map[string][]string `json:"observations"`
+	Relations    []storage.Relation  `json:"relations"`
+}
+
+// SessionEntity is an entity recorded in SessionChanges
+type SessionEntity struct {
+	Name       string `json:"name"`
+	EntityType string `json:"entityType"`
+}
+
+// sessionLog keeps the SessionChanges of each namespace in memory only; the
+// store and its timestamps are never touched
+type sessionLog struct {
+	mu      sync.Mutex
+	started time.Time
+	changes map[string]*SessionChanges
+}
+
+func newSessionLog() *sessionLog {
+	return &sessionLog{started: time.Now(), changes: make(map[string]*SessionChanges)}
+}
+
+// namespace returns the changes recorded for ns, creating them; callers hold mu
+func (l *sessionLog) namespace(ns string) *SessionChanges {
+	changes, ok := l.changes[ns]
+	if !ok {
+		changes = &SessionChanges{Since: l.started, Observations: make(map[string][]string)}
+		l.changes[ns] = changes
+	}
+	return changes
+}
+
+// recordEntities records created or merged entities with the observations of
+// sent that they kept
+func (l *sessionLog) recordEntities(ns string, sent, stored []storage.Entity) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	changes := l.namespace(ns)
+	for _, entity := range stored {
+		if !slices.ContainsFunc(changes.Entities, func(e SessionEntity) bool { return e.Name == entity.Name }) {
+			changes.Entities = append(changes.Entities, SessionEntity{Name: entity.Name, EntityType: entity.EntityType})
+		}
+		for _, s := range sent {
+			if s.Name != entity.Name {
+				continue
+			}
+			for _, obs := range s.Observations {
+				if slices.Contains(entity.Observations, obs) && !slices.Contains(changes.Observations[entity.Name], obs) {
+					changes.Observations[entity.Name] = append(changes.Observations[entity.Name], obs)
+				}
+			}
+		}
+	}
+}
+
+// recordObservations records observations added to existing entities
+func (l *sessionLog) recordObservations(ns string, added map[string][]string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	changes := l.namespace(ns)
+	for name, observations := range added {
+		for _, obs := range observations {
+			if !slices.Contains(changes.Observations[name], obs) {
+				changes.Observations[name] = append(changes.Observations[name], obs)
+			}
+		}
+	}
+}
+
+// recordRelations records created relations
+func (l *sessionLog) recordRelations(ns string, relations []storage.Relation) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	changes := l.namespace(ns)
+	changes.Relations = append(changes.Relations, relations...)
+}
+
+// snapshot returns a copy of the changes recorded for ns
+func (l *sessionLog) snapshot(ns string) SessionChanges {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	changes := l.namespace(ns)
+	observations := make(map[string][]string, len(changes.Observations))
+	for name, obs := range changes.Observations {
+		observations[name] = slices.Clone(obs)
+	}
+	return SessionChanges{
+		Since:        changes.Since,
+		Entities:     append([]SessionEntity{}, changes.Entities...),
+		Observations: observations,
+		Relations:    append([]storage.Relation{}, changes.Relations...),
+	}
+}
+
+// reset forgets the changes recorded for ns and starts its session now
+func (l *sessionLog) reset(ns string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.changes[ns] = &SessionChanges{Since: time.Now(), Observations: make(map[string][]string)}
+}
+
+// ConfigOption customizes the storage configuration built by NewKnowledgeGraphManager
+type ConfigOption func(*storage.Config)
+
 // NewKnowledgeGraphManager creates a new manager with auto-detection of storage type
-func NewKnowledgeGraphManager(memoryPath string, storageType string, autoMigrate bool) (*KnowledgeGraphManager, error) {
+func NewKnowledgeGraphManager(memoryPath string, storageType string, autoMigrate bool, opts ...ConfigOption) (*KnowledgeGraphManager, error) {
 	// Resolve memory path
 	resolvedPath := resolveMemoryPath(memoryPath)
 	var finalPath string
 
 	// Auto-detect storage type if not specified
-	if storageType == "" {
+	detected := storageType == ""
+	if detected {
 		storageType, finalPath = detectStorageType(resolvedPath, autoMigrate)
 	} else {
 		finalPath = resolvedPath
 		// Handle SQLite path adjustment for explicit storage type
 		if storageType == "sqlite" && !strings.HasSuffix(resolvedPath, ".db") {
-			finalPath = strings.TrimSuffix(resolvedPath, filepath.Ext(resolvedPath)) + ".db"
+			finalPath = sqlitePathFor(resolvedPath)
 		}
 	}
 
+	// Create storage configuration
+	config := storage.Config{
+		Type:           storageType,
+		FilePath:       finalPath,
+		AutoMigrate:    autoMigrate,
+		MigrationBatch: 1000,
+		WALMode:        true,
+		CacheSize:      10000,
+		BusyTimeout:    5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
 	// Handle auto-migration BEFORE creating storage
 	if autoMigrate && storageType == "sqlite" && resolvedPath != finalPath {
 		// Check if we need to migrate
 		if _, err := os.Stat(resolvedPath); err == nil {
 			if _, err := os.Stat(finalPath); os.IsNotExist(err) {
 				log.Printf("Performing seamless migration from %s to %s...", resolvedPath, finalPath)
-				if err := performSeamlessMigration(resolvedPath, finalPath); err != nil {
+				if err := performSeamlessMigration(resolvedPath, finalPath, config); err != nil {
 					log.Printf("Migration failed, falling back to JSONL: %v", err)
 					storageType = "jsonl"
 					finalPath = resolvedPath
+					config.Type = storageType
+					config.FilePath = finalPath
 				} else {
 					log.Printf("Migration completed successfully! Now using SQLite for better performance.")
 				}
@@ -76,31 +216,41 @@ func NewKnowledgeGraphManager(memoryPath string, storageType string, autoMigrate
 		}
 	}
 
-	// Create storage configuration
-	config := storage.Config{
-		Type:           storageType,
-		FilePath:       finalPath,
-		AutoMigrate:    autoMigrate,
-		MigrationBatch: 1000,
-		WALMode:        true,
-		CacheSize:      10000,
-		BusyTimeout:    5 * time.Second,
-	}
-
 	// Create storage instance
 	store, err := storage.NewStorage(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create storage: %w", err)
 	}
 
-	// Initialize storage
+	// Initialize storage. SQLite that auto-detection chose over a JSONL file falls
+	// back to that file rather than taking the server down.
 	if err := store.Initialize(); err != nil {
-		return nil, fmt.Errorf("failed to initialize storage: %w", err)
+		if !detected || storageType != "sqlite" || finalPath == resolvedPath {
+			return nil, fmt.Errorf("failed to initialize storage: %w", err)
+		}
+		store.Close()
+		log.Printf("WARNING: SQLite storage at %s failed to initialize (%v); falling back to JSONL at %s. Changes made now will not be in the SQLite database.", finalPath, err, resolvedPath)
+
+		config.Type = "jsonl"
+		config.FilePath = resolvedPath
+		finalPath = resolvedPath
+		if store, err = storage.NewStorage(config); err != nil {
+			return nil, fmt.Errorf("failed to create storage: %w", err)
+		}
+		if err := store.Initialize(); err != nil {
+			return nil, fmt.Errorf("failed to initialize storage: %w", err)
+		}
 	}
 
+	namespace := config.Namespace
+	if namespace == "" {
+		namespace = storage.DefaultNamespace
+	}
 	return &KnowledgeGraphManager{
 		storage:    store,
 		memoryPath: finalPath,
+		namespace:  namespace,
+		session:    newSessionLog(),
 	}, nil
 }
 
@@ -144,8 +294,14 @@ func detectStorageType(memoryPath string, autoMigrate bool) (storageType string,
 		return "sqlite", memoryPath
 	}
 
+	// Compressed JSONL (.jsonl.gz, .json.gz) is an explicit choice to stay on JSONL,
+	// and with auto-migrate off the extension alone decides: no .db is used or created
+	if storage.IsCompressedPath(memoryPath) || !autoMigrate {
+		return "jsonl", memoryPath
+	}
+
 	// Generate SQLite path from JSONL path
-	sqlitePath := strings.TrimSuffix(memoryPath, filepath.Ext(memoryPath)) + ".db"
+	sqlitePath := sqlitePathFor(memoryPath)
 
 	// Check if SQLite database already exists
 	if _, err := os.Stat(sqlitePath); err == nil {
@@ -153,21 +309,219 @@ func detectStorageType(memoryPath string, autoMigrate bool) (storageType string,
 		return "sqlite", sqlitePath
 	}
 
-	// If auto-migrate is enabled and JSONL file exists, migrate to SQLite
-	if autoMigrate {
-		if _, err := os.Stat(memoryPath); err == nil {
-			log.Printf("Auto-migrating %s to SQLite for better performance...", memoryPath)
-			return "sqlite", sqlitePath // Return SQLite path for migration
-		}
+	// If the JSONL file exists, migrate it to SQLite
+	if _, err := os.Stat(memoryPath); err == nil {
+		log.Printf("Auto-migrating %s to SQLite for better performance...", memoryPath)
+		return "sqlite", sqlitePath // Return SQLite path for migration
 	}
 
-	// Default to JSONL for new installations or when auto-migrate is disabled
+	// Default to JSONL for new installations
 	return "jsonl", memoryPath
 }
 
-// performSeamlessMigration performs migration with minimal user disruption
-func performSeamlessMigration(jsonlPath, sqlitePath string) error {
-	config := storage.Config{MigrationBatch: 1000}
+// resolveAutoMigrate applies the MCP_AUTO_MIGRATE environment variable unless
+// --auto-migrate was given explicitly (the flag always wins)
+func resolveAutoMigrate(flagValue bool, flagSet bool) (bool, error) {
+	env := os.Getenv("MCP_AUTO_MIGRATE")
+	if flagSet || env == "" {
+		return flagValue, nil
+	}
+	value, err := strconv.ParseBool(env)
+	if err != nil {
+		return false, fmt.Errorf("invalid MCP_AUTO_MIGRATE %q: use true or false", env)
+	}
+	return value, nil
+}
+
+// resolveAuthBearer picks the Bearer token from --auth-bearer, then
+// --auth-bearer-file, then MEMORY_MCP_AUTH_BEARER. The first one set wins;
+// the others are reported in the warnings, as is a token file other users can read.
+func resolveAuthBearer(flagValue, path string) (string, []string, error) {
+	type source struct{ name, token string }
+	var sources []source
+	var warnings []string
+
+	if flagValue != "" {
+		sources = append(sources, source{"--auth-bearer", flagValue})
+	}
+	if path != "" {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read --auth-bearer-file: %w", err)
+		}
+		if info.Mode().Perm()&0077 != 0 {
+			warnings = append(warnings, fmt.Sprintf("--auth-bearer-file %s is accessible by other users (mode %v); restrict it with chmod 600", path, info.Mode().Perm()))
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read --auth-bearer-file: %w", err)
+		}
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return "", nil, fmt.Errorf("--auth-bearer-file %s is empty", path)
+		}
+		sources = append(sources, source{"--auth-bearer-file", token})
+	}
+	if env := strings.TrimSpace(os.Getenv("MEMORY_MCP_AUTH_BEARER")); env != "" {
+		sources = append(sources, source{"MEMORY_MCP_AUTH_BEARER", env})
+	}
+
+	if len(sources) == 0 {
+		return "", warnings, nil
+	}
+	for _, ignored := range sources[1:] {
+		if ignored.token != sources[0].token {
+			warnings = append(warnings, fmt.Sprintf("%s and %s set different Bearer tokens; using %s", sources[0].name, ignored.name, sources[0].name))
+		}
+	}
+	return sources[0].token, warnings, nil
+}
+
+// resolveEncryptionKey reads the memory encryption passphrase from path
+// (--encryption-key-file or --new-encryption-key-file, named by flagName), or
+// from MEMORY_MCP_ENCRYPTION_KEY when path is empty. "" means no encryption.
+// A key file other users can read is reported in the warnings.
+func resolveEncryptionKey(flagName, path string) (string, []string, error) {
+	if path == "" {
+		return strings.TrimSpace(os.Getenv("MEMORY_MCP_ENCRYPTION_KEY")), nil, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read %s: %w", flagName, err)
+	}
+	var warnings []string
+	if info.Mode().Perm()&0077 != 0 {
+		warnings = append(warnings, fmt.Sprintf("%s %s is accessible by other users (mode %v); restrict it with chmod 600", flagName, path, info.Mode().Perm()))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read %s: %w", flagName, err)
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return "", nil, fmt.Errorf("%s %s is empty", flagName, path)
+	}
+	return key, warnings, nil
+}
+
+// encryptedStorageType returns the backend for an encrypted store at
+// memoryPath. Encryption at rest is JSONL only (modernc.org/sqlite has no
+// SQLCipher), so SQLite is an error, as is a SQLite database auto-detection
+// would otherwise pick up.
+func encryptedStorageType(memoryPath, storageType string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(memoryPath))
+	if storageType == "sqlite" || ext == ".db" || ext == ".sqlite" || ext == ".sqlite3" {
+		return "", fmt.Errorf("encryption at rest requires JSONL storage; SQLite databases cannot be encrypted")
+	}
+	if storageType == "" {
+		if _, err := os.Stat(sqlitePathFor(memoryPath)); err == nil {
+			return "", fmt.Errorf("encryption at rest requires JSONL storage, but the data is in %s; write it out with the backup tool and move the database away first", sqlitePathFor(memoryPath))
+		}
+	}
+	return "jsonl", nil
+}
+
+// backendMarkerPath is the file beside memoryPath that remembers its backend
+func backendMarkerPath(memoryPath string) string {
+	return memoryPath + ".backend"
+}
+
+// resolveBackendChoice settles auto-migration for the JSON/JSONL file at
+// memoryPath. An explicit choice (--auto-migrate, --no-auto-migrate, or
+// MCP_AUTO_MIGRATE) is recorded in the backend marker as "jsonl" or "sqlite";
+// without one, the marker left by an earlier run decides, so restarts never
+// re-decide. SQLite paths need no marker. The marker gets mode, as set by
+// --file-mode (0 = 0644 for a new marker; an existing one keeps its own).
+func resolveBackendChoice(memoryPath string, autoMigrate, explicit bool, mode os.FileMode) (bool, error) {
+	switch strings.ToLower(filepath.Ext(memoryPath)) {
+	case ".db", ".sqlite", ".sqlite3":
+		return autoMigrate, nil
+	}
+	markerPath := backendMarkerPath(memoryPath)
+
+	if explicit {
+		backend := "jsonl"
+		if autoMigrate {
+			backend = "sqlite"
+		}
+		if data, err := os.ReadFile(markerPath); err == nil && strings.TrimSpace(string(data)) == backend {
+			return autoMigrate, nil
+		}
+		err := os.WriteFile(markerPath, []byte(backend+"\n"), cmp.Or(mode, 0644))
+		if err == nil && mode != 0 {
+			// Explicit, so the umask cannot change the requested mode
+			err = os.Chmod(markerPath, mode)
+		}
+		if err != nil {
+			log.Printf("WARNING: could not record backend %s in %s (%v); later runs without an explicit setting will decide again", backend, markerPath, err)
+		} else {
+			log.Printf("Recorded backend %s for %s in %s", backend, memoryPath, markerPath)
+		}
+		return autoMigrate, nil
+	}
+
+	data, err := os.ReadFile(markerPath)
+	if os.IsNotExist(err) {
+		return autoMigrate, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read backend marker: %w", err)
+	}
+	switch backend := strings.TrimSpace(string(data)); backend {
+	case "jsonl":
+		return false, nil
+	case "sqlite":
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid backend %q in %s: use jsonl or sqlite, or delete the file", backend, markerPath)
+	}
+}
+
+// loadEntityTemplates reads the --entity-templates JSON file, an object mapping
+// entity types to the observations new entities of that type start with
+func loadEntityTemplates(path string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read entity templates: %w", err)
+	}
+	var templates map[string][]string
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return nil, fmt.Errorf("invalid entity templates %s: expected an object of entity type to observation list: %w", path, err)
+	}
+	return templates, nil
+}
+
+// loadStopwords reads a stopword list: one word per line, blank lines and
+// lines starting with # ignored
+func loadStopwords(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stopwords: %w", err)
+	}
+	stopwords := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if word := strings.TrimSpace(line); word != "" && !strings.HasPrefix(word, "#") {
+			stopwords = append(stopwords, word)
+		}
+	}
+	return stopwords, nil
+}
+
+// sqlitePathFor returns the .db path that sits next to a JSONL path
+// (memory.json, memory.jsonl.gz -> memory.db)
+func sqlitePathFor(jsonlPath string) string {
+	base := jsonlPath
+	if storage.IsCompressedPath(base) {
+		base = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	return strings.TrimSuffix(base, filepath.Ext(base)) + ".db"
+}
+
+// performSeamlessMigration performs migration with minimal user disruption. The
+// database and the JSONL backup get the FileMode of base (0 = the default), and
+// the database its FTS setting.
+func performSeamlessMigration(jsonlPath, sqlitePath string, base storage.Config) error {
+	config := storage.Config{MigrationBatch: 1000, FileMode: base.FileMode, FTS: base.FTS}
 	migrator := storage.NewMigrator(config)
 
 	// Only show important progress, not every step
@@ -190,6 +544,94 @@ func performSeamlessMigration(jsonlPath, sqlitePath string) error {
 	return nil
 }
 
+// namespaceKey is the context key namespaceMiddleware stores a call's namespace under
+type namespaceKey struct{}
+
+// namespaceMiddleware validates a tool call's optional "namespace" argument and
+// passes it to the handler in the context, where manager.In picks it up
+func namespaceMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if ns := request.GetString("namespace", ""); ns != "" {
+			if err := storage.ValidateNamespace(ns); err != nil {
+				return nil, err
+			}
+			ctx = context.WithValue(ctx, namespaceKey{}, ns)
+		}
+		return next(ctx, request)
+	}
+}
+
+// In returns the manager scoped to the namespace of the tool call in ctx, or m
+// itself when the call did not name one
+func (m *KnowledgeGraphManager) In(ctx context.Context) *KnowledgeGraphManager {
+	ns, ok := ctx.Value(namespaceKey{}).(string)
+	if !ok {
+		return m
+	}
+	return &KnowledgeGraphManager{storage: m.storage.WithNamespace(ns), memoryPath: m.memoryPath, namespace: ns, session: m.session}
+}
+
+// WithObservationOrder returns a manager whose open_nodes and read_graph reads
+// list observations oldest or newest first ("" = oldest)
+func (m *KnowledgeGraphManager) WithObservationOrder(order string) (*KnowledgeGraphManager, error) {
+	if err := storage.ValidateObservationOrder(order); err != nil {
+		return nil, err
+	}
+	if order == "" {
+		return m, nil
+	}
+	return &KnowledgeGraphManager{storage: m.storage.WithObservationOrder(order), memoryPath: m.memoryPath, namespace: m.namespace, session: m.session}, nil
+}
+
+// TransferEntities copies or moves entities from fromNamespace ("" = the manager's
+// namespace) to toNamespace
+func (m *KnowledgeGraphManager) TransferEntities(names []string, fromNamespace, toNamespace string, opts storage.TransferOptions) (*storage.TransferResult, error) {
+	source := m.storage
+	if fromNamespace != "" {
+		if err := storage.ValidateNamespace(fromNamespace); err != nil {
+			return nil, err
+		}
+		source = source.WithNamespace(fromNamespace)
+	}
+	return source.TransferEntities(names, toNamespace, opts)
+}
+
+// Backup writes every namespace to a timestamped JSONL file in dir, keeping the
+// newest keep backups there
+func (m *KnowledgeGraphManager) Backup(dir string, keep int) (string, error) {
+	return storage.BackupGraph(m.storage, dir, keep)
+}
+
+// ExportEntityContext writes name and its neighborhood within depth hops to a file in dir
+func (m *KnowledgeGraphManager) ExportEntityContext(name string, depth int, dir, format string) (*storage.EntityContextExport, error) {
+	return storage.ExportEntityContext(m.storage, name, depth, dir, format)
+}
+
+// AdjacencyList maps each entity matching query and filter to its outgoing relations among them
+func (m *KnowledgeGraphManager) AdjacencyList(query string, filter storage.TypeFilter) (map[string][]storage.Adjacent, error) {
+	return storage.AdjacencyList(m.storage, query, filter)
+}
+
+// SimilarEntities scores other entities by the neighbors and observation words they share with name
+func (m *KnowledgeGraphManager) SimilarEntities(name, by string, limit int) ([]storage.SimilarEntity, error) {
+	return storage.SimilarEntities(m.storage, name, by, limit)
+}
+
+// TopicSummary returns the most frequent words, and optionally bigrams, across all observations
+func (m *KnowledgeGraphManager) TopicSummary(opts storage.TopicOptions) (*storage.TopicSummaryResult, error) {
+	return storage.TopicSummary(m.storage, opts)
+}
+
+// WriteNDJSON streams the graph to w as newline-delimited JSON
+func (m *KnowledgeGraphManager) WriteNDJSON(w io.Writer) error {
+	return storage.WriteNDJSON(m.storage, w)
+}
+
+// Namespaces lists the namespaces in the store
+func (m *KnowledgeGraphManager) Namespaces() ([]string, error) {
+	return m.storage.Namespaces()
+}
+
 // Close closes the storage
 func (m *KnowledgeGraphManager) Close() error {
 	if m.storage != nil {
@@ -200,16 +642,68 @@ func (m *KnowledgeGraphManager) Close() error {
 
 // CreateEntities creates multiple new entities
 func (m *KnowledgeGraphManager) CreateEntities(entities []storage.Entity) ([]storage.Entity, error) {
-	return m.storage.CreateEntities(entities)
+	created, err := m.storage.CreateEntities(entities)
+	if err != nil {
+		return nil, err
+	}
+	m.session.recordEntities(m.namespace, entities, created)
+	return created, nil
+}
+
+// CreateEntitiesWithStrategy creates entities, merging into existing ones by strategy
+func (m *KnowledgeGraphManager) CreateEntitiesWithStrategy(entities []storage.Entity, strategy string) ([]storage.Entity, error) {
+	created, err := m.storage.CreateEntitiesWithStrategy(entities, strategy)
+	if err != nil {
+		return nil, err
+	}
+	m.session.recordEntities(m.namespace, entities, created)
+	return created, nil
 }
 
 // CreateRelations creates multiple new relations
 func (m *KnowledgeGraphManager) CreateRelations(relations []storage.Relation) ([]storage.Relation, error) {
-	return m.storage.CreateRelations(relations)
+	created, err := m.storage.CreateRelations(relations)
+	if err != nil {
+		return nil, err
+	}
+	m.session.recordRelations(m.namespace, created)
+	return created, nil
+}
+
+// CreatePlaceholders creates an entity of entityType, with no observations, for
+// each relation endpoint that does not exist yet and returns the ones it created
+func (m *KnowledgeGraphManager) CreatePlaceholders(relations []storage.Relation, entityType string) ([]storage.Entity, error) {
+	var names []string
+	for _, relation := range relations {
+		for _, name := range []string{relation.From, relation.To} {
+			if name != "" && !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	// A name-only lookup: OpenNodes would count this check as an access
+	existing, err := m.storage.EntityTypes(names)
+	if err != nil {
+		return nil, err
+	}
+	var placeholders []storage.Entity
+	for _, name := range names {
+		if _, ok := existing[name]; !ok {
+			placeholders = append(placeholders, storage.Entity{Name: name, EntityType: entityType, Observations: []string{}})
+		}
+	}
+	if len(placeholders) == 0 {
+		return nil, nil
+	}
+	return m.CreateEntities(placeholders)
 }
 
-// AddObservations adds new observations to existing entities
-func (m *KnowledgeGraphManager) AddObservations(additions []ObservationAddition) ([]ObservationAdditionResult, error) {
+// AddObservations adds new observations to existing entities, attributed to source (may be empty)
+func (m *KnowledgeGraphManager) AddObservations(additions []ObservationAddition, source string) ([]ObservationAdditionResult, error) {
 	// Convert to storage format
 	obsMap := make(map[string][]string)
 	for _, addition := range additions {
@@ -217,10 +711,11 @@ func (m *KnowledgeGraphManager) AddObservations(additions []ObservationAddition)
 	}
 
 	// Add observations
-	added, err := m.storage.AddObservations(obsMap)
+	added, err := m.storage.AddObservations(obsMap, source)
 	if err != nil {
 		return nil, err
 	}
+	m.session.recordObservations(m.namespace, added)
 
 	// Convert back to legacy format
 	results := make([]ObservationAdditionResult, 0, len(added))
@@ -234,24 +729,102 @@ func (m *KnowledgeGraphManager) AddObservations(additions []ObservationAddition)
 	return results, nil
 }
 
-// DeleteEntities deletes multiple entities and their associated relations
-func (m *KnowledgeGraphManager) DeleteEntities(entityNames []string) error {
+// SessionChanges returns what was added to the namespace since startup or the
+// last ResetSession
+func (m *KnowledgeGraphManager) SessionChanges() SessionChanges {
+	return m.session.snapshot(m.namespace)
+}
+
+// ResetSession starts a new session for the namespace, forgetting its recorded changes
+func (m *KnowledgeGraphManager) ResetSession() {
+	m.session.reset(m.namespace)
+}
+
+// DeleteEntities deletes multiple entities and their associated relations and
+// returns how many existed
+func (m *KnowledgeGraphManager) DeleteEntities(entityNames []string) (int, error) {
 	return m.storage.DeleteEntities(entityNames)
 }
 
-// DeleteObservations deletes specific observations from entities
-func (m *KnowledgeGraphManager) DeleteObservations(deletions []storage.ObservationDeletion) error {
+// DeleteByQuery deletes the entities matching query and entityType, or with
+// dryRun only lists them
+func (m *KnowledgeGraphManager) DeleteByQuery(query, entityType string, dryRun bool) (*storage.DeleteByQueryResult, error) {
+	return storage.DeleteByQuery(m.storage, query, entityType, dryRun)
+}
+
+// PreviewDeletion lists what deleting the entities and relations would remove, without deleting
+func (m *KnowledgeGraphManager) PreviewDeletion(names []string, relations []storage.Relation) (*storage.DeletionPreview, error) {
+	return storage.PreviewDeletion(m.storage, names, relations)
+}
+
+// DeleteObservations deletes matching observations from entities and returns
+// how many were deleted per entity
+func (m *KnowledgeGraphManager) DeleteObservations(deletions []storage.ObservationDeletion) (map[string]int, error) {
 	return m.storage.DeleteObservations(deletions)
 }
 
-// DeleteRelations deletes multiple relations
-func (m *KnowledgeGraphManager) DeleteRelations(relations []storage.Relation) error {
+// DeleteRelations deletes multiple relations and returns how many existed
+func (m *KnowledgeGraphManager) DeleteRelations(relations []storage.Relation) (int, error) {
 	return m.storage.DeleteRelations(relations)
 }
 
-// ReadGraph returns either a summary or full graph based on mode
-func (m *KnowledgeGraphManager) ReadGraph(mode string, limit int) (interface{}, error) {
-	return m.storage.ReadGraph(mode, limit)
+// DeleteRelationsByType deletes all relations of a type and returns how many were removed
+func (m *KnowledgeGraphManager) DeleteRelationsByType(relationType string) (int, error) {
+	return m.storage.DeleteRelationsByType(relationType)
+}
+
+// MergeRelationTypes rewrites relations of the alias types to canonical and returns how many changed
+func (m *KnowledgeGraphManager) MergeRelationTypes(canonical string, aliases []string) (int, error) {
+	return m.storage.MergeRelationTypes(canonical, aliases)
+}
+
+// ReadGraph returns either a summary or full graph based on mode, restricted by filter
+func (m *KnowledgeGraphManager) ReadGraph(mode string, limit int, filter storage.TypeFilter) (interface{}, error) {
+	return m.storage.ReadGraph(mode, limit, filter)
+}
+
+// ReadGraphAsOf is ReadGraph over only what had been created by asOf (SQLite only)
+func (m *KnowledgeGraphManager) ReadGraphAsOf(asOf time.Time, mode string, limit int, filter storage.TypeFilter) (interface{}, error) {
+	return m.storage.ReadGraphAsOf(asOf, mode, limit, filter)
+}
+
+// SearchNodesAsOf is SearchNodes over only what had been created by asOf (SQLite only)
+func (m *KnowledgeGraphManager) SearchNodesAsOf(asOf time.Time, query string, limit int) (storage.SearchResult, error) {
+	result, err := m.storage.SearchNodesAsOf(asOf, query, limit)
+	if err != nil {
+		return storage.SearchResult{}, err
+	}
+	return *result, nil
+}
+
+// ObservationsInRange returns the observations added between start and end (zero end = no upper bound)
+func (m *KnowledgeGraphManager) ObservationsInRange(start, end time.Time) ([]storage.ObservationWithEntity, error) {
+	return m.storage.ObservationsInRange(start, end)
+}
+
+// EntitiesModifiedSince returns the entities created or changed at or after since
+func (m *KnowledgeGraphManager) EntitiesModifiedSince(since time.Time) ([]storage.Entity, error) {
+	return m.storage.EntitiesModifiedSince(since)
+}
+
+// parseAsOf parses an asOf argument: an RFC 3339 timestamp or a date (end of that day, UTC)
+func parseAsOf(value string) (time.Time, error) {
+	return parseTimeArg("asOf", value, true)
+}
+
+// parseTimeArg parses the time argument name: an RFC 3339 timestamp or a date,
+// meaning the end of that day (UTC) when endOfDay is set and its start otherwise
+func parseTimeArg(name, value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if d, err := time.Parse(time.DateOnly, value); err == nil {
+		if endOfDay {
+			d = d.Add(24*time.Hour - time.Second)
+		}
+		return d, nil
+	}
+	return time.Time{}, fmt.Errorf("%w: %s must be an RFC 3339 timestamp or YYYY-MM-DD date, got %q", storage.ErrInvalidArgument, name, value)
 }
 
 // SearchNodes searches for nodes in the knowledge graph and returns lightweight summaries
@@ -263,6 +836,16 @@ func (m *KnowledgeGraphManager) SearchNodes(query string, limit int) (storage.Se
 	return *result, nil
 }
 
+// SearchNodesWithSnippets is SearchNodes with at most maxSnippets matched
+// observations per hit (0 = storage default)
+func (m *KnowledgeGraphManager) SearchNodesWithSnippets(query string, limit, maxSnippets int) (storage.SearchResult, error) {
+	result, err := m.storage.SearchNodesWithSnippets(query, limit, maxSnippets)
+	if err != nil {
+		return storage.SearchResult{}, err
+	}
+	return *result, nil
+}
+
 // OpenNodes opens specific nodes in the knowledge graph by their names
 func (m *KnowledgeGraphManager) OpenNodes(names []string) (storage.KnowledgeGraph, error) {
 	graph, err := m.storage.OpenNodes(names)
@@ -272,1050 +855,4556 @@ func (m *KnowledgeGraphManager) OpenNodes(names []string) (storage.KnowledgeGrap
 	return *graph, nil
 }
 
-func (m *KnowledgeGraphManager) MergeEntities(sourceName, targetName string) (*storage.MergeResult, error) {
-	return m.storage.MergeEntities(sourceName, targetName)
-}
+// withNeighborTypes sets FromType and ToType on the graph's relations, taking
+// the types of its own entities from the graph and looking up the rest
+func (m *KnowledgeGraphManager) withNeighborTypes(graph storage.KnowledgeGraph) (storage.KnowledgeGraph, error) {
+	types := make(map[string]string, len(graph.Entities))
+	for _, e := range graph.Entities {
+		types[e.Name] = e.EntityType
+	}
+	var neighbors []string
+	for _, r := range graph.Relations {
+		for _, name := range []string{r.From, r.To} {
+			if _, ok := types[name]; !ok && !slices.Contains(neighbors, name) {
+				neighbors = append(neighbors, name)
+			}
+		}
+	}
+	if len(neighbors) > 0 {
+		found, err := m.storage.EntityTypes(neighbors)
+		if err != nil {
+			return storage.KnowledgeGraph{}, err
+		}
+		maps.Copy(types, found)
+	}
 
-func (m *KnowledgeGraphManager) UpdateEntityType(name string, newType string) error {
-	return m.storage.UpdateEntityType(name, newType)
+	relations := make([]storage.Relation, len(graph.Relations))
+	for i, r := range graph.Relations {
+		r.FromType, r.ToType = types[r.From], types[r.To]
+		relations[i] = r
+	}
+	graph.Relations = relations
+	return graph, nil
 }
 
-func (m *KnowledgeGraphManager) UpdateObservation(entityName string, oldContent string, newContent string) error {
-	return m.storage.UpdateObservation(entityName, oldContent, newContent)
+// onlyInternalRelations drops relations whose endpoints are not both in the graph's
+// entity list, so the result is a self-contained subgraph with no dangling references
+func onlyInternalRelations(graph storage.KnowledgeGraph) storage.KnowledgeGraph {
+	names := make(map[string]bool, len(graph.Entities))
+	for _, e := range graph.Entities {
+		names[e.Name] = true
+	}
+
+	relations := []storage.Relation{}
+	for _, r := range graph.Relations {
+		if names[r.From] && names[r.To] {
+			relations = append(relations, r)
+		}
+	}
+	graph.Relations = relations
+	return graph
 }
 
-func (m *KnowledgeGraphManager) DetectConflicts(entityName string) ([]storage.Conflict, error) {
-	return m.storage.DetectConflicts(entityName)
+// orderRelations applies a relationOrder argument to relations in place: "stored"
+// (or empty) keeps storage order, "recent" puts the newest relations first
+func orderRelations(relations []storage.Relation, order string) error {
+	switch order {
+	case "", "stored":
+	case "recent":
+		storage.SortRelationsByRecency(relations)
+	default:
+		return fmt.Errorf("%w: relationOrder %q must be stored or recent", storage.ErrInvalidArgument, order)
+	}
+	return nil
 }
 
-// Version information
-var (
-	// version can be overridden by -ldflags "-X main.version=..."
-	version = "dev"
-	appName = "Memory MCP Server"
-)
-
-// printVersion prints version information
-func printVersion() {
-	fmt.Printf("%s version %s\n", appName, version)
+// deletionResult is the delete_entities and delete_relations result: how many
+// distinct items were requested, how many existed and were deleted, and a
+// summary such as "Deleted 2 of 3 requested entities; 1 not found"
+func deletionResult(kind string, requested, deleted int, backup string) (*mcp.CallToolResult, error) {
+	notFound := max(requested-deleted, 0)
+	message := fmt.Sprintf("Deleted %d of %d requested %s", deleted, requested, kind)
+	if notFound > 0 {
+		message += fmt.Sprintf("; %d not found", notFound)
+	}
+	resultJSON, err := json.MarshalIndent(struct {
+		Requested  int    `json:"requested"`
+		Deleted    int    `json:"deleted"`
+		NotFound   int    `json:"notFound"`
+		Message    string `json:"message"`
+		BackupPath string `json:"backupPath,omitempty"`
+	}{requested, deleted, notFound, message, backup}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
 }
 
-// printUsage prints a custom usage message
-func printUsage() {
-	fmt.Fprintf(os.Stderr, "Usage: %s [options]\n\n", os.Args[0])
-	fmt.Fprintf(os.Stderr, "%s is a Model Context Protocol server that provides knowledge graph management capabilities.\n\n", appName)
-	fmt.Fprintf(os.Stderr, "Options:\n")
-	flag.PrintDefaults()
+// onlyInternalRelatedHits replaces the related entities of a search result, which
+// are never hits themselves, with the relations whose endpoints are both hits.
+// Each is listed once, as outgoing from its source.
+func onlyInternalRelatedHits(result storage.SearchResult, relations []storage.Relation) storage.SearchResult {
+	types := make(map[string]string, len(result.Entities))
+	for _, e := range result.Entities {
+		types[e.Name] = e.EntityType
+	}
+
+	var related []storage.RelatedHit
+	seen := make(map[storage.Relation]bool)
+	for _, r := range relations {
+		key := storage.Relation{From: r.From, To: r.To, RelationType: r.RelationType}
+		toType, ok := types[r.To]
+		if _, fromHit := types[r.From]; !ok || !fromHit || seen[key] {
+			continue
+		}
+		seen[key] = true
+		related = append(related, storage.RelatedHit{
+			Name:         r.To,
+			EntityType:   toType,
+			RelationType: r.RelationType,
+			RelatedTo:    r.From,
+			Direction:    "outgoing",
+		})
+	}
+	result.RelatedEntities = related
+	return result
 }
 
-func main() {
-	var transport string
-	var memory string
-	var port int = 8080
-	var showVersion bool
-	var showHelp bool
-	var storageType string
-	var autoMigrate bool
-	var migrate string
-	var migrateTo string
-	var dryRun bool
-	var force bool
-	// HTTP transport options
-	var httpEndpoint string
-	var httpHeartbeat string
-	var httpStateless bool
-	// Auth options
-	var authBearer string
-	// OAuth options
-	var oauthUser string
-	var oauthPass string
-	var oauthIssuer string
-	// CORS options
-	var corsOrigin string
+// Seed imports a JSON or JSONL graph file into the store. Unless force is set, the
+// store is only seeded when it is empty, so restarts don't re-apply the seed.
+func (m *KnowledgeGraphManager) Seed(path string, force bool) error {
+	if !force {
+		result, err := m.storage.ReadGraph("summary", 0, storage.TypeFilter{})
+		if err != nil {
+			return err
+		}
+		if summary, ok := result.(*storage.GraphSummary); ok && (summary.TotalEntities > 0 || summary.TotalRelations > 0) {
+			log.Printf("Store already has %d entities, skipping seed (use --seed-force to import anyway)", summary.TotalEntities)
+			return nil
+		}
+	}
 
-	// Override the default usage message
-	flag.Usage = printUsage
+	graph, err := storage.LoadGraphFile(path)
+	if err != nil {
+		return err
+	}
+	report, err := m.storage.ImportData(graph, storage.ConflictModeOverwrite)
+	if err != nil {
+		return err
+	}
 
-	// Define command-line flags
-	flag.StringVar(&transport, "transport", "stdio", "Transport type (stdio, sse, or http)")
-	flag.StringVar(&transport, "t", "stdio", "Transport type (stdio, sse, or http)")
-	flag.StringVar(&memory, "memory", "", "Path to memory file")
-	flag.StringVar(&memory, "m", "", "Path to memory file")
-	flag.IntVar(&port, "port", 8080, "Port for SSE transport")
-	flag.IntVar(&port, "p", 8080, "Port for SSE transport")
-	flag.BoolVar(&showVersion, "version", false, "Show version information and exit")
-	flag.BoolVar(&showVersion, "v", false, "Show version information and exit")
-	flag.BoolVar(&showHelp, "help", false, "Show this help message and exit")
-	flag.BoolVar(&showHelp, "h", false, "Show this help message and exit")
+	log.Printf("Seeded %d entities and %d relations from %s (%d entities updated, %d type conflicts)",
+		report.EntitiesCreated, report.RelationsCreated, path, report.EntitiesUpdated, len(report.TypeConflicts))
+	return nil
+}
 
-	// New storage-related flags
-	flag.StringVar(&storageType, "storage", "", "Storage type (sqlite or jsonl, auto-detected if not specified)")
-	flag.BoolVar(&autoMigrate, "auto-migrate", true, "Automatically migrate from JSONL to SQLite")
-	flag.StringVar(&migrate, "migrate", "", "Migrate data from JSONL file to SQLite")
-	flag.StringVar(&migrateTo, "migrate-to", "", "Destination SQLite file for migration")
-	flag.BoolVar(&dryRun, "dry-run", false, "Perform a dry run of migration")
-	flag.BoolVar(&force, "force", false, "Force overwrite destination file during migration")
+// Limits for import_url downloads
+const (
+	remoteImportMaxBytes = 10 << 20 // of the graph, after any gzip decompression
+	remoteImportTimeout  = 30 * time.Second
+)
 
-	// HTTP transport flags
-	flag.StringVar(&httpEndpoint, "http-endpoint", "/mcp", "Streamable HTTP endpoint path (e.g. /mcp)")
-	flag.StringVar(&httpEndpoint, "http_ep", "/mcp", "Streamable HTTP endpoint path (alias)")
-	flag.StringVar(&httpHeartbeat, "http-heartbeat", "30s", "Streamable HTTP heartbeat interval, e.g. 30s, 1m")
-	flag.BoolVar(&httpStateless, "http-stateless", false, "Run Streamable HTTP in stateless mode (no session tracking)")
+// remoteImportClient returns the HTTP client import_url fetches with. Its dialer
+// checks every resolved address, redirects included, so a URL cannot reach the
+// server's own loopback, private network, or link-local metadata endpoints.
+// Proxies from the environment are ignored since they would bypass the check.
+func remoteImportClient() *http.Client {
+	dialer := &net.Dialer{Timeout: remoteImportTimeout, Control: rejectInternalAddress}
+	return &http.Client{Transport: &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}}
+}
 
-	// Auth flags
-	flag.StringVar(&authBearer, "auth-bearer", "", "Require Authorization: Bearer <token> for SSE/HTTP transports")
+// internalPrefixes are the IPv4 ranges rejectInternalAddress refuses beyond
+// what the netip predicates cover: "this network" and carrier-grade NAT
+var internalPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
 
-	// OAuth flags
-	flag.StringVar(&oauthUser, "oauth-user", "", "OAuth login username (env: OAUTH_USER)")
-	flag.StringVar(&oauthPass, "oauth-pass", "", "OAuth login password (env: OAUTH_PASS)")
-	flag.StringVar(&oauthIssuer, "oauth-issuer", "", "OAuth issuer URL, e.g. https://mcp.example.com (auto-detect if empty)")
+// embeddedIPv4Prefixes are IPv6 ranges that carry an IPv4 address in their low
+// 32 bits (IPv4-compatible, IPv4-translated, and NAT64), checked as that IPv4
+// address; IPv4-mapped addresses are unmapped first
+var embeddedIPv4Prefixes = []netip.Prefix{
+	netip.MustParsePrefix("::/96"),
+	netip.MustParsePrefix("::ffff:0:0:0/96"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
 
-	// CORS flags
-	flag.StringVar(&corsOrigin, "cors-origin", "*", "Allowed CORS origins: '*' for all, or comma-separated list")
+// rejectInternalAddress is a net.Dialer Control hook refusing connections to
+// loopback, private (RFC 1918 and fc00::/7), carrier-grade NAT, link-local, and
+// unspecified addresses, also when written as IPv4-mapped or embedded IPv6
+func rejectInternalAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err == nil {
+		ip = ip.WithZone("").Unmap()
+		for _, prefix := range embeddedIPv4Prefixes {
+			if prefix.Contains(ip) {
+				b := ip.As16()
+				ip = netip.AddrFrom4([4]byte(b[12:]))
+			}
+		}
+	}
+	if err != nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified() ||
+		slices.ContainsFunc(internalPrefixes, func(p netip.Prefix) bool { return p.Contains(ip) }) {
+		return fmt.Errorf("%w: %s is not a public address", storage.ErrInvalidArgument, host)
+	}
+	return nil
+}
 
-	flag.Parse()
+// RemoteImport is what fetchGraph downloaded and parsed
+type RemoteImport struct {
+	URL          string                `json:"url"`
+	Bytes        int                   `json:"bytes"`
+	SkippedLines []storage.LoadWarning `json:"skippedLines,omitempty"`
+}
 
-	// OAuth: environment variable fallback
-	if oauthUser == "" {
-		oauthUser = os.Getenv("OAUTH_USER")
+// fetchGraph downloads a JSON or JSONL graph (gzip-compressed or not) from an
+// http or https URL and parses it. The download fails past timeout or maxBytes,
+// on a non-200 response, and when nothing in it parses as an entity or relation.
+func fetchGraph(ctx context.Context, client *http.Client, rawURL string, maxBytes int64, timeout time.Duration) (*storage.KnowledgeGraph, *RemoteImport, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, nil, fmt.Errorf("%w: url must be an absolute http or https URL", storage.ErrInvalidArgument)
 	}
-	if oauthPass == "" {
-		oauthPass = os.Getenv("OAUTH_PASS")
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
 	}
-	if oauthIssuer == "" {
-		oauthIssuer = os.Getenv("OAUTH_ISSUER")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch %s: %w", u.Redacted(), err)
 	}
-
-	// Determine if OAuth is enabled
-	oauthEnabled := oauthUser != "" && oauthPass != ""
-	if (oauthUser != "") != (oauthPass != "") {
-		log.Fatal("Both --oauth-user and --oauth-pass must be provided together")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("failed to fetch %s: %s", u.Redacted(), resp.Status)
 	}
-	if oauthEnabled && authBearer != "" {
-		log.Fatal("--auth-bearer and --oauth-user/--oauth-pass are mutually exclusive")
+	if resp.ContentLength > maxBytes {
+		return nil, nil, fmt.Errorf("%w: %s is %d bytes, over the %d byte limit", storage.ErrInvalidArgument, u.Redacted(), resp.ContentLength, maxBytes)
 	}
 
-	// Parse CORS origins
-	var allowedOrigins []string
-	allowAllOrigins := corsOrigin == "*"
-	if !allowAllOrigins {
-		for _, o := range strings.Split(corsOrigin, ",") {
-			if o = strings.TrimSpace(o); o != "" {
-				allowedOrigins = append(allowedOrigins, o)
-			}
+	// Reading one byte past the limit tells a file at the limit from a larger one
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err == nil && bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		var zr *gzip.Reader
+		if zr, err = gzip.NewReader(bytes.NewReader(data)); err == nil {
+			data, err = io.ReadAll(io.LimitReader(zr, maxBytes+1))
+			zr.Close()
 		}
 	}
-
-	// In stdio mode, ensure logging doesn't interfere with MCP JSON-RPC
-	if transport == "stdio" {
-		// Set environment variable to track stdio mode for suppressing logs
-		os.Setenv("MCP_TRANSPORT", "stdio")
-		// Log output already goes to stderr by default, which is fine
-		// But we should suppress non-critical logging in stdio mode
-		log.SetOutput(os.Stderr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", u.Redacted(), err)
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, nil, fmt.Errorf("%w: %s is over the %d byte limit", storage.ErrInvalidArgument, u.Redacted(), maxBytes)
 	}
 
-	// Handle version flag
-	if showVersion {
-		printVersion()
-		os.Exit(0)
+	graph, warnings := storage.ParseGraph(data)
+	if len(graph.Entities) == 0 && len(graph.Relations) == 0 {
+		return nil, nil, fmt.Errorf("%w: %s holds no entities or relations (%d lines could not be parsed)", storage.ErrInvalidArgument, u.Redacted(), len(warnings))
 	}
+	return graph, &RemoteImport{URL: u.Redacted(), Bytes: len(data), SkippedLines: warnings}, nil
+}
 
-	// Handle help flag
-	if showHelp {
-		printUsage()
-		os.Exit(0)
+// observationSource returns the explicit source argument, or else the client name
+// the session reported at initialize, for attributing new observations
+func observationSource(ctx context.Context, source string) string {
+	if source != "" {
+		return source
 	}
+	if session, ok := server.ClientSessionFromContext(ctx).(server.SessionWithClientInfo); ok {
+		return session.GetClientInfo().Name
+	}
+	return ""
+}
 
-	// Handle migration command
-	if migrate != "" {
-		if migrateTo == "" {
-			migrateTo = strings.TrimSuffix(migrate, filepath.Ext(migrate)) + ".db"
+// withSource attributes every observation of entities that has no source yet to source
+func withSource(entities []storage.Entity, source string) []storage.Entity {
+	if source == "" {
+		return entities
+	}
+	for i, entity := range entities {
+		if entity.ObservationSources == nil {
+			entities[i].ObservationSources = make(map[string]string, len(entity.Observations))
 		}
-
-		cmd := storage.MigrateCommand{
-			Source:      migrate,
-			Destination: migrateTo,
-			DryRun:      dryRun,
-			Force:       force,
-			Verbose:     true,
+		for _, obs := range entity.Observations {
+			if entities[i].ObservationSources[obs] == "" {
+				entities[i].ObservationSources[obs] = source
+			}
 		}
+	}
+	return entities
+}
 
-		if err := storage.ExecuteMigration(cmd); err != nil {
-			log.Fatalf("Migration failed: %v", err)
-		}
+// Default per-call observation limits for one entity (see observationLimits)
+const (
+	defaultObservationSoftLimit = 1000
+	defaultObservationHardLimit = 10000
+)
 
-		os.Exit(0)
-	}
+// observationLimits guards against attaching an enormous observations array to
+// one entity in a single create_entities or add_observations call. Above soft
+// the call succeeds with a warning; above hard it is rejected. 0 disables a limit.
+type observationLimits struct {
+	soft, hard int
+}
 
-	// Create knowledge graph manager
-	manager, err := NewKnowledgeGraphManager(memory, storageType, autoMigrate)
-	if err != nil {
-		log.Fatalf("Failed to create knowledge graph manager: %v", err)
+// check returns a warning for each entity over the soft limit, or an error if
+// any is over the hard limit. counts maps entity names to observations sent.
+func (l observationLimits) check(counts map[string]int) ([]string, error) {
+	names := slices.Sorted(maps.Keys(counts))
+	var warnings []string
+	for _, name := range names {
+		n := counts[name]
+		switch {
+		case l.hard > 0 && n > l.hard:
+			return nil, fmt.Errorf("%w: %d observations for %q exceed the limit of %d per call; send them with add_observations in chunks of at most %d",
+				storage.ErrInvalidArgument, n, name, l.hard, l.chunkSize())
+		case l.soft > 0 && n > l.soft:
+			warnings = append(warnings, fmt.Sprintf("warning: %d observations for %q in one call is above the recommended %d; prefer add_observations in chunks of at most %d",
+				n, name, l.soft, l.chunkSize()))
+		}
 	}
-	defer manager.Close()
+	return warnings, nil
+}
 
-	// Create a new MCP server
-	s := server.NewMCPServer(
-		appName,
-		version,
-		server.WithResourceCapabilities(true, true),
-		server.WithPromptCapabilities(true),
-		server.WithLogging(),
-		server.WithRecovery(),
-	)
+// chunkSize is the observation batch size suggested in limit messages
+func (l observationLimits) chunkSize() int {
+	if l.soft > 0 {
+		return l.soft
+	}
+	return l.hard
+}
 
-	// ─── MCP Resources ─────────────────────────────────────────────────
-	// Resources allow AI clients to passively load memory context without
-	// explicitly calling tools, improving memory awareness and utilization.
+// parseFileMode parses --file-mode, an octal permission such as 0600; "" is 0,
+// which keeps the default modes
+func parseFileMode(s string) (os.FileMode, error) {
+	if s == "" {
+		return 0, nil
+	}
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode == 0 || mode > 0777 {
+		return 0, fmt.Errorf("%q is not an octal permission between 0001 and 0777", s)
+	}
+	return os.FileMode(mode), nil
+}
 
-	// Resource: Knowledge Graph Summary
-	s.AddResource(mcp.NewResource(
-		"memory://graph/summary",
-		"Knowledge Graph Summary",
-		mcp.WithResourceDescription("Overview of the knowledge graph including entity/relation counts, type distribution, and entity name list. Load this at the start of a conversation to understand what memories are available."),
-		mcp.WithMIMEType("application/json"),
-	), func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
-		result, err := manager.ReadGraph("summary", 50)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read graph summary: %w", err)
+// limitRequestBody rejects request bodies over maxBytes (0 = no limit) with a
+// 413. The body is buffered up front so oversized requests get a 413 instead
+// of a JSON-RPC parse error.
+func limitRequestBody(next http.Handler, maxBytes int64) http.Handler {
+	if maxBytes <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > maxBytes {
+			http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+			return
 		}
-		data, err := json.MarshalIndent(result, "", "  ")
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
 		if err != nil {
-			return nil, fmt.Errorf("failed to marshal graph summary: %w", err)
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
 		}
-		return []mcp.ResourceContents{
-			mcp.TextResourceContents{
-				URI:      "memory://graph/summary",
-				MIMEType: "application/json",
-				Text:     string(data),
-			},
-		}, nil
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
 	})
+}
 
-	// Resource: Entity type and relation type distribution
-	s.AddResource(mcp.NewResource(
-		"memory://graph/types",
-		"Entity & Relation Types",
-		mcp.WithResourceDescription("Lists all entity types and relation types currently in the knowledge graph with their counts. Useful for understanding the schema and maintaining consistent naming when creating new entities."),
-		mcp.WithMIMEType("application/json"),
-	), func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
-		result, err := manager.ReadGraph("summary", 1)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read graph types: %w", err)
-		}
-		// Extract just the type information from the summary
-		summary, ok := result.(*storage.GraphSummary)
-		if !ok {
-			return nil, fmt.Errorf("unexpected result type from ReadGraph")
+// parseKeepAlive parses --sse-keepalive: a duration, or 0 to disable keep-alives
+func parseKeepAlive(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("%q must not be negative (0 disables keep-alives)", s)
+	}
+	return d, nil
+}
+
+// toolSelection decides which tools are registered, from --tools: a
+// comma-separated list of tool names and the groups "read" (tools annotated
+// read-only) and "write" (all others). Entries prefixed with "-" are denied.
+// Without any allowed entry every tool starts out allowed; denials then win.
+type toolSelection struct {
+	allow map[string]bool // nil = allow all
+	deny  map[string]bool
+}
+
+// Tool groups for --tools
+const (
+	toolGroupRead  = "read"
+	toolGroupWrite = "write"
+)
+
+// parseToolSelection parses --tools ("" registers every tool)
+func parseToolSelection(spec string) (toolSelection, error) {
+	sel := toolSelection{deny: map[string]bool{}}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		name, denied := strings.CutPrefix(entry, "-")
+		name = strings.TrimSpace(name)
+		if name == "" {
+			if entry != "" {
+				return toolSelection{}, fmt.Errorf("empty tool name in %q", spec)
+			}
+			continue
 		}
-		typeInfo := map[string]interface{}{
-			"entityTypes":   summary.EntityTypes,
-			"relationTypes": summary.RelationTypes,
+		if denied {
+			sel.deny[name] = true
+			continue
 		}
-		data, err := json.MarshalIndent(typeInfo, "", "  ")
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal type info: %w", err)
+		if sel.allow == nil {
+			sel.allow = map[string]bool{}
 		}
-		return []mcp.ResourceContents{
-			mcp.TextResourceContents{
-				URI:      "memory://graph/types",
-				MIMEType: "application/json",
-				Text:     string(data),
-			},
-		}, nil
-	})
+		sel.allow[name] = true
+	}
+	return sel, nil
+}
 
-	// Resource Template: Individual entity details
-	s.AddResourceTemplate(mcp.NewResourceTemplate(
-		"memory://entities/{name}",
-		"Entity Details",
-		mcp.WithTemplateDescription("Get full details of a specific entity by name, including all observations and relations. Use the entity name from graph summary or search results."),
-		mcp.WithTemplateMIMEType("application/json"),
-	), func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
-		// Extract entity name from URI
-		uri := request.Params.URI
-		prefix := "memory://entities/"
-		if !strings.HasPrefix(uri, prefix) {
-			return nil, fmt.Errorf("invalid resource URI: %s", uri)
-		}
-		name := strings.TrimPrefix(uri, prefix)
-		if name == "" {
-			return nil, fmt.Errorf("entity name is required")
+// toolGroup returns the --tools group tool belongs to
+func toolGroup(tool mcp.Tool) string {
+	if hint := tool.Annotations.ReadOnlyHint; hint != nil && *hint {
+		return toolGroupRead
+	}
+	return toolGroupWrite
+}
+
+// allows reports whether tool is registered
+func (sel toolSelection) allows(tool mcp.Tool) bool {
+	group := toolGroup(tool)
+	if sel.deny[tool.Name] || sel.deny[group] {
+		return false
+	}
+	return sel.allow == nil || sel.allow[tool.Name] || sel.allow[group]
+}
+
+// check reports names in the selection that are neither a group nor one of
+// the registered tools, so a typo doesn't silently expose or hide a tool
+func (sel toolSelection) check(registered map[string]bool) error {
+	var unknown []string
+	for _, set := range []map[string]bool{sel.allow, sel.deny} {
+		for name := range set {
+			if name != toolGroupRead && name != toolGroupWrite && !registered[name] {
+				unknown = append(unknown, name)
+			}
 		}
+	}
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		return fmt.Errorf("unknown tools: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
 
-		// Open the entity
-		graph, err := manager.OpenNodes([]string{name})
-		if err != nil {
-			return nil, fmt.Errorf("failed to open entity %q: %w", name, err)
+// inverseRelations maps relation types to their inverses in both directions,
+// from --inverse-relations "parent_of=child_of,...". A parent_of B means B
+// child_of A, so it contradicts A child_of B and B parent_of A.
+type inverseRelations map[string]string
+
+// parseInverseRelations parses a comma-separated list of type=inverse pairs
+func parseInverseRelations(spec string) (inverseRelations, error) {
+	inverses := inverseRelations{}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
 		}
-		data, err := json.MarshalIndent(graph, "", "  ")
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal entity data: %w", err)
+		a, b, ok := strings.Cut(pair, "=")
+		a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+		if !ok || a == "" || b == "" {
+			return nil, fmt.Errorf("invalid pair %q: use type=inverse", pair)
 		}
-		return []mcp.ResourceContents{
-			mcp.TextResourceContents{
-				URI:      uri,
-				MIMEType: "application/json",
-				Text:     string(data),
-			},
-		}, nil
-	})
-
-	// ─── MCP Prompts ────────────────────────────────────────────────────
-	// Prompts provide standardized memory operation templates that appear
-	// as clickable actions in clients like Claude Desktop and VS Code.
-
-	// Prompt: Recall memories about a topic
-	s.AddPrompt(mcp.NewPrompt("memory-recall",
-		mcp.WithPromptDescription("Search and recall relevant memories from the knowledge graph based on a topic or question"),
-		mcp.WithArgument("topic",
-			mcp.ArgumentDescription("The topic, question, or keywords to search memories for"),
-			mcp.RequiredArgument(),
-		),
-	), func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
-		topic := request.Params.Arguments["topic"]
-		results, err := manager.SearchNodes(topic, 10)
-		if err != nil {
-			return nil, fmt.Errorf("failed to search for topic %q: %w", topic, err)
+		for t, inverse := range map[string]string{a: b, b: a} {
+			if existing, ok := inverses[t]; ok && existing != inverse {
+				return nil, fmt.Errorf("%q is already the inverse of %q", t, existing)
+			}
+			inverses[t] = inverse
 		}
+	}
+	return inverses, nil
+}
 
-		var promptText string
-		if results.Total == 0 {
-			promptText = fmt.Sprintf("I searched the knowledge graph for '%s' but found no matching memories. You may want to create new entities to store information about this topic.", topic)
-		} else {
-			var entityList strings.Builder
-			for i, entity := range results.Entities {
-				entityList.WriteString(fmt.Sprintf("\n%d. **%s** (%s) - %d observations, %d relations",
-					i+1, entity.Name, entity.EntityType, entity.ObservationsCount, entity.RelationsCount))
-				for _, snippet := range entity.Snippets {
-					entityList.WriteString(fmt.Sprintf("\n   > %s", snippet))
+// contradictions returns the relations that would contradict each relation:
+// its own reverse and the inverse type in the same direction. Symmetric types
+// (sibling_of=sibling_of) and types without an inverse have none.
+func (inv inverseRelations) contradictions(r storage.Relation) []storage.Relation {
+	inverse, ok := inv[r.RelationType]
+	if !ok || inverse == r.RelationType {
+		return nil
+	}
+	return []storage.Relation{
+		{From: r.From, To: r.To, RelationType: inverse},
+		{From: r.To, To: r.From, RelationType: r.RelationType},
+	}
+}
+
+// check rejects relations that contradict the stored graph or an earlier
+// relation in the same call
+func (inv inverseRelations) check(m *KnowledgeGraphManager, relations []storage.Relation) error {
+	type edge struct{ from, to, relationType string }
+	batch := make(map[edge]bool, len(relations))
+	for _, r := range relations {
+		for _, c := range inv.contradictions(r) {
+			exists := batch[edge{c.From, c.To, c.RelationType}]
+			if !exists {
+				var err error
+				if exists, err = m.RelationExists(c.From, c.To, c.RelationType); err != nil {
+					return err
 				}
 			}
-
-			// Collect entity names for the open_nodes suggestion
-			names := make([]string, len(results.Entities))
-			for i, e := range results.Entities {
-				names[i] = e.Name
+			if exists {
+				return fmt.Errorf("%w: %s %s %s contradicts %s %s %s (%s is the inverse of %s)", storage.ErrConflict,
+					r.From, r.RelationType, r.To, c.From, c.RelationType, c.To, inv[r.RelationType], r.RelationType)
 			}
+		}
+		batch[edge{r.From, r.To, r.RelationType}] = true
+	}
+	return nil
+}
 
-			promptText = fmt.Sprintf(`I searched the knowledge graph for '%s' and found %d matching entities:
-%s
+// inverses returns the inverse edge of each relation whose type has one
+func (inv inverseRelations) inverses(relations []storage.Relation) []storage.Relation {
+	var inverses []storage.Relation
+	for _, r := range relations {
+		if inverse, ok := inv[r.RelationType]; ok {
+			inverses = append(inverses, storage.Relation{From: r.To, To: r.From, RelationType: inverse})
+		}
+	}
+	return inverses
+}
 
-Please use the open_nodes tool to retrieve full details for the most relevant entities (suggested names: %s), then summarize the relevant memories for the user.`,
-				topic, results.Total, entityList.String(), strings.Join(names, ", "))
+// withWarnings appends each warning to result as an extra text block, leaving
+// the first block's JSON intact for clients that parse it
+func withWarnings(result *mcp.CallToolResult, warnings []string) *mcp.CallToolResult {
+	for _, w := range warnings {
+		result.Content = append(result.Content, mcp.NewTextContent(w))
+	}
+	return result
+}
+
+// flushWriter flushes an HTTP response after every write, so each NDJSON line
+// reaches the client as soon as it is encoded
+type flushWriter struct {
+	w       http.ResponseWriter
+	written bool
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	f.written = true
+	n, err := f.w.Write(p)
+	if flusher, ok := f.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return n, err
+}
+
+// ndjsonHandler serves the graph (GET, optional ?namespace=) as streamed NDJSON:
+// one entity or relation per line, so large graphs never become one JSON blob
+func ndjsonHandler(manager *KnowledgeGraphManager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		m := manager
+		if ns := r.URL.Query().Get("namespace"); ns != "" {
+			if err := storage.ValidateNamespace(ns); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			m = manager.In(context.WithValue(r.Context(), namespaceKey{}, ns))
 		}
 
-		return &mcp.GetPromptResult{
-			Description: fmt.Sprintf("Recall memories about: %s", topic),
-			Messages: []mcp.PromptMessage{
-				{
-					Role: mcp.RoleUser,
-					Content: mcp.TextContent{
-						Type: "text",
-						Text: promptText,
-					},
-				},
-			},
-		}, nil
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		out := &flushWriter{w: w}
+		if err := m.WriteNDJSON(out); err != nil {
+			if !out.written {
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			}
+			// Once lines are out the status is sent; the truncated stream is all the client sees
+			log.Printf("NDJSON stream failed: %v", err)
+		}
 	})
+}
 
-	// Prompt: Save conversation information as memories
-	s.AddPrompt(mcp.NewPrompt("memory-save",
-		mcp.WithPromptDescription("Analyze text and extract entities, relations, and observations to save as memories in the knowledge graph"),
-		mcp.WithArgument("content",
-			mcp.ArgumentDescription("The text or conversation content to extract memories from"),
-			mcp.RequiredArgument(),
-		),
-	), func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
-		content := request.Params.Arguments["content"]
-
-		promptText := fmt.Sprintf(`Analyze the following content and extract structured knowledge to save in the memory graph.
+// errToolDisabled is returned by tools that are turned off by server flags
+var errToolDisabled = errors.New("tool disabled")
+
+// toolErrorCode maps an error to a stable, machine-readable code for tool results
+func toolErrorCode(err error) string {
+	switch {
+	case errors.Is(err, storage.ErrEntityNotFound):
+		return "entity_not_found"
+	case errors.Is(err, storage.ErrObservationNotFound):
+		return "observation_not_found"
+	case errors.Is(err, storage.ErrInvalidArgument):
+		return "invalid_argument"
+	case errors.Is(err, storage.ErrConflict):
+		return "conflict"
+	case errors.Is(err, storage.ErrStorageLocked):
+		return "storage_locked"
+	case errors.Is(err, errToolDisabled):
+		return "tool_disabled"
+	default:
+		return "internal_error"
+	}
+}
 
-CONTENT TO ANALYZE:
----
-%s
----
+// toolErrorMiddleware turns handler errors into MCP tool error results carrying a
+// stable code, both as a text prefix and as "errorCode" in the result metadata
+func toolErrorMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := next(ctx, request)
+		if err == nil {
+			return result, nil
+		}
+		code := toolErrorCode(err)
+		result = mcp.NewToolResultError(fmt.Sprintf("%s: %v", code, err))
+		result.Meta = mcp.NewMetaFromMap(map[string]any{"errorCode": code})
+		return result, nil
+	}
+}
 
-INSTRUCTIONS:
-1. Identify key entities (people, technologies, projects, concepts, preferences, organizations)
-2. For each entity, determine:
-   - name: Clear, descriptive name
-   - entityType: Category (person, technology, project, concept, preference, organization)
-   - observations: Atomic facts about this entity from the content
-3. Identify relations between entities (use active voice: "works_on", "uses", "belongs_to", etc.)
-4. IMPORTANT: First use search_nodes to check if any of these entities already exist
-   - If an entity exists, use add_observations to add new facts
-   - If an entity is new, use create_entities to create it
-5. Use create_relations to connect related entities
+// timingMiddleware measures each tool call, nearly all of which is the storage
+// operation, logging the duration and adding it to the result metadata as durationMs
+func timingMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		start := time.Now()
+		result, err := next(ctx, request)
+		elapsed := time.Since(start)
+
+		log.Printf("Tool %s took %s", request.Params.Name, elapsed)
+		if result != nil {
+			if result.Meta == nil {
+				result.Meta = &mcp.Meta{}
+			}
+			if result.Meta.AdditionalFields == nil {
+				result.Meta.AdditionalFields = map[string]any{}
+			}
+			result.Meta.AdditionalFields["durationMs"] = float64(elapsed.Microseconds()) / 1000
+		}
+		return result, err
+	}
+}
 
-Please proceed to extract and save the memories.`, content)
+// idempotency replays the recorded result of a mutating tool call retried with
+// the same idempotencyKey within ttl, instead of running it again
+type idempotency struct {
+	manager *KnowledgeGraphManager
+	ttl     time.Duration   // 0 disables replay
+	tools   map[string]bool // tools that accept idempotencyKey
+	mu      sync.Mutex      // keyed calls run one at a time, so a concurrent retry sees the first result
+}
 
-		return &mcp.GetPromptResult{
-			Description: "Extract and save memories from content",
-			Messages: []mcp.PromptMessage{
-				{
-					Role: mcp.RoleUser,
-					Content: mcp.TextContent{
-						Type: "text",
-						Text: promptText,
-					},
-				},
-			},
-		}, nil
-	})
+// middleware must run inside namespaceMiddleware, which scopes keys to the call's namespace
+func (i *idempotency) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		tool := request.Params.Name
+		key := request.GetString("idempotencyKey", "")
+		if key == "" || i.ttl <= 0 || !i.tools[tool] {
+			return next(ctx, request)
+		}
 
-	// Prompt: Review memories about an entity
-	s.AddPrompt(mcp.NewPrompt("memory-review",
-		mcp.WithPromptDescription("Review and summarize all stored memories about a specific entity, including its observations and connections"),
-		mcp.WithArgument("entity_name",
-			mcp.ArgumentDescription("The exact name of the entity to review"),
-			mcp.RequiredArgument(),
-		),
-	), func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
-		entityName := request.Params.Arguments["entity_name"]
+		i.mu.Lock()
+		defer i.mu.Unlock()
 
-		graph, err := manager.OpenNodes([]string{entityName})
+		store := i.manager.In(ctx).storage
+		recorded, found, err := store.IdempotentResult(tool, key, i.ttl)
 		if err != nil {
-			return nil, fmt.Errorf("failed to open entity %q: %w", entityName, err)
+			return nil, err
 		}
-
-		if len(graph.Entities) == 0 {
-			return &mcp.GetPromptResult{
-				Description: fmt.Sprintf("No entity found: %s", entityName),
-				Messages: []mcp.PromptMessage{
-					{
-						Role: mcp.RoleUser,
-						Content: mcp.TextContent{
-							Type: "text",
-							Text: fmt.Sprintf("Entity '%s' was not found in the knowledge graph. Use search_nodes to find similar entity names.", entityName),
-						},
-					},
-				},
-			}, nil
+		if found {
+			var texts []string
+			if err := json.Unmarshal([]byte(recorded), &texts); err != nil {
+				return nil, fmt.Errorf("failed to decode recorded result: %w", err)
+			}
+			result := &mcp.CallToolResult{Result: mcp.Result{Meta: mcp.NewMetaFromMap(map[string]any{"idempotentReplay": true})}}
+			for _, text := range texts {
+				result.Content = append(result.Content, mcp.NewTextContent(text))
+			}
+			return result, nil
 		}
 
-		data, err := json.MarshalIndent(graph, "", "  ")
+		result, err := next(ctx, request)
+		if err != nil || result == nil || result.IsError {
+			return result, err // failures are not recorded, so a retry runs again
+		}
+		var texts []string
+		for _, content := range result.Content {
+			if text, ok := content.(mcp.TextContent); ok {
+				texts = append(texts, text.Text)
+			}
+		}
+		data, err := json.Marshal(texts)
+		if err == nil {
+			err = store.RecordIdempotentResult(tool, key, string(data), i.ttl)
+		}
 		if err != nil {
-			return nil, fmt.Errorf("failed to marshal entity data: %w", err)
+			log.Printf("Failed to record idempotency key %q for %s: %v", key, tool, err)
 		}
+		return result, nil
+	}
+}
 
-		promptText := fmt.Sprintf(`Review the following entity data from the knowledge graph and provide a clear summary:
+// ListEntities lists entities with filtering, sorting and paging
+func (m *KnowledgeGraphManager) ListEntities(opts storage.ListOptions) (*storage.EntityList, error) {
+	return m.storage.ListEntities(opts)
+}
 
-ENTITY DATA:
-%s
+// ListEntityNames returns a page of entity names sorted by name
+func (m *KnowledgeGraphManager) ListEntityNames(limit, offset int) (*storage.NameList, error) {
+	return m.storage.ListEntityNames(limit, offset)
+}
 
-Please summarize:
-1. What is this entity and what type is it?
-2. Key facts (observations) — highlight the most important ones
-3. How it connects to other entities (relations)
-4. Are there any observations that seem outdated or contradictory?
-5. Suggest any missing information that might be worth adding`, string(data))
+// ListRelations returns a page of relations, oldest first
+func (m *KnowledgeGraphManager) ListRelations(limit, offset int) (*storage.RelationPage, error) {
+	return m.storage.ListRelations(limit, offset)
+}
 
-		return &mcp.GetPromptResult{
-			Description: fmt.Sprintf("Review memories about: %s", entityName),
-			Messages: []mcp.PromptMessage{
-				{
-					Role: mcp.RoleUser,
-					Content: mcp.TextContent{
-						Type: "text",
-						Text: promptText,
-					},
-				},
-			},
-		}, nil
-	})
+// RecentlyModified returns the most recently updated entities
+func (m *KnowledgeGraphManager) RecentlyModified(limit int) ([]storage.Entity, error) {
+	return m.storage.RecentlyModified(limit)
+}
 
-	// ─── MCP Tools ──────────────────────────────────────────────────────
+// FindByObservation returns all entities containing the exact observation content
+func (m *KnowledgeGraphManager) FindByObservation(content string) ([]storage.Entity, error) {
+	return m.storage.FindByObservation(content)
+}
 
-	// Add create_entities tool
-	createEntitiesTool := mcp.NewTool("create_entities",
-		mcp.WithDescription(`Create new entities in the knowledge graph. Each entity has a unique name, a type, and observations (atomic facts).
+// GetObservations returns a page of an entity's observations in insertion order
+func (m *KnowledgeGraphManager) GetObservations(entityName string, offset, limit int) (*storage.ObservationPage, error) {
+	return m.storage.GetObservations(entityName, offset, limit)
+}
 
-BEFORE CREATING: Use search_nodes to check if the entity already exists. If it does, use add_observations to add new facts instead of creating a duplicate.
+// Traverse returns entities connected to an entity by a relation type in a direction
+func (m *KnowledgeGraphManager) Traverse(from string, relationType string, direction string) ([]storage.RelatedHit, error) {
+	return m.storage.Traverse(from, relationType, direction)
+}
 
-NAMING CONVENTIONS:
-- Use clear, descriptive names (e.g. "TypeScript", "ProjectAlpha", "JohnDoe")
-- entityType should be a lowercase category: "person", "technology", "project", "concept", "preference", "organization", "event", "location"
-- Each observation should be a single, atomic fact — not a paragraph
+// Reachable finds the entities within maxHops of start along one relation type
+func (m *KnowledgeGraphManager) Reachable(start string, relationType string, maxHops int, direction string) ([]storage.ReachableEntity, error) {
+	return m.storage.Reachable(start, relationType, maxHops, direction)
+}
 
-EXAMPLE:
-  name: "TypeScript", entityType: "technology"
-  observations: ["Preferred language for frontend development", "Used with React in current project"]`),
-		mcp.WithTitleAnnotation("Create Entities"),
-		mcp.WithDestructiveHintAnnotation(true),
-		mcp.WithArray("entities",
-			mcp.Required(),
-			mcp.Description("An array of entities to create"),
-			mcp.Items(map[string]any{
-				"type": "object",
-				"properties": map[string]any{
-					"name": map[string]any{
-						"type":        "string",
-						"description": "Unique name for the entity. Check with search_nodes first to avoid duplicates.",
-					},
-					"entityType": map[string]any{
-						"type":        "string",
-						"description": "Category of the entity (e.g. person, technology, project, concept, preference, organization)",
-					},
-					"observations": map[string]any{
-						"type":        "array",
-						"description": "Atomic facts about the entity. Each observation should be a single, self-contained statement.",
-						"items": map[string]any{
-							"type": "string",
-						},
-					},
-				},
-				"required": []string{"name", "entityType", "observations"},
-			}),
-		),
-	)
-
-	// Add create_relations tool
-	createRelationsTool := mcp.NewTool("create_relations",
-		mcp.WithDescription(`Create directed relations (edges) between existing entities in the knowledge graph.
+// Closure returns every entity transitively reachable from start, up to maxNodes
+func (m *KnowledgeGraphManager) Closure(start string, relationType string, direction string, maxNodes int) (*storage.ClosureResult, error) {
+	return m.storage.Closure(start, relationType, direction, maxNodes)
+}
 
-Relations express how entities are connected. Use active voice for relation types.
-Both "from" and "to" entities must already exist — create them first if needed.
+// FindAllPaths returns acyclic paths from one entity to another, shortest first
+func (m *KnowledgeGraphManager) FindAllPaths(from, to string, maxDepth int, maxPaths int) ([][]storage.Relation, error) {
+	return m.storage.FindAllPaths(from, to, maxDepth, maxPaths)
+}
 
-RELATION TYPE EXAMPLES:
-  "works_on", "uses", "belongs_to", "created_by", "depends_on", "manages", "likes", "knows"
+// RelationExists reports whether a specific relation exists
+func (m *KnowledgeGraphManager) RelationExists(from, to, relationType string) (bool, error) {
+	return m.storage.RelationExists(from, to, relationType)
+}
 
-EXAMPLE:
-  from: "JohnDoe", to: "ProjectAlpha", relationType: "works_on"
-  from: "ProjectAlpha", to: "TypeScript", relationType: "uses"`),
-		mcp.WithTitleAnnotation("Create Relations"),
-		mcp.WithDestructiveHintAnnotation(true),
-		mcp.WithArray("relations",
-			mcp.Required(),
-			mcp.Description("An array of relations to create"),
-			mcp.Items(map[string]any{
-				"type": "object",
-				"properties": map[string]any{
-					"from": map[string]any{
-						"type":        "string",
-						"description": "Source entity name (must already exist)",
-					},
-					"to": map[string]any{
-						"type":        "string",
-						"description": "Target entity name (must already exist)",
-					},
-					"relationType": map[string]any{
-						"type":        "string",
-						"description": "Relation label in active voice (e.g. works_on, uses, belongs_to)",
-					},
-				},
-				"required": []string{"from", "to", "relationType"},
-			}),
-		),
-	)
+func (m *KnowledgeGraphManager) TopConnectedPairs(limit int) ([]storage.PairCount, error) {
+	return m.storage.TopConnectedPairs(limit)
+}
 
-	// Add add_observations tool
-	addObservationsTool := mcp.NewTool("add_observations",
-		mcp.WithDescription(`Add new observations (facts) to existing entities in the knowledge graph.
+// EntitiesByObservationCount returns entities whose observation count is in range
+func (m *KnowledgeGraphManager) EntitiesByObservationCount(minCount, maxCount, limit int, ascending bool) ([]storage.ObservationCount, error) {
+	return m.storage.EntitiesByObservationCount(minCount, maxCount, limit, ascending)
+}
 
-Use this to append new information to entities that already exist. If the entity doesn't exist yet, use create_entities first.
+// SelfRelations returns the relations from an entity to itself
+func (m *KnowledgeGraphManager) SelfRelations() ([]storage.Relation, error) {
+	return m.storage.SelfRelations()
+}
 
-Each observation should be a single, atomic fact. Duplicate observations are automatically skipped.
+// RelationsAmong returns the relations between the named entities, as of asOf
+// unless it is zero
+func (m *KnowledgeGraphManager) RelationsAmong(names []string, asOf time.Time) ([]storage.Relation, error) {
+	return m.storage.RelationsAmong(names, asOf)
+}
 
-EXAMPLE:
-  entityName: "TypeScript", contents: ["Version 5.0 released in 2023", "Supports decorators natively"]`),
-		mcp.WithTitleAnnotation("Add Observations"),
-		mcp.WithDestructiveHintAnnotation(true),
-		mcp.WithArray("observations",
-			mcp.Required(),
-			mcp.Description("An array of observations to add to entities"),
-			mcp.Items(map[string]any{
-				"type": "object",
-				"properties": map[string]any{
-					"entityName": map[string]any{
-						"type":        "string",
-						"description": "Exact name of the existing entity to add observations to",
-					},
-					"contents": map[string]any{
-						"type":        "array",
-						"description": "New atomic facts to add. Duplicates are automatically skipped.",
-						"items": map[string]any{
-							"type": "string",
-						},
-					},
-				},
-				"required": []string{"entityName", "contents"},
-			}),
-		),
-	)
+// EmptyEntities returns the entities without observations
+func (m *KnowledgeGraphManager) EmptyEntities() ([]storage.EntitySummary, error) {
+	return m.storage.EmptyEntities()
+}
 
-	// Add delete_entities tool
-	deleteEntitiesTool := mcp.NewTool("delete_entities",
-		mcp.WithDescription("Delete entities and all their associated observations and relations from the knowledge graph. This action is irreversible."),
-		mcp.WithTitleAnnotation("Delete Entities"),
-		mcp.WithDestructiveHintAnnotation(true),
-		mcp.WithArray("entityNames",
-			mcp.Required(),
-			mcp.Description("Exact names of entities to delete. All associated observations and relations will also be removed."),
-			mcp.Items(map[string]any{
-				"type": "string",
-			}),
-		),
-	)
+// RelationTypeSamples returns up to limit example relations of each relation type
+func (m *KnowledgeGraphManager) RelationTypeSamples(limit int) (map[string][]storage.Relation, error) {
+	return m.storage.RelationTypeSamples(limit)
+}
 
-	// Add delete_observations tool
-	deleteObservationsTool := mcp.NewTool("delete_observations",
-		mcp.WithDescription("Delete specific observations from entities. Use this to remove outdated or incorrect facts while keeping the entity itself."),
-		mcp.WithTitleAnnotation("Delete Observations"),
-		mcp.WithDestructiveHintAnnotation(true),
-		mcp.WithArray("deletions",
-			mcp.Required(),
-			mcp.Description("An array of observations to delete from entities"),
-			mcp.Items(map[string]any{
-				"type": "object",
-				"properties": map[string]any{
-					"entityName": map[string]any{
-						"type":        "string",
-						"description": "Exact name of the entity containing the observations to delete",
-					},
-					"observations": map[string]any{
-						"type":        "array",
-						"description": "Exact observation text strings to remove. Must match existing observations exactly.",
-						"items": map[string]any{
-							"type": "string",
-						},
-					},
-				},
-				"required": []string{"entityName", "observations"},
-			}),
-		),
-	)
+func (m *KnowledgeGraphManager) MergeEntities(sourceName, targetName string) (*storage.MergeResult, error) {
+	return m.storage.MergeEntities(sourceName, targetName)
+}
 
-	// Add delete_relations tool
-	deleteRelationsTool := mcp.NewTool("delete_relations",
-		mcp.WithDescription("Delete specific relations from the knowledge graph. All three fields (from, to, relationType) must match exactly."),
-		mcp.WithTitleAnnotation("Delete Relations"),
-		mcp.WithDestructiveHintAnnotation(true),
-		mcp.WithArray("relations",
-			mcp.Required(),
-			mcp.Description("An array of relations to delete (exact match required for all fields)"),
-			mcp.Items(map[string]any{
-				"type": "object",
-				"properties": map[string]any{
-					"from": map[string]any{
-						"type":        "string",
-						"description": "Source entity name",
-					},
-					"to": map[string]any{
-						"type":        "string",
-						"description": "Target entity name",
-					},
-					"relationType": map[string]any{
-						"type":        "string",
-						"description": "Relation type to delete",
-					},
-				},
-				"required": []string{"from", "to", "relationType"},
-			}),
-		),
-	)
+func (m *KnowledgeGraphManager) UpdateEntityType(name string, newType string) error {
+	return m.storage.UpdateEntityType(name, newType)
+}
 
-	// Add read_graph tool
-	readGraphTool := mcp.NewTool("read_graph",
-		mcp.WithDescription(`Read the knowledge graph to understand what memories are stored.
+// SetEntityType changes an entity's type and returns the updated entity
+func (m *KnowledgeGraphManager) SetEntityType(name string, entityType string) (*storage.Entity, error) {
+	return m.storage.SetEntityType(name, entityType)
+}
 
-MODES:
-- "summary" (default): Returns statistics (entity/relation counts, type distribution) and a list of entity names. Use this to get an overview of available memories.
-- "full": Returns the complete graph with all entities, observations, and relations. Use for backup or comprehensive analysis. Can be large.
+// SetTypeDescription documents an entity or relation type; an empty description removes it
+func (m *KnowledgeGraphManager) SetTypeDescription(kind, typeName, description string) error {
+	return m.storage.SetTypeDescription(kind, typeName, description)
+}
 
-RECOMMENDED WORKFLOW: Start with summary mode to see what's available, then use search_nodes for specific topics.`),
-		mcp.WithTitleAnnotation("Read Graph"),
-		mcp.WithReadOnlyHintAnnotation(true),
-		mcp.WithString("mode",
-			mcp.Description("'summary' (default): statistics + entity name list; 'full': complete graph export with all data"),
-		),
-		mcp.WithNumber("limit",
-			mcp.Description("Max entity names in summary mode (default: 50, max: 200). Ignored in full mode."),
-		),
-	)
+// TypeDescriptions lists the type descriptions of kind ("" = both)
+func (m *KnowledgeGraphManager) TypeDescriptions(kind string) ([]storage.TypeDescription, error) {
+	return m.storage.TypeDescriptions(kind)
+}
 
-	// Add search_nodes tool
-	searchNodesTool := mcp.NewTool("search_nodes",
-		mcp.WithDescription(`Search the knowledge graph for entities matching your query. This should be your FIRST step when looking for stored information.
+// UpdateEntity renames, retypes, and/or redescribes an entity atomically and returns it
+func (m *KnowledgeGraphManager) UpdateEntity(oldName, newName, newType string, description *string) (*storage.Entity, error) {
+	return m.storage.UpdateEntity(oldName, newName, newType, description)
+}
 
-Returns lightweight results (name, type, matched snippets, counts) — NOT full entity details. Use open_nodes with entity names from results to get complete information.
+func (m *KnowledgeGraphManager) UpdateObservation(entityName string, oldContent string, newContent string) error {
+	return m.storage.UpdateObservation(entityName, oldContent, newContent)
+}
 
-SEARCH BEHAVIOR:
-- Single keyword: "React" matches entities with "React" in name, type, or observations
-- Multiple keywords (space-separated OR): "React Vue" finds entities matching EITHER keyword
-- Results are ranked: name matches first, then type matches, then observation content matches
+// Clear removes all entities, observations, and relations
+func (m *KnowledgeGraphManager) StorageInfo() (*storage.StorageInfo, error) {
+	return m.storage.StorageInfo()
+}
 
-WORKFLOW: search_nodes (find relevant entities) → open_nodes (get full details)`),
-		mcp.WithTitleAnnotation("Search Nodes"),
-		mcp.WithReadOnlyHintAnnotation(true),
-		mcp.WithString("query",
-			mcp.Required(),
-			mcp.Description("Search keywords. Space-separated words are treated as OR search. Matches against entity names, types, and observation content."),
-		),
-		mcp.WithNumber("limit",
-			mcp.Description("Max entities to return. Omit or set to 0 for all matches."),
-		),
-	)
+// Analytics returns graph analytics, from the cache when --analytics-cache is set
+func (m *KnowledgeGraphManager) Analytics() (*storage.GraphAnalytics, error) {
+	return m.storage.Analytics()
+}
 
-	// Add open_nodes tool
-	openNodesTool := mcp.NewTool("open_nodes",
-		mcp.WithDescription(`Get FULL details of specific entities by their exact names.
+// RefreshAnalytics recomputes graph analytics and replaces the cached copy
+func (m *KnowledgeGraphManager) RefreshAnalytics() (*storage.GraphAnalytics, error) {
+	return m.storage.RefreshAnalytics()
+}
 
-Returns complete entity data including ALL observations and ALL relations (both incoming and outgoing). Use search_nodes first to find entity names if you're unsure of the exact name.
+// CheckIntegrity reports dangling relations, orphaned observations, duplicate
+// entities, and FTS index drift across the whole store
+func (m *KnowledgeGraphManager) CheckIntegrity() (*storage.IntegrityReport, error) {
+	return m.storage.CheckIntegrity()
+}
 
-REQUIRES: Exact entity names (case-sensitive). Get these from search_nodes results.
-RETURNS: Complete entities with all observations, plus all relations connected to these entities.`),
-		mcp.WithTitleAnnotation("Open Nodes"),
-		mcp.WithReadOnlyHintAnnotation(true),
-		mcp.WithArray("names",
-			mcp.Required(),
-			mcp.Description("Exact entity names to retrieve (case-sensitive). Use search_nodes first if unsure."),
-			mcp.Items(map[string]any{
-				"type": "string",
-			}),
-		),
-	)
+// Checkpoint flushes the SQLite write-ahead log into the database and truncates it
+func (m *KnowledgeGraphManager) Checkpoint() (*storage.CheckpointResult, error) {
+	return m.storage.Checkpoint()
+}
 
-	// Add merge_entities tool
-	mergeEntitiesTool := mcp.NewTool("merge_entities",
-		mcp.WithDescription(`Merge two entities into one. All observations and relations from the source entity are migrated to the target entity, then the source is deleted.
+func (m *KnowledgeGraphManager) ImportData(graph *storage.KnowledgeGraph, conflictMode string) (*storage.ImportReport, error) {
+	return m.storage.ImportData(graph, conflictMode)
+}
 
-USE WHEN: You discover duplicate entities (e.g. "React.js" and "React" refer to the same thing).
+// DiffGraph compares the stored graph (uncapped) against baseline
+func (m *KnowledgeGraphManager) DiffGraph(baseline *storage.KnowledgeGraph) (*storage.GraphDiff, error) {
+	current, err := m.storage.ExportData()
+	if err != nil {
+		return nil, err
+	}
+	return storage.DiffGraphs(baseline, current), nil
+}
 
-BEHAVIOR:
-- Source observations are added to target (duplicates skipped)
-- Source relations are redirected to target (duplicates skipped)
-- Source entity is deleted after migration
+func (m *KnowledgeGraphManager) Clear() (*storage.ClearResult, error) {
+	return m.storage.Clear()
+}
 
-EXAMPLE: sourceName: "React.js", targetName: "React" → merges React.js into React`),
-		mcp.WithTitleAnnotation("Merge Entities"),
-		mcp.WithDestructiveHintAnnotation(true),
-		mcp.WithString("sourceName",
-			mcp.Required(),
-			mcp.Description("Entity to merge FROM (will be deleted)"),
-		),
-		mcp.WithString("targetName",
-			mcp.Required(),
-			mcp.Description("Entity to merge INTO (will receive observations and relations)"),
-		),
-	)
+// SetObservations replaces an entity's observations with exactly the given list
+func (m *KnowledgeGraphManager) SetObservations(entityName string, observations []string) error {
+	return m.storage.SetObservations(entityName, observations)
+}
 
-	// Add update_entities tool
-	updateEntitiesTool := mcp.NewTool("update_entities",
-		mcp.WithDescription(`Update the type of an existing entity.
+// ReplaceInObservations replaces text in every observation and returns how many changed
+func (m *KnowledgeGraphManager) ReplaceInObservations(old, new string, caseSensitive bool) (int, error) {
+	return m.storage.ReplaceInObservations(old, new, caseSensitive)
+}
 
-USE WHEN: An entity was created with the wrong type and needs correction.
+func (m *KnowledgeGraphManager) DetectConflicts(entityName string) ([]storage.Conflict, error) {
+	return m.storage.DetectConflicts(entityName)
+}
 
-EXAMPLE: name: "React", entityType: "framework" (was previously "library")`),
-		mcp.WithTitleAnnotation("Update Entity Type"),
-		mcp.WithDestructiveHintAnnotation(true),
-		mcp.WithString("name",
-			mcp.Required(),
-			mcp.Description("Exact name of the entity to update"),
-		),
-		mcp.WithString("entityType",
-			mcp.Required(),
-			mcp.Description("New entity type to set"),
-		),
+// Version information
+var (
+	// version can be overridden by -ldflags "-X main.version=..."
+	version = "dev"
+	appName = "Memory MCP Server"
+)
+
+// printVersion prints version information
+func printVersion() {
+	fmt.Printf("%s version %s\n", appName, version)
+}
+
+// printUsage prints a custom usage message
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [options]\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "%s is a Model Context Protocol server that provides knowledge graph management capabilities.\n\n", appName)
+	fmt.Fprintf(os.Stderr, "Options:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	var transport string
+	var memory string
+	var port int = 8080
+	var showVersion bool
+	var showHelp bool
+	var storageType string
+	var autoMigrate bool
+	var noAutoMigrate bool
+	var migrate string
+	var repair string
+	var migrateTo string
+	var dryRun bool
+	var force bool
+	var maxObservations int
+	var maxObservationsInRead int
+	var maxSearchMatches int
+	var emptyQueryListsAll bool
+	var writeRetries int
+	var observationDedup string
+	var allowDestructive bool
+	var observationSoftLimit int
+	var observationHardLimit int
+	var autoBackupDir string
+	var autoBackupKeep int
+	var entityTemplates string
+	var exportDir string
+	var stopwordsFile string
+	var exposeIDs bool
+	var checkpointInterval time.Duration
+	var fileMode string
+	var mirrorJSONL string
+	var noFTS bool
+	var noForeignKeys bool
+	var analyticsCache bool
+	var analyticsRefreshEvery int
+	var readCacheSize int
+	var allowRemoteImport bool
+	var toolsSpec string
+	var jsonlFlushInterval time.Duration
+	var encryptionKeyFile string
+	var newEncryptionKeyFile string
+	var timing bool
+	var idempotencyTTL time.Duration
+	var inverseSpec string
+	var autoInverse bool
+	var placeholderType string
+	var seed string
+	var seedForce bool
+	var namespace string
+	// HTTP transport options
+	var httpEndpoint string
+	var httpHeartbeat string
+	var httpStateless bool
+	// SSE transport options
+	var ssePath string
+	var sseMessagePath string
+	var sseKeepAlive string
+	var maxRequestBytes int64
+	var ndjsonPath string
+	// Auth options
+	var authBearer string
+	var authBearerFile string
+	// OAuth options
+	var oauthUser string
+	var oauthPass string
+	var oauthIssuer string
+	// CORS options
+	var corsOrigin string
+
+	// Override the default usage message
+	flag.Usage = printUsage
+
+	// Define command-line flags
+	flag.StringVar(&transport, "transport", "stdio", "Transport type (stdio, sse, or http)")
+	flag.StringVar(&transport, "t", "stdio", "Transport type (stdio, sse, or http)")
+	flag.StringVar(&memory, "memory", "", "Path to memory file")
+	flag.StringVar(&memory, "m", "", "Path to memory file")
+	flag.IntVar(&port, "port", 8080, "Port for SSE transport")
+	flag.IntVar(&port, "p", 8080, "Port for SSE transport")
+	flag.BoolVar(&showVersion, "version", false, "Show version information and exit")
+	flag.BoolVar(&showVersion, "v", false, "Show version information and exit")
+	flag.BoolVar(&showHelp, "help", false, "Show this help message and exit")
+	flag.BoolVar(&showHelp, "h", false, "Show this help message and exit")
+
+	// New storage-related flags
+	flag.StringVar(&storageType, "storage", "", "Storage type (sqlite or jsonl, auto-detected if not specified)")
+	flag.BoolVar(&autoMigrate, "auto-migrate", true, "Automatically migrate from JSONL to SQLite (env: MCP_AUTO_MIGRATE)")
+	flag.BoolVar(&noAutoMigrate, "no-auto-migrate", false, "Stay on JSONL even if a .db file exists beside it; remembered for later runs")
+	flag.StringVar(&migrate, "migrate", "", "Migrate data from JSONL file to SQLite")
+	flag.StringVar(&repair, "repair", "", "Repair a JSONL file (orphaned relations, duplicates, whitespace), backing up the original, then exit")
+	flag.StringVar(&migrateTo, "migrate-to", "", "Destination SQLite file for migration")
+	flag.BoolVar(&dryRun, "dry-run", false, "Perform a dry run of migration")
+	flag.BoolVar(&force, "force", false, "Force overwrite destination file during migration")
+	flag.StringVar(&seed, "seed", "", "Seed an empty store from this JSON or JSONL graph file at startup")
+	flag.BoolVar(&seedForce, "seed-force", false, "Import the --seed file even if the store already has data (merges)")
+	flag.BoolVar(&allowDestructive, "allow-destructive", false, "Enable admin tools that wipe data (clear_graph)")
+	flag.IntVar(&observationSoftLimit, "observation-soft-limit", defaultObservationSoftLimit, "Observations for one entity in a single create_entities/add_observations call above which a chunking warning is returned (0 disables)")
+	flag.StringVar(&autoBackupDir, "auto-backup-dir", "", "Directory for a timestamped JSONL backup taken before clear_graph and delete_entities (off when empty)")
+	flag.IntVar(&autoBackupKeep, "auto-backup-keep", 10, "Number of automatic backups to keep in --auto-backup-dir, 0 keeps all")
+	flag.StringVar(&inverseSpec, "inverse-relations", "", "Comma-separated inverse relation types, e.g. parent_of=child_of; create_relations rejects relations that contradict them")
+	flag.BoolVar(&autoInverse, "auto-inverse", false, "With --inverse-relations, create_relations also creates the inverse of each relation")
+	flag.StringVar(&placeholderType, "placeholder-type", "unknown", "Entity type create_relations gives the endpoints it creates with autoCreateEntities")
+	flag.DurationVar(&idempotencyTTL, "idempotency-ttl", 24*time.Hour, "How long a mutating tool call's idempotencyKey replays its first result (0 disables)")
+	flag.BoolVar(&timing, "timing", false, "Log how long each tool call takes and add it to the result metadata as durationMs")
+	flag.StringVar(&encryptionKeyFile, "encryption-key-file", "", "Encrypt the JSONL memory file (AES-256-GCM) with the passphrase in this file (env: MEMORY_MCP_ENCRYPTION_KEY); JSONL storage only")
+	flag.StringVar(&newEncryptionKeyFile, "new-encryption-key-file", "", "Re-encrypt the memory file from the current key to the passphrase in this file, then exit")
+	flag.StringVar(&toolsSpec, "tools", "", "Comma-separated tools to register: names or the groups read and write; prefix with - to leave out (e.g. read or -clear_graph,-delete_by_query)")
+	flag.BoolVar(&allowRemoteImport, "allow-remote-import", false, "Enable import_url, which fetches a graph over HTTP(S) and merges it into the store")
+	flag.BoolVar(&noForeignKeys, "no-foreign-keys", false, "With SQLite storage, leave foreign key enforcement off (SQLite's default): deleting an entity row no longer cascades to its observations and relations")
+	flag.BoolVar(&noFTS, "no-fts", false, "With SQLite storage, skip full-text search: search_nodes uses LIKE matching, and new databases get no FTS index to maintain on writes")
+	flag.BoolVar(&analyticsCache, "analytics-cache", false, "With SQLite storage, cache analyze_graph results in the database until refresh_analytics is called; results report whether they are stale")
+	flag.IntVar(&analyticsRefreshEvery, "analytics-refresh-every", 0, "With --analytics-cache, recompute cached analytics on the first analyze_graph call after this many writes (0 = only via refresh_analytics)")
+	flag.IntVar(&readCacheSize, "read-cache-size", 0, "With SQLite storage, cache this many open_nodes and search_nodes results in memory, dropped when a write touches them (0 = off; not safe with other processes writing the database)")
+	flag.StringVar(&mirrorJSONL, "mirror-jsonl", "", "With SQLite storage, keep this JSONL file in sync with the database, rewriting it shortly after each change (one-way)")
+	flag.StringVar(&fileMode, "file-mode", "", "Octal permission, e.g. 0600, for the memory file, the SQLite database, backups, and exports (default: 0644 for new files; existing files keep theirs)")
+	flag.DurationVar(&jsonlFlushInterval, "jsonl-flush-interval", 0, "With JSONL storage, buffer changes in memory and rewrite the file at most this long after the first one, and on shutdown (0 = write every change immediately)")
+	flag.DurationVar(&checkpointInterval, "checkpoint-interval", 5*time.Minute, "How often SQLite checkpoints and truncates its -wal file (0 = only on shutdown)")
+	flag.BoolVar(&exposeIDs, "expose-ids", false, "Include entity and relation IDs in read_graph, search_nodes, and open_nodes results (SQLite row IDs; hashed names for JSONL)")
+	flag.StringVar(&exportDir, "export-dir", "", "Directory export_entity_context writes files to (default: the system temp directory)")
+	flag.StringVar(&stopwordsFile, "stopwords-file", "", "File of words topic_summary leaves out, one per line, replacing the built-in English list")
+	flag.StringVar(&entityTemplates, "entity-templates", "", "JSON file mapping entity types to the observations new entities of that type start with when created without any")
+	flag.IntVar(&observationHardLimit, "observation-hard-limit", defaultObservationHardLimit, "Observations for one entity in a single create_entities/add_observations call above which the call is rejected (0 disables)")
+	flag.IntVar(&writeRetries, "write-retries", storage.DefaultWriteRetries, "Times to retry a SQLite write that fails with \"database is locked\", with exponential backoff (-1 disables)")
+	flag.StringVar(&observationDedup, "observation-dedup", storage.DedupExact, "When observations count as duplicates: exact, or normalized (ignore case and whitespace)")
+	flag.StringVar(&namespace, "namespace", "", "Graph namespace tools use unless a call passes its own (env: MCP_NAMESPACE, default \"default\")")
+	flag.IntVar(&maxObservations, "max-observations-per-entity", storage.DefaultMaxObservationsPerEntity, "Max observations returned per entity by open_nodes/read_graph (-1 for no cap); use get_observations to page through the rest")
+	flag.IntVar(&maxSearchMatches, "max-search-matches", storage.DefaultMaxSearchMatches, "Max entities a search_nodes call returns; broader searches return only their total and ask to refine the query (0 = no limit)")
+	flag.BoolVar(&emptyQueryListsAll, "empty-query-lists-all", false, "Let search_nodes take an empty query, listing entities in name order up to its limit (default: an empty query is rejected)")
+	flag.IntVar(&maxObservationsInRead, "max-observations-in-read", 0, "Max observations per entity in read_graph results, ending cut lists with \"... (N more)\", and max snippets per search_nodes hit (0 = off); open_nodes is unaffected")
+
+	// HTTP transport flags
+	flag.StringVar(&httpEndpoint, "http-endpoint", "/mcp", "Streamable HTTP endpoint path (e.g. /mcp)")
+	flag.StringVar(&httpEndpoint, "http_ep", "/mcp", "Streamable HTTP endpoint path (alias)")
+	flag.StringVar(&httpHeartbeat, "http-heartbeat", "30s", "Streamable HTTP heartbeat interval, e.g. 30s, 1m")
+	flag.BoolVar(&httpStateless, "http-stateless", false, "Run Streamable HTTP in stateless mode (no session tracking)")
+
+	flag.StringVar(&ndjsonPath, "ndjson-path", "", "Serve the graph as streamed NDJSON at this path on SSE/HTTP transports, e.g. /graph.ndjson (off when empty)")
+	flag.Int64Var(&maxRequestBytes, "max-request-bytes", 10<<20, "Max request body size in bytes for SSE/HTTP transports, larger requests get 413 (0 disables)")
+
+	// SSE transport flags
+	flag.StringVar(&ssePath, "sse-path", "/sse", "SSE stream endpoint path (e.g. /sse or /memory/sse)")
+	flag.StringVar(&sseMessagePath, "sse-message-path", "/message", "SSE message endpoint path (e.g. /message or /memory/message)")
+	flag.StringVar(&sseKeepAlive, "sse-keepalive", "30s", "SSE keep-alive interval, e.g. 30s, 1m (0 disables)")
+
+	// Auth flags
+	flag.StringVar(&authBearer, "auth-bearer", "", "Require Authorization: Bearer <token> for SSE/HTTP transports (visible in the process list; prefer --auth-bearer-file or MEMORY_MCP_AUTH_BEARER)")
+	flag.StringVar(&authBearerFile, "auth-bearer-file", "", "Read the Bearer token from this file (env: MEMORY_MCP_AUTH_BEARER)")
+
+	// OAuth flags
+	flag.StringVar(&oauthUser, "oauth-user", "", "OAuth login username (env: OAUTH_USER)")
+	flag.StringVar(&oauthPass, "oauth-pass", "", "OAuth login password (env: OAUTH_PASS)")
+	flag.StringVar(&oauthIssuer, "oauth-issuer", "", "OAuth issuer URL, e.g. https://mcp.example.com (auto-detect if empty)")
+
+	// CORS flags
+	flag.StringVar(&corsOrigin, "cors-origin", "*", "Allowed CORS origins: '*' for all, or comma-separated list")
+
+	flag.Parse()
+
+	// Auto-migrate: environment variable fallback
+	autoMigrateSet := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "auto-migrate" {
+			autoMigrateSet = true
+		}
+	})
+	if noAutoMigrate {
+		if autoMigrateSet && autoMigrate {
+			log.Fatal("--auto-migrate and --no-auto-migrate are mutually exclusive")
+		}
+		autoMigrate, autoMigrateSet = false, true
+	}
+	autoMigrateExplicit := autoMigrateSet || os.Getenv("MCP_AUTO_MIGRATE") != ""
+	autoMigrate, err := resolveAutoMigrate(autoMigrate, autoMigrateSet)
+	if err != nil {
+		log.Fatal(err)
+	}
+	limits := observationLimits{soft: observationSoftLimit, hard: observationHardLimit}
+
+	// Namespace: environment variable fallback
+	if namespace == "" {
+		namespace = os.Getenv("MCP_NAMESPACE")
+	}
+	if namespace != "" {
+		if err := storage.ValidateNamespace(namespace); err != nil {
+			log.Fatalf("Invalid --namespace: %v", err)
+		}
+	}
+
+	authBearer, authWarnings, err := resolveAuthBearer(authBearer, authBearerFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, warning := range authWarnings {
+		log.Printf("WARNING: %s", warning)
+	}
+
+	// OAuth: environment variable fallback
+	if oauthUser == "" {
+		oauthUser = os.Getenv("OAUTH_USER")
+	}
+	if oauthPass == "" {
+		oauthPass = os.Getenv("OAUTH_PASS")
+	}
+	if oauthIssuer == "" {
+		oauthIssuer = os.Getenv("OAUTH_ISSUER")
+	}
+
+	// Determine if OAuth is enabled
+	oauthEnabled := oauthUser != "" && oauthPass != ""
+	if (oauthUser != "") != (oauthPass != "") {
+		log.Fatal("Both --oauth-user and --oauth-pass must be provided together")
+	}
+	if oauthEnabled && authBearer != "" {
+		log.Fatal("--auth-bearer and --oauth-user/--oauth-pass are mutually exclusive")
+	}
+
+	// Parse CORS origins
+	var allowedOrigins []string
+	allowAllOrigins := corsOrigin == "*"
+	if !allowAllOrigins {
+		for _, o := range strings.Split(corsOrigin, ",") {
+			if o = strings.TrimSpace(o); o != "" {
+				allowedOrigins = append(allowedOrigins, o)
+			}
+		}
+	}
+
+	// In stdio mode, ensure logging doesn't interfere with MCP JSON-RPC
+	if transport == "stdio" {
+		// Set environment variable to track stdio mode for suppressing logs
+		os.Setenv("MCP_TRANSPORT", "stdio")
+		// Log output already goes to stderr by default, which is fine
+		// But we should suppress non-critical logging in stdio mode
+		log.SetOutput(os.Stderr)
+	}
+
+	// Handle version flag
+	if showVersion {
+		printVersion()
+		os.Exit(0)
+	}
+
+	// Handle help flag
+	if showHelp {
+		printUsage()
+		os.Exit(0)
+	}
+
+	// Handle repair command
+	if repair != "" {
+		report, err := storage.RepairJSONL(repair, dryRun)
+		if err != nil {
+			log.Fatalf("Repair failed: %v", err)
+		}
+		switch {
+		case !report.Changed():
+			log.Printf("%s needs no repair", repair)
+		case dryRun:
+			log.Printf("DRY RUN: %s would be repaired:", repair)
+		default:
+			log.Printf("Repaired %s (backup saved to %s):", repair, report.BackupPath)
+		}
+		if report.Changed() {
+			log.Printf("  Orphaned relations removed: %d", len(report.OrphanedRelations))
+			log.Printf("  Duplicate relations removed: %d", report.DuplicateRelations)
+			log.Printf("  Duplicate entities merged: %d", len(report.MergedEntities))
+			log.Printf("  Duplicate observations removed: %d", report.DuplicateObservations)
+			log.Printf("  Blank observations removed: %d", report.EmptyObservations)
+			log.Printf("  Values trimmed: %d", report.TrimmedValues)
+		}
+		os.Exit(0)
+	}
+
+	// Handle migration command
+	if migrate != "" {
+		if migrateTo == "" {
+			migrateTo = sqlitePathFor(migrate)
+		}
+
+		cmd := storage.MigrateCommand{
+			Source:      migrate,
+			Destination: migrateTo,
+			DryRun:      dryRun,
+			Force:       force,
+			Verbose:     true,
+		}
+
+		if err := storage.ExecuteMigration(cmd); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+
+		os.Exit(0)
+	}
+
+	if observationDedup != storage.DedupExact && observationDedup != storage.DedupNormalized {
+		log.Fatalf("Invalid --observation-dedup %q: use exact or normalized", observationDedup)
+	}
+	inverses, err := parseInverseRelations(inverseSpec)
+	if err != nil {
+		log.Fatalf("Invalid --inverse-relations: %v", err)
+	}
+	if autoInverse && len(inverses) == 0 {
+		log.Fatalf("--auto-inverse requires --inverse-relations")
+	}
+	mode, err := parseFileMode(fileMode)
+	if err != nil {
+		log.Fatalf("Invalid --file-mode: %v", err)
+	}
+	keepAlive, err := parseKeepAlive(sseKeepAlive)
+	if err != nil {
+		log.Fatalf("Invalid --sse-keepalive: %v", err)
+	}
+	toolsAllowed, err := parseToolSelection(toolsSpec)
+	if err != nil {
+		log.Fatalf("Invalid --tools: %v", err)
+	}
+	var templates map[string][]string
+	if entityTemplates != "" {
+		if templates, err = loadEntityTemplates(entityTemplates); err != nil {
+			log.Fatalf("Invalid --entity-templates: %v", err)
+		}
+	}
+	stopwords := storage.DefaultStopwords
+	if stopwordsFile != "" {
+		if stopwords, err = loadStopwords(stopwordsFile); err != nil {
+			log.Fatalf("Invalid --stopwords-file: %v", err)
+		}
+	}
+
+	encryptionKey, keyWarnings, err := resolveEncryptionKey("--encryption-key-file", encryptionKeyFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, warning := range keyWarnings {
+		log.Printf("WARNING: %s", warning)
+	}
+	if newEncryptionKeyFile != "" {
+		newKey, keyWarnings, err := resolveEncryptionKey("--new-encryption-key-file", newEncryptionKeyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, warning := range keyWarnings {
+			log.Printf("WARNING: %s", warning)
+		}
+		path := resolveMemoryPath(memory)
+		if err := storage.RotateEncryptionKey(path, encryptionKey, newKey); err != nil {
+			log.Fatalf("Key rotation failed: %v", err)
+		}
+		log.Printf("Re-encrypted %s with the new key", path)
+		os.Exit(0)
+	}
+	if encryptionKey != "" {
+		if storageType, err = encryptedStorageType(resolveMemoryPath(memory), storageType); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// A backend chosen on an earlier run sticks unless --storage or an explicit
+	// auto-migrate setting says otherwise
+	if storageType == "" {
+		if autoMigrate, err = resolveBackendChoice(resolveMemoryPath(memory), autoMigrate, autoMigrateExplicit, mode); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Create knowledge graph manager
+	manager, err := NewKnowledgeGraphManager(memory, storageType, autoMigrate, func(c *storage.Config) {
+		c.MaxObservationsPerEntity = maxObservations
+		c.MaxObservationsInRead = maxObservationsInRead
+		c.MaxSearchMatches = maxSearchMatches
+		c.EmptyQueryListsAll = emptyQueryListsAll
+		c.WriteRetries = writeRetries
+		c.ObservationDedup = observationDedup
+		c.Namespace = namespace
+		c.EntityTemplates = templates
+		c.ExposeIDs = exposeIDs
+		c.CheckpointInterval = checkpointInterval
+		c.FileMode = mode
+		c.MirrorJSONL = mirrorJSONL
+		c.EncryptionKey = encryptionKey
+		c.FlushInterval = jsonlFlushInterval
+		c.AnalyticsCache = analyticsCache
+		c.AnalyticsRefreshEvery = analyticsRefreshEvery
+		c.ReadCacheSize = readCacheSize
+		c.DisableForeignKeys = noForeignKeys
+		if noFTS {
+			c.FTS = &storage.FTSConfig{Enabled: false}
+		}
+	})
+	if err != nil {
+		log.Fatalf("Failed to create knowledge graph manager: %v", err)
+	}
+	// Close flushes buffered JSONL changes, including on SIGINT/SIGTERM, which
+	// the transports turn into a normal return from main
+	defer func() {
+		if err := manager.Close(); err != nil {
+			log.Printf("Failed to close storage: %v", err)
+		}
+	}()
+	if _, ok := manager.storage.(*storage.SQLiteStorage); mirrorJSONL != "" && !ok {
+		log.Printf("WARNING: --mirror-jsonl only applies to SQLite storage; the JSONL memory file is already diffable")
+	}
+	if _, ok := manager.storage.(*storage.SQLiteStorage); noForeignKeys && !ok {
+		log.Printf("WARNING: --no-foreign-keys only applies to SQLite storage")
+	}
+	if _, ok := manager.storage.(*storage.SQLiteStorage); noFTS && !ok {
+		log.Printf("WARNING: --no-fts only applies to SQLite storage; JSONL search never uses FTS")
+	}
+	if _, ok := manager.storage.(*storage.SQLiteStorage); readCacheSize > 0 && !ok {
+		log.Printf("WARNING: --read-cache-size only applies to SQLite storage")
+	}
+	if _, ok := manager.storage.(*storage.SQLiteStorage); analyticsCache && !ok {
+		log.Printf("WARNING: --analytics-cache only applies to SQLite storage; JSONL computes analyze_graph from the loaded graph on every call")
+	}
+	// Name the store and what it holds, so an unexpected path or an empty store
+	// shows up before any tool call
+	if info, err := manager.StorageInfo(); err != nil {
+		log.Printf("WARNING: failed to read storage info: %v", err)
+	} else {
+		log.Printf("Using %s storage at %s: %d entities, %d relations in namespace %q",
+			info.Backend, info.FilePath, info.Entities, info.Relations, info.Namespace)
+	}
+
+	// autoBackup snapshots the store before a destructive tool runs and returns
+	// the backup path, or "" when --auto-backup-dir is not set. A failed backup
+	// fails the tool call so nothing is deleted without one.
+	autoBackup := func() (string, error) {
+		if autoBackupDir == "" {
+			return "", nil
+		}
+		path, err := manager.Backup(autoBackupDir, autoBackupKeep)
+		if err != nil {
+			return "", fmt.Errorf("automatic backup failed, operation not performed: %w", err)
+		}
+		log.Printf("Automatic backup written to %s", path)
+		return path, nil
+	}
+
+	// Seed initial graph if requested
+	if seed != "" {
+		if err := manager.Seed(seed, seedForce); err != nil {
+			log.Fatalf("Failed to seed graph from %s: %v", seed, err)
+		}
+	}
+
+	// Create a new MCP server
+	serverOptions := []server.ServerOption{
+		server.WithResourceCapabilities(true, true),
+		server.WithPromptCapabilities(true),
+		server.WithLogging(),
+	}
+	if timing {
+		// Outermost, so error results are timed too
+		serverOptions = append(serverOptions, server.WithToolHandlerMiddleware(timingMiddleware))
+	}
+	idempotent := &idempotency{manager: manager, ttl: idempotencyTTL, tools: map[string]bool{}}
+	serverOptions = append(serverOptions,
+		// Registered before recovery so recovered panics also become coded tool errors
+		server.WithToolHandlerMiddleware(toolErrorMiddleware),
+		server.WithRecovery(),
+		server.WithToolHandlerMiddleware(namespaceMiddleware),
+		server.WithToolHandlerMiddleware(idempotent.middleware),
 	)
+	s := server.NewMCPServer(appName, version, serverOptions...)
+
+	// ─── MCP Resources ─────────────────────────────────────────────────
+	// Resources allow AI clients to passively load memory context without
+	// explicitly calling tools, improving memory awareness and utilization.
+
+	// Resource: Knowledge Graph Summary
+	s.AddResource(mcp.NewResource(
+		"memory://graph/summary",
+		"Knowledge Graph Summary",
+		mcp.WithResourceDescription("Overview of the knowledge graph including entity/relation counts, type distribution, and entity name list. Load this at the start of a conversation to understand what memories are available."),
+		mcp.WithMIMEType("application/json"),
+	), func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		result, err := manager.ReadGraph("summary", 50, storage.TypeFilter{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read graph summary: %w", err)
+		}
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal graph summary: %w", err)
+		}
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{
+				URI:      "memory://graph/summary",
+				MIMEType: "application/json",
+				Text:     string(data),
+			},
+		}, nil
+	})
+
+	// Resource: Entity type and relation type distribution
+	s.AddResource(mcp.NewResource(
+		"memory://graph/types",
+		"Entity & Relation Types",
+		mcp.WithResourceDescription("Lists all entity types and relation types currently in the knowledge graph with their counts. Useful for understanding the schema and maintaining consistent naming when creating new entities."),
+		mcp.WithMIMEType("application/json"),
+	), func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		result, err := manager.ReadGraph("summary", 1, storage.TypeFilter{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read graph types: %w", err)
+		}
+		// Extract just the type information from the summary
+		summary, ok := result.(*storage.GraphSummary)
+		if !ok {
+			return nil, fmt.Errorf("unexpected result type from ReadGraph")
+		}
+		typeInfo := map[string]interface{}{
+			"entityTypes":   summary.EntityTypes,
+			"relationTypes": summary.RelationTypes,
+		}
+		data, err := json.MarshalIndent(typeInfo, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal type info: %w", err)
+		}
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{
+				URI:      "memory://graph/types",
+				MIMEType: "application/json",
+				Text:     string(data),
+			},
+		}, nil
+	})
+
+	// Resource Template: Individual entity details
+	s.AddResourceTemplate(mcp.NewResourceTemplate(
+		"memory://entities/{name}",
+		"Entity Details",
+		mcp.WithTemplateDescription("Get full details of a specific entity by name, including all observations and relations. Use the entity name from graph summary or search results."),
+		mcp.WithTemplateMIMEType("application/json"),
+	), func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		// Extract entity name from URI
+		uri := request.Params.URI
+		prefix := "memory://entities/"
+		if !strings.HasPrefix(uri, prefix) {
+			return nil, fmt.Errorf("invalid resource URI: %s", uri)
+		}
+		name := strings.TrimPrefix(uri, prefix)
+		if name == "" {
+			return nil, fmt.Errorf("entity name is required")
+		}
+
+		// Open the entity
+		graph, err := manager.OpenNodes([]string{name})
+		if err != nil {
+			return nil, fmt.Errorf("failed to open entity %q: %w", name, err)
+		}
+		data, err := json.MarshalIndent(graph, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal entity data: %w", err)
+		}
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{
+				URI:      uri,
+				MIMEType: "application/json",
+				Text:     string(data),
+			},
+		}, nil
+	})
+
+	// ─── MCP Prompts ────────────────────────────────────────────────────
+	// Prompts provide standardized memory operation templates that appear
+	// as clickable actions in clients like Claude Desktop and VS Code.
+
+	// Prompt: Recall memories about a topic
+	s.AddPrompt(mcp.NewPrompt("memory-recall",
+		mcp.WithPromptDescription("Search and recall relevant memories from the knowledge graph based on a topic or question"),
+		mcp.WithArgument("topic",
+			mcp.ArgumentDescription("The topic, question, or keywords to search memories for"),
+			mcp.RequiredArgument(),
+		),
+	), func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		topic := request.Params.Arguments["topic"]
+		results, err := manager.SearchNodes(topic, 10)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search for topic %q: %w", topic, err)
+		}
+
+		var promptText string
+		if results.Total == 0 {
+			promptText = fmt.Sprintf("I searched the knowledge graph for '%s' but found no matching memories. You may want to create new entities to store information about this topic.", topic)
+		} else {
+			var entityList strings.Builder
+			for i, entity := range results.Entities {
+				entityList.WriteString(fmt.Sprintf("\n%d. **%s** (%s) - %d observations, %d relations",
+					i+1, entity.Name, entity.EntityType, entity.ObservationsCount, entity.RelationsCount))
+				for _, snippet := range entity.Snippets {
+					entityList.WriteString(fmt.Sprintf("\n   > %s", snippet))
+				}
+			}
+
+			// Collect entity names for the open_nodes suggestion
+			names := make([]string, len(results.Entities))
+			for i, e := range results.Entities {
+				names[i] = e.Name
+			}
+
+			promptText = fmt.Sprintf(`I searched the knowledge graph for '%s' and found %d matching entities:
+%s
+
+Please use the open_nodes tool to retrieve full details for the most relevant entities (suggested names: %s), then summarize the relevant memories for the user.`,
+				topic, results.Total, entityList.String(), strings.Join(names, ", "))
+		}
+
+		return &mcp.GetPromptResult{
+			Description: fmt.Sprintf("Recall memories about: %s", topic),
+			Messages: []mcp.PromptMessage{
+				{
+					Role: mcp.RoleUser,
+					Content: mcp.TextContent{
+						Type: "text",
+						Text: promptText,
+					},
+				},
+			},
+		}, nil
+	})
+
+	// Prompt: Save conversation information as memories
+	s.AddPrompt(mcp.NewPrompt("memory-save",
+		mcp.WithPromptDescription("Analyze text and extract entities, relations, and observations to save as memories in the knowledge graph"),
+		mcp.WithArgument("content",
+			mcp.ArgumentDescription("The text or conversation content to extract memories from"),
+			mcp.RequiredArgument(),
+		),
+	), func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		content := request.Params.Arguments["content"]
+
+		promptText := fmt.Sprintf(`Analyze the following content and extract structured knowledge to save in the memory graph.
+
+CONTENT TO ANALYZE:
+---
+%s
+---
+
+INSTRUCTIONS:
+1. Identify key entities (people, technologies, projects, concepts, preferences, organizations)
+2. For each entity, determine:
+   - name: Clear, descriptive name
+   - entityType: Category (person, technology, project, concept, preference, organization)
+   - observations: Atomic facts about this entity from the content
+3. Identify relations between entities (use active voice: "works_on", "uses", "belongs_to", etc.)
+4. IMPORTANT: First use search_nodes to check if any of these entities already exist
+   - If an entity exists, use add_observations to add new facts
+   - If an entity is new, use create_entities to create it
+5. Use create_relations to connect related entities
+
+Please proceed to extract and save the memories.`, content)
+
+		return &mcp.GetPromptResult{
+			Description: "Extract and save memories from content",
+			Messages: []mcp.PromptMessage{
+				{
+					Role: mcp.RoleUser,
+					Content: mcp.TextContent{
+						Type: "text",
+						Text: promptText,
+					},
+				},
+			},
+		}, nil
+	})
+
+	// Prompt: Review memories about an entity
+	s.AddPrompt(mcp.NewPrompt("memory-review",
+		mcp.WithPromptDescription("Review and summarize all stored memories about a specific entity, including its observations and connections"),
+		mcp.WithArgument("entity_name",
+			mcp.ArgumentDescription("The exact name of the entity to review"),
+			mcp.RequiredArgument(),
+		),
+	), func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		entityName := request.Params.Arguments["entity_name"]
+
+		graph, err := manager.OpenNodes([]string{entityName})
+		if err != nil {
+			return nil, fmt.Errorf("failed to open entity %q: %w", entityName, err)
+		}
+
+		if len(graph.Entities) == 0 {
+			return &mcp.GetPromptResult{
+				Description: fmt.Sprintf("No entity found: %s", entityName),
+				Messages: []mcp.PromptMessage{
+					{
+						Role: mcp.RoleUser,
+						Content: mcp.TextContent{
+							Type: "text",
+							Text: fmt.Sprintf("Entity '%s' was not found in the knowledge graph. Use search_nodes to find similar entity names.", entityName),
+						},
+					},
+				},
+			}, nil
+		}
+
+		data, err := json.MarshalIndent(graph, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal entity data: %w", err)
+		}
+
+		promptText := fmt.Sprintf(`Review the following entity data from the knowledge graph and provide a clear summary:
+
+ENTITY DATA:
+%s
+
+Please summarize:
+1. What is this entity and what type is it?
+2. Key facts (observations) — highlight the most important ones
+3. How it connects to other entities (relations)
+4. Are there any observations that seem outdated or contradictory?
+5. Suggest any missing information that might be worth adding`, string(data))
+
+		return &mcp.GetPromptResult{
+			Description: fmt.Sprintf("Review memories about: %s", entityName),
+			Messages: []mcp.PromptMessage{
+				{
+					Role: mcp.RoleUser,
+					Content: mcp.TextContent{
+						Type: "text",
+						Text: promptText,
+					},
+				},
+			},
+		}, nil
+	})
+
+	// ─── MCP Tools ──────────────────────────────────────────────────────
+
+	// Every tool accepts a namespace; namespaceMiddleware applies it
+	namespaceParam := mcp.WithString("namespace",
+		mcp.Description("Optional: graph namespace to operate on (letters, digits, '.', '_', '-'). Defaults to the server's --namespace, or \"default\""),
+	)
+
+	// Add create_entities tool
+	createEntitiesTool := mcp.NewTool("create_entities",
+		mcp.WithDescription(`Create new entities in the knowledge graph. Each entity has a unique name, a type, and observations (atomic facts).
+
+BEFORE CREATING: Use search_nodes to check if the entity already exists. If it does, use add_observations to add new facts instead of creating a duplicate.
+
+NAMING CONVENTIONS:
+- Use clear, descriptive names (e.g. "TypeScript", "ProjectAlpha", "JohnDoe")
+- entityType should be a lowercase category: "person", "technology", "project", "concept", "preference", "organization", "event", "location"
+- Each observation should be a single, atomic fact — not a paragraph
+- For an entity with many observations (hundreds or more), create it with a few and send the rest with add_observations in chunks
+
+EXAMPLE:
+  name: "TypeScript", entityType: "technology"
+  observations: ["Preferred language for frontend development", "Used with React in current project"]`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Create Entities"),
+		mcp.WithDestructiveHintAnnotation(true),
+		mcp.WithArray("entities",
+			mcp.Required(),
+			mcp.Description("An array of entities to create"),
+			mcp.Items(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"name": map[string]any{
+						"type":        "string",
+						"description": "Unique name for the entity. Check with search_nodes first to avoid duplicates.",
+					},
+					"entityType": map[string]any{
+						"type":        "string",
+						"description": "Category of the entity (e.g. person, technology, project, concept, preference, organization)",
+					},
+					"description": map[string]any{
+						"type":        "string",
+						"description": "Optional one-line summary of what the entity is, kept apart from its observations and matched by search_nodes",
+					},
+					"observations": map[string]any{
+						"type":        "array",
+						"description": "Atomic facts about the entity. Each observation should be a single, self-contained statement.",
+						"items": map[string]any{
+							"type": "string",
+						},
+					},
+				},
+				"required": []string{"name", "entityType", "observations"},
+			}),
+		),
+		mcp.WithString("source",
+			mcp.Description("Optional: who or what is recording these observations (e.g. an agent name). Defaults to the client name."),
+		),
+		mcp.WithString("mergeStrategy",
+			mcp.Description("What to do when an entity already exists: 'append' (default) sets the new type and adds observations not yet present, 'replace' sets the new type and replaces all observations, 'keep' leaves it untouched except to fill in a missing type or observations"),
+			mcp.Enum(storage.MergeAppend, storage.MergeReplace, storage.MergeKeep),
+		),
+	)
+
+	// Add create_relations tool
+	createRelationsTool := mcp.NewTool("create_relations",
+		mcp.WithDescription(`Create directed relations (edges) between existing entities in the knowledge graph.
+
+Relations express how entities are connected. Use active voice for relation types.
+Both "from" and "to" entities must already exist — create them first, or pass autoCreateEntities to create bare placeholders for missing ones.
+
+RELATION TYPE EXAMPLES:
+  "works_on", "uses", "belongs_to", "created_by", "depends_on", "manages", "likes", "knows"
+
+EXAMPLE:
+  from: "JohnDoe", to: "ProjectAlpha", relationType: "works_on"
+  from: "ProjectAlpha", to: "TypeScript", relationType: "uses"`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Create Relations"),
+		mcp.WithDestructiveHintAnnotation(true),
+		mcp.WithBoolean("autoCreateEntities",
+			mcp.Description("Create a placeholder entity, without observations, for any endpoint that does not exist yet instead of dropping the relation (default: false)"),
+		),
+		mcp.WithString("placeholderType",
+			mcp.Description("Entity type for placeholders created by autoCreateEntities (default: the server's --placeholder-type, \"unknown\")"),
+		),
+		mcp.WithArray("relations",
+			mcp.Required(),
+			mcp.Description("An array of relations to create"),
+			mcp.Items(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"from": map[string]any{
+						"type":        "string",
+						"description": "Source entity name (must already exist)",
+					},
+					"to": map[string]any{
+						"type":        "string",
+						"description": "Target entity name (must already exist)",
+					},
+					"relationType": map[string]any{
+						"type":        "string",
+						"description": "Relation label in active voice (e.g. works_on, uses, belongs_to)",
+					},
+				},
+				"required": []string{"from", "to", "relationType"},
+			}),
+		),
+	)
+
+	// Add add_observations tool
+	addObservationsTool := mcp.NewTool("add_observations",
+		mcp.WithDescription(`Add new observations (facts) to existing entities in the knowledge graph.
+
+Use this to append new information to entities that already exist. If the entity doesn't exist yet, use create_entities first.
+
+Each observation should be a single, atomic fact. Duplicate observations are automatically skipped.
+
+EXAMPLE:
+  entityName: "TypeScript", contents: ["Version 5.0 released in 2023", "Supports decorators natively"]`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Add Observations"),
+		mcp.WithDestructiveHintAnnotation(true),
+		mcp.WithArray("observations",
+			mcp.Required(),
+			mcp.Description("An array of observations to add to entities"),
+			mcp.Items(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"entityName": map[string]any{
+						"type":        "string",
+						"description": "Exact name of the existing entity to add observations to",
+					},
+					"contents": map[string]any{
+						"type":        "array",
+						"description": "New atomic facts to add. Duplicates are automatically skipped.",
+						"items": map[string]any{
+							"type": "string",
+						},
+					},
+				},
+				"required": []string{"entityName", "contents"},
+			}),
+		),
+		mcp.WithString("source",
+			mcp.Description("Optional: who or what is adding these observations (e.g. an agent name). Defaults to the client name."),
+		),
+	)
+
+	// Add delete_entities tool
+	// Shared by delete_entities and delete_relations
+	dryRunParam := mcp.WithBoolean("dryRun",
+		mcp.Description("Report what would be deleted without deleting anything (default: false)"),
+	)
+
+	deleteEntitiesTool := mcp.NewTool("delete_entities",
+		mcp.WithDescription("Delete entities and all their associated observations and relations from the knowledge graph. This action is irreversible. Returns {requested, deleted, notFound, message}: how many of the named entities existed and were deleted. With dryRun, nothing is deleted and the result is {dryRun, entities, observations, relations, notFound}: the entities that exist, how many observations they have, and every relation to or from them."),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Delete Entities"),
+		mcp.WithDestructiveHintAnnotation(true),
+		mcp.WithArray("entityNames",
+			mcp.Required(),
+			mcp.Description("Exact names of entities to delete. All associated observations and relations will also be removed."),
+			mcp.Items(map[string]any{
+				"type": "string",
+			}),
+		),
+		dryRunParam,
+	)
+
+	// Add delete_by_query tool
+	deleteByQueryTool := mcp.NewTool("delete_by_query",
+		mcp.WithDescription(`Delete every entity matching a search query and/or an entity type, with all their observations and relations.
+
+USE WHEN: Cleaning up many entities at once (e.g. all entities of an obsolete type). For a few known names, use delete_entities.
+
+BEHAVIOR: query matches like search_nodes (any word in the name, type, or observations); entityType must match exactly. With both, an entity must match both. Run with dryRun first to see what would be deleted; a real run requires confirm: true. This action is irreversible.
+
+RETURNS: {"deleted", "names", "dryRun"}, plus backupPath when the server takes automatic backups. A dry run also lists under relations every relation that would go with the entities.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Delete By Query"),
+		mcp.WithDestructiveHintAnnotation(true),
+		mcp.WithString("query",
+			mcp.Description("Search query selecting the entities to delete"),
+		),
+		mcp.WithString("entityType",
+			mcp.Description("Delete only entities of this exact type (alone: delete all entities of the type)"),
+		),
+		mcp.WithBoolean("dryRun",
+			mcp.Description("List the entities that would be deleted without deleting them (default: false)"),
+		),
+		mcp.WithBoolean("confirm",
+			mcp.Description("Must be true to delete; not needed with dryRun"),
+		),
+	)
+
+	// Add delete_observations tool
+	deleteObservationsTool := mcp.NewTool("delete_observations",
+		mcp.WithDescription(`Delete specific observations from entities. Use this to remove outdated or incorrect facts while keeping the entity itself.
+
+BEHAVIOR: Per entity, an observation is deleted when it exactly equals one of observations, contains one of the contains substrings (case-sensitive), or sits at one of indexes (0-based, oldest first, as open_nodes lists them by default). Give at least one of the three.
+
+RETURNS: {"deleted": {entityName: n, ...}, "total": n}: how many observations were actually deleted. 0 means nothing matched (or the entity does not exist).`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Delete Observations"),
+		mcp.WithDestructiveHintAnnotation(true),
+		mcp.WithArray("deletions",
+			mcp.Required(),
+			mcp.Description("An array of observations to delete from entities"),
+			mcp.Items(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"entityName": map[string]any{
+						"type":        "string",
+						"description": "Exact name of the entity containing the observations to delete",
+					},
+					"observations": map[string]any{
+						"type":        "array",
+						"description": "Exact observation text strings to remove. Must match existing observations exactly.",
+						"items": map[string]any{
+							"type": "string",
+						},
+					},
+					"contains": map[string]any{
+						"type":        "array",
+						"description": "Remove every observation containing one of these substrings (case-sensitive, non-empty)",
+						"items": map[string]any{
+							"type": "string",
+						},
+					},
+					"indexes": map[string]any{
+						"type":        "array",
+						"description": "Remove the observations at these 0-based positions, oldest first",
+						"items": map[string]any{
+							"type": "integer",
+						},
+					},
+				},
+				"required": []string{"entityName"},
+			}),
+		),
+	)
+
+	// Add delete_relations tool
+	deleteRelationsTool := mcp.NewTool("delete_relations",
+		mcp.WithDescription("Delete specific relations from the knowledge graph. All three fields (from, to, relationType) must match exactly. Returns {requested, deleted, notFound, message}: how many of the relations existed and were deleted. With dryRun, nothing is deleted and the result lists under relations the ones that exist."),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Delete Relations"),
+		mcp.WithDestructiveHintAnnotation(true),
+		mcp.WithArray("relations",
+			mcp.Required(),
+			mcp.Description("An array of relations to delete (exact match required for all fields)"),
+			mcp.Items(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"from": map[string]any{
+						"type":        "string",
+						"description": "Source entity name",
+					},
+					"to": map[string]any{
+						"type":        "string",
+						"description": "Target entity name",
+					},
+					"relationType": map[string]any{
+						"type":        "string",
+						"description": "Relation type to delete",
+					},
+				},
+				"required": []string{"from", "to", "relationType"},
+			}),
+		),
+		dryRunParam,
+	)
+
+	// Add delete_relations_by_type tool
+	deleteRelationsByTypeTool := mcp.NewTool("delete_relations_by_type",
+		mcp.WithDescription("Delete every relation of one type, e.g. after deprecating it. Returns how many relations were deleted. Entities are not affected."),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Delete Relations By Type"),
+		mcp.WithDestructiveHintAnnotation(true),
+		mcp.WithString("relationType",
+			mcp.Required(),
+			mcp.Description("Relation type to delete (exact match)"),
+		),
+	)
+
+	mergeRelationTypesTool := mcp.NewTool("merge_relation_types",
+		mcp.WithDescription(`Rewrite every relation of the alias types to one canonical type, e.g. "works at", "employed_by_company", and "worksAt" to "works_at".
+
+USE WHEN: The same relationship has been recorded under several spellings and queries by type miss some of them.
+
+BEHAVIOR: A rewritten relation that duplicates one already of the canonical type between the same entities is dropped.
+
+RETURNS: {canonical, aliases, changed}, where changed counts the relations rewritten or dropped.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Merge Relation Types"),
+		mcp.WithDestructiveHintAnnotation(true),
+		mcp.WithString("canonical",
+			mcp.Required(),
+			mcp.Description("Relation type to keep"),
+		),
+		mcp.WithArray("aliases",
+			mcp.Required(),
+			mcp.Description("Relation types to rewrite to canonical (exact match)"),
+			mcp.Items(map[string]any{
+				"type": "string",
+			}),
+		),
+	)
+
+	// Shared by read_graph and search_nodes
+	// Shared by tools that return relations
+	relationOrderParam := mcp.WithString("relationOrder",
+		mcp.Description("Order of returned relations: 'stored' (default) or 'recent' (newest first by createdAt)"),
+		mcp.Enum("stored", "recent"),
+	)
+	// Shared by read_graph and open_nodes
+	observationOrderParam := mcp.WithString("observationOrder",
+		mcp.Description("Order of each entity's observations: 'oldest' (default, insertion order) or 'newest' (most recent first). When observations are capped, newest keeps the most recent ones."),
+		mcp.Enum(storage.ObservationOrderOldest, storage.ObservationOrderNewest),
+	)
+	const asOfDescription = "Optional (SQLite only): RFC 3339 timestamp or YYYY-MM-DD date; only show entities, observations, and relations created at or before it. " +
+		"Only creations are filtered: deleted data stays gone and edited data shows its current content."
+
+	// Add read_graph tool
+	readGraphTool := mcp.NewTool("read_graph",
+		mcp.WithDescription(`Read the knowledge graph to understand what memories are stored.
+
+MODES:
+- "summary" (default): Returns statistics (entity/relation counts, type distribution) and a list of entity names. Use this to get an overview of available memories.
+- "full": Returns the complete graph with all entities, observations, and relations. Use for backup or comprehensive analysis. Can be large.
+
+RECOMMENDED WORKFLOW: Start with summary mode to see what's available, then use search_nodes for specific topics.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Read Graph"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("mode",
+			mcp.Description("'summary' (default): statistics + entity name list; 'full': complete graph export with all data"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Max entity names in summary mode (default: 50, max: 200). Ignored in full mode."),
+		),
+		mcp.WithArray("includeTypes",
+			mcp.Description("Optional: only include entities of these types (relations are kept only between included entities)"),
+			mcp.Items(map[string]any{"type": "string"}),
+		),
+		mcp.WithArray("excludeTypes",
+			mcp.Description("Optional: leave out entities of these types, e.g. [\"log\"] (relations touching them are dropped)"),
+			mcp.Items(map[string]any{"type": "string"}),
+		),
+		mcp.WithString("asOf",
+			mcp.Description(asOfDescription),
+		),
+		relationOrderParam,
+		observationOrderParam,
+	)
+
+	// Add search_nodes tool
+	searchNodesTool := mcp.NewTool("search_nodes",
+		mcp.WithDescription(`Search the knowledge graph for entities matching your query. This should be your FIRST step when looking for stored information.
+
+Returns lightweight results (name, type, matched snippets, counts) — NOT full entity details. Use open_nodes with entity names from results to get complete information.
+
+SEARCH BEHAVIOR:
+- Single keyword: "React" matches entities with "React" in name, type, or observations
+- Multiple keywords (space-separated OR): "React Vue" finds entities matching EITHER keyword
+- Quoted phrases: the query 'notes "user feedback"' matches "notes", or "user feedback" as a phrase (those words together, in that order)
+- Results are ranked: entities matching more keywords first, then name matches, then type matches, then observation content matches
+
+TOO MANY RESULTS: when a query matches more entities than the server returns at once, the result has "tooManyResults": true, the "total", and no entities. Refine the query or pass a smaller limit.
+
+WORKFLOW: search_nodes (find relevant entities) → open_nodes (get full details)
+For entities with a large observationsCount, use get_observations to page through them instead.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Search Nodes"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("Search keywords. Space-separated words are treated as OR search; wrap words in double quotes to match them as a phrase. Matches against entity names, types, and observation content. An empty query is rejected unless the server runs with --empty-query-lists-all, which lists all entities in name order up to limit."),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Max entities to return. Omit or set to 0 for all matches."),
+		),
+		mcp.WithNumber("maxSnippets",
+			mcp.Description("Max matched observations per entity, best matches first. Default: 2 when limit is set, all otherwise. Not applied with asOf."),
+		),
+		mcp.WithString("asOf",
+			mcp.Description(asOfDescription),
+		),
+		mcp.WithBoolean("onlyInternalRelations",
+			mcp.Description("Instead of related entities outside the matched set, list the relations among the matched entities themselves (default: false)"),
+		),
+	)
+
+	// Add open_nodes tool
+	openNodesTool := mcp.NewTool("open_nodes",
+		mcp.WithDescription(`Get FULL details of specific entities by their exact names.
+
+Returns complete entity data including observations and ALL relations (both incoming and outgoing). Use search_nodes first to find entity names if you're unsure of the exact name.
+
+REQUIRES: Exact entity names (case-sensitive). Get these from search_nodes results.
+RETURNS: Complete entities with observations, plus all relations connected to these entities.
+LARGE ENTITIES: Observations are capped per entity; capped entities carry "observationsTotal" — use get_observations to page through the rest.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Open Nodes"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithArray("names",
+			mcp.Required(),
+			mcp.Description("Exact entity names to retrieve (case-sensitive). Use search_nodes first if unsure."),
+			mcp.Items(map[string]any{
+				"type": "string",
+			}),
+		),
+		mcp.WithBoolean("onlyInternalRelations",
+			mcp.Description("Only return relations where both endpoints are among the returned entities, producing a self-contained subgraph (default: false)"),
+		),
+		mcp.WithBoolean("includeNeighborTypes",
+			mcp.Description("Add fromType and toType to every relation, so the type of each neighbor is known without another call (default: false)"),
+		),
+		relationOrderParam,
+		observationOrderParam,
+	)
+
+	// Add list_entities tool
+	listEntitiesTool := mcp.NewTool("list_entities",
+		mcp.WithDescription(`List entities page by page, optionally filtered by type and sorted.
+
+USE WHEN: You want to browse memories systematically (e.g. "all person entities, newest first") rather than search by keyword.
+
+RETURNS: A page of entities plus total count and hasMore flag. Observations are omitted unless includeObservations is true.
+With namesOnly, returns just a page of names sorted by name — the cheapest way to enumerate or cache exact entity names.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("List Entities"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("entityType",
+			mcp.Description("Only list entities of this type. Omit for all types."),
+		),
+		mcp.WithString("sort",
+			mcp.Description("Sort field: 'name' (default), 'created', or 'updated'"),
+			mcp.Enum("name", "created", "updated"),
+		),
+		mcp.WithString("order",
+			mcp.Description("Sort order: 'asc' (default) or 'desc'"),
+			mcp.Enum("asc", "desc"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Max entities to return (default: 50, max: 200, or 1000 with namesOnly)"),
+		),
+		mcp.WithNumber("offset",
+			mcp.Description("Number of entities to skip (default: 0)"),
+		),
+		mcp.WithBoolean("includeObservations",
+			mcp.Description("Include observations for each entity (default: false)"),
+		),
+		mcp.WithBoolean("namesOnly",
+			mcp.Description("Return only entity names, sorted by name (default: false). Cannot be combined with entityType, sort, order, or includeObservations."),
+		),
+	)
+
+	listRelationsTool := mcp.NewTool("list_relations",
+		mcp.WithDescription(`List relations page by page, oldest first.
+
+USE WHEN: Exporting or auditing edges incrementally on a large graph, where read_graph would return too much at once. Keep requesting the next offset until hasMore is false.
+
+RETURNS: A page of relations (from, to, relationType, createdAt when known) plus total count and hasMore flag.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("List Relations"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithNumber("limit",
+			mcp.Description("Max relations to return (default: 100, max: 1000)"),
+		),
+		mcp.WithNumber("offset",
+			mcp.Description("Number of relations to skip (default: 0)"),
+		),
+	)
+
+	// Add recent_entities tool
+	recentEntitiesTool := mcp.NewTool("recent_entities",
+		mcp.WithDescription(`List the entities that changed most recently, newest first.
+
+USE WHEN: Resuming work in a new session ("what was I working on?") — it is a cheap recency cue before open_nodes on the entities that matter.
+
+RETURNS: Entity names and types with updatedAt (the last time the entity or its observations changed). On JSONL, entities unchanged since the file was written by a version that recorded no update times come last, without updatedAt.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Recent Entities"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithNumber("limit",
+			mcp.Description(fmt.Sprintf("Max entities to return (default: %d, max: 200)", storage.DefaultRecentLimit)),
+		),
+	)
+
+	// Add find_by_observation tool
+	findByObservationTool := mcp.NewTool("find_by_observation",
+		mcp.WithDescription(`Find which entities contain an exact observation. The inverse of open_nodes.
+
+USE WHEN: You need to know where a fact lives, e.g. before moving, updating, or deleting it.
+
+REQUIRES: The exact observation text (case-sensitive, no partial matching). Use search_nodes for keyword search.
+RETURNS: All entities containing that observation, with their full observations.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Find By Observation"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("content",
+			mcp.Required(),
+			mcp.Description("Exact observation text to look up"),
+		),
+	)
+
+	// Add traverse tool
+	traverseTool := mcp.NewTool("traverse",
+		mcp.WithDescription(`Follow relations of a specific type from one entity to find its typed neighbors.
+
+USE WHEN: Answering relation questions such as "who works at Acme?" (from: "Acme", relationType: "works_at", direction: "in") or "what does Alice use?" (from: "Alice", relationType: "uses").
+
+DIRECTION:
+- "out" (default): entities that 'from' points to (from → X)
+- "in": entities pointing to 'from' (X → from)
+- "both": either direction
+
+RETURNS: Connected entities with name, type, relation type, and direction.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Traverse Relations"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("from",
+			mcp.Required(),
+			mcp.Description("Exact name of the entity to start from"),
+		),
+		mcp.WithString("relationType",
+			mcp.Description("Relation type to follow (e.g. 'works_at'). Omit to follow all relation types."),
+		),
+		mcp.WithString("direction",
+			mcp.Description("'out' (default), 'in', or 'both'"),
+			mcp.Enum("out", "in", "both"),
+		),
+	)
+
+	// Add reachable tool
+	reachableTool := mcp.NewTool("reachable",
+		mcp.WithDescription(`Find every entity reachable from one entity within a number of hops along a relation type.
+
+USE WHEN: Answering transitive questions such as "which organizations is Alice connected to through employment?" (start: "Alice", relationType: "works_at") or "everything that depends on X, directly or indirectly" (direction: "in"). Use traverse for a single hop.
+
+RETURNS: Reachable entities with name, type, and hops (the shortest distance from start), nearest first. The start entity is not included.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Reachable Entities"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("start",
+			mcp.Required(),
+			mcp.Description("Exact name of the entity to start from"),
+		),
+		mcp.WithString("relationType",
+			mcp.Description("Relation type to follow at every hop (e.g. 'works_at'). Omit to follow all relation types."),
+		),
+		mcp.WithNumber("maxHops",
+			mcp.Description(fmt.Sprintf("Maximum number of hops (default %d, max %d)", storage.DefaultReachableHops, storage.MaxReachableHops)),
+		),
+		mcp.WithString("direction",
+			mcp.Description("'out' (default), 'in', or 'both'"),
+			mcp.Enum("out", "in", "both"),
+		),
+	)
+
+	closureTool := mcp.NewTool("closure",
+		mcp.WithDescription(`Find the full transitive closure of an entity: everything reachable from it, at any distance, up to a node limit.
+
+USE WHEN: Impact analysis such as "everything that depends on module X, transitively" (start: "X", relationType: "depends_on", direction: "in"). Use reachable when a few hops are enough.
+
+RETURNS: {start, entities, truncated}. Each entity has name, type, and hops (the shortest path length from start), nearest first; the start entity is not included. truncated is true when the node limit cut the result short, in which case the farthest entities are missing.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Transitive Closure"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("start",
+			mcp.Required(),
+			mcp.Description("Exact name of the entity to start from"),
+		),
+		mcp.WithString("relationType",
+			mcp.Description("Relation type to follow at every hop (e.g. 'depends_on'). Omit to follow all relation types."),
+		),
+		mcp.WithString("direction",
+			mcp.Description("'out' (default) follows relations from each entity, 'in' follows them backwards, 'both' ignores direction"),
+			mcp.Enum("out", "in", "both"),
+		),
+		mcp.WithNumber("maxNodes",
+			mcp.Description(fmt.Sprintf("Maximum number of entities to return (default %d, max %d)", storage.DefaultClosureNodes, storage.MaxClosureNodes)),
+		),
+	)
+
+	findAllPathsTool := mcp.NewTool("find_all_paths",
+		mcp.WithDescription(`Find every route from one entity to another along outgoing relations, not just the shortest.
+
+USE WHEN: Explaining how two things are connected, e.g. all the ways a service ends up depending on a library. Use reachable or closure to list what can be reached at all.
+
+BEHAVIOR: No path visits an entity twice. The search stops at maxPaths, keeping the shortest paths.
+
+RETURNS: A list of paths, shortest first. Each path is the list of relations followed, from "from" to "to". Empty when the entities are not connected within maxDepth.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Find All Paths"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("from",
+			mcp.Required(),
+			mcp.Description("Exact name of the entity the paths start at"),
+		),
+		mcp.WithString("to",
+			mcp.Required(),
+			mcp.Description("Exact name of the entity the paths end at"),
+		),
+		mcp.WithNumber("maxDepth",
+			mcp.Description(fmt.Sprintf("Maximum number of relations in a path (default %d, max %d)", storage.DefaultPathDepth, storage.MaxPathDepth)),
+		),
+		mcp.WithNumber("maxPaths",
+			mcp.Description(fmt.Sprintf("Maximum number of paths to return (default %d, max %d)", storage.DefaultMaxPaths, storage.MaxPaths)),
+		),
+	)
+
+	// Add export_entity_context tool
+	exportEntityContextTool := mcp.NewTool("export_entity_context",
+		mcp.WithDescription(`Write one entity and its neighborhood to a self-contained graph file on the server.
+
+USE WHEN: Handing focused context about one thing to another agent or store without exporting the whole graph. The file loads into another server with --seed.
+
+BEHAVIOR: Includes the entity, every entity within depth hops in either direction, all of their observations, and the relations among them. Relations leading outside the neighborhood are left out.
+
+RETURNS: The path of the written file and its entity and relation counts.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Export Entity Context"),
+		mcp.WithReadOnlyHintAnnotation(false), // writes a file, but leaves the graph untouched
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Exact name of the entity to export"),
+		),
+		mcp.WithNumber("depth",
+			mcp.Description(fmt.Sprintf("Hops of neighbors to include (default 1, max %d)", storage.MaxReachableHops)),
+		),
+		mcp.WithString("format",
+			mcp.Description("'jsonl' (default, the memory file format) or 'json' (a single object with entities and relations)"),
+			mcp.Enum(storage.ContextFormatJSONL, storage.ContextFormatJSON),
+		),
+	)
+
+	// Add adjacency_list tool
+	adjacencyListTool := mcp.NewTool("adjacency_list",
+		mcp.WithDescription(`Get the graph's structure as a compact adjacency list, without observations.
+
+USE WHEN: Reasoning about how entities connect (clusters, hubs, dead ends) where read_graph in full mode would be too large.
+
+BEHAVIOR: Covers every entity, or only those matching query (like search_nodes) and the type filters. Only relations between covered entities are listed.
+
+RETURNS: {"adjacency": {entityName: [{to, relationType}, ...]}} with every covered entity as a key (an empty list when it has no outgoing relations), plus entity and relation counts.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Adjacency List"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("query",
+			mcp.Description("Optional: only cover entities matching these search keywords"),
+		),
+		mcp.WithArray("includeTypes",
+			mcp.Description("Optional: only cover entities of these types"),
+			mcp.Items(map[string]any{"type": "string"}),
+		),
+		mcp.WithArray("excludeTypes",
+			mcp.Description("Optional: leave out entities of these types"),
+			mcp.Items(map[string]any{"type": "string"}),
+		),
+	)
+
+	// Add similar_entities tool
+	similarEntitiesTool := mcp.NewTool("similar_entities",
+		mcp.WithDescription(`Find the entities most like a given one, without embeddings.
+
+USE WHEN: Recommendation-style questions ("what else is like X?"), or looking for duplicates and related work that keyword search would miss.
+
+BEHAVIOR: Scores each candidate by the Jaccard overlap of the entities it is related to (in either direction) and/or of the words in its observations. Candidates are entities sharing a neighbor with the target plus those a search for its most distinctive words finds, capped for large graphs.
+
+RETURNS: {"entity": name, "similar": [{name, entityType, score, neighborOverlap, observationOverlap, sharedNeighbors}, ...], "count": N}, best first. Scores range from 0 to 1; entities scoring 0 are left out.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Similar Entities"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("The entity to find similar entities for"),
+		),
+		mcp.WithString("by",
+			mcp.Description("What to compare: relations, observations, or both (default: both)"),
+			mcp.Enum(storage.SimilarityBoth, storage.SimilarityRelations, storage.SimilarityObservations),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description(fmt.Sprintf("Maximum entities to return (default: %d, max: %d)", storage.DefaultSimilarLimit, storage.MaxSimilarLimit)),
+		),
+	)
+
+	// Add topic_summary tool
+	topicSummaryTool := mcp.NewTool("topic_summary",
+		mcp.WithDescription(`Get a quick thematic overview of the graph: the most common words, and optionally word pairs, across all observations.
+
+USE WHEN: Getting oriented in an unfamiliar memory ("what is this graph about?") before searching, without reading every entity.
+
+BEHAVIOR: Splits every observation into lowercased words, leaves out stopwords (a configurable list of common words such as "the" and "with"), single characters, and numbers, and counts the rest. A bigram is two counted words that appear next to each other.
+
+RETURNS: {"observations": N, "terms": [{term, count}, ...], "bigrams": [{term, count}, ...]}, most frequent first.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Topic Summary"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithNumber("limit",
+			mcp.Description(fmt.Sprintf("Maximum terms and bigrams to return each (default: %d, max: %d)", storage.DefaultTopicLimit, storage.MaxTopicLimit)),
+		),
+		mcp.WithBoolean("bigrams",
+			mcp.Description("Also count pairs of adjacent words (default: true)"),
+		),
+		mcp.WithArray("extraStopwords",
+			mcp.Description("More words to leave out for this call, on top of the server's stopword list"),
+			mcp.Items(map[string]any{
+				"type": "string",
+			}),
+		),
+	)
+
+	// Add observations_in_range tool
+	observationsInRangeTool := mcp.NewTool("observations_in_range",
+		mcp.WithDescription(`List the observations added during a time window, across all entities.
+
+USE WHEN: Answering "what did I learn last week?" or reviewing recent additions to memory.
+
+BEHAVIOR: start and end are inclusive. A date alone means the start of that day for start and the end of it for end (UTC). Without end, everything since start is listed. With JSONL storage, observations written before the server recorded observation times have none and are never listed.
+
+RETURNS: {"observations": [{entityName, entityType, content, createdAt, source}, ...], "count": N}, oldest first.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Observations In Range"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("start",
+			mcp.Required(),
+			mcp.Description("Start of the window: an RFC 3339 timestamp or YYYY-MM-DD date"),
+		),
+		mcp.WithString("end",
+			mcp.Description("Optional: end of the window, an RFC 3339 timestamp or YYYY-MM-DD date (default: now)"),
+		),
+	)
+
+	// Add entities_modified_since tool
+	entitiesModifiedSinceTool := mcp.NewTool("entities_modified_since",
+		mcp.WithDescription(`List the entities created or changed since a time, with all their observations.
+
+USE WHEN: Syncing an external index incrementally: record when you last synced and pull only what changed since, instead of the whole graph.
+
+BEHAVIOR: An entity changes when it is created, renamed, retyped, or its description or observations change. since is inclusive and SQLite times have one-second resolution, so passing the newest updatedAt from the last call returns those entities again rather than missing later changes in the same second. Deleted entities are not reported, nor, on JSONL, entities unchanged since the file was written by a version that recorded no update times.
+
+RETURNS: {"entities": [{name, entityType, description, observations, updatedAt}, ...], "count": N}, oldest change first.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Entities Modified Since"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("since",
+			mcp.Required(),
+			mcp.Description("An RFC 3339 timestamp or YYYY-MM-DD date (the start of that day, UTC)"),
+		),
+	)
+
+	// Add relation_exists tool
+	relationExistsTool := mcp.NewTool("relation_exists",
+		mcp.WithDescription(`Check whether a specific relation already exists.
+
+USE WHEN: Before create_relations, to avoid asserting an edge that is already recorded.
+
+RETURNS: {"exists": true|false}. All three fields must match exactly (case-sensitive).`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Relation Exists"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("from",
+			mcp.Required(),
+			mcp.Description("Exact name of the source entity"),
+		),
+		mcp.WithString("to",
+			mcp.Required(),
+			mcp.Description("Exact name of the target entity"),
+		),
+		mcp.WithString("relationType",
+			mcp.Required(),
+			mcp.Description("Exact relation type"),
+		),
+	)
+
+	// Add find_self_relations tool
+	findSelfRelationsTool := mcp.NewTool("find_self_relations",
+		mcp.WithDescription(`List relations whose from and to are the same entity (self-loops), optionally deleting them.
+
+USE WHEN: Cleaning up after bad writes. Self-loops are almost always mistakes and clutter traversals.
+
+RETURNS: {"relations": [...], "deleted": n}. Relations are listed whether or not they were deleted.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Find Self Relations"),
+		mcp.WithDestructiveHintAnnotation(true),
+		mcp.WithBoolean("delete",
+			mcp.Description("Delete the self-referential relations that were found (default: false, only list them)"),
+		),
+	)
+
+	// Add find_empty_entities tool
+	findEmptyEntitiesTool := mcp.NewTool("find_empty_entities",
+		mcp.WithDescription(`List entities that have no observations.
+
+USE WHEN: Auditing data quality. Entities without observations are often stubs created only as relation endpoints, and need enriching with add_observations or deleting.
+
+RETURNS: {"entities": [{"name", "entityType"}, ...], "count": n}, sorted by name.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Find Empty Entities"),
+		mcp.WithReadOnlyHintAnnotation(true),
+	)
+
+	relationTypeSamplesTool := mcp.NewTool("relation_type_samples",
+		mcp.WithDescription(`Count the relations of each type and show a few examples of what each type connects.
+
+USE WHEN: Auditing relation types for misuse, e.g. a "manages" relation between two documents, without reading every relation.
+
+RETURNS: {"relationTypes": {type: {"count": n, "examples": [...]}}}. Examples are the oldest relations of the type.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Relation Type Samples"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithNumber("limit",
+			mcp.Description(fmt.Sprintf("Maximum examples per relation type (default %d, max %d)", storage.DefaultRelationSamples, storage.MaxRelationSamples)),
+		),
+	)
+
+	// Add top_connected_pairs tool
+	topConnectedPairsTool := mcp.NewTool("top_connected_pairs",
+		mcp.WithDescription(`List the entity pairs connected by the most distinct relations, to spot tightly-coupled concepts.
+
+Pairs are directed: A → B and B → A are counted separately.
+
+RETURNS: [{"from", "to", "count"}], most relations first.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Top Connected Pairs"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithNumber("limit",
+			mcp.Description("Max pairs to return (default: 10, max: 100)"),
+		),
+	)
+
+	filterByObservationCountTool := mcp.NewTool("filter_by_observation_count",
+		mcp.WithDescription(`List entities whose number of observations falls within a range.
+
+USE WHEN: Checking graph quality, e.g. "entities with no observations" (maxCount: 0) or "the 10 most-documented entities" (limit: 10).
+
+RETURNS: [{"name", "entityType", "observationCount"}], most observations first unless order is "asc".`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Filter by Observation Count"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithNumber("minCount",
+			mcp.Description("Minimum number of observations (default: 0)"),
+		),
+		mcp.WithNumber("maxCount",
+			mcp.Description("Maximum number of observations. Omit for no upper bound."),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Max entities to return (default: 50, max: 1000)"),
+		),
+		mcp.WithString("order",
+			mcp.Description("Sort by observation count: 'desc' (default) or 'asc'"),
+			mcp.Enum("asc", "desc"),
+		),
+	)
+
+	// Add get_observations tool
+	getObservationsTool := mcp.NewTool("get_observations",
+		mcp.WithDescription(`Page through the observations of a single entity, in insertion order.
+
+USE WHEN: An entity has more observations than open_nodes returns (it reports "observationsTotal"), or you only need part of a heavily-annotated entity.
+
+RETURNS: The requested slice of observations plus total count and hasMore flag.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Get Observations"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("entityName",
+			mcp.Required(),
+			mcp.Description("Exact name of the entity"),
+		),
+		mcp.WithNumber("offset",
+			mcp.Description("Number of observations to skip (default: 0)"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Max observations to return (default: 50, max: 500)"),
+		),
+	)
+
+	// Add merge_entities tool
+	mergeEntitiesTool := mcp.NewTool("merge_entities",
+		mcp.WithDescription(`Merge two entities into one. All observations and relations from the source entity are migrated to the target entity, then the source is deleted.
+
+USE WHEN: You discover duplicate entities (e.g. "React.js" and "React" refer to the same thing).
+
+BEHAVIOR:
+- Source observations are added to target (duplicates skipped)
+- Source relations are redirected to target (duplicates skipped)
+- Source entity is deleted after migration
+
+EXAMPLE: sourceName: "React.js", targetName: "React" → merges React.js into React`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Merge Entities"),
+		mcp.WithDestructiveHintAnnotation(true),
+		mcp.WithString("sourceName",
+			mcp.Required(),
+			mcp.Description("Entity to merge FROM (will be deleted)"),
+		),
+		mcp.WithString("targetName",
+			mcp.Required(),
+			mcp.Description("Entity to merge INTO (will receive observations and relations)"),
+		),
+	)
+
+	// Add copy_entities and move_entities tools, which share their parameters
+	transferParams := []mcp.ToolOption{
+		mcp.WithArray("names",
+			mcp.Required(),
+			mcp.Description("Names of the entities to transfer"),
+			mcp.Items(map[string]any{
+				"type": "string",
+			}),
+		),
+		mcp.WithString("fromNamespace",
+			mcp.Description("Namespace to take the entities from (default: the server's namespace)"),
+		),
+		mcp.WithString("toNamespace",
+			mcp.Required(),
+			mcp.Description("Namespace to put the entities in"),
+		),
+		mcp.WithString("mode",
+			mcp.Description("What to do when the target namespace already has an entity of the same name: skip (default), overwrite its type and observations, or rename the transferred one (e.g. \"Alice (2)\")"),
+			mcp.Enum(storage.TransferModeSkip, storage.TransferModeOverwrite, storage.TransferModeRename),
+		),
+		mcp.WithBoolean("includeRelations",
+			mcp.Description("Also transfer relations whose other endpoint is transferred too or already exists in the target (default true)"),
+		),
+	}
+	copyEntitiesTool := mcp.NewTool("copy_entities", append([]mcp.ToolOption{
+		mcp.WithDescription(`Copy entities, with their observations and optionally their relations, into another namespace. The originals stay where they are.
+
+USE WHEN: Promoting something learned in a scratch namespace into a shared one.
+
+RETURNS: Each transferred entity with its name in the target, the names skipped because they already exist there, and relation counts.`),
+		mcp.WithTitleAnnotation("Copy Entities"),
+		mcp.WithDestructiveHintAnnotation(true),
+	}, transferParams...)...)
+	moveEntitiesTool := mcp.NewTool("move_entities", append([]mcp.ToolOption{
+		mcp.WithDescription(`Move entities, with their observations and optionally their relations, into another namespace. Moved entities and all their relations are removed from the source; entities skipped because of a name collision stay.
+
+USE WHEN: Curating a clean shared graph from an experimental namespace.
+
+RETURNS: Each transferred entity with its name in the target, the names skipped because they already exist there, and relation counts.`),
+		mcp.WithTitleAnnotation("Move Entities"),
+		mcp.WithDestructiveHintAnnotation(true),
+	}, transferParams...)...)
+
+	// Add update_entities tool
+	updateEntitiesTool := mcp.NewTool("update_entities",
+		mcp.WithDescription(`Update the type of an existing entity.
+
+USE WHEN: An entity was created with the wrong type and needs correction.
+
+EXAMPLE: name: "React", entityType: "framework" (was previously "library")`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Update Entity Type"),
+		mcp.WithDestructiveHintAnnotation(true),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Exact name of the entity to update"),
+		),
+		mcp.WithString("entityType",
+			mcp.Required(),
+			mcp.Description("New entity type to set"),
+		),
+	)
+
+	// Add set_entity_type tool
+	setEntityTypeTool := mcp.NewTool("set_entity_type",
+		mcp.WithDescription(`Change an entity's type without touching its observations or relations.
+
+USE WHEN: Correcting a type, e.g. "organisation" -> "organization". Use this instead of re-sending the entity to create_entities.
+
+RETURNS: The updated entity with all its observations.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Set Entity Type"),
+		mcp.WithDestructiveHintAnnotation(true),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Exact name of the entity to update"),
+		),
+		mcp.WithString("entityType",
+			mcp.Required(),
+			mcp.Description("New entity type"),
+		),
+	)
+
+	typeKindParam := func(opts ...mcp.PropertyOption) mcp.ToolOption {
+		return mcp.WithString("kind", append(opts, mcp.Enum(storage.TypeKindEntity, storage.TypeKindRelation))...)
+	}
+
+	setTypeDescriptionTool := mcp.NewTool("set_type_description",
+		mcp.WithDescription(`Record what an entity or relation type means, e.g. person: "an individual human", so agents sharing the graph use its vocabulary the same way. SQLite storage only.
+
+USE WHEN: Introducing a type, or when a type's meaning is unclear from its name. The type need not be in use yet.
+
+BEHAVIOR: Replaces any earlier description of the type; an empty description removes it. Descriptions are metadata: renaming or deleting types does not change them.
+
+RETURNS: The namespace's type descriptions of that kind, as get_type_descriptions shows them.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Set Type Description"),
+		mcp.WithDestructiveHintAnnotation(false),
+		typeKindParam(
+			mcp.Required(),
+			mcp.Description("Whether type is an entity type or a relation type"),
+		),
+		mcp.WithString("type",
+			mcp.Required(),
+			mcp.Description("Exact entity or relation type"),
+		),
+		mcp.WithString("description",
+			mcp.Required(),
+			mcp.Description("What the type means (empty to remove the description)"),
+		),
+	)
+
+	getTypeDescriptionsTool := mcp.NewTool("get_type_descriptions",
+		mcp.WithDescription(`List the recorded meanings of entity and relation types. SQLite storage only.
+
+USE WHEN: Before creating entities or relations in a shared graph, to pick the types already in use with the meaning intended. read_graph's summary includes them too.
+
+RETURNS: [{kind, type, description, updatedAt}], entity types first, then by type.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Get Type Descriptions"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		typeKindParam(
+			mcp.Description("Only list entity or relation types (default: both)"),
+		),
+	)
+
+	updateEntityTool := mcp.NewTool("update_entity",
+		mcp.WithDescription(`Rename an entity, change its type, and/or set its description in one atomic step. Relations follow the entity to its new name.
+
+USE WHEN: An entity was created under the wrong name, e.g. "Jon Smith" -> "John Smith", possibly with the wrong type too, or its description needs rewriting. Leave newName or newType empty and omit description to keep that field.
+
+RETURNS: The updated entity, as open_nodes shows it. Fails without changing anything if newName is already taken.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Update Entity"),
+		mcp.WithDestructiveHintAnnotation(true),
+		mcp.WithString("oldName",
+			mcp.Required(),
+			mcp.Description("Exact current name of the entity"),
+		),
+		mcp.WithString("newName",
+			mcp.Description("New name (omit to keep the current one)"),
+		),
+		mcp.WithString("newType",
+			mcp.Description("New entity type (omit to keep the current one)"),
+		),
+		mcp.WithString("description",
+			mcp.Description("New description (omit to keep the current one, empty string to clear it)"),
+		),
+	)
+
+	// Add update_observations tool
+	updateObservationsTool := mcp.NewTool("update_observations",
+		mcp.WithDescription(`Replace an existing observation with updated content. Use this to correct outdated or inaccurate facts.
+
+USE WHEN: An observation needs correction (e.g. "Uses React 17" → "Uses React 18").
+
+REQUIRES: The exact old observation text. Use open_nodes first to get the current text.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Update Observation"),
+		mcp.WithDestructiveHintAnnotation(true),
+		mcp.WithString("entityName",
+			mcp.Required(),
+			mcp.Description("Exact name of the entity containing the observation"),
+		),
+		mcp.WithString("oldContent",
+			mcp.Required(),
+			mcp.Description("Exact current observation text to replace"),
+		),
+		mcp.WithString("newContent",
+			mcp.Required(),
+			mcp.Description("New observation text"),
+		),
+	)
+
+	// Add set_observations tool
+	setObservationsTool := mcp.NewTool("set_observations",
+		mcp.WithDescription(`Replace ALL observations of an entity with exactly the given list, atomically.
+
+USE WHEN: You know the complete desired state of an entity and want to reconcile it in one step, instead of deleting and re-adding observations.
+
+WARNING: Observations not in the list are removed. Use add_observations to append instead.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Set Observations"),
+		mcp.WithDestructiveHintAnnotation(true),
+		mcp.WithString("entityName",
+			mcp.Required(),
+			mcp.Description("Exact name of the entity to update"),
+		),
+		mcp.WithArray("observations",
+			mcp.Required(),
+			mcp.Description("The complete new list of observations (may be empty to clear them)"),
+			mcp.Items(map[string]any{
+				"type": "string",
+			}),
+		),
+	)
+
+	replaceInObservationsTool := mcp.NewTool("replace_in_observations",
+		mcp.WithDescription(`Replace a phrase in every observation of every entity, e.g. after a terminology change ("ML" -> "machine learning").
+
+BEHAVIOR: Plain text, not a pattern. Every occurrence in an observation is replaced. An observation that ends up identical to another one of the same entity is merged into it.
+
+WARNING: Graph-wide. A short phrase may also match inside other words, especially with caseSensitive false; check with search_nodes first.
+
+RETURNS: {"old", "new", "modified"}: the number of observations changed, merged ones included.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Replace In Observations"),
+		mcp.WithDestructiveHintAnnotation(true),
+		mcp.WithString("old",
+			mcp.Required(),
+			mcp.Description("Text to replace (must not be empty)"),
+		),
+		mcp.WithString("new",
+			mcp.Required(),
+			mcp.Description("Replacement text (may be empty to remove the text)"),
+		),
+		mcp.WithBoolean("caseSensitive",
+			mcp.Description("Match old with exact case (default: true)"),
+		),
+	)
+
+	// Add detect_conflicts tool
+	detectConflictsTool := mcp.NewTool("detect_conflicts",
+		mcp.WithDescription(`Detect potential duplicate or contradictory observations within entities.
+
+Analyzes observation pairs for:
+- Potential duplicates: observations with high prefix overlap (>60% of words match at start)
+- Potential contradictions: observations containing antonym keyword pairs (e.g. "likes X" vs "dislikes X")
+
+USE WHEN: Reviewing memory quality, or after bulk imports to find inconsistencies.
+
+RETURNS: List of conflicts with entity name, both observations, and conflict type.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Detect Conflicts"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("entityName",
+			mcp.Description("Optional: check only this entity. Omit to check all entities."),
+		),
+	)
+
+	// Add storage_info tool
+	storageInfoTool := mcp.NewTool("storage_info",
+		mcp.WithDescription(`Report which storage backend the server is using and where the data lives.
+
+USE WHEN: Memories you expect are missing — e.g. the server auto-migrated a JSONL file to a .db file next to it.
+
+RETURNS: backend (sqlite or jsonl), absolute filePath, the namespace's entities and relations counts, for SQLite the schemaVersion, journalMode, walEnabled, foreignKeys, and ftsAvailable, and for JSONL any loadWarnings (lines skipped as malformed).`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Storage Info"),
+		mcp.WithReadOnlyHintAnnotation(true),
+	)
+
+	// Add check_integrity tool
+	checkIntegrityTool := mcp.NewTool("check_integrity",
+		mcp.WithDescription(`Check the store for inconsistent data. A read-only diagnostic.
+
+USE WHEN: Something behaves oddly — search misses an entity that exists, a relation points at nothing, or an entity shows up twice.
+
+BEHAVIOR: Checks every namespace for relations to or from missing entities; for SQLite, observations of missing entities and whether the full-text search indexes match their tables; for JSONL, entity names stored more than once.
+
+RETURNS: ok, plus each problem's count and up to 10 samples. Missing SQLite entities are shown by row ID, e.g. "#42". fts (SQLite only) gives row counts of the tables and their indexes and any error from FTS5's integrity check.`),
+		mcp.WithTitleAnnotation("Check Integrity"),
+		mcp.WithReadOnlyHintAnnotation(true),
+	)
+
+	sessionChangesTool := mcp.NewTool("session_changes",
+		mcp.WithDescription(`List what was added to the graph since the server started or reset_session was last called.
+
+USE WHEN: Recapping a conversation, e.g. "summarize what I learned this session", without diffing snapshots.
+
+BEHAVIOR: Tracks additions made through create_entities, create_relations, and add_observations, in memory only. Deletions and edits are not tracked, and the record is lost on restart.
+
+RETURNS: {since, entities, observations, relations}. entities lists name and type of entities created, or merged into by create_entities; observations maps entity names to the observations added to them.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Session Changes"),
+		mcp.WithReadOnlyHintAnnotation(true),
+	)
+
+	resetSessionTool := mcp.NewTool("reset_session",
+		mcp.WithDescription(`Start a new session: forget the additions session_changes has recorded so far. The graph itself is not changed.
+
+RETURNS: The time the new session started.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Reset Session"),
+		mcp.WithDestructiveHintAnnotation(false),
+	)
+
+	analyzeGraphTool := mcp.NewTool("analyze_graph",
+		mcp.WithDescription(`Summarize the graph: entity, relation, and observation counts, how many entities and relations there are of each type, and the 10 most connected entities.
+
+USE WHEN: Getting an overview of what the graph holds, e.g. for a dashboard. Prefer it to read_graph when only the shape matters.
+
+BEHAVIOR: With --analytics-cache on SQLite storage, returns the copy cached by the last refresh, recomputed only when there is none or --analytics-refresh-every writes have been made since. Otherwise computed for each call.
+
+RETURNS: {analysis: {entity_count, relation_count, observation_count, entity_types, relation_types, most_connected}, computedAt, cached, stale, writesSince}. stale means the graph may have changed since computedAt; call refresh_analytics for current figures.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Analyze Graph"),
+		mcp.WithReadOnlyHintAnnotation(true),
+	)
+
+	refreshAnalyticsTool := mcp.NewTool("refresh_analytics",
+		mcp.WithDescription(`Recompute the figures analyze_graph returns and replace its cached copy. The graph itself is not changed.
+
+USE WHEN: analyze_graph reported stale: true and current figures are needed.
+
+RETURNS: The fresh analytics, as analyze_graph returns them.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Refresh Analytics"),
+		mcp.WithDestructiveHintAnnotation(false),
+	)
+
+	checkpointTool := mcp.NewTool("checkpoint",
+		mcp.WithDescription(`Flush the SQLite write-ahead log (the -wal file) into the database and truncate it.
+
+USE WHEN: The -wal file has grown during heavy writes and disk usage matters now. The server also checkpoints every --checkpoint-interval and on shutdown.
+
+RETURNS: {busy, logFrames, checkpointedFrames}. busy is true when active readers or writers kept the checkpoint from finishing, in which case logFrames counts the frames left in the WAL; run it again later. Fails on JSONL storage.`),
+		mcp.WithTitleAnnotation("Checkpoint"),
+		mcp.WithDestructiveHintAnnotation(false),
+	)
+
+	// Add validate_file tool
+	validateFileTool := mcp.NewTool("validate_file",
+		mcp.WithDescription(`Check a JSONL memory file line by line and report the lines that cannot be loaded, without loading the graph.
+
+USE WHEN: Data seems to be missing from a JSONL store, or before importing or migrating a file that may be partially corrupted. Malformed lines are skipped on load and dropped the next time the file is saved.
+
+RETURNS: {"path", "lines", "entities", "relations", "errors": [{"line", "reason"}], "valid"}.`),
+		mcp.WithTitleAnnotation("Validate File"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("path",
+			mcp.Description("JSONL file to check (plain or gzip). Omit to check the server's own memory file when it uses JSONL storage."),
+		),
+	)
+
+	// Add list_namespaces tool
+	listNamespacesTool := mcp.NewTool("list_namespaces",
+		mcp.WithDescription(`List the graph namespaces in the store. Each namespace is a separate graph; pass "namespace" to any tool to work in it.
+
+RETURNS: Sorted namespace names, always including the server's active namespace.`),
+		mcp.WithTitleAnnotation("List Namespaces"),
+		mcp.WithReadOnlyHintAnnotation(true),
+	)
+
+	// Add import_graph tool
+	importGraphTool := mcp.NewTool("import_graph",
+		mcp.WithDescription(`Import a batch of entities and relations, reporting conflicts with what is already stored.
+
+CONFLICT MODES:
+- overwrite (default): existing entities take the imported type and gain new observations
+- skip: existing entities are left untouched; only new entities and relations are added
+- report: nothing is written; returns what an overwrite import would change
+
+USE WHEN: Loading memories exported from another store, or checking an import with conflictMode "report" before applying it.
+
+MIGRATING FROM THE REFERENCE SERVER: Pass the contents of a @modelcontextprotocol/server-memory memory.json file as data with format "mcp-memory" instead of entities and relations. JSONL, JSON array, and {entities, relations} object files are all understood, including common field-name variants.
+
+RETURNS: Counts of created/updated/skipped items plus type conflicts, new observations on existing entities, relations already present, and relations dropped for missing endpoints. With format "mcp-memory", skipped lists items of data that could not be read.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Import Graph"),
+		mcp.WithDestructiveHintAnnotation(true),
+		mcp.WithArray("entities",
+			mcp.Description("Entities to import"),
+			mcp.Items(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"name":         map[string]any{"type": "string"},
+					"entityType":   map[string]any{"type": "string"},
+					"observations": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+				},
+				"required": []string{"name", "entityType"},
+			}),
+		),
+		mcp.WithArray("relations",
+			mcp.Description("Relations to import; both endpoints must exist in the store or in this import"),
+			mcp.Items(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"from":         map[string]any{"type": "string"},
+					"to":           map[string]any{"type": "string"},
+					"relationType": map[string]any{"type": "string"},
+				},
+				"required": []string{"from", "to", "relationType"},
+			}),
+		),
+		mcp.WithString("conflictMode",
+			mcp.Description("How to treat entities that already exist: overwrite (default), skip, or report"),
+			mcp.Enum(storage.ConflictModeOverwrite, storage.ConflictModeSkip, storage.ConflictModeReport),
+		),
+		mcp.WithString("format",
+			mcp.Description("'graph' (default): import the entities and relations parameters; 'mcp-memory': parse data as a reference server memory file"),
+			mcp.Enum("graph", storage.ImportFormatMCPMemory),
+		),
+		mcp.WithString("data",
+			mcp.Description("With format 'mcp-memory': the memory file's contents"),
+		),
+	)
+
+	// Add diff_graph tool
+	diffGraphTool := mcp.NewTool("diff_graph",
+		mcp.WithDescription(`Compare the stored graph against a baseline graph and return what changed since it.
+
+USE WHEN: Reviewing what was learned or changed during a session: save read_graph (mode "full") output at the start, then pass its entities and relations here at the end.
+
+BEHAVIOR: Entities are matched by name, relations by from/to/relationType; observation order is ignored. "Added" means present now but not in the baseline.
+
+RETURNS: Counts, plus added and removed entities and relations, and modified entities with their type change and added/removed observations.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Diff Graph"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithArray("entities",
+			mcp.Description("Entities of the baseline graph"),
+			mcp.Items(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"name":         map[string]any{"type": "string"},
+					"entityType":   map[string]any{"type": "string"},
+					"observations": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+				},
+				"required": []string{"name", "entityType"},
+			}),
+		),
+		mcp.WithArray("relations",
+			mcp.Description("Relations of the baseline graph"),
+			mcp.Items(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"from":         map[string]any{"type": "string"},
+					"to":           map[string]any{"type": "string"},
+					"relationType": map[string]any{"type": "string"},
+				},
+				"required": []string{"from", "to", "relationType"},
+			}),
+		),
+	)
+
+	// Add import_url tool
+	importURLTool := mcp.NewTool("import_url",
+		mcp.WithDescription(`Fetch a JSON or JSONL graph (optionally gzip-compressed) over HTTP(S) and merge it into the store, like import_graph.
+
+ADMIN ONLY: Refuses to run unless the server was started with --allow-remote-import.
+
+LIMITS: The download must finish within 30 seconds and be at most 10 MiB (after decompression). A file in which nothing parses as an entity or relation is rejected.
+
+RETURNS: The import_graph report plus url, bytes downloaded, and any skippedLines that could not be parsed.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Import From URL"),
+		mcp.WithDestructiveHintAnnotation(true),
+		mcp.WithOpenWorldHintAnnotation(true),
+		mcp.WithString("url",
+			mcp.Required(),
+			mcp.Description("http or https URL of the graph file"),
+		),
+		mcp.WithString("conflictMode",
+			mcp.Description("overwrite (default), skip, or report, as for import_graph"),
+			mcp.Enum(storage.ConflictModeOverwrite, storage.ConflictModeSkip, storage.ConflictModeReport),
+		),
+	)
+
+	// Add clear_graph tool
+	clearGraphTool := mcp.NewTool("clear_graph",
+		mcp.WithDescription(`Delete ALL entities, observations, and relations from the knowledge graph.
+
+ADMIN ONLY: Refuses to run unless the server was started with --allow-destructive. This cannot be undone.
+
+RETURNS: Counts of entities, observations, and relations removed, plus backupPath when the server takes automatic backups.`),
+		namespaceParam,
+		mcp.WithTitleAnnotation("Clear Graph"),
+		mcp.WithDestructiveHintAnnotation(true),
+	)
+
+	// Add handlers
+	// Mutating tools accept an idempotencyKey, which idempotent.middleware honors
+	idempotencyParam := mcp.WithString("idempotencyKey",
+		mcp.Description("Optional: a unique key for this call. Retrying with the same key returns the first call's result instead of applying the change again."),
+	)
+	for _, tool := range []*mcp.Tool{
+		&createEntitiesTool, &createRelationsTool, &addObservationsTool,
+		&deleteEntitiesTool, &deleteByQueryTool, &deleteObservationsTool, &deleteRelationsTool, &deleteRelationsByTypeTool,
+		&mergeEntitiesTool, &copyEntitiesTool, &moveEntitiesTool,
+		&updateEntitiesTool, &setEntityTypeTool, &updateEntityTool, &updateObservationsTool, &setObservationsTool, &replaceInObservationsTool,
+		&importGraphTool, &importURLTool, &clearGraphTool,
+	} {
+		idempotencyParam(tool)
+		idempotent.tools[tool.Name] = true
+	}
+
+	// addTool registers a tool unless --tools leaves it out
+	registeredTools := map[string]bool{}
+	addTool := func(tool mcp.Tool, handler server.ToolHandlerFunc) {
+		registeredTools[tool.Name] = true
+		if toolsAllowed.allows(tool) {
+			s.AddTool(tool, handler)
+		}
+	}
+
+	addTool(createEntitiesTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		// Bind arguments using new mcp-go helpers
+		var arg struct {
+			Entities      []storage.Entity `json:"entities"`
+			Source        string           `json:"source"`
+			MergeStrategy string           `json:"mergeStrategy"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+		if len(arg.Entities) == 0 {
+			return nil, fmt.Errorf("%w: missing required parameter: entities", storage.ErrInvalidArgument)
+		}
+		counts := make(map[string]int, len(arg.Entities))
+		for _, entity := range arg.Entities {
+			counts[entity.Name] += len(entity.Observations)
+		}
+		warnings, err := limits.check(counts)
+		if err != nil {
+			return nil, err
+		}
+
+		// Create entities
+		newEntities, err := manager.In(ctx).CreateEntitiesWithStrategy(withSource(arg.Entities, observationSource(ctx, arg.Source)), arg.MergeStrategy)
+		if err != nil {
+			return nil, err
+		}
+
+		// Convert result to JSON
+		resultJSON, err := json.MarshalIndent(newEntities, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+
+		return withWarnings(mcp.NewToolResultText(string(resultJSON)), warnings), nil
+	})
+
+	addTool(createRelationsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			Relations          []storage.Relation `json:"relations"`
+			AutoCreateEntities bool               `json:"autoCreateEntities"`
+			PlaceholderType    string             `json:"placeholderType"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+		if len(arg.Relations) == 0 {
+			return nil, fmt.Errorf("%w: missing required parameter: relations", storage.ErrInvalidArgument)
+		}
+
+		if err := inverses.check(manager.In(ctx), arg.Relations); err != nil {
+			return nil, err
+		}
+		var placeholders []storage.Entity
+		if arg.AutoCreateEntities {
+			if arg.PlaceholderType == "" {
+				arg.PlaceholderType = placeholderType
+			}
+			var err error
+			if placeholders, err = manager.In(ctx).CreatePlaceholders(arg.Relations, arg.PlaceholderType); err != nil {
+				return nil, err
+			}
+		}
+		relations := arg.Relations
+		var autoInverses []storage.Relation
+		if autoInverse {
+			autoInverses = inverses.inverses(arg.Relations)
+			relations = append(slices.Clip(relations), autoInverses...)
+		}
+
+		// Create relations
+		newRelations, err := manager.In(ctx).CreateRelations(relations)
+		if err != nil {
+			return nil, err
+		}
+
+		// Convert result to JSON
+		resultJSON, err := json.MarshalIndent(newRelations, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+
+		// Point out the entities and inverse edges that were created on the caller's behalf
+		var notes []string
+		for _, entity := range placeholders {
+			notes = append(notes, fmt.Sprintf("auto-created entity: %s (%s)", entity.Name, entity.EntityType))
+		}
+		for _, inverse := range autoInverses {
+			if slices.ContainsFunc(newRelations, func(r storage.Relation) bool {
+				return r.From == inverse.From && r.To == inverse.To && r.RelationType == inverse.RelationType
+			}) {
+				notes = append(notes, fmt.Sprintf("auto-created inverse relation: %s %s %s", inverse.From, inverse.RelationType, inverse.To))
+			}
+		}
+		return withWarnings(mcp.NewToolResultText(string(resultJSON)), notes), nil
+	})
+
+	addTool(addObservationsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			Observations []ObservationAddition `json:"observations"`
+			Source       string                `json:"source"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+		if len(arg.Observations) == 0 {
+			return nil, fmt.Errorf("%w: missing required parameter: observations", storage.ErrInvalidArgument)
+		}
+		counts := make(map[string]int, len(arg.Observations))
+		for _, addition := range arg.Observations {
+			counts[addition.EntityName] += len(addition.Contents)
+		}
+		warnings, err := limits.check(counts)
+		if err != nil {
+			return nil, err
+		}
+
+		// Add observations
+		results, err := manager.In(ctx).AddObservations(arg.Observations, observationSource(ctx, arg.Source))
+		if err != nil {
+			return nil, err
+		}
+
+		// Convert result to JSON
+		resultJSON, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+
+		return withWarnings(mcp.NewToolResultText(string(resultJSON)), warnings), nil
+	})
+
+	addTool(deleteEntitiesTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			EntityNames []string `json:"entityNames"`
+			DryRun      bool     `json:"dryRun"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+		if len(arg.EntityNames) == 0 {
+			return nil, fmt.Errorf("%w: missing required parameter: entityNames", storage.ErrInvalidArgument)
+		}
+		if arg.DryRun {
+			preview, err := manager.In(ctx).PreviewDeletion(arg.EntityNames, nil)
+			if err != nil {
+				return nil, err
+			}
+			resultJSON, err := json.MarshalIndent(preview, "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			return mcp.NewToolResultText(string(resultJSON)), nil
+		}
+
+		backup, err := autoBackup()
+		if err != nil {
+			return nil, err
+		}
+
+		deleted, err := manager.In(ctx).DeleteEntities(arg.EntityNames)
+		if err != nil {
+			return nil, err
+		}
+		requested := len(slices.Compact(slices.Sorted(slices.Values(arg.EntityNames))))
+		return deletionResult("entities", requested, deleted, backup)
+	})
+
+	addTool(deleteByQueryTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			Query      string `json:"query"`
+			EntityType string `json:"entityType"`
+			DryRun     bool   `json:"dryRun"`
+			Confirm    bool   `json:"confirm"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+		if !arg.DryRun && !arg.Confirm {
+			return nil, fmt.Errorf("%w: delete_by_query requires confirm: true (or dryRun: true to preview)", storage.ErrInvalidArgument)
+		}
+
+		var backup string
+		if !arg.DryRun {
+			var err error
+			if backup, err = autoBackup(); err != nil {
+				return nil, err
+			}
+		}
+
+		result, err := manager.In(ctx).DeleteByQuery(arg.Query, arg.EntityType, arg.DryRun)
+		if err != nil {
+			return nil, err
+		}
+		result.BackupPath = backup
+		if !arg.DryRun {
+			log.Printf("delete_by_query (query %q, entityType %q) deleted %d entities: %s",
+				arg.Query, arg.EntityType, result.Deleted, strings.Join(result.Names, ", "))
+		}
+
+		resultJSON, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(deleteObservationsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			Deletions []storage.ObservationDeletion `json:"deletions"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+		if len(arg.Deletions) == 0 {
+			return nil, fmt.Errorf("%w: missing required parameter: deletions", storage.ErrInvalidArgument)
+		}
+
+		for _, deletion := range arg.Deletions {
+			if len(deletion.Observations) == 0 && len(deletion.Contains) == 0 && len(deletion.Indexes) == 0 {
+				return nil, fmt.Errorf("%w: deletion for %q needs observations, contains, or indexes", storage.ErrInvalidArgument, deletion.EntityName)
+			}
+		}
+
+		deleted, err := manager.In(ctx).DeleteObservations(arg.Deletions)
+		if err != nil {
+			return nil, err
+		}
+		total := 0
+		for _, n := range deleted {
+			total += n
+		}
+
+		resultJSON, err := json.MarshalIndent(map[string]any{
+			"deleted": deleted,
+			"total":   total,
+		}, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(deleteRelationsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			Relations []storage.Relation `json:"relations"`
+			DryRun    bool               `json:"dryRun"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+		if len(arg.Relations) == 0 {
+			return nil, fmt.Errorf("%w: missing required parameter: relations", storage.ErrInvalidArgument)
+		}
+		if arg.DryRun {
+			preview, err := manager.In(ctx).PreviewDeletion(nil, arg.Relations)
+			if err != nil {
+				return nil, err
+			}
+			resultJSON, err := json.MarshalIndent(preview, "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			return mcp.NewToolResultText(string(resultJSON)), nil
+		}
+
+		deleted, err := manager.In(ctx).DeleteRelations(arg.Relations)
+		if err != nil {
+			return nil, err
+		}
+		requested := make(map[[3]string]bool, len(arg.Relations))
+		for _, r := range arg.Relations {
+			requested[[3]string{r.From, r.To, r.RelationType}] = true
+		}
+		return deletionResult("relations", len(requested), deleted, "")
+	})
+
+	addTool(deleteRelationsByTypeTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			RelationType string `json:"relationType"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+		if arg.RelationType == "" {
+			return nil, fmt.Errorf("%w: missing required parameter: relationType", storage.ErrInvalidArgument)
+		}
+
+		deleted, err := manager.In(ctx).DeleteRelationsByType(arg.RelationType)
+		if err != nil {
+			return nil, err
+		}
+
+		resultJSON, err := json.MarshalIndent(map[string]any{
+			"relationType": arg.RelationType,
+			"deleted":      deleted,
+		}, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(mergeRelationTypesTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			Canonical string   `json:"canonical"`
+			Aliases   []string `json:"aliases"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+
+		changed, err := manager.In(ctx).MergeRelationTypes(arg.Canonical, arg.Aliases)
+		if err != nil {
+			return nil, err
+		}
+
+		resultJSON, err := json.MarshalIndent(map[string]any{
+			"canonical": arg.Canonical,
+			"aliases":   arg.Aliases,
+			"changed":   changed,
+		}, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(readGraphTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			Mode             *string  `json:"mode"`
+			Limit            *int     `json:"limit"`
+			IncludeTypes     []string `json:"includeTypes"`
+			ExcludeTypes     []string `json:"excludeTypes"`
+			AsOf             string   `json:"asOf"`
+			RelationOrder    string   `json:"relationOrder"`
+			ObservationOrder string   `json:"observationOrder"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+
+		// Default mode is "summary"
+		mode := "summary"
+		if arg.Mode != nil && *arg.Mode == "full" {
+			mode = "full"
+		}
+
+		// Apply default and max limits (only relevant for summary mode)
+		limit := 50
+		if arg.Limit != nil {
+			limit = *arg.Limit
+			if limit > 200 {
+				limit = 200
+			}
+			if limit < 1 {
+				limit = 50
+			}
+		}
+
+		// Get graph data
+		filter := storage.TypeFilter{Include: arg.IncludeTypes, Exclude: arg.ExcludeTypes}
+		reader, err := manager.In(ctx).WithObservationOrder(arg.ObservationOrder)
+		if err != nil {
+			return nil, err
+		}
+		var result interface{}
+		if arg.AsOf != "" {
+			var asOf time.Time
+			if asOf, err = parseAsOf(arg.AsOf); err != nil {
+				return nil, err
+			}
+			result, err = reader.ReadGraphAsOf(asOf, mode, limit, filter)
+		} else {
+			result, err = reader.ReadGraph(mode, limit, filter)
+		}
+		if err != nil {
+			return nil, err
+		}
+		var relations []storage.Relation
+		if graph, ok := result.(*storage.KnowledgeGraph); ok {
+			relations = graph.Relations
+		}
+		if err := orderRelations(relations, arg.RelationOrder); err != nil {
+			return nil, err
+		}
+
+		// Convert result to JSON
+		resultJSON, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(searchNodesTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			Query                 string `json:"query"`
+			Limit                 *int   `json:"limit"`
+			MaxSnippets           int    `json:"maxSnippets"`
+			OnlyInternalRelations bool   `json:"onlyInternalRelations"`
+			AsOf                  string `json:"asOf"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+		if arg.Query == "" && (!emptyQueryListsAll || arg.AsOf != "") {
+			return nil, fmt.Errorf("%w: missing required parameter: query", storage.ErrInvalidArgument)
+		}
+		if arg.MaxSnippets < 0 {
+			return nil, fmt.Errorf("%w: maxSnippets must not be negative", storage.ErrInvalidArgument)
+		}
+
+		// If limit not specified, use 0 to indicate "all results"
+		// If specified, apply reasonable bounds
+		limit := 0
+		if arg.Limit != nil {
+			limit = *arg.Limit
+			if limit < 1 {
+				limit = 0 // treat invalid as "all"
+			}
+		}
+
+		// Search nodes
+		var results storage.SearchResult
+		var asOf time.Time
+		var err error
+		if arg.AsOf != "" {
+			if asOf, err = parseAsOf(arg.AsOf); err != nil {
+				return nil, err
+			}
+			results, err = manager.In(ctx).SearchNodesAsOf(asOf, arg.Query, limit)
+		} else {
+			results, err = manager.In(ctx).SearchNodesWithSnippets(arg.Query, limit, arg.MaxSnippets)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if arg.OnlyInternalRelations && len(results.Entities) > 0 {
+			names := make([]string, len(results.Entities))
+			for i, e := range results.Entities {
+				names[i] = e.Name
+			}
+			// Relations as of the same time as the hits, read without the
+			// access stats open_nodes records
+			relations, err := manager.In(ctx).RelationsAmong(names, asOf)
+			if err != nil {
+				return nil, err
+			}
+			results = onlyInternalRelatedHits(results, relations)
+		}
+
+		// Convert result to JSON
+		resultJSON, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(openNodesTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			Names                 []string `json:"names"`
+			OnlyInternalRelations bool     `json:"onlyInternalRelations"`
+			IncludeNeighborTypes  bool     `json:"includeNeighborTypes"`
+			RelationOrder         string   `json:"relationOrder"`
+			ObservationOrder      string   `json:"observationOrder"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+		if len(arg.Names) == 0 {
+			return nil, fmt.Errorf("%w: missing required parameter: names", storage.ErrInvalidArgument)
+		}
+
+		// Open nodes
+		reader, err := manager.In(ctx).WithObservationOrder(arg.ObservationOrder)
+		if err != nil {
+			return nil, err
+		}
+		results, err := reader.OpenNodes(arg.Names)
+		if err != nil {
+			return nil, err
+		}
+		if arg.OnlyInternalRelations {
+			results = onlyInternalRelations(results)
+		}
+		if arg.IncludeNeighborTypes {
+			if results, err = manager.In(ctx).withNeighborTypes(results); err != nil {
+				return nil, err
+			}
+		}
+		if err := orderRelations(results.Relations, arg.RelationOrder); err != nil {
+			return nil, err
+		}
+
+		// Convert result to JSON
+		resultJSON, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(listEntitiesTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			EntityType          string `json:"entityType"`
+			Sort                string `json:"sort"`
+			Order               string `json:"order"`
+			Limit               *int   `json:"limit"`
+			Offset              int    `json:"offset"`
+			IncludeObservations bool   `json:"includeObservations"`
+			NamesOnly           bool   `json:"namesOnly"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+		if arg.NamesOnly && (arg.EntityType != "" || arg.Sort != "" || arg.Order != "" || arg.IncludeObservations) {
+			return nil, fmt.Errorf("%w: namesOnly cannot be combined with entityType, sort, order, or includeObservations", storage.ErrInvalidArgument)
+		}
+
+		// Apply default and max limits; names are small enough for bigger pages
+		maxLimit := 200
+		if arg.NamesOnly {
+			maxLimit = 1000
+		}
+		limit := 50
+		if arg.Limit != nil {
+			limit = *arg.Limit
+			if limit > maxLimit {
+				limit = maxLimit
+			}
+			if limit < 1 {
+				limit = 50
+			}
+		}
+		if arg.Offset < 0 {
+			arg.Offset = 0
+		}
+
+		var result any
+		var err error
+		if arg.NamesOnly {
+			result, err = manager.In(ctx).ListEntityNames(limit, arg.Offset)
+		} else {
+			result, err = manager.In(ctx).ListEntities(storage.ListOptions{
+				EntityType:          arg.EntityType,
+				Sort:                arg.Sort,
+				Order:               arg.Order,
+				Limit:               limit,
+				Offset:              arg.Offset,
+				IncludeObservations: arg.IncludeObservations,
+			})
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		resultJSON, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(findByObservationTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			Content string `json:"content"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+		if arg.Content == "" {
+			return nil, fmt.Errorf("%w: missing required parameter: content", storage.ErrInvalidArgument)
+		}
+
+		entities, err := manager.In(ctx).FindByObservation(arg.Content)
+		if err != nil {
+			return nil, err
+		}
+
+		resultJSON, err := json.MarshalIndent(entities, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(listRelationsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			Limit  *int `json:"limit"`
+			Offset int  `json:"offset"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+
+		limit := 100
+		if arg.Limit != nil {
+			limit = *arg.Limit
+			if limit > 1000 {
+				limit = 1000
+			}
+			if limit < 1 {
+				limit = 100
+			}
+		}
+		if arg.Offset < 0 {
+			arg.Offset = 0
+		}
+
+		page, err := manager.In(ctx).ListRelations(limit, arg.Offset)
+		if err != nil {
+			return nil, err
+		}
+
+		resultJSON, err := json.MarshalIndent(page, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(recentEntitiesTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			Limit int `json:"limit"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+
+		entities, err := manager.In(ctx).RecentlyModified(min(arg.Limit, 200))
+		if err != nil {
+			return nil, err
+		}
+
+		resultJSON, err := json.MarshalIndent(entities, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(traverseTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			From         string `json:"from"`
+			RelationType string `json:"relationType"`
+			Direction    string `json:"direction"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+		if arg.From == "" {
+			return nil, fmt.Errorf("%w: missing required parameter: from", storage.ErrInvalidArgument)
+		}
+
+		hits, err := manager.In(ctx).Traverse(arg.From, arg.RelationType, arg.Direction)
+		if err != nil {
+			return nil, err
+		}
+
+		resultJSON, err := json.MarshalIndent(hits, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(reachableTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			Start        string `json:"start"`
+			RelationType string `json:"relationType"`
+			MaxHops      int    `json:"maxHops"`
+			Direction    string `json:"direction"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+		if arg.Start == "" {
+			return nil, fmt.Errorf("%w: missing required parameter: start", storage.ErrInvalidArgument)
+		}
+
+		found, err := manager.In(ctx).Reachable(arg.Start, arg.RelationType, arg.MaxHops, arg.Direction)
+		if err != nil {
+			return nil, err
+		}
+
+		resultJSON, err := json.MarshalIndent(found, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(closureTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			Start        string `json:"start"`
+			RelationType string `json:"relationType"`
+			Direction    string `json:"direction"`
+			MaxNodes     int    `json:"maxNodes"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+		if arg.Start == "" {
+			return nil, fmt.Errorf("%w: missing required parameter: start", storage.ErrInvalidArgument)
+		}
+
+		closure, err := manager.In(ctx).Closure(arg.Start, arg.RelationType, arg.Direction, arg.MaxNodes)
+		if err != nil {
+			return nil, err
+		}
+
+		resultJSON, err := json.MarshalIndent(closure, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(findAllPathsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			From     string `json:"from"`
+			To       string `json:"to"`
+			MaxDepth int    `json:"maxDepth"`
+			MaxPaths int    `json:"maxPaths"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+		if arg.From == "" || arg.To == "" {
+			return nil, fmt.Errorf("%w: missing required parameters: from and to", storage.ErrInvalidArgument)
+		}
+
+		paths, err := manager.In(ctx).FindAllPaths(arg.From, arg.To, arg.MaxDepth, arg.MaxPaths)
+		if err != nil {
+			return nil, err
+		}
+
+		resultJSON, err := json.MarshalIndent(paths, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(exportEntityContextTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			Name   string `json:"name"`
+			Depth  *int   `json:"depth"`
+			Format string `json:"format"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+		if arg.Name == "" {
+			return nil, fmt.Errorf("%w: missing required parameter: name", storage.ErrInvalidArgument)
+		}
+		depth := 1
+		if arg.Depth != nil {
+			depth = *arg.Depth
+		}
+		if depth < 1 {
+			return nil, fmt.Errorf("%w: depth must be between 1 and %d", storage.ErrInvalidArgument, storage.MaxReachableHops)
+		}
+
+		dir := exportDir
+		if dir == "" {
+			dir = os.TempDir()
+		}
+		export, err := manager.In(ctx).ExportEntityContext(arg.Name, depth, dir, arg.Format)
+		if err != nil {
+			return nil, err
+		}
+
+		resultJSON, err := json.MarshalIndent(export, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(adjacencyListTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			Query        string   `json:"query"`
+			IncludeTypes []string `json:"includeTypes"`
+			ExcludeTypes []string `json:"excludeTypes"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
 
-	// Add update_observations tool
-	updateObservationsTool := mcp.NewTool("update_observations",
-		mcp.WithDescription(`Replace an existing observation with updated content. Use this to correct outdated or inaccurate facts.
+		filter := storage.TypeFilter{Include: arg.IncludeTypes, Exclude: arg.ExcludeTypes}
+		adjacency, err := manager.In(ctx).AdjacencyList(arg.Query, filter)
+		if err != nil {
+			return nil, err
+		}
+		relations := 0
+		for _, edges := range adjacency {
+			relations += len(edges)
+		}
 
-USE WHEN: An observation needs correction (e.g. "Uses React 17" → "Uses React 18").
+		resultJSON, err := json.MarshalIndent(map[string]any{
+			"adjacency": adjacency,
+			"entities":  len(adjacency),
+			"relations": relations,
+		}, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
 
-REQUIRES: The exact old observation text. Use open_nodes first to get the current text.`),
-		mcp.WithTitleAnnotation("Update Observation"),
-		mcp.WithDestructiveHintAnnotation(true),
-		mcp.WithString("entityName",
-			mcp.Required(),
-			mcp.Description("Exact name of the entity containing the observation"),
-		),
-		mcp.WithString("oldContent",
-			mcp.Required(),
-			mcp.Description("Exact current observation text to replace"),
-		),
-		mcp.WithString("newContent",
-			mcp.Required(),
-			mcp.Description("New observation text"),
-		),
-	)
+	addTool(similarEntitiesTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			Name  string `json:"name"`
+			By    string `json:"by"`
+			Limit int    `json:"limit"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+
+		similar, err := manager.In(ctx).SimilarEntities(arg.Name, arg.By, arg.Limit)
+		if err != nil {
+			return nil, err
+		}
+
+		resultJSON, err := json.MarshalIndent(map[string]any{
+			"entity":  arg.Name,
+			"similar": similar,
+			"count":   len(similar),
+		}, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(topicSummaryTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			Limit          int      `json:"limit"`
+			Bigrams        *bool    `json:"bigrams"`
+			ExtraStopwords []string `json:"extraStopwords"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+
+		summary, err := manager.In(ctx).TopicSummary(storage.TopicOptions{
+			Limit:     arg.Limit,
+			Stopwords: append(slices.Clone(stopwords), arg.ExtraStopwords...),
+			Bigrams:   arg.Bigrams == nil || *arg.Bigrams,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		resultJSON, err := json.MarshalIndent(summary, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(observationsInRangeTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			Start string `json:"start"`
+			End   string `json:"end"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+
+		start, err := parseTimeArg("start", arg.Start, false)
+		if err != nil {
+			return nil, err
+		}
+		var end time.Time
+		if arg.End != "" {
+			if end, err = parseTimeArg("end", arg.End, true); err != nil {
+				return nil, err
+			}
+		}
+		observations, err := manager.In(ctx).ObservationsInRange(start, end)
+		if err != nil {
+			return nil, err
+		}
+
+		resultJSON, err := json.MarshalIndent(map[string]any{
+			"observations": observations,
+			"count":        len(observations),
+		}, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(entitiesModifiedSinceTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			Since string `json:"since"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+
+		since, err := parseTimeArg("since", arg.Since, false)
+		if err != nil {
+			return nil, err
+		}
+		entities, err := manager.In(ctx).EntitiesModifiedSince(since)
+		if err != nil {
+			return nil, err
+		}
+
+		resultJSON, err := json.MarshalIndent(map[string]any{
+			"entities": entities,
+			"count":    len(entities),
+		}, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(relationExistsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			From         string `json:"from"`
+			To           string `json:"to"`
+			RelationType string `json:"relationType"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+		if arg.From == "" || arg.To == "" || arg.RelationType == "" {
+			return nil, fmt.Errorf("%w: missing required parameters: from, to, and relationType", storage.ErrInvalidArgument)
+		}
+
+		exists, err := manager.In(ctx).RelationExists(arg.From, arg.To, arg.RelationType)
+		if err != nil {
+			return nil, err
+		}
+
+		resultJSON, err := json.MarshalIndent(map[string]bool{"exists": exists}, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(relationTypeSamplesTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			Limit int `json:"limit"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+
+		samples, err := manager.In(ctx).RelationTypeSamples(arg.Limit)
+		if err != nil {
+			return nil, err
+		}
+		result, err := manager.In(ctx).ReadGraph("summary", 1, storage.TypeFilter{})
+		if err != nil {
+			return nil, err
+		}
+		summary, ok := result.(*storage.GraphSummary)
+		if !ok {
+			return nil, fmt.Errorf("unexpected result type from ReadGraph")
+		}
+
+		type typeSamples struct {
+			Count    int                `json:"count"`
+			Examples []storage.Relation `json:"examples"`
+		}
+		types := make(map[string]typeSamples, len(samples))
+		for relationType, examples := range samples {
+			types[relationType] = typeSamples{Count: summary.RelationTypes[relationType], Examples: examples}
+		}
+
+		resultJSON, err := json.MarshalIndent(map[string]any{"relationTypes": types}, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(findEmptyEntitiesTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		entities, err := manager.In(ctx).EmptyEntities()
+		if err != nil {
+			return nil, err
+		}
+
+		resultJSON, err := json.MarshalIndent(struct {
+			Entities []storage.EntitySummary `json:"entities"`
+			Count    int                     `json:"count"`
+		}{entities, len(entities)}, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(findSelfRelationsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			Delete bool `json:"delete"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+
+		relations, err := manager.In(ctx).SelfRelations()
+		if err != nil {
+			return nil, err
+		}
+		deleted := 0
+		if arg.Delete && len(relations) > 0 {
+			if deleted, err = manager.In(ctx).DeleteRelations(relations); err != nil {
+				return nil, err
+			}
+		}
+
+		resultJSON, err := json.MarshalIndent(struct {
+			Relations []storage.Relation `json:"relations"`
+			Deleted   int                `json:"deleted"`
+		}{relations, deleted}, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(topConnectedPairsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			Limit *int `json:"limit"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+
+		limit := 10
+		if arg.Limit != nil {
+			limit = *arg.Limit
+			if limit > 100 {
+				limit = 100
+			}
+			if limit < 1 {
+				limit = 10
+			}
+		}
+
+		pairs, err := manager.In(ctx).TopConnectedPairs(limit)
+		if err != nil {
+			return nil, err
+		}
+
+		resultJSON, err := json.MarshalIndent(pairs, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(filterByObservationCountTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			MinCount int    `json:"minCount"`
+			MaxCount *int   `json:"maxCount"`
+			Limit    *int   `json:"limit"`
+			Order    string `json:"order"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+		if arg.Order != "" && arg.Order != "asc" && arg.Order != "desc" {
+			return nil, fmt.Errorf("%w: order must be asc or desc", storage.ErrInvalidArgument)
+		}
+		maxCount := -1
+		if arg.MaxCount != nil {
+			if maxCount = *arg.MaxCount; maxCount < 0 {
+				return nil, fmt.Errorf("%w: maxCount must not be negative", storage.ErrInvalidArgument)
+			}
+		}
+
+		limit := 50
+		if arg.Limit != nil {
+			limit = *arg.Limit
+			if limit > 1000 {
+				limit = 1000
+			}
+			if limit < 1 {
+				limit = 50
+			}
+		}
+
+		counts, err := manager.In(ctx).EntitiesByObservationCount(arg.MinCount, maxCount, limit, arg.Order == "asc")
+		if err != nil {
+			return nil, err
+		}
+
+		resultJSON, err := json.MarshalIndent(counts, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(getObservationsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			EntityName string `json:"entityName"`
+			Offset     int    `json:"offset"`
+			Limit      *int   `json:"limit"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+		if arg.EntityName == "" {
+			return nil, fmt.Errorf("%w: missing required parameter: entityName", storage.ErrInvalidArgument)
+		}
+
+		// Apply default and max limits
+		limit := 50
+		if arg.Limit != nil {
+			limit = *arg.Limit
+			if limit > 500 {
+				limit = 500
+			}
+			if limit < 1 {
+				limit = 50
+			}
+		}
+		if arg.Offset < 0 {
+			arg.Offset = 0
+		}
+
+		page, err := manager.In(ctx).GetObservations(arg.EntityName, arg.Offset, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		resultJSON, err := json.MarshalIndent(page, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(mergeEntitiesTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			SourceName string `json:"sourceName"`
+			TargetName string `json:"targetName"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+		if arg.SourceName == "" || arg.TargetName == "" {
+			return nil, fmt.Errorf("%w: missing required parameters: sourceName and targetName", storage.ErrInvalidArgument)
+		}
+
+		result, err := manager.In(ctx).MergeEntities(arg.SourceName, arg.TargetName)
+		if err != nil {
+			return nil, err
+		}
 
-	// Add detect_conflicts tool
-	detectConflictsTool := mcp.NewTool("detect_conflicts",
-		mcp.WithDescription(`Detect potential duplicate or contradictory observations within entities.
+		resultJSON, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
 
-Analyzes observation pairs for:
-- Potential duplicates: observations with high prefix overlap (>60% of words match at start)
-- Potential contradictions: observations containing antonym keyword pairs (e.g. "likes X" vs "dislikes X")
+	transferHandler := func(move bool) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			var arg struct {
+				Names            []string `json:"names"`
+				FromNamespace    string   `json:"fromNamespace"`
+				ToNamespace      string   `json:"toNamespace"`
+				Mode             string   `json:"mode"`
+				IncludeRelations *bool    `json:"includeRelations"`
+			}
+			if err := request.BindArguments(&arg); err != nil {
+				return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+			}
+			if len(arg.Names) == 0 || arg.ToNamespace == "" {
+				return nil, fmt.Errorf("%w: missing required parameters: names and toNamespace", storage.ErrInvalidArgument)
+			}
 
-USE WHEN: Reviewing memory quality, or after bulk imports to find inconsistencies.
+			result, err := manager.In(ctx).TransferEntities(arg.Names, arg.FromNamespace, arg.ToNamespace, storage.TransferOptions{
+				Mode:      arg.Mode,
+				Relations: arg.IncludeRelations == nil || *arg.IncludeRelations,
+				Move:      move,
+			})
+			if err != nil {
+				return nil, err
+			}
 
-RETURNS: List of conflicts with entity name, both observations, and conflict type.`),
-		mcp.WithTitleAnnotation("Detect Conflicts"),
-		mcp.WithReadOnlyHintAnnotation(true),
-		mcp.WithString("entityName",
-			mcp.Description("Optional: check only this entity. Omit to check all entities."),
-		),
-	)
+			resultJSON, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			return mcp.NewToolResultText(string(resultJSON)), nil
+		}
+	}
+	addTool(copyEntitiesTool, transferHandler(false))
+	addTool(moveEntitiesTool, transferHandler(true))
 
-	// Add handlers
-	s.AddTool(createEntitiesTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		// Bind arguments using new mcp-go helpers
+	addTool(updateEntitiesTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		var arg struct {
-			Entities []storage.Entity `json:"entities"`
+			Name       string `json:"name"`
+			EntityType string `json:"entityType"`
 		}
 		if err := request.BindArguments(&arg); err != nil {
-			return nil, fmt.Errorf("invalid arguments: %w", err)
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
 		}
-		if len(arg.Entities) == 0 {
-			return nil, errors.New("missing required parameter: entities")
+		if arg.Name == "" || arg.EntityType == "" {
+			return nil, fmt.Errorf("%w: missing required parameters: name and entityType", storage.ErrInvalidArgument)
 		}
 
-		// Create entities
-		newEntities, err := manager.CreateEntities(arg.Entities)
-		if err != nil {
+		if err := manager.In(ctx).UpdateEntityType(arg.Name, arg.EntityType); err != nil {
 			return nil, err
 		}
+		return mcp.NewToolResultText(fmt.Sprintf("Entity %q type updated to %q", arg.Name, arg.EntityType)), nil
+	})
 
-		// Convert result to JSON
-		resultJSON, err := json.MarshalIndent(newEntities, "", "  ")
+	addTool(setEntityTypeTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			Name       string `json:"name"`
+			EntityType string `json:"entityType"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
+		if arg.Name == "" || arg.EntityType == "" {
+			return nil, fmt.Errorf("%w: missing required parameters: name and entityType", storage.ErrInvalidArgument)
+		}
+
+		entity, err := manager.In(ctx).SetEntityType(arg.Name, arg.EntityType)
 		if err != nil {
 			return nil, err
 		}
 
+		resultJSON, err := json.MarshalIndent(entity, "", "  ")
+		if err != nil {
+			return nil, err
+		}
 		return mcp.NewToolResultText(string(resultJSON)), nil
 	})
 
-	s.AddTool(createRelationsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	addTool(setTypeDescriptionTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		var arg struct {
-			Relations []storage.Relation `json:"relations"`
+			Kind        string `json:"kind"`
+			Type        string `json:"type"`
+			Description string `json:"description"`
 		}
 		if err := request.BindArguments(&arg); err != nil {
-			return nil, fmt.Errorf("invalid arguments: %w", err)
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
 		}
-		if len(arg.Relations) == 0 {
-			return nil, errors.New("missing required parameter: relations")
+
+		if err := manager.In(ctx).SetTypeDescription(arg.Kind, arg.Type, arg.Description); err != nil {
+			return nil, err
+		}
+		descriptions, err := manager.In(ctx).TypeDescriptions(arg.Kind)
+		if err != nil {
+			return nil, err
 		}
 
-		// Create relations
-		newRelations, err := manager.CreateRelations(arg.Relations)
+		resultJSON, err := json.MarshalIndent(descriptions, "", "  ")
 		if err != nil {
 			return nil, err
 		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(getTypeDescriptionsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var arg struct {
+			Kind string `json:"kind"`
+		}
+		if err := request.BindArguments(&arg); err != nil {
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
+		}
 
-		// Convert result to JSON
-		resultJSON, err := json.MarshalIndent(newRelations, "", "  ")
+		descriptions, err := manager.In(ctx).TypeDescriptions(arg.Kind)
 		if err != nil {
 			return nil, err
 		}
 
+		resultJSON, err := json.MarshalIndent(descriptions, "", "  ")
+		if err != nil {
+			return nil, err
+		}
 		return mcp.NewToolResultText(string(resultJSON)), nil
 	})
 
-	s.AddTool(addObservationsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	addTool(updateEntityTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		var arg struct {
-			Observations []ObservationAddition `json:"observations"`
+			OldName     string  `json:"oldName"`
+			NewName     string  `json:"newName"`
+			NewType     string  `json:"newType"`
+			Description *string `json:"description"`
 		}
 		if err := request.BindArguments(&arg); err != nil {
-			return nil, fmt.Errorf("invalid arguments: %w", err)
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
 		}
-		if len(arg.Observations) == 0 {
-			return nil, errors.New("missing required parameter: observations")
+		if arg.OldName == "" {
+			return nil, fmt.Errorf("%w: missing required parameter: oldName", storage.ErrInvalidArgument)
 		}
 
-		// Add observations
-		results, err := manager.AddObservations(arg.Observations)
+		entity, err := manager.In(ctx).UpdateEntity(arg.OldName, arg.NewName, arg.NewType, arg.Description)
 		if err != nil {
 			return nil, err
 		}
 
-		// Convert result to JSON
-		resultJSON, err := json.MarshalIndent(results, "", "  ")
+		resultJSON, err := json.MarshalIndent(entity, "", "  ")
 		if err != nil {
 			return nil, err
 		}
-
 		return mcp.NewToolResultText(string(resultJSON)), nil
 	})
 
-	s.AddTool(deleteEntitiesTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	addTool(updateObservationsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		var arg struct {
-			EntityNames []string `json:"entityNames"`
+			EntityName string `json:"entityName"`
+			OldContent string `json:"oldContent"`
+			NewContent string `json:"newContent"`
 		}
 		if err := request.BindArguments(&arg); err != nil {
-			return nil, fmt.Errorf("invalid arguments: %w", err)
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
 		}
-		if len(arg.EntityNames) == 0 {
-			return nil, errors.New("missing required parameter: entityNames")
+		if arg.EntityName == "" || arg.OldContent == "" || arg.NewContent == "" {
+			return nil, fmt.Errorf("%w: missing required parameters: entityName, oldContent, and newContent", storage.ErrInvalidArgument)
 		}
 
-		// Delete entities
-		if err := manager.DeleteEntities(arg.EntityNames); err != nil {
+		if err := manager.In(ctx).UpdateObservation(arg.EntityName, arg.OldContent, arg.NewContent); err != nil {
 			return nil, err
 		}
-
-		return mcp.NewToolResultText("Entities deleted successfully"), nil
+		return mcp.NewToolResultText("Observation updated successfully"), nil
 	})
 
-	s.AddTool(deleteObservationsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	addTool(setObservationsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		var arg struct {
-			Deletions []storage.ObservationDeletion `json:"deletions"`
+			EntityName   string    `json:"entityName"`
+			Observations *[]string `json:"observations"`
 		}
 		if err := request.BindArguments(&arg); err != nil {
-			return nil, fmt.Errorf("invalid arguments: %w", err)
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
 		}
-		if len(arg.Deletions) == 0 {
-			return nil, errors.New("missing required parameter: deletions")
+		if arg.EntityName == "" || arg.Observations == nil {
+			return nil, fmt.Errorf("%w: missing required parameters: entityName and observations", storage.ErrInvalidArgument)
 		}
 
-		// Delete observations
-		if err := manager.DeleteObservations(arg.Deletions); err != nil {
+		if err := manager.In(ctx).SetObservations(arg.EntityName, *arg.Observations); err != nil {
 			return nil, err
 		}
-
-		return mcp.NewToolResultText("Observations deleted successfully"), nil
+		return mcp.NewToolResultText(fmt.Sprintf("Observations of %q set (%d provided)", arg.EntityName, len(*arg.Observations))), nil
 	})
 
-	s.AddTool(deleteRelationsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	addTool(replaceInObservationsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		var arg struct {
-			Relations []storage.Relation `json:"relations"`
+			Old           string  `json:"old"`
+			New           *string `json:"new"`
+			CaseSensitive *bool   `json:"caseSensitive"`
 		}
 		if err := request.BindArguments(&arg); err != nil {
-			return nil, fmt.Errorf("invalid arguments: %w", err)
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
 		}
-		if len(arg.Relations) == 0 {
-			return nil, errors.New("missing required parameter: relations")
+		if arg.Old == "" || arg.New == nil {
+			return nil, fmt.Errorf("%w: missing required parameters: old and new", storage.ErrInvalidArgument)
 		}
+		caseSensitive := arg.CaseSensitive == nil || *arg.CaseSensitive
 
-		// Delete relations
-		if err := manager.DeleteRelations(arg.Relations); err != nil {
+		modified, err := manager.In(ctx).ReplaceInObservations(arg.Old, *arg.New, caseSensitive)
+		if err != nil {
 			return nil, err
 		}
 
-		return mcp.NewToolResultText("Relations deleted successfully"), nil
+		resultJSON, err := json.MarshalIndent(map[string]any{
+			"old":      arg.Old,
+			"new":      *arg.New,
+			"modified": modified,
+		}, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
 	})
 
-	s.AddTool(readGraphTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	addTool(detectConflictsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		var arg struct {
-			Mode  *string `json:"mode"`
-			Limit *int    `json:"limit"`
+			EntityName *string `json:"entityName"`
 		}
 		if err := request.BindArguments(&arg); err != nil {
-			return nil, fmt.Errorf("invalid arguments: %w", err)
-		}
-
-		// Default mode is "summary"
-		mode := "summary"
-		if arg.Mode != nil && *arg.Mode == "full" {
-			mode = "full"
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
 		}
 
-		// Apply default and max limits (only relevant for summary mode)
-		limit := 50
-		if arg.Limit != nil {
-			limit = *arg.Limit
-			if limit > 200 {
-				limit = 200
-			}
-			if limit < 1 {
-				limit = 50
-			}
+		entityName := ""
+		if arg.EntityName != nil {
+			entityName = *arg.EntityName
 		}
 
-		// Get graph data
-		result, err := manager.ReadGraph(mode, limit)
+		conflicts, err := manager.In(ctx).DetectConflicts(entityName)
 		if err != nil {
 			return nil, err
 		}
 
-		// Convert result to JSON
-		resultJSON, err := json.MarshalIndent(result, "", "  ")
+		if len(conflicts) == 0 {
+			return mcp.NewToolResultText("No conflicts detected"), nil
+		}
+
+		resultJSON, err := json.MarshalIndent(conflicts, "", "  ")
 		if err != nil {
 			return nil, err
 		}
-
 		return mcp.NewToolResultText(string(resultJSON)), nil
 	})
 
-	s.AddTool(searchNodesTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	addTool(validateFileTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		var arg struct {
-			Query string `json:"query"`
-			Limit *int   `json:"limit"`
+			Path string `json:"path"`
 		}
 		if err := request.BindArguments(&arg); err != nil {
-			return nil, fmt.Errorf("invalid arguments: %w", err)
-		}
-		if arg.Query == "" {
-			return nil, errors.New("missing required parameter: query")
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
 		}
 
-		// If limit not specified, use 0 to indicate "all results"
-		// If specified, apply reasonable bounds
-		limit := 0
-		if arg.Limit != nil {
-			limit = *arg.Limit
-			if limit < 1 {
-				limit = 0 // treat invalid as "all"
+		path := arg.Path
+		if path == "" {
+			info, err := manager.StorageInfo()
+			if err != nil {
+				return nil, err
+			}
+			if info.Backend != "jsonl" {
+				return nil, fmt.Errorf("%w: the server uses %s storage; pass the path of a JSONL file to check", storage.ErrInvalidArgument, info.Backend)
 			}
+			path = info.FilePath
 		}
 
-		// Search nodes
-		results, err := manager.SearchNodes(arg.Query, limit)
+		report, err := storage.ValidateJSONL(path)
 		if err != nil {
 			return nil, err
 		}
 
-		// Convert result to JSON
-		resultJSON, err := json.MarshalIndent(results, "", "  ")
+		resultJSON, err := json.MarshalIndent(report, "", "  ")
 		if err != nil {
 			return nil, err
 		}
-
 		return mcp.NewToolResultText(string(resultJSON)), nil
 	})
 
-	s.AddTool(openNodesTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		var arg struct {
-			Names []string `json:"names"`
-		}
-		if err := request.BindArguments(&arg); err != nil {
-			return nil, fmt.Errorf("invalid arguments: %w", err)
+	addTool(checkIntegrityTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		report, err := manager.CheckIntegrity()
+		if err != nil {
+			return nil, err
 		}
-		if len(arg.Names) == 0 {
-			return nil, errors.New("missing required parameter: names")
+
+		resultJSON, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return nil, err
 		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
 
-		// Open nodes
-		results, err := manager.OpenNodes(arg.Names)
+	addTool(storageInfoTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		info, err := manager.In(ctx).StorageInfo()
 		if err != nil {
 			return nil, err
 		}
 
-		// Convert result to JSON
-		resultJSON, err := json.MarshalIndent(results, "", "  ")
+		resultJSON, err := json.MarshalIndent(info, "", "  ")
 		if err != nil {
 			return nil, err
 		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
 
+	addTool(sessionChangesTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		resultJSON, err := json.MarshalIndent(manager.In(ctx).SessionChanges(), "", "  ")
+		if err != nil {
+			return nil, err
+		}
 		return mcp.NewToolResultText(string(resultJSON)), nil
 	})
 
-	s.AddTool(mergeEntitiesTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		var arg struct {
-			SourceName string `json:"sourceName"`
-			TargetName string `json:"targetName"`
+	addTool(resetSessionTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		manager.In(ctx).ResetSession()
+		since := manager.In(ctx).SessionChanges().Since
+		return mcp.NewToolResultText(fmt.Sprintf("Session reset; recording changes since %s", since.Format(time.RFC3339))), nil
+	})
+
+	addTool(analyzeGraphTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := manager.In(ctx).Analytics()
+		if err != nil {
+			return nil, err
 		}
-		if err := request.BindArguments(&arg); err != nil {
-			return nil, fmt.Errorf("invalid arguments: %w", err)
+
+		resultJSON, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return nil, err
 		}
-		if arg.SourceName == "" || arg.TargetName == "" {
-			return nil, errors.New("missing required parameters: sourceName and targetName")
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(refreshAnalyticsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := manager.In(ctx).RefreshAnalytics()
+		if err != nil {
+			return nil, err
+		}
+
+		resultJSON, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return nil, err
 		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
 
-		result, err := manager.MergeEntities(arg.SourceName, arg.TargetName)
+	addTool(checkpointTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := manager.Checkpoint()
 		if err != nil {
 			return nil, err
 		}
@@ -1327,71 +5416,158 @@ RETURNS: List of conflicts with entity name, both observations, and conflict typ
 		return mcp.NewToolResultText(string(resultJSON)), nil
 	})
 
-	s.AddTool(updateEntitiesTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	addTool(listNamespacesTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		namespaces, err := manager.Namespaces()
+		if err != nil {
+			return nil, err
+		}
+
+		resultJSON, err := json.MarshalIndent(namespaces, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	addTool(importGraphTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		var arg struct {
-			Name       string `json:"name"`
-			EntityType string `json:"entityType"`
+			Entities     []storage.Entity   `json:"entities"`
+			Relations    []storage.Relation `json:"relations"`
+			ConflictMode string             `json:"conflictMode"`
+			Format       string             `json:"format"`
+			Data         string             `json:"data"`
 		}
 		if err := request.BindArguments(&arg); err != nil {
-			return nil, fmt.Errorf("invalid arguments: %w", err)
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
 		}
-		if arg.Name == "" || arg.EntityType == "" {
-			return nil, errors.New("missing required parameters: name and entityType")
+
+		graph := &storage.KnowledgeGraph{Entities: arg.Entities, Relations: arg.Relations}
+		var skipped []storage.LoadWarning
+		switch arg.Format {
+		case "", "graph":
+			if arg.Data != "" {
+				return nil, fmt.Errorf("%w: data requires format %q", storage.ErrInvalidArgument, storage.ImportFormatMCPMemory)
+			}
+			if len(arg.Entities) == 0 && len(arg.Relations) == 0 {
+				return nil, fmt.Errorf("%w: missing required parameter: entities or relations", storage.ErrInvalidArgument)
+			}
+		case storage.ImportFormatMCPMemory:
+			if arg.Data == "" {
+				return nil, fmt.Errorf("%w: missing required parameter: data", storage.ErrInvalidArgument)
+			}
+			if len(arg.Entities) > 0 || len(arg.Relations) > 0 {
+				return nil, fmt.Errorf("%w: pass either data or entities and relations, not both", storage.ErrInvalidArgument)
+			}
+			var err error
+			if graph, skipped, err = storage.ParseMCPMemory([]byte(arg.Data)); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("%w: unknown format %q (use graph or %s)", storage.ErrInvalidArgument, arg.Format, storage.ImportFormatMCPMemory)
 		}
 
-		if err := manager.UpdateEntityType(arg.Name, arg.EntityType); err != nil {
+		report, err := manager.In(ctx).ImportData(graph, arg.ConflictMode)
+		if err != nil {
 			return nil, err
 		}
-		return mcp.NewToolResultText(fmt.Sprintf("Entity %q type updated to %q", arg.Name, arg.EntityType)), nil
+
+		resultJSON, err := json.MarshalIndent(struct {
+			*storage.ImportReport
+			Skipped []storage.LoadWarning `json:"skipped,omitempty"`
+		}{report, skipped}, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
 	})
 
-	s.AddTool(updateObservationsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	remoteClient := remoteImportClient()
+	addTool(importURLTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if !allowRemoteImport {
+			return nil, fmt.Errorf("%w: import_url requires starting the server with --allow-remote-import", errToolDisabled)
+		}
 		var arg struct {
-			EntityName string `json:"entityName"`
-			OldContent string `json:"oldContent"`
-			NewContent string `json:"newContent"`
+			URL          string `json:"url"`
+			ConflictMode string `json:"conflictMode"`
 		}
 		if err := request.BindArguments(&arg); err != nil {
-			return nil, fmt.Errorf("invalid arguments: %w", err)
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
 		}
-		if arg.EntityName == "" || arg.OldContent == "" || arg.NewContent == "" {
-			return nil, errors.New("missing required parameters: entityName, oldContent, and newContent")
+		if arg.URL == "" {
+			return nil, fmt.Errorf("%w: missing required parameter: url", storage.ErrInvalidArgument)
 		}
 
-		if err := manager.UpdateObservation(arg.EntityName, arg.OldContent, arg.NewContent); err != nil {
+		graph, fetched, err := fetchGraph(ctx, remoteClient, arg.URL, remoteImportMaxBytes, remoteImportTimeout)
+		if err != nil {
 			return nil, err
 		}
-		return mcp.NewToolResultText("Observation updated successfully"), nil
+		report, err := manager.In(ctx).ImportData(graph, arg.ConflictMode)
+		if err != nil {
+			return nil, err
+		}
+		if report.Applied {
+			log.Printf("Imported %s: %d entities and %d relations created, %d entities updated",
+				fetched.URL, report.EntitiesCreated, report.RelationsCreated, report.EntitiesUpdated)
+		}
+
+		resultJSON, err := json.MarshalIndent(struct {
+			*RemoteImport
+			*storage.ImportReport
+		}{fetched, report}, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
 	})
 
-	s.AddTool(detectConflictsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	addTool(diffGraphTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		var arg struct {
-			EntityName *string `json:"entityName"`
+			Entities  []storage.Entity   `json:"entities"`
+			Relations []storage.Relation `json:"relations"`
 		}
 		if err := request.BindArguments(&arg); err != nil {
-			return nil, fmt.Errorf("invalid arguments: %w", err)
+			return nil, fmt.Errorf("%w: %w", storage.ErrInvalidArgument, err)
 		}
 
-		entityName := ""
-		if arg.EntityName != nil {
-			entityName = *arg.EntityName
+		diff, err := manager.In(ctx).DiffGraph(&storage.KnowledgeGraph{Entities: arg.Entities, Relations: arg.Relations})
+		if err != nil {
+			return nil, err
 		}
 
-		conflicts, err := manager.DetectConflicts(entityName)
+		resultJSON, err := json.MarshalIndent(diff, "", "  ")
 		if err != nil {
 			return nil, err
 		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
 
-		if len(conflicts) == 0 {
-			return mcp.NewToolResultText("No conflicts detected"), nil
+	addTool(clearGraphTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if !allowDestructive {
+			return nil, fmt.Errorf("%w: clear_graph requires starting the server with --allow-destructive", errToolDisabled)
 		}
 
-		resultJSON, err := json.MarshalIndent(conflicts, "", "  ")
+		backup, err := autoBackup()
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := manager.In(ctx).Clear()
+		if err != nil {
+			return nil, err
+		}
+		result.BackupPath = backup
+		log.Printf("Graph cleared: %d entities, %d observations, %d relations removed",
+			result.EntitiesRemoved, result.ObservationsRemoved, result.RelationsRemoved)
+
+		resultJSON, err := json.MarshalIndent(result, "", "  ")
 		if err != nil {
 			return nil, err
 		}
 		return mcp.NewToolResultText(string(resultJSON)), nil
 	})
+	if err := toolsAllowed.check(registeredTools); err != nil {
+		log.Fatalf("Invalid --tools: %v", err)
+	}
 
 	// Create OAuth server if enabled
 	var oauthSrv *auth.OAuthServer
@@ -1423,6 +5599,11 @@ RETURNS: List of conflicts with entity name, both observations, and conflict typ
 		})
 	}
 
+	// Shared request body size limit for SSE/HTTP transports
+	limitWrap := func(next http.Handler) http.Handler {
+		return limitRequestBody(next, maxRequestBytes)
+	}
+
 	// Shared CORS middleware for SSE/HTTP transports
 	corsWrap := func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -1477,19 +5658,26 @@ RETURNS: List of conflicts with entity name, both observations, and conflict typ
 		mux := http.NewServeMux()
 		customSrv := &http.Server{Handler: mux}
 		// Build SSE server using custom http.Server so Start() uses our mux
-		sseServer := server.NewSSEServer(
-			s,
+		sseOpts := []server.SSEOption{
 			server.WithBaseURL(fmt.Sprintf("http://localhost:%d", port)),
-			server.WithKeepAliveInterval(30*time.Second),
+			server.WithSSEEndpoint(ssePath),
+			server.WithMessageEndpoint(sseMessagePath),
 			server.WithHTTPServer(customSrv),
-		)
+		}
+		if keepAlive > 0 {
+			sseOpts = append(sseOpts, server.WithKeepAliveInterval(keepAlive))
+		}
+		sseServer := server.NewSSEServer(s, sseOpts...)
 		if oauthSrv != nil {
 			oauthSrv.RegisterRoutes(mux, corsWrap)
 		}
-		mux.Handle("/sse", corsWrap(authWrap(sseServer.SSEHandler())))
-		mux.Handle("/message", corsWrap(authWrap(sseServer.MessageHandler())))
+		mux.Handle(ssePath, corsWrap(authWrap(sseServer.SSEHandler())))
+		mux.Handle(sseMessagePath, corsWrap(authWrap(limitWrap(sseServer.MessageHandler()))))
+		if ndjsonPath != "" {
+			mux.Handle(ndjsonPath, corsWrap(authWrap(ndjsonHandler(manager))))
+		}
 
-		log.Printf("SSE listening on :%d\n", port)
+		log.Printf("SSE listening on http://localhost:%d%s (messages: %s)\n", port, ssePath, sseMessagePath)
 		// Start in background and handle graceful shutdown
 		errCh := make(chan error, 1)
 		go func() { errCh <- sseServer.Start(fmt.Sprintf(":%d", port)) }()
@@ -1530,7 +5718,10 @@ RETURNS: List of conflicts with entity name, both observations, and conflict typ
 		if oauthSrv != nil {
 			oauthSrv.RegisterRoutes(mux, corsWrap)
 		}
-		mux.Handle(httpEndpoint, corsWrap(authWrap(streamSrv)))
+		mux.Handle(httpEndpoint, corsWrap(authWrap(limitWrap(streamSrv))))
+		if ndjsonPath != "" {
+			mux.Handle(ndjsonPath, corsWrap(authWrap(ndjsonHandler(manager))))
+		}
 
 		log.Printf("Streamable HTTP listening on http://localhost:%d%s\n", port, httpEndpoint)
 
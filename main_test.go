@@ -1,10 +1,24 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
 
 	"memory-mcp-server-go/storage"
 )
@@ -37,7 +51,7 @@ func TestAddObservationsDuplicateEntity(t *testing.T) {
 	results, err := mgr.AddObservations([]ObservationAddition{
 		{EntityName: "TestEntity", Contents: []string{"obs-a", "obs-b"}},
 		{EntityName: "TestEntity", Contents: []string{"obs-c", "obs-d"}},
-	})
+	}, "")
 	if err != nil {
 		t.Fatalf("AddObservations failed: %v", err)
 	}
@@ -62,3 +76,876 @@ func TestAddObservationsDuplicateEntity(t *testing.T) {
 		}
 	}
 }
+
+func TestOnlyInternalRelations(t *testing.T) {
+	graph := storage.KnowledgeGraph{
+		Entities: []storage.Entity{{Name: "A"}, {Name: "B"}},
+		Relations: []storage.Relation{
+			{From: "A", To: "B", RelationType: "knows"},
+			{From: "A", To: "C", RelationType: "knows"},
+			{From: "C", To: "B", RelationType: "knows"},
+		},
+	}
+
+	filtered := onlyInternalRelations(graph)
+	if len(filtered.Relations) != 1 || filtered.Relations[0].To != "B" || filtered.Relations[0].From != "A" {
+		t.Errorf("Expected only A->B, got %+v", filtered.Relations)
+	}
+	if len(graph.Relations) != 3 {
+		t.Errorf("Original graph relations should be untouched, got %+v", graph.Relations)
+	}
+}
+
+func TestOnlyInternalRelatedHits(t *testing.T) {
+	result := storage.SearchResult{
+		Entities:        []storage.EntitySearchHit{{Name: "A", EntityType: "person"}, {Name: "B", EntityType: "company"}},
+		RelatedEntities: []storage.RelatedHit{{Name: "C", RelatedTo: "A", RelationType: "knows", Direction: "outgoing"}},
+	}
+	relations := []storage.Relation{
+		{From: "A", To: "B", RelationType: "works_at"},
+		{From: "A", To: "C", RelationType: "knows"},
+		{From: "C", To: "B", RelationType: "owns"},
+		{From: "A", To: "B", RelationType: "works_at"}, // listed again for B
+	}
+
+	filtered := onlyInternalRelatedHits(result, relations)
+	want := []storage.RelatedHit{{Name: "B", EntityType: "company", RelationType: "works_at", RelatedTo: "A", Direction: "outgoing"}}
+	if !slices.Equal(filtered.RelatedEntities, want) {
+		t.Errorf("Expected only A->B, got %+v", filtered.RelatedEntities)
+	}
+}
+
+func TestToolErrorMiddleware(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code string
+	}{
+		{"not found", fmt.Errorf("source %w", fmt.Errorf("%w: %q", storage.ErrEntityNotFound, "X")), "entity_not_found"},
+		{"invalid", fmt.Errorf("%w: missing required parameter: names", storage.ErrInvalidArgument), "invalid_argument"},
+		{"conflict", fmt.Errorf("%w: duplicate", storage.ErrConflict), "conflict"},
+		{"locked", fmt.Errorf("%w: database is locked", storage.ErrStorageLocked), "storage_locked"},
+		{"storage failure", errors.New("failed to begin transaction: disk I/O error"), "internal_error"},
+	}
+
+	for _, tt := range tests {
+		handler := toolErrorMiddleware(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return nil, tt.err
+		})
+		result, err := handler(context.Background(), mcp.CallToolRequest{})
+		if err != nil {
+			t.Fatalf("%s: expected error to be converted to a result, got %v", tt.name, err)
+		}
+		if !result.IsError {
+			t.Errorf("%s: expected IsError result", tt.name)
+		}
+		if got := result.Meta.AdditionalFields["errorCode"]; got != tt.code {
+			t.Errorf("%s: expected errorCode %q, got %v", tt.name, tt.code, got)
+		}
+		text := result.Content[0].(mcp.TextContent).Text
+		if !strings.HasPrefix(text, tt.code+": ") {
+			t.Errorf("%s: expected text prefixed with code, got %q", tt.name, text)
+		}
+	}
+}
+
+func TestTimingMiddleware(t *testing.T) {
+	handler := timingMiddleware(toolErrorMiddleware(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		time.Sleep(2 * time.Millisecond)
+		return nil, fmt.Errorf("%w: X", storage.ErrEntityNotFound)
+	}))
+	result, err := handler(context.Background(), mcp.CallToolRequest{})
+	if err != nil {
+		t.Fatalf("Expected a tool result, got %v", err)
+	}
+	if ms, ok := result.Meta.AdditionalFields["durationMs"].(float64); !ok || ms < 2 {
+		t.Errorf("Expected durationMs of at least 2, got %v", result.Meta.AdditionalFields["durationMs"])
+	}
+	if got := result.Meta.AdditionalFields["errorCode"]; got != "entity_not_found" {
+		t.Errorf("Expected errorCode to be kept alongside the timing, got %v", got)
+	}
+}
+
+func TestLimitRequestBody(t *testing.T) {
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	})
+	post := func(handler http.Handler, body string, chunked bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(body))
+		if chunked {
+			req.ContentLength = -1 // as for a chunked body, so only reading finds the size
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	limited := limitRequestBody(echo, 10)
+	if rec := post(limited, "0123456789", false); rec.Code != http.StatusOK || rec.Body.String() != "0123456789" {
+		t.Errorf("Expected a body at the limit passed through, got %d %q", rec.Code, rec.Body)
+	}
+	for _, chunked := range []bool{false, true} {
+		if rec := post(limited, "0123456789!", chunked); rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("chunked=%v: expected 413 over the limit, got %d", chunked, rec.Code)
+		}
+	}
+	if rec := post(limitRequestBody(echo, 0), strings.Repeat("x", 100), false); rec.Code != http.StatusOK || rec.Body.Len() != 100 {
+		t.Errorf("Expected no limit with 0, got %d with %d bytes", rec.Code, rec.Body.Len())
+	}
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	mgr, err := NewKnowledgeGraphManager(filepath.Join(t.TempDir(), "test.db"), "sqlite", false)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer mgr.Close()
+
+	runs := 0
+	idempotent := &idempotency{manager: mgr, ttl: time.Hour, tools: map[string]bool{"create_entities": true}}
+	handler := namespaceMiddleware(idempotent.middleware(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		runs++
+		return withWarnings(mcp.NewToolResultText(fmt.Sprintf("run %d", runs)), []string{"note"}), nil
+	}))
+	call := func(tool string, args map[string]any) *mcp.CallToolResult {
+		var request mcp.CallToolRequest
+		request.Params.Name = tool
+		request.Params.Arguments = args
+		result, err := handler(context.Background(), request)
+		if err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+		return result
+	}
+	text := func(result *mcp.CallToolResult) string {
+		return result.Content[0].(mcp.TextContent).Text
+	}
+
+	first := call("create_entities", map[string]any{"idempotencyKey": "k1"})
+	retry := call("create_entities", map[string]any{"idempotencyKey": "k1"})
+	if runs != 1 || text(retry) != "run 1" || len(retry.Content) != 2 || retry.Meta.AdditionalFields["idempotentReplay"] != true {
+		t.Errorf("Expected the retry to replay %q with both blocks, got %d runs and %+v", text(first), runs, retry)
+	}
+
+	call("create_entities", map[string]any{"idempotencyKey": "k1", "namespace": "other"})
+	call("create_entities", map[string]any{"idempotencyKey": "k2"})
+	call("create_entities", map[string]any{})
+	call("read_graph", map[string]any{"idempotencyKey": "k1"})
+	if runs != 5 {
+		t.Errorf("Expected other namespaces, other keys, no key, and other tools to run, got %d runs", runs)
+	}
+
+	idempotent.ttl = time.Millisecond
+	time.Sleep(5 * time.Millisecond)
+	if result := call("create_entities", map[string]any{"idempotencyKey": "k1"}); text(result) != "run 6" {
+		t.Errorf("Expected an expired key to run again, got %q", text(result))
+	}
+}
+
+func TestSeedOnlyWhenEmpty(t *testing.T) {
+	tempDir := t.TempDir()
+	seedPath := filepath.Join(tempDir, "seed.jsonl")
+	seed := `{"type":"entity","name":"Seeded","entityType":"test","observations":["from seed"]}` + "\n"
+	if err := os.WriteFile(seedPath, []byte(seed), 0644); err != nil {
+		t.Fatalf("Failed to write seed file: %v", err)
+	}
+
+	mgr, err := NewKnowledgeGraphManager(filepath.Join(tempDir, "test.db"), "sqlite", false)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer mgr.Close()
+
+	if err := mgr.Seed(seedPath, false); err != nil {
+		t.Fatalf("Seed failed: %v", err)
+	}
+	graph, _ := mgr.OpenNodes([]string{"Seeded"})
+	if len(graph.Entities) != 1 {
+		t.Fatalf("Expected seeded entity, got %+v", graph.Entities)
+	}
+
+	// A non-empty store is not re-seeded unless forced
+	if err := mgr.SetObservations("Seeded", []string{"edited"}); err != nil {
+		t.Fatalf("SetObservations failed: %v", err)
+	}
+	if err := mgr.Seed(seedPath, false); err != nil {
+		t.Fatalf("Seed failed: %v", err)
+	}
+	page, _ := mgr.GetObservations("Seeded", 0, 0)
+	if len(page.Observations) != 1 || page.Observations[0] != "edited" {
+		t.Errorf("Expected seed to be skipped on non-empty store, got %v", page.Observations)
+	}
+
+	if err := mgr.Seed(seedPath, true); err != nil {
+		t.Fatalf("Forced seed failed: %v", err)
+	}
+	page, _ = mgr.GetObservations("Seeded", 0, 0)
+	if len(page.Observations) != 2 {
+		t.Errorf("Expected forced seed to merge observations, got %v", page.Observations)
+	}
+}
+
+func TestParseAsOf(t *testing.T) {
+	ts, err := parseAsOf("2024-02-01T12:30:00Z")
+	if err != nil || !ts.Equal(time.Date(2024, 2, 1, 12, 30, 0, 0, time.UTC)) {
+		t.Errorf("Expected RFC 3339 timestamp, got %v (%v)", ts, err)
+	}
+
+	// A bare date covers the whole day
+	day, err := parseAsOf("2024-02-01")
+	if err != nil || !day.Equal(time.Date(2024, 2, 1, 23, 59, 59, 0, time.UTC)) {
+		t.Errorf("Expected end of day, got %v (%v)", day, err)
+	}
+
+	if _, err := parseAsOf("last tuesday"); !errors.Is(err, storage.ErrInvalidArgument) {
+		t.Errorf("Expected ErrInvalidArgument, got %v", err)
+	}
+}
+
+func TestParseFileMode(t *testing.T) {
+	if mode, err := parseFileMode("0600"); err != nil || mode != 0600 {
+		t.Errorf("Expected 0600, got %v (%v)", mode, err)
+	}
+	if mode, err := parseFileMode(""); err != nil || mode != 0 {
+		t.Errorf("Expected 0 for the default, got %v (%v)", mode, err)
+	}
+	for _, bad := range []string{"0", "0800", "1777", "rw-------"} {
+		if _, err := parseFileMode(bad); err == nil {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestParseKeepAlive(t *testing.T) {
+	for s, want := range map[string]time.Duration{"30s": 30 * time.Second, "1m": time.Minute, "0": 0} {
+		if d, err := parseKeepAlive(s); err != nil || d != want {
+			t.Errorf("%q: expected %v, got %v (%v)", s, want, d, err)
+		}
+	}
+	for _, bad := range []string{"", "30", "soon", "-5s"} {
+		if _, err := parseKeepAlive(bad); err == nil {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestObservationLimits(t *testing.T) {
+	limits := observationLimits{soft: 2, hard: 4}
+
+	warnings, err := limits.check(map[string]int{"A": 2, "B": 1})
+	if err != nil || len(warnings) != 0 {
+		t.Errorf("Expected no warnings at the soft limit, got %v (%v)", warnings, err)
+	}
+
+	warnings, err = limits.check(map[string]int{"A": 3, "B": 4})
+	if err != nil || len(warnings) != 2 || !strings.Contains(warnings[0], `"A"`) {
+		t.Errorf("Expected a warning per entity over the soft limit, got %v (%v)", warnings, err)
+	}
+
+	_, err = limits.check(map[string]int{"A": 1, "B": 5})
+	if !errors.Is(err, storage.ErrInvalidArgument) || !strings.Contains(err.Error(), "add_observations") {
+		t.Errorf("Expected ErrInvalidArgument with chunking guidance, got %v", err)
+	}
+
+	if warnings, err := (observationLimits{}).check(map[string]int{"A": 1 << 20}); err != nil || len(warnings) != 0 {
+		t.Errorf("Expected zero limits to disable the guard, got %v (%v)", warnings, err)
+	}
+
+	result := withWarnings(mcp.NewToolResultText("[]"), []string{"warning: big"})
+	if len(result.Content) != 2 {
+		t.Errorf("Expected the warning as a second content block, got %d blocks", len(result.Content))
+	}
+}
+
+func TestAutoMigrateOff(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "memory.json")
+	if err := os.WriteFile(jsonPath, []byte(`{"type":"entity","name":"A","entityType":"test","observations":[]}`+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write JSON file: %v", err)
+	}
+
+	// Even with a sibling .db, auto-migrate off keeps the JSON file as the store
+	if err := os.WriteFile(filepath.Join(dir, "memory.db"), nil, 0644); err != nil {
+		t.Fatalf("Failed to write db file: %v", err)
+	}
+	if storageType, path := detectStorageType(jsonPath, false); storageType != "jsonl" || path != jsonPath {
+		t.Errorf("Expected jsonl at %s, got %s at %s", jsonPath, storageType, path)
+	}
+	if storageType, _ := detectStorageType(filepath.Join(dir, "memory.db"), false); storageType != "sqlite" {
+		t.Errorf("Expected a .db path to stay sqlite, got %s", storageType)
+	}
+
+	t.Setenv("MCP_AUTO_MIGRATE", "false")
+	if on, err := resolveAutoMigrate(true, false); err != nil || on {
+		t.Errorf("Expected env to turn auto-migrate off, got %v (%v)", on, err)
+	}
+	if on, err := resolveAutoMigrate(true, true); err != nil || !on {
+		t.Errorf("Expected explicit flag to win over env, got %v (%v)", on, err)
+	}
+
+	t.Setenv("MCP_AUTO_MIGRATE", "sometimes")
+	if _, err := resolveAutoMigrate(true, false); err == nil {
+		t.Error("Expected an error for an invalid MCP_AUTO_MIGRATE value")
+	}
+}
+
+func TestEncryptionKeyOptions(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key")
+	if err := os.WriteFile(keyFile, []byte("passphrase\n"), 0644); err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+	t.Setenv("MEMORY_MCP_ENCRYPTION_KEY", "env-passphrase")
+	if key, warnings, err := resolveEncryptionKey("--encryption-key-file", keyFile); err != nil || key != "passphrase" || len(warnings) != 1 {
+		t.Errorf("Expected the file key with a permissions warning, got %q %v (%v)", key, warnings, err)
+	}
+	if key, _, err := resolveEncryptionKey("--encryption-key-file", ""); err != nil || key != "env-passphrase" {
+		t.Errorf("Expected the environment key, got %q (%v)", key, err)
+	}
+
+	memory := filepath.Join(dir, "memory.jsonl")
+	if storageType, err := encryptedStorageType(memory, ""); err != nil || storageType != "jsonl" {
+		t.Errorf("Expected encrypted storage to stay on JSONL, got %q (%v)", storageType, err)
+	}
+	if _, err := encryptedStorageType(memory, "sqlite"); err == nil {
+		t.Error("Expected --storage sqlite to be rejected")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "memory.db"), nil, 0600); err != nil {
+		t.Fatalf("Failed to write database: %v", err)
+	}
+	if _, err := encryptedStorageType(memory, ""); err == nil {
+		t.Error("Expected an existing SQLite database to be reported rather than ignored")
+	}
+}
+
+func TestFetchGraph(t *testing.T) {
+	jsonl := `{"type":"entity","name":"A","entityType":"test","observations":["a1"]}
+not json
+{"type":"relation","from":"A","to":"A","relationType":"self"}
+`
+	var gzipped bytes.Buffer
+	zw := gzip.NewWriter(&gzipped)
+	zw.Write([]byte(`{"entities":[{"name":"B","entityType":"test"}]}`))
+	zw.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/seed.jsonl", func(w http.ResponseWriter, r *http.Request) { fmt.Fprint(w, jsonl) })
+	mux.HandleFunc("/seed.json.gz", func(w http.ResponseWriter, r *http.Request) { w.Write(gzipped.Bytes()) })
+	mux.HandleFunc("/empty", func(w http.ResponseWriter, r *http.Request) { fmt.Fprint(w, "nothing here") })
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) { time.Sleep(200 * time.Millisecond) })
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	fetch := func(path string, maxBytes int64) (*storage.KnowledgeGraph, *RemoteImport, error) {
+		return fetchGraph(context.Background(), srv.Client(), srv.URL+path, maxBytes, 100*time.Millisecond)
+	}
+
+	graph, fetched, err := fetch("/seed.jsonl", 1024)
+	if err != nil {
+		t.Fatalf("fetchGraph failed: %v", err)
+	}
+	if len(graph.Entities) != 1 || len(graph.Relations) != 1 || len(fetched.SkippedLines) != 1 || fetched.Bytes != len(jsonl) {
+		t.Errorf("Expected one entity, one relation, and one skipped line, got %+v %+v", graph, fetched)
+	}
+	if graph, _, err := fetch("/seed.json.gz", 1024); err != nil || len(graph.Entities) != 1 || graph.Entities[0].Name != "B" {
+		t.Errorf("Expected the gzip JSON graph decompressed, got %+v (%v)", graph, err)
+	}
+
+	for path, maxBytes := range map[string]int64{
+		"/seed.jsonl": 10,   // over the size limit
+		"/empty":      1024, // nothing parses
+		"/missing":    1024, // 404
+		"/slow":       1024, // timeout
+	} {
+		if _, _, err := fetch(path, maxBytes); err == nil {
+			t.Errorf("%s: expected an error", path)
+		}
+	}
+	if _, _, err := fetchGraph(context.Background(), srv.Client(), "file:///etc/passwd", 1024, time.Second); !errors.Is(err, storage.ErrInvalidArgument) {
+		t.Errorf("Expected non-HTTP URLs rejected, got %v", err)
+	}
+}
+
+func TestRemoteImportClientRejectsInternalAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"type":"entity","name":"A","entityType":"test"}`)
+	}))
+	defer srv.Close()
+	if _, _, err := fetchGraph(context.Background(), remoteImportClient(), srv.URL, 1024, time.Second); !errors.Is(err, storage.ErrInvalidArgument) {
+		t.Errorf("Expected a loopback URL rejected, got %v", err)
+	}
+
+	for _, address := range []string{
+		"127.0.0.1:80", "[::1]:80", "10.0.0.5:80", "172.16.0.1:443", "192.168.1.1:80", "169.254.169.254:80", "[fe80::1]:80",
+		"0.0.0.0:80", "0.1.2.3:80", "100.64.0.1:80", "100.127.255.254:80",
+		"[::ffff:127.0.0.1]:80", "[::ffff:10.0.0.1]:80", "[::ffff:100.64.0.1]:80", "[::ffff:169.254.169.254]:80",
+		"[::ffff:0:7f00:1]:80", "[::127.0.0.1]:80", "[64:ff9b::a9fe:a9fe]:80",
+	} {
+		if err := rejectInternalAddress("tcp", address, nil); !errors.Is(err, storage.ErrInvalidArgument) {
+			t.Errorf("%s: expected rejection, got %v", address, err)
+		}
+	}
+	for _, address := range []string{"93.184.216.34:443", "[2606:4700::1111]:443", "100.128.0.1:443", "[::ffff:93.184.216.34]:443", "[64:ff9b::5db8:d822]:443"} {
+		if err := rejectInternalAddress("tcp", address, nil); err != nil {
+			t.Errorf("%s: expected allowed, got %v", address, err)
+		}
+	}
+}
+
+func TestToolSelection(t *testing.T) {
+	search := mcp.NewTool("search_nodes", mcp.WithReadOnlyHintAnnotation(true))
+	create := mcp.NewTool("create_entities")
+	clearTool := mcp.NewTool("clear_graph", mcp.WithDestructiveHintAnnotation(true))
+	tools := []mcp.Tool{search, create, clearTool}
+	registered := map[string]bool{"search_nodes": true, "create_entities": true, "clear_graph": true}
+
+	for spec, want := range map[string][]string{
+		"":                        {"search_nodes", "create_entities", "clear_graph"},
+		"read":                    {"search_nodes"},
+		"-write":                  {"search_nodes"},
+		"-clear_graph":            {"search_nodes", "create_entities"},
+		"read, create_entities":   {"search_nodes", "create_entities"},
+		"write,-clear_graph":      {"create_entities"},
+		"search_nodes,-read":      {},
+		"clear_graph,clear_graph": {"clear_graph"},
+	} {
+		sel, err := parseToolSelection(spec)
+		if err != nil {
+			t.Fatalf("parseToolSelection(%q) failed: %v", spec, err)
+		}
+		if err := sel.check(registered); err != nil {
+			t.Errorf("%q: unexpected check error: %v", spec, err)
+		}
+		got := []string{}
+		for _, tool := range tools {
+			if sel.allows(tool) {
+				got = append(got, tool.Name)
+			}
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("%q: expected %v, got %v", spec, want, got)
+		}
+	}
+
+	if _, err := parseToolSelection("read,-"); err == nil {
+		t.Error("Expected a bare - to be rejected")
+	}
+	sel, _ := parseToolSelection("read,-serch_nodes")
+	if err := sel.check(registered); err == nil || !strings.Contains(err.Error(), "serch_nodes") {
+		t.Errorf("Expected the misspelled tool reported, got %v", err)
+	}
+}
+
+func TestResolveAuthBearer(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("file-token\n"), 0600); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+
+	if token, warnings, err := resolveAuthBearer("", tokenFile); err != nil || token != "file-token" || len(warnings) != 0 {
+		t.Errorf("Expected the trimmed file token without warnings, got %q %v (%v)", token, warnings, err)
+	}
+
+	t.Setenv("MEMORY_MCP_AUTH_BEARER", "env-token")
+	if token, _, err := resolveAuthBearer("", ""); err != nil || token != "env-token" {
+		t.Errorf("Expected the env token, got %q (%v)", token, err)
+	}
+	if token, warnings, err := resolveAuthBearer("", tokenFile); err != nil || token != "file-token" || len(warnings) != 1 {
+		t.Errorf("Expected the file to win over env with a conflict warning, got %q %v (%v)", token, warnings, err)
+	}
+	if token, warnings, err := resolveAuthBearer("flag-token", tokenFile); err != nil || token != "flag-token" || len(warnings) != 2 {
+		t.Errorf("Expected the flag to win with a warning per other source, got %q %v (%v)", token, warnings, err)
+	}
+	if _, warnings, _ := resolveAuthBearer("env-token", ""); len(warnings) != 0 {
+		t.Errorf("Expected no warning when sources agree, got %v", warnings)
+	}
+
+	if err := os.Chmod(tokenFile, 0644); err != nil {
+		t.Fatalf("Failed to chmod token file: %v", err)
+	}
+	t.Setenv("MEMORY_MCP_AUTH_BEARER", "")
+	if _, warnings, _ := resolveAuthBearer("", tokenFile); len(warnings) != 1 {
+		t.Errorf("Expected a warning for a world-readable token file, got %v", warnings)
+	}
+
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, []byte("\n"), 0600); err != nil {
+		t.Fatalf("Failed to write empty file: %v", err)
+	}
+	if _, _, err := resolveAuthBearer("", empty); err == nil {
+		t.Error("Expected an error for an empty token file")
+	}
+	if _, _, err := resolveAuthBearer("", filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected an error for a missing token file")
+	}
+}
+
+func TestBackendMarker(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "memory.json")
+	if err := os.WriteFile(jsonPath, []byte(`{"type":"entity","name":"A","entityType":"test","observations":[]}`+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write JSON file: %v", err)
+	}
+
+	if on, err := resolveBackendChoice(jsonPath, true, false, 0); err != nil || !on {
+		t.Errorf("Expected the default without a marker, got %v (%v)", on, err)
+	}
+
+	// --no-auto-migrate is recorded, and a later run without flags keeps JSONL
+	if on, err := resolveBackendChoice(jsonPath, false, true, 0); err != nil || on {
+		t.Errorf("Expected the explicit opt-out, got %v (%v)", on, err)
+	}
+	if data, _ := os.ReadFile(backendMarkerPath(jsonPath)); string(data) != "jsonl\n" {
+		t.Errorf("Expected the marker to record jsonl, got %q", data)
+	}
+	on, err := resolveBackendChoice(jsonPath, true, false, 0)
+	if err != nil || on {
+		t.Fatalf("Expected the marker to keep JSONL on restart, got %v (%v)", on, err)
+	}
+	mgr, err := NewKnowledgeGraphManager(jsonPath, "", on)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	mgr.Close()
+	if mgr.memoryPath != jsonPath {
+		t.Errorf("Expected JSONL at %s, got %s", jsonPath, mgr.memoryPath)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "memory.db")); !os.IsNotExist(err) {
+		t.Errorf("Expected no database created, got %v", err)
+	}
+
+	// An explicit opt-in replaces the marker
+	if on, err := resolveBackendChoice(jsonPath, true, true, 0); err != nil || !on {
+		t.Errorf("Expected the explicit opt-in, got %v (%v)", on, err)
+	}
+	if on, _ := resolveBackendChoice(jsonPath, false, false, 0); !on {
+		t.Error("Expected the sqlite marker to turn auto-migrate on")
+	}
+
+	// --file-mode applies to the marker too
+	if _, err := resolveBackendChoice(jsonPath, false, true, 0600); err != nil {
+		t.Fatalf("Failed to record the backend: %v", err)
+	}
+	info, err := os.Stat(backendMarkerPath(jsonPath))
+	if err != nil {
+		t.Fatalf("Failed to stat marker: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the marker written with mode 0600, got %v", info.Mode().Perm())
+	}
+
+	if err := os.WriteFile(backendMarkerPath(jsonPath), []byte("postgres\n"), 0644); err != nil {
+		t.Fatalf("Failed to write marker: %v", err)
+	}
+	if _, err := resolveBackendChoice(jsonPath, true, false, 0); err == nil {
+		t.Error("Expected an error for an unknown backend in the marker")
+	}
+}
+
+func TestSQLiteFallback(t *testing.T) {
+	dir := t.TempDir()
+	jsonlPath := filepath.Join(dir, "memory.jsonl")
+	if err := os.WriteFile(jsonlPath, []byte(`{"type":"entity","name":"A","entityType":"test","observations":[]}`+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write JSONL file: %v", err)
+	}
+	// A sibling .db that SQLite cannot open stands in for a platform where it fails
+	dbPath := filepath.Join(dir, "memory.db")
+	if err := os.WriteFile(dbPath, []byte("this is not a sqlite database, just some text long enough to have a header"), 0644); err != nil {
+		t.Fatalf("Failed to write db file: %v", err)
+	}
+
+	mgr, err := NewKnowledgeGraphManager(jsonlPath, "", true)
+	if err != nil {
+		t.Fatalf("Expected auto-detected SQLite to fall back to JSONL, got %v", err)
+	}
+	defer mgr.Close()
+	if mgr.memoryPath != jsonlPath {
+		t.Errorf("Expected the JSONL file %s in use, got %s", jsonlPath, mgr.memoryPath)
+	}
+	if graph, err := mgr.OpenNodes([]string{"A"}); err != nil || len(graph.Entities) != 1 {
+		t.Errorf("Expected the JSONL data to be readable, got %+v (%v)", graph, err)
+	}
+
+	// An explicit SQLite choice still fails loudly
+	if _, err := NewKnowledgeGraphManager(dbPath, "sqlite", true); err == nil {
+		t.Error("Expected explicit SQLite storage to fail without falling back")
+	}
+}
+
+func TestNamespaceMiddleware(t *testing.T) {
+	mgr, err := NewKnowledgeGraphManager(filepath.Join(t.TempDir(), "test.db"), "sqlite", false)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer mgr.Close()
+
+	// The handler creates an entity in whichever namespace the call names
+	handler := namespaceMiddleware(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		_, err := mgr.In(ctx).CreateEntities([]storage.Entity{{Name: "Note", EntityType: "test"}})
+		return nil, err
+	})
+	call := func(args map[string]any) error {
+		var request mcp.CallToolRequest
+		request.Params.Arguments = args
+		_, err := handler(context.Background(), request)
+		return err
+	}
+
+	if err := call(map[string]any{"namespace": "scratch"}); err != nil {
+		t.Fatalf("Call with namespace failed: %v", err)
+	}
+	if graph, _ := mgr.OpenNodes([]string{"Note"}); len(graph.Entities) != 0 {
+		t.Errorf("Expected the default namespace to stay empty, got %+v", graph.Entities)
+	}
+	namespaces, err := mgr.Namespaces()
+	if err != nil || len(namespaces) != 2 || namespaces[1] != "scratch" {
+		t.Errorf("Expected [default scratch], got %v (%v)", namespaces, err)
+	}
+
+	if err := call(map[string]any{}); err != nil {
+		t.Fatalf("Call without namespace failed: %v", err)
+	}
+	if graph, _ := mgr.OpenNodes([]string{"Note"}); len(graph.Entities) != 1 {
+		t.Errorf("Expected calls without a namespace to use the default, got %+v", graph.Entities)
+	}
+
+	if err := call(map[string]any{"namespace": "../etc"}); !errors.Is(err, storage.ErrInvalidArgument) {
+		t.Errorf("Expected an invalid namespace to be rejected, got %v", err)
+	}
+}
+
+func TestNeighborTypes(t *testing.T) {
+	for _, file := range []string{"test.jsonl", "test.db"} {
+		t.Run(file, func(t *testing.T) {
+			mgr, err := NewKnowledgeGraphManager(filepath.Join(t.TempDir(), file), "", false)
+			if err != nil {
+				t.Fatalf("Failed to create manager: %v", err)
+			}
+			defer mgr.Close()
+			if _, err := mgr.CreateEntities([]storage.Entity{
+				{Name: "Alice", EntityType: "person"},
+				{Name: "Acme", EntityType: "organization"},
+				{Name: "Bob", EntityType: "person"},
+			}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			if _, err := mgr.CreateRelations([]storage.Relation{
+				{From: "Alice", To: "Acme", RelationType: "works_at"},
+				{From: "Bob", To: "Alice", RelationType: "knows"},
+			}); err != nil {
+				t.Fatalf("CreateRelations failed: %v", err)
+			}
+
+			graph, err := mgr.OpenNodes([]string{"Alice"})
+			if err != nil {
+				t.Fatalf("OpenNodes failed: %v", err)
+			}
+			if graph.Relations[0].ToType != "" {
+				t.Errorf("Expected no neighbor types unless asked, got %+v", graph.Relations)
+			}
+
+			graph, err = mgr.withNeighborTypes(graph)
+			if err != nil {
+				t.Fatalf("withNeighborTypes failed: %v", err)
+			}
+			types := map[string][2]string{}
+			for _, r := range graph.Relations {
+				types[r.RelationType] = [2]string{r.FromType, r.ToType}
+			}
+			if types["works_at"] != [2]string{"person", "organization"} || types["knows"] != [2]string{"person", "person"} {
+				t.Errorf("Expected resolved endpoint types, got %+v", graph.Relations)
+			}
+		})
+	}
+}
+
+func TestSessionChanges(t *testing.T) {
+	mgr, err := NewKnowledgeGraphManager(filepath.Join(t.TempDir(), "test.db"), "sqlite", false)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer mgr.Close()
+
+	if _, err := mgr.CreateEntities([]storage.Entity{{Name: "Alice", EntityType: "person", Observations: []string{"likes tea"}}}); err != nil {
+		t.Fatalf("CreateEntities failed: %v", err)
+	}
+	mgr.ResetSession()
+
+	if _, err := mgr.CreateEntities([]storage.Entity{{Name: "Acme", EntityType: "company", Observations: []string{"ships widgets"}}}); err != nil {
+		t.Fatalf("CreateEntities failed: %v", err)
+	}
+	if _, err := mgr.AddObservations([]ObservationAddition{{EntityName: "Alice", Contents: []string{"likes tea", "moved to Oslo"}}}, ""); err != nil {
+		t.Fatalf("AddObservations failed: %v", err)
+	}
+	if _, err := mgr.CreateRelations([]storage.Relation{{From: "Alice", To: "Acme", RelationType: "works_at"}}); err != nil {
+		t.Fatalf("CreateRelations failed: %v", err)
+	}
+	work := mgr.In(context.WithValue(context.Background(), namespaceKey{}, "work"))
+	if _, err := work.CreateEntities([]storage.Entity{{Name: "Elsewhere", EntityType: "note"}}); err != nil {
+		t.Fatalf("CreateEntities failed: %v", err)
+	}
+
+	changes := mgr.SessionChanges()
+	if !slices.Equal(changes.Entities, []SessionEntity{{Name: "Acme", EntityType: "company"}}) {
+		t.Errorf("Expected only Acme created this session, got %+v", changes.Entities)
+	}
+	want := map[string][]string{"Acme": {"ships widgets"}, "Alice": {"moved to Oslo"}}
+	if !maps.EqualFunc(changes.Observations, want, slices.Equal) {
+		t.Errorf("Expected %v, got %v", want, changes.Observations)
+	}
+	if len(changes.Relations) != 1 || changes.Relations[0].RelationType != "works_at" {
+		t.Errorf("Expected the works_at relation, got %+v", changes.Relations)
+	}
+	if other := work.SessionChanges(); len(other.Entities) != 1 || other.Entities[0].Name != "Elsewhere" {
+		t.Errorf("Expected namespaces tracked separately, got %+v", other)
+	}
+
+	mgr.ResetSession()
+	if changes := mgr.SessionChanges(); len(changes.Entities)+len(changes.Observations)+len(changes.Relations) != 0 {
+		t.Errorf("Expected nothing after a reset, got %+v", changes)
+	}
+	if graph, err := mgr.OpenNodes([]string{"Acme"}); err != nil || len(graph.Entities) != 1 {
+		t.Errorf("Expected the store untouched by a reset, got %+v (%v)", graph, err)
+	}
+}
+
+func TestNDJSONHandler(t *testing.T) {
+	mgr, err := NewKnowledgeGraphManager(filepath.Join(t.TempDir(), "test.db"), "sqlite", false)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer mgr.Close()
+	if _, err := mgr.In(context.WithValue(context.Background(), namespaceKey{}, "work")).CreateEntities(
+		[]storage.Entity{{Name: "Acme", EntityType: "company", Observations: []string{"ships widgets"}}}); err != nil {
+		t.Fatalf("CreateEntities failed: %v", err)
+	}
+
+	handler := ndjsonHandler(mgr)
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	if rec := get("/graph.ndjson"); rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Errorf("Expected an empty stream for the empty default namespace, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec := get("/graph.ndjson?namespace=work")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("Expected NDJSON response, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if want := `{"type":"entity","name":"Acme","entityType":"company","observations":["ships widgets"]}` + "\n"; rec.Body.String() != want {
+		t.Errorf("Expected %q, got %q", want, rec.Body.String())
+	}
+
+	if rec := get("/graph.ndjson?namespace=../etc"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid namespace, got %d", rec.Code)
+	}
+	post := httptest.NewRecorder()
+	handler.ServeHTTP(post, httptest.NewRequest(http.MethodPost, "/graph.ndjson", nil))
+	if post.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for POST, got %d", post.Code)
+	}
+}
+
+func TestCreatePlaceholders(t *testing.T) {
+	mgr, err := NewKnowledgeGraphManager(filepath.Join(t.TempDir(), "test.db"), "sqlite", false)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer mgr.Close()
+	if _, err := mgr.CreateEntities([]storage.Entity{{Name: "Ann", EntityType: "person", Observations: []string{"likes tea"}}}); err != nil {
+		t.Fatalf("CreateEntities failed: %v", err)
+	}
+
+	relations := []storage.Relation{
+		{From: "Ann", To: "Acme", RelationType: "works_at"},
+		{From: "Acme", To: "Berlin", RelationType: "based_in"},
+	}
+	placeholders, err := mgr.CreatePlaceholders(relations, "unknown")
+	if err != nil {
+		t.Fatalf("CreatePlaceholders failed: %v", err)
+	}
+	var names []string
+	for _, e := range placeholders {
+		names = append(names, e.Name)
+		if e.EntityType != "unknown" || len(e.Observations) != 0 {
+			t.Errorf("Expected a bare placeholder of type unknown, got %+v", e)
+		}
+	}
+	if !slices.Equal(names, []string{"Acme", "Berlin"}) {
+		t.Errorf("Expected placeholders for Acme and Berlin only, got %v", names)
+	}
+
+	created, err := mgr.CreateRelations(relations)
+	if err != nil || len(created) != 2 {
+		t.Errorf("Expected both relations to be stored, got %v (%v)", created, err)
+	}
+	if graph, _ := mgr.OpenNodes([]string{"Ann"}); len(graph.Entities) != 1 || graph.Entities[0].EntityType != "person" {
+		t.Errorf("Expected the existing entity untouched, got %+v", graph.Entities)
+	}
+	if again, err := mgr.CreatePlaceholders(relations, "unknown"); err != nil || len(again) != 0 {
+		t.Errorf("Expected no placeholders once every endpoint exists, got %v (%v)", again, err)
+	}
+}
+
+func TestInverseRelations(t *testing.T) {
+	if _, err := parseInverseRelations("parent_of"); err == nil {
+		t.Error("Expected an error for a pair without '='")
+	}
+	if _, err := parseInverseRelations("parent_of=child_of,parent_of=offspring_of"); err == nil {
+		t.Error("Expected an error for a type paired twice")
+	}
+	inverses, err := parseInverseRelations("parent_of=child_of, sibling_of=sibling_of")
+	if err != nil {
+		t.Fatalf("parseInverseRelations failed: %v", err)
+	}
+
+	mgr, err := NewKnowledgeGraphManager(filepath.Join(t.TempDir(), "test.db"), "sqlite", false)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer mgr.Close()
+	if _, err := mgr.CreateEntities([]storage.Entity{{Name: "Ann", EntityType: "person"}, {Name: "Bea", EntityType: "person"}}); err != nil {
+		t.Fatalf("CreateEntities failed: %v", err)
+	}
+	if _, err := mgr.CreateRelations([]storage.Relation{{From: "Ann", To: "Bea", RelationType: "parent_of"}}); err != nil {
+		t.Fatalf("CreateRelations failed: %v", err)
+	}
+
+	for _, r := range []storage.Relation{
+		{From: "Ann", To: "Bea", RelationType: "child_of"},  // same direction, inverse type
+		{From: "Bea", To: "Ann", RelationType: "parent_of"}, // reversed
+	} {
+		if err := inverses.check(mgr, []storage.Relation{r}); !errors.Is(err, storage.ErrConflict) {
+			t.Errorf("Expected %+v to be rejected as a contradiction, got %v", r, err)
+		}
+	}
+	consistent := []storage.Relation{
+		{From: "Bea", To: "Ann", RelationType: "child_of"},
+		{From: "Ann", To: "Bea", RelationType: "sibling_of"},
+		{From: "Bea", To: "Ann", RelationType: "sibling_of"},
+	}
+	if err := inverses.check(mgr, consistent); err != nil {
+		t.Errorf("Expected consistent relations to pass, got %v", err)
+	}
+	batch := []storage.Relation{
+		{From: "Bea", To: "Ann", RelationType: "knows"},
+		{From: "Ann", To: "Bea", RelationType: "knows"},
+		{From: "Bea", To: "Cat", RelationType: "parent_of"},
+		{From: "Cat", To: "Bea", RelationType: "parent_of"},
+	}
+	if err := inverses.check(mgr, batch); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("Expected a contradiction within the batch to be rejected, got %v", err)
+	}
+
+	got := inverses.inverses([]storage.Relation{
+		{From: "Ann", To: "Bea", RelationType: "parent_of"},
+		{From: "Ann", To: "Bea", RelationType: "knows"},
+	})
+	if len(got) != 1 || got[0] != (storage.Relation{From: "Bea", To: "Ann", RelationType: "child_of"}) {
+		t.Errorf("Expected only the child_of inverse, got %+v", got)
+	}
+}
@@ -0,0 +1,48 @@
+package storage
+
+// Adjacent is an outgoing edge in an AdjacencyList
+type Adjacent struct {
+	To           string `json:"to"`
+	RelationType string `json:"relationType"`
+}
+
+// AdjacencyList maps every entity in scope to its outgoing relations to other
+// entities in scope, oldest first; entities without any map to an empty list.
+// The scope is every entity allowed by filter and, when query is set, matching
+// it as SearchNodes does. The relations are read in a single pass.
+func AdjacencyList(source Storage, query string, filter TypeFilter) (map[string][]Adjacent, error) {
+	entities, err := source.ListEntities(ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var matched map[string]bool
+	if query != "" {
+		result, err := source.SearchNodes(query, 0)
+		if err != nil {
+			return nil, err
+		}
+		matched = make(map[string]bool, len(result.Entities))
+		for _, hit := range result.Entities {
+			matched[hit.Name] = true
+		}
+	}
+
+	adjacency := make(map[string][]Adjacent)
+	for _, entity := range entities.Entities {
+		if filter.allows(entity.EntityType) && (matched == nil || matched[entity.Name]) {
+			adjacency[entity.Name] = []Adjacent{}
+		}
+	}
+
+	relations, err := source.ListRelations(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, relation := range relations.Relations {
+		edges, ok := adjacency[relation.From]
+		if _, inScope := adjacency[relation.To]; ok && inScope {
+			adjacency[relation.From] = append(edges, Adjacent{To: relation.To, RelationType: relation.RelationType})
+		}
+	}
+	return adjacency, nil
+}
@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mostConnectedLimit is how many entities the most_connected analytics list
+const mostConnectedLimit = 10
+
+// GraphAnalytics is AnalyzeGraph's result as analyze_graph returns it
+type GraphAnalytics struct {
+	Analysis   map[string]interface{} `json:"analysis"`
+	ComputedAt time.Time              `json:"computedAt"`
+	Cached     bool                   `json:"cached"` // served from the cache rather than computed for this call
+	// Stale is set when the graph may have changed since ComputedAt: writes
+	// were made since, or the cache predates this process and writes can't be told
+	Stale       bool `json:"stale"`
+	WritesSince int  `json:"writesSince,omitempty"`
+}
+
+// analyticsState counts SQLite writes so cached analytics know how stale they
+// are; shared by namespace views. Counts live in memory only.
+type analyticsState struct {
+	mu       sync.Mutex
+	writes   int            // writes through this store since it opened
+	computed map[string]int // namespace -> writes when its cache was last refreshed
+}
+
+// written records one write; any namespace may have changed
+func (a *analyticsState) written() {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.writes++
+}
+
+// current returns the write count, to pass to refreshed once a refresh is stored
+func (a *analyticsState) current() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.writes
+}
+
+// refreshed records that ns's cache reflects the first writes writes
+func (a *analyticsState) refreshed(ns string, writes int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.computed[ns] = writes
+}
+
+// since returns the writes made since ns's cache was refreshed, and false when
+// it was refreshed before this process started
+func (a *analyticsState) since(ns string) (int, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	at, ok := a.computed[ns]
+	return a.writes - at, ok
+}
+
+// analyticsKey is the metadata row holding the namespace's cached analytics
+func (s *SQLiteStorage) analyticsKey() string {
+	return "analytics:" + s.ns()
+}
+
+// Analytics returns AnalyzeGraph's result. With Config.AnalyticsCache it is
+// read from the metadata table, and recomputed only when missing or when
+// Config.AnalyticsRefreshEvery writes have been made since.
+func (s *SQLiteStorage) Analytics() (*GraphAnalytics, error) {
+	if !s.config.AnalyticsCache {
+		return s.computeAnalytics()
+	}
+
+	var value string
+	err := s.rdb().QueryRow("SELECT value FROM metadata WHERE key = ?", s.analyticsKey()).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s.RefreshAnalytics()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached analytics: %w", err)
+	}
+	var cached GraphAnalytics
+	if err := json.Unmarshal([]byte(value), &cached); err != nil {
+		return s.RefreshAnalytics()
+	}
+
+	writes, known := s.analytics.since(s.ns())
+	if every := s.config.AnalyticsRefreshEvery; every > 0 && (!known || writes >= every) {
+		return s.RefreshAnalytics()
+	}
+	cached.Cached = true
+	cached.Stale = !known || writes > 0
+	if known {
+		cached.WritesSince = writes
+	}
+	return &cached, nil
+}
+
+// RefreshAnalytics recomputes AnalyzeGraph's result and, with
+// Config.AnalyticsCache, replaces the cached copy
+func (s *SQLiteStorage) RefreshAnalytics() (*GraphAnalytics, error) {
+	if !s.config.AnalyticsCache {
+		return s.computeAnalytics()
+	}
+
+	// Writes made while computing leave the new cache stale
+	writes := s.analytics.current()
+	result, err := s.computeAnalytics()
+	if err != nil {
+		return nil, err
+	}
+	value, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	retries, backoff := s.config.writeRetryPolicy()
+	if err := retryOnBusy(retries, backoff, func() error {
+		_, err := s.db.Exec("INSERT OR REPLACE INTO metadata (key, value) VALUES (?, ?)", s.analyticsKey(), string(value))
+		return err
+	}); err != nil {
+		return nil, fmt.Errorf("failed to cache analytics: %w", err)
+	}
+	s.analytics.refreshed(s.ns(), writes)
+	return result, nil
+}
+
+// computeAnalytics runs AnalyzeGraph now
+func (s *SQLiteStorage) computeAnalytics() (*GraphAnalytics, error) {
+	analysis, err := s.AnalyzeGraph()
+	if err != nil {
+		return nil, err
+	}
+	return &GraphAnalytics{Analysis: analysis, ComputedAt: time.Now().UTC()}, nil
+}
+
+// Analytics computes the same figures as SQLite's AnalyzeGraph from the graph;
+// JSONL keeps no cache, so they are always fresh
+func (j *JSONLStorage) Analytics() (*GraphAnalytics, error) {
+	graph, err := j.loadGraph()
+	if err != nil {
+		return nil, err
+	}
+	return &GraphAnalytics{Analysis: analyzeGraph(graph), ComputedAt: time.Now().UTC()}, nil
+}
+
+// RefreshAnalytics is Analytics: there is no cache to replace
+func (j *JSONLStorage) RefreshAnalytics() (*GraphAnalytics, error) {
+	return j.Analytics()
+}
+
+// analyzeGraph returns the figures of SQLiteStorage.AnalyzeGraph for an
+// in-memory graph
+func analyzeGraph(graph *KnowledgeGraph) map[string]interface{} {
+	observationCount := 0
+	entityTypes := make(map[string]int)
+	for _, entity := range graph.Entities {
+		observationCount += len(entity.Observations)
+		entityTypes[entity.EntityType]++
+	}
+
+	relationTypes := make(map[string]int)
+	connections := make(map[string]int)
+	for _, relation := range graph.Relations {
+		relationTypes[relation.RelationType]++
+		connections[relation.From]++
+		connections[relation.To]++
+	}
+
+	connected := slices.Clone(graph.Entities)
+	connected = slices.DeleteFunc(connected, func(e Entity) bool { return connections[e.Name] == 0 })
+	slices.SortStableFunc(connected, func(a, b Entity) int {
+		if diff := connections[b.Name] - connections[a.Name]; diff != 0 {
+			return diff
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	mostConnected := []map[string]interface{}{}
+	for _, entity := range connected[:min(len(connected), mostConnectedLimit)] {
+		mostConnected = append(mostConnected, map[string]interface{}{
+			"name":             entity.Name,
+			"entity_type":      entity.EntityType,
+			"connection_count": connections[entity.Name],
+		})
+	}
+
+	return map[string]interface{}{
+		"entity_count":      len(graph.Entities),
+		"relation_count":    len(graph.Relations),
+		"observation_count": observationCount,
+		"entity_types":      entityTypes,
+		"relation_types":    relationTypes,
+		"most_connected":    mostConnected,
+	}
+}
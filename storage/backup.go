@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Backup files written by BackupGraph are named backupPrefix + timestamp + backupSuffix
+const (
+	backupPrefix = "memory_backup_"
+	backupSuffix = ".jsonl"
+)
+
+// BackupGraph writes every namespace of source to a timestamped JSONL file in dir
+// and returns its path. Older backups in dir beyond the newest keep are removed
+// (keep <= 0 keeps them all). The file can be loaded back with --seed or import_data.
+func BackupGraph(source Storage, dir string, keep int) (string, error) {
+	namespaces, graphs, err := exportNamespaces(source)
+	if err != nil {
+		return "", fmt.Errorf("failed to export graph for backup: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	timestamp := time.Now().UTC().Format("20060102_150405.000000")
+	path := filepath.Join(dir, backupPrefix+timestamp+backupSuffix)
+	if err := writeGraphSet(path, &graphSet{order: namespaces, graphs: graphs}, fileOptionsOf(source)); err != nil {
+		return "", fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	if err := pruneBackups(dir, keep); err != nil {
+		return path, err
+	}
+	return path, nil
+}
+
+// pruneBackups removes all but the newest keep backups in dir. The timestamped
+// names sort chronologically, so the oldest come first.
+func pruneBackups(dir string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, backupSuffix) {
+			backups = append(backups, name)
+		}
+	}
+	slices.Sort(backups)
+	for len(backups) > keep {
+		if err := os.Remove(filepath.Join(dir, backups[0])); err != nil {
+			return fmt.Errorf("failed to remove old backup: %w", err)
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"log"
+	"slices"
+	"sync"
+	"time"
+)
+
+// writeBuffer holds JSONL changes in memory for Config.FlushInterval, so a burst
+// of mutations rewrites the file once instead of once each. While changes are
+// pending, reads are served from the buffered graphs, never the stale file.
+type writeBuffer struct {
+	interval time.Duration
+	write    func(*graphSet) error
+
+	mu    sync.Mutex  // held while flushing, so reads never see the file mid-update
+	set   *graphSet   // pending changes; nil when the file is current
+	timer *time.Timer // pending flush
+}
+
+// newWriteBuffer returns a buffer flushing with write, or nil when interval is
+// not positive (every change is written through)
+func newWriteBuffer(interval time.Duration, write func(*graphSet) error) *writeBuffer {
+	if interval <= 0 {
+		return nil
+	}
+	return &writeBuffer{interval: interval, write: write}
+}
+
+// pending returns a copy of the buffered graphs, or nil when nothing is pending
+func (b *writeBuffer) pending() *graphSet {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.set == nil {
+		return nil
+	}
+	return b.set.clone()
+}
+
+// store buffers set, which the caller must not use afterwards, and schedules
+// a flush unless one is already pending
+func (b *writeBuffer) store(set *graphSet) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.set = set
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.interval, func() {
+			if err := b.flush(); err != nil {
+				log.Printf("Failed to flush buffered JSONL changes: %v", err)
+			}
+		})
+	}
+}
+
+// flush writes the pending changes now. On failure they stay buffered and the
+// next change or flush tries again.
+func (b *writeBuffer) flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if b.set == nil {
+		return nil
+	}
+	if err := b.write(b.set); err != nil {
+		return err
+	}
+	b.set = nil
+	return nil
+}
+
+// clone returns a copy of g that shares nothing mutable with it, holding what
+// reading g back after writeGraphSet would: only the fields the file stores
+func (g *graphSet) clone() *graphSet {
+	c := &graphSet{
+		order:  slices.Clone(g.order),
+		graphs: make(map[string]*KnowledgeGraph, len(g.graphs)),
+		gzip:   g.gzip,
+	}
+	for ns, graph := range g.graphs {
+		entities := make([]Entity, len(graph.Entities))
+		for i, entity := range graph.Entities {
+			entities[i] = Entity{
+				Name:               entity.Name,
+				EntityType:         entity.EntityType,
+				Description:        entity.Description,
+				Observations:       slices.Clone(entity.Observations),
+				ObservationSources: pruneSources(entity.ObservationSources, entity.Observations),
+				ObservationTimes:   pruneObservationMap(entity.ObservationTimes, entity.Observations),
+				UpdateTime:         entity.UpdateTime,
+			}
+		}
+		relations := make([]Relation, len(graph.Relations))
+		for i, relation := range graph.Relations {
+			relations[i] = Relation{From: relation.From, To: relation.To, RelationType: relation.RelationType, CreatedAt: relation.CreatedAt}
+		}
+		c.graphs[ns] = &KnowledgeGraph{Entities: entities, Relations: relations}
+	}
+	return c
+}
+
+// readSet returns every namespace of the memory file, including buffered changes
+func (j *JSONLStorage) readSet() (*graphSet, error) {
+	if j.buffer != nil {
+		if set := j.buffer.pending(); set != nil {
+			return set, nil
+		}
+	}
+	return readGraphSet(j.config.FilePath, j.cipher)
+}
+
+// writeSet replaces every namespace of the memory file with set, buffering it
+// when Config.FlushInterval is set
+func (j *JSONLStorage) writeSet(set *graphSet) error {
+	if j.buffer != nil {
+		j.buffer.store(set)
+		return nil
+	}
+	return writeGraphSet(j.config.FilePath, set, j.fileOptions())
+}
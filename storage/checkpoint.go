@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// CheckpointResult reports a WAL checkpoint, as PRAGMA wal_checkpoint returns it
+type CheckpointResult struct {
+	Busy               bool `json:"busy"`               // a reader or writer kept the checkpoint from finishing
+	LogFrames          int  `json:"logFrames"`          // frames left in the WAL, 0 once it is truncated; -1 when not in WAL mode
+	CheckpointedFrames int  `json:"checkpointedFrames"` // of those, frames already copied into the database; -1 when not in WAL mode
+}
+
+var errCheckpointUnsupported = fmt.Errorf("%w: checkpoint requires SQLite storage (JSONL has no write-ahead log)", ErrInvalidArgument)
+
+// Checkpoint is not supported by JSONL storage, which writes the file directly
+func (j *JSONLStorage) Checkpoint() (*CheckpointResult, error) {
+	return nil, errCheckpointUnsupported
+}
+
+// Checkpoint copies the WAL into the database and truncates the -wal file to
+// zero bytes. It waits for the busy timeout on active readers and writers and
+// reports Busy when it could not finish.
+func (s *SQLiteStorage) Checkpoint() (*CheckpointResult, error) {
+	var busy int
+	result := &CheckpointResult{}
+	err := s.db.QueryRow("PRAGMA wal_checkpoint(TRUNCATE)").Scan(&busy, &result.LogFrames, &result.CheckpointedFrames)
+	if err != nil {
+		return nil, fmt.Errorf("failed to checkpoint: %w", err)
+	}
+	result.Busy = busy != 0
+	return result, nil
+}
+
+// startCheckpoints checkpoints every Config.CheckpointInterval until Close
+func (s *SQLiteStorage) startCheckpoints() {
+	if s.config.CheckpointInterval <= 0 || !s.config.WALMode || s.config.FilePath == ":memory:" {
+		return
+	}
+	s.checkpointStop = make(chan struct{})
+	s.checkpointDone = make(chan struct{})
+	go func() {
+		defer close(s.checkpointDone)
+		ticker := time.NewTicker(s.config.CheckpointInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := s.Checkpoint(); err != nil {
+					log.Printf("Periodic checkpoint failed: %v", err)
+				}
+			case <-s.checkpointStop:
+				return
+			}
+		}
+	}()
+}
+
+// stopCheckpoints ends the periodic checkpoints and runs a final one, so a
+// graceful shutdown leaves no -wal file behind
+func (s *SQLiteStorage) stopCheckpoints() {
+	if s.checkpointStop != nil {
+		close(s.checkpointStop)
+		<-s.checkpointDone
+		s.checkpointStop = nil
+	}
+	if s.db == nil || !s.config.WALMode || s.config.FilePath == ":memory:" {
+		return
+	}
+	if _, err := s.Checkpoint(); err != nil {
+		log.Printf("Checkpoint on close failed: %v", err)
+	}
+}
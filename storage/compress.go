@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IsCompressedPath reports whether path names a gzip-compressed JSONL file
+// (e.g. memory.jsonl.gz or memory.json.gz)
+func IsCompressedPath(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".gz")
+}
+
+// gzipMagic is the header every gzip stream starts with
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// isGzipFile reports whether the existing file at path holds gzip content, so
+// writes keep a compressed file compressed without a .gz suffix. An encrypted
+// file is checked after decrypting it with cipher; one it cannot open counts as
+// plain.
+func isGzipFile(path string, cipher *fileCipher) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	header := make([]byte, len(encryptedMagic))
+	n, _ := io.ReadFull(f, header)
+	header = header[:n]
+	if !isEncrypted(header) {
+		return bytes.HasPrefix(header, gzipMagic)
+	}
+	if cipher == nil {
+		return false
+	}
+	rest, err := io.ReadAll(f)
+	if err != nil {
+		return false
+	}
+	plain, err := cipher.open(append(header, rest...))
+	return err == nil && bytes.HasPrefix(plain, gzipMagic)
+}
+
+// readGraphFile reads a graph file, decrypting it with cipher when it is
+// encrypted and decompressing it when the path is compressed or the content is gzip
+func readGraphFile(path string, cipher *fileCipher) ([]byte, error) {
+	data, _, err := readGraphContent(path, cipher)
+	return data, err
+}
+
+// readGraphContent is readGraphFile that also reports whether the content was
+// gzip once decrypted, so a save can keep a file compressed whatever its suffix
+func readGraphContent(path string, cipher *fileCipher) ([]byte, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read file: %w", err)
+	}
+	if isEncrypted(data) {
+		if data, err = cipher.open(data); err != nil {
+			return nil, false, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if len(data) == 0 || (!IsCompressedPath(path) && !bytes.HasPrefix(data, gzipMagic)) {
+		return data, false, nil
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to open gzip file %s: %w", path, err)
+	}
+	defer zr.Close()
+
+	data, err = io.ReadAll(zr)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to decompress %s: %w", path, err)
+	}
+	return data, true, nil
+}
+
+// writeGraphFile writes a graph file, compressing it when the path is compressed,
+// opts asks for gzip, or the existing file is gzip (so a compressed file keeps
+// its format on save, also when the caller did not read it first), then
+// encrypting it when opts has a cipher. The data goes to a temp file in the
+// same directory that is renamed over path, so readers never see a partially
+// written file. It gets opts.mode, or when that is 0 the mode of the existing
+// file (defaultFileMode for a new one).
+func writeGraphFile(path string, data []byte, opts fileOptions) error {
+	mode := opts.mode
+	if mode == 0 {
+		mode = defaultFileMode
+		if info, err := os.Stat(path); err == nil {
+			mode = info.Mode().Perm()
+		}
+	}
+
+	if IsCompressedPath(path) || opts.gzip || isGzipFile(path, opts.cipher) {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return fmt.Errorf("failed to compress %s: %w", path, err)
+		}
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("failed to compress %s: %w", path, err)
+		}
+		data = buf.Bytes()
+	}
+	if opts.cipher != nil {
+		sealed, err := opts.cipher.seal(data)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt %s: %w", path, err)
+		}
+		data = sealed
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
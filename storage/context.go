@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Formats ExportEntityContext can write
+const (
+	ContextFormatJSONL = "jsonl" // one entity or relation per line, like the memory file
+	ContextFormatJSON  = "json"  // a single {"entities": [...], "relations": [...]} object
+)
+
+// EntityContextExport describes a file written by ExportEntityContext
+type EntityContextExport struct {
+	Path      string `json:"path"`
+	Entities  int    `json:"entities"`
+	Relations int    `json:"relations"`
+}
+
+// EntityContext returns the neighborhood of name: the entity and every entity
+// within depth hops of it in either direction (depth 0 = DefaultReachableHops),
+// with all their observations and the relations among them
+func EntityContext(source Storage, name string, depth int) (*KnowledgeGraph, error) {
+	reached, err := source.Reachable(name, "", depth, "both")
+	if err != nil {
+		return nil, err
+	}
+	names := []string{name}
+	for _, entity := range reached {
+		names = append(names, entity.Name)
+	}
+
+	graph, err := source.OpenNodes(names)
+	if err != nil {
+		return nil, err
+	}
+
+	// OpenNodes caps observations; the export carries them all
+	for i := range graph.Entities {
+		entity := &graph.Entities[i]
+		if entity.ObservationsTotal == 0 {
+			continue
+		}
+		page, err := source.GetObservations(entity.Name, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		entity.Observations = page.Observations
+		entity.ObservationsTotal = 0
+	}
+	graph.Truncated = false
+
+	// Keep only relations inside the neighborhood so the file is self-contained
+	inside := make(map[string]bool, len(names))
+	for _, n := range names {
+		inside[n] = true
+	}
+	relations := []Relation{}
+	for _, relation := range graph.Relations {
+		if inside[relation.From] && inside[relation.To] {
+			relations = append(relations, relation)
+		}
+	}
+	graph.Relations = relations
+	return graph, nil
+}
+
+// ExportEntityContext writes the EntityContext of name to a timestamped file in
+// dir, as JSONL ("" or ContextFormatJSONL) or JSON. The file can be loaded into
+// another store with --seed.
+func ExportEntityContext(source Storage, name string, depth int, dir, format string) (*EntityContextExport, error) {
+	if format == "" {
+		format = ContextFormatJSONL
+	}
+	if format != ContextFormatJSONL && format != ContextFormatJSON {
+		return nil, fmt.Errorf("%w: format %q must be jsonl or json", ErrInvalidArgument, format)
+	}
+
+	graph, err := EntityContext(source, name, depth)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %w", err)
+	}
+	timestamp := time.Now().UTC().Format("20060102_150405.000000")
+	path := filepath.Join(dir, fileSafeName(name)+"_context_"+timestamp+"."+format)
+
+	if format == ContextFormatJSON {
+		data, err := json.MarshalIndent(graph, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode export: %w", err)
+		}
+		err = writeGraphFile(path, append(data, '\n'), fileOptionsOf(source))
+	} else {
+		err = writeGraphSet(path, &graphSet{
+			order:  []string{DefaultNamespace},
+			graphs: map[string]*KnowledgeGraph{DefaultNamespace: graph},
+		}, fileOptionsOf(source))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write export: %w", err)
+	}
+
+	return &EntityContextExport{Path: path, Entities: len(graph.Entities), Relations: len(graph.Relations)}, nil
+}
+
+// fileSafeName turns an entity name into a file name stem, replacing anything
+// but letters, digits, '-' and '_' with '_'
+func fileSafeName(name string) string {
+	safe := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+	if safe == "" {
+		return "entity"
+	}
+	return safe
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DeleteByQueryResult reports the entities DeleteByQuery deleted, or would delete
+type DeleteByQueryResult struct {
+	Deleted    int      `json:"deleted"`
+	Names      []string `json:"names"` // sorted
+	DryRun     bool     `json:"dryRun,omitempty"`
+	BackupPath string   `json:"backupPath,omitempty"` // set by callers that take a backup first
+
+	// Set only on a dry run: the relations that would go with the entities
+	Relations []Relation `json:"relations,omitempty"`
+}
+
+// DeletionPreview lists what deleting entities and relations would remove
+type DeletionPreview struct {
+	DryRun       bool       `json:"dryRun"`
+	Entities     []string   `json:"entities"`           // the named entities that exist, sorted
+	Observations int        `json:"observations"`       // observations of those entities
+	Relations    []Relation `json:"relations"`          // the named relations that exist, and every relation to or from a deleted entity
+	NotFound     []string   `json:"notFound,omitempty"` // named entities that do not exist
+}
+
+// PreviewDeletion resolves what DeleteEntities(names) followed by
+// DeleteRelations(relations) would remove, including the relations that
+// cascade from deleted entities, without changing anything
+func PreviewDeletion(source Storage, names []string, relations []Relation) (*DeletionPreview, error) {
+	preview := &DeletionPreview{DryRun: true, Entities: []string{}, Relations: []Relation{}}
+	seen := make(map[Relation]bool)
+	addRelation := func(relation Relation) {
+		if !seen[relation.key()] {
+			seen[relation.key()] = true
+			preview.Relations = append(preview.Relations, relation)
+		}
+	}
+
+	if len(names) > 0 {
+		// Not OpenNodes, which would count the preview as an access
+		counts, err := source.ObservationCounts(names)
+		if err != nil {
+			return nil, err
+		}
+		for name, count := range counts {
+			preview.Entities = append(preview.Entities, name)
+			preview.Observations += count
+		}
+		for _, name := range names {
+			if _, found := counts[name]; !found && !slices.Contains(preview.NotFound, name) {
+				preview.NotFound = append(preview.NotFound, name)
+			}
+		}
+		slices.Sort(preview.Entities)
+		cascading, err := source.RelationsTouching(names)
+		if err != nil {
+			return nil, err
+		}
+		for _, relation := range cascading {
+			addRelation(relation)
+		}
+	}
+
+	for _, relation := range relations {
+		exists, err := source.RelationExists(relation.From, relation.To, relation.RelationType)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			addRelation(relation.key())
+		}
+	}
+	return preview, nil
+}
+
+// DeleteByQuery deletes every entity that matches query (as SearchNodes matches
+// it) and is of entityType. Either may be empty, but not both. Matching names are
+// resolved first and then deleted with DeleteEntities, so their relations go too.
+// With dryRun nothing is deleted and the result lists what would be, relations
+// included.
+func DeleteByQuery(source Storage, query, entityType string, dryRun bool) (*DeleteByQueryResult, error) {
+	names, err := matchingEntityNames(source, strings.TrimSpace(query), strings.TrimSpace(entityType))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DeleteByQueryResult{Deleted: len(names), Names: names, DryRun: dryRun}
+	if dryRun && len(names) > 0 {
+		preview, err := PreviewDeletion(source, names, nil)
+		if err != nil {
+			return nil, err
+		}
+		result.Relations = preview.Relations
+	}
+	if dryRun || len(names) == 0 {
+		return result, nil
+	}
+	// Deleted reports what was actually deleted, in case another writer got there first
+	if result.Deleted, err = source.DeleteEntities(names); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// matchingEntityNames returns the sorted names of the entities matching query
+// and entityType (see DeleteByQuery)
+func matchingEntityNames(source Storage, query, entityType string) ([]string, error) {
+	names := []string{}
+	switch {
+	case query == "" && entityType == "":
+		return nil, fmt.Errorf("%w: a query or an entity type is required", ErrInvalidArgument)
+	case query == "":
+		list, err := source.ListEntities(ListOptions{EntityType: entityType})
+		if err != nil {
+			return nil, err
+		}
+		for _, entity := range list.Entities {
+			names = append(names, entity.Name)
+		}
+	default:
+		result, err := source.SearchNodes(query, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, hit := range result.Entities {
+			if entityType == "" || hit.EntityType == entityType {
+				names = append(names, hit.Name)
+			}
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names), nil
+}
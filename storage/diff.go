@@ -0,0 +1,119 @@
+package storage
+
+// EntityChange describes how an entity present in both graphs differs
+type EntityChange struct {
+	Name                string   `json:"name"`
+	OldType             string   `json:"oldType,omitempty"` // set only when the type changed
+	NewType             string   `json:"newType,omitempty"`
+	AddedObservations   []string `json:"addedObservations,omitempty"`
+	RemovedObservations []string `json:"removedObservations,omitempty"`
+}
+
+// DiffCounts summarizes a GraphDiff
+type DiffCounts struct {
+	EntitiesAdded       int `json:"entitiesAdded"`
+	EntitiesRemoved     int `json:"entitiesRemoved"`
+	EntitiesModified    int `json:"entitiesModified"`
+	ObservationsAdded   int `json:"observationsAdded"` // including those of added entities
+	ObservationsRemoved int `json:"observationsRemoved"`
+	RelationsAdded      int `json:"relationsAdded"`
+	RelationsRemoved    int `json:"relationsRemoved"`
+}
+
+// GraphDiff is the change from one graph to another. Entities are matched by
+// name and relations by their (from, to, relationType) triple; observation
+// order is ignored.
+type GraphDiff struct {
+	Counts           DiffCounts     `json:"counts"`
+	AddedEntities    []Entity       `json:"addedEntities"`
+	RemovedEntities  []Entity       `json:"removedEntities"`
+	ModifiedEntities []EntityChange `json:"modifiedEntities"`
+	AddedRelations   []Relation     `json:"addedRelations"`
+	RemovedRelations []Relation     `json:"removedRelations"`
+}
+
+// DiffGraphs returns what changed going from before to after
+func DiffGraphs(before, after *KnowledgeGraph) *GraphDiff {
+	diff := &GraphDiff{
+		AddedEntities:    []Entity{},
+		RemovedEntities:  []Entity{},
+		ModifiedEntities: []EntityChange{},
+		AddedRelations:   []Relation{},
+		RemovedRelations: []Relation{},
+	}
+
+	old := make(map[string]Entity, len(before.Entities))
+	for _, entity := range before.Entities {
+		old[entity.Name] = entity
+	}
+	seen := make(map[string]bool, len(after.Entities))
+	for _, entity := range after.Entities {
+		seen[entity.Name] = true
+		prev, ok := old[entity.Name]
+		if !ok {
+			diff.AddedEntities = append(diff.AddedEntities, entity)
+			diff.Counts.ObservationsAdded += len(entity.Observations)
+			continue
+		}
+
+		change := EntityChange{
+			Name:                entity.Name,
+			AddedObservations:   missingFrom(prev.Observations, entity.Observations),
+			RemovedObservations: missingFrom(entity.Observations, prev.Observations),
+		}
+		if prev.EntityType != entity.EntityType {
+			change.OldType, change.NewType = prev.EntityType, entity.EntityType
+		}
+		if change.OldType != "" || change.NewType != "" || len(change.AddedObservations) > 0 || len(change.RemovedObservations) > 0 {
+			diff.ModifiedEntities = append(diff.ModifiedEntities, change)
+			diff.Counts.ObservationsAdded += len(change.AddedObservations)
+			diff.Counts.ObservationsRemoved += len(change.RemovedObservations)
+		}
+	}
+	for _, entity := range before.Entities {
+		if !seen[entity.Name] {
+			seen[entity.Name] = true // report names repeated in before only once
+			diff.RemovedEntities = append(diff.RemovedEntities, entity)
+			diff.Counts.ObservationsRemoved += len(entity.Observations)
+		}
+	}
+
+	beforeKeys, afterKeys := relationKeys(before.Relations), relationKeys(after.Relations)
+	diff.AddedRelations = missingFrom(beforeKeys, afterKeys)
+	diff.RemovedRelations = missingFrom(afterKeys, beforeKeys)
+
+	diff.Counts.EntitiesAdded = len(diff.AddedEntities)
+	diff.Counts.EntitiesRemoved = len(diff.RemovedEntities)
+	diff.Counts.EntitiesModified = len(diff.ModifiedEntities)
+	diff.Counts.RelationsAdded = len(diff.AddedRelations)
+	diff.Counts.RelationsRemoved = len(diff.RemovedRelations)
+	return diff
+}
+
+// relationKeys strips relations down to their identity so timestamps don't count as changes
+func relationKeys(relations []Relation) []Relation {
+	keys := make([]Relation, len(relations))
+	for i, r := range relations {
+		keys[i] = r.key()
+	}
+	return keys
+}
+
+// missingFrom returns the distinct items of items that base lacks, in order
+func missingFrom[T comparable](base, items []T) []T {
+	have := make(map[T]bool, len(base))
+	for _, item := range base {
+		have[item] = true
+	}
+	var missing []T
+	for _, item := range items {
+		if !have[item] {
+			have[item] = true
+			missing = append(missing, item)
+		}
+	}
+	if missing == nil {
+		return []T{}
+	}
+	return missing
+}
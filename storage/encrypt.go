@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// encryptedMagic starts every file written with Config.EncryptionKey; the
+// digit is the format version. It is followed by the key salt, the nonce, and
+// the AES-256-GCM ciphertext.
+var encryptedMagic = []byte("MMSENC1\n")
+
+const (
+	encryptionSaltSize = 16
+	encryptionKeySize  = 32 // AES-256
+	// encryptionKeyIterations is the PBKDF2-HMAC-SHA256 work factor for
+	// turning the passphrase into a key
+	encryptionKeyIterations = 600_000
+)
+
+// isEncrypted reports whether data is a file written with an encryption key
+func isEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, encryptedMagic)
+}
+
+// fileCipher encrypts and decrypts graph files with a key derived from a
+// passphrase. Deriving is deliberately slow, so the key for the file's salt is
+// cached and reused: every save keeps the salt and only the nonce changes.
+type fileCipher struct {
+	passphrase []byte
+
+	mu   sync.Mutex
+	salt []byte // salt of key; nil until the first read or write
+	key  []byte
+}
+
+// newFileCipher returns a cipher for passphrase, or nil when it is empty
+func newFileCipher(passphrase string) *fileCipher {
+	if passphrase == "" {
+		return nil
+	}
+	return &fileCipher{passphrase: []byte(passphrase)}
+}
+
+// keyFor returns the key for salt, deriving it unless it is the cached one
+func (c *fileCipher) keyFor(salt []byte) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.key != nil && bytes.Equal(c.salt, salt) {
+		return c.key, nil
+	}
+	key, err := pbkdf2.Key(sha256.New, string(c.passphrase), salt, encryptionKeyIterations, encryptionKeySize)
+	if err != nil {
+		return nil, err
+	}
+	c.salt, c.key = bytes.Clone(salt), key
+	return key, nil
+}
+
+// currentSalt returns the salt of the cached key, or a new random one
+func (c *fileCipher) currentSalt() ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.salt != nil {
+		return c.salt, nil
+	}
+	salt := make([]byte, encryptionSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// aead returns AES-256-GCM under the key for salt
+func (c *fileCipher) aead(salt []byte) (cipher.AEAD, error) {
+	key, err := c.keyFor(salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plain into the encrypted file format
+func (c *fileCipher) seal(plain []byte) ([]byte, error) {
+	salt, err := c.currentSalt()
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := c.aead(salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(encryptedMagic)+len(salt)+len(nonce)+len(plain)+gcm.Overhead())
+	out = append(out, encryptedMagic...)
+	out = append(out, salt...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, plain, encryptedMagic), nil
+}
+
+// open decrypts data written by seal. A nil cipher (no key configured) and a
+// wrong key both fail with ErrEncryptionKey.
+func (c *fileCipher) open(data []byte) ([]byte, error) {
+	if c == nil {
+		return nil, fmt.Errorf("%w: the file is encrypted and no encryption key was given", ErrEncryptionKey)
+	}
+	rest := data[len(encryptedMagic):]
+	if len(rest) < encryptionSaltSize {
+		return nil, fmt.Errorf("encrypted file is truncated")
+	}
+	salt, rest := rest[:encryptionSaltSize], rest[encryptionSaltSize:]
+	gcm, err := c.aead(salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted file is truncated")
+	}
+	nonce, sealed := rest[:gcm.NonceSize()], rest[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, sealed, encryptedMagic)
+	if err != nil {
+		return nil, fmt.Errorf("%w: wrong encryption key, or the file is corrupted", ErrEncryptionKey)
+	}
+	return plain, nil
+}
+
+// encryptPlaintext encrypts the memory file in place when a key is configured
+// but the file is still plaintext, so turning encryption on takes effect at
+// startup rather than on the first change
+func (j *JSONLStorage) encryptPlaintext() error {
+	if j.cipher == nil {
+		return nil
+	}
+	data, err := os.ReadFile(j.config.FilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	if len(bytes.TrimSpace(data)) == 0 || isEncrypted(data) {
+		return nil
+	}
+	set, err := readGraphSet(j.config.FilePath, j.cipher)
+	if err != nil {
+		return err
+	}
+	return writeGraphSet(j.config.FilePath, set, j.fileOptions())
+}
+
+// RotateEncryptionKey re-encrypts the JSONL file at path from oldKey to newKey.
+// An empty oldKey reads a plaintext file and an empty newKey writes one, so this
+// also turns encryption on or off. The file is replaced atomically and keeps
+// its mode; a wrong oldKey fails with ErrEncryptionKey and changes nothing.
+func RotateEncryptionKey(path, oldKey, newKey string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	if oldKey == "" && isEncrypted(data) {
+		return fmt.Errorf("%w: %s is encrypted and no current key was given", ErrEncryptionKey, path)
+	}
+
+	set, err := readGraphSet(path, newFileCipher(oldKey))
+	if err != nil {
+		return err
+	}
+	if len(set.warnings) > 0 {
+		return fmt.Errorf("%w: %s has %d lines that cannot be loaded; repair it first", ErrInvalidArgument, path, len(set.warnings))
+	}
+	return writeGraphSet(path, set, fileOptions{cipher: newFileCipher(newKey)})
+}
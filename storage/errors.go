@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Sentinel errors returned (wrapped) by Storage implementations.
+// Test for them with errors.Is; the wrapping message carries the details.
+var (
+	// ErrEntityNotFound means a referenced entity does not exist
+	ErrEntityNotFound = errors.New("entity not found")
+
+	// ErrObservationNotFound means a referenced observation does not exist on the entity
+	ErrObservationNotFound = errors.New("observation not found")
+
+	// ErrInvalidArgument means the request itself is malformed (bad option, self-merge, etc.)
+	ErrInvalidArgument = errors.New("invalid argument")
+
+	// ErrConflict means the change would violate a uniqueness constraint
+	ErrConflict = errors.New("conflict")
+
+	// ErrDuplicate is ErrConflict under the name callers often look for
+	ErrDuplicate = ErrConflict
+
+	// ErrStorageLocked means the database stayed locked by another writer after
+	// every retry; the operation made no changes and can be retried later
+	ErrStorageLocked = errors.New("storage locked")
+
+	// ErrEncryptionKey means an encrypted memory file could not be decrypted:
+	// no key was given, or the key is wrong
+	ErrEncryptionKey = errors.New("encryption key")
+)
+
+// entityNotFound wraps ErrEntityNotFound with the entity name
+func entityNotFound(name string) error {
+	return fmt.Errorf("%w: %q", ErrEntityNotFound, name)
+}
+
+// sqliteConstraintUnique is SQLITE_CONSTRAINT_UNIQUE (extended result code)
+const sqliteConstraintUnique = 2067
+
+// isUniqueViolation reports whether err is a SQLite UNIQUE constraint failure
+func isUniqueViolation(err error) bool {
+	var coder interface{ Code() int }
+	return errors.As(err, &coder) && coder.Code() == sqliteConstraintUnique
+}
+
+// Primary SQLite result codes for lock contention
+const (
+	sqliteBusy   = 5
+	sqliteLocked = 6
+)
+
+// isBusy reports whether err is SQLITE_BUSY or SQLITE_LOCKED ("database is locked"),
+// including their extended codes
+func isBusy(err error) bool {
+	var coder interface{ Code() int }
+	if !errors.As(err, &coder) {
+		return false
+	}
+	code := coder.Code() & 0xff
+	return code == sqliteBusy || code == sqliteLocked
+}
+
+// retryOnBusy runs op, retrying up to retries more times with exponential backoff
+// while it fails with a lock error. Any other error is returned immediately.
+// Errors are classified with classifySQLiteError.
+func retryOnBusy(retries int, backoff time.Duration, op func() error) error {
+	err := op()
+	for attempt := 0; attempt < retries && isBusy(err); attempt++ {
+		time.Sleep(backoff)
+		backoff *= 2
+		err = op()
+	}
+	return classifySQLiteError(err)
+}
+
+// classifySQLiteError wraps raw SQLite lock and uniqueness failures with
+// ErrStorageLocked and ErrConflict so callers can test for them with errors.Is
+func classifySQLiteError(err error) error {
+	switch {
+	case err == nil, errors.Is(err, ErrStorageLocked), errors.Is(err, ErrConflict):
+		return err
+	case isBusy(err):
+		return fmt.Errorf("%w: %w", ErrStorageLocked, err)
+	case isUniqueViolation(err):
+		return fmt.Errorf("%w: %w", ErrConflict, err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+)
+
+// defaultFileMode is the mode of new files when Config.FileMode is not set
+const defaultFileMode os.FileMode = 0644
+
+// fileOptions control how a graph file is written
+type fileOptions struct {
+	mode   os.FileMode // 0 = keep the existing file's mode (defaultFileMode for a new one)
+	cipher *fileCipher // nil = plaintext
+	gzip   bool        // compress even without a .gz suffix
+}
+
+// fileOptioner is implemented by backends whose Config says how their graph
+// files, and the backups and exports taken from them, are written
+type fileOptioner interface {
+	fileOptions() fileOptions
+}
+
+func (j *JSONLStorage) fileOptions() fileOptions {
+	return fileOptions{mode: j.config.FileMode, cipher: j.cipher}
+}
+
+func (s *SQLiteStorage) fileOptions() fileOptions {
+	return fileOptions{mode: s.config.FileMode}
+}
+
+// fileOptionsOf returns the fileOptions of source, or the defaults when it has none
+func fileOptionsOf(source Storage) fileOptions {
+	if o, ok := source.(fileOptioner); ok {
+		return o.fileOptions()
+	}
+	return fileOptions{}
+}
+
+// prepareFile creates path with mode (0 = defaultFileMode) when it does not
+// exist. An existing file is set to mode when one is given and otherwise keeps its own.
+func prepareFile(path string, mode os.FileMode) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, fileModeOr(mode))
+		if err != nil {
+			return fmt.Errorf("failed to create file: %w", err)
+		}
+		file.Close()
+	} else if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+	// Explicit, so the umask cannot change the requested mode
+	if mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			return fmt.Errorf("failed to set file mode: %w", err)
+		}
+	}
+	return nil
+}
+
+// fileModeOr returns mode, or defaultFileMode when it is 0
+func fileModeOr(mode os.FileMode) os.FileMode {
+	if mode == 0 {
+		return defaultFileMode
+	}
+	return mode
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// idempotencyKeys holds recorded tool results for JSONL storage, keyed by
+// namespace, tool, and key
+type idempotencyKeys struct {
+	mu      sync.Mutex
+	results map[[3]string]idempotentResult
+}
+
+type idempotentResult struct {
+	result string
+	at     time.Time
+}
+
+// IdempotentResult returns the result recorded for key by tool within ttl
+func (j *JSONLStorage) IdempotentResult(tool, key string, ttl time.Duration) (string, bool, error) {
+	j.idempotency.mu.Lock()
+	defer j.idempotency.mu.Unlock()
+
+	recorded, ok := j.idempotency.results[[3]string{j.config.namespace(), tool, key}]
+	if !ok || time.Since(recorded.at) > ttl {
+		return "", false, nil
+	}
+	return recorded.result, true, nil
+}
+
+// RecordIdempotentResult records result for key by tool, dropping results older than ttl
+func (j *JSONLStorage) RecordIdempotentResult(tool, key, result string, ttl time.Duration) error {
+	j.idempotency.mu.Lock()
+	defer j.idempotency.mu.Unlock()
+
+	if j.idempotency.results == nil {
+		j.idempotency.results = make(map[[3]string]idempotentResult)
+	}
+	for k, recorded := range j.idempotency.results {
+		if time.Since(recorded.at) > ttl {
+			delete(j.idempotency.results, k)
+		}
+	}
+	j.idempotency.results[[3]string{j.config.namespace(), tool, key}] = idempotentResult{result: result, at: time.Now()}
+	return nil
+}
+
+// IdempotentResult returns the result recorded for key by tool within ttl
+func (s *SQLiteStorage) IdempotentResult(tool, key string, ttl time.Duration) (string, bool, error) {
+	var result string
+	err := s.rdb().QueryRow(
+		"SELECT result FROM idempotency_keys WHERE namespace = ? AND tool = ? AND key = ? AND created_at >= ?",
+		s.ns(), tool, key, time.Now().Add(-ttl).UnixMilli(),
+	).Scan(&result)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to query idempotency key: %w", err)
+	}
+	return result, true, nil
+}
+
+// RecordIdempotentResult records result for key by tool, dropping results older
+// than ttl. The keys are not part of the graph, so unlike retryWrite this leaves
+// the read cache, the JSONL mirror, and the analytics write count alone.
+func (s *SQLiteStorage) RecordIdempotentResult(tool, key, result string, ttl time.Duration) error {
+	retries, backoff := s.config.writeRetryPolicy()
+	return retryOnBusy(retries, backoff, func() error {
+		now := time.Now()
+		if _, err := s.db.Exec("DELETE FROM idempotency_keys WHERE created_at < ?", now.Add(-ttl).UnixMilli()); err != nil {
+			return fmt.Errorf("failed to expire idempotency keys: %w", err)
+		}
+		_, err := s.db.Exec(
+			"INSERT OR REPLACE INTO idempotency_keys (namespace, tool, key, result, created_at) VALUES (?, ?, ?, ?, ?)",
+			s.ns(), tool, key, result, now.UnixMilli(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to record idempotency key: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strings"
+)
+
+// idSource resolves the IDs Config.ExposeIDs adds to read results
+type idSource struct {
+	entity   func(name string) int64
+	relation func(r Relation) int64
+}
+
+// attach fills in the IDs of the entities and relations in result, which is
+// a *KnowledgeGraph, *GraphSummary, or *SearchResult
+func (ids idSource) attach(result any) {
+	switch r := result.(type) {
+	case *KnowledgeGraph:
+		for i := range r.Entities {
+			r.Entities[i].ID = ids.entity(r.Entities[i].Name)
+		}
+		for i := range r.Relations {
+			relation := &r.Relations[i]
+			relation.ID = ids.relation(*relation)
+			relation.FromID = ids.entity(relation.From)
+			relation.ToID = ids.entity(relation.To)
+		}
+	case *GraphSummary:
+		for i := range r.Entities {
+			r.Entities[i].ID = ids.entity(r.Entities[i].Name)
+		}
+	case *SearchResult:
+		for i := range r.Entities {
+			r.Entities[i].ID = ids.entity(r.Entities[i].Name)
+		}
+	}
+}
+
+// resultNames returns the names of the entities in result and the endpoints
+// of its relations
+func resultNames(result any) []string {
+	var names []string
+	switch r := result.(type) {
+	case *KnowledgeGraph:
+		for _, e := range r.Entities {
+			names = append(names, e.Name)
+		}
+		for _, rel := range r.Relations {
+			names = append(names, rel.From, rel.To)
+		}
+	case *GraphSummary:
+		for _, e := range r.Entities {
+			names = append(names, e.Name)
+		}
+	case *SearchResult:
+		for _, e := range r.Entities {
+			names = append(names, e.Name)
+		}
+	}
+	return names
+}
+
+// stableID synthesizes an ID for JSONL storage, which has none, from the FNV-1a
+// hash of parts. It is masked to 53 bits so JavaScript clients read it exactly.
+func stableID(parts ...string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(strings.Join(parts, "\x00")))
+	return int64(h.Sum64() & (1<<53 - 1))
+}
+
+// exposeIDs adds stable hashed IDs to result when ExposeIDs is set
+func (j *JSONLStorage) exposeIDs(result any) {
+	if !j.config.ExposeIDs {
+		return
+	}
+	idSource{
+		entity:   func(name string) int64 { return stableID(name) },
+		relation: func(r Relation) int64 { return stableID(r.From, r.To, r.RelationType) },
+	}.attach(result)
+}
+
+// idLookupChunk is how many names one ID query binds, well below the
+// SQLITE_MAX_VARIABLE_NUMBER of older SQLite builds (999)
+const idLookupChunk = 500
+
+// exposeIDs adds the database row IDs to result when ExposeIDs is set
+func (s *SQLiteStorage) exposeIDs(result any) error {
+	if !s.config.ExposeIDs {
+		return nil
+	}
+	names := slices.Compact(slices.Sorted(slices.Values(resultNames(result))))
+	if len(names) == 0 {
+		return nil
+	}
+
+	entityIDs := make(map[string]int64, len(names))
+	if err := s.queryByNames("SELECT name, id FROM entities WHERE namespace = ? AND name IN (%s)", names, nil, func(rows *sql.Rows) error {
+		var name string
+		var id int64
+		if err := rows.Scan(&name, &id); err != nil {
+			return fmt.Errorf("failed to scan entity id: %w", err)
+		}
+		entityIDs[name] = id
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to query entity ids: %w", err)
+	}
+
+	relationIDs := make(map[Relation]int64)
+	if graph, ok := result.(*KnowledgeGraph); ok && len(graph.Relations) > 0 {
+		if err := s.queryByNames(`
+			SELECT r.id, f.name, t.name, r.relation_type
+			FROM relations r
+			JOIN entities f ON r.from_entity_id = f.id
+			JOIN entities t ON r.to_entity_id = t.id
+			WHERE f.namespace = ? AND f.name IN (%s)
+		`, names, nil, func(rows *sql.Rows) error {
+			var id int64
+			var r Relation
+			if err := rows.Scan(&id, &r.From, &r.To, &r.RelationType); err != nil {
+				return fmt.Errorf("failed to scan relation id: %w", err)
+			}
+			relationIDs[r] = id
+			return nil
+		}); err != nil {
+			return fmt.Errorf("failed to query relation ids: %w", err)
+		}
+	}
+
+	idSource{
+		entity:   func(name string) int64 { return entityIDs[name] },
+		relation: func(r Relation) int64 { return relationIDs[r.key()] },
+	}.attach(result)
+	return nil
+}
+
+// queryByNames runs query, whose first parameter is the namespace, whose %s
+// takes a list of name placeholders, and whose remaining parameters are extra,
+// over names idLookupChunk at a time, calling scan for every row
+func (s *SQLiteStorage) queryByNames(query string, names []string, extra []any, scan func(*sql.Rows) error) error {
+	for chunk := range slices.Chunk(names, idLookupChunk) {
+		args := []any{s.ns()}
+		for _, name := range chunk {
+			args = append(args, name)
+		}
+		args = append(args, extra...)
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(chunk)), ",")
+		rows, err := s.rdb().Query(fmt.Sprintf(query, placeholders), args...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			if err := scan(rows); err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
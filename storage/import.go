@@ -0,0 +1,131 @@
+package storage
+
+import "fmt"
+
+// importLookup answers questions about the existing store while planning an import
+type importLookup struct {
+	entity         func(name string) (*Entity, error) // nil when absent; observations uncapped
+	relationExists func(r Relation) (bool, error)
+
+	// containsObservation applies the store's dedup mode (Config.containsObservation)
+	containsObservation func(observations []string, content string) bool
+}
+
+// planImport compares graph with the existing store and returns the conflict report
+// together with the changes to write for conflictMode (nil in report mode). Entities
+// in the returned graph carry only what is new: their type and unseen observations.
+func planImport(graph *KnowledgeGraph, conflictMode string, lookup importLookup) (*ImportReport, *KnowledgeGraph, error) {
+	switch conflictMode {
+	case "":
+		conflictMode = ConflictModeOverwrite
+	case ConflictModeOverwrite, ConflictModeSkip, ConflictModeReport:
+	default:
+		return nil, nil, fmt.Errorf("%w: unknown conflictMode %q (use overwrite, skip, or report)", ErrInvalidArgument, conflictMode)
+	}
+
+	report := &ImportReport{Mode: conflictMode, Applied: conflictMode != ConflictModeReport}
+	changes := &KnowledgeGraph{Entities: []Entity{}, Relations: []Relation{}}
+	if graph == nil {
+		return report, changes, nil
+	}
+
+	// Names that will exist after the import, whether new or already stored
+	known := make(map[string]bool, len(graph.Entities))
+	for _, entity := range graph.Entities {
+		existing, err := lookup.entity(entity.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if existing == nil {
+			if !known[entity.Name] {
+				report.EntitiesCreated++
+			}
+			known[entity.Name] = true
+			var observations []string
+			for _, obs := range entity.Observations {
+				if !lookup.containsObservation(observations, obs) {
+					observations = append(observations, obs)
+				}
+			}
+			entity.Observations = observations
+			changes.Entities = append(changes.Entities, entity)
+			continue
+		}
+		known[entity.Name] = true
+
+		var added []string
+		for _, obs := range entity.Observations {
+			if !lookup.containsObservation(existing.Observations, obs) && !lookup.containsObservation(added, obs) {
+				added = append(added, obs)
+			}
+		}
+		typeChanged := entity.EntityType != existing.EntityType
+		describeChanged := entity.Description != "" && entity.Description != existing.Description
+		if typeChanged {
+			report.TypeConflicts = append(report.TypeConflicts, TypeConflict{
+				Name:         entity.Name,
+				ExistingType: existing.EntityType,
+				ImportedType: entity.EntityType,
+			})
+		}
+		if len(added) > 0 {
+			report.NewObservations = append(report.NewObservations, NewObservations{
+				EntityName:   entity.Name,
+				Observations: added,
+			})
+		}
+
+		switch {
+		case conflictMode == ConflictModeSkip:
+			report.EntitiesSkipped++
+		case typeChanged || describeChanged || len(added) > 0:
+			report.EntitiesUpdated++
+			changes.Entities = append(changes.Entities, Entity{
+				Name:               entity.Name,
+				EntityType:         entity.EntityType,
+				Description:        entity.Description,
+				Observations:       added,
+				ObservationSources: pruneSources(entity.ObservationSources, added),
+			})
+		}
+	}
+
+	seen := make(map[Relation]bool, len(graph.Relations))
+	for _, relation := range graph.Relations {
+		if seen[relation.key()] {
+			continue
+		}
+		seen[relation.key()] = true
+
+		for _, name := range []string{relation.From, relation.To} {
+			if known[name] {
+				continue
+			}
+			existing, err := lookup.entity(name)
+			if err != nil {
+				return nil, nil, err
+			}
+			known[name] = existing != nil
+		}
+		if !known[relation.From] || !known[relation.To] {
+			report.MissingEndpoints = append(report.MissingEndpoints, relation)
+			continue
+		}
+
+		exists, err := lookup.relationExists(relation)
+		if err != nil {
+			return nil, nil, err
+		}
+		if exists {
+			report.ExistingRelations = append(report.ExistingRelations, relation)
+			continue
+		}
+		report.RelationsCreated++
+		changes.Relations = append(changes.Relations, relation)
+	}
+
+	if !report.Applied {
+		return report, nil, nil
+	}
+	return report, changes, nil
+}
@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// IntegritySamples is the number of examples an IntegrityIssue keeps
+const IntegritySamples = 10
+
+// IntegrityIssue counts one kind of problem found by CheckIntegrity, with up
+// to IntegritySamples examples
+type IntegrityIssue struct {
+	Count   int      `json:"count"`
+	Samples []string `json:"samples"`
+}
+
+// add counts a problem, keeping sample when there is room for it
+func (i *IntegrityIssue) add(sample string) {
+	i.Count++
+	if len(i.Samples) < IntegritySamples {
+		i.Samples = append(i.Samples, sample)
+	}
+}
+
+// FTSIntegrity compares the SQLite full-text indexes with the tables they index
+type FTSIntegrity struct {
+	Entities            int    `json:"entities"`
+	EntitiesIndexed     int    `json:"entitiesIndexed"`
+	Observations        int    `json:"observations"`
+	ObservationsIndexed int    `json:"observationsIndexed"`
+	Drift               bool   `json:"drift"`           // the indexes disagree with the tables
+	Error               string `json:"error,omitempty"` // what FTS5's integrity-check reported
+}
+
+// IntegrityReport lists the problems CheckIntegrity found across the whole
+// store, every namespace included
+type IntegrityReport struct {
+	Backend              string         `json:"backend"`
+	DanglingRelations    IntegrityIssue `json:"danglingRelations"`    // relations to or from a missing entity
+	OrphanedObservations IntegrityIssue `json:"orphanedObservations"` // SQLite only: observations of a missing entity
+	DuplicateEntities    IntegrityIssue `json:"duplicateEntities"`    // JSONL only: names stored more than once in a namespace
+	FTS                  *FTSIntegrity  `json:"fts,omitempty"`        // SQLite only, when FTS is available
+	OK                   bool           `json:"ok"`
+}
+
+// newIntegrityReport returns an empty report for backend
+func newIntegrityReport(backend string) *IntegrityReport {
+	return &IntegrityReport{
+		Backend:              backend,
+		DanglingRelations:    IntegrityIssue{Samples: []string{}},
+		OrphanedObservations: IntegrityIssue{Samples: []string{}},
+		DuplicateEntities:    IntegrityIssue{Samples: []string{}},
+	}
+}
+
+// finish sets OK when the report found no problems
+func (r *IntegrityReport) finish() *IntegrityReport {
+	r.OK = r.DanglingRelations.Count == 0 && r.OrphanedObservations.Count == 0 &&
+		r.DuplicateEntities.Count == 0 && (r.FTS == nil || !r.FTS.Drift)
+	return r
+}
+
+// relationSample describes a relation for an IntegrityReport
+func relationSample(from, relationType, to string) string {
+	return fmt.Sprintf("%s -[%s]-> %s", from, relationType, to)
+}
+
+// inNamespace prefixes sample with its namespace unless it is the default one
+func inNamespace(ns, sample string) string {
+	if ns == DefaultNamespace {
+		return sample
+	}
+	return ns + ": " + sample
+}
+
+// CheckIntegrity reports relations whose endpoints are missing from their
+// namespace and entity names stored more than once in one
+func (j *JSONLStorage) CheckIntegrity() (*IntegrityReport, error) {
+	set, err := j.readSet()
+	if err != nil {
+		return nil, err
+	}
+
+	report := newIntegrityReport("jsonl")
+	for _, ns := range set.order {
+		graph := set.graphs[ns]
+		names := make(map[string]int, len(graph.Entities))
+		for _, entity := range graph.Entities {
+			names[entity.Name]++
+			if names[entity.Name] == 2 {
+				report.DuplicateEntities.add(inNamespace(ns, entity.Name))
+			}
+		}
+		for _, relation := range graph.Relations {
+			if names[relation.From] == 0 || names[relation.To] == 0 {
+				report.DanglingRelations.add(inNamespace(ns, relationSample(relation.From, relation.RelationType, relation.To)))
+			}
+		}
+	}
+	return report.finish(), nil
+}
+
+// CheckIntegrity reports relations and observations left pointing at deleted
+// entities, which foreign keys do not prevent unless they are enforced, and
+// whether the FTS indexes have drifted from the tables they index. Missing
+// entities show up in samples as their row ID, e.g. "#42".
+func (s *SQLiteStorage) CheckIntegrity() (*IntegrityReport, error) {
+	report := newIntegrityReport("sqlite")
+
+	rows, err := s.rdb().Query(`
+		SELECT r.relation_type,
+			COALESCE(f.name, '#' || r.from_entity_id), COALESCE(f.namespace, t.namespace, ''),
+			COALESCE(t.name, '#' || r.to_entity_id)
+		FROM relations r
+		LEFT JOIN entities f ON r.from_entity_id = f.id
+		LEFT JOIN entities t ON r.to_entity_id = t.id
+		WHERE f.id IS NULL OR t.id IS NULL
+		ORDER BY r.id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dangling relations: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var relationType, from, ns, to string
+		if err := rows.Scan(&relationType, &from, &ns, &to); err != nil {
+			return nil, fmt.Errorf("failed to scan dangling relation: %w", err)
+		}
+		report.DanglingRelations.add(inNamespace(ns, relationSample(from, relationType, to)))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating dangling relations: %w", err)
+	}
+
+	rows, err = s.rdb().Query(`
+		SELECT o.entity_id, o.content
+		FROM observations o
+		LEFT JOIN entities e ON o.entity_id = e.id
+		WHERE e.id IS NULL
+		ORDER BY o.id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query orphaned observations: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var entityID int64
+		var content string
+		if err := rows.Scan(&entityID, &content); err != nil {
+			return nil, fmt.Errorf("failed to scan orphaned observation: %w", err)
+		}
+		report.OrphanedObservations.add(fmt.Sprintf("#%d: %s", entityID, content))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating orphaned observations: %w", err)
+	}
+
+	if s.isFTSAvailable() {
+		if report.FTS, err = s.checkFTSIntegrity(); err != nil {
+			return nil, err
+		}
+	}
+	return report.finish(), nil
+}
+
+// checkFTSIntegrity counts the rows of the FTS indexes and their tables and
+// runs FTS5's integrity-check against the tables. The indexes use external
+// content, so counting the FTS tables themselves would count the content
+// tables; the _docsize shadow tables hold one row per indexed row instead.
+func (s *SQLiteStorage) checkFTSIntegrity() (*FTSIntegrity, error) {
+	fts := &FTSIntegrity{}
+	counts := []struct {
+		query string
+		count *int
+	}{
+		{"SELECT COUNT(*) FROM entities", &fts.Entities},
+		{"SELECT COUNT(*) FROM entities_fts_docsize", &fts.EntitiesIndexed},
+		{"SELECT COUNT(*) FROM observations", &fts.Observations},
+		{"SELECT COUNT(*) FROM observations_fts_docsize", &fts.ObservationsIndexed},
+	}
+	for _, c := range counts {
+		if err := s.rdb().QueryRow(c.query).Scan(c.count); err != nil {
+			return nil, fmt.Errorf("failed to count FTS rows: %w", err)
+		}
+	}
+
+	// A rank of 1 also checks the index against the content table. That can't
+	// work for observations_fts, whose entity_name column the observations table
+	// lacks, so its index is only checked for internal consistency. Problems are
+	// reported as errors rather than results.
+	var problems []error
+	checks := []struct {
+		table string
+		rank  int
+	}{{"entities_fts", 1}, {"observations_fts", 0}}
+	for _, c := range checks {
+		if _, err := s.db.Exec("INSERT INTO "+c.table+"("+c.table+", rank) VALUES('integrity-check', ?)", c.rank); err != nil {
+			problems = append(problems, fmt.Errorf("%s: %w", c.table, err))
+		}
+	}
+	if err := errors.Join(problems...); err != nil {
+		fts.Error = err.Error()
+	}
+	fts.Drift = fts.Error != "" || fts.Entities != fts.EntitiesIndexed || fts.Observations != fts.ObservationsIndexed
+	return fts, nil
+}
@@ -2,21 +2,102 @@ package storage
 
 import (
 	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 )
 
+// DefaultMaxObservationsPerEntity is the per-entity observation cap applied to
+// query responses (open_nodes, read_graph full) when Config leaves it unset
+const DefaultMaxObservationsPerEntity = 100
+
+// Observation dedup modes for Config.ObservationDedup
+const (
+	DedupExact      = "exact"      // observations must match byte for byte to be duplicates (default)
+	DedupNormalized = "normalized" // case and whitespace differences are ignored
+)
+
+// Defaults for retrying SQLite writes that fail because the database is locked
+const (
+	DefaultWriteRetries = 3
+	DefaultRetryBackoff = 50 * time.Millisecond
+)
+
+// DefaultNamespace holds every graph written without an explicit namespace,
+// including all data from stores created before namespaces existed
+const DefaultNamespace = "default"
+
+// namespacePattern limits namespaces to short, file- and URL-safe names
+var namespacePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// ValidateNamespace checks that ns is a usable namespace name
+func ValidateNamespace(ns string) error {
+	if !namespacePattern.MatchString(ns) {
+		return fmt.Errorf("%w: namespace %q must be 1-64 letters, digits, '.', '_' or '-'", ErrInvalidArgument, ns)
+	}
+	return nil
+}
+
 // Entity represents a node in the knowledge graph
 type Entity struct {
-	Name         string   `json:"name"`
-	EntityType   string   `json:"entityType"`
-	Observations []string `json:"observations"`
+	ID                int64    `json:"id,omitempty"` // set only with Config.ExposeIDs
+	Name              string   `json:"name"`
+	EntityType        string   `json:"entityType"`
+	Description       string   `json:"description,omitempty"` // optional one-line summary, searched like observations
+	Observations      []string `json:"observations"`
+	ObservationsTotal int      `json:"observationsTotal,omitempty"` // set only when observations were capped
+
+	// ObservationSources maps observation content to who added it. Only attributed
+	// observations appear; it is filled by detailed reads (open_nodes, read_graph full).
+	ObservationSources map[string]string `json:"observationSources,omitempty"`
+
+	// ObservationTimes maps observation content to when it was added. Only JSONL
+	// storage keeps it, to answer ObservationsInRange; it is never shown.
+	ObservationTimes map[string]time.Time `json:"-"`
+
+	// UpdateTime is when JSONL storage last saw the entity or its observations
+	// change, kept to answer EntitiesModifiedSince; it is never shown. It is
+	// zero for entities loaded from files written before it was recorded.
+	UpdateTime time.Time `json:"-"`
+
+	// UpdatedAt is when the entity or its observations last changed. Only
+	// RecentlyModified and EntitiesModifiedSince set it.
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
 }
 
-// Relation represents an edge between entities
+// Relation represents an edge between entities. From, To, and RelationType
+// identify it; compare relations by key so CreatedAt does not matter.
 type Relation struct {
-	From         string `json:"from"`
-	To           string `json:"to"`
-	RelationType string `json:"relationType"`
+	From         string     `json:"from"`
+	To           string     `json:"to"`
+	RelationType string     `json:"relationType"`
+	CreatedAt    *time.Time `json:"createdAt,omitempty"` // when the relation was recorded, if known
+
+	// Set only when open_nodes is asked for neighbor types
+	FromType string `json:"fromType,omitempty"`
+	ToType   string `json:"toType,omitempty"`
+
+	// Set only with Config.ExposeIDs
+	ID     int64 `json:"id,omitempty"`
+	FromID int64 `json:"fromId,omitempty"`
+	ToID   int64 `json:"toId,omitempty"`
+}
+
+// key returns the relation without metadata, for comparing and deduplicating
+func (r Relation) key() Relation {
+	return Relation{From: r.From, To: r.To, RelationType: r.RelationType}
+}
+
+// SortRelationsByRecency orders relations newest first; relations without a
+// creation time go last, keeping their order
+func SortRelationsByRecency(relations []Relation) {
+	sort.SliceStable(relations, func(a, b int) bool {
+		ta, tb := relations[a].CreatedAt, relations[b].CreatedAt
+		return ta != nil && (tb == nil || ta.After(*tb))
+	})
 }
 
 // KnowledgeGraph represents the entire graph structure
@@ -26,27 +107,86 @@ type KnowledgeGraph struct {
 	Truncated bool       `json:"truncated,omitempty"` // true if any data was truncated
 }
 
-// ObservationDeletion specifies which observations to delete
+// ObservationDeletion specifies which observations to delete: those equal to
+// one of Observations, containing one of Contains, or at one of Indexes
 type ObservationDeletion struct {
 	EntityName   string   `json:"entityName"`
 	Observations []string `json:"observations"`
+	Contains     []string `json:"contains,omitempty"` // case-sensitive substrings
+	Indexes      []int    `json:"indexes,omitempty"`  // 0-based, oldest observation first; out of range matches nothing
+}
+
+// validateObservationDeletions rejects empty substrings, which would match
+// every observation, and negative indexes
+func validateObservationDeletions(deletions []ObservationDeletion) error {
+	for _, deletion := range deletions {
+		if slices.Contains(deletion.Contains, "") {
+			return fmt.Errorf("%w: contains must not include an empty string (entity %q)", ErrInvalidArgument, deletion.EntityName)
+		}
+		if slices.ContainsFunc(deletion.Indexes, func(i int) bool { return i < 0 }) {
+			return fmt.Errorf("%w: indexes must not be negative (entity %q)", ErrInvalidArgument, deletion.EntityName)
+		}
+	}
+	return nil
+}
+
+// matches reports whether the observation at index, oldest first, is one d deletes
+func (d ObservationDeletion) matches(index int, observation string) bool {
+	return slices.Contains(d.Observations, observation) ||
+		slices.ContainsFunc(d.Contains, func(s string) bool { return strings.Contains(observation, s) }) ||
+		slices.Contains(d.Indexes, index)
 }
 
 // EntitySummary is a lightweight entity representation for list results
 type EntitySummary struct {
+	ID         int64  `json:"id,omitempty"` // set only with Config.ExposeIDs
 	Name       string `json:"name"`
 	EntityType string `json:"entityType"`
 }
 
 // EntitySearchHit represents a search result with preview snippets
 type EntitySearchHit struct {
+	ID                int64    `json:"id,omitempty"` // set only with Config.ExposeIDs
 	Name              string   `json:"name"`
 	EntityType        string   `json:"entityType"`
-	Snippets          []string `json:"snippets"`          // matched observation snippets (max 2)
+	Description       string   `json:"description,omitempty"`
+	Snippets          []string `json:"snippets"`          // matched observation snippets (see snippetCap)
 	ObservationsCount int      `json:"observationsCount"` // total observations count
 	RelationsCount    int      `json:"relationsCount"`    // related relations count
 }
 
+// DefaultMaxSnippets is how many matched observations a search hit carries when
+// the search has a limit and no snippet cap is given
+const DefaultMaxSnippets = 2
+
+// snippetCap resolves the per-hit snippet cap for a search: maxSnippets when
+// positive, otherwise DefaultMaxSnippets, or 0 (no cap) for unlimited searches
+func snippetCap(limit, maxSnippets int) int {
+	switch {
+	case maxSnippets > 0:
+		return maxSnippets
+	case limit == 0:
+		return 0
+	default:
+		return DefaultMaxSnippets
+	}
+}
+
+// searchTerms splits a search query into the terms it matches any of: its
+// whitespace-separated words, except that a "double-quoted phrase" is a single
+// term matched as a whole. An unclosed quote runs to the end of the query.
+func searchTerms(query string) []string {
+	var terms []string
+	for i, part := range strings.Split(query, `"`) {
+		if i%2 == 0 {
+			terms = append(terms, strings.Fields(part)...)
+		} else if phrase := strings.Join(strings.Fields(part), " "); phrase != "" {
+			terms = append(terms, phrase)
+		}
+	}
+	return terms
+}
+
 // RelatedHit represents an entity related to a search hit via graph traversal
 type RelatedHit struct {
 	Name         string `json:"name"`
@@ -63,6 +203,36 @@ type SearchResult struct {
 	Total           int               `json:"total"`
 	Limit           int               `json:"limit"`
 	HasMore         bool              `json:"hasMore"`
+
+	// Set instead of any hits when more entities match than Config.MaxSearchMatches allows
+	TooManyResults bool   `json:"tooManyResults,omitempty"`
+	Message        string `json:"message,omitempty"`
+}
+
+// DefaultMaxSearchMatches is the --max-search-matches default: searches
+// returning more hits than this report only their total
+const DefaultMaxSearchMatches = 1000
+
+// tooManyMatches returns the result a search gives instead of its hits when it
+// would return more of them than MaxSearchMatches allows, or nil. Only limit
+// hits of the total are returned, so a small enough limit always passes.
+func (c Config) tooManyMatches(total, limit int) *SearchResult {
+	returned := total
+	if limit > 0 && limit < total {
+		returned = limit
+	}
+	if c.MaxSearchMatches <= 0 || returned <= c.MaxSearchMatches {
+		return nil
+	}
+	return &SearchResult{
+		Entities:       []EntitySearchHit{},
+		Total:          total,
+		Limit:          limit,
+		HasMore:        true,
+		TooManyResults: true,
+		Message: fmt.Sprintf("%d entities match, more than the %d a search returns at once; refine the query or pass a limit of at most %d",
+			total, c.MaxSearchMatches, c.MaxSearchMatches),
+	}
 }
 
 // GraphSummary holds a lightweight summary of the entire graph
@@ -73,12 +243,272 @@ type GraphSummary struct {
 	EntityTypes    map[string]int `json:"entityTypes"`   // type -> count
 	RelationTypes  map[string]int `json:"relationTypes"` // type -> count
 
+	// TypeDescriptions documents the types of the namespace (SQLite only)
+	TypeDescriptions []TypeDescription `json:"typeDescriptions,omitempty"`
+
 	// Entity list (limited)
 	Entities []EntitySummary `json:"entities"`
 	Limit    int             `json:"limit"`
 	HasMore  bool            `json:"hasMore"`
 }
 
+// TypeFilter restricts a read to entity types: Include (when non-empty) keeps only
+// those types and Exclude drops types. Relations are kept only between kept entities.
+type TypeFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// ListOptions controls sorting, filtering, and paging for ListEntities
+type ListOptions struct {
+	EntityType          string // only list entities of this type (empty = all types)
+	Sort                string // "name" (default), "created", or "updated"
+	Order               string // "asc" (default) or "desc"
+	Limit               int    // max entities to return (0 = no limit)
+	Offset              int    // number of entities to skip
+	IncludeObservations bool   // include observations in returned entities
+}
+
+// EntityList holds a page of entities with pagination info
+type EntityList struct {
+	Entities []Entity `json:"entities"` // observations are null unless requested
+	Total    int      `json:"total"`
+	Limit    int      `json:"limit"`
+	Offset   int      `json:"offset"`
+	HasMore  bool     `json:"hasMore"`
+}
+
+// NameList holds a page of entity names in name order
+type NameList struct {
+	Names   []string `json:"names"`
+	Total   int      `json:"total"`
+	Limit   int      `json:"limit"`
+	Offset  int      `json:"offset"`
+	HasMore bool     `json:"hasMore"`
+}
+
+// RelationPage holds a page of relations in creation order
+type RelationPage struct {
+	Relations []Relation `json:"relations"`
+	Total     int        `json:"total"`
+	Limit     int        `json:"limit"`
+	Offset    int        `json:"offset"`
+	HasMore   bool       `json:"hasMore"`
+}
+
+// ObservationPage holds a page of an entity's observations in insertion order
+type ObservationPage struct {
+	EntityName   string   `json:"entityName"`
+	Observations []string `json:"observations"`
+	Total        int      `json:"total"`
+	Offset       int      `json:"offset"`
+	Limit        int      `json:"limit"`
+	HasMore      bool     `json:"hasMore"`
+}
+
+// PairCount is an ordered entity pair and the number of distinct relations from From to To
+type PairCount struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Count int    `json:"count"`
+}
+
+// ObservationCount is an entity and how many observations it has
+type ObservationCount struct {
+	Name       string `json:"name"`
+	EntityType string `json:"entityType"`
+	Count      int    `json:"observationCount"`
+}
+
+// ReachableEntity is an entity found by Reachable and the fewest hops it took to reach it
+type ReachableEntity struct {
+	Name       string `json:"name"`
+	EntityType string `json:"entityType"`
+	Hops       int    `json:"hops"`
+}
+
+// DefaultRecentLimit is how many entities RecentlyModified returns when no limit is given
+const DefaultRecentLimit = 10
+
+// Hop limits for Reachable
+const (
+	DefaultReachableHops = 3
+	MaxReachableHops     = 10
+)
+
+// Node limits for Closure
+const (
+	DefaultClosureNodes = 100
+	MaxClosureNodes     = 1000
+)
+
+// Limits for FindAllPaths
+const (
+	DefaultPathDepth = 4
+	MaxPathDepth     = 8
+	DefaultMaxPaths  = 20
+	MaxPaths         = 200
+)
+
+// Example limits for RelationTypeSamples
+const (
+	DefaultRelationSamples = 3
+	MaxRelationSamples     = 50
+)
+
+// ClosureResult is the transitive closure of an entity, nearest first
+type ClosureResult struct {
+	Start     string            `json:"start"`
+	Entities  []ReachableEntity `json:"entities"`  // hops is the path length from start
+	Truncated bool              `json:"truncated"` // the node limit cut the closure short
+}
+
+// ClearResult holds the number of items removed by Clear
+type ClearResult struct {
+	EntitiesRemoved     int    `json:"entitiesRemoved"`
+	ObservationsRemoved int    `json:"observationsRemoved"`
+	RelationsRemoved    int    `json:"relationsRemoved"`
+	BackupPath          string `json:"backupPath,omitempty"` // set when --auto-backup-dir is configured
+}
+
+// Merge strategies for CreateEntitiesWithStrategy when an entity already exists
+const (
+	MergeAppend  = "append"  // set the new type and add observations not already present (default)
+	MergeReplace = "replace" // set the new type and replace all observations
+	MergeKeep    = "keep"    // keep the type and observations; only fill them in where empty
+)
+
+// mergeDescription returns an existing entity's description after a create with
+// description merges into it: a new description replaces the old one, except
+// that MergeKeep only fills in a missing one
+func mergeDescription(existing, description, strategy string) string {
+	if description == "" || (strategy == MergeKeep && existing != "") {
+		return existing
+	}
+	return description
+}
+
+// validateEntityUpdate checks that an UpdateEntity call changes something
+func validateEntityUpdate(newName, newType string, description *string) error {
+	if newName == "" && newType == "" && description == nil {
+		return fmt.Errorf("%w: newName, newType, or description is required", ErrInvalidArgument)
+	}
+	return nil
+}
+
+// relationTypeAliases validates MergeRelationTypes arguments and returns the
+// aliases without blanks, repeats, or canonical itself
+func relationTypeAliases(canonical string, aliases []string) ([]string, error) {
+	if canonical == "" {
+		return nil, fmt.Errorf("%w: canonical relation type is required", ErrInvalidArgument)
+	}
+	var cleaned []string
+	for _, alias := range aliases {
+		if alias != "" && alias != canonical && !slices.Contains(cleaned, alias) {
+			cleaned = append(cleaned, alias)
+		}
+	}
+	if len(cleaned) == 0 {
+		return nil, fmt.Errorf("%w: at least one alias other than %q is required", ErrInvalidArgument, canonical)
+	}
+	return cleaned, nil
+}
+
+// mergeStrategy validates a merge strategy ("" = MergeAppend)
+func mergeStrategy(strategy string) (string, error) {
+	switch strategy {
+	case "":
+		return MergeAppend, nil
+	case MergeAppend, MergeReplace, MergeKeep:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("%w: unknown mergeStrategy %q (use append, replace, or keep)", ErrInvalidArgument, strategy)
+}
+
+// Observation orders for Config.ObservationOrder
+const (
+	ObservationOrderOldest = "oldest" // insertion order (default)
+	ObservationOrderNewest = "newest" // most recently added first
+)
+
+// ValidateObservationOrder checks an observationOrder argument ("" = ObservationOrderOldest)
+func ValidateObservationOrder(order string) error {
+	switch order {
+	case "", ObservationOrderOldest, ObservationOrderNewest:
+		return nil
+	}
+	return fmt.Errorf("%w: observationOrder %q must be oldest or newest", ErrInvalidArgument, order)
+}
+
+// Import conflict modes for ImportData
+const (
+	ConflictModeOverwrite = "overwrite" // overwrite entity types and append new observations (default)
+	ConflictModeSkip      = "skip"      // leave entities that already exist untouched
+	ConflictModeReport    = "report"    // change nothing, only report what overwrite would do
+)
+
+// TypeConflict is an imported entity whose type differs from the stored one
+type TypeConflict struct {
+	Name         string `json:"name"`
+	ExistingType string `json:"existingType"`
+	ImportedType string `json:"importedType"`
+}
+
+// NewObservations lists observations an import brings to an existing entity
+type NewObservations struct {
+	EntityName   string   `json:"entityName"`
+	Observations []string `json:"observations"`
+}
+
+// ImportReport describes the outcome of ImportData. Conflicts are listed in every
+// mode; in report mode the counts describe what an overwrite import would do.
+type ImportReport struct {
+	Mode             string `json:"mode"`
+	Applied          bool   `json:"applied"`
+	EntitiesCreated  int    `json:"entitiesCreated"`
+	EntitiesUpdated  int    `json:"entitiesUpdated"`
+	EntitiesSkipped  int    `json:"entitiesSkipped"`
+	RelationsCreated int    `json:"relationsCreated"`
+
+	TypeConflicts     []TypeConflict    `json:"typeConflicts,omitempty"`
+	NewObservations   []NewObservations `json:"newObservations,omitempty"`   // observations the import adds to existing entities
+	ExistingRelations []Relation        `json:"existingRelations,omitempty"` // relations already present
+	MissingEndpoints  []Relation        `json:"missingEndpoints,omitempty"`  // relations dropped because an endpoint doesn't exist
+}
+
+// Name collision modes for TransferEntities
+const (
+	TransferModeSkip      = "skip"      // leave entities that already exist in the target untouched (default)
+	TransferModeOverwrite = "overwrite" // replace the target entity's type and observations
+	TransferModeRename    = "rename"    // transfer under a free name such as "Alice (2)"
+)
+
+// TransferOptions controls how TransferEntities copies or moves entities
+type TransferOptions struct {
+	Mode      string // TransferModeSkip, TransferModeOverwrite, or TransferModeRename ("" = skip)
+	Relations bool   // also transfer relations whose other endpoint exists in the target
+	Move      bool   // delete the transferred entities (and their relations) from the source
+}
+
+// TransferredEntity is one entity copied or moved by TransferEntities
+type TransferredEntity struct {
+	Name        string `json:"name"`
+	TargetName  string `json:"targetName"` // differs from Name in rename mode
+	Overwritten bool   `json:"overwritten,omitempty"`
+}
+
+// TransferResult describes what TransferEntities did
+type TransferResult struct {
+	FromNamespace    string              `json:"fromNamespace"`
+	ToNamespace      string              `json:"toNamespace"`
+	Mode             string              `json:"mode"`
+	Moved            bool                `json:"moved"`
+	Transferred      []TransferredEntity `json:"transferred"`
+	Skipped          []string            `json:"skipped"` // already in the target (skip mode)
+	RelationsCopied  int                 `json:"relationsCopied"`
+	RelationsDropped []Relation          `json:"relationsDropped,omitempty"` // other endpoint missing in the target
+}
+
 // MergeResult holds the result of merging two entities
 type MergeResult struct {
 	MergedObservations int  `json:"mergedObservations"` // observations migrated to target
@@ -94,6 +524,28 @@ type Conflict struct {
 	Type         string `json:"type"` // "potential_duplicate" or "potential_contradiction"
 }
 
+// StorageInfo describes the active backend, for diagnosing where data lives
+type StorageInfo struct {
+	Backend       string `json:"backend"` // "sqlite" or "jsonl"
+	FilePath      string `json:"filePath"`
+	Namespace     string `json:"namespace"`
+	SchemaVersion string `json:"schemaVersion,omitempty"` // SQLite only
+	JournalMode   string `json:"journalMode,omitempty"`   // SQLite only
+	WALEnabled    bool   `json:"walEnabled"`
+	ForeignKeys   bool   `json:"foreignKeys,omitempty"` // SQLite only: deletes cascade to observations and relations
+	FTSAvailable  bool   `json:"ftsAvailable"`
+	Entities      int    `json:"entities"`  // in the namespace
+	Relations     int    `json:"relations"` // in the namespace
+
+	LoadWarnings []LoadWarning `json:"loadWarnings,omitempty"` // JSONL only: lines skipped when loading
+}
+
+// LoadWarning describes a JSONL line that could not be loaded
+type LoadWarning struct {
+	Line   int    `json:"line"` // 1-based
+	Reason string `json:"reason"`
+}
+
 // Storage defines the interface for knowledge graph persistence
 type Storage interface {
 	// Initialize sets up the storage backend
@@ -102,34 +554,142 @@ type Storage interface {
 	// Close cleans up resources
 	Close() error
 
+	// StorageInfo reports the backend type, file location, and capabilities
+	StorageInfo() (*StorageInfo, error)
+
+	// CheckIntegrity looks for dangling relations, orphaned observations,
+	// duplicate entities, and FTS index drift across the whole store
+	CheckIntegrity() (*IntegrityReport, error)
+
+	// Checkpoint copies the SQLite write-ahead log into the database and
+	// truncates it (SQLite only)
+	Checkpoint() (*CheckpointResult, error)
+
+	// Namespaces: every other operation is scoped to the storage's namespace.
+	// Idempotency keys: IdempotentResult returns the result recorded for key by
+	// tool within ttl, and RecordIdempotentResult records one, dropping any older
+	// than ttl. JSONL keeps them in memory only.
+	IdempotentResult(tool, key string, ttl time.Duration) (result string, found bool, err error)
+	RecordIdempotentResult(tool, key, result string, ttl time.Duration) error
+
+	// WithNamespace returns a view of the same store scoped to ns; it shares the
+	// underlying file or database, so only the original is initialized and closed.
+	WithNamespace(ns string) Storage
+	// WithObservationOrder returns a view like WithNamespace whose open_nodes and
+	// read_graph reads list observations in order (see Config.ObservationOrder)
+	WithObservationOrder(order string) Storage
+	Namespaces() ([]string, error) // sorted; includes the active namespace even if empty
+
+	// TransferEntities copies the named entities of this namespace into toNamespace,
+	// or moves them when opts.Move is set. It fails without changes if one is missing.
+	TransferEntities(names []string, toNamespace string, opts TransferOptions) (*TransferResult, error)
+
 	// Entity operations
-	CreateEntities(entities []Entity) ([]Entity, error)
-	DeleteEntities(names []string) error
+	CreateEntities(entities []Entity) ([]Entity, error) // same as CreateEntitiesWithStrategy with MergeAppend
+	CreateEntitiesWithStrategy(entities []Entity, strategy string) ([]Entity, error)
+	DeleteEntities(names []string) (int, error) // entities actually deleted
 
 	// Relation operations
 	CreateRelations(relations []Relation) ([]Relation, error)
-	DeleteRelations(relations []Relation) error
+	DeleteRelations(relations []Relation) (int, error)      // relations actually deleted
+	DeleteRelationsByType(relationType string) (int, error) // returns the number deleted
+	// MergeRelationTypes rewrites relations of the alias types to canonical,
+	// dropping any that then duplicate another, and returns how many relations
+	// were rewritten or dropped
+	MergeRelationTypes(canonical string, aliases []string) (int, error)
 
 	// Observation operations
-	AddObservations(observations map[string][]string) (map[string][]string, error)
-	DeleteObservations(deletions []ObservationDeletion) error
+	AddObservations(observations map[string][]string, source string) (map[string][]string, error) // source may be empty
+	DeleteObservations(deletions []ObservationDeletion) (map[string]int, error)                   // entity name -> observations deleted
 
 	// Query operations
-	ReadGraph(mode string, limit int) (interface{}, error) // mode: "summary" or "full"
+	ReadGraph(mode string, limit int, filter TypeFilter) (interface{}, error) // mode: "summary" or "full"
 	SearchNodes(query string, limit int) (*SearchResult, error)
+	SearchNodesWithSnippets(query string, limit, maxSnippets int) (*SearchResult, error) // maxSnippets 0 = see snippetCap
 	OpenNodes(names []string) (*KnowledgeGraph, error)
+	EntityTypes(names []string) (map[string]string, error) // name -> type; missing names are left out
+	ListEntities(opts ListOptions) (*EntityList, error)
+	ListEntityNames(limit, offset int) (*NameList, error)   // limit 0 = all
+	ListRelations(limit, offset int) (*RelationPage, error) // oldest first; limit 0 = all
+	RecentlyModified(limit int) ([]Entity, error)           // most recently updated first, without observations; limit 0 = DefaultRecentLimit
+	FindByObservation(content string) ([]Entity, error)
+	GetObservations(entityName string, offset, limit int) (*ObservationPage, error)
+	Traverse(from string, relationType string, direction string) ([]RelatedHit, error)                     // direction: "out", "in", or "both"
+	Reachable(start string, relationType string, maxHops int, direction string) ([]ReachableEntity, error) // nearest first; maxHops 0 = DefaultReachableHops
+	Closure(start string, relationType string, direction string, maxNodes int) (*ClosureResult, error)     // Reachable without a hop limit, capped at maxNodes (0 = DefaultClosureNodes)
+	// FindAllPaths returns up to maxPaths acyclic paths along outgoing relations
+	// from from to to, shortest first, each at most maxDepth relations long
+	// (0 = DefaultPathDepth and DefaultMaxPaths)
+	FindAllPaths(from, to string, maxDepth int, maxPaths int) ([][]Relation, error)
+	RelationExists(from, to, relationType string) (bool, error)
+	TopConnectedPairs(limit int) ([]PairCount, error) // most relations first
+	// EntitiesByObservationCount returns entities with between minCount and maxCount
+	// observations (maxCount < 0 = no upper bound), most first unless ascending,
+	// ties by name; limit 0 = all
+	EntitiesByObservationCount(minCount, maxCount, limit int, ascending bool) ([]ObservationCount, error)
+	SelfRelations() ([]Relation, error)      // relations from an entity to itself, by name then type
+	EmptyEntities() ([]EntitySummary, error) // entities without observations, by name
+	// RelationsAmong returns the relations whose endpoints are both in names,
+	// created at or before asOf (zero = any time; non-zero is SQLite only). It
+	// records no access stats.
+	RelationsAmong(names []string, asOf time.Time) ([]Relation, error)
+	// RelationsTouching returns the relations to or from any entity in names,
+	// and ObservationCounts how many observations each named entity has (missing
+	// names are left out). Neither records access stats.
+	RelationsTouching(names []string) ([]Relation, error)
+	ObservationCounts(names []string) (map[string]int, error)
+	// RelationTypeSamples returns up to limit example relations of every relation
+	// type, oldest first (0 = DefaultRelationSamples)
+	RelationTypeSamples(limit int) (map[string][]Relation, error)
+
+	// Point-in-time reads: only entities, observations, and relations created at or
+	// before asOf are visible. Deletions and edits are not undone (SQLite only).
+	ReadGraphAsOf(asOf time.Time, mode string, limit int, filter TypeFilter) (interface{}, error)
+	SearchNodesAsOf(asOf time.Time, query string, limit int) (*SearchResult, error)
+	// ObservationsInRange returns the observations of the namespace added between
+	// start and end inclusive (zero end = no upper bound), oldest first
+	ObservationsInRange(start, end time.Time) ([]ObservationWithEntity, error)
+	// EntitiesModifiedSince returns the entities of the namespace created or
+	// changed at or after since, with all their observations, oldest change
+	// first (SQLite only)
+	EntitiesModifiedSince(since time.Time) ([]Entity, error)
 
 	// Entity management operations
 	MergeEntities(sourceName, targetName string) (*MergeResult, error)
 	UpdateEntityType(name string, newType string) error
+	SetEntityType(name string, entityType string) (*Entity, error) // UpdateEntityType returning the updated entity
+	// UpdateEntity renames, retypes, and/or redescribes an entity at once; ""
+	// (nil for description) leaves a field as is and an empty description clears it
+	UpdateEntity(oldName, newName, newType string, description *string) (*Entity, error)
 	UpdateObservation(entityName string, oldContent string, newContent string) error
+	SetObservations(entityName string, observations []string) error
+	// ReplaceInObservations replaces old with new in every observation of the
+	// namespace and returns how many observations changed. An observation that
+	// becomes a duplicate of another on its entity is merged into it.
+	ReplaceInObservations(old, new string, caseSensitive bool) (int, error)
+
+	// Clear removes all entities, observations, and relations of the namespace
+	Clear() (*ClearResult, error)
 
 	// Conflict detection
 	DetectConflicts(entityName string) ([]Conflict, error)
 
+	// SetTypeDescription documents an entity or relation type (kind
+	// TypeKindEntity or TypeKindRelation) of the namespace; an empty description
+	// removes it. TypeDescriptions lists them, kind "" for both (SQLite only).
+	SetTypeDescription(kind, typeName, description string) error
+	TypeDescriptions(kind string) ([]TypeDescription, error)
+
+	// Analytics returns counts, type distributions, and the most connected
+	// entities of the namespace, cached on SQLite with Config.AnalyticsCache;
+	// RefreshAnalytics recomputes them and replaces the cache
+	Analytics() (*GraphAnalytics, error)
+	RefreshAnalytics() (*GraphAnalytics, error)
+
 	// Migration support
 	ExportData() (*KnowledgeGraph, error)
-	ImportData(graph *KnowledgeGraph) error
+	WalkGraph(onEntity func(Entity) error, onRelation func(Relation) error) error // every entity, then every relation; stops at the first callback error
+	ImportData(graph *KnowledgeGraph, conflictMode string) (*ImportReport, error)
 }
 
 // Config holds storage configuration
@@ -141,6 +701,422 @@ type Config struct {
 	WALMode        bool          // Enable WAL mode for SQLite
 	CacheSize      int           // SQLite cache size in pages
 	BusyTimeout    time.Duration // SQLite busy timeout
+
+	// MaxObservationsPerEntity caps observations returned per entity by queries
+	// (0 = DefaultMaxObservationsPerEntity, negative = no cap). Exports are never capped.
+	MaxObservationsPerEntity int
+
+	// MaxObservationsInRead further caps observations per entity in read_graph
+	// results, ending each cut list with "... (N more)", and caps search snippets
+	// per hit (0 = off). open_nodes still returns up to MaxObservationsPerEntity.
+	MaxObservationsInRead int
+
+	// MaxSearchMatches stops a search that would return more hits than this
+	// before building them: it returns only the total and a message asking to
+	// refine the query (0 = no limit)
+	MaxSearchMatches int
+
+	// EmptyQueryListsAll makes a search with an empty query list the
+	// namespace's entities in name order, up to its limit, instead of
+	// returning nothing
+	EmptyQueryListsAll bool
+
+	// WriteRetries is how many times a write is retried after SQLITE_BUSY/LOCKED
+	// (0 = DefaultWriteRetries, negative = no retries). RetryBackoff is the first
+	// delay, doubled on each retry (0 = DefaultRetryBackoff).
+	WriteRetries int
+	RetryBackoff time.Duration
+
+	// ObservationDedup decides when a new observation duplicates an existing one
+	// ("" or DedupExact, or DedupNormalized)
+	ObservationDedup string
+
+	// Namespace scopes all operations to one graph in the store ("" = DefaultNamespace)
+	Namespace string
+
+	// EntityTemplates maps an entity type to the observations a new entity of
+	// that type starts with when it is created without any
+	EntityTemplates map[string][]string
+
+	// ExposeIDs adds entity and relation IDs to read_graph, search_nodes, and
+	// open_nodes results: row IDs on SQLite, name hashes on JSONL
+	ExposeIDs bool
+
+	// CheckpointInterval is how often SQLite in WAL mode checkpoints and
+	// truncates its -wal file (0 = only on Close)
+	CheckpointInterval time.Duration
+
+	// MirrorJSONL, for SQLite, is a JSONL file rewritten with every namespace
+	// MirrorDelay (0 = DefaultMirrorDelay) after each change. It is a one-way
+	// copy: the database stays authoritative.
+	MirrorJSONL string
+	MirrorDelay time.Duration
+
+	// EncryptionKey, for JSONL, is a passphrase the memory file is encrypted
+	// with (AES-256-GCM). Empty leaves it plaintext. SQLite rejects it:
+	// modernc.org/sqlite has no SQLCipher.
+	EncryptionKey string
+
+	// FlushInterval, for JSONL, buffers changes in memory and writes the file
+	// at most this long after the first of them, and on Close (0 = write every
+	// change through). Reads see buffered changes.
+	FlushInterval time.Duration
+
+	// FileMode is the permission of the memory file, the SQLite database, and
+	// backups and exports (0 = 0644 for new files; existing files keep theirs)
+	FileMode os.FileMode
+
+	// ObservationOrder lists observations in OpenNodes and full ReadGraph
+	// results oldest first ("" or ObservationOrderOldest) or newest first
+	// (ObservationOrderNewest). Observation caps keep the first ones listed,
+	// so newest first keeps the most recent.
+	ObservationOrder string
+
+	// AnalyticsCache, for SQLite, keeps Analytics results in the metadata
+	// table, served until RefreshAnalytics or, with AnalyticsRefreshEvery set,
+	// until that many writes have been made since (0 = refresh only on demand)
+	AnalyticsCache        bool
+	AnalyticsRefreshEvery int
+
+	// ReadCacheSize, for SQLite, keeps this many OpenNodes and search results in
+	// an in-process LRU cache (0 = off). A write drops the searches of its
+	// namespace and the OpenNodes results involving the entities it touched;
+	// writes by other processes are not seen.
+	ReadCacheSize int
+
+	// DisableForeignKeys, for SQLite, leaves foreign key enforcement off, as
+	// SQLite does by default: deleting an entity row then leaves its
+	// observations and relations behind instead of cascading
+	DisableForeignKeys bool
+
+	// FTS configures SQLite full-text search (nil = enabled). With Enabled false
+	// a new database gets no FTS tables or triggers, and search uses LIKE
+	// matching even on a database that has them; they are left in place.
+	FTS *FTSConfig
+}
+
+// ftsEnabled reports whether the config leaves full-text search on
+func (c Config) ftsEnabled() bool {
+	return c.FTS == nil || c.FTS.Enabled
+}
+
+// openEntity reads one entity as open_nodes shows it
+func openEntity(store Storage, name string) (*Entity, error) {
+	graph, err := store.OpenNodes([]string{name})
+	if err != nil {
+		return nil, err
+	}
+	for i := range graph.Entities {
+		if graph.Entities[i].Name == name {
+			return &graph.Entities[i], nil
+		}
+	}
+	return nil, entityNotFound(name)
+}
+
+// traverseDirections validates a Traverse direction, returning whether to follow
+// outgoing and incoming relations ("" defaults to "out")
+func traverseDirections(direction string) (out bool, in bool, err error) {
+	switch direction {
+	case "", "out":
+		return true, false, nil
+	case "in":
+		return false, true, nil
+	case "both":
+		return true, true, nil
+	default:
+		return false, false, fmt.Errorf("%w: direction %q must be out, in, or both", ErrInvalidArgument, direction)
+	}
+}
+
+// reachableHops validates a Reachable hop limit (0 = DefaultReachableHops)
+func reachableHops(maxHops int) (int, error) {
+	switch {
+	case maxHops == 0:
+		return DefaultReachableHops, nil
+	case maxHops < 0 || maxHops > MaxReachableHops:
+		return 0, fmt.Errorf("%w: maxHops must be between 1 and %d", ErrInvalidArgument, MaxReachableHops)
+	}
+	return maxHops, nil
+}
+
+// validateCountRange checks an EntitiesByObservationCount range
+func validateCountRange(minCount, maxCount int) error {
+	if minCount < 0 {
+		return fmt.Errorf("%w: minCount must not be negative", ErrInvalidArgument)
+	}
+	if maxCount >= 0 && maxCount < minCount {
+		return fmt.Errorf("%w: maxCount %d is below minCount %d", ErrInvalidArgument, maxCount, minCount)
+	}
+	return nil
+}
+
+// closureNodes validates a Closure node limit (0 = DefaultClosureNodes)
+func closureNodes(maxNodes int) (int, error) {
+	switch {
+	case maxNodes == 0:
+		return DefaultClosureNodes, nil
+	case maxNodes < 0 || maxNodes > MaxClosureNodes:
+		return 0, fmt.Errorf("%w: maxNodes must be between 1 and %d", ErrInvalidArgument, MaxClosureNodes)
+	}
+	return maxNodes, nil
+}
+
+// relationSamples validates a RelationTypeSamples limit (0 = DefaultRelationSamples)
+func relationSamples(limit int) (int, error) {
+	switch {
+	case limit == 0:
+		return DefaultRelationSamples, nil
+	case limit < 0 || limit > MaxRelationSamples:
+		return 0, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidArgument, MaxRelationSamples)
+	}
+	return limit, nil
+}
+
+// observationReplacer returns a function that replaces old with new in an
+// observation and reports whether anything changed
+func observationReplacer(old, new string, caseSensitive bool) (func(string) (string, bool), error) {
+	if old == "" {
+		return nil, fmt.Errorf("%w: old text must not be empty", ErrInvalidArgument)
+	}
+	if caseSensitive {
+		return func(content string) (string, bool) {
+			replaced := strings.ReplaceAll(content, old, new)
+			return replaced, replaced != content
+		}, nil
+	}
+	pattern := regexp.MustCompile("(?i)" + regexp.QuoteMeta(old))
+	return func(content string) (string, bool) {
+		replaced := pattern.ReplaceAllLiteralString(content, new)
+		return replaced, replaced != content
+	}, nil
+}
+
+// pathLimits validates FindAllPaths limits (0 = the defaults)
+func pathLimits(maxDepth, maxPaths int) (int, int, error) {
+	switch {
+	case maxDepth == 0:
+		maxDepth = DefaultPathDepth
+	case maxDepth < 0 || maxDepth > MaxPathDepth:
+		return 0, 0, fmt.Errorf("%w: maxDepth must be between 1 and %d", ErrInvalidArgument, MaxPathDepth)
+	}
+	switch {
+	case maxPaths == 0:
+		maxPaths = DefaultMaxPaths
+	case maxPaths < 0 || maxPaths > MaxPaths:
+		return 0, 0, fmt.Errorf("%w: maxPaths must be between 1 and %d", ErrInvalidArgument, MaxPaths)
+	}
+	return maxDepth, maxPaths, nil
+}
+
+// namespace returns the effective namespace
+func (c Config) namespace() string {
+	if c.Namespace == "" {
+		return DefaultNamespace
+	}
+	return c.Namespace
+}
+
+// observationCap returns the effective per-entity observation cap (0 = no cap)
+func (c Config) observationCap() int {
+	switch {
+	case c.MaxObservationsPerEntity == 0:
+		return DefaultMaxObservationsPerEntity
+	case c.MaxObservationsPerEntity < 0:
+		return 0
+	default:
+		return c.MaxObservationsPerEntity
+	}
+}
+
+// observationKey returns the form observations are compared by for deduplication
+func (c Config) observationKey(content string) string {
+	if c.ObservationDedup == DedupNormalized {
+		return strings.ToLower(strings.Join(strings.Fields(content), " "))
+	}
+	return content
+}
+
+// containsObservation reports whether observations already holds a duplicate of content
+func (c Config) containsObservation(observations []string, content string) bool {
+	key := c.observationKey(content)
+	return slices.ContainsFunc(observations, func(obs string) bool {
+		return c.observationKey(obs) == key
+	})
+}
+
+// templateObservations returns the observations a new entity starts with: its
+// own, or a copy of its type's template when it has none
+func (c Config) templateObservations(entity Entity) []string {
+	if len(entity.Observations) > 0 {
+		return entity.Observations
+	}
+	return slices.Clone(c.EntityTemplates[entity.EntityType])
+}
+
+// writeRetryPolicy returns the effective retry count and initial backoff for writes
+func (c Config) writeRetryPolicy() (retries int, backoff time.Duration) {
+	retries, backoff = c.WriteRetries, c.RetryBackoff
+	switch {
+	case retries == 0:
+		retries = DefaultWriteRetries
+	case retries < 0:
+		retries = 0
+	}
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+	return retries, backoff
+}
+
+// isZero reports whether the filter keeps every entity
+func (f TypeFilter) isZero() bool {
+	return len(f.Include) == 0 && len(f.Exclude) == 0
+}
+
+// allows reports whether entities of entityType pass the filter
+func (f TypeFilter) allows(entityType string) bool {
+	if len(f.Include) > 0 && !slices.Contains(f.Include, entityType) {
+		return false
+	}
+	return !slices.Contains(f.Exclude, entityType)
+}
+
+// apply returns the part of graph whose entities pass the filter, with relations
+// pruned to those between remaining entities
+func (f TypeFilter) apply(graph *KnowledgeGraph) *KnowledgeGraph {
+	if f.isZero() {
+		return graph
+	}
+
+	filtered := &KnowledgeGraph{Entities: []Entity{}, Relations: []Relation{}}
+	kept := make(map[string]bool)
+	for _, entity := range graph.Entities {
+		if f.allows(entity.EntityType) {
+			kept[entity.Name] = true
+			filtered.Entities = append(filtered.Entities, entity)
+		}
+	}
+	for _, relation := range graph.Relations {
+		if kept[relation.From] && kept[relation.To] {
+			filtered.Relations = append(filtered.Relations, relation)
+		}
+	}
+	return filtered
+}
+
+// sqlCondition returns a SQL condition on the given entity_type column, and its
+// arguments, matching the filter ("1 = 1" when the filter is empty)
+func (f TypeFilter) sqlCondition(column string) (string, []interface{}) {
+	conditions := []string{}
+	args := []interface{}{}
+	for _, part := range []struct {
+		op    string
+		types []string
+	}{{"IN", f.Include}, {"NOT IN", f.Exclude}} {
+		if len(part.types) == 0 {
+			continue
+		}
+		placeholders := make([]string, len(part.types))
+		for i, t := range part.types {
+			placeholders[i] = "?"
+			args = append(args, t)
+		}
+		conditions = append(conditions, fmt.Sprintf("%s %s (%s)", column, part.op, strings.Join(placeholders, ",")))
+	}
+	if len(conditions) == 0 {
+		return "1 = 1", args
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// readObservationCap returns the per-entity observation cap for read_graph: the
+// smaller of observationCap and MaxObservationsInRead (0 = no cap)
+func (c Config) readObservationCap() int {
+	max := c.observationCap()
+	if c.MaxObservationsInRead > 0 && (max == 0 || c.MaxObservationsInRead < max) {
+		return c.MaxObservationsInRead
+	}
+	return max
+}
+
+// searchSnippets applies MaxObservationsInRead to a search's maxSnippets, which
+// snippetCap then resolves
+func (c Config) searchSnippets(limit, maxSnippets int) int {
+	if c.MaxObservationsInRead <= 0 {
+		return maxSnippets
+	}
+	if n := snippetCap(limit, maxSnippets); n == 0 || n > c.MaxObservationsInRead {
+		return c.MaxObservationsInRead
+	}
+	return maxSnippets
+}
+
+// noteMoreObservations ends each capped observation list in a read_graph result
+// with "... (N more)" when MaxObservationsInRead is set
+func (c Config) noteMoreObservations(result any) {
+	graph, ok := result.(*KnowledgeGraph)
+	if !ok || c.MaxObservationsInRead <= 0 {
+		return
+	}
+	for i := range graph.Entities {
+		entity := &graph.Entities[i]
+		if more := entity.ObservationsTotal - len(entity.Observations); more > 0 {
+			entity.Observations = append(entity.Observations[:len(entity.Observations):len(entity.Observations)], fmt.Sprintf("... (%d more)", more))
+		}
+	}
+}
+
+// newestFirst reports whether reads list observations newest first
+func (c Config) newestFirst() bool {
+	return c.ObservationOrder == ObservationOrderNewest
+}
+
+// orderObservations returns entity with its observations, kept in insertion
+// order, listed in ObservationOrder
+func (c Config) orderObservations(entity Entity) Entity {
+	if c.newestFirst() {
+		entity.Observations = slices.Clone(entity.Observations)
+		slices.Reverse(entity.Observations)
+	}
+	return entity
+}
+
+// capObservations returns a copy of entity with at most max observations (0 = no cap),
+// recording the original count in ObservationsTotal when truncated
+func capObservations(entity Entity, max int) (Entity, bool) {
+	if max <= 0 || len(entity.Observations) <= max {
+		return entity, false
+	}
+	entity.ObservationsTotal = len(entity.Observations)
+	entity.Observations = entity.Observations[:max]
+	entity.ObservationSources = pruneSources(entity.ObservationSources, entity.Observations)
+	return entity, true
+}
+
+// pruneSources returns the entries of sources whose observation is still present
+// (nil when none remain)
+func pruneSources(sources map[string]string, observations []string) map[string]string {
+	return pruneObservationMap(sources, observations)
+}
+
+// pruneObservationMap returns the non-zero entries of m whose observation is
+// still present (nil when none remain)
+func pruneObservationMap[V comparable](m map[string]V, observations []string) map[string]V {
+	if len(m) == 0 {
+		return nil
+	}
+	var zero V
+	pruned := make(map[string]V)
+	for _, obs := range observations {
+		if v := m[obs]; v != zero {
+			pruned[obs] = v
+		}
+	}
+	if len(pruned) == 0 {
+		return nil
+	}
+	return pruned
 }
 
 // Factory creates storage instances based on configuration
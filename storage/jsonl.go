@@ -3,20 +3,31 @@ package storage
 import (
 	"encoding/json"
 	"fmt"
+	"log"
+	"maps"
 	"os"
 	"path/filepath"
 	"slices"
+	"sort"
 	"strings"
+	"time"
 )
 
 // JSONLStorage implements Storage interface using JSONL file format
 type JSONLStorage struct {
-	config Config
+	config      Config
+	idempotency *idempotencyKeys // shared by namespace views; in memory only
+	cipher      *fileCipher      // from Config.EncryptionKey; nil when unencrypted
+	buffer      *writeBuffer     // from Config.FlushInterval; shared by namespace views
 }
 
 // NewJSONLStorage creates a new JSONL storage instance
 func NewJSONLStorage(config Config) (*JSONLStorage, error) {
-	return &JSONLStorage{config: config}, nil
+	j := &JSONLStorage{config: config, idempotency: &idempotencyKeys{}, cipher: newFileCipher(config.EncryptionKey)}
+	j.buffer = newWriteBuffer(config.FlushInterval, func(set *graphSet) error {
+		return writeGraphSet(j.config.FilePath, set, j.fileOptions())
+	})
+	return j, nil
 }
 
 // Initialize prepares the JSONL storage
@@ -31,119 +42,400 @@ func (j *JSONLStorage) Initialize() error {
 
 	// Create file if it doesn't exist
 	if _, err := os.Stat(j.config.FilePath); os.IsNotExist(err) {
-		file, err := os.Create(j.config.FilePath)
-		if err != nil {
-			return fmt.Errorf("failed to create file: %w", err)
-		}
-		file.Close()
+		return prepareFile(j.config.FilePath, j.config.FileMode)
+	} else if err := prepareFile(j.config.FilePath, j.config.FileMode); err != nil {
+		return err
 	}
 
-	return nil
+	// Report lines that can't be loaded now rather than as missing data later
+	set, err := j.readSet()
+	if err != nil {
+		return err
+	}
+	logLoadWarnings(j.config.FilePath, set.warnings)
+	return j.encryptPlaintext()
+}
+
+// maxLoggedWarnings caps how many skipped lines logLoadWarnings lists
+const maxLoggedWarnings = 10
+
+// logLoadWarnings logs the lines of path that were skipped when loading it
+func logLoadWarnings(path string, warnings []LoadWarning) {
+	if len(warnings) == 0 {
+		return
+	}
+	log.Printf("%s: skipped %d malformed line(s); they will be dropped the next time the file is saved (run validate_file for details)", path, len(warnings))
+	for i, w := range warnings {
+		if i == maxLoggedWarnings {
+			log.Printf("  ... and %d more", len(warnings)-maxLoggedWarnings)
+			break
+		}
+		log.Printf("  line %d: %s", w.Line, w.Reason)
+	}
 }
 
 // Close cleans up resources
 func (j *JSONLStorage) Close() error {
-	// No resources to clean up for file-based storage
+	// Buffered changes are the only state not yet on disk
+	if j.buffer != nil {
+		return j.buffer.flush()
+	}
 	return nil
 }
 
-// loadGraph loads the knowledge graph from JSONL file
+// StorageInfo reports the JSONL file location, the namespace's entity and
+// relation counts, and any lines skipped when loading it; JSONL has no schema,
+// WAL, or FTS
+func (j *JSONLStorage) StorageInfo() (*StorageInfo, error) {
+	path, err := filepath.Abs(j.config.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	set, err := j.readSet()
+	if err != nil {
+		return nil, err
+	}
+	graph := set.graph(j.config.namespace())
+	return &StorageInfo{
+		Backend:      "jsonl",
+		FilePath:     path,
+		Namespace:    j.config.namespace(),
+		Entities:     len(graph.Entities),
+		Relations:    len(graph.Relations),
+		LoadWarnings: set.warnings,
+	}, nil
+}
+
+// WithNamespace returns a view of the same file scoped to ns
+func (j *JSONLStorage) WithNamespace(ns string) Storage {
+	config := j.config
+	config.Namespace = ns
+	return &JSONLStorage{config: config, idempotency: j.idempotency, cipher: j.cipher, buffer: j.buffer}
+}
+
+// WithObservationOrder returns a view of the same file listing observations in order
+func (j *JSONLStorage) WithObservationOrder(order string) Storage {
+	config := j.config
+	config.ObservationOrder = order
+	return &JSONLStorage{config: config, idempotency: j.idempotency, cipher: j.cipher, buffer: j.buffer}
+}
+
+// Namespaces lists the namespaces that hold entities or relations, plus the active one
+func (j *JSONLStorage) Namespaces() ([]string, error) {
+	set, err := j.readSet()
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces := []string{j.config.namespace()}
+	for _, ns := range set.order {
+		graph := set.graphs[ns]
+		if ns != j.config.namespace() && (len(graph.Entities) > 0 || len(graph.Relations) > 0) {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	slices.Sort(namespaces)
+	return namespaces, nil
+}
+
+// loadGraph loads the active namespace's graph from the JSONL file
 func (j *JSONLStorage) loadGraph() (*KnowledgeGraph, error) {
-	graph := &KnowledgeGraph{
-		Entities:  []Entity{},
-		Relations: []Relation{},
+	set, err := j.readSet()
+	if err != nil {
+		return nil, err
 	}
+	return set.graph(j.config.namespace()), nil
+}
 
-	// Check if file exists
-	if _, err := os.Stat(j.config.FilePath); os.IsNotExist(err) {
-		return graph, nil
+// graphSet holds the graph of every namespace in a JSONL file, in file order
+type graphSet struct {
+	order    []string
+	graphs   map[string]*KnowledgeGraph
+	warnings []LoadWarning // lines that were skipped
+	gzip     bool          // the file held gzip content, checked after decryption
+}
+
+// graph returns the graph of namespace ns, adding an empty one if it has none
+func (g *graphSet) graph(ns string) *KnowledgeGraph {
+	graph, ok := g.graphs[ns]
+	if !ok {
+		graph = &KnowledgeGraph{Entities: []Entity{}, Relations: []Relation{}}
+		g.graphs[ns] = graph
+		g.order = append(g.order, ns)
+	}
+	return graph
+}
+
+// readGraphSet reads every namespace from a JSONL file, decrypting it with
+// cipher when it is encrypted; a missing file is empty
+func readGraphSet(path string, cipher *fileCipher) (*graphSet, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return parseJSONLNamespaces(nil), nil
 	}
 
-	// Read file content
-	data, err := os.ReadFile(j.config.FilePath)
+	data, gzipped, err := readGraphContent(path, cipher)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file: %w", err)
+		return nil, err
 	}
+	set := parseJSONLNamespaces(data)
+	set.gzip = gzipped
+	return set, nil
+}
 
-	if len(data) == 0 {
-		return graph, nil
+// parseJSONL parses JSONL graph data, one entity or relation object per line,
+// flattening all namespaces into one graph.
+// Lines that are not valid entity/relation objects are skipped (see LoadWarning).
+func parseJSONL(data []byte) *KnowledgeGraph {
+	graph, _ := parseJSONLWithWarnings(data)
+	return graph
+}
+
+// parseJSONLWithWarnings is parseJSONL that also returns the skipped lines
+func parseJSONLWithWarnings(data []byte) (*KnowledgeGraph, []LoadWarning) {
+	set := parseJSONLNamespaces(data)
+	graph := &KnowledgeGraph{
+		Entities:  []Entity{},
+		Relations: []Relation{},
 	}
+	for _, ns := range set.order {
+		graph.Entities = append(graph.Entities, set.graphs[ns].Entities...)
+		graph.Relations = append(graph.Relations, set.graphs[ns].Relations...)
+	}
+	return graph, set.warnings
+}
+
+// parseJSONLNamespaces parses JSONL graph data into one graph per namespace.
+// Lines without a namespace belong to DefaultNamespace. Lines that cannot be
+// decoded are skipped and recorded in the set's warnings.
+func parseJSONLNamespaces(data []byte) *graphSet {
+	set := &graphSet{graphs: make(map[string]*KnowledgeGraph)}
 
 	// Parse line by line
 	lines := strings.Split(string(data), "\n")
-	for _, line := range lines {
+	for i, line := range lines {
 		line = strings.TrimSpace(line)
 		if line == "" {
 			continue
 		}
 
-		// First check the type field
-		var item map[string]interface{}
-		if err := json.Unmarshal([]byte(line), &item); err != nil {
-			continue
+		entity, relation, err := decodeJSONLLine([]byte(line))
+		switch {
+		case err != nil:
+			set.warnings = append(set.warnings, LoadWarning{Line: i + 1, Reason: err.Error()})
+		case entity != nil:
+			graph := set.graph(Config{Namespace: entity.Namespace}.namespace())
+			graph.Entities = append(graph.Entities, Entity{
+				Name:               entity.Name,
+				EntityType:         entity.EntityType,
+				Description:        entity.Description,
+				Observations:       entity.Observations,
+				ObservationSources: entity.ObservationSources,
+				ObservationTimes:   entity.ObservationTimes,
+			})
+			if entity.UpdatedAt != nil {
+				graph.Entities[len(graph.Entities)-1].UpdateTime = *entity.UpdatedAt
+			}
+		default:
+			graph := set.graph(Config{Namespace: relation.Namespace}.namespace())
+			graph.Relations = append(graph.Relations, Relation{
+				From:         relation.From,
+				To:           relation.To,
+				RelationType: relation.RelationType,
+				CreatedAt:    relation.CreatedAt,
+			})
 		}
+	}
 
-		itemType, ok := item["type"].(string)
-		if !ok {
-			continue
-		}
+	return set
+}
 
-		if itemType == "entity" {
-			var entity jsonlEntity
-			if err := json.Unmarshal([]byte(line), &entity); err == nil {
-				graph.Entities = append(graph.Entities, Entity{
-					Name:         entity.Name,
-					EntityType:   entity.EntityType,
-					Observations: entity.Observations,
-				})
-			}
-		} else if itemType == "relation" {
-			var relation jsonlRelation
-			if err := json.Unmarshal([]byte(line), &relation); err == nil {
-				graph.Relations = append(graph.Relations, Relation{
-					From:         relation.From,
-					To:           relation.To,
-					RelationType: relation.RelationType,
-				})
-			}
+// decodeJSONLLine decodes one non-blank line of a JSONL graph file into either
+// an entity or a relation, or explains why the line can't be loaded
+func decodeJSONLLine(line []byte) (*jsonlEntity, *jsonlRelation, error) {
+	// First check the type field
+	var item struct {
+		Type *string `json:"type"`
+	}
+	if err := json.Unmarshal(line, &item); err != nil {
+		return nil, nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if item.Type == nil {
+		return nil, nil, fmt.Errorf("missing \"type\" field")
+	}
+
+	switch *item.Type {
+	case "entity":
+		var entity jsonlEntity
+		if err := json.Unmarshal(line, &entity); err != nil {
+			return nil, nil, fmt.Errorf("invalid entity: %w", err)
 		}
+		return &entity, nil, nil
+	case "relation":
+		var relation jsonlRelation
+		if err := json.Unmarshal(line, &relation); err != nil {
+			return nil, nil, fmt.Errorf("invalid relation: %w", err)
+		}
+		return nil, &relation, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown type %q (expected entity or relation)", *item.Type)
 	}
+}
 
+// LoadGraphFile reads a knowledge graph from a file in either format: a single JSON
+// object ({"entities": [...], "relations": [...]}) or JSONL (one item per line)
+func LoadGraphFile(path string) (*KnowledgeGraph, error) {
+	data, err := readGraphFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	graph, _ := ParseGraph(data)
 	return graph, nil
 }
 
-// saveGraph saves the knowledge graph to JSONL file
+// ParseGraph parses uncompressed graph data in either format LoadGraphFile
+// reads. The warnings list the JSONL lines that were skipped.
+func ParseGraph(data []byte) (*KnowledgeGraph, []LoadWarning) {
+	// A JSONL file has several objects (or one with a "type" field), so it never
+	// unmarshals as a single graph object
+	var probe struct {
+		Type string `json:"type"`
+		KnowledgeGraph
+	}
+	if err := json.Unmarshal(data, &probe); err == nil && probe.Type == "" {
+		graph := &probe.KnowledgeGraph
+		if graph.Entities == nil {
+			graph.Entities = []Entity{}
+		}
+		if graph.Relations == nil {
+			graph.Relations = []Relation{}
+		}
+		return graph, nil
+	}
+
+	return parseJSONLWithWarnings(data)
+}
+
+// saveGraph replaces the active namespace's graph in the JSONL file, keeping
+// the other namespaces as they are
 func (j *JSONLStorage) saveGraph(graph *KnowledgeGraph) error {
-	var lines []string
+	set, err := j.readSet()
+	if err != nil {
+		return err
+	}
+	previous := set.graph(j.config.namespace())
+	now := time.Now().UTC()
+	stampObservations(graph, previous, now)
+	stampUpdates(graph, previous, now)
+	*previous = *graph
+	return j.writeSet(set)
+}
 
-	// Convert entities
-	for _, entity := range graph.Entities {
-		jsonEntity := jsonlEntity{
-			Type:         "entity",
-			Name:         entity.Name,
-			EntityType:   entity.EntityType,
-			Observations: entity.Observations,
+// stampObservations records now as the time of every observation in graph
+// that previous, the graph it replaces, did not hold, keeping the times it
+// already knows. Observations previous holds without a time, from files
+// written before times were recorded, stay untimed.
+func stampObservations(graph, previous *KnowledgeGraph, now time.Time) {
+	before := make(map[string]*Entity, len(previous.Entities))
+	for i := range previous.Entities {
+		before[previous.Entities[i].Name] = &previous.Entities[i]
+	}
+	for i := range graph.Entities {
+		entity := &graph.Entities[i]
+		var held map[string]bool
+		old := before[entity.Name]
+		if old != nil {
+			held = make(map[string]bool, len(old.Observations))
+			for _, obs := range old.Observations {
+				held[obs] = true
+			}
 		}
-		data, err := json.Marshal(jsonEntity)
-		if err != nil {
-			continue
+
+		times := make(map[string]time.Time, len(entity.Observations))
+		for _, obs := range entity.Observations {
+			if t, ok := entity.ObservationTimes[obs]; ok {
+				times[obs] = t
+			} else if old != nil && !old.ObservationTimes[obs].IsZero() {
+				times[obs] = old.ObservationTimes[obs]
+			} else if !held[obs] {
+				times[obs] = now
+			}
 		}
-		lines = append(lines, string(data))
+		entity.ObservationTimes = pruneObservationMap(times, entity.Observations)
 	}
+}
 
-	// Convert relations
-	for _, relation := range graph.Relations {
-		jsonRelation := jsonlRelation{
-			Type:         "relation",
-			From:         relation.From,
-			To:           relation.To,
-			RelationType: relation.RelationType,
+// stampUpdates records now as the update time of every entity in graph that is
+// new or differs from its version in previous in type, description, or
+// observations, and keeps the update time previous knows for the rest
+func stampUpdates(graph, previous *KnowledgeGraph, now time.Time) {
+	before := make(map[string]*Entity, len(previous.Entities))
+	for i := range previous.Entities {
+		before[previous.Entities[i].Name] = &previous.Entities[i]
+	}
+	for i := range graph.Entities {
+		entity := &graph.Entities[i]
+		old := before[entity.Name]
+		if old == nil || old.EntityType != entity.EntityType || old.Description != entity.Description ||
+			!slices.Equal(old.Observations, entity.Observations) {
+			entity.UpdateTime = now
+		} else {
+			entity.UpdateTime = old.UpdateTime
 		}
-		data, err := json.Marshal(jsonRelation)
-		if err != nil {
-			continue
+	}
+}
+
+// writeGraphSet writes every namespace to a JSONL file with opts (see
+// writeGraphFile), compressed when set was read from gzip content. Default
+// namespace lines carry no namespace field, so single-graph files keep their
+// original format.
+func writeGraphSet(path string, set *graphSet, opts fileOptions) error {
+	opts.gzip = opts.gzip || set.gzip
+	var lines []string
+
+	for _, ns := range set.order {
+		graph := set.graphs[ns]
+		if ns == DefaultNamespace {
+			ns = ""
+		}
+
+		// Convert entities
+		for _, entity := range graph.Entities {
+			jsonEntity := jsonlEntity{
+				Type:               "entity",
+				Namespace:          ns,
+				Name:               entity.Name,
+				EntityType:         entity.EntityType,
+				Description:        entity.Description,
+				Observations:       entity.Observations,
+				ObservationSources: pruneSources(entity.ObservationSources, entity.Observations),
+				ObservationTimes:   pruneObservationMap(entity.ObservationTimes, entity.Observations),
+			}
+			if !entity.UpdateTime.IsZero() {
+				jsonEntity.UpdatedAt = &entity.UpdateTime
+			}
+			data, err := json.Marshal(jsonEntity)
+			if err != nil {
+				continue
+			}
+			lines = append(lines, string(data))
+		}
+
+		// Convert relations
+		for _, relation := range graph.Relations {
+			jsonRelation := jsonlRelation{
+				Type:         "relation",
+				Namespace:    ns,
+				From:         relation.From,
+				To:           relation.To,
+				RelationType: relation.RelationType,
+				CreatedAt:    relation.CreatedAt,
+			}
+			data, err := json.Marshal(jsonRelation)
+			if err != nil {
+				continue
+			}
+			lines = append(lines, string(data))
 		}
-		lines = append(lines, string(data))
 	}
 
 	// Save to file
@@ -152,11 +444,20 @@ func (j *JSONLStorage) saveGraph(graph *KnowledgeGraph) error {
 		content += "\n"
 	}
 
-	return os.WriteFile(j.config.FilePath, []byte(content), 0644)
+	return writeGraphFile(path, []byte(content), opts)
 }
 
 // CreateEntities creates new entities
 func (j *JSONLStorage) CreateEntities(entities []Entity) ([]Entity, error) {
+	return j.CreateEntitiesWithStrategy(entities, MergeAppend)
+}
+
+// CreateEntitiesWithStrategy creates entities, merging into existing ones by strategy
+func (j *JSONLStorage) CreateEntitiesWithStrategy(entities []Entity, strategy string) ([]Entity, error) {
+	strategy, err := mergeStrategy(strategy)
+	if err != nil {
+		return nil, err
+	}
 	graph, err := j.loadGraph()
 	if err != nil {
 		return nil, err
@@ -169,12 +470,29 @@ func (j *JSONLStorage) CreateEntities(entities []Entity) ([]Entity, error) {
 		for i, e := range graph.Entities {
 			if e.Name == entity.Name {
 				exists = true
-				// Update entity type if changed
-				graph.Entities[i].EntityType = entity.EntityType
-				// Merge observations
+				existing := &graph.Entities[i]
+				switch {
+				case strategy != MergeKeep:
+					existing.EntityType = entity.EntityType
+				case existing.EntityType == "":
+					existing.EntityType = entity.EntityType
+				}
+				existing.Description = mergeDescription(existing.Description, entity.Description, strategy)
+				if strategy == MergeReplace {
+					existing.Observations = nil
+					existing.ObservationSources = nil
+				}
+				// Merge observations (keep only fills in an entity that has none)
+				if strategy == MergeKeep && len(existing.Observations) > 0 {
+					created = append(created, *existing)
+					break
+				}
 				for _, obs := range entity.Observations {
-					if !slices.Contains(graph.Entities[i].Observations, obs) {
+					if !j.config.containsObservation(graph.Entities[i].Observations, obs) {
 						graph.Entities[i].Observations = append(graph.Entities[i].Observations, obs)
+						if source := entity.ObservationSources[obs]; source != "" {
+							setSource(&graph.Entities[i], obs, source)
+						}
 					}
 				}
 				created = append(created, graph.Entities[i])
@@ -183,6 +501,16 @@ func (j *JSONLStorage) CreateEntities(entities []Entity) ([]Entity, error) {
 		}
 
 		if !exists {
+			entity.Observations = j.config.templateObservations(entity)
+			if j.config.ObservationDedup == DedupNormalized {
+				deduped := []string{}
+				for _, obs := range entity.Observations {
+					if !j.config.containsObservation(deduped, obs) {
+						deduped = append(deduped, obs)
+					}
+				}
+				entity.Observations = deduped
+			}
 			graph.Entities = append(graph.Entities, entity)
 			created = append(created, entity)
 		}
@@ -195,11 +523,11 @@ func (j *JSONLStorage) CreateEntities(entities []Entity) ([]Entity, error) {
 	return created, nil
 }
 
-// DeleteEntities deletes entities by name
-func (j *JSONLStorage) DeleteEntities(names []string) error {
+// DeleteEntities deletes entities by name and returns how many existed
+func (j *JSONLStorage) DeleteEntities(names []string) (int, error) {
 	graph, err := j.loadGraph()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// Create a set for quick lookup
@@ -215,6 +543,7 @@ func (j *JSONLStorage) DeleteEntities(names []string) error {
 			filteredEntities = append(filteredEntities, entity)
 		}
 	}
+	deleted := len(graph.Entities) - len(filteredEntities)
 	graph.Entities = filteredEntities
 
 	// Filter relations (remove those involving deleted entities)
@@ -226,7 +555,10 @@ func (j *JSONLStorage) DeleteEntities(names []string) error {
 	}
 	graph.Relations = filteredRelations
 
-	return j.saveGraph(graph)
+	if err := j.saveGraph(graph); err != nil {
+		return 0, err
+	}
+	return deleted, nil
 }
 
 // CreateRelations creates new relations
@@ -236,6 +568,7 @@ func (j *JSONLStorage) CreateRelations(relations []Relation) ([]Relation, error)
 		return nil, err
 	}
 
+	now := time.Now().UTC().Truncate(time.Second)
 	created := []Relation{}
 	for _, relation := range relations {
 		// Check if relation already exists
@@ -248,6 +581,9 @@ func (j *JSONLStorage) CreateRelations(relations []Relation) ([]Relation, error)
 		}
 
 		if !exists {
+			if relation.CreatedAt == nil {
+				relation.CreatedAt = &now
+			}
 			graph.Relations = append(graph.Relations, relation)
 			created = append(created, relation)
 		}
@@ -260,11 +596,11 @@ func (j *JSONLStorage) CreateRelations(relations []Relation) ([]Relation, error)
 	return created, nil
 }
 
-// DeleteRelations deletes specific relations
-func (j *JSONLStorage) DeleteRelations(relations []Relation) error {
+// DeleteRelations deletes specific relations and returns how many existed
+func (j *JSONLStorage) DeleteRelations(relations []Relation) (int, error) {
 	graph, err := j.loadGraph()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// Create a set for relation lookup
@@ -282,13 +618,73 @@ func (j *JSONLStorage) DeleteRelations(relations []Relation) error {
 			filteredRelations = append(filteredRelations, relation)
 		}
 	}
+	deleted := len(graph.Relations) - len(filteredRelations)
 	graph.Relations = filteredRelations
 
-	return j.saveGraph(graph)
+	if err := j.saveGraph(graph); err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// DeleteRelationsByType deletes every relation of relationType
+func (j *JSONLStorage) DeleteRelationsByType(relationType string) (int, error) {
+	graph, err := j.loadGraph()
+	if err != nil {
+		return 0, err
+	}
+
+	kept := []Relation{}
+	for _, relation := range graph.Relations {
+		if relation.RelationType != relationType {
+			kept = append(kept, relation)
+		}
+	}
+	deleted := len(graph.Relations) - len(kept)
+	if deleted == 0 {
+		return 0, nil
+	}
+	graph.Relations = kept
+
+	return deleted, j.saveGraph(graph)
+}
+
+// MergeRelationTypes rewrites relations of the alias types to canonical,
+// dropping those that duplicate a relation already of the canonical type
+func (j *JSONLStorage) MergeRelationTypes(canonical string, aliases []string) (int, error) {
+	aliases, err := relationTypeAliases(canonical, aliases)
+	if err != nil {
+		return 0, err
+	}
+	graph, err := j.loadGraph()
+	if err != nil {
+		return 0, err
+	}
+
+	changed := 0
+	seen := make(map[Relation]bool, len(graph.Relations))
+	kept := []Relation{}
+	for _, relation := range graph.Relations {
+		if slices.Contains(aliases, relation.RelationType) {
+			relation.RelationType = canonical
+			changed++
+		}
+		if seen[relation.key()] {
+			continue
+		}
+		seen[relation.key()] = true
+		kept = append(kept, relation)
+	}
+	if changed == 0 {
+		return 0, nil
+	}
+	graph.Relations = kept
+
+	return changed, j.saveGraph(graph)
 }
 
 // AddObservations adds observations to entities
-func (j *JSONLStorage) AddObservations(observations map[string][]string) (map[string][]string, error) {
+func (j *JSONLStorage) AddObservations(observations map[string][]string, source string) (map[string][]string, error) {
 	graph, err := j.loadGraph()
 	if err != nil {
 		return nil, err
@@ -307,9 +703,12 @@ func (j *JSONLStorage) AddObservations(observations map[string][]string) (map[st
 
 				// Add non-duplicate observations
 				for _, obs := range obsList {
-					if !slices.Contains(entity.Observations, obs) {
+					if !j.config.containsObservation(graph.Entities[i].Observations, obs) {
 						graph.Entities[i].Observations = append(graph.Entities[i].Observations, obs)
 						added[entityName] = append(added[entityName], obs)
+						if source != "" {
+							setSource(&graph.Entities[i], obs, source)
+						}
 					}
 				}
 				break
@@ -317,7 +716,7 @@ func (j *JSONLStorage) AddObservations(observations map[string][]string) (map[st
 		}
 
 		if !found {
-			return nil, fmt.Errorf("entity %s not found", entityName)
+			return nil, entityNotFound(entityName)
 		}
 	}
 
@@ -328,27 +727,31 @@ func (j *JSONLStorage) AddObservations(observations map[string][]string) (map[st
 	return added, nil
 }
 
-// DeleteObservations deletes specific observations
-func (j *JSONLStorage) DeleteObservations(deletions []ObservationDeletion) error {
+// DeleteObservations deletes the observations each deletion matches and
+// returns how many were deleted per entity
+func (j *JSONLStorage) DeleteObservations(deletions []ObservationDeletion) (map[string]int, error) {
+	if err := validateObservationDeletions(deletions); err != nil {
+		return nil, err
+	}
 	graph, err := j.loadGraph()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	deleted := make(map[string]int, len(deletions))
+	for _, deletion := range deletions {
+		deleted[deletion.EntityName] = 0
+	}
 	for _, deletion := range deletions {
 		// Find entity
 		for i, entity := range graph.Entities {
 			if entity.Name == deletion.EntityName {
-				// Create set of observations to delete
-				toDelete := make(map[string]bool)
-				for _, obs := range deletion.Observations {
-					toDelete[obs] = true
-				}
-
 				// Filter observations
 				filteredObs := []string{}
-				for _, obs := range entity.Observations {
-					if !toDelete[obs] {
+				for index, obs := range entity.Observations {
+					if deletion.matches(index, obs) {
+						deleted[deletion.EntityName]++
+					} else {
 						filteredObs = append(filteredObs, obs)
 					}
 				}
@@ -358,18 +761,47 @@ func (j *JSONLStorage) DeleteObservations(deletions []ObservationDeletion) error
 		}
 	}
 
-	return j.saveGraph(graph)
+	if err := j.saveGraph(graph); err != nil {
+		return nil, err
+	}
+	return deleted, nil
 }
 
 // ReadGraph returns either a lightweight summary or full graph based on mode
-func (j *JSONLStorage) ReadGraph(mode string, limit int) (interface{}, error) {
+func (j *JSONLStorage) ReadGraph(mode string, limit int, filter TypeFilter) (interface{}, error) {
 	graph, err := j.loadGraph()
 	if err != nil {
 		return nil, err
 	}
+	result := readGraphFrom(graph, mode, limit, filter, j.config)
+	j.exposeIDs(result)
+	j.config.noteMoreObservations(result)
+	return result, nil
+}
+
+// errAsOfUnsupported is returned for point-in-time reads against JSONL storage
+var errAsOfUnsupported = fmt.Errorf("%w: asOf requires SQLite storage (JSONL records no creation times)", ErrInvalidArgument)
+
+// ReadGraphAsOf is not supported by JSONL storage, which records no creation times
+func (j *JSONLStorage) ReadGraphAsOf(asOf time.Time, mode string, limit int, filter TypeFilter) (interface{}, error) {
+	return nil, errAsOfUnsupported
+}
+
+// readGraphFrom builds a ReadGraph result for mode from an in-memory graph, listing
+// observations in config's order and capping them at its readObservationCap in full mode
+func readGraphFrom(graph *KnowledgeGraph, mode string, limit int, filter TypeFilter, config Config) interface{} {
+	graph = filter.apply(graph)
 
 	if mode == "full" {
-		return graph, nil
+		maxObs := config.readObservationCap()
+		for i, entity := range graph.Entities {
+			graph.Entities[i] = config.orderObservations(entity)
+			if capped, ok := capObservations(graph.Entities[i], maxObs); ok {
+				graph.Entities[i] = capped
+				graph.Truncated = true
+			}
+		}
+		return graph
 	}
 
 	// Summary mode
@@ -407,7 +839,7 @@ func (j *JSONLStorage) ReadGraph(mode string, limit int) (interface{}, error) {
 
 	summary.HasMore = summary.TotalEntities > limit
 
-	return summary, nil
+	return summary
 }
 
 // Match priority constants for JSONL search ranking (same as SQLite)
@@ -422,24 +854,81 @@ const (
 // Multiple space-separated words are treated as OR search
 // Results are sorted by match priority: name exact > name partial > type > content
 func (j *JSONLStorage) SearchNodes(query string, limit int) (*SearchResult, error) {
+	return j.SearchNodesWithSnippets(query, limit, 0)
+}
+
+// SearchNodesWithSnippets is SearchNodes with at most maxSnippets matched
+// observations per hit, those containing the most query words first
+func (j *JSONLStorage) SearchNodesWithSnippets(query string, limit, maxSnippets int) (*SearchResult, error) {
 	fullGraph, err := j.loadGraph()
 	if err != nil {
 		return nil, err
 	}
+	result := searchGraph(fullGraph, query, limit, j.config.searchSnippets(limit, maxSnippets), j.config)
+	j.exposeIDs(result)
+	return result, nil
+}
+
+// SearchNodesAsOf is not supported by JSONL storage, which records no creation times
+func (j *JSONLStorage) SearchNodesAsOf(asOf time.Time, query string, limit int) (*SearchResult, error) {
+	return nil, errAsOfUnsupported
+}
+
+// listGraphHits returns fullGraph's entities in name order as search hits
+// without snippets, for an empty query with Config.EmptyQueryListsAll
+func listGraphHits(fullGraph *KnowledgeGraph, limit int, config Config) *SearchResult {
+	result := &SearchResult{
+		Entities: []EntitySearchHit{},
+		Total:    len(fullGraph.Entities),
+		Limit:    limit,
+	}
+	if tooMany := config.tooManyMatches(result.Total, limit); tooMany != nil {
+		return tooMany
+	}
+
+	relationsCount := make(map[string]int)
+	for _, relation := range fullGraph.Relations {
+		relationsCount[relation.From]++
+		relationsCount[relation.To]++
+	}
+	entities := slices.Clone(fullGraph.Entities)
+	slices.SortFunc(entities, func(a, b Entity) int { return strings.Compare(a.Name, b.Name) })
+	if limit > 0 && len(entities) > limit {
+		entities = entities[:limit]
+		result.HasMore = true
+	}
+	for _, entity := range entities {
+		result.Entities = append(result.Entities, EntitySearchHit{
+			Name:              entity.Name,
+			EntityType:        entity.EntityType,
+			Description:       entity.Description,
+			Snippets:          []string{},
+			ObservationsCount: len(entity.Observations),
+			RelationsCount:    relationsCount[entity.Name],
+		})
+	}
+	return result
+}
 
+// searchGraph runs the in-memory search used by SearchNodes over fullGraph,
+// applying config's MaxSearchMatches
+func searchGraph(fullGraph *KnowledgeGraph, query string, limit, maxSnippets int, config Config) *SearchResult {
 	result := &SearchResult{
 		Entities: []EntitySearchHit{},
 		Limit:    limit,
 	}
 
+	if strings.TrimSpace(query) == "" && config.EmptyQueryListsAll {
+		return listGraphHits(fullGraph, limit, config)
+	}
 	if query == "" {
-		return result, nil
+		return result
 	}
 
-	// Split query into words for OR search
-	words := strings.Fields(query)
+	// Split query into words for OR search; a "quoted phrase" is one word
+	words := searchTerms(query)
 	if len(words) == 0 {
-		return result, nil
+		return result
 	}
 
 	// Convert words to lowercase for case-insensitive search
@@ -456,10 +945,7 @@ func (j *JSONLStorage) SearchNodes(query string, limit int) (*SearchResult, erro
 	}
 
 	// Determine max snippets per entity
-	maxSnippets := 2
-	if limit == 0 {
-		maxSnippets = 0 // unlimited snippets when no limit
-	}
+	maxSnippets = snippetCap(limit, maxSnippets)
 
 	// Search entities - match if ANY word matches
 	// Track priority for sorting
@@ -467,6 +953,7 @@ func (j *JSONLStorage) SearchNodes(query string, limit int) (*SearchResult, erro
 		entity          Entity
 		matchedSnippets []string
 		priority        int // Match priority for sorting
+		terms           int // How many query words matched
 	}
 	var matchedEntities []matchedEntity
 
@@ -474,20 +961,23 @@ func (j *JSONLStorage) SearchNodes(query string, limit int) (*SearchResult, erro
 		matched := false
 		var snippets []string
 		priority := 0 // Track the highest priority match
+		terms := 0
+		wordHits := make([]int, len(entity.Observations))
 
 		for _, queryWord := range lowerWords {
+			hit := false
 			lowerName := strings.ToLower(entity.Name)
 			lowerType := strings.ToLower(entity.EntityType)
 
 			// Check name - exact match (highest priority)
 			if lowerName == queryWord {
-				matched = true
+				hit = true
 				if jsonlPriorityNameExact > priority {
 					priority = jsonlPriorityNameExact
 				}
 			} else if strings.Contains(lowerName, queryWord) {
 				// Check name - partial match
-				matched = true
+				hit = true
 				if jsonlPriorityNamePartial > priority {
 					priority = jsonlPriorityNamePartial
 				}
@@ -495,25 +985,51 @@ func (j *JSONLStorage) SearchNodes(query string, limit int) (*SearchResult, erro
 
 			// Check type
 			if strings.Contains(lowerType, queryWord) {
-				matched = true
+				hit = true
 				if jsonlPriorityType > priority {
 					priority = jsonlPriorityType
 				}
 			}
 
-			// Check observations and collect context snippets around keywords
-			for _, obs := range entity.Observations {
+			// Check description, ranked like observation content
+			if strings.Contains(strings.ToLower(entity.Description), queryWord) {
+				hit = true
+				if jsonlPriorityContent > priority {
+					priority = jsonlPriorityContent
+				}
+			}
+
+			// Check observations, counting the query words each one contains
+			for i, obs := range entity.Observations {
 				if strings.Contains(strings.ToLower(obs), queryWord) {
-					matched = true
+					hit = true
 					if jsonlPriorityContent > priority {
 						priority = jsonlPriorityContent
 					}
-					// Add context snippet if within limit
-					if maxSnippets == 0 || len(snippets) < maxSnippets {
-						snippets = append(snippets, extractKeywordContextJSON(obs, words, 50))
-					}
+					wordHits[i]++
 				}
 			}
+
+			if hit {
+				matched = true
+				terms++
+			}
+		}
+
+		// Collect context snippets around keywords, observations containing the
+		// most query words first
+		var order []int
+		for i, hits := range wordHits {
+			if hits > 0 {
+				order = append(order, i)
+			}
+		}
+		slices.SortStableFunc(order, func(a, b int) int { return wordHits[b] - wordHits[a] })
+		for _, i := range order {
+			if maxSnippets > 0 && len(snippets) >= maxSnippets {
+				break
+			}
+			snippets = append(snippets, extractKeywordContextJSON(entity.Observations[i], words, 50))
 		}
 
 		if matched {
@@ -531,12 +1047,21 @@ func (j *JSONLStorage) SearchNodes(query string, limit int) (*SearchResult, erro
 				entity:          entity,
 				matchedSnippets: snippets,
 				priority:        priority,
+				terms:           terms,
 			})
 		}
 	}
 
-	// Sort by priority (descending), then by name (ascending) for stable ordering
+	if tooMany := config.tooManyMatches(len(matchedEntities), limit); tooMany != nil {
+		return tooMany
+	}
+
+	// Sort by matched words and priority (descending), then by name (ascending)
+	// for stable ordering
 	slices.SortFunc(matchedEntities, func(a, b matchedEntity) int {
+		if a.terms != b.terms {
+			return b.terms - a.terms // More query words matched first
+		}
 		if a.priority != b.priority {
 			return b.priority - a.priority // Higher priority first
 		}
@@ -553,6 +1078,7 @@ func (j *JSONLStorage) SearchNodes(query string, limit int) (*SearchResult, erro
 		result.Entities = append(result.Entities, EntitySearchHit{
 			Name:              me.entity.Name,
 			EntityType:        me.entity.EntityType,
+			Description:       me.entity.Description,
 			Snippets:          me.matchedSnippets,
 			ObservationsCount: len(me.entity.Observations),
 			RelationsCount:    relationsCountMap[me.entity.Name],
@@ -566,7 +1092,7 @@ func (j *JSONLStorage) SearchNodes(query string, limit int) (*SearchResult, erro
 		result.HasMore = false // no limit means all results returned
 	}
 
-	return result, nil
+	return result
 }
 
 // truncateStringJSON truncates a string to maxLen characters and adds "..." if truncated
@@ -628,9 +1154,27 @@ func extractKeywordContextJSON(content string, words []string, contextChars int)
 	return result.String()
 }
 
-// OpenNodes retrieves specific nodes by name with truncation protection
-const maxObservationsPerEntityJSONL = 100
+// EntityTypes looks up the types of the named entities
+func (j *JSONLStorage) EntityTypes(names []string) (map[string]string, error) {
+	graph, err := j.loadGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+	types := make(map[string]string, len(names))
+	for _, entity := range graph.Entities {
+		if wanted[entity.Name] {
+			types[entity.Name] = entity.EntityType
+		}
+	}
+	return types, nil
+}
 
+// OpenNodes retrieves specific nodes by name with truncation protection
 func (j *JSONLStorage) OpenNodes(names []string) (*KnowledgeGraph, error) {
 	fullGraph, err := j.loadGraph()
 	if err != nil {
@@ -657,15 +1201,9 @@ func (j *JSONLStorage) OpenNodes(names []string) (*KnowledgeGraph, error) {
 	// Get requested entities with truncation
 	for _, entity := range fullGraph.Entities {
 		if nameSet[entity.Name] {
-			e := Entity{
-				Name:         entity.Name,
-				EntityType:   entity.EntityType,
-				Observations: entity.Observations,
-			}
-
 			// Apply truncation if needed
-			if len(e.Observations) > maxObservationsPerEntityJSONL {
-				e.Observations = e.Observations[:maxObservationsPerEntityJSONL]
+			e, capped := capObservations(j.config.orderObservations(entity), j.config.observationCap())
+			if capped {
 				truncated = true
 			}
 
@@ -682,31 +1220,663 @@ func (j *JSONLStorage) OpenNodes(names []string) (*KnowledgeGraph, error) {
 		}
 	}
 
+	j.exposeIDs(result)
 	return result, nil
 }
 
-// MergeEntities merges source entity into target entity.
-func (j *JSONLStorage) MergeEntities(sourceName, targetName string) (*MergeResult, error) {
+// ListEntities lists entities with optional type filter, sorting and paging.
+// JSONL keeps no timestamps, so "created" and "updated" both use file order.
+func (j *JSONLStorage) ListEntities(opts ListOptions) (*EntityList, error) {
+	switch opts.Sort {
+	case "", "name", "created", "updated":
+	default:
+		return nil, fmt.Errorf("%w: sort %q must be name, created, or updated", ErrInvalidArgument, opts.Sort)
+	}
+	order := strings.ToLower(opts.Order)
+	if order != "" && order != "asc" && order != "desc" {
+		return nil, fmt.Errorf("%w: order %q must be asc or desc", ErrInvalidArgument, opts.Order)
+	}
+
 	graph, err := j.loadGraph()
 	if err != nil {
 		return nil, err
 	}
 
-	// Find source and target
-	sourceIdx, targetIdx := -1, -1
-	for i, e := range graph.Entities {
-		if e.Name == sourceName {
-			sourceIdx = i
+	entities := []Entity{}
+	for _, entity := range graph.Entities {
+		if opts.EntityType == "" || entity.EntityType == opts.EntityType {
+			entities = append(entities, entity)
 		}
-		if e.Name == targetName {
+	}
+
+	if opts.Sort == "" || opts.Sort == "name" {
+		sort.SliceStable(entities, func(a, b int) bool {
+			return entities[a].Name < entities[b].Name
+		})
+	}
+	if order == "desc" {
+		for a, b := 0, len(entities)-1; a < b; a, b = a+1, b-1 {
+			entities[a], entities[b] = entities[b], entities[a]
+		}
+	}
+
+	result := &EntityList{
+		Entities: []Entity{},
+		Total:    len(entities),
+		Limit:    opts.Limit,
+		Offset:   opts.Offset,
+	}
+
+	start := opts.Offset
+	if start > len(entities) {
+		start = len(entities)
+	}
+	end := len(entities)
+	if opts.Limit > 0 && start+opts.Limit < end {
+		end = start + opts.Limit
+	}
+
+	for _, entity := range entities[start:end] {
+		e := Entity{Name: entity.Name, EntityType: entity.EntityType}
+		if opts.IncludeObservations {
+			e.Observations = entity.Observations
+			if e.Observations == nil {
+				e.Observations = []string{}
+			}
+		}
+		result.Entities = append(result.Entities, e)
+	}
+
+	result.HasMore = end < len(entities)
+	return result, nil
+}
+
+// ListEntityNames returns a page of entity names sorted by name
+func (j *JSONLStorage) ListEntityNames(limit, offset int) (*NameList, error) {
+	graph, err := j.loadGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(graph.Entities))
+	for i, entity := range graph.Entities {
+		names[i] = entity.Name
+	}
+	slices.Sort(names)
+
+	start := min(max(offset, 0), len(names))
+	end := len(names)
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+
+	return &NameList{
+		Names:   names[start:end],
+		Total:   len(names),
+		Limit:   limit,
+		Offset:  offset,
+		HasMore: end < len(names),
+	}, nil
+}
+
+// ListRelations returns a page of relations in file order, which is the order
+// they were created in
+func (j *JSONLStorage) ListRelations(limit, offset int) (*RelationPage, error) {
+	graph, err := j.loadGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	start := min(max(offset, 0), len(graph.Relations))
+	end := len(graph.Relations)
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+
+	return &RelationPage{
+		Relations: append([]Relation{}, graph.Relations[start:end]...),
+		Total:     len(graph.Relations),
+		Limit:     limit,
+		Offset:    offset,
+		HasMore:   end < len(graph.Relations),
+	}, nil
+}
+
+// RecentlyModified returns the entities changed most recently, newest first by
+// update time. Ties, and entities without one from files written before update
+// times were recorded (which come last), go by file order, later lines first.
+func (j *JSONLStorage) RecentlyModified(limit int) ([]Entity, error) {
+	if limit <= 0 {
+		limit = DefaultRecentLimit
+	}
+	graph, err := j.loadGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	entities := make([]Entity, 0, len(graph.Entities))
+	for i := len(graph.Entities) - 1; i >= 0; i-- {
+		entity := Entity{Name: graph.Entities[i].Name, EntityType: graph.Entities[i].EntityType}
+		if updated := graph.Entities[i].UpdateTime; !updated.IsZero() {
+			entity.UpdatedAt = &updated
+		}
+		entities = append(entities, entity)
+	}
+	slices.SortStableFunc(entities, func(a, b Entity) int {
+		switch {
+		case a.UpdatedAt == nil && b.UpdatedAt == nil:
+			return 0
+		case a.UpdatedAt == nil:
+			return 1
+		case b.UpdatedAt == nil:
+			return -1
+		}
+		return b.UpdatedAt.Compare(*a.UpdatedAt)
+	})
+	if len(entities) > limit {
+		entities = entities[:limit]
+	}
+	return entities, nil
+}
+
+// FindByObservation returns all entities that contain the exact observation content
+func (j *JSONLStorage) FindByObservation(content string) ([]Entity, error) {
+	graph, err := j.loadGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	entities := []Entity{}
+	for _, entity := range graph.Entities {
+		if slices.Contains(entity.Observations, content) {
+			entities = append(entities, entity)
+		}
+	}
+
+	return entities, nil
+}
+
+// GetObservations returns a page of an entity's observations in insertion order
+func (j *JSONLStorage) GetObservations(entityName string, offset, limit int) (*ObservationPage, error) {
+	graph, err := j.loadGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entity := range graph.Entities {
+		if entity.Name != entityName {
+			continue
+		}
+
+		page := &ObservationPage{
+			EntityName:   entityName,
+			Observations: []string{},
+			Total:        len(entity.Observations),
+			Offset:       offset,
+			Limit:        limit,
+		}
+
+		start := min(max(offset, 0), page.Total)
+		end := page.Total
+		if limit > 0 && start+limit < end {
+			end = start + limit
+		}
+		page.Observations = append(page.Observations, entity.Observations[start:end]...)
+		page.HasMore = end < page.Total
+		return page, nil
+	}
+
+	return nil, entityNotFound(entityName)
+}
+
+// Traverse returns entities connected to from by relationType (empty = any type)
+// in the given direction
+func (j *JSONLStorage) Traverse(from string, relationType string, direction string) ([]RelatedHit, error) {
+	out, in, err := traverseDirections(direction)
+	if err != nil {
+		return nil, err
+	}
+
+	graph, err := j.loadGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	entityTypes := make(map[string]string, len(graph.Entities))
+	for _, entity := range graph.Entities {
+		entityTypes[entity.Name] = entity.EntityType
+	}
+	if _, ok := entityTypes[from]; !ok {
+		return nil, entityNotFound(from)
+	}
+
+	hits := []RelatedHit{}
+	for _, relation := range graph.Relations {
+		if relationType != "" && relation.RelationType != relationType {
+			continue
+		}
+		var name, dir string
+		switch {
+		case out && relation.From == from:
+			name, dir = relation.To, "outgoing"
+		case in && relation.To == from:
+			name, dir = relation.From, "incoming"
+		default:
+			continue
+		}
+		entityType, ok := entityTypes[name]
+		if !ok {
+			continue // dangling relation
+		}
+		hits = append(hits, RelatedHit{
+			Name:         name,
+			EntityType:   entityType,
+			RelationType: relation.RelationType,
+			RelatedTo:    from,
+			Direction:    dir,
+		})
+	}
+
+	return hits, nil
+}
+
+// Reachable returns the entities within maxHops of start along relations of
+// relationType ("" = any type), found by breadth-first search
+func (j *JSONLStorage) Reachable(start string, relationType string, maxHops int, direction string) ([]ReachableEntity, error) {
+	out, in, err := traverseDirections(direction)
+	if err != nil {
+		return nil, err
+	}
+	if maxHops, err = reachableHops(maxHops); err != nil {
+		return nil, err
+	}
+
+	graph, err := j.loadGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	entityTypes := make(map[string]string, len(graph.Entities))
+	for _, entity := range graph.Entities {
+		entityTypes[entity.Name] = entity.EntityType
+	}
+	if _, ok := entityTypes[start]; !ok {
+		return nil, entityNotFound(start)
+	}
+
+	neighbors := make(map[string][]string)
+	for _, relation := range graph.Relations {
+		if relationType != "" && relation.RelationType != relationType {
+			continue
+		}
+		if out {
+			neighbors[relation.From] = append(neighbors[relation.From], relation.To)
+		}
+		if in {
+			neighbors[relation.To] = append(neighbors[relation.To], relation.From)
+		}
+	}
+
+	found := []ReachableEntity{}
+	visited := map[string]bool{start: true}
+	frontier := []string{start}
+	for hops := 1; hops <= maxHops && len(frontier) > 0; hops++ {
+		var next []string
+		for _, name := range frontier {
+			for _, neighbor := range neighbors[name] {
+				entityType, ok := entityTypes[neighbor]
+				if visited[neighbor] || !ok {
+					continue // already reached, or a dangling relation
+				}
+				visited[neighbor] = true
+				next = append(next, neighbor)
+				found = append(found, ReachableEntity{Name: neighbor, EntityType: entityType, Hops: hops})
+			}
+		}
+		frontier = next
+	}
+
+	sort.SliceStable(found, func(a, b int) bool {
+		if found[a].Hops != found[b].Hops {
+			return found[a].Hops < found[b].Hops
+		}
+		return found[a].Name < found[b].Name
+	})
+	return found, nil
+}
+
+// Closure walks breadth-first from start with a visited set until nothing new
+// is reached or maxNodes entities were found
+func (j *JSONLStorage) Closure(start string, relationType string, direction string, maxNodes int) (*ClosureResult, error) {
+	out, in, err := traverseDirections(direction)
+	if err != nil {
+		return nil, err
+	}
+	if maxNodes, err = closureNodes(maxNodes); err != nil {
+		return nil, err
+	}
+
+	graph, err := j.loadGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	entityTypes := make(map[string]string, len(graph.Entities))
+	for _, entity := range graph.Entities {
+		entityTypes[entity.Name] = entity.EntityType
+	}
+	if _, ok := entityTypes[start]; !ok {
+		return nil, entityNotFound(start)
+	}
+
+	neighbors := make(map[string][]string)
+	for _, relation := range graph.Relations {
+		if relationType != "" && relation.RelationType != relationType {
+			continue
+		}
+		if out {
+			neighbors[relation.From] = append(neighbors[relation.From], relation.To)
+		}
+		if in {
+			neighbors[relation.To] = append(neighbors[relation.To], relation.From)
+		}
+	}
+
+	result := &ClosureResult{Start: start, Entities: []ReachableEntity{}}
+	visited := map[string]bool{start: true}
+	frontier := []string{start}
+	for hops := 1; len(frontier) > 0; hops++ {
+		var level []ReachableEntity
+		for _, name := range frontier {
+			for _, neighbor := range neighbors[name] {
+				entityType, ok := entityTypes[neighbor]
+				if visited[neighbor] || !ok {
+					continue // already reached, or a dangling relation
+				}
+				visited[neighbor] = true
+				level = append(level, ReachableEntity{Name: neighbor, EntityType: entityType, Hops: hops})
+			}
+		}
+		// Sort each level by name so a truncated closure keeps the same entities as SQLite
+		sort.Slice(level, func(a, b int) bool { return level[a].Name < level[b].Name })
+		if room := maxNodes - len(result.Entities); len(level) > room {
+			result.Entities = append(result.Entities, level[:room]...)
+			result.Truncated = true
+			break
+		}
+		result.Entities = append(result.Entities, level...)
+
+		frontier = frontier[:0]
+		for _, entity := range level {
+			frontier = append(frontier, entity.Name)
+		}
+	}
+	return result, nil
+}
+
+// FindAllPaths returns up to maxPaths acyclic paths from from to to along
+// outgoing relations, shortest first
+func (j *JSONLStorage) FindAllPaths(from, to string, maxDepth int, maxPaths int) ([][]Relation, error) {
+	maxDepth, maxPaths, err := pathLimits(maxDepth, maxPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	graph, err := j.loadGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	exists := make(map[string]bool, len(graph.Entities))
+	for _, entity := range graph.Entities {
+		exists[entity.Name] = true
+	}
+	for _, name := range []string{from, to} {
+		if !exists[name] {
+			return nil, entityNotFound(name)
+		}
+	}
+
+	outgoing := make(map[string][]Relation)
+	for _, relation := range graph.Relations {
+		if exists[relation.To] {
+			outgoing[relation.From] = append(outgoing[relation.From], relation)
+		}
+	}
+	return allPaths(from, to, maxDepth, maxPaths, func(name string) ([]Relation, error) {
+		return outgoing[name], nil
+	})
+}
+
+// RelationExists reports whether the exact relation from -> to of relationType exists
+func (j *JSONLStorage) RelationExists(from, to, relationType string) (bool, error) {
+	graph, err := j.loadGraph()
+	if err != nil {
+		return false, err
+	}
+
+	target := Relation{From: from, To: to, RelationType: relationType}
+	return slices.ContainsFunc(graph.Relations, func(r Relation) bool { return r.key() == target }), nil
+}
+
+// TopConnectedPairs returns the (from, to) pairs with the most distinct relations,
+// ties broken by names
+func (j *JSONLStorage) TopConnectedPairs(limit int) ([]PairCount, error) {
+	graph, err := j.loadGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	type pair struct{ from, to string }
+	counts := make(map[pair]int)
+	seen := make(map[Relation]bool, len(graph.Relations))
+	for _, r := range graph.Relations {
+		if !seen[r.key()] {
+			seen[r.key()] = true
+			counts[pair{r.From, r.To}]++
+		}
+	}
+
+	pairs := make([]PairCount, 0, len(counts))
+	for p, count := range counts {
+		pairs = append(pairs, PairCount{From: p.from, To: p.to, Count: count})
+	}
+	sort.Slice(pairs, func(a, b int) bool {
+		if pairs[a].Count != pairs[b].Count {
+			return pairs[a].Count > pairs[b].Count
+		}
+		if pairs[a].From != pairs[b].From {
+			return pairs[a].From < pairs[b].From
+		}
+		return pairs[a].To < pairs[b].To
+	})
+
+	if limit > 0 && len(pairs) > limit {
+		pairs = pairs[:limit]
+	}
+	return pairs, nil
+}
+
+// EntitiesByObservationCount filters entities by their observation count
+func (j *JSONLStorage) EntitiesByObservationCount(minCount, maxCount, limit int, ascending bool) ([]ObservationCount, error) {
+	if err := validateCountRange(minCount, maxCount); err != nil {
+		return nil, err
+	}
+	graph, err := j.loadGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := []ObservationCount{}
+	for _, entity := range graph.Entities {
+		n := len(entity.Observations)
+		if n >= minCount && (maxCount < 0 || n <= maxCount) {
+			counts = append(counts, ObservationCount{Name: entity.Name, EntityType: entity.EntityType, Count: n})
+		}
+	}
+	sort.Slice(counts, func(a, b int) bool {
+		if counts[a].Count != counts[b].Count {
+			return (counts[a].Count < counts[b].Count) == ascending
+		}
+		return counts[a].Name < counts[b].Name
+	})
+
+	if limit > 0 && len(counts) > limit {
+		counts = counts[:limit]
+	}
+	return counts, nil
+}
+
+// SelfRelations returns the relations whose from and to are the same entity
+func (j *JSONLStorage) SelfRelations() ([]Relation, error) {
+	graph, err := j.loadGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	relations := []Relation{}
+	for _, r := range graph.Relations {
+		if r.From == r.To {
+			relations = append(relations, r)
+		}
+	}
+	slices.SortStableFunc(relations, func(a, b Relation) int {
+		if c := strings.Compare(a.From, b.From); c != 0 {
+			return c
+		}
+		return strings.Compare(a.RelationType, b.RelationType)
+	})
+	return relations, nil
+}
+
+// RelationsAmong returns the relations whose endpoints are both in names. asOf
+// must be zero, as JSONL records no creation times.
+func (j *JSONLStorage) RelationsAmong(names []string, asOf time.Time) ([]Relation, error) {
+	if !asOf.IsZero() {
+		return nil, errAsOfUnsupported
+	}
+	graph, err := j.loadGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	among := make(map[string]bool, len(names))
+	for _, name := range names {
+		among[name] = true
+	}
+	relations := []Relation{}
+	for _, r := range graph.Relations {
+		if among[r.From] && among[r.To] {
+			relations = append(relations, r)
+		}
+	}
+	return relations, nil
+}
+
+// RelationsTouching returns the relations with either endpoint in names
+func (j *JSONLStorage) RelationsTouching(names []string) ([]Relation, error) {
+	graph, err := j.loadGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	touched := make(map[string]bool, len(names))
+	for _, name := range names {
+		touched[name] = true
+	}
+	relations := []Relation{}
+	for _, r := range graph.Relations {
+		if touched[r.From] || touched[r.To] {
+			relations = append(relations, r)
+		}
+	}
+	return relations, nil
+}
+
+// ObservationCounts returns the observation count of each named entity
+func (j *JSONLStorage) ObservationCounts(names []string) (map[string]int, error) {
+	graph, err := j.loadGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+	counts := make(map[string]int, len(names))
+	for _, entity := range graph.Entities {
+		if wanted[entity.Name] {
+			counts[entity.Name] = len(entity.Observations)
+		}
+	}
+	return counts, nil
+}
+
+// EmptyEntities returns the entities whose observation list is empty
+func (j *JSONLStorage) EmptyEntities() ([]EntitySummary, error) {
+	graph, err := j.loadGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	entities := []EntitySummary{}
+	for _, entity := range graph.Entities {
+		if len(entity.Observations) == 0 {
+			entities = append(entities, EntitySummary{Name: entity.Name, EntityType: entity.EntityType})
+		}
+	}
+	slices.SortFunc(entities, func(a, b EntitySummary) int { return strings.Compare(a.Name, b.Name) })
+	return entities, nil
+}
+
+// RelationTypeSamples returns the first limit relations of each type in file order
+func (j *JSONLStorage) RelationTypeSamples(limit int) (map[string][]Relation, error) {
+	limit, err := relationSamples(limit)
+	if err != nil {
+		return nil, err
+	}
+
+	graph, err := j.loadGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make(map[string][]Relation)
+	for _, r := range graph.Relations {
+		if len(samples[r.RelationType]) < limit {
+			samples[r.RelationType] = append(samples[r.RelationType], r)
+		}
+	}
+	return samples, nil
+}
+
+// MergeEntities merges source entity into target entity.
+func (j *JSONLStorage) MergeEntities(sourceName, targetName string) (*MergeResult, error) {
+	if sourceName == targetName {
+		return nil, fmt.Errorf("%w: cannot merge entity %q into itself", ErrInvalidArgument, sourceName)
+	}
+
+	graph, err := j.loadGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	// Find source and target
+	sourceIdx, targetIdx := -1, -1
+	for i, e := range graph.Entities {
+		if e.Name == sourceName {
+			sourceIdx = i
+		}
+		if e.Name == targetName {
 			targetIdx = i
 		}
 	}
 	if sourceIdx == -1 {
-		return nil, fmt.Errorf("source entity %q not found", sourceName)
+		return nil, fmt.Errorf("source %w", entityNotFound(sourceName))
 	}
 	if targetIdx == -1 {
-		return nil, fmt.Errorf("target entity %q not found", targetName)
+		return nil, fmt.Errorf("target %w", entityNotFound(targetName))
 	}
 
 	// Merge observations (deduplicate)
@@ -774,7 +1944,84 @@ func (j *JSONLStorage) UpdateEntityType(name string, newType string) error {
 			return j.saveGraph(graph)
 		}
 	}
-	return fmt.Errorf("entity %q not found", name)
+	return entityNotFound(name)
+}
+
+// SetEntityType changes an entity's type, leaving its observations untouched,
+// and returns the updated entity with all its observations, in a single save
+func (j *JSONLStorage) SetEntityType(name string, entityType string) (*Entity, error) {
+	graph, err := j.loadGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, e := range graph.Entities {
+		if e.Name == name {
+			graph.Entities[i].EntityType = entityType
+			if err := j.saveGraph(graph); err != nil {
+				return nil, err
+			}
+			return &Entity{
+				Name:         e.Name,
+				EntityType:   entityType,
+				Description:  e.Description,
+				Observations: slices.Clone(e.Observations),
+			}, nil
+		}
+	}
+	return nil, entityNotFound(name)
+}
+
+// UpdateEntity renames, retypes, and/or redescribes an entity in a single
+// save, skipping fields left empty (a nil description). Relations follow the
+// entity to its new name.
+func (j *JSONLStorage) UpdateEntity(oldName, newName, newType string, description *string) (*Entity, error) {
+	if err := validateEntityUpdate(newName, newType, description); err != nil {
+		return nil, err
+	}
+
+	graph, err := j.loadGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	idx := -1
+	for i, e := range graph.Entities {
+		if e.Name == oldName {
+			idx = i
+		} else if newName != "" && e.Name == newName {
+			return nil, fmt.Errorf("%w: entity %q already exists", ErrConflict, newName)
+		}
+	}
+	if idx == -1 {
+		return nil, entityNotFound(oldName)
+	}
+
+	if newType != "" {
+		graph.Entities[idx].EntityType = newType
+	}
+	if description != nil {
+		graph.Entities[idx].Description = *description
+	}
+	if newName != "" && newName != oldName {
+		graph.Entities[idx].Name = newName
+		for i := range graph.Relations {
+			if graph.Relations[i].From == oldName {
+				graph.Relations[i].From = newName
+			}
+			if graph.Relations[i].To == oldName {
+				graph.Relations[i].To = newName
+			}
+		}
+	}
+
+	if err := j.saveGraph(graph); err != nil {
+		return nil, err
+	}
+	if newName == "" {
+		newName = oldName
+	}
+	return openEntity(j, newName)
 }
 
 // UpdateObservation replaces an observation's content for a given entity.
@@ -786,16 +2033,82 @@ func (j *JSONLStorage) UpdateObservation(entityName string, oldContent string, n
 
 	for i, e := range graph.Entities {
 		if e.Name == entityName {
+			if newContent != oldContent && slices.Contains(e.Observations, newContent) {
+				return fmt.Errorf("%w: entity %q already has observation %q", ErrConflict, entityName, newContent)
+			}
 			for k, obs := range e.Observations {
 				if obs == oldContent {
 					graph.Entities[i].Observations[k] = newContent
 					return j.saveGraph(graph)
 				}
 			}
-			return fmt.Errorf("observation not found for entity %q", entityName)
+			return fmt.Errorf("%w: %q on entity %q", ErrObservationNotFound, oldContent, entityName)
 		}
 	}
-	return fmt.Errorf("entity %q not found", entityName)
+	return entityNotFound(entityName)
+}
+
+// ReplaceInObservations rewrites every observation containing old, dropping
+// any that end up equal to another observation of the same entity
+func (j *JSONLStorage) ReplaceInObservations(old, new string, caseSensitive bool) (int, error) {
+	replace, err := observationReplacer(old, new, caseSensitive)
+	if err != nil {
+		return 0, err
+	}
+
+	graph, err := j.loadGraph()
+	if err != nil {
+		return 0, err
+	}
+
+	modified := 0
+	for i := range graph.Entities {
+		entity := &graph.Entities[i]
+		kept := make([]string, 0, len(entity.Observations))
+		for _, obs := range entity.Observations {
+			replaced, changed := replace(obs)
+			if changed {
+				modified++
+				if source, ok := entity.ObservationSources[obs]; ok {
+					delete(entity.ObservationSources, obs)
+					if _, taken := entity.ObservationSources[replaced]; !taken {
+						entity.ObservationSources[replaced] = source
+					}
+				}
+			}
+			if !slices.Contains(kept, replaced) {
+				kept = append(kept, replaced)
+			}
+		}
+		entity.Observations = kept
+	}
+	if modified == 0 {
+		return 0, nil
+	}
+	return modified, j.saveGraph(graph)
+}
+
+// SetObservations replaces an entity's observations with the given list
+// (duplicates in the list are stored once)
+func (j *JSONLStorage) SetObservations(entityName string, observations []string) error {
+	graph, err := j.loadGraph()
+	if err != nil {
+		return err
+	}
+
+	for i, e := range graph.Entities {
+		if e.Name == entityName {
+			deduped := []string{}
+			for _, obs := range observations {
+				if !slices.Contains(deduped, obs) {
+					deduped = append(deduped, obs)
+				}
+			}
+			graph.Entities[i].Observations = deduped
+			return j.saveGraph(graph)
+		}
+	}
+	return entityNotFound(entityName)
 }
 
 // DetectConflicts finds potential duplicate or contradictory observations.
@@ -885,31 +2198,254 @@ func detectConflictTypeJSONL(a, b string) string {
 	return ""
 }
 
+// Clear removes all entities, observations, and relations by truncating the file
+func (j *JSONLStorage) Clear() (*ClearResult, error) {
+	graph, err := j.loadGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ClearResult{
+		EntitiesRemoved:  len(graph.Entities),
+		RelationsRemoved: len(graph.Relations),
+	}
+	for _, entity := range graph.Entities {
+		result.ObservationsRemoved += len(entity.Observations)
+	}
+
+	if err := j.saveGraph(&KnowledgeGraph{}); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// TransferEntities copies or moves entities to another namespace of the file
+func (j *JSONLStorage) TransferEntities(names []string, toNamespace string, opts TransferOptions) (*TransferResult, error) {
+	set, err := j.readSet()
+	if err != nil {
+		return nil, err
+	}
+	from := j.config.namespace()
+	source, target := set.graph(from), set.graph(toNamespace)
+
+	indexOf := func(graph *KnowledgeGraph, name string) int {
+		return slices.IndexFunc(graph.Entities, func(e Entity) bool { return e.Name == name })
+	}
+	exists := func(graph *KnowledgeGraph) func(name string) (bool, error) {
+		return func(name string) (bool, error) { return indexOf(graph, name) >= 0, nil }
+	}
+
+	plan, err := planTransfer(names, from, toNamespace, opts, exists(source), exists(target))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	moved := make(map[string]bool, len(plan.targets))
+	for _, entry := range plan.result.Transferred {
+		moved[entry.Name] = true
+		entity := source.Entities[indexOf(source, entry.Name)]
+		entity.Name = entry.TargetName
+		entity.Observations = slices.Clone(entity.Observations)
+		entity.ObservationSources = maps.Clone(entity.ObservationSources)
+		entity.ObservationTimes = maps.Clone(entity.ObservationTimes)
+		entity.UpdateTime = now
+		if entry.Overwritten {
+			target.Entities[indexOf(target, entry.TargetName)] = entity
+		} else {
+			target.Entities = append(target.Entities, entity)
+		}
+	}
+
+	touches := func(r Relation) bool { return moved[r.From] || moved[r.To] }
+	if opts.Relations {
+		var touching []Relation
+		for _, r := range source.Relations {
+			if touches(r) {
+				touching = append(touching, r)
+			}
+		}
+		mapped, err := plan.relations(touching, exists(target))
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range mapped {
+			if !slices.ContainsFunc(target.Relations, func(t Relation) bool { return t.key() == r.key() }) {
+				target.Relations = append(target.Relations, r)
+				plan.result.RelationsCopied++
+			}
+		}
+	}
+
+	if opts.Move {
+		source.Entities = slices.DeleteFunc(source.Entities, func(e Entity) bool { return moved[e.Name] })
+		source.Relations = slices.DeleteFunc(source.Relations, touches)
+	}
+
+	if err := j.writeSet(set); err != nil {
+		return nil, err
+	}
+	return plan.result, nil
+}
+
 // ExportData exports all data for migration
 func (j *JSONLStorage) ExportData() (*KnowledgeGraph, error) {
 	return j.loadGraph()
 }
 
-// ImportData imports data during migration
-func (j *JSONLStorage) ImportData(graph *KnowledgeGraph) error {
-	if graph == nil {
-		return nil
+// WalkGraph calls onEntity for every entity and then onRelation for every relation
+func (j *JSONLStorage) WalkGraph(onEntity func(Entity) error, onRelation func(Relation) error) error {
+	graph, err := j.loadGraph()
+	if err != nil {
+		return err
+	}
+	for _, entity := range graph.Entities {
+		if err := onEntity(entity); err != nil {
+			return err
+		}
+	}
+	for _, relation := range graph.Relations {
+		if err := onRelation(relation); err != nil {
+			return err
+		}
 	}
-	return j.saveGraph(graph)
+	return nil
+}
+
+// ImportData merges a graph into the store according to conflictMode (see
+// ConflictModeOverwrite and friends). Relations are added when both endpoints
+// exist and the relation is not already present.
+func (j *JSONLStorage) ImportData(graph *KnowledgeGraph, conflictMode string) (*ImportReport, error) {
+	existing, err := j.loadGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int, len(existing.Entities))
+	for i, e := range existing.Entities {
+		index[e.Name] = i
+	}
+
+	report, changes, err := planImport(graph, conflictMode, importLookup{
+		entity: func(name string) (*Entity, error) {
+			if i, ok := index[name]; ok {
+				return &existing.Entities[i], nil
+			}
+			return nil, nil
+		},
+		relationExists: func(r Relation) (bool, error) {
+			return slices.ContainsFunc(existing.Relations, func(e Relation) bool { return e.key() == r.key() }), nil
+		},
+		containsObservation: j.config.containsObservation,
+	})
+	if err != nil || changes == nil {
+		return report, err
+	}
+
+	for _, entity := range changes.Entities {
+		i, ok := index[entity.Name]
+		if !ok {
+			index[entity.Name] = len(existing.Entities)
+			existing.Entities = append(existing.Entities, Entity{
+				Name:         entity.Name,
+				Observations: []string{},
+			})
+			i = len(existing.Entities) - 1
+		}
+		existing.Entities[i].EntityType = entity.EntityType
+		if entity.Description != "" {
+			existing.Entities[i].Description = entity.Description
+		}
+		for _, obs := range entity.Observations {
+			if !j.config.containsObservation(existing.Entities[i].Observations, obs) {
+				existing.Entities[i].Observations = append(existing.Entities[i].Observations, obs)
+				if source := entity.ObservationSources[obs]; source != "" {
+					setSource(&existing.Entities[i], obs, source)
+				}
+			}
+		}
+	}
+	existing.Relations = append(existing.Relations, changes.Relations...)
+
+	if err := j.saveGraph(existing); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// setSource records who added an observation on the entity
+func setSource(entity *Entity, observation, source string) {
+	if entity.ObservationSources == nil {
+		entity.ObservationSources = make(map[string]string)
+	}
+	entity.ObservationSources[observation] = source
 }
 
 // jsonlEntity represents the JSONL format for entities
 type jsonlEntity struct {
-	Type         string   `json:"type"`
-	Name         string   `json:"name"`
-	EntityType   string   `json:"entityType"`
-	Observations []string `json:"observations"`
+	Type               string               `json:"type"`
+	Namespace          string               `json:"namespace,omitempty"` // empty = DefaultNamespace
+	Name               string               `json:"name"`
+	EntityType         string               `json:"entityType"`
+	Description        string               `json:"description,omitempty"`
+	Observations       []string             `json:"observations"`
+	ObservationSources map[string]string    `json:"observationSources,omitempty"`
+	ObservationTimes   map[string]time.Time `json:"observationTimes,omitempty"`
+	UpdatedAt          *time.Time           `json:"updatedAt,omitempty"`
 }
 
 // jsonlRelation represents the JSONL format for relations
 type jsonlRelation struct {
-	Type         string `json:"type"`
-	From         string `json:"from"`
-	To           string `json:"to"`
-	RelationType string `json:"relationType"`
+	Type         string     `json:"type"`
+	Namespace    string     `json:"namespace,omitempty"` // empty = DefaultNamespace
+	From         string     `json:"from"`
+	To           string     `json:"to"`
+	RelationType string     `json:"relationType"`
+	CreatedAt    *time.Time `json:"createdAt,omitempty"`
+}
+
+// UnmarshalJSON also accepts the snake_case field names other memory servers
+// write (entity_type, observation_sources), so their files import with types
+// intact. Saving always writes camelCase.
+func (e *jsonlEntity) UnmarshalJSON(data []byte) error {
+	type plain jsonlEntity
+	var entity struct {
+		plain
+		SnakeEntityType         string            `json:"entity_type"`
+		SnakeObservationSources map[string]string `json:"observation_sources"`
+	}
+	if err := json.Unmarshal(data, &entity); err != nil {
+		return err
+	}
+	*e = jsonlEntity(entity.plain)
+	if e.EntityType == "" {
+		e.EntityType = entity.SnakeEntityType
+	}
+	if e.ObservationSources == nil {
+		e.ObservationSources = entity.SnakeObservationSources
+	}
+	return nil
+}
+
+// UnmarshalJSON also accepts the snake_case field names relation_type and
+// created_at
+func (r *jsonlRelation) UnmarshalJSON(data []byte) error {
+	type plain jsonlRelation
+	var relation struct {
+		plain
+		SnakeRelationType string     `json:"relation_type"`
+		SnakeCreatedAt    *time.Time `json:"created_at"`
+	}
+	if err := json.Unmarshal(data, &relation); err != nil {
+		return err
+	}
+	*r = jsonlRelation(relation.plain)
+	if r.RelationType == "" {
+		r.RelationType = relation.SnakeRelationType
+	}
+	if r.CreatedAt == nil {
+		r.CreatedAt = relation.SnakeCreatedAt
+	}
+	return nil
 }
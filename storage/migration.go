@@ -81,15 +81,17 @@ func (m *Migrator) MigrateJSONLToSQLite(jsonlPath, sqlitePath string) (*Migratio
 
 	m.reportProgress(10, 100, "Reading source data...")
 
-	// Step 3: Export data from source
-	graph, err := source.ExportData()
+	// Step 3: Export data from source, one graph per namespace
+	namespaces, graphs, err := exportNamespaces(source)
 	if err != nil {
 		result.Error = fmt.Errorf("failed to export data: %w", err)
 		return result, result.Error
 	}
 
-	result.EntitiesCount = len(graph.Entities)
-	result.RelationsCount = len(graph.Relations)
+	for _, graph := range graphs {
+		result.EntitiesCount += len(graph.Entities)
+		result.RelationsCount += len(graph.Relations)
+	}
 
 	m.reportProgress(30, 100, fmt.Sprintf("Found %d entities and %d relations",
 		result.EntitiesCount, result.RelationsCount))
@@ -110,6 +112,8 @@ func (m *Migrator) MigrateJSONLToSQLite(jsonlPath, sqlitePath string) (*Migratio
 		WALMode:     true,
 		CacheSize:   10000,
 		BusyTimeout: 5 * time.Second,
+		FileMode:    m.config.FileMode,
+		FTS:         m.config.FTS,
 	}
 	dest, err := NewSQLiteStorage(sqliteConfig)
 	if err != nil {
@@ -126,17 +130,21 @@ func (m *Migrator) MigrateJSONLToSQLite(jsonlPath, sqlitePath string) (*Migratio
 	m.reportProgress(50, 100, "Importing data to SQLite...")
 
 	// Step 6: Import data in batches
-	if err := m.importInBatches(dest, graph); err != nil {
-		result.Error = fmt.Errorf("failed to import data: %w", err)
-		return result, result.Error
+	for _, ns := range namespaces {
+		if err := m.importInBatches(dest.WithNamespace(ns), graphs[ns]); err != nil {
+			result.Error = fmt.Errorf("failed to import data: %w", err)
+			return result, result.Error
+		}
 	}
 
 	m.reportProgress(90, 100, "Verifying migration...")
 
 	// Step 7: Verify migration
-	if err := m.verifyMigration(source, dest); err != nil {
-		result.Error = fmt.Errorf("migration verification failed: %w", err)
-		return result, result.Error
+	for _, ns := range namespaces {
+		if err := m.verifyMigration(source.WithNamespace(ns), dest.WithNamespace(ns)); err != nil {
+			result.Error = fmt.Errorf("migration verification failed: %w", err)
+			return result, result.Error
+		}
 	}
 
 	result.Success = true
@@ -176,6 +184,24 @@ func (m *Migrator) AutoMigrate(memoryPath string) (*MigrationResult, error) {
 	return m.MigrateJSONLToSQLite(memoryPath, sqlitePath)
 }
 
+// exportNamespaces exports the graph of every namespace in source
+func exportNamespaces(source Storage) ([]string, map[string]*KnowledgeGraph, error) {
+	namespaces, err := source.Namespaces()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	graphs := make(map[string]*KnowledgeGraph, len(namespaces))
+	for _, ns := range namespaces {
+		graph, err := source.WithNamespace(ns).ExportData()
+		if err != nil {
+			return nil, nil, fmt.Errorf("namespace %s: %w", ns, err)
+		}
+		graphs[ns] = graph
+	}
+	return namespaces, graphs, nil
+}
+
 // importInBatches imports data in batches to avoid memory issues
 func (m *Migrator) importInBatches(dest Storage, graph *KnowledgeGraph) error {
 	totalItems := len(graph.Entities) + len(graph.Relations)
@@ -342,22 +368,37 @@ func (m *Migrator) verifyMigration(source, dest Storage) error {
 
 // createBackupPath generates a backup file path
 func (m *Migrator) createBackupPath(originalPath string) string {
+	return backupPath(originalPath)
+}
+
+// createBackup creates a backup of the source file
+func (m *Migrator) createBackup(source, backup string) error {
+	return copyFile(source, backup, m.config.FileMode)
+}
+
+// backupPath returns a hidden, timestamped backup path next to originalPath
+func backupPath(originalPath string) string {
 	dir := filepath.Dir(originalPath)
 	base := filepath.Base(originalPath)
 	timestamp := time.Now().Format("20060102_150405")
 	return filepath.Join(dir, fmt.Sprintf(".%s.backup_%s", base, timestamp))
 }
 
-// createBackup creates a backup of the source file
-func (m *Migrator) createBackup(source, backup string) error {
+// copyFile copies source to backup with mode (0 = defaultFileMode)
+func copyFile(source, backup string, mode os.FileMode) error {
 	data, err := os.ReadFile(source)
 	if err != nil {
 		return fmt.Errorf("failed to read source file: %w", err)
 	}
 
-	if err := os.WriteFile(backup, data, 0644); err != nil {
+	if err := os.WriteFile(backup, data, fileModeOr(mode)); err != nil {
 		return fmt.Errorf("failed to write backup: %w", err)
 	}
+	if mode != 0 {
+		if err := os.Chmod(backup, mode); err != nil {
+			return fmt.Errorf("failed to set backup file mode: %w", err)
+		}
+	}
 
 	return nil
 }
@@ -412,13 +453,18 @@ func ExecuteMigration(cmd MigrateCommand) error {
 		}
 		defer source.Close()
 
-		graph, err := source.ExportData()
+		namespaces, graphs, err := exportNamespaces(source)
 		if err != nil {
 			return fmt.Errorf("failed to read source data: %w", err)
 		}
 
-		log.Printf("Would migrate %d entities and %d relations",
-			len(graph.Entities), len(graph.Relations))
+		entities, relations := 0, 0
+		for _, graph := range graphs {
+			entities += len(graph.Entities)
+			relations += len(graph.Relations)
+		}
+		log.Printf("Would migrate %d entities and %d relations in %d namespace(s)",
+			entities, relations, len(namespaces))
 
 		return nil
 	}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// DefaultMirrorDelay is how long after a change Config.MirrorJSONL is rewritten
+// when Config.MirrorDelay is unset; changes within it share one write
+const DefaultMirrorDelay = 2 * time.Second
+
+// jsonlMirror keeps a JSONL export of a SQLite store in sync with it, one way.
+// A change schedules a rewrite of every namespace; changes made before it runs
+// are picked up by the same write.
+type jsonlMirror struct {
+	source *SQLiteStorage
+	path   string
+	delay  time.Duration
+
+	writing sync.Mutex // held while writing, so an older export never lands last
+	mu      sync.Mutex
+	timer   *time.Timer // pending write, nil when the mirror is up to date
+	closed  bool
+}
+
+// startMirror sets up Config.MirrorJSONL and schedules its first write
+func (s *SQLiteStorage) startMirror() {
+	if s.config.MirrorJSONL == "" {
+		return
+	}
+	delay := s.config.MirrorDelay
+	if delay <= 0 {
+		delay = DefaultMirrorDelay
+	}
+	s.mirror = &jsonlMirror{source: s, path: s.config.MirrorJSONL, delay: delay}
+	s.mirror.schedule()
+}
+
+// schedule arranges a write delay from now unless one is already pending
+func (m *jsonlMirror) schedule() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.timer == nil && !m.closed {
+		m.timer = time.AfterFunc(m.delay, m.flush)
+	}
+}
+
+// flush runs when a scheduled write is due
+func (m *jsonlMirror) flush() {
+	m.writing.Lock()
+	defer m.writing.Unlock()
+
+	// Closing writes the pending change itself
+	m.mu.Lock()
+	closed := m.closed
+	m.timer = nil
+	m.mu.Unlock()
+	if closed {
+		return
+	}
+	m.write()
+}
+
+// write updates the mirror file, logging failures: the store stays
+// authoritative and the next change tries again. The caller holds m.writing.
+func (m *jsonlMirror) write() {
+	if err := m.export(); err != nil {
+		log.Printf("Failed to update JSONL mirror %s: %v", m.path, err)
+	}
+}
+
+// export writes every namespace of the store to the mirror file atomically
+func (m *jsonlMirror) export() error {
+	namespaces, graphs, err := exportNamespaces(m.source)
+	if err != nil {
+		return fmt.Errorf("failed to export graph: %w", err)
+	}
+	return writeGraphSet(m.path, &graphSet{order: namespaces, graphs: graphs}, m.source.fileOptions())
+}
+
+// stopMirror writes any pending change so the mirror is current on shutdown
+func (s *SQLiteStorage) stopMirror() {
+	if s.mirror == nil {
+		return
+	}
+	m := s.mirror
+	m.mu.Lock()
+	m.closed = true
+	pending := m.timer != nil
+	if pending {
+		m.timer.Stop()
+	}
+	m.mu.Unlock()
+
+	// Waits out a write the timer already started
+	m.writing.Lock()
+	defer m.writing.Unlock()
+	if pending {
+		m.write()
+	}
+	s.mirror = nil
+}
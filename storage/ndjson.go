@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// WriteNDJSON streams the namespace's graph to w as newline-delimited JSON, one
+// entity or relation per line in the JSONL memory file format, so the output can
+// be processed incrementally or loaded back with --seed. Each line is a separate
+// Write, letting callers flush as they go.
+func WriteNDJSON(source Storage, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	return source.WalkGraph(
+		func(entity Entity) error {
+			if entity.Observations == nil {
+				entity.Observations = []string{}
+			}
+			return enc.Encode(jsonlEntity{
+				Type:               "entity",
+				Name:               entity.Name,
+				EntityType:         entity.EntityType,
+				Description:        entity.Description,
+				Observations:       entity.Observations,
+				ObservationSources: pruneSources(entity.ObservationSources, entity.Observations),
+			})
+		},
+		func(relation Relation) error {
+			return enc.Encode(jsonlRelation{
+				Type:         "relation",
+				From:         relation.From,
+				To:           relation.To,
+				RelationType: relation.RelationType,
+			})
+		},
+	)
+}
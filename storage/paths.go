@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+)
+
+// allPaths finds up to maxPaths paths from from to to that are at most maxDepth
+// relations long and never revisit an entity. It searches depth-first once per
+// length, from 1 up to maxDepth, so the paths come out shortest first and
+// hitting maxPaths drops only the longest ones. outgoing returns the relations
+// leaving an entity; its results are cached, as every round asks again.
+func allPaths(from, to string, maxDepth, maxPaths int, outgoing func(name string) ([]Relation, error)) ([][]Relation, error) {
+	if from == to {
+		return nil, fmt.Errorf("%w: from and to must be different entities", ErrInvalidArgument)
+	}
+
+	cache := make(map[string][]Relation)
+	edges := func(name string) ([]Relation, error) {
+		if relations, ok := cache[name]; ok {
+			return relations, nil
+		}
+		relations, err := outgoing(name)
+		if err != nil {
+			return nil, err
+		}
+		// Stable order keeps the result the same across backends
+		sort.Slice(relations, func(a, b int) bool {
+			if relations[a].To != relations[b].To {
+				return relations[a].To < relations[b].To
+			}
+			return relations[a].RelationType < relations[b].RelationType
+		})
+		cache[name] = relations
+		return relations, nil
+	}
+
+	paths := [][]Relation{}
+	onPath := map[string]bool{from: true}
+	var path []Relation
+
+	// walk extends path by exactly length more relations ending at to
+	var walk func(name string, length int) error
+	walk = func(name string, length int) error {
+		relations, err := edges(name)
+		if err != nil {
+			return err
+		}
+		for _, relation := range relations {
+			if len(paths) >= maxPaths {
+				return nil
+			}
+			if length == 1 {
+				if relation.To == to {
+					paths = append(paths, append(append([]Relation{}, path...), relation))
+				}
+				continue
+			}
+			if onPath[relation.To] || relation.To == to {
+				continue // a cycle, or a path that would pass through to
+			}
+			onPath[relation.To] = true
+			path = append(path, relation)
+			if err := walk(relation.To, length-1); err != nil {
+				return err
+			}
+			path = path[:len(path)-1]
+			onPath[relation.To] = false
+		}
+		return nil
+	}
+
+	for length := 1; length <= maxDepth && len(paths) < maxPaths; length++ {
+		if err := walk(from, length); err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
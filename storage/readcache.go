@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"container/list"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// readCache is the LRU of OpenNodes and search results kept with
+// Config.ReadCacheSize; shared by namespace views. A nil cache caches nothing.
+type readCache struct {
+	mu         sync.Mutex
+	size       int
+	lru        *list.List // of *readCacheEntry, most recently used first
+	entries    map[string]*list.Element
+	generation int // bumped by every invalidation
+}
+
+// readCacheEntry is one cached result: graph for OpenNodes, search for searches
+type readCacheEntry struct {
+	key    string
+	ns     string
+	names  map[string]bool // entities an OpenNodes result involves
+	graph  *KnowledgeGraph
+	search *SearchResult
+}
+
+// newReadCache returns a cache of size entries, or nil when size is not positive
+func newReadCache(size int) *readCache {
+	if size <= 0 {
+		return nil
+	}
+	return &readCache{size: size, lru: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// openNodesKey and searchKey identify a read within the view's namespace and
+// observation order, the only settings views change
+func (s *SQLiteStorage) openNodesKey(names []string) string {
+	return fmt.Sprintf("open\x00%s\x00%s\x00%s", s.ns(), s.config.ObservationOrder, strings.Join(names, "\x00"))
+}
+
+func (s *SQLiteStorage) searchKey(query string, limit, maxSnippets int) string {
+	return fmt.Sprintf("search\x00%s\x00%d\x00%d\x00%s", s.ns(), limit, maxSnippets, query)
+}
+
+// start returns the generation to pass to put once the read is done, so a
+// result read while a write landed is not cached
+func (c *readCache) start() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// get returns a copy of the cached entry for key, or nil
+func (c *readCache) get(key string) *readCacheEntry {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+	entry := elem.Value.(*readCacheEntry)
+	return &readCacheEntry{graph: entry.graph.clone(), search: entry.search.clone()}
+}
+
+// put caches a copy of entry, evicting the least recently used entry when full
+func (c *readCache) put(generation int, entry *readCacheEntry) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation {
+		return
+	}
+	entry.graph, entry.search = entry.graph.clone(), entry.search.clone()
+	if elem, ok := c.entries[entry.key]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	if c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*readCacheEntry).key)
+	}
+}
+
+// invalidate drops what a write to the named entities of ns may have changed:
+// every search of ns and every OpenNodes result involving one of them. nil
+// names drop everything, for writes that may touch any entity or namespace.
+func (c *readCache) invalidate(ns string, names []string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	for elem := c.lru.Front(); elem != nil; {
+		next := elem.Next()
+		entry := elem.Value.(*readCacheEntry)
+		if names == nil || (entry.ns == ns && (entry.search != nil || slices.ContainsFunc(names, func(name string) bool { return entry.names[name] }))) {
+			c.lru.Remove(elem)
+			delete(c.entries, entry.key)
+		}
+		elem = next
+	}
+}
+
+// openNodesEntry returns the cache entry for an OpenNodes result: it involves
+// the names asked for, found or not, and both ends of every relation returned
+func openNodesEntry(key, ns string, names []string, graph *KnowledgeGraph) *readCacheEntry {
+	involved := make(map[string]bool, len(names))
+	for _, name := range names {
+		involved[name] = true
+	}
+	for _, relation := range graph.Relations {
+		involved[relation.From] = true
+		involved[relation.To] = true
+	}
+	return &readCacheEntry{key: key, ns: ns, names: involved, graph: graph}
+}
+
+// entityNames returns the names of entities, for retryWriteTouching
+func entityNames(entities []Entity) []string {
+	names := make([]string, len(entities))
+	for i, entity := range entities {
+		names[i] = entity.Name
+	}
+	return names
+}
+
+// relationEndpoints returns both ends of every relation, for retryWriteTouching
+func relationEndpoints(relations []Relation) []string {
+	names := make([]string, 0, 2*len(relations))
+	for _, relation := range relations {
+		names = append(names, relation.From, relation.To)
+	}
+	return names
+}
+
+// clone returns a copy of g sharing no slices or maps with it
+func (g *KnowledgeGraph) clone() *KnowledgeGraph {
+	if g == nil {
+		return nil
+	}
+	c := *g
+	c.Entities = make([]Entity, len(g.Entities))
+	for i, entity := range g.Entities {
+		entity.Observations = slices.Clone(entity.Observations)
+		entity.ObservationSources = maps.Clone(entity.ObservationSources)
+		entity.ObservationTimes = maps.Clone(entity.ObservationTimes)
+		c.Entities[i] = entity
+	}
+	c.Relations = slices.Clone(g.Relations)
+	return &c
+}
+
+// clone returns a copy of r sharing no slices with it
+func (r *SearchResult) clone() *SearchResult {
+	if r == nil {
+		return nil
+	}
+	c := *r
+	c.Entities = make([]EntitySearchHit, len(r.Entities))
+	for i, hit := range r.Entities {
+		hit.Snippets = slices.Clone(hit.Snippets)
+		c.Entities[i] = hit
+	}
+	c.RelatedEntities = slices.Clone(r.RelatedEntities)
+	return &c
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// RepairReport lists what RepairJSONL fixed (or would fix in a dry run)
+type RepairReport struct {
+	Path       string `json:"path"`
+	BackupPath string `json:"backupPath,omitempty"` // empty when nothing was written
+
+	TrimmedValues         int        `json:"trimmedValues"`         // names, types, observations and relation fields with surrounding whitespace
+	MergedEntities        []string   `json:"mergedEntities"`        // names that appeared more than once and were merged
+	DuplicateObservations int        `json:"duplicateObservations"` // repeated observations removed
+	EmptyObservations     int        `json:"emptyObservations"`     // blank observations removed
+	OrphanedRelations     []Relation `json:"orphanedRelations"`     // relations whose endpoints don't exist
+	DuplicateRelations    int        `json:"duplicateRelations"`    // repeated relations removed
+}
+
+// Changed reports whether the repair found anything to fix
+func (r *RepairReport) Changed() bool {
+	return r.TrimmedValues > 0 || len(r.MergedEntities) > 0 || r.DuplicateObservations > 0 ||
+		r.EmptyObservations > 0 || len(r.OrphanedRelations) > 0 || r.DuplicateRelations > 0
+}
+
+// RepairJSONL sanitizes a JSONL memory file: it trims whitespace, merges entities
+// that appear more than once (first type wins), removes duplicate and blank
+// observations, and drops duplicate relations and relations with missing endpoints.
+// Each namespace is repaired on its own. Unless dryRun is set, the original is backed up next to it before the cleaned
+// graph is written; files that need no repair are left untouched.
+func RepairJSONL(path string, dryRun bool) (*RepairReport, error) {
+	data, gzipped, err := readGraphContent(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	set := parseJSONLNamespaces(data)
+	set.gzip = gzipped
+
+	report := &RepairReport{
+		Path:              path,
+		MergedEntities:    []string{},
+		OrphanedRelations: []Relation{},
+	}
+	trim := func(s string) string {
+		trimmed := strings.TrimSpace(s)
+		if trimmed != s {
+			report.TrimmedValues++
+		}
+		return trimmed
+	}
+
+	for _, ns := range set.order {
+		set.graphs[ns] = repairGraph(set.graphs[ns], report, trim)
+	}
+
+	if dryRun || !report.Changed() {
+		return report, nil
+	}
+
+	report.BackupPath = backupPath(path)
+	if err := copyFile(path, report.BackupPath, 0); err != nil {
+		return nil, err
+	}
+	if err := writeGraphSet(path, set, fileOptions{}); err != nil {
+		return nil, fmt.Errorf("failed to write repaired file: %w", err)
+	}
+	return report, nil
+}
+
+// repairGraph returns the cleaned version of one namespace's graph, recording
+// what it fixed in report
+func repairGraph(graph *KnowledgeGraph, report *RepairReport, trim func(string) string) *KnowledgeGraph {
+	cleaned := &KnowledgeGraph{Entities: []Entity{}, Relations: []Relation{}}
+	index := make(map[string]int, len(graph.Entities))
+	for _, entity := range graph.Entities {
+		name, entityType := trim(entity.Name), trim(entity.EntityType)
+
+		i, seen := index[name]
+		if !seen {
+			index[name] = len(cleaned.Entities)
+			cleaned.Entities = append(cleaned.Entities, Entity{
+				Name:         name,
+				EntityType:   entityType,
+				Observations: []string{},
+			})
+			i = len(cleaned.Entities) - 1
+		} else if !slices.Contains(report.MergedEntities, name) {
+			report.MergedEntities = append(report.MergedEntities, name)
+		}
+
+		target := &cleaned.Entities[i]
+		if target.Description == "" {
+			target.Description = trim(entity.Description)
+		}
+		for _, obs := range entity.Observations {
+			trimmed := trim(obs)
+			switch {
+			case trimmed == "":
+				report.EmptyObservations++
+			case slices.Contains(target.Observations, trimmed):
+				report.DuplicateObservations++
+			default:
+				target.Observations = append(target.Observations, trimmed)
+				if source := entity.ObservationSources[obs]; source != "" {
+					setSource(target, trimmed, source)
+				}
+			}
+		}
+	}
+
+	seen := make(map[Relation]bool, len(graph.Relations))
+	for _, relation := range graph.Relations {
+		relation = Relation{From: trim(relation.From), To: trim(relation.To), RelationType: trim(relation.RelationType), CreatedAt: relation.CreatedAt}
+		_, fromOK := index[relation.From]
+		_, toOK := index[relation.To]
+		switch {
+		case !fromOK || !toOK:
+			report.OrphanedRelations = append(report.OrphanedRelations, relation)
+		case seen[relation.key()]:
+			report.DuplicateRelations++
+		default:
+			seen[relation.key()] = true
+			cleaned.Relations = append(cleaned.Relations, relation)
+		}
+	}
+	return cleaned
+}
@@ -4,6 +4,7 @@
 package storage
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -324,3 +325,75 @@ func TestSearchPriorityTypeMatch(t *testing.T) {
 	t.Logf("Type match priority test passed: '%s' (name) > '%s' (type) > '%s' (content)",
 		result.Entities[0].Name, result.Entities[1].Name, result.Entities[2].Name)
 }
+
+// TestSearchDeterministicOrderSQLite tests that entities with tied ranks are returned
+// in a stable order (by insertion) on every run, for both FTS and LIKE search paths
+func TestSearchDeterministicOrderSQLite(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "search_order_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	config := Config{
+		FilePath:    filepath.Join(tempDir, "test.db"),
+		WALMode:     true,
+		CacheSize:   1000,
+		BusyTimeout: 5000,
+	}
+	storage, err := NewSQLiteStorage(config)
+	if err != nil {
+		t.Fatalf("Failed to create SQLite storage: %v", err)
+	}
+	if err := storage.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize storage: %v", err)
+	}
+	defer storage.Close()
+
+	// Identical observations give every entity the same bm25 rank and LIKE priority
+	const count = 50
+	testEntities := make([]Entity, 0, count)
+	expected := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("Item-%02d", i)
+		testEntities = append(testEntities, Entity{
+			Name:         name,
+			EntityType:   "item",
+			Observations: []string{"Shared keyword observation"},
+		})
+		expected = append(expected, name)
+	}
+	if _, err := storage.CreateEntities(testEntities); err != nil {
+		t.Fatalf("Failed to create entities: %v", err)
+	}
+
+	// Pin timestamps so the recency boost ties too, regardless of insertion timing
+	if _, err := storage.db.Exec(`UPDATE entities SET created_at = '2024-01-01 00:00:00', updated_at = '2024-01-01 00:00:00'`); err != nil {
+		t.Fatalf("Failed to pin timestamps: %v", err)
+	}
+
+	searches := map[string]func() (*SearchResult, error){
+		"fts":   func() (*SearchResult, error) { return storage.SearchNodesWithFTS("keyword", 0) },
+		"basic": func() (*SearchResult, error) { return storage.searchNodesBasic("keyword", 0, 0) },
+	}
+	if !storage.isFTSAvailable() {
+		delete(searches, "fts")
+	}
+
+	for path, search := range searches {
+		for run := 0; run < 10; run++ {
+			result, err := search()
+			if err != nil {
+				t.Fatalf("%s search failed: %v", path, err)
+			}
+			if len(result.Entities) != count {
+				t.Fatalf("%s run %d: expected %d results, got %d", path, run, count, len(result.Entities))
+			}
+			for i, hit := range result.Entities {
+				if hit.Name != expected[i] {
+					t.Fatalf("%s run %d: position %d expected %q, got %q", path, run, i, expected[i], hit.Name)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package storage
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// What SimilarEntities compares
+const (
+	SimilarityBoth         = "both"         // the mean of the two scores below
+	SimilarityRelations    = "relations"    // shared relation neighbors
+	SimilarityObservations = "observations" // shared observation words
+)
+
+// Limits for SimilarEntities
+const (
+	DefaultSimilarLimit  = 10
+	MaxSimilarLimit      = 100
+	MaxSimilarCandidates = 200 // entities scored per measure, bounding the reads on large graphs
+	maxSimilarQueryWords = 20  // observation words used to find candidates
+	minSimilarWordLength = 3   // shorter words ("a", "is", "of") say little about an entity
+)
+
+// SimilarEntity is an entity scored by SimilarEntities. Overlaps are Jaccard
+// indexes between 0 and 1.
+type SimilarEntity struct {
+	Name               string   `json:"name"`
+	EntityType         string   `json:"entityType"`
+	Score              float64  `json:"score"`
+	NeighborOverlap    float64  `json:"neighborOverlap"`
+	ObservationOverlap float64  `json:"observationOverlap"`
+	SharedNeighbors    []string `json:"sharedNeighbors,omitempty"`
+}
+
+// SimilarEntities returns up to limit entities most like name (0 =
+// DefaultSimilarLimit), best first and ties by name. by chooses what is compared
+// ("" = SimilarityBoth): the entities each is related to in either direction,
+// the words of their observations, or both. Candidates are the entities sharing
+// a neighbor with name and those a search for its most distinctive words finds,
+// at most MaxSimilarCandidates of each. Entities scoring 0 are left out.
+func SimilarEntities(source Storage, name, by string, limit int) ([]SimilarEntity, error) {
+	if by == "" {
+		by = SimilarityBoth
+	}
+	if by != SimilarityBoth && by != SimilarityRelations && by != SimilarityObservations {
+		return nil, fmt.Errorf("%w: by %q must be both, relations, or observations", ErrInvalidArgument, by)
+	}
+	switch {
+	case limit == 0:
+		limit = DefaultSimilarLimit
+	case limit < 0 || limit > MaxSimilarLimit:
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidArgument, MaxSimilarLimit)
+	}
+	if types, err := source.EntityTypes([]string{name}); err != nil {
+		return nil, err
+	} else if _, ok := types[name]; !ok {
+		return nil, entityNotFound(name)
+	}
+
+	scores := make(map[string]*SimilarEntity)
+	score := func(candidate string) *SimilarEntity {
+		if scores[candidate] == nil {
+			scores[candidate] = &SimilarEntity{Name: candidate}
+		}
+		return scores[candidate]
+	}
+
+	if by != SimilarityObservations {
+		neighbors, err := neighborSets(source)
+		if err != nil {
+			return nil, err
+		}
+		target := neighbors[name]
+		shared := make(map[string][]string)
+		for neighbor := range target {
+			for candidate := range neighbors[neighbor] {
+				if candidate != name {
+					shared[candidate] = append(shared[candidate], neighbor)
+				}
+			}
+		}
+		candidates := make([]string, 0, len(shared))
+		for candidate := range shared {
+			candidates = append(candidates, candidate)
+		}
+		slices.SortFunc(candidates, func(a, b string) int {
+			return cmp.Or(cmp.Compare(len(shared[b]), len(shared[a])), strings.Compare(a, b))
+		})
+		for _, candidate := range candidates[:min(len(candidates), MaxSimilarCandidates)] {
+			s := score(candidate)
+			s.SharedNeighbors = shared[candidate]
+			slices.Sort(s.SharedNeighbors)
+			s.NeighborOverlap = jaccard(len(s.SharedNeighbors), len(target), len(neighbors[candidate]))
+		}
+	}
+
+	if by != SimilarityRelations {
+		target, err := entityWords(source, name)
+		if err != nil {
+			return nil, err
+		}
+		if len(target) > 0 {
+			result, err := source.SearchNodes(strings.Join(distinctiveWords(target), " "), MaxSimilarCandidates)
+			if err != nil {
+				return nil, err
+			}
+			for _, hit := range result.Entities {
+				if hit.Name != name {
+					score(hit.Name)
+				}
+			}
+			for candidate, s := range scores {
+				words, err := entityWords(source, candidate)
+				if err != nil {
+					return nil, err
+				}
+				common := 0
+				for word := range words {
+					if target[word] {
+						common++
+					}
+				}
+				s.ObservationOverlap = jaccard(common, len(target), len(words))
+			}
+		}
+	}
+
+	similar := []SimilarEntity{}
+	for _, s := range scores {
+		switch by {
+		case SimilarityRelations:
+			s.Score = s.NeighborOverlap
+		case SimilarityObservations:
+			s.Score = s.ObservationOverlap
+		default:
+			s.Score = (s.NeighborOverlap + s.ObservationOverlap) / 2
+		}
+		if s.Score > 0 {
+			similar = append(similar, *s)
+		}
+	}
+	slices.SortFunc(similar, func(a, b SimilarEntity) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), strings.Compare(a.Name, b.Name))
+	})
+	similar = similar[:min(len(similar), limit)]
+
+	names := make([]string, len(similar))
+	for i, s := range similar {
+		names[i] = s.Name
+	}
+	types, err := source.EntityTypes(names)
+	if err != nil {
+		return nil, err
+	}
+	for i := range similar {
+		similar[i].EntityType = types[similar[i].Name]
+	}
+	return similar, nil
+}
+
+// neighborSets maps every entity to the entities it is related to in either
+// direction, reading the relations in a single pass
+func neighborSets(source Storage) (map[string]map[string]bool, error) {
+	relations, err := source.ListRelations(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	neighbors := make(map[string]map[string]bool)
+	link := func(from, to string) {
+		if neighbors[from] == nil {
+			neighbors[from] = make(map[string]bool)
+		}
+		neighbors[from][to] = true
+	}
+	for _, relation := range relations.Relations {
+		if relation.From != relation.To {
+			link(relation.From, relation.To)
+			link(relation.To, relation.From)
+		}
+	}
+	return neighbors, nil
+}
+
+// entityWords returns the lowercased words of all of name's observations,
+// leaving out those shorter than minSimilarWordLength
+func entityWords(source Storage, name string) (map[string]bool, error) {
+	page, err := source.GetObservations(name, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	words := make(map[string]bool)
+	for _, obs := range page.Observations {
+		for _, word := range observationWords(obs) {
+			if utf8.RuneCountInString(word) >= minSimilarWordLength {
+				words[word] = true
+			}
+		}
+	}
+	return words, nil
+}
+
+// observationWords splits an observation into lowercased words: runs of
+// letters and digits
+func observationWords(obs string) []string {
+	return strings.FieldsFunc(strings.ToLower(obs), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// distinctiveWords returns the maxSimilarQueryWords longest of words, taking
+// longer words as the more specific
+func distinctiveWords(words map[string]bool) []string {
+	sorted := make([]string, 0, len(words))
+	for word := range words {
+		sorted = append(sorted, word)
+	}
+	slices.SortFunc(sorted, func(a, b string) int {
+		return cmp.Or(cmp.Compare(utf8.RuneCountInString(b), utf8.RuneCountInString(a)), strings.Compare(a, b))
+	})
+	return sorted[:min(len(sorted), maxSimilarQueryWords)]
+}
+
+// jaccard returns the Jaccard index of two sets of sizes a and b sharing common
+// members (0 when both are empty)
+func jaccard(common, a, b int) float64 {
+	if union := a + b - common; union > 0 {
+		return float64(common) / float64(union)
+	}
+	return 0
+}
@@ -1,9 +1,17 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"maps"
+	"math"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
@@ -13,23 +21,81 @@ type SQLiteStorage struct {
 	db     *sql.DB // write connection (single conn)
 	dbRead *sql.DB // read connection pool (multiple conns)
 	config Config
+	closed bool // set by Close
+
+	// Periodic WAL checkpoints (see startCheckpoints)
+	checkpointStop chan struct{}
+	checkpointDone chan struct{}
+
+	// JSONL export kept in sync with the database (see startMirror); shared
+	// by namespace views
+	mirror *jsonlMirror
+
+	// Write counts behind cached analytics (see Analytics); shared by namespace views
+	analytics *analyticsState
+
+	// Cached OpenNodes and search results (see Config.ReadCacheSize); shared
+	// by namespace views
+	cache *readCache
 }
 
 // NewSQLiteStorage creates a new SQLite storage instance
 func NewSQLiteStorage(config Config) (*SQLiteStorage, error) {
+	if config.EncryptionKey != "" {
+		return nil, fmt.Errorf("%w: encryption at rest requires JSONL storage (SQLite databases cannot be encrypted)", ErrInvalidArgument)
+	}
 	s := &SQLiteStorage{config: config}
 	return s, nil
 }
 
+// connectionPragmas returns the per-connection pragmas from the config. They go
+// in the DSN rather than through Exec, which would reach only whichever pooled
+// connection happened to run it.
+func (s *SQLiteStorage) connectionPragmas() []string {
+	var pragmas []string
+	if s.config.BusyTimeout > 0 {
+		pragmas = append(pragmas, fmt.Sprintf("busy_timeout(%d)", s.config.BusyTimeout.Milliseconds()))
+	}
+	if s.config.CacheSize > 0 {
+		pragmas = append(pragmas, fmt.Sprintf("cache_size(%d)", s.config.CacheSize))
+	}
+	if !s.config.DisableForeignKeys {
+		pragmas = append(pragmas, "foreign_keys(1)")
+	}
+	return pragmas
+}
+
+// sqliteDSN returns the data source name that opens path with pragmas applied
+// to every new connection
+func sqliteDSN(path string, pragmas []string) string {
+	if len(pragmas) == 0 {
+		return path
+	}
+	query := url.Values{"_pragma": pragmas}
+	return path + "?" + query.Encode()
+}
+
 // Initialize sets up the SQLite database
 func (s *SQLiteStorage) Initialize() error {
+	// Creating the file first gives it the configured mode; SQLite gives the
+	// -wal and -shm files the mode of the database file
+	if s.config.FilePath != ":memory:" {
+		if err := prepareFile(s.config.FilePath, s.config.FileMode); err != nil {
+			return err
+		}
+	}
+
 	var err error
-	s.db, err = sql.Open("sqlite", s.config.FilePath)
+	s.db, err = sql.Open("sqlite", sqliteDSN(s.config.FilePath, s.connectionPragmas()))
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
 
-	// Configure SQLite for better performance
+	// Limit write connection to 1 (SQLite serializes writes anyway)
+	s.db.SetMaxOpenConns(1)
+
+	// Configure SQLite for better performance (the journal mode is stored in the
+	// database file, so one connection setting it covers all of them)
 	if s.config.WALMode {
 		_, err = s.db.Exec("PRAGMA journal_mode=WAL")
 		if err != nil {
@@ -37,23 +103,6 @@ func (s *SQLiteStorage) Initialize() error {
 		}
 	}
 
-	if s.config.CacheSize > 0 {
-		_, err = s.db.Exec(fmt.Sprintf("PRAGMA cache_size=%d", s.config.CacheSize))
-		if err != nil {
-			return fmt.Errorf("failed to set cache size: %w", err)
-		}
-	}
-
-	if s.config.BusyTimeout > 0 {
-		_, err = s.db.Exec(fmt.Sprintf("PRAGMA busy_timeout=%d", s.config.BusyTimeout.Milliseconds()))
-		if err != nil {
-			return fmt.Errorf("failed to set busy timeout: %w", err)
-		}
-	}
-
-	// Limit write connection to 1 (SQLite serializes writes anyway)
-	s.db.SetMaxOpenConns(1)
-
 	// Create schema
 	if err = s.createSchema(); err != nil {
 		return fmt.Errorf("failed to create schema: %w", err)
@@ -64,31 +113,125 @@ func (s *SQLiteStorage) Initialize() error {
 		return fmt.Errorf("failed to migrate schema: %w", err)
 	}
 
-	// Try to create FTS schema (optional, will fallback to regular search if it fails)
-	if err = s.createFTSSchema(); err != nil {
-		// Log warning but don't fail initialization
-		// Silently fallback - don't print to stdout in MCP mode
-		// FTS5 is optional, basic search will work fine
+	// Deletes rely on ON DELETE CASCADE, which SQLite only honors with
+	// foreign keys on; a driver ignoring the DSN pragma must not go unnoticed
+	if !s.config.DisableForeignKeys {
+		var enabled bool
+		if err := s.db.QueryRow("PRAGMA foreign_keys").Scan(&enabled); err != nil {
+			return fmt.Errorf("failed to check foreign keys: %w", err)
+		}
+		if !enabled {
+			return fmt.Errorf("foreign key enforcement could not be enabled; deleting entities would leave their observations and relations behind")
+		}
+	}
+
+	// Try to create FTS schema (optional, will fallback to regular search if it fails).
+	// A database used without FTS holds rows a new index has never seen, so
+	// the index is built from them once.
+	if s.config.ftsEnabled() {
+		hadFTS := s.isFTSAvailable()
+		if err = s.createFTSSchema(); err == nil && !hadFTS {
+			err = s.rebuildFTSIndex()
+		}
+		if err != nil {
+			// Log warning but don't fail initialization
+			// Silently fallback - don't print to stdout in MCP mode
+			// FTS5 is optional, basic search will work fine
+		}
+	}
+
+	// An in-memory database exists only on the write connection; a separate
+	// pool would open empty databases that never see its writes
+	if s.config.FilePath == ":memory:" {
+		return nil
 	}
 
-	// Open a separate read connection pool to leverage WAL concurrency
-	s.dbRead, err = sql.Open("sqlite", s.config.FilePath)
+	// Open a separate read connection pool to leverage WAL concurrency. Every
+	// read connection gets the same pragmas and is marked query-only for safety.
+	// Reads see every committed write: each statement outside a transaction
+	// starts a fresh snapshot, so nothing here caches results across calls.
+	s.dbRead, err = sql.Open("sqlite", sqliteDSN(s.config.FilePath, append(s.connectionPragmas(), "query_only(1)")))
 	if err != nil {
 		return fmt.Errorf("failed to open read database: %w", err)
 	}
 	s.dbRead.SetMaxOpenConns(4) // Allow concurrent reads
 
-	// Configure read connection with same pragmas (minus WAL which is db-level)
-	if s.config.CacheSize > 0 {
-		s.dbRead.Exec(fmt.Sprintf("PRAGMA cache_size=%d", s.config.CacheSize))
+	s.startCheckpoints()
+	s.analytics = &analyticsState{computed: make(map[string]int)}
+	s.cache = newReadCache(s.config.ReadCacheSize)
+	s.startMirror()
+	return nil
+}
+
+// StorageInfo reports the database location, schema version, journal mode,
+// foreign key enforcement, FTS availability, and the namespace's entity and
+// relation counts
+func (s *SQLiteStorage) StorageInfo() (*StorageInfo, error) {
+	path, err := filepath.Abs(s.config.FilePath)
+	if err != nil {
+		return nil, err
 	}
-	if s.config.BusyTimeout > 0 {
-		s.dbRead.Exec(fmt.Sprintf("PRAGMA busy_timeout=%d", s.config.BusyTimeout.Milliseconds()))
+	info := &StorageInfo{Backend: "sqlite", FilePath: path, Namespace: s.ns(), FTSAvailable: s.useFTS()}
+
+	err = s.rdb().QueryRow("SELECT value FROM metadata WHERE key = 'schema_version'").Scan(&info.SchemaVersion)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if err := s.rdb().QueryRow("PRAGMA journal_mode").Scan(&info.JournalMode); err != nil {
+		return nil, fmt.Errorf("failed to read journal mode: %w", err)
+	}
+	info.WALEnabled = strings.EqualFold(info.JournalMode, "wal")
+	if err := s.db.QueryRow("PRAGMA foreign_keys").Scan(&info.ForeignKeys); err != nil {
+		return nil, fmt.Errorf("failed to read foreign keys: %w", err)
 	}
-	// Mark read connections as query-only for safety
-	s.dbRead.Exec("PRAGMA query_only=ON")
 
-	return nil
+	if err := s.rdb().QueryRow("SELECT COUNT(*) FROM entities WHERE namespace = ?", s.ns()).Scan(&info.Entities); err != nil {
+		return nil, fmt.Errorf("failed to count entities: %w", err)
+	}
+	if err := s.rdb().QueryRow("SELECT COUNT(*) FROM relations WHERE from_entity_id IN (SELECT id FROM entities WHERE namespace = ?)", s.ns()).Scan(&info.Relations); err != nil {
+		return nil, fmt.Errorf("failed to count relations: %w", err)
+	}
+	return info, nil
+}
+
+// WithNamespace returns a view of the database scoped to ns that shares this
+// storage's connections
+func (s *SQLiteStorage) WithNamespace(ns string) Storage {
+	view := *s
+	view.config.Namespace = ns
+	return &view
+}
+
+// WithObservationOrder returns a view of the same database listing observations in order
+func (s *SQLiteStorage) WithObservationOrder(order string) Storage {
+	view := *s
+	view.config.ObservationOrder = order
+	return &view
+}
+
+// Namespaces lists the namespaces that hold entities, plus the active one
+func (s *SQLiteStorage) Namespaces() ([]string, error) {
+	rows, err := s.rdb().Query("SELECT DISTINCT namespace FROM entities")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query namespaces: %w", err)
+	}
+	defer rows.Close()
+
+	namespaces := []string{s.ns()}
+	for rows.Next() {
+		var ns string
+		if err := rows.Scan(&ns); err != nil {
+			return nil, err
+		}
+		if ns != s.ns() {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	slices.Sort(namespaces)
+	return namespaces, nil
 }
 
 // createSchema creates the database schema
@@ -114,6 +257,8 @@ func (s *SQLiteStorage) createSchema() error {
 		UNIQUE(entity_id, content)
 	);
 	CREATE INDEX IF NOT EXISTS idx_observations_entity ON observations(entity_id);
+	-- Exact content lookups only; '%query%' LIKE patterns cannot use an index (use FTS5 for search)
+	CREATE INDEX IF NOT EXISTS idx_observations_content ON observations(content);
 	
 	-- Relations table
 	CREATE TABLE IF NOT EXISTS relations (
@@ -144,60 +289,189 @@ func (s *SQLiteStorage) createSchema() error {
 	return err
 }
 
-// migrateSchema adds new columns to existing tables (idempotent)
-func (s *SQLiteStorage) migrateSchema() error {
-	// Each migration is a column addition that silently succeeds if column already exists
-	migrations := []string{
+// schemaMigration upgrades the schema from the previous version to version
+type schemaMigration struct {
+	version    string
+	statements []string
+}
+
+// schemaMigrations are applied in order to databases whose schema_version is older.
+// Append new steps at the end; never edit a released one.
+var schemaMigrations = []schemaMigration{
+	{"2.0", []string{
 		// Time awareness: track last access and access frequency for decay-based ranking
 		"ALTER TABLE entities ADD COLUMN last_accessed_at TIMESTAMP",
 		"ALTER TABLE entities ADD COLUMN access_count INTEGER DEFAULT 0",
+	}},
+	{"3.0", []string{
 		// Observation metadata: source tracking, confidence scoring, tagging
 		"ALTER TABLE observations ADD COLUMN source TEXT DEFAULT ''",
 		"ALTER TABLE observations ADD COLUMN confidence REAL DEFAULT 1.0",
 		"ALTER TABLE observations ADD COLUMN tags TEXT DEFAULT '[]'",
+		// Synonyms table for query expansion, seeded with common tech abbreviations
+		`CREATE TABLE IF NOT EXISTS synonyms (
+			term TEXT PRIMARY KEY,
+			expanded TEXT NOT NULL
+		)`,
+		`INSERT OR IGNORE INTO synonyms (term, expanded) VALUES
+			('js', 'javascript'), ('ts', 'typescript'), ('py', 'python'),
+			('rb', 'ruby'), ('rs', 'rust'), ('kt', 'kotlin'),
+			('react', 'reactjs'), ('vue', 'vuejs'), ('ng', 'angular'),
+			('k8s', 'kubernetes'), ('tf', 'terraform'), ('gh', 'github'),
+			('db', 'database'), ('api', 'interface'), ('cli', 'command'),
+			('ui', 'interface'), ('ml', 'machine learning'), ('ai', 'artificial intelligence')`,
+	}},
+	{"4.0", []string{
+		// Namespaces: entity names become unique per namespace, so the table is rebuilt.
+		// The FTS triggers reference entities and would block the rename; createFTSSchema
+		// recreates them after migrations run.
+		"DROP TRIGGER IF EXISTS entities_fts_insert",
+		"DROP TRIGGER IF EXISTS entities_fts_delete",
+		"DROP TRIGGER IF EXISTS entities_fts_update",
+		"DROP TRIGGER IF EXISTS observations_fts_insert",
+		"DROP TRIGGER IF EXISTS observations_fts_delete",
+		"DROP TRIGGER IF EXISTS observations_fts_update",
+		`CREATE TABLE entities_v4 (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			namespace TEXT NOT NULL DEFAULT 'default',
+			name TEXT NOT NULL,
+			entity_type TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			last_accessed_at TIMESTAMP,
+			access_count INTEGER DEFAULT 0,
+			UNIQUE(namespace, name)
+		)`,
+		`INSERT INTO entities_v4 (id, name, entity_type, created_at, updated_at, last_accessed_at, access_count)
+			SELECT id, name, entity_type, created_at, updated_at, last_accessed_at, access_count FROM entities`,
+		"DROP TABLE entities",
+		"ALTER TABLE entities_v4 RENAME TO entities",
+		"CREATE INDEX IF NOT EXISTS idx_entities_type ON entities(entity_type)",
+		"CREATE INDEX IF NOT EXISTS idx_entities_namespace ON entities(namespace)",
+	}},
+	{"5.0", []string{
+		// Idempotency keys: results of retried tool calls, replayed instead of re-run
+		`CREATE TABLE IF NOT EXISTS idempotency_keys (
+			namespace TEXT NOT NULL,
+			tool TEXT NOT NULL,
+			key TEXT NOT NULL,
+			result TEXT NOT NULL,
+			created_at INTEGER NOT NULL, -- unix milliseconds
+			PRIMARY KEY (namespace, tool, key)
+		)`,
+	}},
+	{"6.0", []string{
+		// Entity descriptions: entities_fts gains a description column, so it is
+		// dropped with its triggers and rebuilt by createFTSSchema
+		"ALTER TABLE entities ADD COLUMN description TEXT NOT NULL DEFAULT ''",
+		"DROP TRIGGER IF EXISTS entities_fts_insert",
+		"DROP TRIGGER IF EXISTS entities_fts_delete",
+		"DROP TRIGGER IF EXISTS entities_fts_update",
+		"DROP TABLE IF EXISTS entities_fts",
+	}},
+	{"7.0", []string{
+		// Type descriptions: what each entity and relation type of a namespace means
+		`CREATE TABLE IF NOT EXISTS type_descriptions (
+			namespace TEXT NOT NULL,
+			kind TEXT NOT NULL, -- entity or relation
+			type TEXT NOT NULL,
+			description TEXT NOT NULL,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (namespace, kind, type)
+		)`,
+	}},
+}
+
+// currentSchemaVersion is the version a fully migrated database reports
+var currentSchemaVersion = schemaMigrations[len(schemaMigrations)-1].version
+
+// schemaVersionLess compares two "major.minor" schema versions
+func schemaVersionLess(a, b string) bool {
+	av, errA := strconv.ParseFloat(a, 64)
+	bv, errB := strconv.ParseFloat(b, 64)
+	if errA != nil || errB != nil {
+		return a < b
 	}
+	return av < bv
+}
 
-	for _, m := range migrations {
-		_, err := s.db.Exec(m)
-		if err != nil {
-			// Ignore "duplicate column" errors — column already exists
-			if !strings.Contains(err.Error(), "duplicate column") {
-				return fmt.Errorf("migration failed (%s): %w", m, err)
-			}
+// migrateSchema reads schema_version and applies each newer migration in its own
+// transaction, recording the version as it goes. Databases from newer versions
+// are left untouched.
+func (s *SQLiteStorage) migrateSchema() error {
+	var version string
+	err := s.db.QueryRow("SELECT value FROM metadata WHERE key = 'schema_version'").Scan(&version)
+	if err == sql.ErrNoRows {
+		version = "1.0"
+	} else if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for _, m := range schemaMigrations {
+		if !schemaVersionLess(version, m.version) {
+			continue
 		}
+		if err := s.applyMigration(m); err != nil {
+			return err
+		}
+		version = m.version
 	}
+	return nil
+}
 
-	// Create synonyms table for query expansion
-	_, _ = s.db.Exec(`CREATE TABLE IF NOT EXISTS synonyms (
-		term TEXT PRIMARY KEY,
-		expanded TEXT NOT NULL
-	)`)
+// applyMigration runs one schema migration and bumps schema_version atomically.
+// Foreign keys are off meanwhile, as SQLite requires for rebuilding a table:
+// dropping the old entities table would otherwise cascade to every
+// observation and relation. The pragma is ignored inside a transaction, so it
+// is set on a connection held for the migration.
+func (s *SQLiteStorage) applyMigration(m schemaMigration) error {
+	ctx := context.Background()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration to %s: %w", m.version, err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return fmt.Errorf("failed to begin migration to %s: %w", m.version, err)
+	}
+	if !s.config.DisableForeignKeys {
+		defer conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+	}
 
-	// Seed common tech synonyms
-	defaultSynonyms := [][2]string{
-		{"js", "javascript"}, {"ts", "typescript"}, {"py", "python"},
-		{"rb", "ruby"}, {"rs", "rust"}, {"kt", "kotlin"},
-		{"react", "reactjs"}, {"vue", "vuejs"}, {"ng", "angular"},
-		{"k8s", "kubernetes"}, {"tf", "terraform"}, {"gh", "github"},
-		{"db", "database"}, {"api", "interface"}, {"cli", "command"},
-		{"ui", "interface"}, {"ml", "machine learning"}, {"ai", "artificial intelligence"},
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration to %s: %w", m.version, err)
 	}
-	synonymStmt, _ := s.db.Prepare("INSERT OR IGNORE INTO synonyms (term, expanded) VALUES (?, ?)")
-	if synonymStmt != nil {
-		for _, syn := range defaultSynonyms {
-			synonymStmt.Exec(syn[0], syn[1])
+	defer tx.Rollback()
+
+	for _, stmt := range m.statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			// Tolerate columns added by pre-versioning builds that ran every ALTER on startup
+			if !strings.Contains(err.Error(), "duplicate column") {
+				return fmt.Errorf("migration to %s failed (%s): %w", m.version, stmt, err)
+			}
 		}
-		synonymStmt.Close()
+	}
+	if _, err := tx.Exec("INSERT OR REPLACE INTO metadata (key, value) VALUES ('schema_version', ?)", m.version); err != nil {
+		return fmt.Errorf("failed to record schema version %s: %w", m.version, err)
 	}
 
-	// Update schema version
-	_, _ = s.db.Exec("INSERT OR REPLACE INTO metadata (key, value) VALUES ('schema_version', '3.0')")
-
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration to %s: %w", m.version, err)
+	}
 	return nil
 }
 
-// Close closes both read and write database connections
+// Close closes both read and write database connections. Closing again is a
+// no-op, so no final checkpoint runs against the closed database.
 func (s *SQLiteStorage) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	s.stopMirror()
+	s.stopCheckpoints()
+
 	var errs []error
 	if s.dbRead != nil {
 		if err := s.dbRead.Close(); err != nil {
@@ -227,10 +501,73 @@ func (s *SQLiteStorage) rdb() *sql.DB {
 // batchThreshold is the entity count above which bulk optimizations are applied
 const batchThreshold = 20
 
+// ns returns the graph namespace this storage is scoped to
+func (s *SQLiteStorage) ns() string {
+	return s.config.namespace()
+}
+
+// entityScope returns a WHERE condition selecting the entities (under alias, "" for
+// none) that belong to the storage's namespace and pass filter
+func (s *SQLiteStorage) entityScope(alias string, filter TypeFilter) (string, []interface{}) {
+	prefix := ""
+	if alias != "" {
+		prefix = alias + "."
+	}
+	cond, args := filter.sqlCondition(prefix + "entity_type")
+	return prefix + "namespace = ? AND " + cond, append([]interface{}{s.ns()}, args...)
+}
+
+// retryWrite runs a write transaction, retrying it when the database is locked,
+// and schedules the JSONL mirror after it succeeds
+func (s *SQLiteStorage) retryWrite(op func() error) error {
+	return s.retryWriteTouching(nil, op)
+}
+
+// retryWriteTouching is retryWrite for a write that changes only the named
+// entities of the namespace and relations to or from them, so cached reads of
+// other entities stay valid (nil = any entity, in any namespace)
+func (s *SQLiteStorage) retryWriteTouching(names []string, op func() error) error {
+	retries, backoff := s.config.writeRetryPolicy()
+	if err := retryOnBusy(retries, backoff, op); err != nil {
+		return err
+	}
+	s.mirror.schedule()
+	s.analytics.written()
+	s.cache.invalidate(s.ns(), names)
+	return nil
+}
+
 // CreateEntities creates new entities in the database.
 // For large batches (>20 entities), FTS triggers are temporarily disabled
 // and the FTS index is rebuilt after insertion for better performance.
 func (s *SQLiteStorage) CreateEntities(entities []Entity) ([]Entity, error) {
+	return s.CreateEntitiesWithStrategy(entities, MergeAppend)
+}
+
+// CreateEntitiesWithStrategy creates entities, merging into existing ones by strategy
+func (s *SQLiteStorage) CreateEntitiesWithStrategy(entities []Entity, strategy string) ([]Entity, error) {
+	strategy, err := mergeStrategy(strategy)
+	if err != nil {
+		return nil, err
+	}
+	var created []Entity
+	err = s.retryWriteTouching(entityNames(entities), func() (err error) {
+		created, err = s.createEntities(entities, strategy)
+		return err
+	})
+	return created, err
+}
+
+// entityUpserts is the ON CONFLICT action for an existing entity under each merge strategy;
+// the description columns mirror mergeDescription
+var entityUpserts = map[string]string{
+	MergeAppend:  "entity_type = excluded.entity_type, description = COALESCE(NULLIF(excluded.description, ''), description), updated_at = CURRENT_TIMESTAMP",
+	MergeReplace: "entity_type = excluded.entity_type, description = COALESCE(NULLIF(excluded.description, ''), description), updated_at = CURRENT_TIMESTAMP",
+	MergeKeep:    "entity_type = COALESCE(NULLIF(entity_type, ''), excluded.entity_type), description = COALESCE(NULLIF(description, ''), excluded.description)",
+}
+
+// createEntities performs a single CreateEntities attempt
+func (s *SQLiteStorage) createEntities(entities []Entity, strategy string) ([]Entity, error) {
 	if len(entities) == 0 {
 		return []Entity{}, nil
 	}
@@ -249,14 +586,12 @@ func (s *SQLiteStorage) CreateEntities(entities []Entity) ([]Entity, error) {
 		tx.Exec("DROP TRIGGER IF EXISTS observations_fts_insert")
 	}
 
-	// Prepare statements
+	// Prepare statements (the upsert action comes from the whitelist above)
 	entityStmt, err := tx.Prepare(`
-		INSERT INTO entities (name, entity_type)
-		VALUES (?, ?)
-		ON CONFLICT(name) DO UPDATE SET
-			entity_type = excluded.entity_type,
-			updated_at = CURRENT_TIMESTAMP
-		RETURNING id
+		INSERT INTO entities (namespace, name, entity_type, description)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(namespace, name) DO UPDATE SET ` + entityUpserts[strategy] + `
+		RETURNING id, description
 	`)
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare entity statement: %w", err)
@@ -264,8 +599,8 @@ func (s *SQLiteStorage) CreateEntities(entities []Entity) ([]Entity, error) {
 	defer entityStmt.Close()
 
 	obsStmt, err := tx.Prepare(`
-		INSERT INTO observations (entity_id, content)
-		VALUES (?, ?)
+		INSERT INTO observations (entity_id, content, source)
+		VALUES (?, ?, ?)
 		ON CONFLICT(entity_id, content) DO NOTHING
 	`)
 	if err != nil {
@@ -276,15 +611,50 @@ func (s *SQLiteStorage) CreateEntities(entities []Entity) ([]Entity, error) {
 	created := make([]Entity, 0, len(entities))
 
 	for _, entity := range entities {
+		// Only a new entity created without observations gets its type's template
+		if len(entity.Observations) == 0 && len(s.config.EntityTemplates[entity.EntityType]) > 0 {
+			var exists bool
+			if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM entities WHERE namespace = ? AND name = ?)", s.ns(), entity.Name).Scan(&exists); err != nil {
+				return nil, fmt.Errorf("failed to check entity %s: %w", entity.Name, err)
+			}
+			if !exists {
+				entity.Observations = s.config.templateObservations(entity)
+			}
+		}
+
 		var entityID int64
-		err = entityStmt.QueryRow(entity.Name, entity.EntityType).Scan(&entityID)
+		err = entityStmt.QueryRow(s.ns(), entity.Name, entity.EntityType, entity.Description).Scan(&entityID, &entity.Description)
 		if err != nil {
 			return nil, fmt.Errorf("failed to insert entity %s: %w", entity.Name, err)
 		}
 
+		switch strategy {
+		case MergeReplace:
+			if _, err := tx.Exec("DELETE FROM observations WHERE entity_id = ?", entityID); err != nil {
+				return nil, fmt.Errorf("failed to replace observations for %s: %w", entity.Name, err)
+			}
+		case MergeKeep:
+			var existing int
+			if err := tx.QueryRow("SELECT COUNT(*) FROM observations WHERE entity_id = ?", entityID).Scan(&existing); err != nil {
+				return nil, fmt.Errorf("failed to count observations for %s: %w", entity.Name, err)
+			}
+			if existing > 0 {
+				created = append(created, entity)
+				continue
+			}
+		}
+
+		keys, err := s.storedObservationKeys(tx, entity.Name)
+		if err != nil {
+			return nil, err
+		}
+
 		// Insert observations
 		for _, obs := range entity.Observations {
-			_, err = obsStmt.Exec(entityID, obs)
+			if keys.seen(s.config.observationKey(obs)) {
+				continue
+			}
+			_, err = obsStmt.Exec(entityID, obs, entity.ObservationSources[obs])
 			if err != nil {
 				return nil, fmt.Errorf("failed to insert observation for %s: %w", entity.Name, err)
 			}
@@ -306,30 +676,54 @@ func (s *SQLiteStorage) CreateEntities(entities []Entity) ([]Entity, error) {
 	return created, nil
 }
 
-// DeleteEntities deletes entities by name
-func (s *SQLiteStorage) DeleteEntities(names []string) error {
+// DeleteEntities deletes entities by name, their observations and relations
+// cascading, and returns how many entities existed
+func (s *SQLiteStorage) DeleteEntities(names []string) (int, error) {
+	var deleted int
+	err := s.retryWriteTouching(names, func() (err error) {
+		deleted, err = s.deleteEntities(names)
+		return err
+	})
+	return deleted, err
+}
+
+// deleteEntities performs a single DeleteEntities attempt
+func (s *SQLiteStorage) deleteEntities(names []string) (int, error) {
 	if len(names) == 0 {
-		return nil
+		return 0, nil
 	}
 
 	placeholders := make([]string, len(names))
-	args := make([]interface{}, len(names))
+	args := []interface{}{s.ns()}
 	for i, name := range names {
 		placeholders[i] = "?"
-		args[i] = name
+		args = append(args, name)
 	}
 
-	query := fmt.Sprintf("DELETE FROM entities WHERE name IN (%s)", strings.Join(placeholders, ","))
-	_, err := s.db.Exec(query, args...)
+	query := fmt.Sprintf("DELETE FROM entities WHERE namespace = ? AND name IN (%s)", strings.Join(placeholders, ","))
+	result, err := s.db.Exec(query, args...)
 	if err != nil {
-		return fmt.Errorf("failed to delete entities: %w", err)
+		return 0, fmt.Errorf("failed to delete entities: %w", err)
 	}
-
-	return nil
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count deleted entities: %w", err)
+	}
+	return int(deleted), nil
 }
 
 // CreateRelations creates new relations
 func (s *SQLiteStorage) CreateRelations(relations []Relation) ([]Relation, error) {
+	var created []Relation
+	err := s.retryWriteTouching(relationEndpoints(relations), func() (err error) {
+		created, err = s.createRelations(relations)
+		return err
+	})
+	return created, err
+}
+
+// createRelations performs a single CreateRelations attempt
+func (s *SQLiteStorage) createRelations(relations []Relation) ([]Relation, error) {
 	if len(relations) == 0 {
 		return []Relation{}, nil
 	}
@@ -341,13 +735,14 @@ func (s *SQLiteStorage) CreateRelations(relations []Relation) ([]Relation, error
 	defer tx.Rollback()
 
 	stmt, err := tx.Prepare(`
-		INSERT INTO relations (from_entity_id, to_entity_id, relation_type)
+		INSERT INTO relations (from_entity_id, to_entity_id, relation_type, created_at)
 		SELECT 
-			(SELECT id FROM entities WHERE name = ? LIMIT 1),
-			(SELECT id FROM entities WHERE name = ? LIMIT 1),
-			?
-		WHERE EXISTS(SELECT 1 FROM entities WHERE name = ?)
-		  AND EXISTS(SELECT 1 FROM entities WHERE name = ?)
+			(SELECT id FROM entities WHERE namespace = ?1 AND name = ?2),
+			(SELECT id FROM entities WHERE namespace = ?1 AND name = ?3),
+			?4,
+			COALESCE(?5, CURRENT_TIMESTAMP)
+		WHERE EXISTS(SELECT 1 FROM entities WHERE namespace = ?1 AND name = ?2)
+		  AND EXISTS(SELECT 1 FROM entities WHERE namespace = ?1 AND name = ?3)
 		ON CONFLICT(from_entity_id, to_entity_id, relation_type) DO NOTHING
 	`)
 	if err != nil {
@@ -358,7 +753,7 @@ func (s *SQLiteStorage) CreateRelations(relations []Relation) ([]Relation, error
 	created := make([]Relation, 0, len(relations))
 
 	for _, rel := range relations {
-		result, err := stmt.Exec(rel.From, rel.To, rel.RelationType, rel.From, rel.To)
+		result, err := stmt.Exec(s.ns(), rel.From, rel.To, rel.RelationType, relationCreatedAt(rel))
 		if err != nil {
 			return nil, fmt.Errorf("failed to insert relation: %w", err)
 		}
@@ -375,45 +770,175 @@ func (s *SQLiteStorage) CreateRelations(relations []Relation) ([]Relation, error
 	return created, nil
 }
 
-// DeleteRelations deletes specific relations
-func (s *SQLiteStorage) DeleteRelations(relations []Relation) error {
-	if len(relations) == 0 {
+// relationCreatedAt returns the created_at value to store for r: its own time in
+// CURRENT_TIMESTAMP format (so imports keep their history), or nil for now
+func relationCreatedAt(r Relation) interface{} {
+	if r.CreatedAt == nil {
 		return nil
 	}
+	return sqliteTimestamp(*r.CreatedAt)
+}
+
+// scanRelation scans a from name, to name, relation type, created_at row
+func scanRelation(rows *sql.Rows) (Relation, error) {
+	var relation Relation
+	var createdAt sql.NullTime
+	if err := rows.Scan(&relation.From, &relation.To, &relation.RelationType, &createdAt); err != nil {
+		return Relation{}, fmt.Errorf("failed to scan relation: %w", err)
+	}
+	if createdAt.Valid {
+		relation.CreatedAt = &createdAt.Time
+	}
+	return relation, nil
+}
+
+// DeleteRelations deletes specific relations and returns how many existed
+func (s *SQLiteStorage) DeleteRelations(relations []Relation) (int, error) {
+	var deleted int
+	err := s.retryWriteTouching(relationEndpoints(relations), func() (err error) {
+		deleted, err = s.deleteRelations(relations)
+		return err
+	})
+	return deleted, err
+}
+
+// deleteRelations performs a single DeleteRelations attempt
+func (s *SQLiteStorage) deleteRelations(relations []Relation) (int, error) {
+	if len(relations) == 0 {
+		return 0, nil
+	}
 
 	tx, err := s.db.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
 	stmt, err := tx.Prepare(`
 		DELETE FROM relations 
-		WHERE from_entity_id = (SELECT id FROM entities WHERE name = ?)
-		AND to_entity_id = (SELECT id FROM entities WHERE name = ?)
-		AND relation_type = ?
+		WHERE from_entity_id = (SELECT id FROM entities WHERE namespace = ?1 AND name = ?2)
+		AND to_entity_id = (SELECT id FROM entities WHERE namespace = ?1 AND name = ?3)
+		AND relation_type = ?4
 	`)
 	if err != nil {
-		return fmt.Errorf("failed to prepare statement: %w", err)
+		return 0, fmt.Errorf("failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
+	deleted := 0
 	for _, rel := range relations {
-		_, err = stmt.Exec(rel.From, rel.To, rel.RelationType)
+		result, err := stmt.Exec(s.ns(), rel.From, rel.To, rel.RelationType)
 		if err != nil {
-			return fmt.Errorf("failed to delete relation: %w", err)
+			return 0, fmt.Errorf("failed to delete relation: %w", err)
 		}
+		rows, _ := result.RowsAffected()
+		deleted += int(rows)
 	}
 
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	return nil
+	return deleted, nil
+}
+
+// DeleteRelationsByType deletes every relation of relationType
+func (s *SQLiteStorage) DeleteRelationsByType(relationType string) (int, error) {
+	var deleted int
+	err := s.retryWrite(func() (err error) {
+		deleted, err = s.deleteRelationsByType(relationType)
+		return err
+	})
+	return deleted, err
+}
+
+// deleteRelationsByType performs a single DeleteRelationsByType attempt
+func (s *SQLiteStorage) deleteRelationsByType(relationType string) (int, error) {
+	result, err := s.db.Exec(`
+		DELETE FROM relations
+		WHERE relation_type = ?
+		AND from_entity_id IN (SELECT id FROM entities WHERE namespace = ?)
+	`, relationType, s.ns())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete relations: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count deleted relations: %w", err)
+	}
+	return int(deleted), nil
+}
+
+// MergeRelationTypes rewrites relations of the alias types to canonical,
+// dropping those that duplicate a relation already of the canonical type
+func (s *SQLiteStorage) MergeRelationTypes(canonical string, aliases []string) (int, error) {
+	aliases, err := relationTypeAliases(canonical, aliases)
+	if err != nil {
+		return 0, err
+	}
+	var changed int
+	err = s.retryWrite(func() (err error) {
+		changed, err = s.mergeRelationTypes(canonical, aliases)
+		return err
+	})
+	return changed, err
+}
+
+// mergeRelationTypes performs a single MergeRelationTypes attempt
+func (s *SQLiteStorage) mergeRelationTypes(canonical string, aliases []string) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	placeholders := make([]string, len(aliases))
+	args := make([]interface{}, 0, len(aliases)+1)
+	for i, alias := range aliases {
+		placeholders[i] = "?"
+		args = append(args, alias)
+	}
+	args = append(args, s.ns())
+	where := fmt.Sprintf(`relation_type IN (%s)
+		AND from_entity_id IN (SELECT id FROM entities WHERE namespace = ?)`, strings.Join(placeholders, ","))
+
+	// Rows that would duplicate a canonical relation are skipped here and
+	// deleted below
+	result, err := tx.Exec("UPDATE OR IGNORE relations SET relation_type = ? WHERE "+where, append([]interface{}{canonical}, args...)...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to rewrite relation types: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count rewritten relations: %w", err)
+	}
+	result, err = tx.Exec("DELETE FROM relations WHERE "+where, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete duplicate relations: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count duplicate relations: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return int(updated + deleted), nil
 }
 
 // AddObservations adds observations to entities
-func (s *SQLiteStorage) AddObservations(observations map[string][]string) (map[string][]string, error) {
+func (s *SQLiteStorage) AddObservations(observations map[string][]string, source string) (map[string][]string, error) {
+	var added map[string][]string
+	err := s.retryWriteTouching(slices.Collect(maps.Keys(observations)), func() (err error) {
+		added, err = s.addObservations(observations, source)
+		return err
+	})
+	return added, err
+}
+
+// addObservations performs a single AddObservations attempt
+func (s *SQLiteStorage) addObservations(observations map[string][]string, source string) (map[string][]string, error) {
 	if len(observations) == 0 {
 		return map[string][]string{}, nil
 	}
@@ -425,8 +950,8 @@ func (s *SQLiteStorage) AddObservations(observations map[string][]string) (map[s
 	defer tx.Rollback()
 
 	stmt, err := tx.Prepare(`
-		INSERT INTO observations (entity_id, content)
-		SELECT id, ? FROM entities WHERE name = ?
+		INSERT INTO observations (entity_id, content, source)
+		SELECT id, ?, ? FROM entities WHERE namespace = ? AND name = ?
 		ON CONFLICT(entity_id, content) DO NOTHING
 	`)
 	if err != nil {
@@ -438,8 +963,15 @@ func (s *SQLiteStorage) AddObservations(observations map[string][]string) (map[s
 
 	for entityName, obsList := range observations {
 		added[entityName] = []string{}
+		keys, err := s.storedObservationKeys(tx, entityName)
+		if err != nil {
+			return nil, err
+		}
 		for _, obs := range obsList {
-			result, err := stmt.Exec(obs, entityName)
+			if keys.seen(s.config.observationKey(obs)) {
+				continue
+			}
+			result, err := stmt.Exec(obs, source, s.ns(), entityName)
 			if err != nil {
 				return nil, fmt.Errorf("failed to add observation: %w", err)
 			}
@@ -448,6 +980,11 @@ func (s *SQLiteStorage) AddObservations(observations map[string][]string) (map[s
 				added[entityName] = append(added[entityName], obs)
 			}
 		}
+		if len(added[entityName]) > 0 {
+			if err := s.touchEntity(tx, entityName); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	if err = tx.Commit(); err != nil {
@@ -457,54 +994,169 @@ func (s *SQLiteStorage) AddObservations(observations map[string][]string) (map[s
 	return added, nil
 }
 
-// DeleteObservations deletes specific observations
-func (s *SQLiteStorage) DeleteObservations(deletions []ObservationDeletion) error {
-	if len(deletions) == 0 {
-		return nil
+// touchEntity sets an entity's updated_at to now after its observations changed
+func (s *SQLiteStorage) touchEntity(tx *sql.Tx, entityName string) error {
+	if _, err := tx.Exec("UPDATE entities SET updated_at = CURRENT_TIMESTAMP WHERE namespace = ? AND name = ?", s.ns(), entityName); err != nil {
+		return fmt.Errorf("failed to update entity: %w", err)
+	}
+	return nil
+}
+
+// observationKeySet tracks dedup keys; a nil set (exact mode) never reports duplicates
+type observationKeySet map[string]bool
+
+// seen reports whether key was already present, recording it otherwise
+func (k observationKeySet) seen(key string) bool {
+	if k == nil {
+		return false
+	}
+	if k[key] {
+		return true
 	}
+	k[key] = true
+	return false
+}
 
-	tx, err := s.db.Begin()
+// storedObservationKeys returns the dedup keys of an entity's stored observations when
+// normalized dedup is on, or nil in exact mode where the UNIQUE constraint suffices
+func (s *SQLiteStorage) storedObservationKeys(tx *sql.Tx, entityName string) (observationKeySet, error) {
+	if s.config.ObservationDedup != DedupNormalized {
+		return nil, nil
+	}
+
+	rows, err := tx.Query(`
+		SELECT o.content FROM observations o
+		JOIN entities e ON o.entity_id = e.id
+		WHERE e.namespace = ? AND e.name = ?
+	`, s.ns(), entityName)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, fmt.Errorf("failed to load observations of %s: %w", entityName, err)
 	}
-	defer tx.Rollback()
+	defer rows.Close()
 
-	stmt, err := tx.Prepare(`
-		DELETE FROM observations 
-		WHERE entity_id = (SELECT id FROM entities WHERE name = ?)
-		AND content = ?
-	`)
+	keys := observationKeySet{}
+	for rows.Next() {
+		var content string
+		if err := rows.Scan(&content); err != nil {
+			return nil, fmt.Errorf("failed to scan observation: %w", err)
+		}
+		keys[s.config.observationKey(content)] = true
+	}
+	return keys, rows.Err()
+}
+
+// DeleteObservations deletes the observations each deletion matches and
+// returns how many were deleted per entity
+func (s *SQLiteStorage) DeleteObservations(deletions []ObservationDeletion) (map[string]int, error) {
+	if err := validateObservationDeletions(deletions); err != nil {
+		return nil, err
+	}
+	names := make([]string, len(deletions))
+	for i, deletion := range deletions {
+		names[i] = deletion.EntityName
+	}
+	var deleted map[string]int
+	err := s.retryWriteTouching(names, func() error {
+		var err error
+		deleted, err = s.deleteObservations(deletions)
+		return err
+	})
+	return deleted, err
+}
+
+// deleteObservations performs a single DeleteObservations attempt. Each
+// entity's observations are read in insertion order, so indexes count from
+// the oldest, and the matching ones deleted by id.
+func (s *SQLiteStorage) deleteObservations(deletions []ObservationDeletion) (map[string]int, error) {
+	deleted := make(map[string]int, len(deletions))
+	for _, del := range deletions {
+		deleted[del.EntityName] = 0
+	}
+	if len(deletions) == 0 {
+		return deleted, nil
+	}
+
+	tx, err := s.db.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to prepare statement: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	defer stmt.Close()
+	defer tx.Rollback()
 
 	for _, del := range deletions {
-		for _, obs := range del.Observations {
-			_, err = stmt.Exec(del.EntityName, obs)
-			if err != nil {
-				return fmt.Errorf("failed to delete observation: %w", err)
+		rows, err := tx.Query(`
+			SELECT o.id, o.content
+			FROM observations o
+			JOIN entities e ON o.entity_id = e.id
+			WHERE e.namespace = ? AND e.name = ?
+			ORDER BY o.id
+		`, s.ns(), del.EntityName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query observations: %w", err)
+		}
+		var ids []int64
+		for index := 0; rows.Next(); index++ {
+			var id int64
+			var content string
+			if err := rows.Scan(&id, &content); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan observation: %w", err)
+			}
+			if del.matches(index, content) {
+				ids = append(ids, id)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("error iterating observations: %w", err)
+		}
+
+		for _, id := range ids {
+			if _, err := tx.Exec("DELETE FROM observations WHERE id = ?", id); err != nil {
+				return nil, fmt.Errorf("failed to delete observation: %w", err)
+			}
+		}
+		// The entity is touched in the same transaction so that
+		// EntitiesModifiedSince reports observation deletions too
+		if len(ids) > 0 {
+			deleted[del.EntityName] += len(ids)
+			if err := s.touchEntity(tx, del.EntityName); err != nil {
+				return nil, err
 			}
 		}
 	}
 
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	return nil
+	return deleted, nil
 }
 
 // ReadGraph returns either a lightweight summary or full graph based on mode
-func (s *SQLiteStorage) ReadGraph(mode string, limit int) (interface{}, error) {
+func (s *SQLiteStorage) ReadGraph(mode string, limit int, filter TypeFilter) (interface{}, error) {
 	if mode == "full" {
-		return s.readGraphFull()
-	}
-	return s.readGraphSummary(limit)
-}
+		graph, err := s.readGraphFull(s.config.readObservationCap(), filter)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.exposeIDs(graph); err != nil {
+			return nil, err
+		}
+		s.config.noteMoreObservations(graph)
+		return graph, nil
+	}
+	summary, err := s.readGraphSummary(limit, filter)
+	if err != nil {
+		return nil, err
+	}
+	if summary.TypeDescriptions, err = s.TypeDescriptions(""); err != nil {
+		return nil, err
+	}
+	return summary, s.exposeIDs(summary)
+}
 
 // readGraphSummary returns a lightweight summary of the knowledge graph
-func (s *SQLiteStorage) readGraphSummary(limit int) (*GraphSummary, error) {
+func (s *SQLiteStorage) readGraphSummary(limit int, filter TypeFilter) (*GraphSummary, error) {
 	summary := &GraphSummary{
 		EntityTypes:   make(map[string]int),
 		RelationTypes: make(map[string]int),
@@ -512,20 +1164,31 @@ func (s *SQLiteStorage) readGraphSummary(limit int) (*GraphSummary, error) {
 		Limit:         limit,
 	}
 
+	entityCond, entityArgs := s.entityScope("", filter)
+
+	// Relations are only counted between entities that pass the filter
+	fromCond, fromArgs := s.entityScope("f", filter)
+	toCond, toArgs := s.entityScope("t", filter)
+	relFrom := fmt.Sprintf(`relations r
+		JOIN entities f ON r.from_entity_id = f.id
+		JOIN entities t ON r.to_entity_id = t.id
+		WHERE %s AND %s`, fromCond, toCond)
+	relArgs := append(fromArgs, toArgs...)
+
 	// Get total entity count
-	err := s.rdb().QueryRow("SELECT COUNT(*) FROM entities").Scan(&summary.TotalEntities)
+	err := s.rdb().QueryRow("SELECT COUNT(*) FROM entities WHERE "+entityCond, entityArgs...).Scan(&summary.TotalEntities)
 	if err != nil {
 		return nil, fmt.Errorf("failed to count entities: %w", err)
 	}
 
 	// Get total relation count
-	err = s.rdb().QueryRow("SELECT COUNT(*) FROM relations").Scan(&summary.TotalRelations)
+	err = s.rdb().QueryRow("SELECT COUNT(*) FROM "+relFrom, relArgs...).Scan(&summary.TotalRelations)
 	if err != nil {
 		return nil, fmt.Errorf("failed to count relations: %w", err)
 	}
 
 	// Get entity type distribution
-	rows, err := s.rdb().Query("SELECT entity_type, COUNT(*) FROM entities GROUP BY entity_type ORDER BY COUNT(*) DESC")
+	rows, err := s.rdb().Query("SELECT entity_type, COUNT(*) FROM entities WHERE "+entityCond+" GROUP BY entity_type ORDER BY COUNT(*) DESC", entityArgs...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query entity types: %w", err)
 	}
@@ -541,7 +1204,7 @@ func (s *SQLiteStorage) readGraphSummary(limit int) (*GraphSummary, error) {
 	}
 
 	// Get relation type distribution
-	rows, err = s.rdb().Query("SELECT relation_type, COUNT(*) FROM relations GROUP BY relation_type ORDER BY COUNT(*) DESC")
+	rows, err = s.rdb().Query("SELECT r.relation_type, COUNT(*) FROM "+relFrom+" GROUP BY r.relation_type ORDER BY COUNT(*) DESC", relArgs...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query relation types: %w", err)
 	}
@@ -558,11 +1221,12 @@ func (s *SQLiteStorage) readGraphSummary(limit int) (*GraphSummary, error) {
 
 	// Get entity list (limited)
 	rows, err = s.rdb().Query(`
-		SELECT name, entity_type 
-		FROM entities 
-		ORDER BY created_at DESC 
+		SELECT name, entity_type
+		FROM entities
+		WHERE `+entityCond+`
+		ORDER BY created_at DESC
 		LIMIT ?
-	`, limit)
+	`, append(entityArgs, limit)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query entities: %w", err)
 	}
@@ -588,45 +1252,35 @@ func (s *SQLiteStorage) readGraphSummary(limit int) (*GraphSummary, error) {
 	return summary, nil
 }
 
-// readGraphFull reads the entire knowledge graph (internal use for export/migration)
-func (s *SQLiteStorage) readGraphFull() (*KnowledgeGraph, error) {
+// readGraphFull reads the entire knowledge graph, or the part passing filter, keeping
+// at most maxObservations per entity (0 = no cap, used for export/migration)
+func (s *SQLiteStorage) readGraphFull(maxObservations int, filter TypeFilter) (*KnowledgeGraph, error) {
 	graph := &KnowledgeGraph{
 		Entities:  []Entity{},
 		Relations: []Relation{},
 	}
 
-	// Load entities with observations
+	// Load entities first, then observations in one pass capped per entity
+	entityCond, entityArgs := s.entityScope("", filter)
 	rows, err := s.rdb().Query(`
-		SELECT e.name, e.entity_type,
-		       GROUP_CONCAT(o.content, '|||') as observations
-		FROM entities e
-		LEFT JOIN observations o ON e.id = o.entity_id
-		GROUP BY e.id, e.name, e.entity_type
-		ORDER BY e.created_at
-	`)
+		SELECT id, name, entity_type, description
+		FROM entities
+		WHERE `+entityCond+`
+		ORDER BY created_at, id
+	`, entityArgs...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query entities: %w", err)
 	}
 	defer rows.Close()
 
+	entityIDs := []int64{}
 	for rows.Next() {
-		var name, entityType string
-		var obsStr sql.NullString
-
-		if err := rows.Scan(&name, &entityType, &obsStr); err != nil {
+		var id int64
+		entity := Entity{Observations: []string{}}
+		if err := rows.Scan(&id, &entity.Name, &entity.EntityType, &entity.Description); err != nil {
 			return nil, fmt.Errorf("failed to scan entity: %w", err)
 		}
-
-		entity := Entity{
-			Name:         name,
-			EntityType:   entityType,
-			Observations: []string{},
-		}
-
-		if obsStr.Valid && obsStr.String != "" {
-			entity.Observations = strings.Split(obsStr.String, "|||")
-		}
-
+		entityIDs = append(entityIDs, id)
 		graph.Entities = append(graph.Entities, entity)
 	}
 
@@ -634,30 +1288,49 @@ func (s *SQLiteStorage) readGraphFull() (*KnowledgeGraph, error) {
 		return nil, fmt.Errorf("error iterating entities: %w", err)
 	}
 
-	// Load relations
+	observations, err := s.loadObservations(nil, maxObservations)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := s.loadObservationSources(nil)
+	if err != nil {
+		return nil, err
+	}
+	for i, id := range entityIDs {
+		if obs, ok := observations[id]; ok {
+			graph.Entities[i].Observations = obs
+			graph.Entities[i].ObservationSources = pruneSources(sources[id], obs)
+		}
+		if maxObservations > 0 && len(graph.Entities[i].Observations) == maxObservations {
+			if total := s.countObservations(id); total > maxObservations {
+				graph.Entities[i].ObservationsTotal = total
+				graph.Truncated = true
+			}
+		}
+	}
+
+	// Load relations between loaded entities
+	fromCond, fromArgs := s.entityScope("f", filter)
+	toCond, toArgs := s.entityScope("t", filter)
 	rows, err = s.rdb().Query(`
-		SELECT f.name, t.name, r.relation_type
+		SELECT f.name, t.name, r.relation_type, r.created_at
 		FROM relations r
 		JOIN entities f ON r.from_entity_id = f.id
 		JOIN entities t ON r.to_entity_id = t.id
+		WHERE `+fromCond+` AND `+toCond+`
 		ORDER BY r.created_at
-	`)
+	`, append(fromArgs, toArgs...)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query relations: %w", err)
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var from, to, relType string
-		if err := rows.Scan(&from, &to, &relType); err != nil {
-			return nil, fmt.Errorf("failed to scan relation: %w", err)
+		relation, err := scanRelation(rows)
+		if err != nil {
+			return nil, err
 		}
-
-		graph.Relations = append(graph.Relations, Relation{
-			From:         from,
-			To:           to,
-			RelationType: relType,
-		})
+		graph.Relations = append(graph.Relations, relation)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -669,18 +1342,52 @@ func (s *SQLiteStorage) readGraphFull() (*KnowledgeGraph, error) {
 
 // SearchNodes searches for nodes containing the query string and returns lightweight summaries
 func (s *SQLiteStorage) SearchNodes(query string, limit int) (*SearchResult, error) {
+	return s.SearchNodesWithSnippets(query, limit, 0)
+}
+
+// SearchNodesWithSnippets is SearchNodes with at most maxSnippets matched
+// observations per hit: the best bm25 matches with FTS, otherwise those
+// containing the most query words
+func (s *SQLiteStorage) SearchNodesWithSnippets(query string, limit, maxSnippets int) (*SearchResult, error) {
+	key := s.searchKey(query, limit, maxSnippets)
+	if cached := s.cache.get(key); cached != nil {
+		return cached.search, nil
+	}
+	generation := s.cache.start()
+	result, err := s.searchNodes(query, limit, maxSnippets)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.put(generation, &readCacheEntry{key: key, ns: s.ns(), search: result})
+	return result, nil
+}
+
+// searchNodes performs SearchNodesWithSnippets without the cache
+func (s *SQLiteStorage) searchNodes(query string, limit, maxSnippets int) (*SearchResult, error) {
+	maxSnippets = s.config.searchSnippets(limit, maxSnippets)
+
 	// Try FTS search first if available
-	if s.isFTSAvailable() {
-		result, err := s.SearchNodesWithFTS(query, limit)
-		if err == nil {
-			return result, nil
+	var result *SearchResult
+	var err error
+	if strings.TrimSpace(query) == "" && s.config.EmptyQueryListsAll {
+		if result, err = s.listSearchHits(limit); err != nil {
+			return nil, err
 		}
-		// Log FTS error but continue with basic search
+		return result, s.exposeIDs(result)
+	}
+	if s.useFTS() {
+		result, err = s.searchNodesFTS(query, limit, maxSnippets)
+		// On error, continue with basic search
 		// Silently fallback - don't print to stdout in MCP mode
 	}
 
 	// Always use basic search as fallback
-	return s.searchNodesBasic(query, limit)
+	if result == nil || err != nil {
+		if result, err = s.searchNodesBasic(query, limit, maxSnippets); err != nil {
+			return nil, err
+		}
+	}
+	return result, s.exposeIDs(result)
 }
 
 // isFTSAvailable checks if FTS5 tables are available
@@ -690,6 +1397,13 @@ func (s *SQLiteStorage) isFTSAvailable() bool {
 	return err == nil && count > 0
 }
 
+// useFTS reports whether search goes through FTS: it is enabled in the config
+// and the database has the FTS tables. Writes keep existing tables in sync
+// either way, so they are current if FTS is turned back on.
+func (s *SQLiteStorage) useFTS() bool {
+	return s.config.ftsEnabled() && s.isFTSAvailable()
+}
+
 // Match priority constants for search ranking
 // Higher values indicate higher priority
 const (
@@ -700,9 +1414,10 @@ const (
 )
 
 // searchNodesBasic performs basic LIKE-based search and returns search hits with snippets
-// Multiple space-separated words are treated as OR search
-// Results are sorted by match priority: name exact > name partial > type > content
-func (s *SQLiteStorage) searchNodesBasic(query string, limit int) (*SearchResult, error) {
+// Multiple space-separated words are treated as OR search, a "quoted phrase" as one word
+// Results are sorted by how many words match, then by match priority:
+// name exact > name partial > type > content
+func (s *SQLiteStorage) searchNodesBasic(query string, limit, maxSnippets int) (*SearchResult, error) {
 	result := &SearchResult{
 		Entities: []EntitySearchHit{},
 		Limit:    limit,
@@ -713,23 +1428,23 @@ func (s *SQLiteStorage) searchNodesBasic(query string, limit int) (*SearchResult
 	}
 
 	// Split query into words for OR search and expand with synonyms
-	words := strings.Fields(query)
+	words := searchTerms(query)
 	if len(words) == 0 {
 		return result, nil
 	}
 	words = s.expandQueryWithSynonyms(words)
 
-	// Build dynamic WHERE clause for multi-word OR search
+	// Build dynamic WHERE clause for multi-word OR search within the namespace
 	var whereClauses []string
-	var countArgs []interface{}
+	countArgs := []interface{}{s.ns()}
 
 	for _, word := range words {
 		searchPattern := "%" + word + "%"
-		whereClauses = append(whereClauses, "(e.name LIKE ? OR e.entity_type LIKE ? OR o.content LIKE ?)")
-		countArgs = append(countArgs, searchPattern, searchPattern, searchPattern)
+		whereClauses = append(whereClauses, "(e.name LIKE ? OR e.entity_type LIKE ? OR e.description LIKE ? OR o.content LIKE ?)")
+		countArgs = append(countArgs, searchPattern, searchPattern, searchPattern, searchPattern)
 	}
 
-	whereClause := strings.Join(whereClauses, " OR ")
+	whereClause := "e.namespace = ? AND (" + strings.Join(whereClauses, " OR ") + ")"
 
 	// First, get total count
 	countQuery := fmt.Sprintf(`
@@ -743,6 +1458,9 @@ func (s *SQLiteStorage) searchNodesBasic(query string, limit int) (*SearchResult
 	if err != nil {
 		return nil, fmt.Errorf("failed to count search results: %w", err)
 	}
+	if tooMany := s.config.tooManyMatches(result.Total, limit); tooMany != nil {
+		return tooMany, nil
+	}
 
 	// Build priority CASE expression for each search word
 	// Priority: name exact match > name partial > type match > content match
@@ -767,11 +1485,17 @@ func (s *SQLiteStorage) searchNodesBasic(query string, limit int) (*SearchResult
 	// Use MAX to get the highest priority among all matched words
 	priorityExpr := fmt.Sprintf("MAX(%s)", strings.Join(priorityCases, ", "))
 
-	// Add WHERE clause args
+	// Count the words each entity matches anywhere, to rank those matching more first
+	var matchedCases []string
 	for _, word := range words {
 		searchPattern := "%" + word + "%"
-		searchArgs = append(searchArgs, searchPattern, searchPattern, searchPattern)
+		matchedCases = append(matchedCases, "MAX(CASE WHEN e.name LIKE ? OR e.entity_type LIKE ? OR e.description LIKE ? OR o.content LIKE ? THEN 1 ELSE 0 END)")
+		searchArgs = append(searchArgs, searchPattern, searchPattern, searchPattern, searchPattern)
 	}
+	matchedExpr := strings.Join(matchedCases, " + ")
+
+	// Add WHERE clause args
+	searchArgs = append(searchArgs, countArgs...)
 
 	// Get matched entity IDs with priority sorting
 	// Time-decay ranking: boost recently accessed entities
@@ -786,25 +1510,25 @@ func (s *SQLiteStorage) searchNodesBasic(query string, limit int) (*SearchResult
 	var searchQuery string
 	if limit > 0 {
 		searchQuery = fmt.Sprintf(`
-			SELECT e.id, e.name, e.entity_type, %s AS score
+			SELECT e.id, e.name, e.entity_type, e.description, %s AS score, %s AS matched
 			FROM entities e
 			LEFT JOIN observations o ON e.id = o.entity_id
 			WHERE %s
-			GROUP BY e.id, e.name, e.entity_type
-			ORDER BY score DESC, e.created_at DESC
+			GROUP BY e.id, e.name, e.entity_type, e.description
+			ORDER BY matched DESC, score DESC, e.created_at DESC, e.id
 			LIMIT ?
-		`, rankExpr, whereClause)
+		`, rankExpr, matchedExpr, whereClause)
 		searchArgs = append(searchArgs, limit)
 	} else {
 		// No limit - return all results
 		searchQuery = fmt.Sprintf(`
-			SELECT e.id, e.name, e.entity_type, %s AS score
+			SELECT e.id, e.name, e.entity_type, e.description, %s AS score, %s AS matched
 			FROM entities e
 			LEFT JOIN observations o ON e.id = o.entity_id
 			WHERE %s
-			GROUP BY e.id, e.name, e.entity_type
-			ORDER BY score DESC, e.created_at DESC
-		`, rankExpr, whereClause)
+			GROUP BY e.id, e.name, e.entity_type, e.description
+			ORDER BY matched DESC, score DESC, e.created_at DESC, e.id
+		`, rankExpr, matchedExpr, whereClause)
 	}
 
 	rows, err := s.rdb().Query(searchQuery, searchArgs...)
@@ -818,16 +1542,18 @@ func (s *SQLiteStorage) searchNodesBasic(query string, limit int) (*SearchResult
 
 	for rows.Next() {
 		var id int64
-		var name, entityType string
+		var name, entityType, description string
 		var score float64
-		if err := rows.Scan(&id, &name, &entityType, &score); err != nil {
+		var matched int
+		if err := rows.Scan(&id, &name, &entityType, &description, &score, &matched); err != nil {
 			return nil, fmt.Errorf("failed to scan search result: %w", err)
 		}
 		entityIDs = append(entityIDs, id)
 		entityMap[id] = &EntitySearchHit{
-			Name:       name,
-			EntityType: entityType,
-			Snippets:   []string{},
+			Name:        name,
+			EntityType:  entityType,
+			Description: description,
+			Snippets:    []string{},
 		}
 	}
 
@@ -890,11 +1616,8 @@ func (s *SQLiteStorage) searchNodesBasic(query string, limit int) (*SearchResult
 		}
 
 		// Get snippets - observations that match query with context around keywords
-		// maxSnippets=0 means return all matched snippets when limit=0
-		maxSnippets := 2
-		if limit == 0 {
-			maxSnippets = 0 // unlimited snippets
-		}
+		// maxSnippets=0 means return all matched snippets
+		maxSnippets = snippetCap(limit, maxSnippets)
 		for _, id := range entityIDs {
 			hit := entityMap[id]
 			snippets := s.getMatchedSnippets(id, words, maxSnippets, 50) // 50 chars context before/after keyword
@@ -923,6 +1646,52 @@ func (s *SQLiteStorage) searchNodesBasic(query string, limit int) (*SearchResult
 	return result, nil
 }
 
+// listSearchHits returns the namespace's entities in name order as search hits
+// without snippets, for an empty query with Config.EmptyQueryListsAll
+func (s *SQLiteStorage) listSearchHits(limit int) (*SearchResult, error) {
+	result := &SearchResult{
+		Entities: []EntitySearchHit{},
+		Limit:    limit,
+	}
+	if err := s.rdb().QueryRow("SELECT COUNT(*) FROM entities WHERE namespace = ?", s.ns()).Scan(&result.Total); err != nil {
+		return nil, fmt.Errorf("failed to count entities: %w", err)
+	}
+	if tooMany := s.config.tooManyMatches(result.Total, limit); tooMany != nil {
+		return tooMany, nil
+	}
+
+	queryLimit := -1 // SQLite's "no limit"
+	if limit > 0 {
+		queryLimit = limit
+	}
+	rows, err := s.rdb().Query(`
+		SELECT e.name, e.entity_type, e.description,
+			(SELECT COUNT(*) FROM observations o WHERE o.entity_id = e.id),
+			(SELECT COUNT(*) FROM relations r WHERE r.from_entity_id = e.id OR r.to_entity_id = e.id)
+		FROM entities e
+		WHERE e.namespace = ?
+		ORDER BY e.name
+		LIMIT ?
+	`, s.ns(), queryLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list entities: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		hit := EntitySearchHit{Snippets: []string{}}
+		if err := rows.Scan(&hit.Name, &hit.EntityType, &hit.Description, &hit.ObservationsCount, &hit.RelationsCount); err != nil {
+			return nil, fmt.Errorf("failed to scan entity: %w", err)
+		}
+		result.Entities = append(result.Entities, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating entities: %w", err)
+	}
+	result.HasMore = limit > 0 && result.Total > limit
+	return result, nil
+}
+
 // findRelatedEntities performs 1-hop graph traversal from matched entities to find related context.
 // Returns up to 10 related entities that are not already in the direct match results.
 func (s *SQLiteStorage) findRelatedEntities(entityIDs []int64, directHits map[int64]*EntitySearchHit) []RelatedHit {
@@ -1001,25 +1770,28 @@ func (s *SQLiteStorage) findRelatedEntities(entityIDs []int64, directHits map[in
 	return related
 }
 
-// getMatchedSnippets returns context snippets around matched keywords
+// getMatchedSnippets returns context snippets around matched keywords, observations
+// containing the most query words first
 // contextChars is the number of characters to show before and after the keyword
 func (s *SQLiteStorage) getMatchedSnippets(entityID int64, words []string, maxSnippets int, contextChars int) []string {
 	var snippets []string
 
-	// Build WHERE clause to find matching observations
+	// Build WHERE clause to find matching observations; the same LIKE terms summed
+	// count the words each observation contains
 	var whereClauses []string
-	var args []interface{}
-	args = append(args, entityID)
-
+	var likeArgs []interface{}
 	for _, word := range words {
-		whereClauses = append(whereClauses, "content LIKE ?")
-		args = append(args, "%"+word+"%")
+		whereClauses = append(whereClauses, "(content LIKE ?)")
+		likeArgs = append(likeArgs, "%"+word+"%")
 	}
+	args := append([]interface{}{entityID}, likeArgs...)
+	args = append(args, likeArgs...)
 
 	query := fmt.Sprintf(`
 		SELECT content FROM observations
 		WHERE entity_id = ? AND (%s)
-	`, strings.Join(whereClauses, " OR "))
+		ORDER BY %s DESC, id
+	`, strings.Join(whereClauses, " OR "), strings.Join(whereClauses, " + "))
 
 	rows, err := s.rdb().Query(query, args...)
 	if err != nil {
@@ -1041,9 +1813,13 @@ func (s *SQLiteStorage) getMatchedSnippets(entityID int64, words []string, maxSn
 
 	// If no matched observations, get first 2 observations as fallback
 	if len(snippets) == 0 {
+		fallbackCount := 2
+		if maxSnippets > 0 && maxSnippets < fallbackCount {
+			fallbackCount = maxSnippets
+		}
 		fallbackRows, err := s.rdb().Query(
-			"SELECT content FROM observations WHERE entity_id = ? LIMIT ?",
-			entityID, 2,
+			"SELECT content FROM observations WHERE entity_id = ? ORDER BY id LIMIT ?",
+			entityID, fallbackCount,
 		)
 		if err == nil {
 			defer fallbackRows.Close()
@@ -1118,10 +1894,44 @@ func truncateString(s string, maxLen int) string {
 	return string(runes[:maxLen]) + "..."
 }
 
-// OpenNodes retrieves specific nodes by name with truncation protection
-const maxObservationsPerEntity = 100
+// EntityTypes looks up the types of the named entities
+func (s *SQLiteStorage) EntityTypes(names []string) (map[string]string, error) {
+	types := make(map[string]string, len(names))
+	if len(names) == 0 {
+		return types, nil
+	}
+
+	err := s.queryByNames("SELECT name, entity_type FROM entities WHERE namespace = ? AND name IN (%s)", names, nil, func(rows *sql.Rows) error {
+		var name, entityType string
+		if err := rows.Scan(&name, &entityType); err != nil {
+			return err
+		}
+		types[name] = entityType
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query entity types: %w", err)
+	}
+	return types, nil
+}
 
+// OpenNodes retrieves specific nodes by name with truncation protection
 func (s *SQLiteStorage) OpenNodes(names []string) (*KnowledgeGraph, error) {
+	key := s.openNodesKey(names)
+	if cached := s.cache.get(key); cached != nil {
+		return cached.graph, nil
+	}
+	generation := s.cache.start()
+	graph, err := s.openNodes(names)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.put(generation, openNodesEntry(key, s.ns(), names, graph))
+	return graph, nil
+}
+
+// openNodes performs OpenNodes without the cache
+func (s *SQLiteStorage) openNodes(names []string) (*KnowledgeGraph, error) {
 	graph := &KnowledgeGraph{
 		Entities:  []Entity{},
 		Relations: []Relation{},
@@ -1132,17 +1942,17 @@ func (s *SQLiteStorage) OpenNodes(names []string) (*KnowledgeGraph, error) {
 	}
 
 	placeholders := make([]string, len(names))
-	args := make([]interface{}, len(names))
+	args := []interface{}{s.ns()}
 	for i, name := range names {
 		placeholders[i] = "?"
-		args[i] = name
+		args = append(args, name)
 	}
 
 	// Load entities first (without observations)
 	query := fmt.Sprintf(`
-		SELECT e.id, e.name, e.entity_type
+		SELECT e.id, e.name, e.entity_type, e.description
 		FROM entities e
-		WHERE e.name IN (%s)
+		WHERE e.namespace = ? AND e.name IN (%s)
 		ORDER BY e.created_at
 	`, strings.Join(placeholders, ","))
 
@@ -1157,9 +1967,9 @@ func (s *SQLiteStorage) OpenNodes(names []string) (*KnowledgeGraph, error) {
 
 	for rows.Next() {
 		var id int64
-		var name, entityType string
+		var name, entityType, description string
 
-		if err := rows.Scan(&id, &name, &entityType); err != nil {
+		if err := rows.Scan(&id, &name, &entityType, &description); err != nil {
 			return nil, fmt.Errorf("failed to scan entity: %w", err)
 		}
 
@@ -1167,6 +1977,7 @@ func (s *SQLiteStorage) OpenNodes(names []string) (*KnowledgeGraph, error) {
 		entityMap[id] = &Entity{
 			Name:         name,
 			EntityType:   entityType,
+			Description:  description,
 			Observations: []string{},
 		}
 	}
@@ -1175,40 +1986,41 @@ func (s *SQLiteStorage) OpenNodes(names []string) (*KnowledgeGraph, error) {
 		return nil, fmt.Errorf("error iterating entities: %w", err)
 	}
 
-	// Load observations for each entity with truncation
+	if len(entityIDs) == 0 {
+		return graph, nil
+	}
+
+	// Load observations row by row in one batched query, up to the cap per entity
+	maxObs := s.config.observationCap()
+	observations, err := s.loadObservations(entityIDs, maxObs)
+	if err != nil {
+		return nil, err
+	}
 	truncated := false
 	for _, id := range entityIDs {
 		entity := entityMap[id]
-
-		// Get total count first
-		var totalObs int
-		s.rdb().QueryRow("SELECT COUNT(*) FROM observations WHERE entity_id = ?", id).Scan(&totalObs)
-
-		// Get observations with limit
-		obsRows, err := s.rdb().Query(
-			"SELECT content FROM observations WHERE entity_id = ? LIMIT ?",
-			id, maxObservationsPerEntity,
-		)
-		if err != nil {
-			continue
+		if obs := observations[id]; obs != nil {
+			entity.Observations = obs
 		}
-
-		for obsRows.Next() {
-			var content string
-			if err := obsRows.Scan(&content); err == nil {
-				entity.Observations = append(entity.Observations, content)
+		// Only an entity filled to the cap can have more
+		if maxObs > 0 && len(entity.Observations) == maxObs {
+			if total := s.countObservations(id); total > maxObs {
+				entity.ObservationsTotal = total
+				truncated = true
 			}
 		}
-		obsRows.Close()
+	}
 
-		if totalObs > maxObservationsPerEntity {
-			truncated = true
-		}
+	sources, err := s.loadObservationSources(entityIDs)
+	if err != nil {
+		return nil, err
 	}
 
 	// Build entities list maintaining order
 	for _, id := range entityIDs {
-		graph.Entities = append(graph.Entities, *entityMap[id])
+		entity := entityMap[id]
+		entity.ObservationSources = pruneSources(sources[id], entity.Observations)
+		graph.Entities = append(graph.Entities, *entity)
 	}
 
 	graph.Truncated = truncated
@@ -1226,7 +2038,7 @@ func (s *SQLiteStorage) OpenNodes(names []string) (*KnowledgeGraph, error) {
 		}
 
 		relQuery := fmt.Sprintf(`
-			SELECT f.name, t.name, r.relation_type
+			SELECT f.name, t.name, r.relation_type, r.created_at
 			FROM relations r
 			JOIN entities f ON r.from_entity_id = f.id
 			JOIN entities t ON r.to_entity_id = t.id
@@ -1244,16 +2056,11 @@ func (s *SQLiteStorage) OpenNodes(names []string) (*KnowledgeGraph, error) {
 		defer rows.Close()
 
 		for rows.Next() {
-			var from, to, relType string
-			if err := rows.Scan(&from, &to, &relType); err != nil {
-				return nil, fmt.Errorf("failed to scan relation: %w", err)
+			relation, err := scanRelation(rows)
+			if err != nil {
+				return nil, err
 			}
-
-			graph.Relations = append(graph.Relations, Relation{
-				From:         from,
-				To:           to,
-				RelationType: relType,
-			})
+			graph.Relations = append(graph.Relations, relation)
 		}
 
 		if err = rows.Err(); err != nil {
@@ -1261,140 +2068,1588 @@ func (s *SQLiteStorage) OpenNodes(names []string) (*KnowledgeGraph, error) {
 		}
 	}
 
-	return graph, nil
+	return graph, s.exposeIDs(graph)
 }
 
-// updateAccessStats updates last_accessed_at and access_count for the given entity IDs.
-// Runs asynchronously to avoid blocking read operations.
-func (s *SQLiteStorage) updateAccessStats(entityIDs []int64) {
-	if len(entityIDs) == 0 {
-		return
-	}
-
-	go func() {
-		placeholders := make([]string, len(entityIDs))
-		args := make([]interface{}, len(entityIDs))
-		for i, id := range entityIDs {
-			placeholders[i] = "?"
-			args[i] = id
-		}
-		query := fmt.Sprintf(`
-			UPDATE entities
-			SET last_accessed_at = CURRENT_TIMESTAMP,
-			    access_count = COALESCE(access_count, 0) + 1
-			WHERE id IN (%s)
-		`, strings.Join(placeholders, ","))
-		s.db.Exec(query, args...)
-	}()
+// listSortColumns maps ListOptions.Sort values to entity columns
+var listSortColumns = map[string]string{
+	"":        "name",
+	"name":    "name",
+	"created": "created_at",
+	"updated": "updated_at",
 }
 
-// MergeEntities merges source entity into target: migrates observations and relations, then deletes source.
-func (s *SQLiteStorage) MergeEntities(sourceName, targetName string) (*MergeResult, error) {
-	tx, err := s.db.Begin()
-	if err != nil {
-		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+// ListEntities lists entities with optional type filter, sorting and paging
+func (s *SQLiteStorage) ListEntities(opts ListOptions) (*EntityList, error) {
+	sortColumn, ok := listSortColumns[opts.Sort]
+	if !ok {
+		return nil, fmt.Errorf("%w: sort %q must be name, created, or updated", ErrInvalidArgument, opts.Sort)
+	}
+	order := strings.ToUpper(opts.Order)
+	if order == "" {
+		order = "ASC"
+	}
+	if order != "ASC" && order != "DESC" {
+		return nil, fmt.Errorf("%w: order %q must be asc or desc", ErrInvalidArgument, opts.Order)
 	}
-	defer tx.Rollback()
 
-	// Get source and target entity IDs
-	var sourceID, targetID int64
-	err = tx.QueryRow("SELECT id FROM entities WHERE name = ?", sourceName).Scan(&sourceID)
-	if err != nil {
-		return nil, fmt.Errorf("source entity %q not found: %w", sourceName, err)
+	where := "WHERE namespace = ?"
+	args := []interface{}{s.ns()}
+	if opts.EntityType != "" {
+		where += " AND entity_type = ?"
+		args = append(args, opts.EntityType)
 	}
-	err = tx.QueryRow("SELECT id FROM entities WHERE name = ?", targetName).Scan(&targetID)
-	if err != nil {
-		return nil, fmt.Errorf("target entity %q not found: %w", targetName, err)
+
+	result := &EntityList{
+		Entities: []Entity{},
+		Limit:    opts.Limit,
+		Offset:   opts.Offset,
 	}
 
-	// Migrate observations (skip duplicates)
-	obsResult, err := tx.Exec(`
-		INSERT INTO observations (entity_id, content)
-		SELECT ?, content FROM observations WHERE entity_id = ?
-		ON CONFLICT(entity_id, content) DO NOTHING
-	`, targetID, sourceID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to migrate observations: %w", err)
+	if err := s.rdb().QueryRow("SELECT COUNT(*) FROM entities "+where, args...).Scan(&result.Total); err != nil {
+		return nil, fmt.Errorf("failed to count entities: %w", err)
 	}
-	mergedObs, _ := obsResult.RowsAffected()
 
-	// Redirect outgoing relations from source to target
-	outResult, err := tx.Exec(`
-		UPDATE relations SET from_entity_id = ?
-		WHERE from_entity_id = ?
-		AND NOT EXISTS (
-			SELECT 1 FROM relations r2
-			WHERE r2.from_entity_id = ? AND r2.to_entity_id = relations.to_entity_id
-			AND r2.relation_type = relations.relation_type
-		)
-	`, targetID, sourceID, targetID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to redirect outgoing relations: %w", err)
+	// LIMIT -1 means no limit in SQLite
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = -1
 	}
-	mergedOut, _ := outResult.RowsAffected()
 
-	// Redirect incoming relations to target
-	inResult, err := tx.Exec(`
-		UPDATE relations SET to_entity_id = ?
-		WHERE to_entity_id = ?
-		AND NOT EXISTS (
-			SELECT 1 FROM relations r2
-			WHERE r2.from_entity_id = relations.from_entity_id AND r2.to_entity_id = ?
-			AND r2.relation_type = relations.relation_type
-		)
-	`, targetID, sourceID, targetID)
+	// Sort column and direction come from the whitelists above, never from user input
+	query := fmt.Sprintf(`
+		SELECT id, name, entity_type
+		FROM entities
+		%s
+		ORDER BY %s %s, id %s
+		LIMIT ? OFFSET ?
+	`, where, sortColumn, order, order)
+
+	rows, err := s.rdb().Query(query, append(args, limit, opts.Offset)...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to redirect incoming relations: %w", err)
+		return nil, fmt.Errorf("failed to query entities: %w", err)
 	}
-	mergedIn, _ := inResult.RowsAffected()
+	defer rows.Close()
 
-	// Delete source entity (cascades observations and remaining duplicate relations)
-	_, err = tx.Exec("DELETE FROM entities WHERE id = ?", sourceID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to delete source entity: %w", err)
+	entityIDs := []int64{}
+	for rows.Next() {
+		var id int64
+		var entity Entity
+		if err := rows.Scan(&id, &entity.Name, &entity.EntityType); err != nil {
+			return nil, fmt.Errorf("failed to scan entity: %w", err)
+		}
+		entityIDs = append(entityIDs, id)
+		result.Entities = append(result.Entities, entity)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating entities: %w", err)
 	}
 
-	if err = tx.Commit(); err != nil {
-		return nil, fmt.Errorf("failed to commit merge: %w", err)
+	if opts.IncludeObservations && len(entityIDs) > 0 {
+		observations, err := s.loadObservations(entityIDs, 0)
+		if err != nil {
+			return nil, err
+		}
+		for i, id := range entityIDs {
+			result.Entities[i].Observations = observations[id]
+			if result.Entities[i].Observations == nil {
+				result.Entities[i].Observations = []string{}
+			}
+		}
 	}
 
-	return &MergeResult{
-		MergedObservations: int(mergedObs),
-		MergedRelations:    int(mergedOut + mergedIn),
-		SourceDeleted:      true,
-	}, nil
+	result.HasMore = opts.Offset+len(result.Entities) < result.Total
+	return result, nil
 }
 
-// UpdateEntityType updates the entity type for a given entity name.
-func (s *SQLiteStorage) UpdateEntityType(name string, newType string) error {
-	result, err := s.db.Exec(
-		"UPDATE entities SET entity_type = ?, updated_at = CURRENT_TIMESTAMP WHERE name = ?",
-		newType, name,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to update entity type: %w", err)
+// ListEntityNames returns a page of entity names sorted by name
+func (s *SQLiteStorage) ListEntityNames(limit, offset int) (*NameList, error) {
+	result := &NameList{Names: []string{}, Limit: limit, Offset: offset}
+	if err := s.rdb().QueryRow("SELECT COUNT(*) FROM entities WHERE namespace = ?", s.ns()).Scan(&result.Total); err != nil {
+		return nil, fmt.Errorf("failed to count entities: %w", err)
 	}
-	rows, _ := result.RowsAffected()
-	if rows == 0 {
-		return fmt.Errorf("entity %q not found", name)
+
+	// LIMIT -1 means no limit in SQLite
+	if limit <= 0 {
+		limit = -1
 	}
-	return nil
-}
+	rows, err := s.rdb().Query("SELECT name FROM entities WHERE namespace = ? ORDER BY name LIMIT ? OFFSET ?", s.ns(), limit, max(offset, 0))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query entity names: %w", err)
+	}
+	defer rows.Close()
 
-// UpdateObservation replaces an observation's content for a given entity.
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan entity name: %w", err)
+		}
+		result.Names = append(result.Names, name)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating entity names: %w", err)
+	}
+
+	result.HasMore = max(offset, 0)+len(result.Names) < result.Total
+	return result, nil
+}
+
+// ListRelations returns a page of relations ordered by creation time
+func (s *SQLiteStorage) ListRelations(limit, offset int) (*RelationPage, error) {
+	result := &RelationPage{Relations: []Relation{}, Limit: limit, Offset: offset}
+	err := s.rdb().QueryRow(`
+		SELECT COUNT(*) FROM relations r
+		JOIN entities f ON r.from_entity_id = f.id
+		WHERE f.namespace = ?
+	`, s.ns()).Scan(&result.Total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count relations: %w", err)
+	}
+
+	// LIMIT -1 means no limit in SQLite
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := s.rdb().Query(`
+		SELECT f.name, t.name, r.relation_type, r.created_at
+		FROM relations r
+		JOIN entities f ON r.from_entity_id = f.id
+		JOIN entities t ON r.to_entity_id = t.id
+		WHERE f.namespace = ?
+		ORDER BY r.created_at, r.id
+		LIMIT ? OFFSET ?
+	`, s.ns(), limit, max(offset, 0))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query relations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		relation, err := scanRelation(rows)
+		if err != nil {
+			return nil, err
+		}
+		result.Relations = append(result.Relations, relation)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating relations: %w", err)
+	}
+
+	result.HasMore = max(offset, 0)+len(result.Relations) < result.Total
+	return result, nil
+}
+
+// RecentlyModified returns the most recently updated entities with their update times
+func (s *SQLiteStorage) RecentlyModified(limit int) ([]Entity, error) {
+	if limit <= 0 {
+		limit = DefaultRecentLimit
+	}
+	rows, err := s.rdb().Query(`
+		SELECT name, entity_type, updated_at FROM entities
+		WHERE namespace = ?
+		ORDER BY updated_at DESC, id DESC
+		LIMIT ?
+	`, s.ns(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recent entities: %w", err)
+	}
+	defer rows.Close()
+
+	entities := []Entity{}
+	for rows.Next() {
+		var entity Entity
+		var updatedAt time.Time
+		if err := rows.Scan(&entity.Name, &entity.EntityType, &updatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan entity: %w", err)
+		}
+		entity.UpdatedAt = &updatedAt
+		entities = append(entities, entity)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating entities: %w", err)
+	}
+	return entities, nil
+}
+
+// FindByObservation returns all entities that contain the exact observation content
+func (s *SQLiteStorage) FindByObservation(content string) ([]Entity, error) {
+	rows, err := s.rdb().Query(`
+		SELECT DISTINCT e.id, e.name, e.entity_type
+		FROM observations o
+		JOIN entities e ON o.entity_id = e.id
+		WHERE e.namespace = ? AND o.content = ?
+		ORDER BY e.id
+	`, s.ns(), content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query observations: %w", err)
+	}
+	defer rows.Close()
+
+	entities := []Entity{}
+	entityIDs := []int64{}
+	for rows.Next() {
+		var id int64
+		var entity Entity
+		if err := rows.Scan(&id, &entity.Name, &entity.EntityType); err != nil {
+			return nil, fmt.Errorf("failed to scan entity: %w", err)
+		}
+		entityIDs = append(entityIDs, id)
+		entities = append(entities, entity)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating entities: %w", err)
+	}
+
+	if len(entityIDs) == 0 {
+		return entities, nil
+	}
+
+	observations, err := s.loadObservations(entityIDs, 0)
+	if err != nil {
+		return nil, err
+	}
+	for i, id := range entityIDs {
+		entities[i].Observations = observations[id]
+	}
+
+	return entities, nil
+}
+
+// loadObservations loads observations for the given entity IDs (nil = all entities in
+// the namespace) in Config.ObservationOrder, keeping at most maxPerEntity per entity
+// (0 = no cap)
+func (s *SQLiteStorage) loadObservations(entityIDs []int64, maxPerEntity int) (map[int64][]string, error) {
+	where, args := s.entityIDCondition(entityIDs)
+	order := "id"
+	if s.config.newestFirst() {
+		order = "id DESC"
+	}
+
+	query := fmt.Sprintf(`
+		SELECT entity_id, content
+		FROM observations
+		%s
+		ORDER BY entity_id, %s
+	`, where, order)
+	if maxPerEntity > 0 {
+		query = fmt.Sprintf(`
+			SELECT entity_id, content FROM (
+				SELECT entity_id, content, id,
+				       ROW_NUMBER() OVER (PARTITION BY entity_id ORDER BY %[2]s) AS rn
+				FROM observations
+				%[1]s
+			)
+			WHERE rn <= ?
+			ORDER BY entity_id, %[2]s
+		`, where, order)
+		args = append(args, maxPerEntity)
+	}
+
+	rows, err := s.rdb().Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query observations: %w", err)
+	}
+	defer rows.Close()
+
+	observations := make(map[int64][]string, len(entityIDs))
+	for rows.Next() {
+		var entityID int64
+		var content string
+		if err := rows.Scan(&entityID, &content); err != nil {
+			return nil, fmt.Errorf("failed to scan observation: %w", err)
+		}
+		observations[entityID] = append(observations[entityID], content)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating observations: %w", err)
+	}
+
+	return observations, nil
+}
+
+// loadObservationSources loads the non-empty observation sources for the given entity
+// IDs (nil = all entities in the namespace), keyed by entity ID and observation content
+func (s *SQLiteStorage) loadObservationSources(entityIDs []int64) (map[int64]map[string]string, error) {
+	if entityIDs != nil && len(entityIDs) == 0 {
+		return map[int64]map[string]string{}, nil
+	}
+
+	where, args := s.entityIDCondition(entityIDs)
+	rows, err := s.rdb().Query("SELECT entity_id, content, source FROM observations "+where+" AND source != ''", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query observation sources: %w", err)
+	}
+	defer rows.Close()
+
+	sources := make(map[int64]map[string]string)
+	for rows.Next() {
+		var entityID int64
+		var content, source string
+		if err := rows.Scan(&entityID, &content, &source); err != nil {
+			return nil, fmt.Errorf("failed to scan observation source: %w", err)
+		}
+		if sources[entityID] == nil {
+			sources[entityID] = make(map[string]string)
+		}
+		sources[entityID][content] = source
+	}
+	return sources, rows.Err()
+}
+
+// entityIDCondition returns a WHERE clause restricting entity_id to entityIDs, or to
+// the entities of the namespace when entityIDs is nil
+func (s *SQLiteStorage) entityIDCondition(entityIDs []int64) (string, []interface{}) {
+	if entityIDs == nil {
+		return "WHERE entity_id IN (SELECT id FROM entities WHERE namespace = ?)", []interface{}{s.ns()}
+	}
+	placeholders := make([]string, len(entityIDs))
+	args := make([]interface{}, len(entityIDs))
+	for i, id := range entityIDs {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	return fmt.Sprintf("WHERE entity_id IN (%s)", strings.Join(placeholders, ",")), args
+}
+
+// countObservations returns the number of observations stored for an entity
+func (s *SQLiteStorage) countObservations(entityID int64) int {
+	var count int
+	s.rdb().QueryRow("SELECT COUNT(*) FROM observations WHERE entity_id = ?", entityID).Scan(&count)
+	return count
+}
+
+// GetObservations returns a page of an entity's observations in insertion order
+func (s *SQLiteStorage) GetObservations(entityName string, offset, limit int) (*ObservationPage, error) {
+	var entityID int64
+	err := s.rdb().QueryRow("SELECT id FROM entities WHERE namespace = ? AND name = ?", s.ns(), entityName).Scan(&entityID)
+	if err == sql.ErrNoRows {
+		return nil, entityNotFound(entityName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query entity: %w", err)
+	}
+
+	page := &ObservationPage{
+		EntityName:   entityName,
+		Observations: []string{},
+		Total:        s.countObservations(entityID),
+		Offset:       offset,
+		Limit:        limit,
+	}
+
+	// LIMIT -1 means no limit in SQLite
+	if limit <= 0 {
+		limit = -1
+	}
+
+	rows, err := s.rdb().Query(`
+		SELECT content FROM observations
+		WHERE entity_id = ?
+		ORDER BY id
+		LIMIT ? OFFSET ?
+	`, entityID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query observations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var content string
+		if err := rows.Scan(&content); err != nil {
+			return nil, fmt.Errorf("failed to scan observation: %w", err)
+		}
+		page.Observations = append(page.Observations, content)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating observations: %w", err)
+	}
+
+	page.HasMore = offset+len(page.Observations) < page.Total
+	return page, nil
+}
+
+// Traverse returns entities connected to from by relationType (empty = any type)
+// in the given direction, using the relation from/to and type indexes
+func (s *SQLiteStorage) Traverse(from string, relationType string, direction string) ([]RelatedHit, error) {
+	out, in, err := traverseDirections(direction)
+	if err != nil {
+		return nil, err
+	}
+
+	var fromID int64
+	err = s.rdb().QueryRow("SELECT id FROM entities WHERE namespace = ? AND name = ?", s.ns(), from).Scan(&fromID)
+	if err == sql.ErrNoRows {
+		return nil, entityNotFound(from)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query entity: %w", err)
+	}
+
+	typeFilter := ""
+	if relationType != "" {
+		typeFilter = "AND r.relation_type = ?"
+	}
+
+	var parts []string
+	var args []interface{}
+	if out {
+		parts = append(parts, fmt.Sprintf(`
+			SELECT r.id, e.name, e.entity_type, r.relation_type, 'outgoing'
+			FROM relations r
+			JOIN entities e ON e.id = r.to_entity_id
+			WHERE r.from_entity_id = ? %s`, typeFilter))
+		args = append(args, fromID)
+		if relationType != "" {
+			args = append(args, relationType)
+		}
+	}
+	if in {
+		parts = append(parts, fmt.Sprintf(`
+			SELECT r.id, e.name, e.entity_type, r.relation_type, 'incoming'
+			FROM relations r
+			JOIN entities e ON e.id = r.from_entity_id
+			WHERE r.to_entity_id = ? %s`, typeFilter))
+		args = append(args, fromID)
+		if relationType != "" {
+			args = append(args, relationType)
+		}
+	}
+
+	rows, err := s.rdb().Query(strings.Join(parts, " UNION ALL ")+" ORDER BY 1", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query relations: %w", err)
+	}
+	defer rows.Close()
+
+	hits := []RelatedHit{}
+	for rows.Next() {
+		var relID int64
+		hit := RelatedHit{RelatedTo: from}
+		if err := rows.Scan(&relID, &hit.Name, &hit.EntityType, &hit.RelationType, &hit.Direction); err != nil {
+			return nil, fmt.Errorf("failed to scan relation: %w", err)
+		}
+		hits = append(hits, hit)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating relations: %w", err)
+	}
+
+	return hits, nil
+}
+
+// Reachable returns the entities within maxHops of start along relations of
+// relationType ("" = any type), walking the graph with a recursive CTE
+func (s *SQLiteStorage) Reachable(start string, relationType string, maxHops int, direction string) ([]ReachableEntity, error) {
+	out, in, err := traverseDirections(direction)
+	if err != nil {
+		return nil, err
+	}
+	if maxHops, err = reachableHops(maxHops); err != nil {
+		return nil, err
+	}
+
+	var startID int64
+	err = s.rdb().QueryRow("SELECT id FROM entities WHERE namespace = ? AND name = ?", s.ns(), start).Scan(&startID)
+	if err == sql.ErrNoRows {
+		return nil, entityNotFound(start)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query entity: %w", err)
+	}
+
+	typeFilter := ""
+	if relationType != "" {
+		typeFilter = "WHERE relation_type = ?"
+	}
+
+	// edges holds each followed relation as src -> dst in the walking direction
+	var edges []string
+	var args []interface{}
+	if out {
+		edges = append(edges, "SELECT from_entity_id AS src, to_entity_id AS dst FROM relations "+typeFilter)
+		if relationType != "" {
+			args = append(args, relationType)
+		}
+	}
+	if in {
+		edges = append(edges, "SELECT to_entity_id AS src, from_entity_id AS dst FROM relations "+typeFilter)
+		if relationType != "" {
+			args = append(args, relationType)
+		}
+	}
+	args = append(args, startID, maxHops, startID)
+
+	// UNION drops repeated (id, hops) rows, so cycles end at maxHops
+	query := fmt.Sprintf(`
+		WITH RECURSIVE edges(src, dst) AS (%s),
+		walk(id, hops) AS (
+			SELECT ?, 0
+			UNION
+			SELECT edges.dst, walk.hops + 1
+			FROM walk JOIN edges ON edges.src = walk.id
+			WHERE walk.hops < ?
+		)
+		SELECT e.name, e.entity_type, MIN(walk.hops) AS hops
+		FROM walk JOIN entities e ON e.id = walk.id
+		WHERE walk.id != ?
+		GROUP BY walk.id
+		ORDER BY hops, e.name`, strings.Join(edges, " UNION ALL "))
+
+	rows, err := s.rdb().Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reachable entities: %w", err)
+	}
+	defer rows.Close()
+
+	found := []ReachableEntity{}
+	for rows.Next() {
+		var entity ReachableEntity
+		if err := rows.Scan(&entity.Name, &entity.EntityType, &entity.Hops); err != nil {
+			return nil, fmt.Errorf("failed to scan reachable entity: %w", err)
+		}
+		found = append(found, entity)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reachable entities: %w", err)
+	}
+
+	return found, nil
+}
+
+// closureWalkFactor bounds the rows Closure's recursive walk may produce, as a
+// multiple of maxNodes. UNION only drops repeated (id, hops) pairs, so every
+// cycle revisits its entities at growing distances until the hop bound.
+const closureWalkFactor = 20
+
+// Closure finds every entity reachable from start with a recursive CTE. Shortest
+// paths cannot be longer than the entity count, which bounds the hops; a LIMIT
+// on the walk keeps dense cyclic graphs from exploding.
+func (s *SQLiteStorage) Closure(start string, relationType string, direction string, maxNodes int) (*ClosureResult, error) {
+	out, in, err := traverseDirections(direction)
+	if err != nil {
+		return nil, err
+	}
+	if maxNodes, err = closureNodes(maxNodes); err != nil {
+		return nil, err
+	}
+
+	var startID int64
+	err = s.rdb().QueryRow("SELECT id FROM entities WHERE namespace = ? AND name = ?", s.ns(), start).Scan(&startID)
+	if err == sql.ErrNoRows {
+		return nil, entityNotFound(start)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query entity: %w", err)
+	}
+	var entityCount int
+	if err := s.rdb().QueryRow("SELECT COUNT(*) FROM entities WHERE namespace = ?", s.ns()).Scan(&entityCount); err != nil {
+		return nil, fmt.Errorf("failed to count entities: %w", err)
+	}
+
+	typeFilter := ""
+	if relationType != "" {
+		typeFilter = "WHERE relation_type = ?"
+	}
+
+	// edges holds each followed relation as src -> dst in the walking direction
+	var edges []string
+	var args []interface{}
+	if out {
+		edges = append(edges, "SELECT from_entity_id AS src, to_entity_id AS dst FROM relations "+typeFilter)
+		if relationType != "" {
+			args = append(args, relationType)
+		}
+	}
+	if in {
+		edges = append(edges, "SELECT to_entity_id AS src, from_entity_id AS dst FROM relations "+typeFilter)
+		if relationType != "" {
+			args = append(args, relationType)
+		}
+	}
+	walkLimit := maxNodes * closureWalkFactor
+	args = append(args, startID, entityCount, walkLimit, startID, maxNodes+1)
+
+	// ORDER BY hops makes the walk breadth-first, so the LIMIT cuts off the
+	// farthest rows and MIN(hops) is the shortest path to each entity
+	query := fmt.Sprintf(`
+		WITH RECURSIVE edges(src, dst) AS (%s),
+		walk(id, hops) AS (
+			SELECT ?, 0
+			UNION
+			SELECT edges.dst, walk.hops + 1
+			FROM walk JOIN edges ON edges.src = walk.id
+			WHERE walk.hops < ?
+			ORDER BY 2
+			LIMIT ?
+		)
+		SELECT e.name, e.entity_type, MIN(walk.hops) AS hops, (SELECT COUNT(*) FROM walk) AS walked
+		FROM walk JOIN entities e ON e.id = walk.id
+		WHERE walk.id != ?
+		GROUP BY walk.id
+		ORDER BY hops, e.name
+		LIMIT ?`, strings.Join(edges, " UNION ALL "))
+
+	rows, err := s.rdb().Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query closure: %w", err)
+	}
+	defer rows.Close()
+
+	result := &ClosureResult{Start: start, Entities: []ReachableEntity{}}
+	for rows.Next() {
+		var entity ReachableEntity
+		var walked int
+		if err := rows.Scan(&entity.Name, &entity.EntityType, &entity.Hops, &walked); err != nil {
+			return nil, fmt.Errorf("failed to scan closure entity: %w", err)
+		}
+		if walked >= walkLimit {
+			result.Truncated = true // the walk stopped early; farther entities may be missing
+		}
+		result.Entities = append(result.Entities, entity)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating closure: %w", err)
+	}
+
+	if len(result.Entities) > maxNodes {
+		result.Entities = result.Entities[:maxNodes]
+		result.Truncated = true
+	}
+	return result, nil
+}
+
+// FindAllPaths returns up to maxPaths acyclic paths from from to to along
+// outgoing relations, shortest first. The search reads the relations leaving
+// each entity it visits rather than loading the whole graph.
+func (s *SQLiteStorage) FindAllPaths(from, to string, maxDepth int, maxPaths int) ([][]Relation, error) {
+	maxDepth, maxPaths, err := pathLimits(maxDepth, maxPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range []string{from, to} {
+		var id int64
+		err := s.rdb().QueryRow("SELECT id FROM entities WHERE namespace = ? AND name = ?", s.ns(), name).Scan(&id)
+		if err == sql.ErrNoRows {
+			return nil, entityNotFound(name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to query entity: %w", err)
+		}
+	}
+
+	return allPaths(from, to, maxDepth, maxPaths, func(name string) ([]Relation, error) {
+		rows, err := s.rdb().Query(`
+			SELECT f.name, t.name, r.relation_type, r.created_at
+			FROM relations r
+			JOIN entities f ON r.from_entity_id = f.id
+			JOIN entities t ON r.to_entity_id = t.id
+			WHERE f.namespace = ? AND f.name = ?
+		`, s.ns(), name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query relations: %w", err)
+		}
+		defer rows.Close()
+
+		var relations []Relation
+		for rows.Next() {
+			relation, err := scanRelation(rows)
+			if err != nil {
+				return nil, err
+			}
+			relations = append(relations, relation)
+		}
+		if err = rows.Err(); err != nil {
+			return nil, fmt.Errorf("error iterating relations: %w", err)
+		}
+		return relations, nil
+	})
+}
+
+// RelationExists reports whether the exact relation from -> to of relationType exists
+func (s *SQLiteStorage) RelationExists(from, to, relationType string) (bool, error) {
+	var exists int
+	err := s.rdb().QueryRow(`
+		SELECT 1
+		FROM relations r
+		JOIN entities f ON r.from_entity_id = f.id
+		JOIN entities t ON r.to_entity_id = t.id
+		WHERE f.namespace = ?1 AND f.name = ?2 AND t.namespace = ?1 AND t.name = ?3 AND r.relation_type = ?4
+		LIMIT 1
+	`, s.ns(), from, to, relationType).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to query relation: %w", err)
+	}
+	return true, nil
+}
+
+// TopConnectedPairs returns the (from, to) pairs with the most distinct relations,
+// ties broken by names
+func (s *SQLiteStorage) TopConnectedPairs(limit int) ([]PairCount, error) {
+	if limit <= 0 {
+		limit = -1 // no limit
+	}
+
+	rows, err := s.rdb().Query(`
+		SELECT f.name, t.name, COUNT(DISTINCT r.relation_type) AS relation_count
+		FROM relations r
+		JOIN entities f ON r.from_entity_id = f.id
+		JOIN entities t ON r.to_entity_id = t.id
+		WHERE f.namespace = ?
+		GROUP BY r.from_entity_id, r.to_entity_id
+		ORDER BY relation_count DESC, f.name, t.name
+		LIMIT ?
+	`, s.ns(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query relation pairs: %w", err)
+	}
+	defer rows.Close()
+
+	pairs := []PairCount{}
+	for rows.Next() {
+		var p PairCount
+		if err := rows.Scan(&p.From, &p.To, &p.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan relation pair: %w", err)
+		}
+		pairs = append(pairs, p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating relation pairs: %w", err)
+	}
+	return pairs, nil
+}
+
+// EntitiesByObservationCount filters entities by their observation count with
+// GROUP BY ... HAVING; the LEFT JOIN keeps entities without observations
+func (s *SQLiteStorage) EntitiesByObservationCount(minCount, maxCount, limit int, ascending bool) ([]ObservationCount, error) {
+	if err := validateCountRange(minCount, maxCount); err != nil {
+		return nil, err
+	}
+	upper := int64(maxCount)
+	if maxCount < 0 {
+		upper = math.MaxInt64
+	}
+	if limit <= 0 {
+		limit = -1 // no limit
+	}
+	direction := "DESC"
+	if ascending {
+		direction = "ASC"
+	}
+
+	rows, err := s.rdb().Query(`
+		SELECT e.name, e.entity_type, COUNT(o.id) AS observation_count
+		FROM entities e
+		LEFT JOIN observations o ON o.entity_id = e.id
+		WHERE e.namespace = ?
+		GROUP BY e.id
+		HAVING COUNT(o.id) BETWEEN ? AND ?
+		ORDER BY observation_count `+direction+`, e.name
+		LIMIT ?
+	`, s.ns(), minCount, upper, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query observation counts: %w", err)
+	}
+	defer rows.Close()
+
+	counts := []ObservationCount{}
+	for rows.Next() {
+		var c ObservationCount
+		if err := rows.Scan(&c.Name, &c.EntityType, &c.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan observation count: %w", err)
+		}
+		counts = append(counts, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating observation counts: %w", err)
+	}
+	return counts, nil
+}
+
+// SelfRelations returns the relations whose from and to are the same entity
+func (s *SQLiteStorage) SelfRelations() ([]Relation, error) {
+	rows, err := s.rdb().Query(`
+		SELECT e.name, e.name, r.relation_type, r.created_at
+		FROM relations r
+		JOIN entities e ON r.from_entity_id = e.id
+		WHERE r.from_entity_id = r.to_entity_id AND e.namespace = ?
+		ORDER BY e.name, r.relation_type
+	`, s.ns())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query self relations: %w", err)
+	}
+	defer rows.Close()
+
+	relations := []Relation{}
+	for rows.Next() {
+		relation, err := scanRelation(rows)
+		if err != nil {
+			return nil, err
+		}
+		relations = append(relations, relation)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating self relations: %w", err)
+	}
+	return relations, nil
+}
+
+// RelationsAmong returns the relations whose endpoints are both in names,
+// created at or before asOf unless it is zero
+func (s *SQLiteStorage) RelationsAmong(names []string, asOf time.Time) ([]Relation, error) {
+	names = slices.Compact(slices.Sorted(slices.Values(names)))
+	among := make(map[string]bool, len(names))
+	for _, name := range names {
+		among[name] = true
+	}
+
+	query := `
+		SELECT f.name, t.name, r.relation_type, r.created_at
+		FROM relations r
+		JOIN entities f ON r.from_entity_id = f.id
+		JOIN entities t ON r.to_entity_id = t.id
+		WHERE f.namespace = ? AND f.name IN (%s)`
+	var extra []any
+	if !asOf.IsZero() {
+		query += " AND r.created_at <= ?"
+		extra = append(extra, sqliteTimestamp(asOf))
+	}
+
+	relations := []Relation{}
+	err := s.queryByNames(query+" ORDER BY r.id", names, extra, func(rows *sql.Rows) error {
+		relation, err := scanRelation(rows)
+		if err != nil {
+			return err
+		}
+		if among[relation.To] {
+			relations = append(relations, relation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query relations: %w", err)
+	}
+	return relations, nil
+}
+
+// RelationsTouching looks relations up by their source and then by their
+// target, so each chunk of names binds its placeholders once; a relation
+// between two of the names is found twice and kept once
+func (s *SQLiteStorage) RelationsTouching(names []string) ([]Relation, error) {
+	names = slices.Compact(slices.Sorted(slices.Values(names)))
+	seen := make(map[Relation]bool)
+	relations := []Relation{}
+	for _, side := range []string{"f", "t"} {
+		query := `
+			SELECT f.name, t.name, r.relation_type, r.created_at
+			FROM relations r
+			JOIN entities f ON r.from_entity_id = f.id
+			JOIN entities t ON r.to_entity_id = t.id
+			WHERE ` + side + `.namespace = ? AND ` + side + `.name IN (%s)
+			ORDER BY r.id`
+		err := s.queryByNames(query, names, nil, func(rows *sql.Rows) error {
+			relation, err := scanRelation(rows)
+			if err != nil {
+				return err
+			}
+			if !seen[relation.key()] {
+				seen[relation.key()] = true
+				relations = append(relations, relation)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to query relations: %w", err)
+		}
+	}
+	return relations, nil
+}
+
+// ObservationCounts counts with a LEFT JOIN so entities without observations
+// are reported with 0
+func (s *SQLiteStorage) ObservationCounts(names []string) (map[string]int, error) {
+	counts := make(map[string]int, len(names))
+	err := s.queryByNames(`
+		SELECT e.name, COUNT(o.id)
+		FROM entities e
+		LEFT JOIN observations o ON o.entity_id = e.id
+		WHERE e.namespace = ? AND e.name IN (%s)
+		GROUP BY e.id`, slices.Compact(slices.Sorted(slices.Values(names))), nil, func(rows *sql.Rows) error {
+		var name string
+		var count int
+		if err := rows.Scan(&name, &count); err != nil {
+			return err
+		}
+		counts[name] = count
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count observations: %w", err)
+	}
+	return counts, nil
+}
+
+// EmptyEntities returns the entities without observations, those the LEFT
+// JOIN finds no observation for
+func (s *SQLiteStorage) EmptyEntities() ([]EntitySummary, error) {
+	rows, err := s.rdb().Query(`
+		SELECT e.name, e.entity_type
+		FROM entities e
+		LEFT JOIN observations o ON o.entity_id = e.id
+		WHERE e.namespace = ? AND o.id IS NULL
+		ORDER BY e.name
+	`, s.ns())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query empty entities: %w", err)
+	}
+	defer rows.Close()
+
+	entities := []EntitySummary{}
+	for rows.Next() {
+		var entity EntitySummary
+		if err := rows.Scan(&entity.Name, &entity.EntityType); err != nil {
+			return nil, fmt.Errorf("failed to scan empty entity: %w", err)
+		}
+		entities = append(entities, entity)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating empty entities: %w", err)
+	}
+	return entities, nil
+}
+
+// RelationTypeSamples returns the oldest limit relations of each type, numbering
+// the relations of every type with a window function
+func (s *SQLiteStorage) RelationTypeSamples(limit int) (map[string][]Relation, error) {
+	limit, err := relationSamples(limit)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.rdb().Query(`
+		SELECT from_name, to_name, relation_type, created_at
+		FROM (
+			SELECT f.name AS from_name, t.name AS to_name, r.relation_type, r.created_at, r.id,
+				ROW_NUMBER() OVER (PARTITION BY r.relation_type ORDER BY r.created_at, r.id) AS n
+			FROM relations r
+			JOIN entities f ON r.from_entity_id = f.id
+			JOIN entities t ON r.to_entity_id = t.id
+			WHERE f.namespace = ?
+		)
+		WHERE n <= ?
+		ORDER BY relation_type, n
+	`, s.ns(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query relation samples: %w", err)
+	}
+	defer rows.Close()
+
+	samples := make(map[string][]Relation)
+	for rows.Next() {
+		relation, err := scanRelation(rows)
+		if err != nil {
+			return nil, err
+		}
+		samples[relation.RelationType] = append(samples[relation.RelationType], relation)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating relation samples: %w", err)
+	}
+	return samples, nil
+}
+
+// ReadGraphAsOf reads the graph as it existed at asOf (see graphAsOf)
+func (s *SQLiteStorage) ReadGraphAsOf(asOf time.Time, mode string, limit int, filter TypeFilter) (interface{}, error) {
+	graph, err := s.graphAsOf(asOf)
+	if err != nil {
+		return nil, err
+	}
+	result := readGraphFrom(graph, mode, limit, filter, s.config)
+	s.config.noteMoreObservations(result)
+	return result, nil
+}
+
+// SearchNodesAsOf searches the graph as it existed at asOf (see graphAsOf). The
+// snapshot is matched in memory, so ranking follows the JSONL search, not FTS5.
+func (s *SQLiteStorage) SearchNodesAsOf(asOf time.Time, query string, limit int) (*SearchResult, error) {
+	graph, err := s.graphAsOf(asOf)
+	if err != nil {
+		return nil, err
+	}
+	return searchGraph(graph, query, limit, 0, s.config), nil
+}
+
+// sqliteTimestamp formats t the way CURRENT_TIMESTAMP stores it (UTC, second precision)
+func sqliteTimestamp(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+// graphAsOf loads the entities, observations, and relations created at or before
+// asOf. Only creations are filtered: deleted rows are gone and edited rows show
+// their current content, so the snapshot is exact only for append-only history.
+func (s *SQLiteStorage) graphAsOf(asOf time.Time) (*KnowledgeGraph, error) {
+	cutoff := sqliteTimestamp(asOf)
+	graph := &KnowledgeGraph{Entities: []Entity{}, Relations: []Relation{}}
+
+	rows, err := s.rdb().Query(`
+		SELECT id, name, entity_type
+		FROM entities
+		WHERE namespace = ? AND created_at <= ?
+		ORDER BY created_at, id
+	`, s.ns(), cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query entities: %w", err)
+	}
+	defer rows.Close()
+
+	index := make(map[int64]int)
+	for rows.Next() {
+		var id int64
+		entity := Entity{Observations: []string{}}
+		if err := rows.Scan(&id, &entity.Name, &entity.EntityType); err != nil {
+			return nil, fmt.Errorf("failed to scan entity: %w", err)
+		}
+		index[id] = len(graph.Entities)
+		graph.Entities = append(graph.Entities, entity)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating entities: %w", err)
+	}
+
+	rows, err = s.rdb().Query(`
+		SELECT entity_id, content, COALESCE(source, '')
+		FROM observations
+		WHERE entity_id IN (SELECT id FROM entities WHERE namespace = ?) AND created_at <= ?
+		ORDER BY entity_id, id
+	`, s.ns(), cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query observations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entityID int64
+		var content, source string
+		if err := rows.Scan(&entityID, &content, &source); err != nil {
+			return nil, fmt.Errorf("failed to scan observation: %w", err)
+		}
+		i, ok := index[entityID]
+		if !ok {
+			continue
+		}
+		graph.Entities[i].Observations = append(graph.Entities[i].Observations, content)
+		if source != "" {
+			setSource(&graph.Entities[i], content, source)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating observations: %w", err)
+	}
+
+	rows, err = s.rdb().Query(`
+		SELECT f.name, t.name, r.relation_type, r.created_at
+		FROM relations r
+		JOIN entities f ON r.from_entity_id = f.id
+		JOIN entities t ON r.to_entity_id = t.id
+		WHERE f.namespace = ?1 AND r.created_at <= ?2 AND f.created_at <= ?2 AND t.created_at <= ?2
+		ORDER BY r.created_at, r.id
+	`, s.ns(), cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query relations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		relation, err := scanRelation(rows)
+		if err != nil {
+			return nil, err
+		}
+		graph.Relations = append(graph.Relations, relation)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating relations: %w", err)
+	}
+
+	return graph, nil
+}
+
+// updateAccessStats updates last_accessed_at and access_count for the given entity IDs.
+// Runs asynchronously to avoid blocking read operations.
+func (s *SQLiteStorage) updateAccessStats(entityIDs []int64) {
+	if len(entityIDs) == 0 {
+		return
+	}
+
+	go func() {
+		placeholders := make([]string, len(entityIDs))
+		args := make([]interface{}, len(entityIDs))
+		for i, id := range entityIDs {
+			placeholders[i] = "?"
+			args[i] = id
+		}
+		query := fmt.Sprintf(`
+			UPDATE entities
+			SET last_accessed_at = CURRENT_TIMESTAMP,
+			    access_count = COALESCE(access_count, 0) + 1
+			WHERE id IN (%s)
+		`, strings.Join(placeholders, ","))
+		s.db.Exec(query, args...)
+	}()
+}
+
+// MergeEntities merges source entity into target: migrates observations and relations, then deletes source.
+func (s *SQLiteStorage) MergeEntities(sourceName, targetName string) (*MergeResult, error) {
+	var result *MergeResult
+	err := s.retryWriteTouching([]string{sourceName, targetName}, func() (err error) {
+		result, err = s.mergeEntities(sourceName, targetName)
+		return err
+	})
+	return result, err
+}
+
+// mergeEntities performs a single MergeEntities attempt
+func (s *SQLiteStorage) mergeEntities(sourceName, targetName string) (*MergeResult, error) {
+	if sourceName == targetName {
+		return nil, fmt.Errorf("%w: cannot merge entity %q into itself", ErrInvalidArgument, sourceName)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Get source and target entity IDs
+	var sourceID, targetID int64
+	err = tx.QueryRow("SELECT id FROM entities WHERE namespace = ? AND name = ?", s.ns(), sourceName).Scan(&sourceID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("source %w", entityNotFound(sourceName))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query source entity: %w", err)
+	}
+	err = tx.QueryRow("SELECT id FROM entities WHERE namespace = ? AND name = ?", s.ns(), targetName).Scan(&targetID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("target %w", entityNotFound(targetName))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query target entity: %w", err)
+	}
+
+	// Migrate observations (skip duplicates)
+	obsResult, err := tx.Exec(`
+		INSERT INTO observations (entity_id, content)
+		SELECT ?, content FROM observations WHERE entity_id = ?
+		ON CONFLICT(entity_id, content) DO NOTHING
+	`, targetID, sourceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate observations: %w", err)
+	}
+	mergedObs, _ := obsResult.RowsAffected()
+
+	// Redirect outgoing relations from source to target
+	outResult, err := tx.Exec(`
+		UPDATE relations SET from_entity_id = ?
+		WHERE from_entity_id = ?
+		AND NOT EXISTS (
+			SELECT 1 FROM relations r2
+			WHERE r2.from_entity_id = ? AND r2.to_entity_id = relations.to_entity_id
+			AND r2.relation_type = relations.relation_type
+		)
+	`, targetID, sourceID, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to redirect outgoing relations: %w", err)
+	}
+	mergedOut, _ := outResult.RowsAffected()
+
+	// Redirect incoming relations to target
+	inResult, err := tx.Exec(`
+		UPDATE relations SET to_entity_id = ?
+		WHERE to_entity_id = ?
+		AND NOT EXISTS (
+			SELECT 1 FROM relations r2
+			WHERE r2.from_entity_id = relations.from_entity_id AND r2.to_entity_id = ?
+			AND r2.relation_type = relations.relation_type
+		)
+	`, targetID, sourceID, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to redirect incoming relations: %w", err)
+	}
+	mergedIn, _ := inResult.RowsAffected()
+
+	// Delete source entity (cascades observations and remaining duplicate relations)
+	_, err = tx.Exec("DELETE FROM entities WHERE id = ?", sourceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete source entity: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit merge: %w", err)
+	}
+
+	return &MergeResult{
+		MergedObservations: int(mergedObs),
+		MergedRelations:    int(mergedOut + mergedIn),
+		SourceDeleted:      true,
+	}, nil
+}
+
+// UpdateEntityType updates the entity type for a given entity name.
+func (s *SQLiteStorage) UpdateEntityType(name string, newType string) error {
+	return s.retryWriteTouching([]string{name}, func() error {
+		return s.updateEntityType(name, newType)
+	})
+}
+
+// updateEntityType performs a single UpdateEntityType attempt
+func (s *SQLiteStorage) updateEntityType(name string, newType string) error {
+	result, err := s.db.Exec(
+		"UPDATE entities SET entity_type = ?, updated_at = CURRENT_TIMESTAMP WHERE namespace = ? AND name = ?",
+		newType, s.ns(), name,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update entity type: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return entityNotFound(name)
+	}
+	return nil
+}
+
+// SetEntityType changes an entity's type (and updated_at), leaving its
+// observations untouched, and returns the updated entity with all its
+// observations, read in the same transaction. Unlike open_nodes it records no
+// access stats.
+func (s *SQLiteStorage) SetEntityType(name string, entityType string) (*Entity, error) {
+	var entity *Entity
+	err := s.retryWriteTouching([]string{name}, func() (err error) {
+		entity, err = s.setEntityType(name, entityType)
+		return err
+	})
+	return entity, err
+}
+
+// setEntityType performs a single SetEntityType attempt
+func (s *SQLiteStorage) setEntityType(name string, entityType string) (*Entity, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	entity := &Entity{Name: name, EntityType: entityType, Observations: []string{}}
+	var id int64
+	var description sql.NullString
+	err = tx.QueryRow(`
+		UPDATE entities SET entity_type = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE namespace = ? AND name = ?
+		RETURNING id, description
+	`, entityType, s.ns(), name).Scan(&id, &description)
+	if err == sql.ErrNoRows {
+		return nil, entityNotFound(name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update entity type: %w", err)
+	}
+	entity.Description = description.String
+
+	order := "id"
+	if s.config.newestFirst() {
+		order = "id DESC"
+	}
+	rows, err := tx.Query("SELECT content FROM observations WHERE entity_id = ? ORDER BY "+order, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query observations: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var content string
+		if err := rows.Scan(&content); err != nil {
+			return nil, fmt.Errorf("failed to scan observation: %w", err)
+		}
+		entity.Observations = append(entity.Observations, content)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating observations: %w", err)
+	}
+	rows.Close()
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return entity, nil
+}
+
+// UpdateEntity renames, retypes, and/or redescribes an entity in one
+// transaction, skipping fields left empty (a nil description). Relations
+// reference entity IDs, so they follow a rename.
+func (s *SQLiteStorage) UpdateEntity(oldName, newName, newType string, description *string) (*Entity, error) {
+	if err := validateEntityUpdate(newName, newType, description); err != nil {
+		return nil, err
+	}
+	if err := s.retryWriteTouching([]string{oldName, newName}, func() error {
+		return s.updateEntity(oldName, newName, newType, description)
+	}); err != nil {
+		return nil, err
+	}
+	if newName == "" {
+		newName = oldName
+	}
+	return openEntity(s, newName)
+}
+
+// updateEntity performs a single UpdateEntity attempt
+func (s *SQLiteStorage) updateEntity(oldName, newName, newType string, description *string) error {
+	fts := newName != "" && newName != oldName && s.isFTSAvailable()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRow("SELECT id FROM entities WHERE namespace = ? AND name = ?", s.ns(), oldName).Scan(&id)
+	if err == sql.ErrNoRows {
+		return entityNotFound(oldName)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query entity: %w", err)
+	}
+
+	// observations_fts indexes the entity name alongside each observation, so
+	// the entries under the old name are removed before the rename
+	if fts {
+		if _, err := tx.Exec(`
+			INSERT INTO observations_fts(observations_fts, rowid, content, entity_name)
+			SELECT 'delete', id, content, ? FROM observations WHERE entity_id = ?
+		`, oldName, id); err != nil {
+			return fmt.Errorf("failed to update observation index: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE entities
+		SET name = COALESCE(NULLIF(?, ''), name),
+			entity_type = COALESCE(NULLIF(?, ''), entity_type),
+			description = COALESCE(?, description),
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, newName, newType, description, id)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%w: entity %q already exists", ErrConflict, newName)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update entity: %w", err)
+	}
+
+	if fts {
+		if _, err := tx.Exec(`
+			INSERT INTO observations_fts(rowid, content, entity_name)
+			SELECT id, content, ? FROM observations WHERE entity_id = ?
+		`, newName, id); err != nil {
+			return fmt.Errorf("failed to update observation index: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UpdateObservation replaces an observation's content for a given entity.
 func (s *SQLiteStorage) UpdateObservation(entityName string, oldContent string, newContent string) error {
+	return s.retryWriteTouching([]string{entityName}, func() error {
+		return s.updateObservation(entityName, oldContent, newContent)
+	})
+}
+
+// updateObservation performs a single UpdateObservation attempt
+func (s *SQLiteStorage) updateObservation(entityName string, oldContent string, newContent string) error {
 	result, err := s.db.Exec(`
 		UPDATE observations SET content = ?
-		WHERE entity_id = (SELECT id FROM entities WHERE name = ?)
+		WHERE entity_id = (SELECT id FROM entities WHERE namespace = ? AND name = ?)
 		AND content = ?
-	`, newContent, entityName, oldContent)
+	`, newContent, s.ns(), entityName, oldContent)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%w: entity %q already has observation %q", ErrConflict, entityName, newContent)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to update observation: %w", err)
 	}
 	rows, _ := result.RowsAffected()
 	if rows == 0 {
-		return fmt.Errorf("observation not found for entity %q", entityName)
+		var exists int
+		s.rdb().QueryRow("SELECT COUNT(*) FROM entities WHERE namespace = ? AND name = ?", s.ns(), entityName).Scan(&exists)
+		if exists == 0 {
+			return entityNotFound(entityName)
+		}
+		return fmt.Errorf("%w: %q on entity %q", ErrObservationNotFound, oldContent, entityName)
+	}
+	if _, err := s.db.Exec("UPDATE entities SET updated_at = CURRENT_TIMESTAMP WHERE namespace = ? AND name = ?", s.ns(), entityName); err != nil {
+		return fmt.Errorf("failed to update entity: %w", err)
+	}
+	return nil
+}
+
+// ReplaceInObservations rewrites every observation containing old in one
+// transaction; the FTS triggers keep the search index in step
+func (s *SQLiteStorage) ReplaceInObservations(old, new string, caseSensitive bool) (int, error) {
+	replace, err := observationReplacer(old, new, caseSensitive)
+	if err != nil {
+		return 0, err
+	}
+	var modified int
+	err = s.retryWrite(func() error {
+		var err error
+		modified, err = s.replaceInObservations(old, replace, caseSensitive)
+		return err
+	})
+	return modified, err
+}
+
+// replaceInObservations performs a single ReplaceInObservations attempt
+func (s *SQLiteStorage) replaceInObservations(old string, replace func(string) (string, bool), caseSensitive bool) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// instr narrows a case-sensitive search; SQLite's lower() folds only ASCII,
+	// so a case-insensitive one checks every observation in Go
+	query := `
+		SELECT o.id, o.entity_id, o.content
+		FROM observations o
+		JOIN entities e ON o.entity_id = e.id
+		WHERE e.namespace = ?`
+	args := []interface{}{s.ns()}
+	if caseSensitive {
+		query += " AND instr(o.content, ?) > 0"
+		args = append(args, old)
+	}
+	rows, err := tx.Query(query+" ORDER BY o.id", args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query observations: %w", err)
+	}
+	type change struct {
+		id, entityID int64
+		content      string
+	}
+	var changes []change
+	for rows.Next() {
+		var c change
+		if err := rows.Scan(&c.id, &c.entityID, &c.content); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan observation: %w", err)
+		}
+		if replaced, changed := replace(c.content); changed {
+			c.content = replaced
+			changes = append(changes, c)
+		}
 	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, fmt.Errorf("error iterating observations: %w", err)
+	}
+	rows.Close()
+
+	// An update can collide with an observation that is itself still to be
+	// rewritten, so collisions are retried until a pass makes no progress. What
+	// still collides then duplicates a final observation and is merged into it.
+	touched := make(map[int64]bool)
+	pending := changes
+	for len(pending) > 0 {
+		var collided []change
+		for _, c := range pending {
+			_, err := tx.Exec("UPDATE observations SET content = ? WHERE id = ?", c.content, c.id)
+			if isUniqueViolation(err) {
+				collided = append(collided, c)
+				continue
+			}
+			if err != nil {
+				return 0, fmt.Errorf("failed to update observation: %w", err)
+			}
+			touched[c.entityID] = true
+		}
+		if len(collided) == len(pending) {
+			for _, c := range collided {
+				if _, err := tx.Exec("DELETE FROM observations WHERE id = ?", c.id); err != nil {
+					return 0, fmt.Errorf("failed to delete duplicate observation: %w", err)
+				}
+				touched[c.entityID] = true
+			}
+			break
+		}
+		pending = collided
+	}
+	for entityID := range touched {
+		if _, err := tx.Exec("UPDATE entities SET updated_at = CURRENT_TIMESTAMP WHERE id = ?", entityID); err != nil {
+			return 0, fmt.Errorf("failed to update entity: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return len(changes), nil
+}
+
+// SetObservations atomically replaces an entity's observations with the given list
+// (duplicates in the list are stored once)
+func (s *SQLiteStorage) SetObservations(entityName string, observations []string) error {
+	return s.retryWriteTouching([]string{entityName}, func() error {
+		return s.setObservations(entityName, observations)
+	})
+}
+
+// setObservations performs a single SetObservations attempt
+func (s *SQLiteStorage) setObservations(entityName string, observations []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var entityID int64
+	err = tx.QueryRow("SELECT id FROM entities WHERE namespace = ? AND name = ?", s.ns(), entityName).Scan(&entityID)
+	if err == sql.ErrNoRows {
+		return entityNotFound(entityName)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query entity: %w", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM observations WHERE entity_id = ?", entityID); err != nil {
+		return fmt.Errorf("failed to delete observations: %w", err)
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO observations (entity_id, content)
+		VALUES (?, ?)
+		ON CONFLICT(entity_id, content) DO NOTHING
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, obs := range observations {
+		if _, err := stmt.Exec(entityID, obs); err != nil {
+			return fmt.Errorf("failed to insert observation: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE entities SET updated_at = CURRENT_TIMESTAMP WHERE id = ?", entityID); err != nil {
+		return fmt.Errorf("failed to update entity: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return nil
 }
 
@@ -1409,10 +3664,11 @@ func (s *SQLiteStorage) DetectConflicts(entityName string) ([]Conflict, error) {
 		FROM observations o1
 		JOIN observations o2 ON o1.entity_id = o2.entity_id AND o1.id < o2.id
 		JOIN entities e ON e.id = o1.entity_id
+		WHERE e.namespace = ?
 	`
-	var args []interface{}
+	args := []interface{}{s.ns()}
 	if entityName != "" {
-		query += " WHERE e.name = ?"
+		query += " AND e.name = ?"
 		args = append(args, entityName)
 	}
 	query += " ORDER BY e.name, o1.id"
@@ -1521,59 +3777,412 @@ func prefixOverlap(a, b string) float64 {
 	return float64(common) / float64(minLen)
 }
 
+// Clear removes all entities, observations, and relations of the namespace in a
+// single transaction
+func (s *SQLiteStorage) Clear() (*ClearResult, error) {
+	var result *ClearResult
+	err := s.retryWrite(func() (err error) {
+		result, err = s.clear()
+		return err
+	})
+	return result, err
+}
+
+// clear performs a single Clear attempt
+func (s *SQLiteStorage) clear() (*ClearResult, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	const inNamespace = "(SELECT id FROM entities WHERE namespace = ?)"
+	result := &ClearResult{}
+	tables := []struct {
+		table string
+		where string
+		dest  *int
+	}{
+		// Children first; FTS delete triggers keep the indexes in sync row by row
+		{"relations", "from_entity_id IN " + inNamespace, &result.RelationsRemoved},
+		{"observations", "entity_id IN " + inNamespace, &result.ObservationsRemoved},
+		{"entities", "namespace = ?", &result.EntitiesRemoved},
+	}
+	for _, t := range tables {
+		if err := tx.QueryRow("SELECT COUNT(*) FROM "+t.table+" WHERE "+t.where, s.ns()).Scan(t.dest); err != nil {
+			return nil, fmt.Errorf("failed to count %s: %w", t.table, err)
+		}
+	}
+	for _, t := range tables {
+		if _, err := tx.Exec("DELETE FROM "+t.table+" WHERE "+t.where, s.ns()); err != nil {
+			return nil, fmt.Errorf("failed to clear %s: %w", t.table, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// Rebuild FTS indexes in case they had drifted from the content tables
+	if s.isFTSAvailable() {
+		if err := s.rebuildFTSIndex(); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// TransferEntities copies or moves entities to another namespace in one transaction
+func (s *SQLiteStorage) TransferEntities(names []string, toNamespace string, opts TransferOptions) (*TransferResult, error) {
+	var result *TransferResult
+	err := s.retryWrite(func() (err error) {
+		result, err = s.transferEntities(names, toNamespace, opts)
+		return err
+	})
+	return result, err
+}
+
+// transferEntities performs a single TransferEntities attempt
+func (s *SQLiteStorage) transferEntities(names []string, toNamespace string, opts TransferOptions) (*TransferResult, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	entityID := func(ns string) func(name string) (int64, error) {
+		return func(name string) (int64, error) {
+			var id int64
+			err := tx.QueryRow("SELECT id FROM entities WHERE namespace = ? AND name = ?", ns, name).Scan(&id)
+			if err == sql.ErrNoRows {
+				return 0, nil
+			}
+			if err != nil {
+				return 0, fmt.Errorf("failed to look up entity %s: %w", name, err)
+			}
+			return id, nil
+		}
+	}
+	exists := func(lookup func(string) (int64, error)) func(name string) (bool, error) {
+		return func(name string) (bool, error) {
+			id, err := lookup(name)
+			return id != 0, err
+		}
+	}
+	sourceID, targetID := entityID(s.ns()), entityID(toNamespace)
+
+	plan, err := planTransfer(names, s.ns(), toNamespace, opts, exists(sourceID), exists(targetID))
+	if err != nil {
+		return nil, err
+	}
+
+	var movedIDs []interface{}
+	for _, entry := range plan.result.Transferred {
+		srcID, err := sourceID(entry.Name)
+		if err != nil {
+			return nil, err
+		}
+		movedIDs = append(movedIDs, srcID)
+
+		var dstID int64
+		if entry.Overwritten {
+			if dstID, err = targetID(entry.TargetName); err != nil {
+				return nil, err
+			}
+			_, err = tx.Exec(`
+				UPDATE entities SET (entity_type, description) = (SELECT entity_type, description FROM entities WHERE id = ?), updated_at = CURRENT_TIMESTAMP
+				WHERE id = ?
+			`, srcID, dstID)
+			if err == nil {
+				_, err = tx.Exec("DELETE FROM observations WHERE entity_id = ?", dstID)
+			}
+		} else {
+			err = tx.QueryRow(`
+				INSERT INTO entities (namespace, name, entity_type, description, created_at, updated_at)
+				SELECT ?, ?, entity_type, description, created_at, updated_at FROM entities WHERE id = ?
+				RETURNING id
+			`, toNamespace, entry.TargetName, srcID).Scan(&dstID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to transfer entity %s: %w", entry.Name, err)
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO observations (entity_id, content, created_at, source, confidence, tags)
+			SELECT ?, content, created_at, source, confidence, tags FROM observations WHERE entity_id = ?
+			ORDER BY id
+		`, dstID, srcID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to transfer observations of %s: %w", entry.Name, err)
+		}
+	}
+
+	if len(movedIDs) == 0 {
+		return plan.result, tx.Commit()
+	}
+	placeholders := strings.Repeat("?,", len(movedIDs)-1) + "?"
+	touching := "from_entity_id IN (" + placeholders + ") OR to_entity_id IN (" + placeholders + ")"
+	touchingArgs := append(slices.Clone(movedIDs), movedIDs...)
+
+	if opts.Relations {
+		rows, err := tx.Query(`
+			SELECT f.name, t.name, r.relation_type, r.created_at
+			FROM relations r
+			JOIN entities f ON r.from_entity_id = f.id
+			JOIN entities t ON r.to_entity_id = t.id
+			WHERE `+touching+`
+			ORDER BY r.id
+		`, touchingArgs...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query relations: %w", err)
+		}
+		var source []Relation
+		for rows.Next() {
+			r, err := scanRelation(rows)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			source = append(source, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		mapped, err := plan.relations(source, exists(targetID))
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range mapped {
+			result, err := tx.Exec(`
+				INSERT INTO relations (from_entity_id, to_entity_id, relation_type, created_at)
+				SELECT f.id, t.id, ?4, COALESCE(?5, CURRENT_TIMESTAMP) FROM entities f, entities t
+				WHERE f.namespace = ?1 AND f.name = ?2 AND t.namespace = ?1 AND t.name = ?3
+				ON CONFLICT(from_entity_id, to_entity_id, relation_type) DO NOTHING
+			`, toNamespace, r.From, r.To, r.RelationType, relationCreatedAt(r))
+			if err != nil {
+				return nil, fmt.Errorf("failed to transfer relation: %w", err)
+			}
+			if n, _ := result.RowsAffected(); n > 0 {
+				plan.result.RelationsCopied++
+			}
+		}
+	}
+
+	if opts.Move {
+		deletes := []struct {
+			query string
+			args  []interface{}
+		}{
+			{"DELETE FROM relations WHERE " + touching, touchingArgs},
+			{"DELETE FROM observations WHERE entity_id IN (" + placeholders + ")", movedIDs},
+			{"DELETE FROM entities WHERE id IN (" + placeholders + ")", movedIDs},
+		}
+		for _, d := range deletes {
+			if _, err := tx.Exec(d.query, d.args...); err != nil {
+				return nil, fmt.Errorf("failed to remove moved entities: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transfer: %w", err)
+	}
+	return plan.result, nil
+}
+
 // ExportData exports all data for migration
 func (s *SQLiteStorage) ExportData() (*KnowledgeGraph, error) {
-	return s.readGraphFull()
+	return s.readGraphFull(0, TypeFilter{})
 }
 
-// ImportData imports data during migration
-func (s *SQLiteStorage) ImportData(graph *KnowledgeGraph) error {
-	if graph == nil {
-		return nil
+// WalkGraph calls onEntity for every entity and then onRelation for every relation.
+// Rows are read as the callbacks consume them, so only one entity is held in memory.
+func (s *SQLiteStorage) WalkGraph(onEntity func(Entity) error, onRelation func(Relation) error) error {
+	if err := s.walkEntities(onEntity); err != nil {
+		return err
+	}
+
+	rows, err := s.rdb().Query(`
+		SELECT f.name, t.name, r.relation_type, r.created_at
+		FROM relations r
+		JOIN entities f ON r.from_entity_id = f.id
+		JOIN entities t ON r.to_entity_id = t.id
+		WHERE f.namespace = ?
+		ORDER BY r.created_at, r.id
+	`, s.ns())
+	if err != nil {
+		return fmt.Errorf("failed to query relations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		relation, err := scanRelation(rows)
+		if err != nil {
+			return err
+		}
+		if err := onRelation(relation); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error iterating relations: %w", err)
+	}
+	return nil
+}
+
+// walkEntities reads entities joined with their observations in one ordered pass,
+// calling onEntity as each entity's last observation row goes by
+func (s *SQLiteStorage) walkEntities(onEntity func(Entity) error) error {
+	rows, err := s.rdb().Query(`
+		SELECT e.id, e.name, e.entity_type, e.description, o.content, o.source
+		FROM entities e
+		LEFT JOIN observations o ON o.entity_id = e.id
+		WHERE e.namespace = ?
+		ORDER BY e.created_at, e.id, o.id
+	`, s.ns())
+	if err != nil {
+		return fmt.Errorf("failed to query entities: %w", err)
 	}
+	defer rows.Close()
+
+	var current *Entity
+	var currentID int64
+	for rows.Next() {
+		var id int64
+		var name, entityType, description string
+		var content, source sql.NullString
+		if err := rows.Scan(&id, &name, &entityType, &description, &content, &source); err != nil {
+			return fmt.Errorf("failed to scan entity: %w", err)
+		}
+		if current == nil || id != currentID {
+			if current != nil {
+				if err := onEntity(*current); err != nil {
+					return err
+				}
+			}
+			current = &Entity{Name: name, EntityType: entityType, Description: description, Observations: []string{}}
+			currentID = id
+		}
+		if content.Valid {
+			current.Observations = append(current.Observations, content.String)
+			if source.String != "" {
+				setSource(current, content.String, source.String)
+			}
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error iterating entities: %w", err)
+	}
+	if current != nil {
+		return onEntity(*current)
+	}
+	return nil
+}
 
+// ImportData merges a graph into the store according to conflictMode (see
+// ConflictModeOverwrite and friends). Planning and writing share one transaction.
+func (s *SQLiteStorage) ImportData(graph *KnowledgeGraph, conflictMode string) (*ImportReport, error) {
+	var report *ImportReport
+	err := s.retryWrite(func() (err error) {
+		report, err = s.importData(graph, conflictMode)
+		return err
+	})
+	return report, err
+}
+
+// importData performs a single ImportData attempt
+func (s *SQLiteStorage) importData(graph *KnowledgeGraph, conflictMode string) (*ImportReport, error) {
 	tx, err := s.db.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
+	report, changes, err := planImport(graph, conflictMode, importLookup{
+		entity: func(name string) (*Entity, error) {
+			var id int64
+			entity := Entity{Name: name, Observations: []string{}}
+			err := tx.QueryRow("SELECT id, entity_type, description FROM entities WHERE namespace = ? AND name = ?", s.ns(), name).Scan(&id, &entity.EntityType, &entity.Description)
+			if err == sql.ErrNoRows {
+				return nil, nil
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to look up entity %s: %w", name, err)
+			}
+
+			rows, err := tx.Query("SELECT content FROM observations WHERE entity_id = ? ORDER BY id", id)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load observations for %s: %w", name, err)
+			}
+			defer rows.Close()
+			for rows.Next() {
+				var content string
+				if err := rows.Scan(&content); err != nil {
+					return nil, err
+				}
+				entity.Observations = append(entity.Observations, content)
+			}
+			return &entity, rows.Err()
+		},
+		relationExists: func(r Relation) (bool, error) {
+			var exists bool
+			err := tx.QueryRow(`
+				SELECT EXISTS(
+					SELECT 1 FROM relations r
+					JOIN entities ef ON r.from_entity_id = ef.id
+					JOIN entities et ON r.to_entity_id = et.id
+					WHERE ef.namespace = ?1 AND ef.name = ?2 AND et.namespace = ?1 AND et.name = ?3 AND r.relation_type = ?4
+				)
+			`, s.ns(), r.From, r.To, r.RelationType).Scan(&exists)
+			return exists, err
+		},
+		containsObservation: s.config.containsObservation,
+	})
+	if err != nil || changes == nil {
+		return report, err
+	}
+	graph = changes
+
 	// Import entities
 	if len(graph.Entities) > 0 {
 		entityStmt, err := tx.Prepare(`
-			INSERT INTO entities (name, entity_type) 
-			VALUES (?, ?) 
-			ON CONFLICT(name) DO UPDATE SET 
+			INSERT INTO entities (namespace, name, entity_type, description) 
+			VALUES (?, ?, ?, ?) 
+			ON CONFLICT(namespace, name) DO UPDATE SET 
 				entity_type = excluded.entity_type,
+				description = COALESCE(NULLIF(excluded.description, ''), description),
 				updated_at = CURRENT_TIMESTAMP
 			RETURNING id
 		`)
 		if err != nil {
-			return fmt.Errorf("failed to prepare entity statement: %w", err)
+			return nil, fmt.Errorf("failed to prepare entity statement: %w", err)
 		}
 		defer entityStmt.Close()
 
 		obsStmt, err := tx.Prepare(`
-			INSERT INTO observations (entity_id, content) 
-			VALUES (?, ?) 
+			INSERT INTO observations (entity_id, content, source)
+			VALUES (?, ?, ?)
 			ON CONFLICT(entity_id, content) DO NOTHING
 		`)
 		if err != nil {
-			return fmt.Errorf("failed to prepare observation statement: %w", err)
+			return nil, fmt.Errorf("failed to prepare observation statement: %w", err)
 		}
 		defer obsStmt.Close()
 
 		for _, entity := range graph.Entities {
 			var entityID int64
-			err = entityStmt.QueryRow(entity.Name, entity.EntityType).Scan(&entityID)
+			err = entityStmt.QueryRow(s.ns(), entity.Name, entity.EntityType, entity.Description).Scan(&entityID)
 			if err != nil {
-				return fmt.Errorf("failed to import entity %s: %w", entity.Name, err)
+				return nil, fmt.Errorf("failed to import entity %s: %w", entity.Name, err)
 			}
 
 			for _, obs := range entity.Observations {
-				_, err = obsStmt.Exec(entityID, obs)
+				_, err = obsStmt.Exec(entityID, obs, entity.ObservationSources[obs])
 				if err != nil {
-					return fmt.Errorf("failed to import observation for %s: %w", entity.Name, err)
+					return nil, fmt.Errorf("failed to import observation for %s: %w", entity.Name, err)
 				}
 			}
 		}
@@ -1582,31 +4191,32 @@ func (s *SQLiteStorage) ImportData(graph *KnowledgeGraph) error {
 	// Import relations
 	if len(graph.Relations) > 0 {
 		relStmt, err := tx.Prepare(`
-			INSERT INTO relations (from_entity_id, to_entity_id, relation_type)
+			INSERT INTO relations (from_entity_id, to_entity_id, relation_type, created_at)
 			SELECT 
-				(SELECT id FROM entities WHERE name = ? LIMIT 1),
-				(SELECT id FROM entities WHERE name = ? LIMIT 1),
-				?
-			WHERE EXISTS(SELECT 1 FROM entities WHERE name = ?)
-			  AND EXISTS(SELECT 1 FROM entities WHERE name = ?)
+				(SELECT id FROM entities WHERE namespace = ?1 AND name = ?2),
+				(SELECT id FROM entities WHERE namespace = ?1 AND name = ?3),
+				?4,
+				COALESCE(?5, CURRENT_TIMESTAMP)
+			WHERE EXISTS(SELECT 1 FROM entities WHERE namespace = ?1 AND name = ?2)
+			  AND EXISTS(SELECT 1 FROM entities WHERE namespace = ?1 AND name = ?3)
 			ON CONFLICT(from_entity_id, to_entity_id, relation_type) DO NOTHING
 		`)
 		if err != nil {
-			return fmt.Errorf("failed to prepare relation statement: %w", err)
+			return nil, fmt.Errorf("failed to prepare relation statement: %w", err)
 		}
 		defer relStmt.Close()
 
 		for _, rel := range graph.Relations {
-			_, err = relStmt.Exec(rel.From, rel.To, rel.RelationType, rel.From, rel.To)
+			_, err = relStmt.Exec(s.ns(), rel.From, rel.To, rel.RelationType, relationCreatedAt(rel))
 			if err != nil {
-				return fmt.Errorf("failed to import relation: %w", err)
+				return nil, fmt.Errorf("failed to import relation: %w", err)
 			}
 		}
 	}
 
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit import transaction: %w", err)
+		return nil, fmt.Errorf("failed to commit import transaction: %w", err)
 	}
 
-	return nil
+	return report, nil
 }
@@ -0,0 +1,122 @@
+// Benchmarks for observation content search on the SQLite backend.
+// Run with: go test -run '^$' -bench . -benchmem ./storage/
+
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// benchEntityCount is the number of seeded entities (each with 5 observations)
+const benchEntityCount = 2000
+
+// newBenchSQLiteStorage creates a SQLite storage seeded with a large graph
+func newBenchSQLiteStorage(b *testing.B) *SQLiteStorage {
+	b.Helper()
+
+	tempDir, err := os.MkdirTemp("", "sqlite_bench")
+	if err != nil {
+		b.Fatalf("Failed to create temp dir: %v", err)
+	}
+	b.Cleanup(func() { os.RemoveAll(tempDir) })
+
+	config := Config{
+		FilePath:    filepath.Join(tempDir, "bench.db"),
+		WALMode:     true,
+		CacheSize:   10000,
+		BusyTimeout: 5000,
+	}
+	s, err := NewSQLiteStorage(config)
+	if err != nil {
+		b.Fatalf("Failed to create SQLite storage: %v", err)
+	}
+	if err := s.Initialize(); err != nil {
+		b.Fatalf("Failed to initialize storage: %v", err)
+	}
+	b.Cleanup(func() { s.Close() })
+
+	if _, err := s.CreateEntities(benchEntities(benchEntityCount)); err != nil {
+		b.Fatalf("Failed to seed entities: %v", err)
+	}
+
+	return s
+}
+
+// benchEntities returns n entities with 5 observations each
+func benchEntities(n int) []Entity {
+	entities := make([]Entity, 0, n)
+	for i := 0; i < n; i++ {
+		entities = append(entities, Entity{
+			Name:       fmt.Sprintf("Entity-%05d", i),
+			EntityType: fmt.Sprintf("type-%d", i%10),
+			Observations: []string{
+				fmt.Sprintf("Observation %d about distributed systems", i),
+				fmt.Sprintf("Works with component %d in the pipeline", i),
+				fmt.Sprintf("Prefers tool-%d for daily tasks", i%50),
+				fmt.Sprintf("Joined the project in year %d", 2000+i%25),
+				fmt.Sprintf("Unique marker m%05d", i),
+			},
+		})
+	}
+	return entities
+}
+
+// benchRelations links each entity to the next two, for 2 relations per entity
+func benchRelations(n int) []Relation {
+	relations := make([]Relation, 0, 2*n)
+	for i := 0; i < n; i++ {
+		for _, step := range []int{1, 2} {
+			relations = append(relations, Relation{
+				From:         fmt.Sprintf("Entity-%05d", i),
+				To:           fmt.Sprintf("Entity-%05d", (i+step)%n),
+				RelationType: fmt.Sprintf("rel-%d", step),
+			})
+		}
+	}
+	return relations
+}
+
+// BenchmarkSearchNodesBasic measures the LIKE-based fallback used when FTS5 is unavailable.
+// '%query%' patterns cannot use any index, so this scans the observations table.
+func BenchmarkSearchNodesBasic(b *testing.B) {
+	s := newBenchSQLiteStorage(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.searchNodesBasic("m01234", 10, 0); err != nil {
+			b.Fatalf("searchNodesBasic failed: %v", err)
+		}
+	}
+}
+
+// BenchmarkSearchNodesFTS measures the FTS5 search path for the same query
+func BenchmarkSearchNodesFTS(b *testing.B) {
+	s := newBenchSQLiteStorage(b)
+	if !s.isFTSAvailable() {
+		b.Skip("FTS5 not available")
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.SearchNodesWithFTS("m01234", 10); err != nil {
+			b.Fatalf("SearchNodesWithFTS failed: %v", err)
+		}
+	}
+}
+
+// BenchmarkObservationExactLookup measures exact content lookups, which use idx_observations_content
+func BenchmarkObservationExactLookup(b *testing.B) {
+	s := newBenchSQLiteStorage(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var entityID int64
+		content := fmt.Sprintf("Unique marker m%05d", i%benchEntityCount)
+		if err := s.rdb().QueryRow("SELECT entity_id FROM observations WHERE content = ?", content).Scan(&entityID); err != nil {
+			b.Fatalf("exact lookup failed: %v", err)
+		}
+	}
+}
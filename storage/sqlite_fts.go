@@ -2,12 +2,15 @@ package storage
 
 import (
 	"fmt"
+	"slices"
+	"sort"
 	"strings"
+	"unicode"
 )
 
 // FTSConfig holds FTS5 configuration
 type FTSConfig struct {
-	Enabled          bool
+	Enabled          bool   // false skips FTS and forces basic LIKE search
 	Tokenizer        string // porter, unicode61, etc.
 	RemoveDiacritics bool
 }
@@ -19,6 +22,7 @@ func (s *SQLiteStorage) createFTSSchema() error {
 	CREATE VIRTUAL TABLE IF NOT EXISTS entities_fts USING fts5(
 		name, 
 		entity_type, 
+		description,
 		content='entities', 
 		content_rowid='id',
 		tokenize='porter unicode61 remove_diacritics 1'
@@ -35,16 +39,16 @@ func (s *SQLiteStorage) createFTSSchema() error {
 
 	-- Triggers to keep FTS tables in sync
 	CREATE TRIGGER IF NOT EXISTS entities_fts_insert AFTER INSERT ON entities BEGIN
-		INSERT INTO entities_fts(rowid, name, entity_type) VALUES (new.id, new.name, new.entity_type);
+		INSERT INTO entities_fts(rowid, name, entity_type, description) VALUES (new.id, new.name, new.entity_type, new.description);
 	END;
 
 	CREATE TRIGGER IF NOT EXISTS entities_fts_delete AFTER DELETE ON entities BEGIN
-		INSERT INTO entities_fts(entities_fts, rowid, name, entity_type) VALUES('delete', old.id, old.name, old.entity_type);
+		INSERT INTO entities_fts(entities_fts, rowid, name, entity_type, description) VALUES('delete', old.id, old.name, old.entity_type, old.description);
 	END;
 
 	CREATE TRIGGER IF NOT EXISTS entities_fts_update AFTER UPDATE ON entities BEGIN
-		INSERT INTO entities_fts(entities_fts, rowid, name, entity_type) VALUES('delete', old.id, old.name, old.entity_type);
-		INSERT INTO entities_fts(rowid, name, entity_type) VALUES (new.id, new.name, new.entity_type);
+		INSERT INTO entities_fts(entities_fts, rowid, name, entity_type, description) VALUES('delete', old.id, old.name, old.entity_type, old.description);
+		INSERT INTO entities_fts(rowid, name, entity_type, description) VALUES (new.id, new.name, new.entity_type, new.description);
 	END;
 
 	CREATE TRIGGER IF NOT EXISTS observations_fts_insert AFTER INSERT ON observations BEGIN
@@ -74,44 +78,46 @@ func (s *SQLiteStorage) createFTSSchema() error {
 	return nil
 }
 
-// rebuildFTSIndex rebuilds the FTS index
+// rebuildFTSIndex rebuilds the FTS index from the content tables.
+// Both FTS tables use external content, so "SELECT rowid FROM *_fts" reads the
+// content table rather than the index and cannot be used to find missing rows.
 func (s *SQLiteStorage) rebuildFTSIndex() error {
-	// First populate entities FTS manually
-	_, err := s.db.Exec(`
-		INSERT INTO entities_fts(rowid, name, entity_type)
-		SELECT id, name, entity_type FROM entities
-		WHERE id NOT IN (SELECT rowid FROM entities_fts)
-	`)
+	if _, err := s.db.Exec("INSERT INTO entities_fts(entities_fts) VALUES('rebuild')"); err != nil {
+		return fmt.Errorf("failed to rebuild entities FTS: %w", err)
+	}
+
+	// observations_fts carries entity_name, which the observations table lacks,
+	// so it is cleared and repopulated instead of using 'rebuild'
+	tx, err := s.db.Begin()
 	if err != nil {
-		// Try rebuild if manual insert fails
-		_, err = s.db.Exec("INSERT INTO entities_fts(entities_fts) VALUES('rebuild')")
-		if err != nil {
-			return fmt.Errorf("failed to rebuild entities FTS: %w", err)
-		}
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	// Populate observations FTS manually
-	_, err = s.db.Exec(`
+	if _, err := tx.Exec("INSERT INTO observations_fts(observations_fts) VALUES('delete-all')"); err != nil {
+		return fmt.Errorf("failed to clear observations FTS: %w", err)
+	}
+	_, err = tx.Exec(`
 		INSERT INTO observations_fts(rowid, content, entity_name)
-		SELECT o.id, o.content, e.name 
+		SELECT o.id, o.content, e.name
 		FROM observations o
 		JOIN entities e ON o.entity_id = e.id
-		WHERE o.id NOT IN (SELECT rowid FROM observations_fts)
 	`)
 	if err != nil {
-		// Try rebuild if manual insert fails
-		_, err = s.db.Exec("INSERT INTO observations_fts(observations_fts) VALUES('rebuild')")
-		if err != nil {
-			return fmt.Errorf("failed to rebuild observations FTS: %w", err)
-		}
+		return fmt.Errorf("failed to rebuild observations FTS: %w", err)
 	}
 
-	return nil
+	return tx.Commit()
 }
 
 // SearchNodesWithFTS searches using FTS5 and returns search hits with snippets
 // Results are sorted by match location priority: name/type matches before content matches
 func (s *SQLiteStorage) SearchNodesWithFTS(query string, limit int) (*SearchResult, error) {
+	return s.searchNodesFTS(query, limit, 0)
+}
+
+// searchNodesFTS is SearchNodesWithFTS with at most maxSnippets snippets per hit
+func (s *SQLiteStorage) searchNodesFTS(query string, limit, maxSnippets int) (*SearchResult, error) {
 	result := &SearchResult{
 		Entities: []EntitySearchHit{},
 		Limit:    limit,
@@ -122,11 +128,16 @@ func (s *SQLiteStorage) SearchNodesWithFTS(query string, limit int) (*SearchResu
 	}
 
 	// Expand query with synonyms
-	words := strings.Fields(query)
+	words := searchTerms(query)
 	expandedWords := s.expandQueryWithSynonyms(words)
 
-	// Prepare FTS query using expanded words
-	ftsQuery := prepareFTSQuery(strings.Join(expandedWords, " "))
+	// Prepare FTS query using expanded words; a query of punctuation alone has
+	// nothing FTS can match, so it is left to the basic search
+	terms := ftsTerms(expandedWords)
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("FTS query %q has no searchable words", query)
+	}
+	ftsQuery := strings.Join(terms, " OR ")
 
 	// Use a map to track unique entities (by ID to avoid duplicates)
 	// Track match source: entity FTS (name/type) has higher priority than observation FTS
@@ -134,23 +145,24 @@ func (s *SQLiteStorage) SearchNodesWithFTS(query string, limit int) (*SearchResu
 		ID            int64
 		Name          string
 		EntityType    string
+		Description   string
 		Rank          float64
-		MatchedInName bool // true if matched in entities_fts (name/type)
+		MatchedInName bool // true if matched in entities_fts (name/type/description)
 	}
 	entityMap := make(map[int64]*entityInfo)
 	var nameMatchIDs []int64    // IDs matched in name/type (higher priority)
 	var contentMatchIDs []int64 // IDs matched only in observations (lower priority)
 
-	// Search entities using FTS (matches in name or entity_type)
+	// Search entities using FTS (matches in name, entity_type or description)
 	entityQuery := `
-		SELECT DISTINCT e.id, e.name, e.entity_type, bm25(ef) as rank
-		FROM entities_fts ef
-		JOIN entities e ON ef.rowid = e.id
-		WHERE entities_fts MATCH ?
-		ORDER BY rank
+		SELECT e.id, e.name, e.entity_type, e.description, bm25(entities_fts) as rank
+		FROM entities_fts
+		JOIN entities e ON entities_fts.rowid = e.id
+		WHERE entities_fts MATCH ? AND e.namespace = ?
+		ORDER BY rank, e.id
 	`
 
-	entityRows, err := s.rdb().Query(entityQuery, ftsQuery)
+	entityRows, err := s.rdb().Query(entityQuery, ftsQuery, s.ns())
 	if err != nil {
 		// Return error to allow fallback to basic search
 		return nil, fmt.Errorf("FTS entity search failed: %w", err)
@@ -159,10 +171,10 @@ func (s *SQLiteStorage) SearchNodesWithFTS(query string, limit int) (*SearchResu
 
 	for entityRows.Next() {
 		var id int64
-		var name, entityType string
+		var name, entityType, description string
 		var rank float64
 
-		if err := entityRows.Scan(&id, &name, &entityType, &rank); err != nil {
+		if err := entityRows.Scan(&id, &name, &entityType, &description, &rank); err != nil {
 			continue
 		}
 
@@ -171,6 +183,7 @@ func (s *SQLiteStorage) SearchNodesWithFTS(query string, limit int) (*SearchResu
 				ID:            id,
 				Name:          name,
 				EntityType:    entityType,
+				Description:   description,
 				Rank:          rank,
 				MatchedInName: true, // Matched in entities_fts
 			}
@@ -180,24 +193,24 @@ func (s *SQLiteStorage) SearchNodesWithFTS(query string, limit int) (*SearchResu
 
 	// Search observations using FTS (matches in observation content)
 	obsQuery := `
-		SELECT DISTINCT e.id, e.name, e.entity_type, bm25(of) as rank
-		FROM observations_fts of
-		JOIN observations o ON of.rowid = o.id
+		SELECT e.id, e.name, e.entity_type, e.description, bm25(observations_fts) as rank
+		FROM observations_fts
+		JOIN observations o ON observations_fts.rowid = o.id
 		JOIN entities e ON o.entity_id = e.id
-		WHERE observations_fts MATCH ?
-		ORDER BY rank
+		WHERE observations_fts MATCH ? AND e.namespace = ?
+		ORDER BY rank, e.id
 	`
 
-	obsRows, err := s.rdb().Query(obsQuery, ftsQuery)
+	obsRows, err := s.rdb().Query(obsQuery, ftsQuery, s.ns())
 	if err == nil {
 		defer obsRows.Close()
 
 		for obsRows.Next() {
 			var id int64
-			var name, entityType string
+			var name, entityType, description string
 			var rank float64
 
-			if err := obsRows.Scan(&id, &name, &entityType, &rank); err != nil {
+			if err := obsRows.Scan(&id, &name, &entityType, &description, &rank); err != nil {
 				continue
 			}
 
@@ -207,6 +220,7 @@ func (s *SQLiteStorage) SearchNodesWithFTS(query string, limit int) (*SearchResu
 					ID:            id,
 					Name:          name,
 					EntityType:    entityType,
+					Description:   description,
 					Rank:          rank,
 					MatchedInName: false, // Only matched in observations
 				}
@@ -217,6 +231,9 @@ func (s *SQLiteStorage) SearchNodesWithFTS(query string, limit int) (*SearchResu
 
 	// Calculate total
 	result.Total = len(entityMap)
+	if tooMany := s.config.tooManyMatches(result.Total, limit); tooMany != nil {
+		return tooMany, nil
+	}
 
 	// Reorder within each group by recency (recently accessed entities first)
 	nameMatchIDs = s.reorderByRecency(nameMatchIDs)
@@ -226,6 +243,12 @@ func (s *SQLiteStorage) SearchNodesWithFTS(query string, limit int) (*SearchResu
 	// This ensures entities matched by name/type appear before those matched only by content
 	orderedIDs := append(nameMatchIDs, contentMatchIDs...)
 
+	// Entities matching more of the query terms come first
+	if len(terms) > 1 {
+		matched := s.countMatchedTerms(terms)
+		slices.SortStableFunc(orderedIDs, func(a, b int64) int { return matched[b] - matched[a] })
+	}
+
 	// Apply limit to ordered IDs (only if limit > 0)
 	limitedIDs := orderedIDs
 	if limit > 0 && len(limitedIDs) > limit {
@@ -285,17 +308,15 @@ func (s *SQLiteStorage) SearchNodesWithFTS(query string, limit int) (*SearchResu
 		}
 
 		// Build result with snippets
-		// maxSnippets=0 means return all matched snippets when limit=0
-		maxSnippets := 2
-		if limit == 0 {
-			maxSnippets = 0 // unlimited snippets
-		}
+		// maxSnippets=0 means return all matched snippets
+		maxSnippets = snippetCap(limit, maxSnippets)
 		for _, id := range limitedIDs {
 			info := entityMap[id]
 			hit := EntitySearchHit{
 				Name:              info.Name,
 				EntityType:        info.EntityType,
-				Snippets:          s.getMatchedSnippets(id, words, maxSnippets, 50), // 50 chars context
+				Description:       info.Description,
+				Snippets:          s.getRankedSnippets(id, ftsQuery, words, maxSnippets, 50), // 50 chars context
 				ObservationsCount: obsCountMap[id],
 				RelationsCount:    relCountMap[id],
 			}
@@ -327,29 +348,61 @@ func (s *SQLiteStorage) SearchNodesWithFTS(query string, limit int) (*SearchResu
 }
 
 // prepareFTSQuery prepares a query string for FTS5
-// Multiple space-separated words are treated as OR search with prefix matching
-func prepareFTSQuery(query string) string {
-	// Escape special FTS characters
-	query = strings.ReplaceAll(query, `"`, `""`)
-
-	// Split into words
-	words := strings.Fields(query)
-	if len(words) == 0 {
-		return `""`
-	}
-
-	if len(words) == 1 {
-		// Single word - use prefix matching
-		return fmt.Sprintf(`%s*`, words[0])
-	}
-
+// Multiple space-separated words are treated as OR search with prefix matching,
+// and a "double-quoted phrase" matches only those words in that order.
+// Each term is quoted as an FTS5 string, so operators (AND, NOT, NEAR), column
+// filters, and punctuation in user input are matched as text rather than parsed.
+// Terms without a letter or digit tokenize to nothing and are dropped; ok is
+// false when no term is left.
+func prepareFTSQuery(query string) (ftsQuery string, ok bool) {
 	// Multiple words - use OR with prefix matching for each word
 	// This allows "十里 田野 开发者" to find entities matching ANY of these keywords
+	parts := ftsTerms(searchTerms(query))
+	return strings.Join(parts, " OR "), len(parts) > 0
+}
+
+// ftsTerms quotes search terms as FTS5 strings: a word as a prefix match and a
+// phrase as an exact one. Terms without a letter or digit are dropped.
+func ftsTerms(terms []string) []string {
 	var parts []string
-	for _, word := range words {
-		parts = append(parts, fmt.Sprintf(`%s*`, word))
+	for _, term := range terms {
+		if !strings.ContainsFunc(term, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) {
+			continue
+		}
+		part := `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+		if !strings.Contains(term, " ") {
+			part += "*"
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// countMatchedTerms returns how many of the FTS terms each entity matches in
+// its name, type, or observations
+func (s *SQLiteStorage) countMatchedTerms(terms []string) map[int64]int {
+	counts := make(map[int64]int)
+	for _, term := range terms {
+		rows, err := s.rdb().Query(`
+			SELECT rowid FROM entities_fts WHERE entities_fts MATCH ?1
+			UNION
+			SELECT o.entity_id
+			FROM observations_fts
+			JOIN observations o ON observations_fts.rowid = o.id
+			WHERE observations_fts MATCH ?1
+		`, term)
+		if err != nil {
+			continue
+		}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err == nil {
+				counts[id]++
+			}
+		}
+		rows.Close()
 	}
-	return strings.Join(parts, " OR ")
+	return counts
 }
 
 // expandQueryWithSynonyms expands query words using the synonyms table.
@@ -399,7 +452,7 @@ func (s *SQLiteStorage) findEntitiesBySubstring(words []string, excludeIDs map[i
 	}
 
 	var whereClauses []string
-	var args []interface{}
+	args := []interface{}{s.ns()}
 	for _, word := range words {
 		whereClauses = append(whereClauses, "LOWER(name) LIKE ?")
 		args = append(args, "%"+strings.ToLower(word)+"%")
@@ -407,7 +460,7 @@ func (s *SQLiteStorage) findEntitiesBySubstring(words []string, excludeIDs map[i
 
 	query := fmt.Sprintf(`
 		SELECT id FROM entities
-		WHERE %s
+		WHERE namespace = ? AND (%s)
 		ORDER BY length(name)
 		LIMIT ?
 	`, strings.Join(whereClauses, " OR "))
@@ -441,12 +494,12 @@ func (s *SQLiteStorage) GetSearchSuggestions(partial string, limit int) ([]strin
 	query := `
 		SELECT DISTINCT name
 		FROM entities
-		WHERE name LIKE ?
+		WHERE namespace = ? AND name LIKE ?
 		ORDER BY name
 		LIMIT ?
 	`
 
-	rows, err := s.rdb().Query(query, partial+"%", limit/2)
+	rows, err := s.rdb().Query(query, s.ns(), partial+"%", limit/2)
 	if err != nil {
 		return suggestions, err
 	}
@@ -463,12 +516,12 @@ func (s *SQLiteStorage) GetSearchSuggestions(partial string, limit int) ([]strin
 	query = `
 		SELECT DISTINCT entity_type
 		FROM entities
-		WHERE entity_type LIKE ?
+		WHERE namespace = ? AND entity_type LIKE ?
 		ORDER BY entity_type
 		LIMIT ?
 	`
 
-	rows, err = s.rdb().Query(query, partial+"%", limit-len(suggestions))
+	rows, err = s.rdb().Query(query, s.ns(), partial+"%", limit-len(suggestions))
 	if err != nil {
 		return suggestions, err
 	}
@@ -491,17 +544,18 @@ func (s *SQLiteStorage) AnalyzeGraph() (map[string]interface{}, error) {
 	// Total counts
 	var entityCount, relationCount, observationCount int
 
-	err := s.rdb().QueryRow("SELECT COUNT(*) FROM entities").Scan(&entityCount)
+	const inNamespace = "(SELECT id FROM entities WHERE namespace = ?)"
+	err := s.rdb().QueryRow("SELECT COUNT(*) FROM entities WHERE namespace = ?", s.ns()).Scan(&entityCount)
 	if err != nil {
 		return nil, err
 	}
 
-	err = s.rdb().QueryRow("SELECT COUNT(*) FROM relations").Scan(&relationCount)
+	err = s.rdb().QueryRow("SELECT COUNT(*) FROM relations WHERE from_entity_id IN "+inNamespace, s.ns()).Scan(&relationCount)
 	if err != nil {
 		return nil, err
 	}
 
-	err = s.rdb().QueryRow("SELECT COUNT(*) FROM observations").Scan(&observationCount)
+	err = s.rdb().QueryRow("SELECT COUNT(*) FROM observations WHERE entity_id IN "+inNamespace, s.ns()).Scan(&observationCount)
 	if err != nil {
 		return nil, err
 	}
@@ -512,7 +566,7 @@ func (s *SQLiteStorage) AnalyzeGraph() (map[string]interface{}, error) {
 
 	// Entity type distribution
 	entityTypes := make(map[string]int)
-	rows, err := s.rdb().Query("SELECT entity_type, COUNT(*) FROM entities GROUP BY entity_type ORDER BY COUNT(*) DESC")
+	rows, err := s.rdb().Query("SELECT entity_type, COUNT(*) FROM entities WHERE namespace = ? GROUP BY entity_type ORDER BY COUNT(*) DESC", s.ns())
 	if err == nil {
 		defer rows.Close()
 		for rows.Next() {
@@ -527,7 +581,7 @@ func (s *SQLiteStorage) AnalyzeGraph() (map[string]interface{}, error) {
 
 	// Relation type distribution
 	relationTypes := make(map[string]int)
-	rows, err = s.rdb().Query("SELECT relation_type, COUNT(*) FROM relations GROUP BY relation_type ORDER BY COUNT(*) DESC")
+	rows, err = s.rdb().Query("SELECT relation_type, COUNT(*) FROM relations WHERE from_entity_id IN "+inNamespace+" GROUP BY relation_type ORDER BY COUNT(*) DESC", s.ns())
 	if err == nil {
 		defer rows.Close()
 		for rows.Next() {
@@ -548,11 +602,12 @@ func (s *SQLiteStorage) AnalyzeGraph() (map[string]interface{}, error) {
 		FROM entities e
 		LEFT JOIN relations r1 ON e.id = r1.from_entity_id
 		LEFT JOIN relations r2 ON e.id = r2.to_entity_id
+		WHERE e.namespace = ?
 		GROUP BY e.id, e.name, e.entity_type
 		HAVING connection_count > 0
 		ORDER BY connection_count DESC
 		LIMIT 10
-	`)
+	`, s.ns())
 	if err == nil {
 		defer rows.Close()
 		for rows.Next() {
@@ -574,6 +629,7 @@ func (s *SQLiteStorage) AnalyzeGraph() (map[string]interface{}, error) {
 
 // reorderByRecency reorders entity IDs by last access time (most recent first).
 // Entities that have never been accessed fall back to updated_at/created_at.
+// Ties keep their incoming (rank) order so results are deterministic.
 func (s *SQLiteStorage) reorderByRecency(ids []int64) []int64 {
 	if len(ids) <= 1 {
 		return ids
@@ -587,12 +643,11 @@ func (s *SQLiteStorage) reorderByRecency(ids []int64) []int64 {
 	}
 
 	query := fmt.Sprintf(`
-		SELECT id FROM entities
-		WHERE id IN (%s)
-		ORDER BY
+		SELECT id,
 			(1.0 / (1.0 + 0.01 * MAX(0, COALESCE(julianday('now') - julianday(COALESCE(last_accessed_at, updated_at, created_at)), 0))))
 			* (1.0 + log(2.0 + COALESCE(access_count, 0)) / log(2.0))
-			DESC
+		FROM entities
+		WHERE id IN (%s)
 	`, strings.Join(placeholders, ","))
 
 	rows, err := s.rdb().Query(query, args...)
@@ -601,16 +656,56 @@ func (s *SQLiteStorage) reorderByRecency(ids []int64) []int64 {
 	}
 	defer rows.Close()
 
-	var reordered []int64
+	scores := make(map[int64]float64, len(ids))
 	for rows.Next() {
 		var id int64
-		if err := rows.Scan(&id); err == nil {
-			reordered = append(reordered, id)
+		var score float64
+		if err := rows.Scan(&id, &score); err == nil {
+			scores[id] = score
 		}
 	}
 
-	if len(reordered) == len(ids) {
-		return reordered
+	if len(scores) != len(ids) {
+		return ids // fallback if something went wrong
+	}
+
+	reordered := slices.Clone(ids)
+	sort.SliceStable(reordered, func(a, b int) bool {
+		return scores[reordered[a]] > scores[reordered[b]]
+	})
+	return reordered
+}
+
+// getRankedSnippets returns context snippets from the entity's observations that
+// match ftsQuery, best bm25 score first. Entities that matched only on name or
+// type fall back to getMatchedSnippets.
+func (s *SQLiteStorage) getRankedSnippets(entityID int64, ftsQuery string, words []string, maxSnippets int, contextChars int) []string {
+	limit := -1 // SQLite: no limit
+	if maxSnippets > 0 {
+		limit = maxSnippets
+	}
+	rows, err := s.rdb().Query(`
+		SELECT o.content
+		FROM observations_fts
+		JOIN observations o ON observations_fts.rowid = o.id
+		WHERE observations_fts MATCH ? AND o.entity_id = ?
+		ORDER BY bm25(observations_fts), o.id
+		LIMIT ?
+	`, ftsQuery, entityID, limit)
+	if err != nil {
+		return s.getMatchedSnippets(entityID, words, maxSnippets, contextChars)
+	}
+	defer rows.Close()
+
+	var snippets []string
+	for rows.Next() {
+		var content string
+		if err := rows.Scan(&content); err == nil {
+			snippets = append(snippets, extractKeywordContext(content, words, contextChars))
+		}
+	}
+	if len(snippets) == 0 {
+		return s.getMatchedSnippets(entityID, words, maxSnippets, contextChars)
 	}
-	return ids // fallback if something went wrong
+	return snippets
 }
@@ -0,0 +1,103 @@
+// Benchmarks for common operations on both backends against a seeded graph of
+// benchEntityCount entities. Compare jsonl and sqlite sub-benchmarks to decide
+// when to migrate. Run with: go test -run '^$' -bench . -benchmem ./storage/
+
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newBenchStorages creates a JSONL and a SQLite storage, each seeded with
+// benchEntities and benchRelations
+func newBenchStorages(b *testing.B) map[string]Storage {
+	b.Helper()
+
+	tempDir, err := os.MkdirTemp("", "storage_bench")
+	if err != nil {
+		b.Fatalf("Failed to create temp dir: %v", err)
+	}
+	b.Cleanup(func() { os.RemoveAll(tempDir) })
+
+	jsonlStorage, err := NewJSONLStorage(Config{FilePath: filepath.Join(tempDir, "bench.jsonl")})
+	if err != nil {
+		b.Fatalf("Failed to create JSONL storage: %v", err)
+	}
+	sqliteStorage, err := NewSQLiteStorage(Config{
+		FilePath:    filepath.Join(tempDir, "bench.db"),
+		WALMode:     true,
+		CacheSize:   10000,
+		BusyTimeout: 5000,
+	})
+	if err != nil {
+		b.Fatalf("Failed to create SQLite storage: %v", err)
+	}
+
+	storages := map[string]Storage{"jsonl": jsonlStorage, "sqlite": sqliteStorage}
+	for name, s := range storages {
+		if err := s.Initialize(); err != nil {
+			b.Fatalf("Failed to initialize %s storage: %v", name, err)
+		}
+		b.Cleanup(func() { s.Close() })
+		if _, err := s.CreateEntities(benchEntities(benchEntityCount)); err != nil {
+			b.Fatalf("Failed to seed %s entities: %v", name, err)
+		}
+		if _, err := s.CreateRelations(benchRelations(benchEntityCount)); err != nil {
+			b.Fatalf("Failed to seed %s relations: %v", name, err)
+		}
+	}
+	return storages
+}
+
+// BenchmarkSearchNodes measures a limited multi-word search, FTS5 on SQLite
+func BenchmarkSearchNodes(b *testing.B) {
+	for name, s := range newBenchStorages(b) {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := s.SearchNodes("distributed pipeline", 10); err != nil {
+					b.Fatalf("SearchNodes failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkReadGraph measures the summary and full reads of the whole graph
+func BenchmarkReadGraph(b *testing.B) {
+	for name, s := range newBenchStorages(b) {
+		for _, mode := range []string{"summary", "full"} {
+			b.Run(name+"/"+mode, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, err := s.ReadGraph(mode, 0, TypeFilter{}); err != nil {
+						b.Fatalf("ReadGraph failed: %v", err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkCreateEntities measures adding a batch of 10 new entities to the
+// seeded graph; JSONL rewrites the whole file for every batch
+func BenchmarkCreateEntities(b *testing.B) {
+	for name, s := range newBenchStorages(b) {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				batch := make([]Entity, 10)
+				for j := range batch {
+					batch[j] = Entity{
+						Name:         fmt.Sprintf("New-%d-%d", i, j),
+						EntityType:   "bench",
+						Observations: []string{fmt.Sprintf("Created in batch %d", i)},
+					}
+				}
+				if _, err := s.CreateEntities(batch); err != nil {
+					b.Fatalf("CreateEntities failed: %v", err)
+				}
+			}
+		})
+	}
+}
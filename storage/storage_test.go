@@ -0,0 +1,4548 @@
+// Tests for behavior shared by both storage backends
+
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestStorages creates an initialized JSONL and SQLite storage in a temp directory.
+// Optional configure funcs are applied to both configs before creation.
+func newTestStorages(t *testing.T, configure ...func(*Config)) map[string]Storage {
+	t.Helper()
+
+	tempDir, err := os.MkdirTemp("", "storage_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tempDir) })
+
+	jsonlConfig := Config{
+		FilePath: filepath.Join(tempDir, "test.jsonl"),
+	}
+	sqliteConfig := Config{
+		FilePath:    filepath.Join(tempDir, "test.db"),
+		WALMode:     true,
+		CacheSize:   1000,
+		BusyTimeout: 5000,
+	}
+	for _, fn := range configure {
+		fn(&jsonlConfig)
+		fn(&sqliteConfig)
+	}
+
+	jsonlStorage, err := NewJSONLStorage(jsonlConfig)
+	if err != nil {
+		t.Fatalf("Failed to create JSONL storage: %v", err)
+	}
+
+	sqliteStorage, err := NewSQLiteStorage(sqliteConfig)
+	if err != nil {
+		t.Fatalf("Failed to create SQLite storage: %v", err)
+	}
+
+	storages := map[string]Storage{
+		"jsonl":  jsonlStorage,
+		"sqlite": sqliteStorage,
+	}
+	for name, s := range storages {
+		if err := s.Initialize(); err != nil {
+			t.Fatalf("Failed to initialize %s storage: %v", name, err)
+		}
+		t.Cleanup(func() { s.Close() })
+	}
+
+	return storages
+}
+
+func TestListEntities(t *testing.T) {
+	for backend, s := range newTestStorages(t) {
+		t.Run(backend, func(t *testing.T) {
+			_, err := s.CreateEntities([]Entity{
+				{Name: "Charlie", EntityType: "person", Observations: []string{"c1"}},
+				{Name: "Alice", EntityType: "person", Observations: []string{"a1", "a2"}},
+				{Name: "Go", EntityType: "language"},
+				{Name: "Bob", EntityType: "person"},
+			})
+			if err != nil {
+				t.Fatalf("Failed to create entities: %v", err)
+			}
+
+			tests := []struct {
+				name    string
+				opts    ListOptions
+				want    []string
+				total   int
+				hasMore bool
+			}{
+				{"default sorts by name", ListOptions{}, []string{"Alice", "Bob", "Charlie", "Go"}, 4, false},
+				{"type filter", ListOptions{EntityType: "person"}, []string{"Alice", "Bob", "Charlie"}, 3, false},
+				{"desc with paging", ListOptions{Order: "desc", Limit: 2, Offset: 1}, []string{"Charlie", "Bob"}, 4, true},
+				{"created order", ListOptions{Sort: "created"}, []string{"Charlie", "Alice", "Go", "Bob"}, 4, false},
+				{"offset past end", ListOptions{Offset: 10}, []string{}, 4, false},
+			}
+
+			for _, tt := range tests {
+				result, err := s.ListEntities(tt.opts)
+				if err != nil {
+					t.Fatalf("%s: ListEntities failed: %v", tt.name, err)
+				}
+				got := []string{}
+				for _, e := range result.Entities {
+					got = append(got, e.Name)
+				}
+				if len(got) != len(tt.want) {
+					t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+					continue
+				}
+				for i := range got {
+					if got[i] != tt.want[i] {
+						t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+						break
+					}
+				}
+				if result.Total != tt.total || result.HasMore != tt.hasMore {
+					t.Errorf("%s: expected total=%d hasMore=%v, got total=%d hasMore=%v",
+						tt.name, tt.total, tt.hasMore, result.Total, result.HasMore)
+				}
+			}
+
+			result, err := s.ListEntities(ListOptions{EntityType: "person", Limit: 1, IncludeObservations: true})
+			if err != nil {
+				t.Fatalf("ListEntities failed: %v", err)
+			}
+			if len(result.Entities) != 1 || len(result.Entities[0].Observations) != 2 {
+				t.Errorf("Expected Alice with 2 observations, got %+v", result.Entities)
+			}
+
+			if _, err := s.ListEntities(ListOptions{Sort: "name; DROP TABLE entities"}); err == nil {
+				t.Error("Expected error for invalid sort field")
+			}
+		})
+	}
+}
+
+func TestFindByObservation(t *testing.T) {
+	for backend, s := range newTestStorages(t) {
+		t.Run(backend, func(t *testing.T) {
+			_, err := s.CreateEntities([]Entity{
+				{Name: "Alice", EntityType: "person", Observations: []string{"Lives in Berlin", "Likes tea"}},
+				{Name: "Bob", EntityType: "person", Observations: []string{"Lives in Berlin"}},
+				{Name: "Carol", EntityType: "person", Observations: []string{"Lives in Berlin too"}},
+			})
+			if err != nil {
+				t.Fatalf("Failed to create entities: %v", err)
+			}
+
+			entities, err := s.FindByObservation("Lives in Berlin")
+			if err != nil {
+				t.Fatalf("FindByObservation failed: %v", err)
+			}
+			if len(entities) != 2 || entities[0].Name != "Alice" || entities[1].Name != "Bob" {
+				t.Fatalf("Expected [Alice Bob], got %+v", entities)
+			}
+			if len(entities[0].Observations) != 2 {
+				t.Errorf("Expected Alice's 2 observations, got %v", entities[0].Observations)
+			}
+
+			entities, err = s.FindByObservation("lives in berlin")
+			if err != nil {
+				t.Fatalf("FindByObservation failed: %v", err)
+			}
+			if len(entities) != 0 {
+				t.Errorf("Expected no case-insensitive matches, got %+v", entities)
+			}
+		})
+	}
+}
+
+func TestObservationCap(t *testing.T) {
+	storages := newTestStorages(t, func(c *Config) { c.MaxObservationsPerEntity = 3 })
+	for backend, s := range storages {
+		t.Run(backend, func(t *testing.T) {
+			_, err := s.CreateEntities([]Entity{
+				{Name: "Big", EntityType: "test", Observations: []string{"o1", "o2", "o3", "o4", "o5"}},
+				{Name: "Small", EntityType: "test", Observations: []string{"s1"}},
+			})
+			if err != nil {
+				t.Fatalf("Failed to create entities: %v", err)
+			}
+
+			graph, err := s.OpenNodes([]string{"Big", "Small"})
+			if err != nil {
+				t.Fatalf("OpenNodes failed: %v", err)
+			}
+			if !graph.Truncated {
+				t.Error("Expected OpenNodes result to be marked truncated")
+			}
+			for _, e := range graph.Entities {
+				switch e.Name {
+				case "Big":
+					if len(e.Observations) != 3 || e.Observations[0] != "o1" || e.ObservationsTotal != 5 {
+						t.Errorf("Expected Big capped to [o1 o2 o3] of 5, got %v of %d", e.Observations, e.ObservationsTotal)
+					}
+				case "Small":
+					if e.ObservationsTotal != 0 {
+						t.Errorf("Expected Small uncapped, got observationsTotal %d", e.ObservationsTotal)
+					}
+				}
+			}
+
+			full, err := s.ReadGraph("full", 0, TypeFilter{})
+			if err != nil {
+				t.Fatalf("ReadGraph failed: %v", err)
+			}
+			if kg := full.(*KnowledgeGraph); !kg.Truncated {
+				t.Error("Expected full read_graph to be marked truncated")
+			}
+
+			exported, err := s.ExportData()
+			if err != nil {
+				t.Fatalf("ExportData failed: %v", err)
+			}
+			for _, e := range exported.Entities {
+				if e.Name == "Big" && len(e.Observations) != 5 {
+					t.Errorf("Expected export to keep all 5 observations, got %v", e.Observations)
+				}
+			}
+
+			page, err := s.GetObservations("Big", 3, 10)
+			if err != nil {
+				t.Fatalf("GetObservations failed: %v", err)
+			}
+			if page.Total != 5 || page.HasMore || len(page.Observations) != 2 || page.Observations[0] != "o4" {
+				t.Errorf("Expected [o4 o5] of 5 with no more, got %+v", page)
+			}
+		})
+	}
+}
+
+func TestLargeEntityObservations(t *testing.T) {
+	// Far beyond any concatenation limit: 2,000 observations of ~500 bytes each
+	observations := make([]string, 2000)
+	for i := range observations {
+		observations[i] = fmt.Sprintf("%04d %s", i, strings.Repeat("x", 500))
+	}
+
+	for backend, s := range newTestStorages(t, func(c *Config) { c.MaxObservationsPerEntity = -1 }) {
+		t.Run(backend, func(t *testing.T) {
+			if _, err := s.CreateEntities([]Entity{{Name: "Huge", EntityType: "test", Observations: observations}}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+
+			graph, err := s.OpenNodes([]string{"Huge"})
+			if err != nil {
+				t.Fatalf("OpenNodes failed: %v", err)
+			}
+			if len(graph.Entities) != 1 || !slices.Equal(graph.Entities[0].Observations, observations) {
+				t.Errorf("Expected OpenNodes to return all %d observations intact", len(observations))
+			}
+
+			full, err := s.ReadGraph("full", 0, TypeFilter{})
+			if err != nil {
+				t.Fatalf("ReadGraph failed: %v", err)
+			}
+			if kg := full.(*KnowledgeGraph); len(kg.Entities) != 1 || !slices.Equal(kg.Entities[0].Observations, observations) || kg.Truncated {
+				t.Errorf("Expected ReadGraph to return all %d observations intact", len(observations))
+			}
+		})
+	}
+}
+
+func TestMaxObservationsInRead(t *testing.T) {
+	for backend, s := range newTestStorages(t, func(c *Config) { c.MaxObservationsInRead = 2 }) {
+		t.Run(backend, func(t *testing.T) {
+			if _, err := s.CreateEntities([]Entity{
+				{Name: "Big", EntityType: "test", Observations: []string{"red one", "red two", "red three", "red four", "red five"}},
+				{Name: "Small", EntityType: "test", Observations: []string{"red s1"}},
+			}); err != nil {
+				t.Fatalf("Failed to create entities: %v", err)
+			}
+
+			full, err := s.ReadGraph("full", 0, TypeFilter{})
+			if err != nil {
+				t.Fatalf("ReadGraph failed: %v", err)
+			}
+			for _, e := range full.(*KnowledgeGraph).Entities {
+				switch e.Name {
+				case "Big":
+					if want := []string{"red one", "red two", "... (3 more)"}; !slices.Equal(e.Observations, want) || e.ObservationsTotal != 5 {
+						t.Errorf("Expected %v of 5, got %v of %d", want, e.Observations, e.ObservationsTotal)
+					}
+				case "Small":
+					if !slices.Equal(e.Observations, []string{"red s1"}) {
+						t.Errorf("Expected Small untouched, got %v", e.Observations)
+					}
+				}
+			}
+
+			result, err := s.SearchNodes("red", 0)
+			if err != nil {
+				t.Fatalf("SearchNodes failed: %v", err)
+			}
+			for _, hit := range result.Entities {
+				if hit.Name == "Big" && (len(hit.Snippets) != 2 || hit.ObservationsCount != 5) {
+					t.Errorf("Expected 2 snippets of 5 observations, got %+v", hit)
+				}
+			}
+
+			graph, err := s.OpenNodes([]string{"Big"})
+			if err != nil {
+				t.Fatalf("OpenNodes failed: %v", err)
+			}
+			if len(graph.Entities) != 1 || len(graph.Entities[0].Observations) != 5 {
+				t.Errorf("Expected open_nodes to return all 5 observations, got %+v", graph.Entities)
+			}
+		})
+	}
+}
+
+func TestGetObservations(t *testing.T) {
+	for backend, s := range newTestStorages(t) {
+		t.Run(backend, func(t *testing.T) {
+			_, err := s.CreateEntities([]Entity{
+				{Name: "Notes", EntityType: "test", Observations: []string{"n1", "n2", "n3", "n4", "n5"}},
+			})
+			if err != nil {
+				t.Fatalf("Failed to create entities: %v", err)
+			}
+
+			tests := []struct {
+				name          string
+				offset, limit int
+				want          []string
+				hasMore       bool
+			}{
+				{"first page", 0, 2, []string{"n1", "n2"}, true},
+				{"middle page", 2, 2, []string{"n3", "n4"}, true},
+				{"last page", 4, 2, []string{"n5"}, false},
+				{"no limit", 1, 0, []string{"n2", "n3", "n4", "n5"}, false},
+				{"offset past end", 10, 2, []string{}, false},
+			}
+
+			for _, tt := range tests {
+				page, err := s.GetObservations("Notes", tt.offset, tt.limit)
+				if err != nil {
+					t.Fatalf("%s: GetObservations failed: %v", tt.name, err)
+				}
+				if page.Total != 5 || page.HasMore != tt.hasMore || !slices.Equal(page.Observations, tt.want) {
+					t.Errorf("%s: expected %v (hasMore=%v) of 5, got %+v", tt.name, tt.want, tt.hasMore, page)
+				}
+			}
+
+			if _, err := s.GetObservations("Missing", 0, 10); err == nil {
+				t.Error("Expected error for missing entity")
+			}
+		})
+	}
+}
+
+func TestClear(t *testing.T) {
+	for backend, s := range newTestStorages(t) {
+		t.Run(backend, func(t *testing.T) {
+			_, err := s.CreateEntities([]Entity{
+				{Name: "A", EntityType: "test", Observations: []string{"a1", "a2"}},
+				{Name: "B", EntityType: "test", Observations: []string{"b1"}},
+			})
+			if err != nil {
+				t.Fatalf("Failed to create entities: %v", err)
+			}
+			if _, err := s.CreateRelations([]Relation{{From: "A", To: "B", RelationType: "knows"}}); err != nil {
+				t.Fatalf("Failed to create relations: %v", err)
+			}
+
+			result, err := s.Clear()
+			if err != nil {
+				t.Fatalf("Clear failed: %v", err)
+			}
+			if *result != (ClearResult{EntitiesRemoved: 2, ObservationsRemoved: 3, RelationsRemoved: 1}) {
+				t.Errorf("Unexpected clear counts: %+v", result)
+			}
+
+			graph, err := s.ExportData()
+			if err != nil {
+				t.Fatalf("ExportData failed: %v", err)
+			}
+			if len(graph.Entities) != 0 || len(graph.Relations) != 0 {
+				t.Errorf("Expected empty graph after clear, got %+v", graph)
+			}
+
+			// The graph must remain usable, including search
+			if _, err := s.CreateEntities([]Entity{{Name: "A", EntityType: "test", Observations: []string{"fresh start"}}}); err != nil {
+				t.Fatalf("Failed to recreate entity: %v", err)
+			}
+			found, err := s.SearchNodes("fresh", 10)
+			if err != nil {
+				t.Fatalf("SearchNodes failed: %v", err)
+			}
+			if found.Total != 1 {
+				t.Errorf("Expected 1 search hit after clear, got %d", found.Total)
+			}
+		})
+	}
+}
+
+func TestSentinelErrors(t *testing.T) {
+	for backend, s := range newTestStorages(t) {
+		t.Run(backend, func(t *testing.T) {
+			_, err := s.CreateEntities([]Entity{
+				{Name: "A", EntityType: "test", Observations: []string{"one", "two"}},
+			})
+			if err != nil {
+				t.Fatalf("Failed to create entities: %v", err)
+			}
+
+			_, selfMergeErr := s.MergeEntities("A", "A")
+			_, missingMergeErr := s.MergeEntities("Missing", "A")
+
+			tests := []struct {
+				name string
+				err  error
+				want error
+			}{
+				{"update missing entity", s.UpdateEntityType("Missing", "x"), ErrEntityNotFound},
+				{"update missing observation", s.UpdateObservation("A", "three", "four"), ErrObservationNotFound},
+				{"update observation on missing entity", s.UpdateObservation("Missing", "one", "uno"), ErrEntityNotFound},
+				{"update to duplicate observation", s.UpdateObservation("A", "one", "two"), ErrConflict},
+				{"self merge", selfMergeErr, ErrInvalidArgument},
+				{"merge missing source", missingMergeErr, ErrEntityNotFound},
+			}
+
+			for _, tt := range tests {
+				if !errors.Is(tt.err, tt.want) {
+					t.Errorf("%s: expected %v, got %v", tt.name, tt.want, tt.err)
+				}
+			}
+		})
+	}
+}
+
+func TestTraverse(t *testing.T) {
+	for backend, s := range newTestStorages(t) {
+		t.Run(backend, func(t *testing.T) {
+			_, err := s.CreateEntities([]Entity{
+				{Name: "Acme", EntityType: "company"},
+				{Name: "Alice", EntityType: "person"},
+				{Name: "Bob", EntityType: "person"},
+				{Name: "Go", EntityType: "language"},
+			})
+			if err != nil {
+				t.Fatalf("Failed to create entities: %v", err)
+			}
+			_, err = s.CreateRelations([]Relation{
+				{From: "Alice", To: "Acme", RelationType: "works_at"},
+				{From: "Bob", To: "Acme", RelationType: "works_at"},
+				{From: "Acme", To: "Go", RelationType: "uses"},
+			})
+			if err != nil {
+				t.Fatalf("Failed to create relations: %v", err)
+			}
+
+			tests := []struct {
+				from, relationType, direction string
+				want                          []string
+			}{
+				{"Acme", "works_at", "in", []string{"Alice", "Bob"}},
+				{"Acme", "works_at", "out", []string{}},
+				{"Acme", "", "both", []string{"Alice", "Bob", "Go"}},
+				{"Alice", "works_at", "", []string{"Acme"}},
+			}
+			for _, tt := range tests {
+				hits, err := s.Traverse(tt.from, tt.relationType, tt.direction)
+				if err != nil {
+					t.Fatalf("Traverse(%s, %s, %s) failed: %v", tt.from, tt.relationType, tt.direction, err)
+				}
+				got := []string{}
+				for _, h := range hits {
+					got = append(got, h.Name)
+				}
+				slices.Sort(got)
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("Traverse(%s, %s, %s): expected %v, got %v", tt.from, tt.relationType, tt.direction, tt.want, got)
+				}
+			}
+
+			if _, err := s.Traverse("Missing", "", "out"); !errors.Is(err, ErrEntityNotFound) {
+				t.Errorf("Expected ErrEntityNotFound, got %v", err)
+			}
+			if _, err := s.Traverse("Acme", "", "sideways"); !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("Expected ErrInvalidArgument, got %v", err)
+			}
+		})
+	}
+}
+
+func TestReplaceInObservations(t *testing.T) {
+	for name, store := range newTestStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.CreateEntities([]Entity{
+				{Name: "A", EntityType: "note", Observations: []string{"uses ML daily", "uses machine learning daily", "ml basics", "html page"}},
+				{Name: "B", EntityType: "note", Observations: []string{"ML"}},
+				{Name: "C", EntityType: "note", Observations: []string{"x", "xx"}},
+			}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			observations := func(name string) []string {
+				graph, err := store.OpenNodes([]string{name})
+				if err != nil || len(graph.Entities) != 1 {
+					t.Fatalf("OpenNodes(%s) failed: %v", name, err)
+				}
+				obs := slices.Clone(graph.Entities[0].Observations)
+				slices.Sort(obs)
+				return obs
+			}
+
+			modified, err := store.ReplaceInObservations("ML", "machine learning", true)
+			if err != nil {
+				t.Fatalf("ReplaceInObservations failed: %v", err)
+			}
+			if modified != 2 {
+				t.Errorf("Expected 2 observations modified, got %d", modified)
+			}
+			if got, want := observations("A"), []string{"html page", "ml basics", "uses machine learning daily"}; !slices.Equal(got, want) {
+				t.Errorf("Expected the duplicate merged and lowercase left alone, got %v", got)
+			}
+			if got := observations("B"); !slices.Equal(got, []string{"machine learning"}) {
+				t.Errorf("Expected B rewritten, got %v", got)
+			}
+			result, err := store.SearchNodes("learning", 10)
+			if err != nil || len(result.Entities) != 2 {
+				t.Errorf("Expected search to find the new text on A and B, got %+v (%v)", result, err)
+			}
+
+			if modified, _ := store.ReplaceInObservations("ml", "ML", false); modified != 2 {
+				t.Errorf("Expected 2 case-insensitive matches, got %d", modified)
+			}
+			if got := observations("A"); !slices.Contains(got, "ML basics") || !slices.Contains(got, "htML page") {
+				t.Errorf("Expected case-insensitive replacement, got %v", got)
+			}
+
+			// "x" -> "xx" collides with "xx" until that one becomes "xxxx"
+			if _, err := store.ReplaceInObservations("x", "xx", true); err != nil {
+				t.Fatalf("ReplaceInObservations failed: %v", err)
+			}
+			if got := observations("C"); !slices.Equal(got, []string{"xx", "xxxx"}) {
+				t.Errorf("Expected both observations rewritten, got %v", got)
+			}
+
+			if _, err := store.ReplaceInObservations("", "y", true); !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("Expected ErrInvalidArgument for empty old text, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSetObservations(t *testing.T) {
+	for backend, s := range newTestStorages(t) {
+		t.Run(backend, func(t *testing.T) {
+			_, err := s.CreateEntities([]Entity{
+				{Name: "A", EntityType: "test", Observations: []string{"old1", "keep", "old2"}},
+			})
+			if err != nil {
+				t.Fatalf("Failed to create entities: %v", err)
+			}
+
+			if err := s.SetObservations("A", []string{"keep", "new", "new"}); err != nil {
+				t.Fatalf("SetObservations failed: %v", err)
+			}
+			page, err := s.GetObservations("A", 0, 0)
+			if err != nil {
+				t.Fatalf("GetObservations failed: %v", err)
+			}
+			if want := []string{"keep", "new"}; !slices.Equal(page.Observations, want) {
+				t.Errorf("Expected %v, got %v", want, page.Observations)
+			}
+
+			// Replaced observations must be searchable, removed ones must not
+			if result, _ := s.SearchNodes("old1", 0); result.Total != 0 {
+				t.Errorf("Expected removed observation to be unsearchable, got %d hits", result.Total)
+			}
+			if result, _ := s.SearchNodes("new", 0); result.Total != 1 {
+				t.Errorf("Expected new observation to be searchable, got %d hits", result.Total)
+			}
+
+			if err := s.SetObservations("Missing", nil); !errors.Is(err, ErrEntityNotFound) {
+				t.Errorf("Expected ErrEntityNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRelationExists(t *testing.T) {
+	for backend, s := range newTestStorages(t) {
+		t.Run(backend, func(t *testing.T) {
+			_, err := s.CreateEntities([]Entity{{Name: "A", EntityType: "test"}, {Name: "B", EntityType: "test"}})
+			if err != nil {
+				t.Fatalf("Failed to create entities: %v", err)
+			}
+			if _, err := s.CreateRelations([]Relation{{From: "A", To: "B", RelationType: "knows"}}); err != nil {
+				t.Fatalf("Failed to create relations: %v", err)
+			}
+
+			tests := []struct {
+				from, to, relationType string
+				want                   bool
+			}{
+				{"A", "B", "knows", true},
+				{"B", "A", "knows", false},
+				{"A", "B", "likes", false},
+				{"A", "Missing", "knows", false},
+			}
+			for _, tt := range tests {
+				got, err := s.RelationExists(tt.from, tt.to, tt.relationType)
+				if err != nil {
+					t.Fatalf("RelationExists failed: %v", err)
+				}
+				if got != tt.want {
+					t.Errorf("RelationExists(%s, %s, %s): expected %v, got %v", tt.from, tt.to, tt.relationType, tt.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadGraphFile(t *testing.T) {
+	tempDir := t.TempDir()
+
+	files := map[string]string{
+		"graph.json": `{
+  "entities": [{"name": "A", "entityType": "test", "observations": ["a1"]}, {"name": "B", "entityType": "test", "observations": []}],
+  "relations": [{"from": "A", "to": "B", "relationType": "knows"}]
+}`,
+		"graph.jsonl": `{"type":"entity","name":"A","entityType":"test","observations":["a1"]}
+{"type":"entity","name":"B","entityType":"test","observations":[]}
+{"type":"relation","from":"A","to":"B","relationType":"knows"}
+`,
+	}
+
+	for name, content := range files {
+		path := filepath.Join(tempDir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+
+		graph, err := LoadGraphFile(path)
+		if err != nil {
+			t.Fatalf("LoadGraphFile(%s) failed: %v", name, err)
+		}
+		if len(graph.Entities) != 2 || len(graph.Relations) != 1 || graph.Entities[0].Observations[0] != "a1" {
+			t.Errorf("%s: unexpected graph %+v", name, graph)
+		}
+	}
+}
+
+func TestLoadSnakeCaseJSONL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.jsonl")
+	content := `{"type":"entity","name":"A","entity_type":"person","observations":["a1"],"observation_sources":{"a1":"chat"}}
+{"type":"entity","name":"B","entity_type":"place","observations":[]}
+{"type":"relation","from":"A","to":"B","relation_type":"lives_in","created_at":"2024-01-02T03:04:05Z"}
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	graph, err := LoadGraphFile(path)
+	if err != nil {
+		t.Fatalf("LoadGraphFile failed: %v", err)
+	}
+	if len(graph.Entities) != 2 || graph.Entities[0].EntityType != "person" || graph.Entities[1].EntityType != "place" {
+		t.Errorf("Expected snake_case entity types to load, got %+v", graph.Entities)
+	}
+	if graph.Entities[0].ObservationSources["a1"] != "chat" {
+		t.Errorf("Expected snake_case observation sources to load, got %+v", graph.Entities[0])
+	}
+	if len(graph.Relations) != 1 || graph.Relations[0].RelationType != "lives_in" || graph.Relations[0].CreatedAt == nil {
+		t.Errorf("Expected snake_case relation fields to load, got %+v", graph.Relations)
+	}
+
+	// Serving the file and writing to it rewrites every line in camelCase
+	store, err := NewJSONLStorage(Config{FilePath: path})
+	if err != nil {
+		t.Fatalf("Failed to create JSONL storage: %v", err)
+	}
+	if err := store.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	defer store.Close()
+	if _, err := store.AddObservations(map[string][]string{"B": {"b1"}}, ""); err != nil {
+		t.Fatalf("AddObservations failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	saved := string(data)
+	if strings.Contains(saved, "entity_type") || strings.Contains(saved, "relation_type") {
+		t.Errorf("Expected the file rewritten in camelCase, got:\n%s", saved)
+	}
+	if !strings.Contains(saved, `"entityType":"person"`) || !strings.Contains(saved, `"relationType":"lives_in"`) {
+		t.Errorf("Expected the types kept in camelCase fields, got:\n%s", saved)
+	}
+}
+
+func TestImportDataMerges(t *testing.T) {
+	for backend, s := range newTestStorages(t) {
+		t.Run(backend, func(t *testing.T) {
+			_, err := s.CreateEntities([]Entity{
+				{Name: "A", EntityType: "old", Observations: []string{"a1"}},
+				{Name: "Keep", EntityType: "test"},
+			})
+			if err != nil {
+				t.Fatalf("Failed to create entities: %v", err)
+			}
+
+			_, err = s.ImportData(&KnowledgeGraph{
+				Entities: []Entity{
+					{Name: "A", EntityType: "new", Observations: []string{"a1", "a2"}},
+					{Name: "B", EntityType: "test"},
+				},
+				Relations: []Relation{
+					{From: "A", To: "B", RelationType: "knows"},
+					{From: "A", To: "Ghost", RelationType: "knows"},
+				},
+			}, "")
+			if err != nil {
+				t.Fatalf("ImportData failed: %v", err)
+			}
+
+			graph, err := s.ExportData()
+			if err != nil {
+				t.Fatalf("ExportData failed: %v", err)
+			}
+			entities := map[string]Entity{}
+			for _, e := range graph.Entities {
+				entities[e.Name] = e
+			}
+			if len(entities) != 3 {
+				t.Errorf("Expected existing entities to be kept alongside imported ones, got %+v", graph.Entities)
+			}
+			if a := entities["A"]; a.EntityType != "new" || !slices.Equal(a.Observations, []string{"a1", "a2"}) {
+				t.Errorf("Expected A upserted to type new with [a1 a2], got %+v", a)
+			}
+			if len(graph.Relations) != 1 {
+				t.Errorf("Expected only the relation with existing endpoints, got %+v", graph.Relations)
+			}
+		})
+	}
+}
+
+func TestImportDataConflictModes(t *testing.T) {
+	tests := []struct {
+		mode         string
+		wantType     string
+		wantObs      []string
+		wantUpdated  int
+		wantSkipped  int
+		wantCreated  int
+		wantRelation bool
+	}{
+		{ConflictModeOverwrite, "new", []string{"a1", "a2"}, 1, 0, 1, true},
+		{ConflictModeSkip, "old", []string{"a1"}, 0, 1, 1, true},
+		{ConflictModeReport, "old", []string{"a1"}, 1, 0, 1, false},
+	}
+
+	for _, tt := range tests {
+		for backend, s := range newTestStorages(t) {
+			t.Run(tt.mode+"/"+backend, func(t *testing.T) {
+				if _, err := s.CreateEntities([]Entity{
+					{Name: "A", EntityType: "old", Observations: []string{"a1"}},
+					{Name: "C", EntityType: "test"},
+				}); err != nil {
+					t.Fatalf("Failed to create entities: %v", err)
+				}
+				if _, err := s.CreateRelations([]Relation{{From: "A", To: "C", RelationType: "knows"}}); err != nil {
+					t.Fatalf("Failed to create relation: %v", err)
+				}
+
+				report, err := s.ImportData(&KnowledgeGraph{
+					Entities: []Entity{
+						{Name: "A", EntityType: "new", Observations: []string{"a1", "a2"}},
+						{Name: "B", EntityType: "test"},
+					},
+					Relations: []Relation{
+						{From: "A", To: "C", RelationType: "knows"},
+						{From: "A", To: "B", RelationType: "knows"},
+						{From: "A", To: "Ghost", RelationType: "knows"},
+					},
+				}, tt.mode)
+				if err != nil {
+					t.Fatalf("ImportData failed: %v", err)
+				}
+
+				if report.Applied != (tt.mode != ConflictModeReport) {
+					t.Errorf("Applied = %v for mode %s", report.Applied, tt.mode)
+				}
+				if report.EntitiesCreated != tt.wantCreated || report.EntitiesUpdated != tt.wantUpdated ||
+					report.EntitiesSkipped != tt.wantSkipped || report.RelationsCreated != 1 {
+					t.Errorf("Unexpected counts: %+v", report)
+				}
+				if len(report.TypeConflicts) != 1 || report.TypeConflicts[0] != (TypeConflict{"A", "old", "new"}) {
+					t.Errorf("Expected A type conflict old -> new, got %+v", report.TypeConflicts)
+				}
+				if len(report.NewObservations) != 1 || !slices.Equal(report.NewObservations[0].Observations, []string{"a2"}) {
+					t.Errorf("Expected a2 reported as new observation, got %+v", report.NewObservations)
+				}
+				if len(report.ExistingRelations) != 1 || len(report.MissingEndpoints) != 1 {
+					t.Errorf("Expected one existing and one dangling relation, got %+v / %+v", report.ExistingRelations, report.MissingEndpoints)
+				}
+
+				graph, err := s.ExportData()
+				if err != nil {
+					t.Fatalf("ExportData failed: %v", err)
+				}
+				for _, e := range graph.Entities {
+					if e.Name == "A" && (e.EntityType != tt.wantType || !slices.Equal(e.Observations, tt.wantObs)) {
+						t.Errorf("Expected A to be %s %v, got %+v", tt.wantType, tt.wantObs, e)
+					}
+				}
+				exists, _ := s.RelationExists("A", "B", "knows")
+				if exists != tt.wantRelation {
+					t.Errorf("Relation A->B exists = %v, want %v", exists, tt.wantRelation)
+				}
+			})
+		}
+	}
+}
+
+func TestImportDataInvalidMode(t *testing.T) {
+	for backend, s := range newTestStorages(t) {
+		t.Run(backend, func(t *testing.T) {
+			_, err := s.ImportData(&KnowledgeGraph{}, "merge")
+			if !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("Expected ErrInvalidArgument, got %v", err)
+			}
+		})
+	}
+}
+
+// codedError mimics the SQLite driver's error type for retry tests
+type codedError int
+
+func (e codedError) Error() string { return fmt.Sprintf("sqlite error %d", int(e)) }
+func (e codedError) Code() int     { return int(e) }
+
+func TestRetryOnBusy(t *testing.T) {
+	tests := []struct {
+		name      string
+		errs      []error // returned by successive attempts
+		retries   int
+		wantCalls int
+		wantErr   bool
+		sentinel  error // what the final error wraps, if anything
+	}{
+		{"succeeds after busy", []error{codedError(5), codedError(517), nil}, 3, 3, false, nil},
+		{"locked is retried", []error{codedError(6), nil}, 3, 2, false, nil},
+		{"gives up after retries", []error{codedError(5), codedError(5), codedError(5)}, 2, 3, true, ErrStorageLocked},
+		{"other errors are not retried", []error{codedError(2067), nil}, 3, 1, true, ErrDuplicate},
+		{"plain errors are not retried", []error{errors.New("boom"), nil}, 3, 1, true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := retryOnBusy(tt.retries, time.Millisecond, func() error {
+				err := tt.errs[calls]
+				calls++
+				if err == nil {
+					return nil
+				}
+				return fmt.Errorf("wrapped: %w", err)
+			})
+			if calls != tt.wantCalls {
+				t.Errorf("Expected %d attempts, got %d", tt.wantCalls, calls)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Unexpected error result: %v", err)
+			}
+			if tt.sentinel != nil && !errors.Is(err, tt.sentinel) {
+				t.Errorf("Expected error wrapping %v, got %v", tt.sentinel, err)
+			}
+		})
+	}
+}
+
+func TestStorageInfo(t *testing.T) {
+	for backend, s := range newTestStorages(t) {
+		t.Run(backend, func(t *testing.T) {
+			info, err := s.StorageInfo()
+			if err != nil {
+				t.Fatalf("StorageInfo failed: %v", err)
+			}
+			if info.Backend != backend || !filepath.IsAbs(info.FilePath) {
+				t.Errorf("Unexpected info: %+v", info)
+			}
+			if backend == "sqlite" && (info.SchemaVersion == "" || !info.WALEnabled) {
+				t.Errorf("Expected schema version and WAL for SQLite, got %+v", info)
+			}
+			if info.Entities != 0 || info.Relations != 0 {
+				t.Errorf("Expected an empty store, got %+v", info)
+			}
+
+			if _, err := s.CreateEntities([]Entity{{Name: "A", EntityType: "test"}, {Name: "B", EntityType: "test"}}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			if _, err := s.CreateRelations([]Relation{{From: "A", To: "B", RelationType: "knows"}}); err != nil {
+				t.Fatalf("CreateRelations failed: %v", err)
+			}
+			if info, err := s.StorageInfo(); err != nil || info.Entities != 2 || info.Relations != 1 {
+				t.Errorf("Expected 2 entities and 1 relation, got %+v (%v)", info, err)
+			}
+		})
+	}
+}
+
+func TestObservationSources(t *testing.T) {
+	for backend, s := range newTestStorages(t) {
+		t.Run(backend, func(t *testing.T) {
+			_, err := s.CreateEntities([]Entity{{
+				Name:               "A",
+				EntityType:         "test",
+				Observations:       []string{"from agent", "unattributed"},
+				ObservationSources: map[string]string{"from agent": "agent-x"},
+			}})
+			if err != nil {
+				t.Fatalf("Failed to create entity: %v", err)
+			}
+			if _, err := s.AddObservations(map[string][]string{"A": {"added later"}}, "agent-y"); err != nil {
+				t.Fatalf("AddObservations failed: %v", err)
+			}
+
+			want := map[string]string{"from agent": "agent-x", "added later": "agent-y"}
+			graph, err := s.OpenNodes([]string{"A"})
+			if err != nil {
+				t.Fatalf("OpenNodes failed: %v", err)
+			}
+			if len(graph.Entities) != 1 || !maps.Equal(graph.Entities[0].ObservationSources, want) {
+				t.Errorf("OpenNodes sources = %v, want %v", graph.Entities[0].ObservationSources, want)
+			}
+
+			exported, err := s.ExportData()
+			if err != nil {
+				t.Fatalf("ExportData failed: %v", err)
+			}
+			if !maps.Equal(exported.Entities[0].ObservationSources, want) {
+				t.Errorf("ExportData sources = %v, want %v", exported.Entities[0].ObservationSources, want)
+			}
+
+			// Sources of removed observations are dropped
+			if err := s.SetObservations("A", []string{"unattributed"}); err != nil {
+				t.Fatalf("SetObservations failed: %v", err)
+			}
+			graph, _ = s.OpenNodes([]string{"A"})
+			if graph.Entities[0].ObservationSources != nil {
+				t.Errorf("Expected no sources after reset, got %v", graph.Entities[0].ObservationSources)
+			}
+		})
+	}
+}
+
+func TestReadGraphTypeFilter(t *testing.T) {
+	tests := []struct {
+		name          string
+		filter        TypeFilter
+		wantEntities  []string
+		wantRelations int
+	}{
+		{"no filter", TypeFilter{}, []string{"Alice", "Bob", "Log1"}, 2},
+		{"exclude", TypeFilter{Exclude: []string{"log"}}, []string{"Alice", "Bob"}, 1},
+		{"include", TypeFilter{Include: []string{"person", "log"}, Exclude: []string{"person"}}, []string{"Log1"}, 0},
+	}
+
+	for backend, s := range newTestStorages(t) {
+		_, err := s.CreateEntities([]Entity{
+			{Name: "Alice", EntityType: "person", Observations: []string{"a"}},
+			{Name: "Bob", EntityType: "person", Observations: []string{"b"}},
+			{Name: "Log1", EntityType: "log", Observations: []string{"l"}},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create entities: %v", err)
+		}
+		_, err = s.CreateRelations([]Relation{
+			{From: "Alice", To: "Bob", RelationType: "knows"},
+			{From: "Alice", To: "Log1", RelationType: "wrote"},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create relations: %v", err)
+		}
+
+		for _, tt := range tests {
+			t.Run(backend+"/"+tt.name, func(t *testing.T) {
+				result, err := s.ReadGraph("full", 0, tt.filter)
+				if err != nil {
+					t.Fatalf("ReadGraph full failed: %v", err)
+				}
+				graph := result.(*KnowledgeGraph)
+				var names []string
+				for _, e := range graph.Entities {
+					names = append(names, e.Name)
+				}
+				slices.Sort(names)
+				if !slices.Equal(names, tt.wantEntities) || len(graph.Relations) != tt.wantRelations {
+					t.Errorf("full: got %v / %d relations, want %v / %d", names, len(graph.Relations), tt.wantEntities, tt.wantRelations)
+				}
+
+				result, err = s.ReadGraph("summary", 10, tt.filter)
+				if err != nil {
+					t.Fatalf("ReadGraph summary failed: %v", err)
+				}
+				summary := result.(*GraphSummary)
+				if summary.TotalEntities != len(tt.wantEntities) || summary.TotalRelations != tt.wantRelations || len(summary.Entities) != len(tt.wantEntities) {
+					t.Errorf("summary: got %+v, want %d entities / %d relations", summary, len(tt.wantEntities), tt.wantRelations)
+				}
+			})
+		}
+	}
+}
+
+func TestForeignKeyCascade(t *testing.T) {
+	for _, disabled := range []bool{false, true} {
+		s := newTestStorages(t, func(c *Config) { c.DisableForeignKeys = disabled })["sqlite"].(*SQLiteStorage)
+		if _, err := s.CreateEntities([]Entity{
+			{Name: "Alice", EntityType: "person", Observations: []string{"likes Go", "lives in Paris"}},
+			{Name: "Bob", EntityType: "person", Observations: []string{"manager"}},
+		}); err != nil {
+			t.Fatalf("CreateEntities failed: %v", err)
+		}
+		if _, err := s.CreateRelations([]Relation{
+			{From: "Alice", To: "Bob", RelationType: "knows"},
+			{From: "Bob", To: "Alice", RelationType: "manages"},
+		}); err != nil {
+			t.Fatalf("CreateRelations failed: %v", err)
+		}
+		if info, err := s.StorageInfo(); err != nil || info.ForeignKeys == disabled {
+			t.Errorf("Expected foreignKeys %v, got %+v (%v)", !disabled, info, err)
+		}
+
+		if _, err := s.DeleteEntities([]string{"Alice"}); err != nil {
+			t.Fatalf("DeleteEntities failed: %v", err)
+		}
+		// Count the raw rows: reads join entities and would hide orphans
+		var observations, relations int
+		if err := s.db.QueryRow("SELECT COUNT(*) FROM observations").Scan(&observations); err != nil {
+			t.Fatalf("Failed to count observations: %v", err)
+		}
+		if err := s.db.QueryRow("SELECT COUNT(*) FROM relations").Scan(&relations); err != nil {
+			t.Fatalf("Failed to count relations: %v", err)
+		}
+		if !disabled && (observations != 1 || relations != 0) {
+			t.Errorf("Expected Alice's observations and relations deleted with her, got %d observations and %d relations", observations, relations)
+		}
+		if disabled && (observations != 3 || relations != 2) {
+			t.Errorf("Expected rows left behind without foreign keys, got %d observations and %d relations", observations, relations)
+		}
+	}
+}
+
+func TestSchemaMigrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+
+	// A 1.0 database: the original tables, none of the later columns. The 4.0
+	// migration rebuilds entities, which must not cascade to observations.
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE entities (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL UNIQUE, entity_type TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP);
+		CREATE TABLE observations (id INTEGER PRIMARY KEY AUTOINCREMENT, entity_id INTEGER NOT NULL, content TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, UNIQUE(entity_id, content),
+			FOREIGN KEY (entity_id) REFERENCES entities(id) ON DELETE CASCADE);
+		CREATE TABLE metadata (key TEXT PRIMARY KEY, value TEXT NOT NULL);
+		INSERT INTO metadata (key, value) VALUES ('schema_version', '1.0');
+		INSERT INTO entities (name, entity_type) VALUES ('Legacy', 'test');
+		INSERT INTO observations (entity_id, content) VALUES (1, 'old fact');
+	`)
+	db.Close()
+	if err != nil {
+		t.Fatalf("Failed to create legacy schema: %v", err)
+	}
+
+	// Initializing twice must be a no-op the second time
+	for i := 0; i < 2; i++ {
+		s, err := NewSQLiteStorage(Config{FilePath: path})
+		if err != nil {
+			t.Fatalf("Failed to create storage: %v", err)
+		}
+		if err := s.Initialize(); err != nil {
+			t.Fatalf("Initialize #%d failed: %v", i+1, err)
+		}
+
+		info, err := s.StorageInfo()
+		if err != nil || info.SchemaVersion != currentSchemaVersion {
+			t.Errorf("Expected schema version %s, got %+v (%v)", currentSchemaVersion, info, err)
+		}
+		var source, namespace string
+		var accessCount int
+		err = s.db.QueryRow(`
+			SELECT o.source, e.access_count, e.namespace FROM observations o JOIN entities e ON e.id = o.entity_id
+			WHERE e.name = 'Legacy'
+		`).Scan(&source, &accessCount, &namespace)
+		if err != nil || source != "" || accessCount != 0 || namespace != DefaultNamespace {
+			t.Errorf("Expected migrated columns with defaults, got %q %d %q (%v)", source, accessCount, namespace, err)
+		}
+		// ReadGraph, unlike OpenNodes, records no access stats in the
+		// background that the next pass would see
+		if graph, err := s.ReadGraph("full", 0, TypeFilter{}); err != nil || len(graph.(*KnowledgeGraph).Entities) != 1 {
+			t.Errorf("Expected legacy data in the default namespace, got %+v (%v)", graph, err)
+		}
+		s.Close()
+	}
+}
+
+func TestRepairJSONL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.jsonl")
+	legacy := `{"type":"entity","name":" Alice ","entityType":"person","observations":["likes tea","likes tea "," "]}
+{"type":"entity","name":"Alice","entityType":"other","observations":["likes coffee","likes tea"]}
+{"type":"entity","name":"Bob","entityType":"person","observations":[]}
+{"type":"relation","from":"Alice","to":"Bob","relationType":"knows"}
+{"type":"relation","from":"Alice","to":"Bob ","relationType":"knows"}
+{"type":"relation","from":"Alice","to":"Ghost","relationType":"knows"}
+`
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	report, err := RepairJSONL(path, true)
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != legacy || report.BackupPath != "" {
+		t.Fatalf("Dry run must not modify the file")
+	}
+
+	report, err = RepairJSONL(path, false)
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	if len(report.OrphanedRelations) != 1 || report.DuplicateRelations != 1 || !slices.Equal(report.MergedEntities, []string{"Alice"}) ||
+		report.DuplicateObservations != 2 || report.EmptyObservations != 1 || report.TrimmedValues != 4 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+	if backup, err := os.ReadFile(report.BackupPath); err != nil || string(backup) != legacy {
+		t.Errorf("Expected original content in backup, got %v", err)
+	}
+
+	graph, err := LoadGraphFile(path)
+	if err != nil {
+		t.Fatalf("Failed to load repaired file: %v", err)
+	}
+	if len(graph.Entities) != 2 || len(graph.Relations) != 1 ||
+		!slices.Equal(graph.Entities[0].Observations, []string{"likes tea", "likes coffee"}) || graph.Entities[0].EntityType != "person" {
+		t.Errorf("Unexpected repaired graph: %+v", graph)
+	}
+
+	// A clean file is left alone
+	report, err = RepairJSONL(path, false)
+	if err != nil || report.Changed() || report.BackupPath != "" {
+		t.Errorf("Expected no changes on second repair, got %+v (%v)", report, err)
+	}
+}
+
+func TestTopConnectedPairs(t *testing.T) {
+	for backend, s := range newTestStorages(t) {
+		t.Run(backend, func(t *testing.T) {
+			_, err := s.CreateEntities([]Entity{
+				{Name: "A", EntityType: "test"},
+				{Name: "B", EntityType: "test"},
+				{Name: "C", EntityType: "test"},
+			})
+			if err != nil {
+				t.Fatalf("Failed to create entities: %v", err)
+			}
+			_, err = s.CreateRelations([]Relation{
+				{From: "A", To: "C", RelationType: "uses"},
+				{From: "A", To: "B", RelationType: "knows"},
+				{From: "A", To: "B", RelationType: "works_with"},
+				{From: "B", To: "A", RelationType: "knows"},
+			})
+			if err != nil {
+				t.Fatalf("Failed to create relations: %v", err)
+			}
+
+			pairs, err := s.TopConnectedPairs(2)
+			if err != nil {
+				t.Fatalf("TopConnectedPairs failed: %v", err)
+			}
+			want := []PairCount{{"A", "B", 2}, {"A", "C", 1}}
+			if !slices.Equal(pairs, want) {
+				t.Errorf("Got %+v, want %+v", pairs, want)
+			}
+		})
+	}
+}
+
+func TestNormalizedObservationDedup(t *testing.T) {
+	configure := func(c *Config) { c.ObservationDedup = DedupNormalized }
+	for backend, s := range newTestStorages(t, configure) {
+		t.Run(backend, func(t *testing.T) {
+			_, err := s.CreateEntities([]Entity{
+				{Name: "A", EntityType: "test", Observations: []string{"likes coffee", "Likes  Coffee"}},
+			})
+			if err != nil {
+				t.Fatalf("Failed to create entity: %v", err)
+			}
+
+			added, err := s.AddObservations(map[string][]string{"A": {"LIKES coffee ", "likes tea", "Likes Tea"}}, "")
+			if err != nil {
+				t.Fatalf("AddObservations failed: %v", err)
+			}
+			if !slices.Equal(added["A"], []string{"likes tea"}) {
+				t.Errorf("Expected only \"likes tea\" added, got %v", added["A"])
+			}
+
+			_, err = s.CreateEntities([]Entity{{Name: "A", EntityType: "test", Observations: []string{" likes TEA"}}})
+			if err != nil {
+				t.Fatalf("Failed to re-create entity: %v", err)
+			}
+
+			page, err := s.GetObservations("A", 0, 0)
+			if err != nil {
+				t.Fatalf("GetObservations failed: %v", err)
+			}
+			if !slices.Equal(page.Observations, []string{"likes coffee", "likes tea"}) {
+				t.Errorf("Unexpected observations %v", page.Observations)
+			}
+		})
+	}
+}
+
+func TestCompressedJSONLRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.jsonl.gz")
+	s, err := NewJSONLStorage(Config{Type: "jsonl", FilePath: path})
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	_, err = s.CreateEntities([]Entity{{Name: "A", EntityType: "test", Observations: []string{"compressed fact"}}})
+	if err != nil {
+		t.Fatalf("Failed to create entity: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		t.Fatalf("Expected a gzip file on disk, got %q", data)
+	}
+
+	// A fresh storage (and LoadGraphFile) must read it back transparently
+	reopened, _ := NewJSONLStorage(Config{Type: "jsonl", FilePath: path})
+	graph, err := reopened.OpenNodes([]string{"A"})
+	if err != nil || len(graph.Entities) != 1 || graph.Entities[0].Observations[0] != "compressed fact" {
+		t.Errorf("Round trip failed: %+v (%v)", graph, err)
+	}
+	if loaded, err := LoadGraphFile(path); err != nil || len(loaded.Entities) != 1 {
+		t.Errorf("LoadGraphFile failed: %+v (%v)", loaded, err)
+	}
+
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("Expected no leftover temp files, got %d entries", len(entries))
+	}
+
+	// Without the suffix the gzip header is enough, and saves stay compressed
+	plain := filepath.Join(t.TempDir(), "archive.jsonl")
+	if err := os.WriteFile(plain, data, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	archive, _ := NewJSONLStorage(Config{Type: "jsonl", FilePath: plain})
+	if _, err := archive.CreateEntities([]Entity{{Name: "B", EntityType: "test"}}); err != nil {
+		t.Fatalf("Failed to create entity in unsuffixed gzip file: %v", err)
+	}
+	if graph, err := LoadGraphFile(plain); err != nil || len(graph.Entities) != 2 {
+		t.Errorf("Expected both entities from unsuffixed gzip file, got %+v (%v)", graph, err)
+	}
+	if saved, _ := os.ReadFile(plain); !bytes.HasPrefix(saved, gzipMagic) {
+		t.Error("Expected unsuffixed gzip file to stay compressed after save")
+	}
+}
+
+func TestReadGraphAsOf(t *testing.T) {
+	storages := newTestStorages(t)
+	s := storages["sqlite"].(*SQLiteStorage)
+
+	_, err := s.CreateEntities([]Entity{
+		{Name: "Old", EntityType: "person", Observations: []string{"early fact"}},
+		{Name: "New", EntityType: "person", Observations: []string{"late fact"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create entities: %v", err)
+	}
+	if _, err := s.AddObservations(map[string][]string{"Old": {"later fact"}}, ""); err != nil {
+		t.Fatalf("Failed to add observation: %v", err)
+	}
+	if _, err := s.CreateRelations([]Relation{{From: "Old", To: "New", RelationType: "knows"}}); err != nil {
+		t.Fatalf("Failed to create relation: %v", err)
+	}
+
+	// Backdate the history: Old and its first fact in January, everything else in March
+	_, err = s.db.Exec(`
+		UPDATE entities SET created_at = CASE name WHEN 'Old' THEN '2024-01-01 00:00:00' ELSE '2024-03-01 00:00:00' END;
+		UPDATE observations SET created_at = CASE content WHEN 'early fact' THEN '2024-01-01 00:00:00' ELSE '2024-03-01 00:00:00' END;
+		UPDATE relations SET created_at = '2024-03-01 00:00:00';
+	`)
+	if err != nil {
+		t.Fatalf("Failed to backdate rows: %v", err)
+	}
+
+	february := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	result, err := s.ReadGraphAsOf(february, "full", 0, TypeFilter{})
+	if err != nil {
+		t.Fatalf("ReadGraphAsOf failed: %v", err)
+	}
+	graph := result.(*KnowledgeGraph)
+	if len(graph.Entities) != 1 || graph.Entities[0].Name != "Old" {
+		t.Fatalf("Expected only Old in February, got %+v", graph.Entities)
+	}
+	if !slices.Equal(graph.Entities[0].Observations, []string{"early fact"}) {
+		t.Errorf("Expected only the early observation, got %v", graph.Entities[0].Observations)
+	}
+	if len(graph.Relations) != 0 {
+		t.Errorf("Expected no relations in February, got %v", graph.Relations)
+	}
+
+	summary, err := s.ReadGraphAsOf(february, "summary", 10, TypeFilter{})
+	if err != nil || summary.(*GraphSummary).TotalEntities != 1 {
+		t.Errorf("Expected 1 entity in the February summary, got %+v (%v)", summary, err)
+	}
+
+	// Search sees neither the later entity nor the later observation
+	hits, err := s.SearchNodesAsOf(february, "late later", 0)
+	if err != nil {
+		t.Fatalf("SearchNodesAsOf failed: %v", err)
+	}
+	if hits.Total != 0 {
+		t.Errorf("Expected no February hits for later data, got %+v", hits.Entities)
+	}
+	hits, _ = s.SearchNodesAsOf(february, "early", 0)
+	if hits.Total != 1 || hits.Entities[0].Name != "Old" {
+		t.Errorf("Expected Old to match 'early', got %+v", hits.Entities)
+	}
+
+	// At a later point everything is visible again
+	result, _ = s.ReadGraphAsOf(time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), "full", 0, TypeFilter{})
+	graph = result.(*KnowledgeGraph)
+	if len(graph.Entities) != 2 || len(graph.Relations) != 1 {
+		t.Errorf("Expected the full graph in April, got %+v", graph)
+	}
+
+	// JSONL has no creation times to filter on
+	if _, err := storages["jsonl"].ReadGraphAsOf(february, "full", 0, TypeFilter{}); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("Expected ErrInvalidArgument from JSONL, got %v", err)
+	}
+}
+
+func TestDeleteRelationsByType(t *testing.T) {
+	for backend, s := range newTestStorages(t) {
+		t.Run(backend, func(t *testing.T) {
+			_, err := s.CreateEntities([]Entity{
+				{Name: "A", EntityType: "test"},
+				{Name: "B", EntityType: "test"},
+				{Name: "C", EntityType: "test"},
+			})
+			if err != nil {
+				t.Fatalf("Failed to create entities: %v", err)
+			}
+			_, err = s.CreateRelations([]Relation{
+				{From: "A", To: "B", RelationType: "deprecated"},
+				{From: "B", To: "C", RelationType: "deprecated"},
+				{From: "A", To: "C", RelationType: "knows"},
+			})
+			if err != nil {
+				t.Fatalf("Failed to create relations: %v", err)
+			}
+
+			deleted, err := s.DeleteRelationsByType("deprecated")
+			if err != nil || deleted != 2 {
+				t.Fatalf("Expected 2 deleted relations, got %d (%v)", deleted, err)
+			}
+
+			result, _ := s.ReadGraph("full", 0, TypeFilter{})
+			graph := result.(*KnowledgeGraph)
+			if len(graph.Relations) != 1 || graph.Relations[0].RelationType != "knows" {
+				t.Errorf("Expected only the knows relation to remain, got %v", graph.Relations)
+			}
+			if len(graph.Entities) != 3 {
+				t.Errorf("Expected entities to be untouched, got %d", len(graph.Entities))
+			}
+
+			// Unknown types delete nothing
+			if deleted, err := s.DeleteRelationsByType("deprecated"); err != nil || deleted != 0 {
+				t.Errorf("Expected 0 deleted on second call, got %d (%v)", deleted, err)
+			}
+		})
+	}
+}
+
+func TestListEntityNames(t *testing.T) {
+	for backend, s := range newTestStorages(t) {
+		t.Run(backend, func(t *testing.T) {
+			empty, err := s.ListEntityNames(10, 0)
+			if err != nil || empty.Names == nil || empty.Total != 0 || empty.HasMore {
+				t.Fatalf("Expected an empty, non-nil page, got %+v (%v)", empty, err)
+			}
+
+			_, err = s.CreateEntities([]Entity{
+				{Name: "Charlie", EntityType: "person", Observations: []string{"ignored"}},
+				{Name: "Alice", EntityType: "person"},
+				{Name: "Bob", EntityType: "project"},
+			})
+			if err != nil {
+				t.Fatalf("Failed to create entities: %v", err)
+			}
+
+			page, err := s.ListEntityNames(2, 0)
+			if err != nil {
+				t.Fatalf("ListEntityNames failed: %v", err)
+			}
+			if !slices.Equal(page.Names, []string{"Alice", "Bob"}) || page.Total != 3 || !page.HasMore {
+				t.Errorf("Unexpected first page: %+v", page)
+			}
+
+			page, _ = s.ListEntityNames(2, 2)
+			if !slices.Equal(page.Names, []string{"Charlie"}) || page.HasMore {
+				t.Errorf("Unexpected second page: %+v", page)
+			}
+
+			all, _ := s.ListEntityNames(0, 0)
+			if len(all.Names) != 3 || all.HasMore {
+				t.Errorf("Expected all names with limit 0, got %+v", all)
+			}
+		})
+	}
+}
+
+func TestListRelations(t *testing.T) {
+	for backend, s := range newTestStorages(t) {
+		t.Run(backend, func(t *testing.T) {
+			empty, err := s.ListRelations(10, 0)
+			if err != nil || empty.Relations == nil || empty.Total != 0 || empty.HasMore {
+				t.Fatalf("Expected an empty, non-nil page, got %+v (%v)", empty, err)
+			}
+
+			if _, err := s.CreateEntities([]Entity{{Name: "A", EntityType: "node"}, {Name: "B", EntityType: "node"}, {Name: "C", EntityType: "node"}}); err != nil {
+				t.Fatalf("Failed to create entities: %v", err)
+			}
+			created := []Relation{
+				{From: "C", To: "A", RelationType: "links"},
+				{From: "A", To: "B", RelationType: "links"},
+				{From: "B", To: "C", RelationType: "follows"},
+			}
+			for _, relation := range created {
+				if _, err := s.CreateRelations([]Relation{relation}); err != nil {
+					t.Fatalf("Failed to create relation: %v", err)
+				}
+			}
+
+			keys := func(page *RelationPage) []Relation {
+				var out []Relation
+				for _, r := range page.Relations {
+					out = append(out, r.key())
+				}
+				return out
+			}
+
+			page, err := s.ListRelations(2, 0)
+			if err != nil {
+				t.Fatalf("ListRelations failed: %v", err)
+			}
+			if !slices.Equal(keys(page), created[:2]) || page.Total != 3 || !page.HasMore {
+				t.Errorf("Unexpected first page: %+v", page)
+			}
+
+			page, _ = s.ListRelations(2, 2)
+			if !slices.Equal(keys(page), created[2:]) || page.HasMore {
+				t.Errorf("Unexpected second page: %+v", page)
+			}
+
+			all, _ := s.ListRelations(0, 0)
+			if !slices.Equal(keys(all), created) || all.HasMore {
+				t.Errorf("Expected all relations in creation order with limit 0, got %+v", all)
+			}
+		})
+	}
+}
+
+func TestNamespaces(t *testing.T) {
+	for backend, s := range newTestStorages(t) {
+		t.Run(backend, func(t *testing.T) {
+			work := s.WithNamespace("work")
+
+			// The same name can live in two namespaces without colliding
+			if _, err := s.CreateEntities([]Entity{
+				{Name: "Alice", EntityType: "person", Observations: []string{"likes tea"}},
+				{Name: "Bob", EntityType: "person"},
+			}); err != nil {
+				t.Fatalf("Failed to create default entities: %v", err)
+			}
+			if _, err := s.CreateRelations([]Relation{{From: "Alice", To: "Bob", RelationType: "knows"}}); err != nil {
+				t.Fatalf("Failed to create default relation: %v", err)
+			}
+			if _, err := work.CreateEntities([]Entity{
+				{Name: "Alice", EntityType: "engineer", Observations: []string{"writes Go"}},
+			}); err != nil {
+				t.Fatalf("Failed to create work entities: %v", err)
+			}
+
+			graph, err := work.OpenNodes([]string{"Alice", "Bob"})
+			if err != nil || len(graph.Entities) != 1 || graph.Entities[0].EntityType != "engineer" ||
+				!slices.Equal(graph.Entities[0].Observations, []string{"writes Go"}) {
+				t.Errorf("Expected only the work Alice, got %+v (%v)", graph, err)
+			}
+
+			result, err := work.SearchNodes("tea", 10)
+			if err != nil || result.Total != 0 {
+				t.Errorf("Expected default observations to be invisible in work, got %+v (%v)", result, err)
+			}
+			if exists, _ := work.RelationExists("Alice", "Bob", "knows"); exists {
+				t.Error("Expected default relations to be invisible in work")
+			}
+
+			namespaces, err := s.Namespaces()
+			if err != nil || !slices.Equal(namespaces, []string{DefaultNamespace, "work"}) {
+				t.Errorf("Expected [default work], got %v (%v)", namespaces, err)
+			}
+			if info, _ := work.StorageInfo(); info.Namespace != "work" {
+				t.Errorf("Expected storage info to report the work namespace, got %+v", info)
+			}
+
+			// Clearing one namespace leaves the other intact
+			cleared, err := work.Clear()
+			if err != nil || cleared.EntitiesRemoved != 1 {
+				t.Fatalf("Expected to clear one work entity, got %+v (%v)", cleared, err)
+			}
+			full, err := s.ReadGraph("full", 0, TypeFilter{})
+			if err != nil {
+				t.Fatalf("ReadGraph failed: %v", err)
+			}
+			kg := full.(*KnowledgeGraph)
+			if len(kg.Entities) != 2 || len(kg.Relations) != 1 {
+				t.Errorf("Expected the default graph to survive, got %+v", kg)
+			}
+			if result, _ := s.SearchNodes("tea", 10); result.Total != 1 {
+				t.Errorf("Expected default observations to stay searchable, got %+v", result)
+			}
+		})
+	}
+}
+
+func TestValidateNamespace(t *testing.T) {
+	for _, ns := range []string{"default", "team-a", "v1.2_x"} {
+		if err := ValidateNamespace(ns); err != nil {
+			t.Errorf("Expected %q to be valid: %v", ns, err)
+		}
+	}
+	for _, ns := range []string{"", "has space", "a/b", strings.Repeat("x", 65)} {
+		if err := ValidateNamespace(ns); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("Expected %q to be rejected, got %v", ns, err)
+		}
+	}
+}
+
+func TestTransferEntities(t *testing.T) {
+	for backend, s := range newTestStorages(t) {
+		t.Run(backend, func(t *testing.T) {
+			scratch, shared := s.WithNamespace("scratch"), s.WithNamespace("shared")
+			if _, err := scratch.CreateEntities([]Entity{
+				{Name: "Alice", EntityType: "person", Observations: []string{"new fact"}},
+				{Name: "Bob", EntityType: "person", Observations: []string{"knows Go"}},
+				{Name: "Carol", EntityType: "person"},
+			}); err != nil {
+				t.Fatalf("Failed to create scratch entities: %v", err)
+			}
+			if _, err := scratch.CreateRelations([]Relation{
+				{From: "Alice", To: "Bob", RelationType: "knows"},
+				{From: "Bob", To: "Carol", RelationType: "knows"},
+			}); err != nil {
+				t.Fatalf("Failed to create scratch relations: %v", err)
+			}
+			if _, err := shared.CreateEntities([]Entity{{Name: "Alice", EntityType: "engineer", Observations: []string{"old fact"}}}); err != nil {
+				t.Fatalf("Failed to create shared entity: %v", err)
+			}
+
+			// Skip keeps the existing Alice, and relations to her land on her; Carol
+			// is not in shared, so Bob -> Carol is dropped
+			result, err := scratch.TransferEntities([]string{"Alice", "Bob"}, "shared", TransferOptions{Relations: true})
+			if err != nil {
+				t.Fatalf("Copy failed: %v", err)
+			}
+			if !slices.Equal(result.Skipped, []string{"Alice"}) || len(result.Transferred) != 1 || result.RelationsCopied != 1 ||
+				len(result.RelationsDropped) != 1 {
+				t.Errorf("Unexpected skip result: %+v", result)
+			}
+			if exists, _ := shared.RelationExists("Alice", "Bob", "knows"); !exists {
+				t.Error("Expected Alice -> Bob to be copied into shared")
+			}
+			graph, _ := shared.OpenNodes([]string{"Alice"})
+			if len(graph.Entities) != 1 || graph.Entities[0].EntityType != "engineer" {
+				t.Errorf("Expected skip to leave shared Alice alone, got %+v", graph.Entities)
+			}
+
+			// Rename copies Alice under a free name
+			result, err = scratch.TransferEntities([]string{"Alice"}, "shared", TransferOptions{Mode: TransferModeRename})
+			if err != nil || len(result.Transferred) != 1 || result.Transferred[0].TargetName != "Alice (2)" {
+				t.Fatalf("Unexpected rename result: %+v (%v)", result, err)
+			}
+
+			// Overwrite then move: scratch Alice replaces shared Alice and leaves scratch
+			result, err = scratch.TransferEntities([]string{"Alice"}, "shared", TransferOptions{Mode: TransferModeOverwrite, Move: true, Relations: true})
+			if err != nil || len(result.Transferred) != 1 || !result.Transferred[0].Overwritten {
+				t.Fatalf("Unexpected overwrite result: %+v (%v)", result, err)
+			}
+			graph, _ = shared.OpenNodes([]string{"Alice"})
+			if len(graph.Entities) != 1 || graph.Entities[0].EntityType != "person" ||
+				!slices.Equal(graph.Entities[0].Observations, []string{"new fact"}) {
+				t.Errorf("Expected shared Alice to be overwritten, got %+v", graph.Entities)
+			}
+			graph, _ = scratch.OpenNodes([]string{"Alice", "Bob"})
+			if exists, _ := scratch.RelationExists("Alice", "Bob", "knows"); len(graph.Entities) != 1 || exists {
+				t.Errorf("Expected Alice and her relations to leave scratch, got %+v", graph)
+			}
+
+			// A missing entity fails the whole transfer
+			_, err = scratch.TransferEntities([]string{"Bob", "Nobody"}, "shared", TransferOptions{})
+			if !errors.Is(err, ErrEntityNotFound) {
+				t.Errorf("Expected ErrEntityNotFound, got %v", err)
+			}
+			if _, err := scratch.TransferEntities([]string{"Bob"}, "scratch", TransferOptions{}); !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("Expected a same-namespace transfer to be rejected, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDiffGraphs(t *testing.T) {
+	before := &KnowledgeGraph{
+		Entities: []Entity{
+			{Name: "Alice", EntityType: "person", Observations: []string{"likes tea", "lives in Paris"}},
+			{Name: "Bob", EntityType: "person", Observations: []string{"plays chess"}},
+			{Name: "Gone", EntityType: "note", Observations: []string{"temporary"}},
+		},
+		Relations: []Relation{
+			{From: "Alice", To: "Bob", RelationType: "knows"},
+			{From: "Alice", To: "Gone", RelationType: "wrote"},
+		},
+	}
+	after := &KnowledgeGraph{
+		Entities: []Entity{
+			{Name: "Bob", EntityType: "person", Observations: []string{"plays chess"}},
+			{Name: "Alice", EntityType: "engineer", Observations: []string{"lives in Paris", "likes coffee"}},
+			{Name: "Carol", EntityType: "person", Observations: []string{"new"}},
+		},
+		Relations: []Relation{
+			{From: "Alice", To: "Bob", RelationType: "knows"},
+			{From: "Carol", To: "Alice", RelationType: "knows"},
+		},
+	}
+
+	diff := DiffGraphs(before, after)
+	want := DiffCounts{
+		EntitiesAdded: 1, EntitiesRemoved: 1, EntitiesModified: 1,
+		ObservationsAdded: 2, ObservationsRemoved: 2,
+		RelationsAdded: 1, RelationsRemoved: 1,
+	}
+	if diff.Counts != want {
+		t.Errorf("Expected counts %+v, got %+v", want, diff.Counts)
+	}
+	if len(diff.ModifiedEntities) != 1 {
+		t.Fatalf("Expected one modified entity, got %+v", diff.ModifiedEntities)
+	}
+	change := diff.ModifiedEntities[0]
+	if change.Name != "Alice" || change.OldType != "person" || change.NewType != "engineer" ||
+		!slices.Equal(change.AddedObservations, []string{"likes coffee"}) ||
+		!slices.Equal(change.RemovedObservations, []string{"likes tea"}) {
+		t.Errorf("Unexpected change for Alice: %+v", change)
+	}
+	if diff.AddedEntities[0].Name != "Carol" || diff.RemovedEntities[0].Name != "Gone" {
+		t.Errorf("Unexpected added/removed entities: %+v / %+v", diff.AddedEntities, diff.RemovedEntities)
+	}
+
+	if same := DiffGraphs(after, after); same.Counts != (DiffCounts{}) {
+		t.Errorf("Expected no changes between identical graphs, got %+v", same.Counts)
+	}
+}
+
+func TestBackupGraph(t *testing.T) {
+	for name, store := range newTestStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.CreateEntities([]Entity{{Name: "Alice", EntityType: "person", Observations: []string{"likes tea"}}}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			if _, err := store.WithNamespace("work").CreateEntities([]Entity{{Name: "Acme", EntityType: "company"}}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+
+			dir := filepath.Join(t.TempDir(), "backups")
+			var latest string
+			for range 3 {
+				path, err := BackupGraph(store, dir, 2)
+				if err != nil {
+					t.Fatalf("BackupGraph failed: %v", err)
+				}
+				latest = path
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil || len(entries) != 2 {
+				t.Fatalf("Expected 2 backups to be kept, got %d (%v)", len(entries), err)
+			}
+			if entries[1].Name() != filepath.Base(latest) {
+				t.Errorf("Expected the newest backup %s to be kept, got %v", latest, entries)
+			}
+
+			set, err := readGraphSet(latest, nil)
+			if err != nil {
+				t.Fatalf("Failed to read backup: %v", err)
+			}
+			if got := set.graph(DefaultNamespace).Entities; len(got) != 1 || got[0].Name != "Alice" {
+				t.Errorf("Expected Alice in the default namespace backup, got %+v", got)
+			}
+			if got := set.graph("work").Entities; len(got) != 1 || got[0].Name != "Acme" {
+				t.Errorf("Expected Acme in the work namespace backup, got %+v", got)
+			}
+		})
+	}
+}
+
+func TestReachable(t *testing.T) {
+	for name, store := range newTestStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			var entities []Entity
+			for _, n := range []string{"A", "B", "C", "D", "E"} {
+				entities = append(entities, Entity{Name: n, EntityType: "node"})
+			}
+			if _, err := store.CreateEntities(entities); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			if _, err := store.CreateRelations([]Relation{
+				{From: "A", To: "B", RelationType: "links"},
+				{From: "B", To: "C", RelationType: "links"},
+				{From: "C", To: "D", RelationType: "links"},
+				{From: "C", To: "A", RelationType: "links"}, // cycle back to the start
+				{From: "A", To: "E", RelationType: "other"},
+			}); err != nil {
+				t.Fatalf("CreateRelations failed: %v", err)
+			}
+
+			found, err := store.Reachable("A", "links", 0, "")
+			if err != nil {
+				t.Fatalf("Reachable failed: %v", err)
+			}
+			want := []ReachableEntity{
+				{Name: "B", EntityType: "node", Hops: 1},
+				{Name: "C", EntityType: "node", Hops: 2},
+				{Name: "D", EntityType: "node", Hops: 3},
+			}
+			if !slices.Equal(found, want) {
+				t.Errorf("Expected %+v, got %+v", want, found)
+			}
+
+			found, _ = store.Reachable("A", "links", 2, "in")
+			if want := []ReachableEntity{{Name: "C", EntityType: "node", Hops: 1}, {Name: "B", EntityType: "node", Hops: 2}}; !slices.Equal(found, want) {
+				t.Errorf("Expected %+v going in, got %+v", want, found)
+			}
+
+			found, _ = store.Reachable("A", "", 1, "out")
+			if len(found) != 2 {
+				t.Errorf("Expected B and E one hop out over any type, got %+v", found)
+			}
+
+			if _, err := store.Reachable("Missing", "links", 1, ""); !errors.Is(err, ErrEntityNotFound) {
+				t.Errorf("Expected ErrEntityNotFound, got %v", err)
+			}
+			if _, err := store.Reachable("A", "links", MaxReachableHops+1, ""); !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("Expected ErrInvalidArgument for too many hops, got %v", err)
+			}
+		})
+	}
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	for name, store := range newTestStorages(t, func(c *Config) { c.AnalyticsCache = true }) {
+		t.Run(name, func(t *testing.T) {
+			if _, found, err := store.IdempotentResult("create_entities", "k1", time.Hour); err != nil || found {
+				t.Fatalf("Expected no recorded result, got found=%v (%v)", found, err)
+			}
+			if err := store.RecordIdempotentResult("create_entities", "k1", `["ok"]`, time.Hour); err != nil {
+				t.Fatalf("RecordIdempotentResult failed: %v", err)
+			}
+			if sqlite, ok := store.(*SQLiteStorage); ok && sqlite.analytics.current() != 0 {
+				t.Errorf("Expected recording a key not to count as a graph write, got %d writes", sqlite.analytics.current())
+			}
+			if result, found, err := store.IdempotentResult("create_entities", "k1", time.Hour); err != nil || !found || result != `["ok"]` {
+				t.Errorf("Expected the recorded result, got %q found=%v (%v)", result, found, err)
+			}
+			if _, found, _ := store.IdempotentResult("add_observations", "k1", time.Hour); found {
+				t.Error("Expected keys to be scoped to the tool")
+			}
+			if _, found, _ := store.WithNamespace("other").IdempotentResult("create_entities", "k1", time.Hour); found {
+				t.Error("Expected keys to be scoped to the namespace")
+			}
+
+			time.Sleep(5 * time.Millisecond)
+			if _, found, _ := store.IdempotentResult("create_entities", "k1", time.Millisecond); found {
+				t.Error("Expected the key to expire after the TTL")
+			}
+		})
+	}
+}
+
+func TestEntitiesByObservationCount(t *testing.T) {
+	for name, store := range newTestStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.CreateEntities([]Entity{
+				{Name: "Empty", EntityType: "note"},
+				{Name: "One", EntityType: "note", Observations: []string{"a"}},
+				{Name: "Three", EntityType: "note", Observations: []string{"a", "b", "c"}},
+				{Name: "Also One", EntityType: "note", Observations: []string{"x"}},
+			}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+
+			names := func(counts []ObservationCount) []string {
+				var out []string
+				for _, c := range counts {
+					out = append(out, fmt.Sprintf("%s=%d", c.Name, c.Count))
+				}
+				return out
+			}
+
+			all, err := store.EntitiesByObservationCount(0, -1, 0, false)
+			if err != nil {
+				t.Fatalf("EntitiesByObservationCount failed: %v", err)
+			}
+			if want := []string{"Three=3", "Also One=1", "One=1", "Empty=0"}; !slices.Equal(names(all), want) {
+				t.Errorf("Expected %v, got %v", want, names(all))
+			}
+
+			empty, _ := store.EntitiesByObservationCount(0, 0, 0, false)
+			if want := []string{"Empty=0"}; !slices.Equal(names(empty), want) {
+				t.Errorf("Expected only the entity without observations, got %v", names(empty))
+			}
+
+			some, _ := store.EntitiesByObservationCount(1, 3, 2, true)
+			if want := []string{"Also One=1", "One=1"}; !slices.Equal(names(some), want) {
+				t.Errorf("Expected the 2 least-documented with observations, got %v", names(some))
+			}
+
+			if _, err := store.EntitiesByObservationCount(3, 1, 0, false); !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("Expected ErrInvalidArgument for an empty range, got %v", err)
+			}
+		})
+	}
+}
+
+func TestClosure(t *testing.T) {
+	for name, store := range newTestStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			// A chain longer than MaxReachableHops, closed into a cycle, with a side branch
+			var entities []Entity
+			var relations []Relation
+			for i := 0; i < 12; i++ {
+				entities = append(entities, Entity{Name: fmt.Sprintf("M%02d", i), EntityType: "module"})
+				relations = append(relations, Relation{From: fmt.Sprintf("M%02d", i), To: fmt.Sprintf("M%02d", (i+1)%12), RelationType: "depends_on"})
+			}
+			entities = append(entities, Entity{Name: "Lib", EntityType: "module"})
+			relations = append(relations, Relation{From: "M00", To: "Lib", RelationType: "depends_on"})
+			if _, err := store.CreateEntities(entities); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			if _, err := store.CreateRelations(relations); err != nil {
+				t.Fatalf("CreateRelations failed: %v", err)
+			}
+
+			closure, err := store.Closure("M00", "depends_on", "out", 0)
+			if err != nil {
+				t.Fatalf("Closure failed: %v", err)
+			}
+			if closure.Truncated || len(closure.Entities) != 12 {
+				t.Fatalf("Expected all 12 other modules untruncated, got %+v", closure)
+			}
+			if first, last := closure.Entities[0], closure.Entities[11]; first.Name != "Lib" || first.Hops != 1 || last.Name != "M11" || last.Hops != 11 {
+				t.Errorf("Expected Lib at 1 hop first and M11 at 11 hops last, got %+v", closure.Entities)
+			}
+
+			closure, _ = store.Closure("M00", "depends_on", "out", 4)
+			want := []ReachableEntity{
+				{Name: "Lib", EntityType: "module", Hops: 1},
+				{Name: "M01", EntityType: "module", Hops: 1},
+				{Name: "M02", EntityType: "module", Hops: 2},
+				{Name: "M03", EntityType: "module", Hops: 3},
+			}
+			if !closure.Truncated || !slices.Equal(closure.Entities, want) {
+				t.Errorf("Expected the nearest 4 modules, truncated, got %+v", closure)
+			}
+
+			closure, _ = store.Closure("Lib", "depends_on", "in", 0)
+			if closure.Truncated || len(closure.Entities) != 12 || closure.Entities[0].Name != "M00" {
+				t.Errorf("Expected every module to depend on Lib transitively, got %+v", closure)
+			}
+
+			if _, err := store.Closure("Missing", "", "out", 0); !errors.Is(err, ErrEntityNotFound) {
+				t.Errorf("Expected ErrEntityNotFound, got %v", err)
+			}
+			if _, err := store.Closure("M00", "", "out", MaxClosureNodes+1); !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("Expected ErrInvalidArgument for too many nodes, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFindAllPaths(t *testing.T) {
+	for name, store := range newTestStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.CreateEntities([]Entity{
+				{Name: "A", EntityType: "node"},
+				{Name: "B", EntityType: "node"},
+				{Name: "C", EntityType: "node"},
+				{Name: "D", EntityType: "node"},
+			}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			// D -> A closes a cycle the search must not follow back around
+			if _, err := store.CreateRelations([]Relation{
+				{From: "A", To: "B", RelationType: "links"},
+				{From: "A", To: "C", RelationType: "links"},
+				{From: "A", To: "D", RelationType: "links"},
+				{From: "B", To: "C", RelationType: "links"},
+				{From: "B", To: "D", RelationType: "links"},
+				{From: "C", To: "D", RelationType: "links"},
+				{From: "D", To: "A", RelationType: "links"},
+			}); err != nil {
+				t.Fatalf("CreateRelations failed: %v", err)
+			}
+
+			route := func(path []Relation) string {
+				names := []string{path[0].From}
+				for _, relation := range path {
+					names = append(names, relation.To)
+				}
+				return strings.Join(names, ">")
+			}
+			routes := func(paths [][]Relation) []string {
+				out := []string{}
+				for _, path := range paths {
+					out = append(out, route(path))
+				}
+				return out
+			}
+
+			paths, err := store.FindAllPaths("A", "D", 0, 0)
+			if err != nil {
+				t.Fatalf("FindAllPaths failed: %v", err)
+			}
+			want := []string{"A>D", "A>B>D", "A>C>D", "A>B>C>D"}
+			if got := routes(paths); !slices.Equal(got, want) {
+				t.Errorf("Expected %v, got %v", want, got)
+			}
+
+			paths, _ = store.FindAllPaths("A", "D", 2, 0)
+			if got := routes(paths); !slices.Equal(got, want[:3]) {
+				t.Errorf("Expected paths up to 2 relations long, got %v", got)
+			}
+			paths, _ = store.FindAllPaths("A", "D", 0, 2)
+			if got := routes(paths); !slices.Equal(got, want[:2]) {
+				t.Errorf("Expected the 2 shortest paths, got %v", got)
+			}
+
+			paths, err = store.FindAllPaths("C", "B", 0, 0)
+			if err != nil {
+				t.Fatalf("FindAllPaths failed: %v", err)
+			}
+			if got := routes(paths); !slices.Equal(got, []string{"C>D>A>B"}) {
+				t.Errorf("Expected the single path through the cycle, got %v", got)
+			}
+
+			if _, err := store.FindAllPaths("A", "Missing", 0, 0); !errors.Is(err, ErrEntityNotFound) {
+				t.Errorf("Expected ErrEntityNotFound, got %v", err)
+			}
+			if _, err := store.FindAllPaths("A", "A", 0, 0); !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("Expected ErrInvalidArgument for from == to, got %v", err)
+			}
+			if _, err := store.FindAllPaths("A", "D", MaxPathDepth+1, 0); !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("Expected ErrInvalidArgument for too deep a search, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRecentlyModified(t *testing.T) {
+	for name, store := range newTestStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.CreateEntities([]Entity{
+				{Name: "A", EntityType: "note"},
+				{Name: "B", EntityType: "note"},
+				{Name: "C", EntityType: "note"},
+			}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+
+			// Entities created together tie, newest in the file first, and the edit
+			// below moves A ahead of them
+			want := []string{"A", "C"}
+			if sqlite, ok := store.(*SQLiteStorage); ok {
+				// Age every entity so the edit below is strictly the newest change
+				if _, err := sqlite.db.Exec("UPDATE entities SET updated_at = '2020-01-01 00:00:00'"); err != nil {
+					t.Fatalf("Failed to age entities: %v", err)
+				}
+			}
+			if _, err := store.AddObservations(map[string][]string{"A": {"edited"}}, ""); err != nil {
+				t.Fatalf("AddObservations failed: %v", err)
+			}
+
+			recent, err := store.RecentlyModified(2)
+			if err != nil {
+				t.Fatalf("RecentlyModified failed: %v", err)
+			}
+			var names []string
+			for _, entity := range recent {
+				names = append(names, entity.Name)
+				if entity.UpdatedAt == nil {
+					t.Errorf("Expected an update time for %s", entity.Name)
+				}
+			}
+			if !slices.Equal(names, want) {
+				t.Errorf("Expected %v, got %v", want, names)
+			}
+
+			if all, _ := store.RecentlyModified(0); len(all) != 3 {
+				t.Errorf("Expected the default limit to cover all 3 entities, got %d", len(all))
+			}
+		})
+	}
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	for name, store := range newTestStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.CreateEntities([]Entity{
+				{Name: "Alice", EntityType: "person", Observations: []string{"likes tea", "lives in Paris"}},
+				{Name: "Bob", EntityType: "person"},
+			}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			if _, err := store.CreateRelations([]Relation{{From: "Alice", To: "Bob", RelationType: "knows"}}); err != nil {
+				t.Fatalf("CreateRelations failed: %v", err)
+			}
+			if _, err := store.WithNamespace("other").CreateEntities([]Entity{{Name: "Hidden", EntityType: "note"}}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+
+			var buf strings.Builder
+			if err := WriteNDJSON(store, &buf); err != nil {
+				t.Fatalf("WriteNDJSON failed: %v", err)
+			}
+			lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+			if len(lines) != 3 {
+				t.Fatalf("Expected 2 entity lines and 1 relation line, got %q", lines)
+			}
+
+			// The stream is a valid JSONL memory file
+			graph := parseJSONL([]byte(buf.String()))
+			if len(graph.Entities) != 2 || len(graph.Relations) != 1 {
+				t.Fatalf("Expected the stream to parse back, got %+v", graph)
+			}
+			if graph.Entities[0].Name != "Alice" || !slices.Equal(graph.Entities[0].Observations, []string{"likes tea", "lives in Paris"}) {
+				t.Errorf("Unexpected first entity: %+v", graph.Entities[0])
+			}
+			if graph.Entities[1].Observations == nil {
+				t.Error("Expected an empty observations array for Bob, got null")
+			}
+		})
+	}
+}
+
+func TestCreateEntitiesMergeStrategy(t *testing.T) {
+	for name, store := range newTestStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.CreateEntities([]Entity{
+				{Name: "Append", EntityType: "old", Observations: []string{"a"}},
+				{Name: "Replace", EntityType: "old", Observations: []string{"a", "b"}},
+				{Name: "Keep", EntityType: "old", Observations: []string{"a"}},
+				{Name: "Empty", EntityType: "old"},
+			}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+
+			update := func(name, strategy string) {
+				t.Helper()
+				if _, err := store.CreateEntitiesWithStrategy([]Entity{{Name: name, EntityType: "new", Observations: []string{"a", "c"}}}, strategy); err != nil {
+					t.Fatalf("CreateEntitiesWithStrategy(%s) failed: %v", strategy, err)
+				}
+			}
+			update("Append", "")
+			update("Replace", MergeReplace)
+			update("Keep", MergeKeep)
+			update("Empty", MergeKeep)
+
+			graph, err := store.OpenNodes([]string{"Append", "Replace", "Keep", "Empty"})
+			if err != nil {
+				t.Fatalf("OpenNodes failed: %v", err)
+			}
+			want := map[string]struct {
+				entityType   string
+				observations []string
+			}{
+				"Append":  {"new", []string{"a", "c"}},
+				"Replace": {"new", []string{"a", "c"}},
+				"Keep":    {"old", []string{"a"}},
+				"Empty":   {"old", []string{"a", "c"}},
+			}
+			for _, entity := range graph.Entities {
+				w := want[entity.Name]
+				obs := slices.Clone(entity.Observations)
+				slices.Sort(obs)
+				if entity.EntityType != w.entityType || !slices.Equal(obs, w.observations) {
+					t.Errorf("%s: expected %s %v, got %s %v", entity.Name, w.entityType, w.observations, entity.EntityType, obs)
+				}
+			}
+
+			if _, err := store.CreateEntitiesWithStrategy([]Entity{{Name: "X", EntityType: "t"}}, "merge"); !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("Expected ErrInvalidArgument for an unknown strategy, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRelationCreatedAt(t *testing.T) {
+	for name, store := range newTestStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.CreateEntities([]Entity{{Name: "A", EntityType: "t"}, {Name: "B", EntityType: "t"}, {Name: "C", EntityType: "t"}}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			old := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+			if _, err := store.CreateRelations([]Relation{
+				{From: "A", To: "B", RelationType: "old", CreatedAt: &old},
+				{From: "A", To: "C", RelationType: "new"},
+			}); err != nil {
+				t.Fatalf("CreateRelations failed: %v", err)
+			}
+
+			graph, err := store.OpenNodes([]string{"A"})
+			if err != nil {
+				t.Fatalf("OpenNodes failed: %v", err)
+			}
+			SortRelationsByRecency(graph.Relations)
+			if len(graph.Relations) != 2 || graph.Relations[0].RelationType != "new" {
+				t.Fatalf("Expected the new relation first, got %+v", graph.Relations)
+			}
+			for _, r := range graph.Relations {
+				if r.CreatedAt == nil {
+					t.Errorf("Expected a creation time on %+v", r)
+				}
+			}
+			if got := graph.Relations[1].CreatedAt; got == nil || !got.Equal(old) {
+				t.Errorf("Expected a supplied creation time to be kept, got %v", got)
+			}
+
+			// Timestamps don't affect relation identity
+			if exists, _ := store.RelationExists("A", "B", "old"); !exists {
+				t.Error("Expected RelationExists to ignore the timestamp")
+			}
+			if created, _ := store.CreateRelations([]Relation{{From: "A", To: "B", RelationType: "old"}}); len(created) != 0 {
+				t.Errorf("Expected the duplicate relation to be skipped, got %+v", created)
+			}
+		})
+	}
+}
+
+func TestSearchNodesWithSnippets(t *testing.T) {
+	for name, store := range newTestStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			// Filler observations keep bm25's document frequencies meaningful
+			filler := make([]string, 20)
+			for i := range filler {
+				filler[i] = fmt.Sprintf("filler %d", i)
+			}
+			if _, err := store.CreateEntities([]Entity{{
+				Name:       "Verbose",
+				EntityType: "note",
+				Observations: []string{
+					"alpha only",
+					"beta only",
+					"unrelated",
+					"alpha and beta together",
+					"alpha again",
+				},
+			}, {Name: "Other", EntityType: "note", Observations: filler}}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+
+			snippets := func(limit, maxSnippets int) []string {
+				t.Helper()
+				result, err := store.SearchNodesWithSnippets("alpha beta", limit, maxSnippets)
+				if err != nil {
+					t.Fatalf("SearchNodesWithSnippets failed: %v", err)
+				}
+				if len(result.Entities) != 1 {
+					t.Fatalf("Expected 1 hit, got %d", len(result.Entities))
+				}
+				return result.Entities[0].Snippets
+			}
+
+			top := snippets(10, 1)
+			if len(top) != 1 || top[0] != "alpha and beta together" {
+				t.Errorf("Expected the observation with both words first, got %v", top)
+			}
+			if got := snippets(10, 3); len(got) != 3 {
+				t.Errorf("Expected 3 snippets, got %v", got)
+			}
+			if got := snippets(10, 0); len(got) != DefaultMaxSnippets {
+				t.Errorf("Expected %d snippets by default, got %v", DefaultMaxSnippets, got)
+			}
+			if got := snippets(0, 0); len(got) != 4 {
+				t.Errorf("Expected every matching observation without a limit, got %v", got)
+			}
+		})
+	}
+}
+
+func TestEntityTemplates(t *testing.T) {
+	templates := map[string][]string{"person": {"Role: ", "Email: "}}
+	for name, store := range newTestStorages(t, func(c *Config) { c.EntityTemplates = templates }) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.CreateEntities([]Entity{{Name: "Existing", EntityType: "person", Observations: []string{"Role: admin"}}}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			created, err := store.CreateEntities([]Entity{
+				{Name: "Alice", EntityType: "person"},
+				{Name: "Bob", EntityType: "person", Observations: []string{"Likes tea"}},
+				{Name: "Acme", EntityType: "company"},
+				{Name: "Existing", EntityType: "person"},
+			})
+			if err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			if !slices.Equal(created[0].Observations, templates["person"]) {
+				t.Errorf("Expected the template in the result, got %v", created[0].Observations)
+			}
+
+			graph, err := store.OpenNodes([]string{"Alice", "Bob", "Acme", "Existing"})
+			if err != nil {
+				t.Fatalf("OpenNodes failed: %v", err)
+			}
+			want := map[string][]string{
+				"Alice":    {"Role: ", "Email: "},
+				"Bob":      {"Likes tea"},
+				"Acme":     nil,
+				"Existing": {"Role: admin"},
+			}
+			for _, entity := range graph.Entities {
+				if !slices.Equal(entity.Observations, want[entity.Name]) {
+					t.Errorf("%s: expected observations %v, got %v", entity.Name, want[entity.Name], entity.Observations)
+				}
+			}
+		})
+	}
+}
+
+func TestExportEntityContext(t *testing.T) {
+	for name, store := range newTestStorages(t, func(c *Config) { c.MaxObservationsPerEntity = 1 }) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.CreateEntities([]Entity{
+				{Name: "Alice", EntityType: "person", Observations: []string{"a1", "a2"}},
+				{Name: "Acme", EntityType: "company", Observations: []string{"c1"}},
+				{Name: "Bob", EntityType: "person"},
+				{Name: "Far", EntityType: "place"},
+			}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			if _, err := store.CreateRelations([]Relation{
+				{From: "Alice", To: "Acme", RelationType: "works_at"},
+				{From: "Bob", To: "Alice", RelationType: "knows"},
+				{From: "Acme", To: "Far", RelationType: "located_in"},
+			}); err != nil {
+				t.Fatalf("CreateRelations failed: %v", err)
+			}
+
+			dir := t.TempDir()
+			for _, format := range []string{ContextFormatJSONL, ContextFormatJSON} {
+				export, err := ExportEntityContext(store, "Alice", 1, dir, format)
+				if err != nil {
+					t.Fatalf("ExportEntityContext(%s) failed: %v", format, err)
+				}
+				if export.Entities != 3 || export.Relations != 2 {
+					t.Errorf("%s: expected 3 entities and 2 relations, got %+v", format, export)
+				}
+
+				graph, err := LoadGraphFile(export.Path)
+				if err != nil {
+					t.Fatalf("LoadGraphFile failed: %v", err)
+				}
+				for _, entity := range graph.Entities {
+					if entity.Name == "Far" {
+						t.Errorf("%s: entity outside the neighborhood was exported", format)
+					}
+					if entity.Name == "Alice" && len(entity.Observations) != 2 {
+						t.Errorf("%s: expected all of Alice's observations, got %v", format, entity.Observations)
+					}
+				}
+				if len(graph.Relations) != 2 {
+					t.Errorf("%s: expected 2 relations in the file, got %v", format, graph.Relations)
+				}
+			}
+
+			if _, err := ExportEntityContext(store, "Alice", 1, dir, "xml"); !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("Expected ErrInvalidArgument for an unknown format, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	stores := newTestStorages(t)
+	if _, err := stores["jsonl"].Checkpoint(); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("Expected JSONL to reject checkpoints, got %v", err)
+	}
+
+	s := stores["sqlite"].(*SQLiteStorage)
+	walSize := func() int64 {
+		info, err := os.Stat(s.config.FilePath + "-wal")
+		if err != nil {
+			t.Fatalf("Failed to stat WAL: %v", err)
+		}
+		return info.Size()
+	}
+	if _, err := s.CreateEntities([]Entity{{Name: "A", EntityType: "test", Observations: []string{"a1"}}}); err != nil {
+		t.Fatalf("CreateEntities failed: %v", err)
+	}
+	if walSize() == 0 {
+		t.Fatal("Expected the write to land in the WAL")
+	}
+
+	result, err := s.Checkpoint()
+	if err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	if result.Busy || result.LogFrames != 0 {
+		t.Errorf("Expected the checkpoint to finish and empty the WAL, got %+v", result)
+	}
+	if size := walSize(); size != 0 {
+		t.Errorf("Expected the WAL truncated, got %d bytes", size)
+	}
+
+	// A second Close (as from test cleanup) must not checkpoint the closed database
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+	for range 2 {
+		if err := s.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+	}
+	if logged.Len() != 0 {
+		t.Errorf("Expected a quiet second Close, got %q", logged.String())
+	}
+}
+
+func TestAnalytics(t *testing.T) {
+	entityCount := func(a *GraphAnalytics) string { return fmt.Sprint(a.Analysis["entity_count"]) }
+
+	for name, store := range newTestStorages(t, func(c *Config) { c.AnalyticsCache = true }) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.CreateEntities([]Entity{{Name: "A", EntityType: "test"}, {Name: "B", EntityType: "test"}}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			if _, err := store.CreateRelations([]Relation{{From: "A", To: "B", RelationType: "knows"}}); err != nil {
+				t.Fatalf("CreateRelations failed: %v", err)
+			}
+
+			first, err := store.Analytics()
+			if err != nil {
+				t.Fatalf("Analytics failed: %v", err)
+			}
+			if entityCount(first) != "2" || first.Cached || first.Stale {
+				t.Errorf("Expected freshly computed analytics for 2 entities, got %+v", first)
+			}
+			mostConnected := first.Analysis["most_connected"].([]map[string]interface{})
+			if len(mostConnected) != 2 || mostConnected[0]["connection_count"] != 1 {
+				t.Errorf("Expected both entities among the most connected, got %v", mostConnected)
+			}
+
+			if _, err := store.CreateEntities([]Entity{{Name: "C", EntityType: "test"}}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			second, err := store.Analytics()
+			if err != nil {
+				t.Fatalf("Analytics failed: %v", err)
+			}
+			if name == "sqlite" {
+				if entityCount(second) != "2" || !second.Cached || !second.Stale || second.WritesSince != 1 {
+					t.Errorf("Expected the stale cached copy after a write, got %+v", second)
+				}
+			} else if entityCount(second) != "3" || second.Cached {
+				t.Errorf("Expected JSONL to compute analytics on every call, got %+v", second)
+			}
+
+			refreshed, err := store.RefreshAnalytics()
+			if err != nil {
+				t.Fatalf("RefreshAnalytics failed: %v", err)
+			}
+			if entityCount(refreshed) != "3" || refreshed.Stale {
+				t.Errorf("Expected refreshed analytics for 3 entities, got %+v", refreshed)
+			}
+			if cached, _ := store.Analytics(); entityCount(cached) != "3" || cached.Stale {
+				t.Errorf("Expected the refreshed copy served fresh, got %+v", cached)
+			}
+		})
+	}
+
+	s := newTestStorages(t, func(c *Config) {
+		c.AnalyticsCache = true
+		c.AnalyticsRefreshEvery = 2
+	})["sqlite"]
+	for i, name := range []string{"A", "B"} {
+		if _, err := s.Analytics(); err != nil {
+			t.Fatalf("Analytics failed: %v", err)
+		}
+		if _, err := s.CreateEntities([]Entity{{Name: name, EntityType: "test"}}); err != nil {
+			t.Fatalf("CreateEntities failed: %v", err)
+		}
+		result, err := s.Analytics()
+		if err != nil {
+			t.Fatalf("Analytics failed: %v", err)
+		}
+		if want := []string{"0", "2"}[i]; entityCount(result) != want {
+			t.Errorf("After %d writes expected %s entities, got %+v", i+1, want, result)
+		}
+	}
+}
+
+func TestReadCache(t *testing.T) {
+	s := newTestStorages(t, func(c *Config) { c.ReadCacheSize = 3 })["sqlite"].(*SQLiteStorage)
+	if _, err := s.CreateEntities([]Entity{
+		{Name: "Hub", EntityType: "project", Observations: []string{"central"}},
+		{Name: "Spoke", EntityType: "project"},
+		{Name: "Other", EntityType: "project"},
+	}); err != nil {
+		t.Fatalf("CreateEntities failed: %v", err)
+	}
+	if _, err := s.CreateRelations([]Relation{{From: "Spoke", To: "Hub", RelationType: "depends_on"}}); err != nil {
+		t.Fatalf("CreateRelations failed: %v", err)
+	}
+
+	// Changes made behind the cache's back show which reads it serves
+	retype := func() {
+		if _, err := s.db.Exec("UPDATE entities SET entity_type = 'retyped'"); err != nil {
+			t.Fatalf("Failed to retype entities: %v", err)
+		}
+	}
+	hubType := func() string {
+		graph, err := s.OpenNodes([]string{"Hub"})
+		if err != nil || len(graph.Entities) != 1 {
+			t.Fatalf("OpenNodes failed: %+v (err %v)", graph, err)
+		}
+		return graph.Entities[0].EntityType
+	}
+
+	graph, _ := s.OpenNodes([]string{"Hub"})
+	graph.Entities[0].Observations[0] = "changed by the caller"
+	retype()
+	if got := hubType(); got != "project" {
+		t.Errorf("Expected the cached type, got %q", got)
+	}
+	if graph, _ := s.OpenNodes([]string{"Hub"}); graph.Entities[0].Observations[0] != "central" {
+		t.Errorf("Expected callers not to change cached results, got %v", graph.Entities[0].Observations)
+	}
+
+	// A write to an unrelated entity keeps the entry; one to a neighbor drops it
+	if _, err := s.AddObservations(map[string][]string{"Other": {"unrelated"}}, ""); err != nil {
+		t.Fatalf("AddObservations failed: %v", err)
+	}
+	if got := hubType(); got != "project" {
+		t.Errorf("Expected an unrelated write to keep the entry, got %q", got)
+	}
+	if _, err := s.DeleteEntities([]string{"Spoke"}); err != nil {
+		t.Fatalf("DeleteEntities failed: %v", err)
+	}
+	if got := hubType(); got != "retyped" {
+		t.Errorf("Expected deleting a neighbor to drop the entry, got %q", got)
+	}
+
+	// Any write in the namespace drops cached searches
+	if result, err := s.SearchNodes("fresh", 0); err != nil || len(result.Entities) != 0 {
+		t.Fatalf("Expected no hits yet, got %+v (err %v)", result, err)
+	}
+	if _, err := s.CreateEntities([]Entity{{Name: "Fresh", EntityType: "project"}}); err != nil {
+		t.Fatalf("CreateEntities failed: %v", err)
+	}
+	if result, err := s.SearchNodes("fresh", 0); err != nil || len(result.Entities) != 1 {
+		t.Errorf("Expected the new entity found, got %+v (err %v)", result, err)
+	}
+
+	// The least recently used entry is evicted past the size
+	if _, err := s.db.Exec("UPDATE entities SET entity_type = 'evicted' WHERE name = 'Hub'"); err != nil {
+		t.Fatalf("Failed to retype Hub: %v", err)
+	}
+	for _, name := range []string{"Other", "Fresh", "Missing"} {
+		s.OpenNodes([]string{name})
+	}
+	if got := hubType(); got != "evicted" {
+		t.Errorf("Expected the oldest entry evicted, got %q", got)
+	}
+}
+
+func TestPeriodicCheckpoint(t *testing.T) {
+	s := newTestStorages(t, func(c *Config) { c.CheckpointInterval = 10 * time.Millisecond })["sqlite"].(*SQLiteStorage)
+	if _, err := s.CreateEntities([]Entity{{Name: "A", EntityType: "test"}}); err != nil {
+		t.Fatalf("CreateEntities failed: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		info, err := os.Stat(s.config.FilePath + "-wal")
+		if err != nil {
+			t.Fatalf("Failed to stat WAL: %v", err)
+		}
+		if info.Size() == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected a periodic checkpoint to truncate the WAL, still %d bytes", info.Size())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMirrorJSONL(t *testing.T) {
+	mirror := filepath.Join(t.TempDir(), "mirror.jsonl")
+	s := newTestStorages(t, func(c *Config) {
+		c.MirrorJSONL = mirror
+		c.MirrorDelay = 10 * time.Millisecond
+	})["sqlite"].(*SQLiteStorage)
+	if _, err := s.CreateEntities([]Entity{{Name: "A", EntityType: "test", Observations: []string{"a1"}}}); err != nil {
+		t.Fatalf("CreateEntities failed: %v", err)
+	}
+	if _, err := s.WithNamespace("work").CreateEntities([]Entity{{Name: "W", EntityType: "test"}}); err != nil {
+		t.Fatalf("CreateEntities failed: %v", err)
+	}
+
+	mirrored := func() map[string][]string {
+		set, err := readGraphSet(mirror, nil)
+		if err != nil {
+			return nil
+		}
+		names := map[string][]string{}
+		for ns, graph := range set.graphs {
+			for _, e := range graph.Entities {
+				names[ns] = append(names[ns], e.Name)
+			}
+		}
+		return names
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(mirrored()["work"]) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the mirror to catch up, got %v", mirrored())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if names := mirrored(); !slices.Equal(names[DefaultNamespace], []string{"A"}) {
+		t.Errorf("Expected every namespace mirrored, got %v", names)
+	}
+
+	// Close writes a change still waiting on the delay
+	s.mirror.mu.Lock()
+	s.mirror.delay = time.Hour
+	s.mirror.mu.Unlock()
+	if _, err := s.DeleteEntities([]string{"A"}); err != nil {
+		t.Fatalf("DeleteEntities failed: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if names := mirrored(); len(names[DefaultNamespace]) != 0 {
+		t.Errorf("Expected the pending delete mirrored on close, got %v", names)
+	}
+}
+
+func TestSQLiteReadYourWrites(t *testing.T) {
+	s := newTestStorages(t, func(c *Config) { c.BusyTimeout = 5 * time.Second })["sqlite"].(*SQLiteStorage)
+
+	// Every pooled read connection carries the configured pragmas, not just the
+	// one that happened to be open when Initialize ran
+	ctx := context.Background()
+	var conns []*sql.Conn
+	for i := 0; i < 4; i++ {
+		conn, err := s.dbRead.Conn(ctx)
+		if err != nil {
+			t.Fatalf("Failed to get read connection: %v", err)
+		}
+		conns = append(conns, conn)
+	}
+	for i, conn := range conns {
+		var queryOnly, busyTimeout int
+		if err := conn.QueryRowContext(ctx, "PRAGMA query_only").Scan(&queryOnly); err != nil || queryOnly != 1 {
+			t.Errorf("Read connection %d: expected query_only=1, got %d (%v)", i, queryOnly, err)
+		}
+		if err := conn.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&busyTimeout); err != nil || busyTimeout != 5000 {
+			t.Errorf("Read connection %d: expected busy_timeout=5000, got %d (%v)", i, busyTimeout, err)
+		}
+		conn.Close()
+	}
+
+	// Concurrent callers each read back their own write straight away, as the
+	// server does for create_entities followed by open_nodes
+	var wg sync.WaitGroup
+	errs := make(chan error, 8*10)
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				name := fmt.Sprintf("writer%d-%d", w, i)
+				if _, err := s.CreateEntities([]Entity{{Name: name, EntityType: "test", Observations: []string{"fact " + name}}}); err != nil {
+					errs <- err
+					return
+				}
+				graph, err := s.OpenNodes([]string{name})
+				if err != nil || len(graph.Entities) != 1 || len(graph.Entities[0].Observations) != 1 {
+					errs <- fmt.Errorf("%s not visible after its write: %+v (%v)", name, graph, err)
+					return
+				}
+				if _, err := s.AddObservations(map[string][]string{name: {"second"}}, ""); err != nil {
+					errs <- err
+					return
+				}
+				page, err := s.GetObservations(name, 0, 0)
+				if err != nil || page.Total != 2 {
+					errs <- fmt.Errorf("%s: added observation not visible: %+v (%v)", name, page, err)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestRelationTypeSamples(t *testing.T) {
+	for name, store := range newTestStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.CreateEntities([]Entity{
+				{Name: "Alice", EntityType: "person"},
+				{Name: "Bob", EntityType: "person"},
+				{Name: "Carol", EntityType: "person"},
+				{Name: "Spec", EntityType: "document"},
+			}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			if _, err := store.CreateRelations([]Relation{
+				{From: "Alice", To: "Bob", RelationType: "manages"},
+				{From: "Alice", To: "Carol", RelationType: "manages"},
+				{From: "Spec", To: "Spec", RelationType: "manages"},
+				{From: "Bob", To: "Spec", RelationType: "wrote"},
+			}); err != nil {
+				t.Fatalf("CreateRelations failed: %v", err)
+			}
+
+			samples, err := store.RelationTypeSamples(2)
+			if err != nil {
+				t.Fatalf("RelationTypeSamples failed: %v", err)
+			}
+			if len(samples) != 2 {
+				t.Fatalf("Expected samples for 2 relation types, got %+v", samples)
+			}
+			manages := samples["manages"]
+			if len(manages) != 2 || manages[0].To != "Bob" || manages[1].To != "Carol" {
+				t.Errorf("Expected the 2 oldest manages relations, got %+v", manages)
+			}
+			if wrote := samples["wrote"]; len(wrote) != 1 || wrote[0].From != "Bob" {
+				t.Errorf("Expected the single wrote relation, got %+v", wrote)
+			}
+
+			if samples, _ := store.RelationTypeSamples(0); len(samples["manages"]) != DefaultRelationSamples {
+				t.Errorf("Expected %d manages examples by default, got %+v", DefaultRelationSamples, samples["manages"])
+			}
+			if _, err := store.RelationTypeSamples(MaxRelationSamples + 1); !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("Expected ErrInvalidArgument for too large a limit, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRelationsAmong(t *testing.T) {
+	for name, store := range newTestStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.CreateEntities([]Entity{
+				{Name: "Alice", EntityType: "person"},
+				{Name: "Bob", EntityType: "person"},
+				{Name: "Carol", EntityType: "person"},
+			}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			if _, err := store.CreateRelations([]Relation{
+				{From: "Alice", To: "Bob", RelationType: "knows"},
+				{From: "Bob", To: "Carol", RelationType: "knows"},
+				{From: "Carol", To: "Alice", RelationType: "knows"},
+			}); err != nil {
+				t.Fatalf("CreateRelations failed: %v", err)
+			}
+
+			relations, err := store.RelationsAmong([]string{"Alice", "Bob", "Alice"}, time.Time{})
+			if err != nil || len(relations) != 1 || relations[0].From != "Alice" || relations[0].To != "Bob" {
+				t.Errorf("Expected only Alice->Bob, got %+v (%v)", relations, err)
+			}
+
+			sqlite, ok := store.(*SQLiteStorage)
+			if !ok {
+				if _, err := store.RelationsAmong([]string{"Alice"}, time.Now()); !errors.Is(err, ErrInvalidArgument) {
+					t.Errorf("Expected asOf rejected on JSONL, got %v", err)
+				}
+				return
+			}
+			// A relation created after asOf is not visible then
+			if _, err := sqlite.db.Exec("UPDATE relations SET created_at = '2020-01-01 00:00:00'"); err != nil {
+				t.Fatalf("Failed to backdate relations: %v", err)
+			}
+			if _, err := store.CreateRelations([]Relation{{From: "Bob", To: "Alice", RelationType: "knows"}}); err != nil {
+				t.Fatalf("CreateRelations failed: %v", err)
+			}
+			asOf := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+			if relations, err := store.RelationsAmong([]string{"Alice", "Bob"}, asOf); err != nil || len(relations) != 1 || relations[0].From != "Alice" {
+				t.Errorf("Expected only Alice->Bob as of 2021, got %+v (%v)", relations, err)
+			}
+			if relations, err := store.RelationsAmong([]string{"Alice", "Bob"}, time.Time{}); err != nil || len(relations) != 2 {
+				t.Errorf("Expected both relations now, got %+v (%v)", relations, err)
+			}
+		})
+	}
+}
+
+func TestSelfRelations(t *testing.T) {
+	for name, store := range newTestStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.CreateEntities([]Entity{
+				{Name: "Alice", EntityType: "person"},
+				{Name: "Bob", EntityType: "person"},
+			}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			if _, err := store.CreateRelations([]Relation{
+				{From: "Bob", To: "Bob", RelationType: "knows"},
+				{From: "Alice", To: "Bob", RelationType: "knows"},
+				{From: "Alice", To: "Alice", RelationType: "manages"},
+			}); err != nil {
+				t.Fatalf("CreateRelations failed: %v", err)
+			}
+
+			relations, err := store.SelfRelations()
+			if err != nil {
+				t.Fatalf("SelfRelations failed: %v", err)
+			}
+			var got []string
+			for _, r := range relations {
+				got = append(got, r.From+"->"+r.To+":"+r.RelationType)
+			}
+			if want := []string{"Alice->Alice:manages", "Bob->Bob:knows"}; !slices.Equal(got, want) {
+				t.Errorf("Expected %v, got %v", want, got)
+			}
+
+			if _, err := store.DeleteRelations(relations); err != nil {
+				t.Fatalf("DeleteRelations failed: %v", err)
+			}
+			if relations, err := store.SelfRelations(); err != nil || len(relations) != 0 {
+				t.Errorf("Expected no self relations after deleting them, got %v (%v)", relations, err)
+			}
+			if exists, err := store.RelationExists("Alice", "Bob", "knows"); err != nil || !exists {
+				t.Errorf("Expected the other relation to remain (%v)", err)
+			}
+		})
+	}
+}
+
+func TestDeleteObservationsMatching(t *testing.T) {
+	for name, store := range newTestStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.CreateEntities([]Entity{
+				{Name: "Alice", EntityType: "person", Observations: []string{"works at Acme", "likes tea", "lives in Paris", "Acme alumni"}},
+				{Name: "Bob", EntityType: "person", Observations: []string{"manager"}},
+			}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+
+			if _, err := store.DeleteObservations([]ObservationDeletion{{EntityName: "Alice", Contains: []string{""}}}); !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("Expected an empty substring to be rejected, got %v", err)
+			}
+
+			deleted, err := store.DeleteObservations([]ObservationDeletion{
+				{EntityName: "Alice", Contains: []string{"Acme"}, Indexes: []int{2, 99}},
+				{EntityName: "Bob", Observations: []string{"reworded manager"}},
+				{EntityName: "Ghost", Observations: []string{"anything"}},
+			})
+			if err != nil {
+				t.Fatalf("DeleteObservations failed: %v", err)
+			}
+			if want := map[string]int{"Alice": 3, "Bob": 0, "Ghost": 0}; !maps.Equal(deleted, want) {
+				t.Errorf("Expected counts %v, got %v", want, deleted)
+			}
+
+			graph, err := store.OpenNodes([]string{"Alice", "Bob"})
+			if err != nil {
+				t.Fatalf("OpenNodes failed: %v", err)
+			}
+			for _, entity := range graph.Entities {
+				switch entity.Name {
+				case "Alice":
+					if !slices.Equal(entity.Observations, []string{"likes tea"}) {
+						t.Errorf("Expected only likes tea left, got %v", entity.Observations)
+					}
+				case "Bob":
+					if len(entity.Observations) != 1 {
+						t.Errorf("Expected Bob untouched, got %v", entity.Observations)
+					}
+				}
+			}
+
+			// Exact matches still work and are counted
+			if deleted, err := store.DeleteObservations([]ObservationDeletion{{EntityName: "Bob", Observations: []string{"manager"}}}); err != nil || deleted["Bob"] != 1 {
+				t.Errorf("Expected 1 exact deletion, got %v (%v)", deleted, err)
+			}
+		})
+	}
+}
+
+func TestEmptyEntities(t *testing.T) {
+	for name, store := range newTestStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.CreateEntities([]Entity{
+				{Name: "Zed", EntityType: "stub"},
+				{Name: "Alice", EntityType: "person", Observations: []string{"engineer"}},
+				{Name: "Acme", EntityType: "company"},
+			}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			if _, err := store.CreateRelations([]Relation{{From: "Alice", To: "Acme", RelationType: "works_at"}}); err != nil {
+				t.Fatalf("CreateRelations failed: %v", err)
+			}
+
+			entities, err := store.EmptyEntities()
+			if err != nil {
+				t.Fatalf("EmptyEntities failed: %v", err)
+			}
+			want := []EntitySummary{{Name: "Acme", EntityType: "company"}, {Name: "Zed", EntityType: "stub"}}
+			if !slices.Equal(entities, want) {
+				t.Errorf("Expected %v, got %v", want, entities)
+			}
+
+			if _, err := store.AddObservations(map[string][]string{"Acme": {"founded 1999"}}, ""); err != nil {
+				t.Fatalf("AddObservations failed: %v", err)
+			}
+			if entities, err := store.EmptyEntities(); err != nil || len(entities) != 1 || entities[0].Name != "Zed" {
+				t.Errorf("Expected only Zed left empty, got %v (%v)", entities, err)
+			}
+		})
+	}
+}
+
+func TestValidateJSONL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.jsonl")
+	content := strings.Join([]string{
+		`{"type":"entity","name":"Alice","entityType":"person","observations":["a"]}`,
+		`{"type":"entity","name":"Bob",`,
+		``,
+		`{"name":"NoType"}`,
+		`{"type":"relation","from":"Alice","to":"Alice","relationType":"knows"}`,
+		`{"type":"widget"}`,
+		`{"type":"entity","name":"Carol","entityType":"person","observations":"not a list"}`,
+	}, "\n") + "\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	report, err := ValidateJSONL(path)
+	if err != nil {
+		t.Fatalf("ValidateJSONL failed: %v", err)
+	}
+	if report.Valid || report.Lines != 7 || report.Entities != 1 || report.Relations != 1 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+	var lines []int
+	for _, e := range report.Errors {
+		lines = append(lines, e.Line)
+	}
+	if want := []int{2, 4, 6, 7}; !slices.Equal(lines, want) {
+		t.Errorf("Expected errors on lines %v, got %+v", want, report.Errors)
+	}
+
+	// Loading skips the same lines and reports them through StorageInfo
+	s, err := NewJSONLStorage(Config{FilePath: path})
+	if err != nil {
+		t.Fatalf("NewJSONLStorage failed: %v", err)
+	}
+	if err := s.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	info, err := s.StorageInfo()
+	if err != nil {
+		t.Fatalf("StorageInfo failed: %v", err)
+	}
+	if !slices.Equal(info.LoadWarnings, report.Errors) {
+		t.Errorf("Expected load warnings %+v, got %+v", report.Errors, info.LoadWarnings)
+	}
+}
+
+func TestSetEntityType(t *testing.T) {
+	for name, store := range newTestStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.CreateEntities([]Entity{{Name: "Acme", EntityType: "organisation", Observations: []string{"founded 2010", "tech"}}}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+
+			entity, err := store.SetEntityType("Acme", "organization")
+			if err != nil {
+				t.Fatalf("SetEntityType failed: %v", err)
+			}
+			if entity.EntityType != "organization" || !slices.Equal(entity.Observations, []string{"founded 2010", "tech"}) {
+				t.Errorf("Expected the new type with observations untouched, got %+v", entity)
+			}
+			if graph, err := store.ReadGraph("full", 0, TypeFilter{}); err != nil || graph.(*KnowledgeGraph).Entities[0].EntityType != "organization" {
+				t.Errorf("Expected the new type stored, got %+v (%v)", graph, err)
+			}
+			if sqlite, ok := store.(*SQLiteStorage); ok {
+				var accessCount int
+				if err := sqlite.db.QueryRow("SELECT access_count FROM entities WHERE name = 'Acme'").Scan(&accessCount); err != nil || accessCount != 0 {
+					t.Errorf("Expected no access recorded, got %d (%v)", accessCount, err)
+				}
+			}
+
+			if _, err := store.SetEntityType("Missing", "x"); !errors.Is(err, ErrEntityNotFound) {
+				t.Errorf("Expected ErrEntityNotFound for a missing entity, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMergeRelationTypes(t *testing.T) {
+	for name, store := range newTestStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.CreateEntities([]Entity{
+				{Name: "Alice", EntityType: "person"},
+				{Name: "Bob", EntityType: "person"},
+				{Name: "Acme", EntityType: "company"},
+			}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			if _, err := store.CreateRelations([]Relation{
+				{From: "Alice", To: "Acme", RelationType: "works_at"},
+				{From: "Alice", To: "Acme", RelationType: "worksAt"},
+				{From: "Alice", To: "Acme", RelationType: "employed_by"},
+				{From: "Bob", To: "Acme", RelationType: "worksAt"},
+				{From: "Alice", To: "Bob", RelationType: "knows"},
+			}); err != nil {
+				t.Fatalf("CreateRelations failed: %v", err)
+			}
+
+			changed, err := store.MergeRelationTypes("works_at", []string{"worksAt", "employed_by", "works_at", "missing"})
+			if err != nil {
+				t.Fatalf("MergeRelationTypes failed: %v", err)
+			}
+			if changed != 3 {
+				t.Errorf("Expected 3 relations changed, got %d", changed)
+			}
+
+			page, err := store.ListRelations(0, 0)
+			if err != nil {
+				t.Fatalf("ListRelations failed: %v", err)
+			}
+			var got []string
+			for _, r := range page.Relations {
+				got = append(got, r.From+" "+r.RelationType+" "+r.To)
+			}
+			slices.Sort(got)
+			want := []string{"Alice knows Bob", "Alice works_at Acme", "Bob works_at Acme"}
+			if !slices.Equal(got, want) {
+				t.Errorf("Expected %v, got %v", want, got)
+			}
+
+			if changed, err := store.MergeRelationTypes("works_at", []string{"worksAt"}); err != nil || changed != 0 {
+				t.Errorf("Expected nothing left to merge, got %d (err %v)", changed, err)
+			}
+			if _, err := store.MergeRelationTypes("works_at", []string{"works_at", ""}); !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("Expected ErrInvalidArgument without aliases, got %v", err)
+			}
+			if _, err := store.MergeRelationTypes("", []string{"knows"}); !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("Expected ErrInvalidArgument without a canonical type, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTypeDescriptions(t *testing.T) {
+	stores := newTestStorages(t)
+	if err := stores["jsonl"].SetTypeDescription(TypeKindEntity, "person", "an individual human"); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("Expected JSONL to reject type descriptions, got %v", err)
+	}
+
+	s := stores["sqlite"]
+	for _, d := range []TypeDescription{
+		{Kind: TypeKindRelation, Type: "works_at", Description: "employment"},
+		{Kind: TypeKindEntity, Type: "person", Description: "a human"},
+		{Kind: TypeKindEntity, Type: "person", Description: "an individual human"},
+		{Kind: TypeKindEntity, Type: "company", Description: "a business"},
+	} {
+		if err := s.SetTypeDescription(d.Kind, d.Type, d.Description); err != nil {
+			t.Fatalf("SetTypeDescription failed: %v", err)
+		}
+	}
+	if err := s.WithNamespace("other").SetTypeDescription(TypeKindEntity, "robot", "a machine"); err != nil {
+		t.Fatalf("SetTypeDescription failed: %v", err)
+	}
+
+	descriptions, err := s.TypeDescriptions("")
+	if err != nil {
+		t.Fatalf("TypeDescriptions failed: %v", err)
+	}
+	var got []string
+	for _, d := range descriptions {
+		got = append(got, d.Kind+" "+d.Type+": "+d.Description)
+		if d.UpdatedAt.IsZero() {
+			t.Errorf("Expected an update time on %+v", d)
+		}
+	}
+	want := []string{"entity company: a business", "entity person: an individual human", "relation works_at: employment"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if relations, err := s.TypeDescriptions(TypeKindRelation); err != nil || len(relations) != 1 {
+		t.Errorf("Expected only the relation type, got %+v (err %v)", relations, err)
+	}
+
+	summary, err := s.ReadGraph("summary", 0, TypeFilter{})
+	if err != nil {
+		t.Fatalf("ReadGraph failed: %v", err)
+	}
+	if n := len(summary.(*GraphSummary).TypeDescriptions); n != 3 {
+		t.Errorf("Expected the summary to include 3 type descriptions, got %d", n)
+	}
+
+	if err := s.SetTypeDescription(TypeKindEntity, "company", ""); err != nil {
+		t.Fatalf("SetTypeDescription failed: %v", err)
+	}
+	if entities, _ := s.TypeDescriptions(TypeKindEntity); len(entities) != 1 || entities[0].Type != "person" {
+		t.Errorf("Expected an empty description to remove the type's, got %+v", entities)
+	}
+	if err := s.SetTypeDescription("attribute", "color", "x"); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("Expected ErrInvalidArgument for an unknown kind, got %v", err)
+	}
+}
+
+func TestUpdateEntity(t *testing.T) {
+	for name, store := range newTestStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.CreateEntities([]Entity{
+				{Name: "Jonathan", EntityType: "company", Observations: []string{"lives in Paris"}},
+				{Name: "Acme", EntityType: "company"},
+			}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			if _, err := store.CreateRelations([]Relation{
+				{From: "Jonathan", To: "Acme", RelationType: "works_at"},
+				{From: "Acme", To: "Jonathan", RelationType: "employs"},
+			}); err != nil {
+				t.Fatalf("CreateRelations failed: %v", err)
+			}
+
+			entity, err := store.UpdateEntity("Jonathan", "Zed", "person", nil)
+			if err != nil {
+				t.Fatalf("UpdateEntity failed: %v", err)
+			}
+			if entity.Name != "Zed" || entity.EntityType != "person" || !slices.Equal(entity.Observations, []string{"lives in Paris"}) {
+				t.Errorf("Expected the renamed, retyped entity with its observations, got %+v", entity)
+			}
+
+			graph, err := store.OpenNodes([]string{"Zed", "Acme"})
+			if err != nil {
+				t.Fatalf("OpenNodes failed: %v", err)
+			}
+			if len(graph.Relations) != 2 {
+				t.Errorf("Expected both relations to follow the rename, got %v", graph.Relations)
+			}
+			if result, err := store.SearchNodes("Jonathan", 0); err != nil || len(result.Entities) != 0 {
+				t.Errorf("Expected no hits for the old name, got %v (err %v)", result, err)
+			}
+			if result, err := store.SearchNodes("Paris", 0); err != nil || len(result.Entities) != 1 || result.Entities[0].Name != "Zed" {
+				t.Errorf("Expected the observation to be found under the new name, got %v (err %v)", result, err)
+			}
+
+			// Empty fields are left as they are
+			if entity, err = store.UpdateEntity("Acme", "", "organization", nil); err != nil || entity.Name != "Acme" || entity.EntityType != "organization" {
+				t.Errorf("Expected a type-only update, got %+v (err %v)", entity, err)
+			}
+			if entity, err = store.UpdateEntity("Acme", "Acme Corp", "", nil); err != nil || entity.Name != "Acme Corp" || entity.EntityType != "organization" {
+				t.Errorf("Expected a name-only update, got %+v (err %v)", entity, err)
+			}
+
+			if _, err := store.UpdateEntity("Zed", "Acme Corp", "robot", nil); !errors.Is(err, ErrConflict) {
+				t.Errorf("Expected ErrConflict renaming onto an existing name, got %v", err)
+			}
+			if entity, _ := store.OpenNodes([]string{"Zed"}); len(entity.Entities) != 1 || entity.Entities[0].EntityType != "person" {
+				t.Errorf("Expected a failed update to change nothing, got %+v", entity)
+			}
+			if _, err := store.UpdateEntity("Missing", "x", "", nil); !errors.Is(err, ErrEntityNotFound) {
+				t.Errorf("Expected ErrEntityNotFound for a missing entity, got %v", err)
+			}
+			if _, err := store.UpdateEntity("Zed", "", "", nil); !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("Expected ErrInvalidArgument with nothing to change, got %v", err)
+			}
+		})
+	}
+}
+
+func TestEntityDescription(t *testing.T) {
+	for name, store := range newTestStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.CreateEntities([]Entity{
+				{Name: "Alice", EntityType: "person", Description: "Backend lead on the payments team", Observations: []string{"likes tea"}},
+				{Name: "Bob", EntityType: "person"},
+			}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+
+			graph, err := store.OpenNodes([]string{"Alice"})
+			if err != nil || len(graph.Entities) != 1 || graph.Entities[0].Description != "Backend lead on the payments team" {
+				t.Fatalf("Expected the description back from OpenNodes, got %+v (err %v)", graph, err)
+			}
+			if !slices.Equal(graph.Entities[0].Observations, []string{"likes tea"}) {
+				t.Errorf("Expected the description kept apart from observations, got %v", graph.Entities[0].Observations)
+			}
+
+			result, err := store.SearchNodes("payments", 0)
+			if err != nil || len(result.Entities) != 1 || result.Entities[0].Name != "Alice" || result.Entities[0].Description == "" {
+				t.Errorf("Expected search to match the description, got %+v (err %v)", result, err)
+			}
+
+			// An empty description on create leaves the stored one; keep only fills a missing one
+			if _, err := store.CreateEntities([]Entity{{Name: "Alice", EntityType: "person"}}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			if _, err := store.CreateEntitiesWithStrategy([]Entity{
+				{Name: "Alice", EntityType: "person", Description: "ignored"},
+				{Name: "Bob", EntityType: "person", Description: "Designer"},
+			}, MergeKeep); err != nil {
+				t.Fatalf("CreateEntitiesWithStrategy failed: %v", err)
+			}
+			graph, _ = store.OpenNodes([]string{"Alice", "Bob"})
+			descriptions := map[string]string{}
+			for _, e := range graph.Entities {
+				descriptions[e.Name] = e.Description
+			}
+			if descriptions["Alice"] != "Backend lead on the payments team" || descriptions["Bob"] != "Designer" {
+				t.Errorf("Unexpected descriptions after merging, got %v", descriptions)
+			}
+
+			description := "Engineering manager"
+			entity, err := store.UpdateEntity("Alice", "", "", &description)
+			if err != nil || entity.Description != description || entity.EntityType != "person" {
+				t.Errorf("Expected a description-only update, got %+v (err %v)", entity, err)
+			}
+			if result, _ := store.SearchNodes("payments", 0); len(result.Entities) != 0 {
+				t.Errorf("Expected the old description no longer to match, got %+v", result.Entities)
+			}
+			empty := ""
+			if entity, err = store.UpdateEntity("Alice", "", "", &empty); err != nil || entity.Description != "" {
+				t.Errorf("Expected an empty description to clear it, got %+v (err %v)", entity, err)
+			}
+
+			full, err := store.ReadGraph("full", 0, TypeFilter{})
+			if err != nil {
+				t.Fatalf("ReadGraph failed: %v", err)
+			}
+			for _, e := range full.(*KnowledgeGraph).Entities {
+				if e.Name == "Bob" && e.Description != "Designer" {
+					t.Errorf("Expected ReadGraph to return the description, got %+v", e)
+				}
+			}
+		})
+	}
+}
+
+func TestExposeIDsLargeGraph(t *testing.T) {
+	store := newTestStorages(t, func(c *Config) { c.ExposeIDs = true })["sqlite"]
+
+	// Entity names plus relation endpoints bind over 32k names, more than one
+	// SQLite statement takes
+	entities := make([]Entity, 12000)
+	for i := range entities {
+		entities[i] = Entity{Name: fmt.Sprintf("E%05d", i), EntityType: "node"}
+	}
+	if _, err := store.CreateEntities(entities); err != nil {
+		t.Fatalf("CreateEntities failed: %v", err)
+	}
+	relations := make([]Relation, 11000)
+	for i := range relations {
+		relations[i] = Relation{From: entities[i].Name, To: entities[i+1].Name, RelationType: "next"}
+	}
+	if _, err := store.CreateRelations(relations); err != nil {
+		t.Fatalf("CreateRelations failed: %v", err)
+	}
+
+	result, err := store.ReadGraph("full", 0, TypeFilter{})
+	if err != nil {
+		t.Fatalf("ReadGraph failed: %v", err)
+	}
+	graph := result.(*KnowledgeGraph)
+	if len(graph.Entities) != len(entities) || len(graph.Relations) != len(relations) {
+		t.Fatalf("Expected the whole graph, got %d entities and %d relations", len(graph.Entities), len(graph.Relations))
+	}
+	for _, r := range graph.Relations {
+		if r.ID == 0 || r.FromID == 0 || r.ToID == 0 {
+			t.Fatalf("Expected every relation to get IDs, got %+v", r)
+		}
+	}
+	for _, e := range graph.Entities {
+		if e.ID == 0 {
+			t.Fatalf("Expected every entity to get an ID, got %+v", e)
+		}
+	}
+}
+
+func TestExposeIDs(t *testing.T) {
+	for name, store := range newTestStorages(t, func(c *Config) { c.ExposeIDs = true }) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.CreateEntities([]Entity{
+				{Name: "Alice", EntityType: "person", Observations: []string{"engineer"}},
+				{Name: "Acme", EntityType: "company"},
+			}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			if _, err := store.CreateRelations([]Relation{{From: "Alice", To: "Acme", RelationType: "works_at"}}); err != nil {
+				t.Fatalf("CreateRelations failed: %v", err)
+			}
+
+			graph, err := store.OpenNodes([]string{"Alice", "Acme"})
+			if err != nil {
+				t.Fatalf("OpenNodes failed: %v", err)
+			}
+			ids := map[string]int64{}
+			for _, e := range graph.Entities {
+				if e.ID == 0 {
+					t.Errorf("Expected an ID on %s", e.Name)
+				}
+				ids[e.Name] = e.ID
+			}
+			if ids["Alice"] == ids["Acme"] {
+				t.Errorf("Expected distinct entity IDs, got %v", ids)
+			}
+			if len(graph.Relations) != 1 {
+				t.Fatalf("Expected 1 relation, got %v", graph.Relations)
+			}
+			if r := graph.Relations[0]; r.ID == 0 || r.FromID != ids["Alice"] || r.ToID != ids["Acme"] {
+				t.Errorf("Expected relation IDs matching its endpoints %v, got %+v", ids, r)
+			}
+
+			full, err := store.ReadGraph("full", 0, TypeFilter{})
+			if err != nil {
+				t.Fatalf("ReadGraph failed: %v", err)
+			}
+			for _, e := range full.(*KnowledgeGraph).Entities {
+				if e.ID != ids[e.Name] {
+					t.Errorf("read_graph: expected %s to keep ID %d, got %d", e.Name, ids[e.Name], e.ID)
+				}
+			}
+			summary, err := store.ReadGraph("summary", 10, TypeFilter{})
+			if err != nil {
+				t.Fatalf("ReadGraph failed: %v", err)
+			}
+			for _, e := range summary.(*GraphSummary).Entities {
+				if e.ID != ids[e.Name] {
+					t.Errorf("summary: expected %s to keep ID %d, got %d", e.Name, ids[e.Name], e.ID)
+				}
+			}
+			result, err := store.SearchNodes("engineer", 10)
+			if err != nil {
+				t.Fatalf("SearchNodes failed: %v", err)
+			}
+			if len(result.Entities) != 1 || result.Entities[0].ID != ids["Alice"] {
+				t.Errorf("search: expected Alice with ID %d, got %+v", ids["Alice"], result.Entities)
+			}
+		})
+	}
+
+	// IDs stay out of results unless enabled
+	for name, store := range newTestStorages(t) {
+		if _, err := store.CreateEntities([]Entity{{Name: "Alice", EntityType: "person"}}); err != nil {
+			t.Fatalf("%s: CreateEntities failed: %v", name, err)
+		}
+		graph, err := store.OpenNodes([]string{"Alice"})
+		if err != nil || graph.Entities[0].ID != 0 {
+			t.Errorf("%s: expected no ID by default, got %+v (%v)", name, graph.Entities, err)
+		}
+	}
+}
+
+func TestFileMode(t *testing.T) {
+	stores := newTestStorages(t, func(c *Config) { c.FileMode = 0600 })
+	assertMode := func(path string) {
+		t.Helper()
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", path, err)
+		}
+		if mode := info.Mode().Perm(); mode != 0600 {
+			t.Errorf("Expected %s to have mode 0600, got %v", filepath.Base(path), mode)
+		}
+	}
+
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			if _, err := s.CreateEntities([]Entity{{Name: "A", EntityType: "test", Observations: []string{"a1"}}}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			path := fileModeConfigPath(s)
+			assertMode(path)
+			if name == "sqlite" {
+				assertMode(path + "-wal")
+			}
+
+			backup, err := BackupGraph(s, t.TempDir(), 0)
+			if err != nil {
+				t.Fatalf("BackupGraph failed: %v", err)
+			}
+			assertMode(backup)
+		})
+	}
+
+	// An existing file is tightened on start
+	path := filepath.Join(t.TempDir(), "open.jsonl")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	s, err := NewJSONLStorage(Config{FilePath: path, FileMode: 0600})
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := s.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	assertMode(path)
+}
+
+// fileModeConfigPath returns the file a test backend stores its graph in
+func fileModeConfigPath(s Storage) string {
+	switch s := s.(type) {
+	case *JSONLStorage:
+		return s.config.FilePath
+	case *SQLiteStorage:
+		return s.config.FilePath
+	}
+	return ""
+}
+
+func TestEncryption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.jsonl")
+	open := func(key string) (*JSONLStorage, error) {
+		t.Helper()
+		s, err := NewJSONLStorage(Config{FilePath: path, EncryptionKey: key})
+		if err != nil {
+			t.Fatalf("Failed to create storage: %v", err)
+		}
+		return s, s.Initialize()
+	}
+	names := func(s Storage) []string {
+		t.Helper()
+		graph, err := s.ExportData()
+		if err != nil {
+			t.Fatalf("ExportData failed: %v", err)
+		}
+		var names []string
+		for _, e := range graph.Entities {
+			names = append(names, e.Name)
+		}
+		return names
+	}
+
+	// Turning encryption on encrypts an existing plaintext file at startup
+	if err := os.WriteFile(path, []byte(`{"type":"entity","name":"Plain","entityType":"test","observations":["secret fact"]}`+"\n"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	s, err := open("correct horse")
+	if err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if _, err := s.CreateEntities([]Entity{{Name: "A", EntityType: "test", Observations: []string{"a1"}}}); err != nil {
+		t.Fatalf("CreateEntities failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if !isEncrypted(data) || bytes.Contains(data, []byte("secret fact")) {
+		t.Fatalf("Expected the file encrypted, got %q", data)
+	}
+
+	if s, err = open("correct horse"); err != nil {
+		t.Fatalf("Reopening with the key failed: %v", err)
+	}
+	if got := names(s); !slices.Equal(got, []string{"Plain", "A"}) {
+		t.Errorf("Expected both entities back, got %v", got)
+	}
+	backup, err := BackupGraph(s, t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("BackupGraph failed: %v", err)
+	}
+	if data, _ := os.ReadFile(backup); !isEncrypted(data) {
+		t.Error("Expected the backup of an encrypted store to be encrypted")
+	}
+
+	for _, key := range []string{"", "wrong"} {
+		if _, err := open(key); !errors.Is(err, ErrEncryptionKey) {
+			t.Errorf("Key %q: expected ErrEncryptionKey, got %v", key, err)
+		}
+	}
+
+	// Rotation leaves only the new key working; an empty one decrypts
+	if err := RotateEncryptionKey(path, "wrong", "new key"); !errors.Is(err, ErrEncryptionKey) {
+		t.Errorf("Expected rotating from a wrong key to fail, got %v", err)
+	}
+	if err := RotateEncryptionKey(path, "correct horse", "new key"); err != nil {
+		t.Fatalf("RotateEncryptionKey failed: %v", err)
+	}
+	if _, err := open("correct horse"); !errors.Is(err, ErrEncryptionKey) {
+		t.Errorf("Expected the old key rejected after rotation, got %v", err)
+	}
+	if s, err = open("new key"); err != nil || len(names(s)) != 2 {
+		t.Errorf("Expected the new key to open the file, got %v", err)
+	}
+	if err := RotateEncryptionKey(path, "new key", ""); err != nil {
+		t.Fatalf("RotateEncryptionKey to plaintext failed: %v", err)
+	}
+	if data, _ := os.ReadFile(path); !bytes.Contains(data, []byte("secret fact")) {
+		t.Errorf("Expected a plaintext file, got %q", data)
+	}
+
+	if _, err := NewSQLiteStorage(Config{FilePath: filepath.Join(t.TempDir(), "memory.db"), EncryptionKey: "key"}); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("Expected SQLite to reject an encryption key, got %v", err)
+	}
+}
+
+func TestEncryptedGzipWithoutSuffix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.jsonl")
+	var gzipped bytes.Buffer
+	zw := gzip.NewWriter(&gzipped)
+	zw.Write([]byte(`{"type":"entity","name":"A","entityType":"test","observations":["a1"]}` + "\n"))
+	zw.Close()
+	if err := os.WriteFile(path, gzipped.Bytes(), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	// The file is encrypted at startup, so from the second save on its header
+	// no longer shows the gzip content underneath
+	s, err := NewJSONLStorage(Config{FilePath: path, EncryptionKey: "key"})
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := s.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	for _, name := range []string{"B", "C"} {
+		if _, err := s.CreateEntities([]Entity{{Name: name, EntityType: "test"}}); err != nil {
+			t.Fatalf("CreateEntities failed: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if !isEncrypted(data) {
+		t.Fatalf("Expected the file encrypted, got %q", data)
+	}
+	if data, err = newFileCipher("key").open(data); err != nil || !bytes.HasPrefix(data, gzipMagic) {
+		t.Errorf("Expected gzip content under the encryption after two saves (%v)", err)
+	}
+	if graph, err := s.ExportData(); err != nil || len(graph.Entities) != 3 {
+		t.Errorf("Expected all three entities back, got %+v (%v)", graph, err)
+	}
+}
+
+func TestMirrorGzipWithoutSuffix(t *testing.T) {
+	mirror := filepath.Join(t.TempDir(), "mirror.jsonl")
+	var gzipped bytes.Buffer
+	zw := gzip.NewWriter(&gzipped)
+	zw.Close()
+	if err := os.WriteFile(mirror, gzipped.Bytes(), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	// The mirror exports a fresh graph set on every write, so only the existing
+	// file's content says it is compressed
+	s := newTestStorages(t, func(c *Config) {
+		c.MirrorJSONL = mirror
+		c.MirrorDelay = time.Hour
+	})["sqlite"].(*SQLiteStorage)
+	if _, err := s.CreateEntities([]Entity{{Name: "A", EntityType: "test"}}); err != nil {
+		t.Fatalf("CreateEntities failed: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if data, err := os.ReadFile(mirror); err != nil || !bytes.HasPrefix(data, gzipMagic) {
+		t.Errorf("Expected the mirror to stay gzip (%v)", err)
+	}
+	if graph, err := LoadGraphFile(mirror); err != nil || len(graph.Entities) != 1 {
+		t.Errorf("Expected the mirrored entity back, got %+v (%v)", graph, err)
+	}
+}
+
+func TestDeleteByQuery(t *testing.T) {
+	for name, s := range newTestStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := s.CreateEntities([]Entity{
+				{Name: "OldProject", EntityType: "project", Observations: []string{"deprecated in 2020"}},
+				{Name: "Legacy", EntityType: "project", Observations: []string{"also deprecated"}},
+				{Name: "Note", EntityType: "note", Observations: []string{"deprecated API list"}},
+				{Name: "Keep", EntityType: "project", Observations: []string{"active"}},
+			}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			if _, err := s.CreateRelations([]Relation{{From: "Keep", To: "Legacy", RelationType: "replaces"}}); err != nil {
+				t.Fatalf("CreateRelations failed: %v", err)
+			}
+
+			if _, err := DeleteByQuery(s, " ", "", false); !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("Expected a query or type to be required, got %v", err)
+			}
+
+			preview, err := DeleteByQuery(s, "deprecated", "project", true)
+			if err != nil {
+				t.Fatalf("DeleteByQuery dry run failed: %v", err)
+			}
+			if !preview.DryRun || !slices.Equal(preview.Names, []string{"Legacy", "OldProject"}) {
+				t.Errorf("Expected the deprecated projects listed, got %+v", preview)
+			}
+			if graph, _ := s.ExportData(); len(graph.Entities) != 4 {
+				t.Fatalf("Expected a dry run to delete nothing, got %d entities", len(graph.Entities))
+			}
+
+			result, err := DeleteByQuery(s, "deprecated", "project", false)
+			if err != nil {
+				t.Fatalf("DeleteByQuery failed: %v", err)
+			}
+			if result.Deleted != 2 {
+				t.Errorf("Expected 2 deleted, got %+v", result)
+			}
+			graph, err := s.ExportData()
+			if err != nil {
+				t.Fatalf("ExportData failed: %v", err)
+			}
+			if len(graph.Entities) != 2 || len(graph.Relations) != 0 {
+				t.Errorf("Expected Note and Keep left without relations, got %+v", graph)
+			}
+
+			// A type alone deletes every entity of it
+			if result, err := DeleteByQuery(s, "", "note", false); err != nil || !slices.Equal(result.Names, []string{"Note"}) {
+				t.Errorf("Expected Note deleted by type, got %+v (%v)", result, err)
+			}
+		})
+	}
+}
+
+func TestDeleteCounts(t *testing.T) {
+	for name, s := range newTestStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := s.CreateEntities([]Entity{
+				{Name: "Alice", EntityType: "person"},
+				{Name: "Bob", EntityType: "person"},
+				{Name: "Carol", EntityType: "person"},
+			}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			if _, err := s.CreateRelations([]Relation{
+				{From: "Alice", To: "Bob", RelationType: "knows"},
+				{From: "Bob", To: "Carol", RelationType: "knows"},
+			}); err != nil {
+				t.Fatalf("CreateRelations failed: %v", err)
+			}
+
+			deleted, err := s.DeleteRelations([]Relation{
+				{From: "Alice", To: "Bob", RelationType: "knows"},
+				{From: "Alice", To: "Bob", RelationType: "manages"},
+			})
+			if err != nil || deleted != 1 {
+				t.Errorf("Expected 1 of 2 relations deleted, got %d (%v)", deleted, err)
+			}
+
+			deleted, err = s.DeleteEntities([]string{"Alice", "Ghost", "Alice"})
+			if err != nil || deleted != 1 {
+				t.Errorf("Expected 1 entity deleted, got %d (%v)", deleted, err)
+			}
+			if deleted, err := s.DeleteEntities([]string{"Ghost"}); err != nil || deleted != 0 {
+				t.Errorf("Expected nothing deleted for a missing entity, got %d (%v)", deleted, err)
+			}
+
+			// Relations cascading from a deleted entity are not counted
+			if deleted, err := s.DeleteEntities([]string{"Carol"}); err != nil || deleted != 1 {
+				t.Errorf("Expected 1 entity deleted, got %d (%v)", deleted, err)
+			}
+		})
+	}
+}
+
+func TestPreviewDeletion(t *testing.T) {
+	for name, s := range newTestStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := s.CreateEntities([]Entity{
+				{Name: "Alice", EntityType: "person", Observations: []string{"engineer", "likes tea"}},
+				{Name: "Bob", EntityType: "person", Observations: []string{"manager"}},
+				{Name: "Acme", EntityType: "company"},
+			}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			if _, err := s.CreateRelations([]Relation{
+				{From: "Alice", To: "Acme", RelationType: "works_at"},
+				{From: "Bob", To: "Alice", RelationType: "manages"},
+				{From: "Bob", To: "Acme", RelationType: "works_at"},
+			}); err != nil {
+				t.Fatalf("CreateRelations failed: %v", err)
+			}
+
+			preview, err := PreviewDeletion(s, []string{"Alice", "Ghost"}, nil)
+			if err != nil {
+				t.Fatalf("PreviewDeletion failed: %v", err)
+			}
+			if !preview.DryRun || !slices.Equal(preview.Entities, []string{"Alice"}) || !slices.Equal(preview.NotFound, []string{"Ghost"}) {
+				t.Errorf("Expected Alice found and Ghost not, got %+v", preview)
+			}
+			if preview.Observations != 2 {
+				t.Errorf("Expected Alice's 2 observations counted, got %d", preview.Observations)
+			}
+			if len(preview.Relations) != 2 {
+				t.Errorf("Expected both relations of Alice to cascade, got %+v", preview.Relations)
+			}
+			if sqlite, ok := s.(*SQLiteStorage); ok {
+				time.Sleep(50 * time.Millisecond) // access stats are written in the background
+				var accessCount int
+				if err := sqlite.db.QueryRow("SELECT access_count FROM entities WHERE name = 'Alice'").Scan(&accessCount); err != nil || accessCount != 0 {
+					t.Errorf("Expected a preview to record no access, got %d (%v)", accessCount, err)
+				}
+			}
+
+			preview, err = PreviewDeletion(s, nil, []Relation{
+				{From: "Bob", To: "Acme", RelationType: "works_at"},
+				{From: "Bob", To: "Acme", RelationType: "owns"},
+			})
+			if err != nil {
+				t.Fatalf("PreviewDeletion failed: %v", err)
+			}
+			if len(preview.Entities) != 0 || !slices.Equal(preview.Relations, []Relation{{From: "Bob", To: "Acme", RelationType: "works_at"}}) {
+				t.Errorf("Expected only the existing relation listed, got %+v", preview)
+			}
+
+			// A named relation that also cascades is listed once
+			preview, err = PreviewDeletion(s, []string{"Bob"}, []Relation{{From: "Bob", To: "Acme", RelationType: "works_at"}})
+			if err != nil {
+				t.Fatalf("PreviewDeletion failed: %v", err)
+			}
+			if len(preview.Relations) != 2 {
+				t.Errorf("Expected Bob's 2 relations, got %+v", preview.Relations)
+			}
+
+			if graph, _ := s.ExportData(); len(graph.Entities) != 3 || len(graph.Relations) != 3 {
+				t.Errorf("Expected a preview to delete nothing, got %+v", graph)
+			}
+
+			result, err := DeleteByQuery(s, "", "company", true)
+			if err != nil {
+				t.Fatalf("DeleteByQuery dry run failed: %v", err)
+			}
+			if len(result.Relations) != 2 {
+				t.Errorf("Expected the dry run to list Acme's 2 relations, got %+v", result.Relations)
+			}
+		})
+	}
+}
+
+func TestEmptyQueryListsAll(t *testing.T) {
+	entities := []Entity{
+		{Name: "Carol", EntityType: "person", Observations: []string{"designer"}},
+		{Name: "Alice", EntityType: "person", Observations: []string{"engineer", "likes tea"}},
+		{Name: "Bob", EntityType: "person"},
+	}
+	for name, s := range newTestStorages(t) {
+		t.Run(name+"/off", func(t *testing.T) {
+			if _, err := s.CreateEntities(entities); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			result, err := s.SearchNodes("", 10)
+			if err != nil || len(result.Entities) != 0 || result.Total != 0 {
+				t.Errorf("Expected an empty query to find nothing by default, got %+v (%v)", result, err)
+			}
+		})
+	}
+	for name, s := range newTestStorages(t, func(c *Config) { c.EmptyQueryListsAll = true }) {
+		t.Run(name+"/on", func(t *testing.T) {
+			if _, err := s.CreateEntities(entities); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			if _, err := s.CreateRelations([]Relation{{From: "Alice", To: "Bob", RelationType: "knows"}}); err != nil {
+				t.Fatalf("CreateRelations failed: %v", err)
+			}
+
+			result, err := s.SearchNodes(" ", 2)
+			if err != nil {
+				t.Fatalf("SearchNodes failed: %v", err)
+			}
+			if result.Total != 3 || !result.HasMore || len(result.Entities) != 2 {
+				t.Fatalf("Expected 2 of 3 entities listed, got %+v", result)
+			}
+			alice := result.Entities[0]
+			if alice.Name != "Alice" || result.Entities[1].Name != "Bob" {
+				t.Errorf("Expected name order, got %+v", result.Entities)
+			}
+			if alice.ObservationsCount != 2 || alice.RelationsCount != 1 || len(alice.Snippets) != 0 {
+				t.Errorf("Expected Alice's counts without snippets, got %+v", alice)
+			}
+
+			if result, err := s.SearchNodes("", 0); err != nil || len(result.Entities) != 3 || result.HasMore {
+				t.Errorf("Expected all entities without a limit, got %+v (%v)", result, err)
+			}
+			// A non-empty query still searches
+			if result, err := s.SearchNodes("designer", 0); err != nil || len(result.Entities) != 1 {
+				t.Errorf("Expected one match for designer, got %+v (%v)", result, err)
+			}
+		})
+	}
+}
+
+func TestJSONLFlushInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.jsonl")
+	s, err := NewJSONLStorage(Config{FilePath: path, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := s.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	onDisk := func() int {
+		t.Helper()
+		set, err := readGraphSet(path, nil)
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+		count := 0
+		for _, graph := range set.graphs {
+			count += len(graph.Entities)
+		}
+		return count
+	}
+
+	for i := 0; i < 20; i++ {
+		if _, err := s.CreateEntities([]Entity{{Name: fmt.Sprintf("E%d", i), EntityType: "test"}}); err != nil {
+			t.Fatalf("CreateEntities failed: %v", err)
+		}
+	}
+	if _, err := s.WithNamespace("work").CreateEntities([]Entity{{Name: "W", EntityType: "test"}}); err != nil {
+		t.Fatalf("CreateEntities failed: %v", err)
+	}
+	if n := onDisk(); n != 0 {
+		t.Errorf("Expected changes buffered, found %d entities on disk", n)
+	}
+	graph, err := s.ExportData()
+	if err != nil || len(graph.Entities) != 20 {
+		t.Errorf("Expected reads to see the buffered entities, got %d (%v)", len(graph.Entities), err)
+	}
+
+	// A read's copy is not the buffer itself
+	graph.Entities[0].Observations = append(graph.Entities[0].Observations, "stray")
+	if again, _ := s.ExportData(); len(again.Entities[0].Observations) != 0 {
+		t.Error("Expected changes to a read result not to reach the buffer")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if n := onDisk(); n != 21 {
+		t.Errorf("Expected Close to flush every namespace, found %d entities on disk", n)
+	}
+
+	// The timer flushes without Close
+	s, err = NewJSONLStorage(Config{FilePath: path, FlushInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if _, err := s.DeleteEntities([]string{"E0"}); err != nil {
+		t.Fatalf("DeleteEntities failed: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for onDisk() != 20 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the buffered delete flushed by the timer")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAdjacencyList(t *testing.T) {
+	for name, s := range newTestStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := s.CreateEntities([]Entity{
+				{Name: "Alice", EntityType: "person", Observations: []string{"works on Go"}},
+				{Name: "Bob", EntityType: "person", Observations: []string{"writes Go too"}},
+				{Name: "Acme", EntityType: "company", Observations: []string{"a Go shop"}},
+				{Name: "Loner", EntityType: "person"},
+			}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			if _, err := s.CreateRelations([]Relation{
+				{From: "Alice", To: "Bob", RelationType: "knows"},
+				{From: "Alice", To: "Acme", RelationType: "works_at"},
+				{From: "Bob", To: "Acme", RelationType: "works_at"},
+			}); err != nil {
+				t.Fatalf("CreateRelations failed: %v", err)
+			}
+
+			all, err := AdjacencyList(s, "", TypeFilter{})
+			if err != nil {
+				t.Fatalf("AdjacencyList failed: %v", err)
+			}
+			want := map[string][]Adjacent{
+				"Alice": {{To: "Bob", RelationType: "knows"}, {To: "Acme", RelationType: "works_at"}},
+				"Bob":   {{To: "Acme", RelationType: "works_at"}},
+				"Acme":  {},
+				"Loner": {},
+			}
+			if !reflect.DeepEqual(all, want) {
+				t.Errorf("Expected %+v, got %+v", want, all)
+			}
+
+			// Relations leaving the scope are dropped
+			people, err := AdjacencyList(s, "", TypeFilter{Include: []string{"person"}})
+			if err != nil {
+				t.Fatalf("AdjacencyList failed: %v", err)
+			}
+			want = map[string][]Adjacent{
+				"Alice": {{To: "Bob", RelationType: "knows"}},
+				"Bob":   {},
+				"Loner": {},
+			}
+			if !reflect.DeepEqual(people, want) {
+				t.Errorf("Expected %+v, got %+v", want, people)
+			}
+
+			goPeople, err := AdjacencyList(s, "Go", TypeFilter{Exclude: []string{"company"}})
+			if err != nil {
+				t.Fatalf("AdjacencyList failed: %v", err)
+			}
+			if len(goPeople) != 2 || len(goPeople["Alice"]) != 1 || goPeople["Bob"] == nil {
+				t.Errorf("Expected Alice and Bob scoped by query, got %+v", goPeople)
+			}
+		})
+	}
+}
+
+func TestCheckIntegrity(t *testing.T) {
+	stores := newTestStorages(t, func(c *Config) { c.DisableForeignKeys = true })
+	for name, s := range stores {
+		if _, err := s.CreateEntities([]Entity{
+			{Name: "Alice", EntityType: "person", Observations: []string{"likes Go"}},
+			{Name: "Bob", EntityType: "person"},
+		}); err != nil {
+			t.Fatalf("%s: CreateEntities failed: %v", name, err)
+		}
+		if _, err := s.CreateRelations([]Relation{{From: "Alice", To: "Bob", RelationType: "knows"}}); err != nil {
+			t.Fatalf("%s: CreateRelations failed: %v", name, err)
+		}
+		report, err := s.CheckIntegrity()
+		if err != nil {
+			t.Fatalf("%s: CheckIntegrity failed: %v", name, err)
+		}
+		if !report.OK || report.Backend != name {
+			t.Errorf("%s: Expected a clean store to pass, got %+v", name, report)
+		}
+	}
+
+	// Without enforced foreign keys, deleting an entity's row leaves its
+	// relations and observations behind
+	s := stores["sqlite"].(*SQLiteStorage)
+	if report, _ := s.CheckIntegrity(); report.FTS == nil || report.FTS.Drift || report.FTS.EntitiesIndexed != 2 {
+		t.Fatalf("Expected FTS in sync, got %+v", report.FTS)
+	}
+	if _, err := s.db.Exec("DELETE FROM entities WHERE name = 'Alice'"); err != nil {
+		t.Fatalf("Failed to delete entity row: %v", err)
+	}
+	if _, err := s.db.Exec("INSERT INTO entities_fts(entities_fts, rowid, name, entity_type) SELECT 'delete', id, name, entity_type FROM entities WHERE name = 'Bob'"); err != nil {
+		t.Fatalf("Failed to drop Bob from the index: %v", err)
+	}
+	report, err := s.CheckIntegrity()
+	if err != nil {
+		t.Fatalf("CheckIntegrity failed: %v", err)
+	}
+	if report.OK || report.DanglingRelations.Count != 1 || report.OrphanedObservations.Count != 1 {
+		t.Errorf("Expected a dangling relation and an orphaned observation, got %+v", report)
+	}
+	if sample := report.DanglingRelations.Samples[0]; !strings.HasPrefix(sample, "#") || !strings.HasSuffix(sample, "-[knows]-> Bob") {
+		t.Errorf("Expected the missing entity shown by ID, got %q", sample)
+	}
+	if !report.FTS.Drift || report.FTS.EntitiesIndexed != 0 || report.FTS.Entities != 1 {
+		t.Errorf("Expected FTS drift, got %+v", report.FTS)
+	}
+
+	// JSONL keeps whatever the file holds
+	j := stores["jsonl"].(*JSONLStorage)
+	content := `{"type":"entity","name":"Carol","entityType":"person","observations":[]}
+{"type":"entity","name":"Carol","entityType":"person","observations":[]}
+{"type":"relation","from":"Carol","to":"Dave","relationType":"knows"}
+{"type":"relation","from":"Erin","to":"Carol","relationType":"knows","namespace":"work"}
+`
+	if err := os.WriteFile(j.config.FilePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	report, err = j.CheckIntegrity()
+	if err != nil {
+		t.Fatalf("CheckIntegrity failed: %v", err)
+	}
+	if report.OK || !slices.Equal(report.DuplicateEntities.Samples, []string{"Carol"}) ||
+		!slices.Equal(report.DanglingRelations.Samples, []string{"Carol -[knows]-> Dave", "work: Erin -[knows]-> Carol"}) {
+		t.Errorf("Expected a duplicate and two dangling relations, got %+v", report)
+	}
+}
+
+// TestSQLInjection feeds SQL and FTS5 syntax through the operations behind every
+// tool that takes user text, and checks it is stored and matched as plain data
+func TestSQLInjection(t *testing.T) {
+	const drop = "'; DROP TABLE entities;--"
+	payloads := []string{
+		drop,
+		`" OR 1=1 --`,
+		"x') OR ('1'='1",
+		"name:*",
+		"NEAR(a b) NOT c",
+		"%_\\",
+	}
+
+	// Failed FTS queries fall back to the LIKE search, so check the quoting directly
+	if q, ok := prepareFTSQuery(`a OR name:b "NOT c`); !ok || q != `"a"* OR "OR"* OR "name:b"* OR "NOT c"` {
+		t.Errorf("Expected every word quoted, got %q", q)
+	}
+	if terms := ftsTerms([]string{`a"b`}); len(terms) != 1 || terms[0] != `"a""b"*` {
+		t.Errorf("Expected quotes in a term doubled, got %q", terms)
+	}
+	if _, ok := prepareFTSQuery("'; --"); ok {
+		t.Error("Expected a query of punctuation alone to be left to the basic search")
+	}
+
+	for name, s := range newTestStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			entities := []Entity{{Name: "Anchor", EntityType: "test"}, {Name: drop + " victim", EntityType: "test"}}
+			relations := []Relation{}
+			for i, p := range payloads {
+				entities = append(entities, Entity{Name: p, EntityType: p, Observations: []string{"obs " + p}})
+				relations = append(relations, Relation{From: "Anchor", To: p, RelationType: fmt.Sprintf("%s %d", p, i)})
+			}
+			if _, err := s.CreateEntities(entities); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			if _, err := s.CreateRelations(relations); err != nil {
+				t.Fatalf("CreateRelations failed: %v", err)
+			}
+
+			for _, p := range payloads {
+				if _, err := s.AddObservations(map[string][]string{p: {p}}, p); err != nil {
+					t.Errorf("AddObservations(%q) failed: %v", p, err)
+				}
+				if result, err := s.SearchNodes(p, 0); err != nil || result.Total == 0 {
+					t.Errorf("SearchNodes(%q) = %+v, %v; expected a match", p, result, err)
+				}
+				if _, err := s.SearchNodesWithSnippets(p, 1, 1); err != nil {
+					t.Errorf("SearchNodesWithSnippets(%q) failed: %v", p, err)
+				}
+				if graph, err := s.OpenNodes([]string{p}); err != nil || len(graph.Entities) != 1 {
+					t.Errorf("OpenNodes(%q) = %+v, %v", p, graph, err)
+				}
+				if types, err := s.EntityTypes([]string{p}); err != nil || types[p] != p {
+					t.Errorf("EntityTypes(%q) = %v, %v", p, types, err)
+				}
+				if list, err := s.ListEntities(ListOptions{EntityType: p}); err != nil || list.Total != 1 {
+					t.Errorf("ListEntities(%q) = %+v, %v", p, list, err)
+				}
+				if _, err := s.ReadGraph("full", 0, TypeFilter{Include: []string{p}, Exclude: []string{drop + "x"}}); err != nil {
+					t.Errorf("ReadGraph(%q) failed: %v", p, err)
+				}
+				if _, err := s.ReadGraphAsOf(time.Now().Add(time.Hour), "summary", 10, TypeFilter{Exclude: []string{p}}); err != nil && !errors.Is(err, ErrInvalidArgument) {
+					t.Errorf("ReadGraphAsOf(%q) failed: %v", p, err)
+				}
+				if _, err := s.FindByObservation(p); err != nil {
+					t.Errorf("FindByObservation(%q) failed: %v", p, err)
+				}
+				if page, err := s.GetObservations(p, 0, 0); err != nil || len(page.Observations) != 2 {
+					t.Errorf("GetObservations(%q) = %+v, %v", p, page, err)
+				}
+				if hits, err := s.Traverse(p, "", "both"); err != nil || len(hits) != 1 {
+					t.Errorf("Traverse(%q) = %+v, %v", p, hits, err)
+				}
+				if _, err := s.Reachable("Anchor", p, 0, "out"); err != nil {
+					t.Errorf("Reachable(%q) failed: %v", p, err)
+				}
+				if _, err := s.Closure(p, p, "in", 0); err != nil {
+					t.Errorf("Closure(%q) failed: %v", p, err)
+				}
+				if _, err := s.FindAllPaths("Anchor", p, 0, 0); err != nil {
+					t.Errorf("FindAllPaths(%q) failed: %v", p, err)
+				}
+				if exists, err := s.RelationExists("Anchor", p, p); err != nil || exists {
+					t.Errorf("RelationExists(%q) = %v, %v", p, exists, err)
+				}
+				if _, err := s.DetectConflicts(p); err != nil {
+					t.Errorf("DetectConflicts(%q) failed: %v", p, err)
+				}
+				if _, err := DeleteByQuery(s, p, p, true); err != nil {
+					t.Errorf("DeleteByQuery(%q) failed: %v", p, err)
+				}
+				if _, err := AdjacencyList(s, p, TypeFilter{Include: []string{p}}); err != nil {
+					t.Errorf("AdjacencyList(%q) failed: %v", p, err)
+				}
+				if _, _, err := s.IdempotentResult(p, p, time.Minute); err != nil {
+					t.Errorf("IdempotentResult(%q) failed: %v", p, err)
+				}
+				if _, err := s.WithNamespace(p).SearchNodes(p, 0); err != nil {
+					t.Errorf("SearchNodes in namespace %q failed: %v", p, err)
+				}
+			}
+
+			// Writes keyed by the payloads touch only the rows they name
+			if err := s.UpdateObservation(drop, drop, "updated"); err != nil {
+				t.Errorf("UpdateObservation failed: %v", err)
+			}
+			if _, err := s.ReplaceInObservations(drop, "replaced", true); err != nil {
+				t.Errorf("ReplaceInObservations failed: %v", err)
+			}
+			if _, err := s.UpdateEntity(drop, drop+" renamed", drop+" retyped", nil); err != nil {
+				t.Errorf("UpdateEntity failed: %v", err)
+			}
+			if _, err := s.DeleteObservations([]ObservationDeletion{{EntityName: payloads[1], Observations: []string{payloads[1]}}}); err != nil {
+				t.Errorf("DeleteObservations failed: %v", err)
+			}
+			if _, err := s.DeleteRelations([]Relation{relations[2]}); err != nil {
+				t.Errorf("DeleteRelations failed: %v", err)
+			}
+			if _, err := s.DeleteRelationsByType(relations[3].RelationType); err != nil {
+				t.Errorf("DeleteRelationsByType failed: %v", err)
+			}
+			if _, err := s.DeleteEntities([]string{drop + " victim"}); err != nil {
+				t.Errorf("DeleteEntities failed: %v", err)
+			}
+
+			graph, err := s.ExportData()
+			if err != nil {
+				t.Fatalf("ExportData failed: %v", err)
+			}
+			if len(graph.Entities) != len(payloads)+1 || len(graph.Relations) != len(payloads)-2 {
+				t.Errorf("Expected %d entities and %d relations left, got %d and %d",
+					len(payloads)+1, len(payloads)-2, len(graph.Entities), len(graph.Relations))
+			}
+			names := map[string]bool{}
+			for _, e := range graph.Entities {
+				names[e.Name] = true
+			}
+			if !names[drop+" renamed"] || !names[payloads[5]] || names[drop+" victim"] {
+				t.Errorf("Expected the payloads stored verbatim, got %v", names)
+			}
+			if report, err := s.CheckIntegrity(); err != nil || !report.OK {
+				t.Errorf("Expected the store intact, got %+v (%v)", report, err)
+			}
+		})
+	}
+}
+
+func TestParseMCPMemory(t *testing.T) {
+	want := &KnowledgeGraph{
+		Entities: []Entity{
+			{Name: "John_Smith", EntityType: "person", Observations: []string{"Speaks fluent Spanish", "Graduated in 2019"}},
+			{Name: "Anthropic", EntityType: "organization", Observations: []string{"AI safety company"}},
+		},
+		Relations: []Relation{{From: "John_Smith", To: "Anthropic", RelationType: "works_at"}},
+	}
+	for file, skipped := range map[string]int{"memory.jsonl": 0, "graph.json": 0, "wrapped.json": 0, "array.json": 1} {
+		data, err := os.ReadFile(filepath.Join("testdata", "mcp-memory", file))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		graph, warnings, err := ParseMCPMemory(data)
+		if err != nil {
+			t.Fatalf("%s: ParseMCPMemory failed: %v", file, err)
+		}
+		if !reflect.DeepEqual(graph, want) {
+			t.Errorf("%s: expected %+v, got %+v", file, want, graph)
+		}
+		if len(warnings) != skipped {
+			t.Errorf("%s: expected %d skipped items, got %+v", file, skipped, warnings)
+		}
+	}
+
+	_, warnings, err := ParseMCPMemory([]byte("{\"type\":\"entity\",\"name\":\"A\"}\nnot json\n{\"foo\":1}\n"))
+	if err != nil || len(warnings) != 2 || warnings[0].Line != 2 || warnings[1].Line != 3 {
+		t.Errorf("Expected lines 2 and 3 skipped, got %+v (%v)", warnings, err)
+	}
+	if _, _, err := ParseMCPMemory([]byte(`{"nodes": []}`)); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("Expected an unrecognized object rejected, got %v", err)
+	}
+}
+
+func TestNoFTS(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	open := func(fts *FTSConfig) *SQLiteStorage {
+		t.Helper()
+		s, err := NewSQLiteStorage(Config{FilePath: path, WALMode: true, FTS: fts})
+		if err != nil {
+			t.Fatalf("Failed to create storage: %v", err)
+		}
+		if err := s.Initialize(); err != nil {
+			t.Fatalf("Initialize failed: %v", err)
+		}
+		return s
+	}
+	// FTS matches word prefixes only, so a match inside a word shows LIKE search
+	inside := func(s *SQLiteStorage) int {
+		t.Helper()
+		result, err := s.SearchNodes("ndroi", 0)
+		if err != nil {
+			t.Fatalf("SearchNodes failed: %v", err)
+		}
+		return result.Total
+	}
+
+	s := open(&FTSConfig{Enabled: false})
+	if _, err := s.CreateEntities([]Entity{{Name: "Android", EntityType: "platform", Observations: []string{"mobile OS"}}}); err != nil {
+		t.Fatalf("CreateEntities failed: %v", err)
+	}
+	if info, _ := s.StorageInfo(); s.isFTSAvailable() || info.FTSAvailable {
+		t.Error("Expected no FTS tables in a database created without FTS")
+	}
+	if inside(s) != 1 {
+		t.Error("Expected LIKE search without FTS")
+	}
+	s.Close()
+
+	// Turning FTS on indexes the rows written without it
+	s = open(nil)
+	if result, err := s.SearchNodes("mobile", 0); err != nil || result.Total != 1 || !s.useFTS() {
+		t.Errorf("Expected FTS to find the existing entity, got %+v (%v)", result, err)
+	}
+	if report, err := s.CheckIntegrity(); err != nil || report.FTS == nil || report.FTS.Drift {
+		t.Errorf("Expected the new index in sync, got %+v (%v)", report, err)
+	}
+	if inside(s) != 0 {
+		t.Error("Expected FTS prefix matching")
+	}
+	s.Close()
+
+	// An existing index is bypassed, not dropped
+	s = open(&FTSConfig{Enabled: false})
+	defer s.Close()
+	if !s.isFTSAvailable() || s.useFTS() || inside(s) != 1 {
+		t.Error("Expected existing FTS tables kept but unused")
+	}
+}
+
+func TestObservationsInRange(t *testing.T) {
+	for name, s := range newTestStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			start := time.Now().Add(-time.Minute)
+			if _, err := s.CreateEntities([]Entity{{Name: "Alice", EntityType: "person", Observations: []string{"first"}}}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			if _, err := s.AddObservations(map[string][]string{"Alice": {"second"}}, "chat"); err != nil {
+				t.Fatalf("AddObservations failed: %v", err)
+			}
+
+			observations, err := s.ObservationsInRange(start, time.Time{})
+			if err != nil {
+				t.Fatalf("ObservationsInRange failed: %v", err)
+			}
+			if len(observations) != 2 || observations[0].Content != "first" || observations[1].Content != "second" {
+				t.Fatalf("Expected both observations oldest first, got %+v", observations)
+			}
+			second := observations[1]
+			if second.EntityName != "Alice" || second.EntityType != "person" || second.Source != "chat" || second.CreatedAt.IsZero() {
+				t.Errorf("Expected entity, source, and time on the observation, got %+v", second)
+			}
+
+			past := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+			if observations, _ := s.ObservationsInRange(past, past.Add(24*time.Hour)); len(observations) != 0 {
+				t.Errorf("Expected nothing in a past window, got %+v", observations)
+			}
+			if _, err := s.ObservationsInRange(start, past); !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("Expected ErrInvalidArgument for end before start, got %v", err)
+			}
+		})
+	}
+}
+
+func TestEntitiesModifiedSince(t *testing.T) {
+	for backend, store := range newTestStorages(t) {
+		t.Run(backend, func(t *testing.T) {
+			if _, err := store.CreateEntities([]Entity{
+				{Name: "Old", EntityType: "note", Observations: []string{"written long ago"}},
+				{Name: "New", EntityType: "note", Observations: []string{"fresh", "also fresh"}},
+			}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+
+			// backdate sets the update time of the named entities, or of all of them
+			backdate := func(names ...string) {
+				t.Helper()
+				at := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+				switch s := store.(type) {
+				case *SQLiteStorage:
+					query := "UPDATE entities SET updated_at = '2020-01-01 00:00:00'"
+					if len(names) > 0 {
+						query += " WHERE name IN ('" + strings.Join(names, "','") + "')"
+					}
+					if _, err := s.db.Exec(query); err != nil {
+						t.Fatalf("Failed to backdate %v: %v", names, err)
+					}
+				case *JSONLStorage:
+					set, err := s.readSet()
+					if err != nil {
+						t.Fatalf("Failed to read the file: %v", err)
+					}
+					graph := set.graph(s.config.namespace())
+					for i := range graph.Entities {
+						if len(names) == 0 || slices.Contains(names, graph.Entities[i].Name) {
+							graph.Entities[i].UpdateTime = at
+						}
+					}
+					if err := s.writeSet(set); err != nil {
+						t.Fatalf("Failed to backdate %v: %v", names, err)
+					}
+				}
+			}
+			backdate("Old")
+
+			entities, err := store.EntitiesModifiedSince(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+			if err != nil {
+				t.Fatalf("EntitiesModifiedSince failed: %v", err)
+			}
+			if len(entities) != 1 || entities[0].Name != "New" || len(entities[0].Observations) != 2 || entities[0].UpdatedAt == nil {
+				t.Fatalf("Expected only New with its observations, got %+v", entities)
+			}
+
+			// since is inclusive, to the second
+			if entities, err := store.EntitiesModifiedSince(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)); err != nil || len(entities) != 2 || entities[0].Name != "Old" {
+				t.Errorf("Expected Old then New, got %+v (%v)", entities, err)
+			}
+
+			if _, err := store.AddObservations(map[string][]string{"Old": {"revisited"}}, ""); err != nil {
+				t.Fatalf("AddObservations failed: %v", err)
+			}
+			if entities, err := store.EntitiesModifiedSince(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)); err != nil || len(entities) != 2 {
+				t.Errorf("Expected Old to count as modified after a new observation, got %+v (%v)", entities, err)
+			}
+			if entities, err := store.EntitiesModifiedSince(time.Now().Add(time.Hour)); err != nil || len(entities) != 0 {
+				t.Errorf("Expected nothing modified in the future, got %+v (%v)", entities, err)
+			}
+
+			// Deleting an observation counts as modifying its entity
+			backdate()
+			if _, err := store.DeleteObservations([]ObservationDeletion{{EntityName: "New", Contains: []string{"also"}}}); err != nil {
+				t.Fatalf("DeleteObservations failed: %v", err)
+			}
+			entities, err = store.EntitiesModifiedSince(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+			if err != nil || len(entities) != 1 || entities[0].Name != "New" || !slices.Equal(entities[0].Observations, []string{"fresh"}) {
+				t.Errorf("Expected New modified by the deletion, got %+v (%v)", entities, err)
+			}
+		})
+	}
+}
+
+func TestJSONLObservationTimes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.jsonl")
+	legacy := `{"type":"entity","name":"Alice","entityType":"person","observations":["legacy"]}` + "\n" +
+		`{"type":"entity","name":"Bob","entityType":"person","observations":["untouched"]}` + "\n"
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	open := func() *JSONLStorage {
+		s, err := NewJSONLStorage(Config{FilePath: path})
+		if err != nil {
+			t.Fatalf("Failed to create JSONL storage: %v", err)
+		}
+		if err := s.Initialize(); err != nil {
+			t.Fatalf("Initialize failed: %v", err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	}
+	s := open()
+	if _, err := s.AddObservations(map[string][]string{"Alice": {"fresh"}}, ""); err != nil {
+		t.Fatalf("AddObservations failed: %v", err)
+	}
+	observations, err := s.ObservationsInRange(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("ObservationsInRange failed: %v", err)
+	}
+	if len(observations) != 1 || observations[0].Content != "fresh" {
+		t.Fatalf("Expected only the new observation timed, got %+v", observations)
+	}
+	stamped := observations[0].CreatedAt
+
+	// Times survive a reload and later writes
+	reopened := open()
+	if _, err := reopened.AddObservations(map[string][]string{"Alice": {"later"}}, ""); err != nil {
+		t.Fatalf("AddObservations failed: %v", err)
+	}
+	observations, _ = reopened.ObservationsInRange(time.Time{}, time.Time{})
+	if len(observations) != 2 || !observations[0].CreatedAt.Equal(stamped) || observations[1].Content != "later" {
+		t.Errorf("Expected the stored time kept and the new observation after it, got %+v", observations)
+	}
+
+	// Entity update times are kept the same way, and untouched legacy entities have none
+	modified, err := reopened.EntitiesModifiedSince(time.Time{})
+	if err != nil || len(modified) != 1 || modified[0].Name != "Alice" || modified[0].UpdatedAt.Before(stamped) {
+		t.Fatalf("Expected only Alice modified, got %+v (%v)", modified, err)
+	}
+	if again, err := open().EntitiesModifiedSince(time.Time{}); err != nil || len(again) != 1 || !again[0].UpdatedAt.Equal(*modified[0].UpdatedAt) {
+		t.Errorf("Expected Alice's update time to survive a reload, got %+v (%v)", again, err)
+	}
+	if recent, err := reopened.RecentlyModified(0); err != nil || len(recent) != 2 || recent[0].Name != "Alice" || recent[0].UpdatedAt == nil || recent[1].UpdatedAt != nil {
+		t.Errorf("Expected Alice with her update time, then untimed Bob, got %+v (%v)", recent, err)
+	}
+}
+
+func TestSimilarEntities(t *testing.T) {
+	for name, s := range newTestStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := s.CreateEntities([]Entity{
+				{Name: "Alice", EntityType: "person", Observations: []string{"writes Golang services", "likes hiking"}},
+				{Name: "Bob", EntityType: "person", Observations: []string{"writes Golang tools"}},
+				{Name: "Carol", EntityType: "person", Observations: []string{"paints watercolors"}},
+				{Name: "Dave", EntityType: "person", Observations: []string{"likes hiking"}},
+				{Name: "Acme", EntityType: "company"},
+				{Name: "Club", EntityType: "group"},
+			}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+			if _, err := s.CreateRelations([]Relation{
+				{From: "Alice", To: "Acme", RelationType: "works_at"},
+				{From: "Alice", To: "Club", RelationType: "member_of"},
+				{From: "Bob", To: "Acme", RelationType: "works_at"},
+				{From: "Carol", To: "Acme", RelationType: "works_at"},
+				{From: "Carol", To: "Club", RelationType: "member_of"},
+			}); err != nil {
+				t.Fatalf("CreateRelations failed: %v", err)
+			}
+
+			byRelations, err := SimilarEntities(s, "Alice", SimilarityRelations, 0)
+			if err != nil {
+				t.Fatalf("SimilarEntities failed: %v", err)
+			}
+			if len(byRelations) != 2 || byRelations[0].Name != "Carol" || byRelations[0].Score != 1 ||
+				!reflect.DeepEqual(byRelations[0].SharedNeighbors, []string{"Acme", "Club"}) ||
+				byRelations[1].Name != "Bob" || byRelations[1].Score != 0.5 || byRelations[1].EntityType != "person" {
+				t.Errorf("Expected Carol then Bob by shared neighbors, got %+v", byRelations)
+			}
+
+			byWords, err := SimilarEntities(s, "Alice", SimilarityObservations, 0)
+			if err != nil {
+				t.Fatalf("SimilarEntities failed: %v", err)
+			}
+			// Alice has writes, golang, services, likes, hiking: Dave shares 2 of the 5
+			// words the two use, Bob 2 of 6
+			if len(byWords) != 2 || byWords[0].Name != "Dave" || byWords[1].Name != "Bob" {
+				t.Errorf("Expected Dave then Bob by shared words, got %+v", byWords)
+			}
+
+			both, err := SimilarEntities(s, "Alice", "", 2)
+			if err != nil {
+				t.Fatalf("SimilarEntities failed: %v", err)
+			}
+			// Carol: (1 + 0) / 2, Bob: (0.5 + 2/6) / 2, Dave: (0 + 2/5) / 2
+			if len(both) != 2 || both[0].Name != "Carol" || both[1].Name != "Bob" || both[1].ObservationOverlap == 0 {
+				t.Errorf("Expected Carol then Bob on both measures, got %+v", both)
+			}
+
+			if _, err := SimilarEntities(s, "Nobody", "", 0); !errors.Is(err, ErrEntityNotFound) {
+				t.Errorf("Expected ErrEntityNotFound, got %v", err)
+			}
+			if _, err := SimilarEntities(s, "Alice", "vibes", 0); !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("Expected ErrInvalidArgument for an unknown measure, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSearchTermsAndPhrases(t *testing.T) {
+	storages := newTestStorages(t)
+	storages["sqlite-like"] = newTestStorages(t, func(c *Config) { c.FTS = &FTSConfig{} })["sqlite"]
+	for name, s := range storages {
+		t.Run(name, func(t *testing.T) {
+			if _, err := s.CreateEntities([]Entity{
+				{Name: "Alpha", EntityType: "note", Observations: []string{"user research notes"}},
+				{Name: "Beta", EntityType: "note", Observations: []string{"collected user feedback today"}},
+				{Name: "Gamma", EntityType: "note", Observations: []string{"a feedback loop for each user"}},
+				{Name: "Delta", EntityType: "note", Observations: []string{"feedback only"}},
+			}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+
+			// Any word matches; entities matching both come first
+			result, err := s.SearchNodes("user feedback", 0)
+			if err != nil {
+				t.Fatalf("SearchNodes failed: %v", err)
+			}
+			names := make([]string, len(result.Entities))
+			for i, hit := range result.Entities {
+				names[i] = hit.Name
+			}
+			slices.Sort(names[:min(2, len(names))])
+			if result.Total != 4 || !reflect.DeepEqual(names[:2], []string{"Beta", "Gamma"}) {
+				t.Errorf("Expected Beta and Gamma ahead of the single-word matches, got %v", names)
+			}
+
+			// A quoted phrase matches only those words together
+			result, err = s.SearchNodes(`"user feedback"`, 0)
+			if err != nil {
+				t.Fatalf("SearchNodes failed: %v", err)
+			}
+			if result.Total != 1 || result.Entities[0].Name != "Beta" {
+				t.Errorf("Expected only Beta for the phrase, got %+v", result.Entities)
+			}
+		})
+	}
+
+	if terms := searchTerms(`go "user  feedback" rust "unclosed phrase`); !reflect.DeepEqual(terms, []string{"go", "user feedback", "rust", "unclosed phrase"}) {
+		t.Errorf("Unexpected terms %q", terms)
+	}
+}
+
+func TestSearchTooManyMatches(t *testing.T) {
+	limitMatches := func(c *Config) { c.MaxSearchMatches = 2 }
+	storages := newTestStorages(t, limitMatches)
+	storages["sqlite-like"] = newTestStorages(t, limitMatches, func(c *Config) { c.FTS = &FTSConfig{} })["sqlite"]
+	for name, s := range storages {
+		t.Run(name, func(t *testing.T) {
+			if _, err := s.CreateEntities([]Entity{
+				{Name: "Alpha", EntityType: "note", Observations: []string{"shared topic"}},
+				{Name: "Beta", EntityType: "note", Observations: []string{"shared topic"}},
+				{Name: "Gamma", EntityType: "note", Observations: []string{"shared topic"}},
+			}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+
+			result, err := s.SearchNodes("shared", 0)
+			if err != nil {
+				t.Fatalf("SearchNodes failed: %v", err)
+			}
+			if !result.TooManyResults || result.Total != 3 || len(result.Entities) != 0 || result.Message == "" {
+				t.Errorf("Expected only the total of 3 and a message, got %+v", result)
+			}
+
+			// A limit within the threshold returns hits as usual
+			result, err = s.SearchNodes("shared", 2)
+			if err != nil {
+				t.Fatalf("SearchNodes failed: %v", err)
+			}
+			if result.TooManyResults || result.Total != 3 || len(result.Entities) != 2 || !result.HasMore {
+				t.Errorf("Expected 2 of 3 hits, got %+v", result)
+			}
+		})
+	}
+}
+
+func TestTopicSummary(t *testing.T) {
+	for name, s := range newTestStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := s.CreateEntities([]Entity{
+				{Name: "Alpha", EntityType: "project", Observations: []string{"Uses the Go language", "Go language server in 2024"}},
+				{Name: "Beta", EntityType: "project", Observations: []string{"Written in Go", "state of the art"}},
+			}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+
+			summary, err := TopicSummary(s, TopicOptions{Limit: 2, Bigrams: true})
+			if err != nil {
+				t.Fatalf("TopicSummary failed: %v", err)
+			}
+			if summary.Observations != 4 {
+				t.Errorf("Expected 4 observations tallied, got %d", summary.Observations)
+			}
+			if want := []TermCount{{"go", 3}, {"language", 2}}; !reflect.DeepEqual(summary.Terms, want) {
+				t.Errorf("Expected terms %v, got %v", want, summary.Terms)
+			}
+			if want := []TermCount{{"go language", 2}, {"language server", 1}}; !reflect.DeepEqual(summary.Bigrams, want) {
+				t.Errorf("Expected bigrams %v, got %v", want, summary.Bigrams)
+			}
+
+			// A custom list replaces the default one, so "the" now counts
+			summary, err = TopicSummary(s, TopicOptions{Limit: MaxTopicLimit, Stopwords: []string{"GO"}})
+			if err != nil {
+				t.Fatalf("TopicSummary failed: %v", err)
+			}
+			terms := make(map[string]int)
+			for _, term := range summary.Terms {
+				terms[term.Term] = term.Count
+			}
+			if terms["go"] != 0 || terms["the"] != 2 || terms["2024"] != 0 || summary.Bigrams != nil {
+				t.Errorf("Unexpected terms %v, bigrams %v", summary.Terms, summary.Bigrams)
+			}
+
+			if _, err := TopicSummary(s, TopicOptions{Limit: MaxTopicLimit + 1}); !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("Expected ErrInvalidArgument for a limit above the max, got %v", err)
+			}
+		})
+	}
+}
+
+func TestObservationOrder(t *testing.T) {
+	for name, s := range newTestStorages(t, func(c *Config) { c.MaxObservationsPerEntity = 2 }) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := s.CreateEntities([]Entity{
+				{Name: "Log", EntityType: "note", Observations: []string{"first", "second", "third"}},
+			}); err != nil {
+				t.Fatalf("CreateEntities failed: %v", err)
+			}
+
+			// The cap keeps the newest observations when they come first
+			newest := s.WithObservationOrder(ObservationOrderNewest)
+			graph, err := newest.OpenNodes([]string{"Log"})
+			if err != nil {
+				t.Fatalf("OpenNodes failed: %v", err)
+			}
+			if got := graph.Entities[0].Observations; !reflect.DeepEqual(got, []string{"third", "second"}) {
+				t.Errorf("Expected the newest observations first, got %v", got)
+			}
+			result, err := newest.ReadGraph("full", 0, TypeFilter{})
+			if err != nil {
+				t.Fatalf("ReadGraph failed: %v", err)
+			}
+			if got := result.(*KnowledgeGraph).Entities[0].Observations; !reflect.DeepEqual(got, []string{"third", "second"}) {
+				t.Errorf("Expected read_graph to list the newest observations first, got %v", got)
+			}
+
+			// The original store keeps insertion order
+			graph, err = s.OpenNodes([]string{"Log"})
+			if err != nil {
+				t.Fatalf("OpenNodes failed: %v", err)
+			}
+			if got := graph.Entities[0].Observations; !reflect.DeepEqual(got, []string{"first", "second"}) {
+				t.Errorf("Expected insertion order, got %v", got)
+			}
+		})
+	}
+
+	if err := ValidateObservationOrder("latest"); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("Expected ErrInvalidArgument for an unknown order, got %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ObservationWithEntity is an observation with the entity it belongs to and
+// when it was added
+type ObservationWithEntity struct {
+	EntityName string    `json:"entityName"`
+	EntityType string    `json:"entityType"`
+	Content    string    `json:"content"`
+	CreatedAt  time.Time `json:"createdAt"`
+	Source     string    `json:"source,omitempty"`
+}
+
+// checkTimeRange rejects a range whose end comes before its start
+func checkTimeRange(start, end time.Time) error {
+	if !end.IsZero() && end.Before(start) {
+		return fmt.Errorf("%w: end %s is before start %s", ErrInvalidArgument,
+			end.Format(time.RFC3339), start.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// ObservationsInRange returns the observations added between start and end,
+// oldest first and ties in file order. Observations loaded from files written before
+// times were recorded have none and are never returned.
+func (j *JSONLStorage) ObservationsInRange(start, end time.Time) ([]ObservationWithEntity, error) {
+	if err := checkTimeRange(start, end); err != nil {
+		return nil, err
+	}
+	graph, err := j.loadGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	observations := []ObservationWithEntity{}
+	for _, entity := range graph.Entities {
+		for _, obs := range entity.Observations {
+			t, ok := entity.ObservationTimes[obs]
+			if !ok || t.Before(start) || (!end.IsZero() && t.After(end)) {
+				continue
+			}
+			observations = append(observations, ObservationWithEntity{
+				EntityName: entity.Name,
+				EntityType: entity.EntityType,
+				Content:    obs,
+				CreatedAt:  t,
+				Source:     entity.ObservationSources[obs],
+			})
+		}
+	}
+	slices.SortStableFunc(observations, func(a, b ObservationWithEntity) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return observations, nil
+}
+
+// ObservationsInRange returns the observations added between start and end,
+// oldest first. Times are stored to the second.
+func (s *SQLiteStorage) ObservationsInRange(start, end time.Time) ([]ObservationWithEntity, error) {
+	if err := checkTimeRange(start, end); err != nil {
+		return nil, err
+	}
+	upper := "9999-12-31 23:59:59"
+	if !end.IsZero() {
+		upper = sqliteTimestamp(end)
+	}
+
+	rows, err := s.rdb().Query(`
+		SELECT e.name, e.entity_type, o.content, o.created_at, COALESCE(o.source, '')
+		FROM observations o
+		JOIN entities e ON o.entity_id = e.id
+		WHERE e.namespace = ? AND o.created_at BETWEEN ? AND ?
+		ORDER BY o.created_at, o.id
+	`, s.ns(), sqliteTimestamp(start), upper)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query observations: %w", err)
+	}
+	defer rows.Close()
+
+	observations := []ObservationWithEntity{}
+	for rows.Next() {
+		var obs ObservationWithEntity
+		var createdAt sql.NullTime
+		if err := rows.Scan(&obs.EntityName, &obs.EntityType, &obs.Content, &createdAt, &obs.Source); err != nil {
+			return nil, fmt.Errorf("failed to scan observation: %w", err)
+		}
+		obs.CreatedAt = createdAt.Time
+		observations = append(observations, obs)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating observations: %w", err)
+	}
+	return observations, nil
+}
+
+// EntitiesModifiedSince returns the entities whose type, description, or
+// observations changed at or after since, oldest first and ties in file order.
+// Entities not changed since being loaded from a file written before update
+// times were recorded have none and are never returned. Deleted entities are
+// gone and not reported.
+func (j *JSONLStorage) EntitiesModifiedSince(since time.Time) ([]Entity, error) {
+	graph, err := j.loadGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	entities := []Entity{}
+	for _, entity := range graph.Entities {
+		if entity.UpdateTime.IsZero() || entity.UpdateTime.Before(since) {
+			continue
+		}
+		updatedAt := entity.UpdateTime
+		entities = append(entities, Entity{
+			Name:         entity.Name,
+			EntityType:   entity.EntityType,
+			Description:  entity.Description,
+			Observations: slices.Clone(entity.Observations),
+			UpdatedAt:    &updatedAt,
+		})
+	}
+	slices.SortStableFunc(entities, func(a, b Entity) int {
+		return a.UpdatedAt.Compare(*b.UpdatedAt)
+	})
+	return entities, nil
+}
+
+// EntitiesModifiedSince returns the entities whose updated_at is at or after
+// since, oldest first. updated_at is stored to the second, so the comparison is
+// inclusive: a sync passing the newest updatedAt it saw gets those entities
+// again rather than missing changes made later in the same second. Deleted
+// entities are gone and not reported.
+func (s *SQLiteStorage) EntitiesModifiedSince(since time.Time) ([]Entity, error) {
+	rows, err := s.rdb().Query(`
+		SELECT id, name, entity_type, description, updated_at
+		FROM entities
+		WHERE namespace = ? AND updated_at >= ?
+		ORDER BY updated_at, id
+	`, s.ns(), sqliteTimestamp(since))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query modified entities: %w", err)
+	}
+	defer rows.Close()
+
+	entities := []Entity{}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		var entity Entity
+		var updatedAt time.Time
+		if err := rows.Scan(&id, &entity.Name, &entity.EntityType, &entity.Description, &updatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan entity: %w", err)
+		}
+		entity.UpdatedAt = &updatedAt
+		entities = append(entities, entity)
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating entities: %w", err)
+	}
+	if len(ids) == 0 {
+		return entities, nil
+	}
+
+	observations, err := s.loadObservations(ids, 0)
+	if err != nil {
+		return nil, err
+	}
+	for i, id := range ids {
+		entities[i].Observations = observations[id]
+		if entities[i].Observations == nil {
+			entities[i].Observations = []string{}
+		}
+	}
+	return entities, nil
+}
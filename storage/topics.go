@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits for TopicSummary
+const (
+	DefaultTopicLimit = 20
+	MaxTopicLimit     = 500
+)
+
+// DefaultStopwords are the common English words TopicSummary leaves out unless
+// given its own list
+var DefaultStopwords = []string{
+	"a", "about", "after", "all", "also", "an", "and", "any", "are", "as", "at",
+	"be", "been", "before", "but", "by", "can", "could", "did", "do", "does",
+	"for", "from", "had", "has", "have", "he", "her", "his", "how", "i", "if",
+	"in", "into", "is", "it", "its", "just", "may", "more", "most", "my", "no",
+	"not", "of", "on", "only", "or", "other", "our", "out", "over", "she", "should",
+	"so", "some", "such", "than", "that", "the", "their", "them", "then", "there",
+	"these", "they", "this", "those", "to", "up", "us", "very", "was", "we",
+	"were", "what", "when", "where", "which", "who", "will", "with", "would",
+	"you", "your",
+}
+
+// TermCount is a word or bigram and how many times it occurs
+type TermCount struct {
+	Term  string `json:"term"`
+	Count int    `json:"count"`
+}
+
+// TopicSummaryResult holds the most frequent words and bigrams of a namespace's observations
+type TopicSummaryResult struct {
+	Observations int         `json:"observations"` // observations tallied
+	Terms        []TermCount `json:"terms"`
+	Bigrams      []TermCount `json:"bigrams,omitempty"`
+}
+
+// TopicOptions controls TopicSummary
+type TopicOptions struct {
+	Limit     int      // terms and bigrams returned each (0 = DefaultTopicLimit)
+	Stopwords []string // words left out, matched case-insensitively (nil = DefaultStopwords)
+	Bigrams   bool     // also tally pairs of adjacent words
+}
+
+// TopicSummary tallies the words of every observation in source, leaving out
+// stopwords, single characters, and numbers, and returns the most frequent,
+// most first and ties by term. A bigram is two counted words that are adjacent
+// in an observation, so "state of the art" yields none.
+func TopicSummary(source Storage, opts TopicOptions) (*TopicSummaryResult, error) {
+	limit := opts.Limit
+	switch {
+	case limit == 0:
+		limit = DefaultTopicLimit
+	case limit < 0 || limit > MaxTopicLimit:
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidArgument, MaxTopicLimit)
+	}
+	stopwords := opts.Stopwords
+	if stopwords == nil {
+		stopwords = DefaultStopwords
+	}
+	skip := make(map[string]bool, len(stopwords))
+	for _, word := range stopwords {
+		skip[strings.ToLower(word)] = true
+	}
+
+	result := &TopicSummaryResult{}
+	terms := make(map[string]int)
+	bigrams := make(map[string]int)
+	err := source.WalkGraph(func(entity Entity) error {
+		for _, obs := range entity.Observations {
+			result.Observations++
+			prev := ""
+			for _, word := range observationWords(obs) {
+				if skip[word] || utf8.RuneCountInString(word) < 2 || !strings.ContainsFunc(word, unicode.IsLetter) {
+					prev = ""
+					continue
+				}
+				terms[word]++
+				if opts.Bigrams && prev != "" {
+					bigrams[prev+" "+word]++
+				}
+				prev = word
+			}
+		}
+		return nil
+	}, func(Relation) error { return nil })
+	if err != nil {
+		return nil, err
+	}
+
+	result.Terms = topTerms(terms, limit)
+	if opts.Bigrams {
+		result.Bigrams = topTerms(bigrams, limit)
+	}
+	return result, nil
+}
+
+// topTerms returns the limit most frequent of counts, most first and ties by term
+func topTerms(counts map[string]int, limit int) []TermCount {
+	top := make([]TermCount, 0, len(counts))
+	for term, count := range counts {
+		top = append(top, TermCount{Term: term, Count: count})
+	}
+	slices.SortFunc(top, func(a, b TermCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), strings.Compare(a.Term, b.Term))
+	})
+	return top[:min(len(top), limit)]
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"fmt"
+	"slices"
+)
+
+// transferPlan is what TransferEntities will write, decided before any change
+type transferPlan struct {
+	result  *TransferResult
+	targets map[string]string // source name -> target name, for entities being transferred
+}
+
+// planTransfer validates a transfer and picks each entity's target name. sourceExists
+// and targetExists look names up in the source and target namespaces.
+func planTransfer(names []string, from, to string, opts TransferOptions, sourceExists, targetExists func(name string) (bool, error)) (*transferPlan, error) {
+	switch opts.Mode {
+	case "":
+		opts.Mode = TransferModeSkip
+	case TransferModeSkip, TransferModeOverwrite, TransferModeRename:
+	default:
+		return nil, fmt.Errorf("%w: unknown mode %q (use skip, overwrite, or rename)", ErrInvalidArgument, opts.Mode)
+	}
+	if err := ValidateNamespace(to); err != nil {
+		return nil, err
+	}
+	if to == from {
+		return nil, fmt.Errorf("%w: source and target namespace are both %q", ErrInvalidArgument, from)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%w: no entities to transfer", ErrInvalidArgument)
+	}
+
+	plan := &transferPlan{
+		result: &TransferResult{
+			FromNamespace: from,
+			ToNamespace:   to,
+			Mode:          opts.Mode,
+			Moved:         opts.Move,
+			Transferred:   []TransferredEntity{},
+			Skipped:       []string{},
+		},
+		targets: make(map[string]string, len(names)),
+	}
+	taken := make(map[string]bool) // target names claimed by renames in this transfer
+	for _, name := range slices.Compact(slices.Sorted(slices.Values(names))) {
+		ok, err := sourceExists(name)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: %q in namespace %q", ErrEntityNotFound, name, from)
+		}
+
+		exists, err := targetExists(name)
+		if err != nil {
+			return nil, err
+		}
+		entry := TransferredEntity{Name: name, TargetName: name}
+		if exists {
+			switch opts.Mode {
+			case TransferModeSkip:
+				plan.result.Skipped = append(plan.result.Skipped, name)
+				continue
+			case TransferModeOverwrite:
+				entry.Overwritten = true
+			case TransferModeRename:
+				for n := 2; ; n++ {
+					candidate := fmt.Sprintf("%s (%d)", name, n)
+					if taken[candidate] || slices.Contains(names, candidate) {
+						continue
+					}
+					if exists, err = targetExists(candidate); err != nil {
+						return nil, err
+					}
+					if !exists {
+						entry.TargetName = candidate
+						break
+					}
+				}
+				taken[entry.TargetName] = true
+			}
+		}
+		plan.targets[name] = entry.TargetName
+		plan.result.Transferred = append(plan.result.Transferred, entry)
+	}
+	return plan, nil
+}
+
+// relations maps source relations touching the transferred entities into the
+// target namespace. An endpoint that is not transferred keeps its name and must
+// already exist in the target; otherwise the relation is recorded as dropped.
+func (p *transferPlan) relations(source []Relation, targetExists func(name string) (bool, error)) ([]Relation, error) {
+	mapped := []Relation{}
+	for _, relation := range source {
+		endpoints := [2]string{relation.From, relation.To}
+		dropped := false
+		for i, name := range endpoints {
+			if target, ok := p.targets[name]; ok {
+				endpoints[i] = target
+				continue
+			}
+			exists, err := targetExists(name)
+			if err != nil {
+				return nil, err
+			}
+			dropped = dropped || !exists
+		}
+		if dropped {
+			p.result.RelationsDropped = append(p.result.RelationsDropped, relation)
+			continue
+		}
+		mapped = append(mapped, Relation{From: endpoints[0], To: endpoints[1], RelationType: relation.RelationType, CreatedAt: relation.CreatedAt})
+	}
+	return mapped, nil
+}
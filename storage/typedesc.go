@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// Kinds of type a TypeDescription documents
+const (
+	TypeKindEntity   = "entity"
+	TypeKindRelation = "relation"
+)
+
+// TypeDescription documents what an entity or relation type means, e.g.
+// person: "an individual human"
+type TypeDescription struct {
+	Kind        string    `json:"kind"` // TypeKindEntity or TypeKindRelation
+	Type        string    `json:"type"`
+	Description string    `json:"description"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+var errTypeDescriptionsUnsupported = fmt.Errorf("%w: type descriptions require SQLite storage", ErrInvalidArgument)
+
+// validateTypeKind checks a kind argument; "" is allowed only when listing
+func validateTypeKind(kind string, allowEmpty bool) error {
+	switch kind {
+	case TypeKindEntity, TypeKindRelation:
+		return nil
+	case "":
+		if allowEmpty {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: kind %q must be entity or relation", ErrInvalidArgument, kind)
+}
+
+// SetTypeDescription is not supported by JSONL storage, whose file holds only
+// entities and relations
+func (j *JSONLStorage) SetTypeDescription(kind, typeName, description string) error {
+	return errTypeDescriptionsUnsupported
+}
+
+// TypeDescriptions is not supported by JSONL storage
+func (j *JSONLStorage) TypeDescriptions(kind string) ([]TypeDescription, error) {
+	return nil, errTypeDescriptionsUnsupported
+}
+
+// SetTypeDescription records the description of an entity or relation type of
+// the namespace, replacing any earlier one; an empty description removes it.
+// The type need not be in use yet.
+func (s *SQLiteStorage) SetTypeDescription(kind, typeName, description string) error {
+	if err := validateTypeKind(kind, false); err != nil {
+		return err
+	}
+	if typeName == "" {
+		return fmt.Errorf("%w: type is required", ErrInvalidArgument)
+	}
+	return s.retryWrite(func() error {
+		var err error
+		if description == "" {
+			_, err = s.db.Exec("DELETE FROM type_descriptions WHERE namespace = ? AND kind = ? AND type = ?", s.ns(), kind, typeName)
+		} else {
+			_, err = s.db.Exec(`
+				INSERT INTO type_descriptions (namespace, kind, type, description, updated_at)
+				VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+				ON CONFLICT(namespace, kind, type) DO UPDATE SET
+					description = excluded.description,
+					updated_at = excluded.updated_at
+			`, s.ns(), kind, typeName, description)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to set type description: %w", err)
+		}
+		return nil
+	})
+}
+
+// TypeDescriptions lists the namespace's type descriptions of kind ("" = both),
+// entity types first, then by type
+func (s *SQLiteStorage) TypeDescriptions(kind string) ([]TypeDescription, error) {
+	if err := validateTypeKind(kind, true); err != nil {
+		return nil, err
+	}
+	rows, err := s.rdb().Query(`
+		SELECT kind, type, description, updated_at
+		FROM type_descriptions
+		WHERE namespace = ? AND (? = '' OR kind = ?)
+		ORDER BY kind, type
+	`, s.ns(), kind, kind)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query type descriptions: %w", err)
+	}
+	defer rows.Close()
+
+	descriptions := []TypeDescription{}
+	for rows.Next() {
+		var d TypeDescription
+		if err := rows.Scan(&d.Kind, &d.Type, &d.Description, &d.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan type description: %w", err)
+		}
+		descriptions = append(descriptions, d)
+	}
+	return descriptions, rows.Err()
+}
@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+)
+
+// ImportFormatMCPMemory names the memory file format of the reference
+// @modelcontextprotocol/server-memory server and its variants
+const ImportFormatMCPMemory = "mcp-memory"
+
+// mcpMemoryWrappers are the keys some deployments nest the graph object under
+var mcpMemoryWrappers = []string{"graph", "knowledgeGraph", "knowledge_graph", "data"}
+
+// mcpMemoryItem holds an entity or relation in any of the field spellings
+// found in reference server files
+type mcpMemoryItem struct {
+	Type              string   `json:"type"`
+	Name              string   `json:"name"`
+	EntityType        string   `json:"entityType"`
+	SnakeEntityType   string   `json:"entity_type"`
+	Observations      []string `json:"observations"`
+	From              string   `json:"from"`
+	To                string   `json:"to"`
+	Source            string   `json:"source"`
+	Target            string   `json:"target"`
+	RelationType      string   `json:"relationType"`
+	SnakeRelationType string   `json:"relation_type"`
+}
+
+// mcpMemoryRaw is an undecoded item and where it was found: its line in a
+// JSONL file or its 1-based position in a list, and the kind the list implies
+type mcpMemoryRaw struct {
+	data []byte
+	pos  int
+	kind string // "entity", "relation", or "" when the item must say
+}
+
+// ParseMCPMemory reads a memory file written by the reference TypeScript
+// server or a deployment of it: JSONL with one {"type": "entity"|"relation"}
+// item per line, a JSON array of such items, or a single object with entities
+// and relations lists, optionally nested under "graph", "knowledgeGraph", or
+// "data". Items may spell fields in snake_case, name relation endpoints
+// source/target, give an entity's type as "type" inside an entities list, or
+// leave out "type" when the other fields make it clear. Items that still can't
+// be read are skipped and reported as warnings, whose Line is the line in a
+// JSONL file and the position in the list otherwise.
+func ParseMCPMemory(data []byte) (*KnowledgeGraph, []LoadWarning, error) {
+	items, err := mcpMemoryItems(bytes.TrimSpace(data))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	graph := &KnowledgeGraph{Entities: []Entity{}, Relations: []Relation{}}
+	var warnings []LoadWarning
+	for _, raw := range items {
+		if err := graph.addMCPMemoryItem(raw); err != nil {
+			warnings = append(warnings, LoadWarning{Line: raw.pos, Reason: err.Error()})
+		}
+	}
+	return graph, warnings, nil
+}
+
+// mcpMemoryItems splits data into the items ParseMCPMemory decodes
+func mcpMemoryItems(data []byte) ([]mcpMemoryRaw, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	if data[0] == '[' {
+		var list []json.RawMessage
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, fmt.Errorf("%w: invalid JSON array: %w", ErrInvalidArgument, err)
+		}
+		return mcpMemoryList(list, ""), nil
+	}
+
+	// A JSONL file with more than one line is not a single JSON value
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		var items []mcpMemoryRaw
+		for i, line := range bytes.Split(data, []byte("\n")) {
+			if line = bytes.TrimSpace(line); len(line) > 0 {
+				items = append(items, mcpMemoryRaw{data: line, pos: i + 1})
+			}
+		}
+		return items, nil
+	}
+
+	_, hasEntities := object["entities"]
+	_, hasRelations := object["relations"]
+	if hasEntities || hasRelations {
+		var items []mcpMemoryRaw
+		for _, part := range []struct{ key, kind string }{{"entities", "entity"}, {"relations", "relation"}} {
+			if raw := object[part.key]; raw != nil && string(raw) != "null" {
+				var list []json.RawMessage
+				if err := json.Unmarshal(raw, &list); err != nil {
+					return nil, fmt.Errorf("%w: %s must be a list: %w", ErrInvalidArgument, part.key, err)
+				}
+				items = append(items, mcpMemoryList(list, part.kind)...)
+			}
+		}
+		return items, nil
+	}
+	for _, key := range mcpMemoryWrappers {
+		if inner, ok := object[key]; ok {
+			return mcpMemoryItems(bytes.TrimSpace(inner))
+		}
+	}
+	if _, ok := object["type"]; ok {
+		return []mcpMemoryRaw{{data: data, pos: 1}}, nil // a one-line JSONL file
+	}
+	return nil, fmt.Errorf("%w: unrecognized memory file: expected JSONL, a list of items, or an object with entities and relations", ErrInvalidArgument)
+}
+
+// mcpMemoryList numbers the items of a list of kind
+func mcpMemoryList(list []json.RawMessage, kind string) []mcpMemoryRaw {
+	items := make([]mcpMemoryRaw, len(list))
+	for i, data := range list {
+		items[i] = mcpMemoryRaw{data: data, pos: i + 1, kind: kind}
+	}
+	return items
+}
+
+// addMCPMemoryItem normalizes raw and appends it to g, or explains why it can't
+func (g *KnowledgeGraph) addMCPMemoryItem(raw mcpMemoryRaw) error {
+	var item mcpMemoryItem
+	if err := json.Unmarshal(raw.data, &item); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	entityType := cmp.Or(item.EntityType, item.SnakeEntityType)
+	kind := item.Type
+	if kind != "entity" && kind != "relation" {
+		if raw.kind == "entity" && entityType == "" {
+			entityType = item.Type
+		}
+		switch {
+		case raw.kind != "":
+			kind = raw.kind
+		case item.Name != "":
+			kind = "entity"
+		case cmp.Or(item.From, item.Source) != "":
+			kind = "relation"
+		default:
+			return fmt.Errorf("cannot tell whether the item is an entity or a relation")
+		}
+	}
+
+	if kind == "entity" {
+		if item.Name == "" {
+			return fmt.Errorf("entity has no name")
+		}
+		observations := item.Observations
+		if observations == nil {
+			observations = []string{}
+		}
+		g.Entities = append(g.Entities, Entity{Name: item.Name, EntityType: entityType, Observations: observations})
+		return nil
+	}
+
+	relation := Relation{
+		From:         cmp.Or(item.From, item.Source),
+		To:           cmp.Or(item.To, item.Target),
+		RelationType: cmp.Or(item.RelationType, item.SnakeRelationType),
+	}
+	if relation.From == "" || relation.To == "" || relation.RelationType == "" {
+		return fmt.Errorf("relation needs from, to, and relationType")
+	}
+	g.Relations = append(g.Relations, relation)
+	return nil
+}
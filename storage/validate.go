@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ValidationReport lists what ValidateJSONL found in a JSONL file
+type ValidationReport struct {
+	Path      string        `json:"path"`
+	Lines     int           `json:"lines"` // including blank lines
+	Entities  int           `json:"entities"`
+	Relations int           `json:"relations"`
+	Errors    []LoadWarning `json:"errors"` // lines a load would skip
+	Valid     bool          `json:"valid"`
+}
+
+// ValidateJSONL checks every line of a JSONL memory file (gzip or plain) the way
+// loading it would, reading it one line at a time rather than into memory
+func ValidateJSONL(path string) (*ValidationReport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	if header, _ := reader.Peek(len(encryptedMagic)); isEncrypted(header) {
+		return nil, fmt.Errorf("%w: %s is encrypted and cannot be validated", ErrEncryptionKey, path)
+	}
+	if header, _ := reader.Peek(len(gzipMagic)); bytes.Equal(header, gzipMagic) || IsCompressedPath(path) {
+		zr, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip file %s: %w", path, err)
+		}
+		defer zr.Close()
+		reader = bufio.NewReader(zr)
+	}
+
+	report := &ValidationReport{Path: path, Errors: []LoadWarning{}}
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			report.Lines++
+			if line = bytes.TrimSpace(line); len(line) > 0 {
+				entity, _, decodeErr := decodeJSONLLine(line)
+				switch {
+				case decodeErr != nil:
+					report.Errors = append(report.Errors, LoadWarning{Line: report.Lines, Reason: decodeErr.Error()})
+				case entity != nil:
+					report.Entities++
+				default:
+					report.Relations++
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+	}
+	report.Valid = len(report.Errors) == 0
+	return report, nil
+}